@@ -0,0 +1,298 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// AlertTag is set in all samples while at least one alert is active. The value is a comma-separated list
+// of the names of all active alerts.
+const AlertTag = "alert"
+
+// AlertRule describes a threshold on the value of a metric, or on its rate of change per second.
+// An alert is triggered when the threshold is exceeded, and resolved when the value returns below the threshold.
+type AlertRule struct {
+	Name      string
+	Metric    string
+	Rate      bool
+	Below     bool
+	Threshold float64
+}
+
+var alertRuleRegex = regexp.MustCompile(`^\s*([^:\s]+)\s*:\s*(rate\(\s*([^)\s]+)\s*\)|([^<>\s]+))\s*([<>])\s*([^\s]+)\s*$`)
+
+// ParseAlertRule parses an alert rule in the format "name: metric > threshold" or "name: rate(metric) < threshold".
+func ParseAlertRule(rule string) (AlertRule, error) {
+	match := alertRuleRegex.FindStringSubmatch(rule)
+	if match == nil {
+		return AlertRule{}, fmt.Errorf("Invalid alert rule '%v', expected format: 'name: metric > threshold' or 'name: rate(metric) < threshold'", rule)
+	}
+	threshold, err := strconv.ParseFloat(match[6], 64)
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("Invalid threshold in alert rule '%v': %v", rule, err)
+	}
+	res := AlertRule{
+		Name:      match[1],
+		Metric:    match[4],
+		Below:     match[5] == "<",
+		Threshold: threshold,
+	}
+	if match[3] != "" {
+		res.Metric = match[3]
+		res.Rate = true
+	}
+	return res, nil
+}
+
+func (rule AlertRule) String() string {
+	metric := rule.Metric
+	if rule.Rate {
+		metric = "rate(" + metric + ")"
+	}
+	op := ">"
+	if rule.Below {
+		op = "<"
+	}
+	return fmt.Sprintf("%v: %v %v %v", rule.Name, metric, op, rule.Threshold)
+}
+
+func (rule AlertRule) isTriggered(value float64) bool {
+	if rule.Below {
+		return value < rule.Threshold
+	}
+	return value > rule.Threshold
+}
+
+// AlertEvent is passed to all AlertActions when an alert is triggered (Active is true) or resolved.
+type AlertEvent struct {
+	Alert  string    `json:"alert"`
+	Rule   string    `json:"rule"`
+	Metric string    `json:"metric"`
+	Value  float64   `json:"value"`
+	Active bool      `json:"active"`
+	Time   time.Time `json:"time"`
+}
+
+// DefaultAlertActionTimeout is used by the AlertActions that have no explicit Timeout configured.
+const DefaultAlertActionTimeout = 10 * time.Second
+
+func alertActionTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultAlertActionTimeout
+	}
+	return timeout
+}
+
+// AlertAction is executed for every AlertEvent. Actions are executed asynchronously, outside of the collection routines.
+type AlertAction interface {
+	Execute(event AlertEvent) error
+	String() string
+}
+
+// WebhookAlertAction sends every AlertEvent as JSON in the body of a POST request to the given URL.
+// Requests that do not finish within Timeout (default DefaultAlertActionTimeout) are aborted.
+type WebhookAlertAction struct {
+	Url     string
+	Timeout time.Duration
+}
+
+func (action *WebhookAlertAction) Execute(event AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: alertActionTimeout(action.Timeout)}
+	resp, err := client.Post(action.Url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook returned status %v", resp.Status)
+	}
+	return nil
+}
+
+func (action *WebhookAlertAction) String() string {
+	return "webhook " + action.Url
+}
+
+// ExecAlertAction executes the given shell command for every AlertEvent. The event is described in the
+// environment variables BITFLOW_ALERT, BITFLOW_ALERT_RULE, BITFLOW_ALERT_METRIC, BITFLOW_ALERT_VALUE
+// and BITFLOW_ALERT_STATE (either "active" or "resolved"). Commands that do not finish within Timeout
+// (default DefaultAlertActionTimeout) are killed.
+type ExecAlertAction struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (action *ExecAlertAction) Execute(event AlertEvent) error {
+	state := "resolved"
+	if event.Active {
+		state = "active"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertActionTimeout(action.Timeout))
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", action.Command)
+	cmd.Env = append(os.Environ(),
+		"BITFLOW_ALERT="+event.Alert,
+		"BITFLOW_ALERT_RULE="+event.Rule,
+		"BITFLOW_ALERT_METRIC="+event.Metric,
+		"BITFLOW_ALERT_VALUE="+strconv.FormatFloat(event.Value, 'g', -1, 64),
+		"BITFLOW_ALERT_STATE="+state)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("Command did not finish within %v", alertActionTimeout(action.Timeout))
+		}
+		return fmt.Errorf("%v (output: %v)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (action *ExecAlertAction) String() string {
+	return "command '" + action.Command + "'"
+}
+
+// alertState contains the alerts that are currently active. It is shared between restarts of the metric collection.
+type alertState struct {
+	lock   sync.Mutex
+	active map[string]bool
+	tag    string
+}
+
+func (state *alertState) set(name string, active bool) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if state.active == nil {
+		state.active = make(map[string]bool)
+	}
+	if active {
+		state.active[name] = true
+	} else {
+		delete(state.active, name)
+	}
+	names := make([]string, 0, len(state.active))
+	for name := range state.active {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	state.tag = strings.Join(names, ",")
+}
+
+func (state *alertState) isActive(name string) bool {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	return state.active[name]
+}
+
+func (state *alertState) activeTag() string {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	return state.tag
+}
+
+// ActiveAlerts returns the names of all currently active alerts.
+func (source *SampleSource) ActiveAlerts() []string {
+	if tag := source.alerts.activeTag(); tag != "" {
+		return strings.Split(tag, ",")
+	}
+	return nil
+}
+
+type alertEvaluator struct {
+	source *SampleSource
+	rules  []*evaluatedAlertRule
+}
+
+type evaluatedAlertRule struct {
+	AlertRule
+	index        int
+	lastValue    float64
+	lastTime     time.Time
+	hasLastValue bool
+}
+
+func (source *SampleSource) newAlertEvaluator(fields []string) *alertEvaluator {
+	if len(source.AlertRules) == 0 {
+		return nil
+	}
+	indices := make(map[string]int, len(fields))
+	for i, field := range fields {
+		indices[field] = i
+	}
+	evaluator := &alertEvaluator{source: source}
+	for _, rule := range source.AlertRules {
+		index, ok := indices[rule.Metric]
+		if !ok {
+			log.Warnf("Metric %v of alert rule '%v' is not collected, ignoring the rule", rule.Metric, rule)
+			continue
+		}
+		evaluator.rules = append(evaluator.rules, &evaluatedAlertRule{AlertRule: rule, index: index})
+	}
+	return evaluator
+}
+
+// evaluate checks all alert rules against the given sample, triggers the configured actions for
+// changed alert states, and tags the sample with the active alerts.
+func (evaluator *alertEvaluator) evaluate(sample *bitflow.Sample) {
+	if evaluator == nil {
+		return
+	}
+	state := &evaluator.source.alerts
+	for _, rule := range evaluator.rules {
+		value := float64(sample.Values[rule.index])
+		if rule.Rate {
+			previous, previousTime, hasPrevious := rule.lastValue, rule.lastTime, rule.hasLastValue
+			rule.lastValue, rule.lastTime, rule.hasLastValue = value, sample.Time, true
+			elapsed := sample.Time.Sub(previousTime).Seconds()
+			if !hasPrevious || elapsed <= 0 {
+				continue
+			}
+			value = (value - previous) / elapsed
+		}
+		triggered := rule.isTriggered(value)
+		if triggered != state.isActive(rule.Name) {
+			state.set(rule.Name, triggered)
+			evaluator.source.executeAlertActions(AlertEvent{
+				Alert:  rule.Name,
+				Rule:   rule.AlertRule.String(),
+				Metric: rule.Metric,
+				Value:  value,
+				Active: triggered,
+				Time:   sample.Time,
+			})
+		}
+	}
+	if tag := state.activeTag(); tag != "" {
+		sample.SetTag(AlertTag, tag)
+	}
+}
+
+func (source *SampleSource) executeAlertActions(event AlertEvent) {
+	if event.Active {
+		log.Warnf("Alert %v triggered (%v = %v)", event.Rule, event.Metric, event.Value)
+	} else {
+		log.Printf("Alert %v resolved (%v = %v)", event.Rule, event.Metric, event.Value)
+	}
+	for _, action := range source.AlertActions {
+		go func(action AlertAction) {
+			if err := action.Execute(event); err != nil {
+				log.Warnf("Alert action %v failed for alert %v: %v", action, event.Alert, err)
+			}
+		}(action)
+	}
+}
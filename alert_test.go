@@ -0,0 +1,184 @@
+package collector
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type AlertTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestAlert(t *testing.T) {
+	suite.Run(t, new(AlertTestSuite))
+}
+
+type recordingAlertAction struct {
+	events chan AlertEvent
+}
+
+func (action *recordingAlertAction) Execute(event AlertEvent) error {
+	action.events <- event
+	return nil
+}
+
+func (action *recordingAlertAction) String() string {
+	return "recording"
+}
+
+func (suite *AlertTestSuite) TestParseAlertRule() {
+	for _, test := range []struct {
+		rule     string
+		expected AlertRule
+		err      bool
+	}{
+		{"high-cpu: cpu > 80", AlertRule{Name: "high-cpu", Metric: "cpu", Threshold: 80}, false},
+		{"  low-mem:mem/free<1e6 ", AlertRule{Name: "low-mem", Metric: "mem/free", Below: true, Threshold: 1e6}, false},
+		{"net: rate( net-io/bytes ) > 1000", AlertRule{Name: "net", Metric: "net-io/bytes", Rate: true, Threshold: 1000}, false},
+		{"neg: temp < -5.5", AlertRule{Name: "neg", Metric: "temp", Below: true, Threshold: -5.5}, false},
+		{"cpu > 80", AlertRule{}, true},
+		{"x: cpu = 80", AlertRule{}, true},
+		{"x: cpu > high", AlertRule{}, true},
+		{"", AlertRule{}, true},
+	} {
+		rule, err := ParseAlertRule(test.rule)
+		if test.err {
+			suite.Error(err, test.rule)
+		} else {
+			suite.NoError(err, test.rule)
+			suite.Equal(test.expected, rule, test.rule)
+		}
+	}
+}
+
+func (suite *AlertTestSuite) TestEvaluate() {
+	type step struct {
+		values []bitflow.Value
+		tag    string
+		events []bool // Active flag of the expected events
+	}
+	for _, test := range []struct {
+		name  string
+		rule  string
+		steps []step
+	}{
+		{"above", "a: x > 10", []step{
+			{[]bitflow.Value{5, 0}, "", nil},
+			{[]bitflow.Value{15, 0}, "a", []bool{true}},
+			{[]bitflow.Value{20, 0}, "a", nil},
+			{[]bitflow.Value{10, 0}, "", []bool{false}},
+		}},
+		{"below", "b: y < 0", []step{
+			{[]bitflow.Value{0, -1}, "b", []bool{true}},
+			{[]bitflow.Value{0, 1}, "", []bool{false}},
+		}},
+		{"rate", "r: rate(x) > 5", []step{
+			{[]bitflow.Value{100, 0}, "", nil},
+			{[]bitflow.Value{110, 0}, "r", []bool{true}},
+			{[]bitflow.Value{112, 0}, "", []bool{false}},
+		}},
+		{"unknown metric", "u: z > 0", []step{
+			{[]bitflow.Value{100, 100}, "", nil},
+		}},
+	} {
+		rule, err := ParseAlertRule(test.rule)
+		suite.NoError(err)
+		action := &recordingAlertAction{events: make(chan AlertEvent, 10)}
+		source := &SampleSource{AlertRules: []AlertRule{rule}, AlertActions: []AlertAction{action}}
+		evaluator := source.newAlertEvaluator([]string{"x", "y"})
+
+		start := time.Now()
+		for i, step := range test.steps {
+			sample := &bitflow.Sample{Values: step.values, Time: start.Add(time.Duration(i) * time.Second)}
+			evaluator.evaluate(sample)
+			suite.Equal(step.tag, sample.Tag(AlertTag), "%v, step %v", test.name, i)
+			for _, active := range step.events {
+				select {
+				case event := <-action.events:
+					suite.Equal(rule.Name, event.Alert, test.name)
+					suite.Equal(active, event.Active, "%v, step %v", test.name, i)
+				case <-time.After(time.Second):
+					suite.Fail("Missing alert event", "%v, step %v", test.name, i)
+				}
+			}
+			suite.Empty(action.events, "%v, step %v", test.name, i)
+		}
+	}
+}
+
+func (suite *AlertTestSuite) TestWebhookAlertAction() {
+	for _, test := range []struct {
+		name  string
+		delay time.Duration
+		code  int
+		err   bool
+	}{
+		{"success", 0, http.StatusOK, false},
+		{"error status", 0, http.StatusInternalServerError, true},
+		{"timeout", time.Second, http.StatusOK, true},
+	} {
+		func() {
+			done := make(chan bool)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(test.delay):
+				case <-done:
+				}
+				w.WriteHeader(test.code)
+			}))
+			defer server.Close()
+			defer close(done)
+			action := &WebhookAlertAction{Url: server.URL, Timeout: 100 * time.Millisecond}
+			start := time.Now()
+			err := action.Execute(AlertEvent{Alert: "a", Active: true})
+			if test.err {
+				suite.Error(err, test.name)
+			} else {
+				suite.NoError(err, test.name)
+			}
+			suite.True(time.Since(start) < 500*time.Millisecond, test.name)
+		}()
+	}
+}
+
+func (suite *AlertTestSuite) TestExecAlertAction() {
+	dir, err := ioutil.TempDir("", "alert")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	output := filepath.Join(dir, "output")
+	for _, test := range []struct {
+		name    string
+		command string
+		output  string
+		err     bool
+	}{
+		{"success", "echo \"$BITFLOW_ALERT $BITFLOW_ALERT_VALUE $BITFLOW_ALERT_STATE\" > " + output, "a 1.5 active\n", false},
+		{"failure", "exit 1", "", true},
+		{"timeout", "exec sleep 5", "", true},
+	} {
+		_ = os.Remove(output)
+		action := &ExecAlertAction{Command: test.command, Timeout: 100 * time.Millisecond}
+		start := time.Now()
+		err := action.Execute(AlertEvent{Alert: "a", Value: 1.5, Active: true})
+		if test.err {
+			suite.Error(err, test.name)
+		} else {
+			suite.NoError(err, test.name)
+			data, err := ioutil.ReadFile(output)
+			suite.NoError(err, test.name)
+			suite.Equal(test.output, string(data), test.name)
+		}
+		suite.True(time.Since(start) < 2*time.Second, test.name)
+	}
+}
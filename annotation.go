@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// annotationState contains tags that are added to all outgoing samples while they are active.
+// Annotations are typically pushed from external components, e.g. to label the current phase of an experiment.
+type annotationState struct {
+	lock   sync.Mutex
+	values map[string]annotation
+}
+
+type annotation struct {
+	value   string
+	expires time.Time
+}
+
+// Annotate adds the tag key=value to all outgoing samples. If the duration is positive, the annotation is removed
+// automatically after that duration, otherwise it stays active until RemoveAnnotation() is called.
+// An existing annotation with the same key is replaced.
+func (source *SampleSource) Annotate(key string, value string, duration time.Duration) {
+	state := &source.annotations
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if state.values == nil {
+		state.values = make(map[string]annotation)
+	}
+	var expires time.Time
+	if duration > 0 {
+		expires = time.Now().Add(duration)
+	}
+	state.values[key] = annotation{value: value, expires: expires}
+}
+
+// RemoveAnnotation removes the annotation with the given key and returns whether it was active.
+func (source *SampleSource) RemoveAnnotation(key string) bool {
+	state := &source.annotations
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.removeExpired(time.Now())
+	_, ok := state.values[key]
+	delete(state.values, key)
+	return ok
+}
+
+// Annotations returns all active annotations, see Annotate().
+func (source *SampleSource) Annotations() map[string]string {
+	state := &source.annotations
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.removeExpired(time.Now())
+	res := make(map[string]string, len(state.values))
+	for key, val := range state.values {
+		res[key] = val.value
+	}
+	return res
+}
+
+func (state *annotationState) apply(sample *bitflow.Sample) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.removeExpired(sample.Time)
+	for key, val := range state.values {
+		sample.SetTag(key, val.value)
+	}
+}
+
+func (state *annotationState) removeExpired(now time.Time) {
+	for key, val := range state.values {
+		if !val.expires.IsZero() && !now.Before(val.expires) {
+			delete(state.values, key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"math"
+	"regexp"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// AnomalyScoreMetric is the name of the metric added by SampleSource.AnomalyMetrics.
+const AnomalyScoreMetric = "anomaly-score"
+
+// zScoreDetector is a lightweight online anomaly detector. It keeps an exponentially weighted mean and variance
+// of every observed metric. The anomaly score of a sample is the root mean square of the z-scores of all observed
+// metrics, computed before the sample is incorporated into the mean and variance.
+type zScoreDetector struct {
+	indices  []int
+	alpha    float64
+	warmup   int
+	mean     []float64
+	variance []float64
+
+	numSamples int
+}
+
+func newZScoreDetector(fields []string, regexes []*regexp.Regexp, windowSamples int) *zScoreDetector {
+	var indices []int
+	for i, field := range fields {
+		if matchesAny(field, regexes) {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		log.Warnln("None of the", len(fields), "metrics are selected for computing the", AnomalyScoreMetric, "metric")
+		return nil
+	}
+	if windowSamples < 1 {
+		windowSamples = 1
+	}
+	log.Debugln("Computing", AnomalyScoreMetric, "based on", len(indices), "metrics")
+	return &zScoreDetector{
+		indices:  indices,
+		alpha:    2 / (float64(windowSamples) + 1),
+		warmup:   windowSamples,
+		mean:     make([]float64, len(indices)),
+		variance: make([]float64, len(indices)),
+	}
+}
+
+// score computes the anomaly score of the given sample values and updates the statistics of the observed metrics.
+// The score is zero until the window is filled for the first time.
+func (d *zScoreDetector) score(values []bitflow.Value) bitflow.Value {
+	var sum float64
+	for i, index := range d.indices {
+		x := float64(values[index])
+		if math.IsNaN(x) {
+			// Missing values (e.g. padded metrics) do not contribute to the score
+			continue
+		}
+		if d.numSamples == 0 {
+			d.mean[i] = x
+			continue
+		}
+		diff := x - d.mean[i]
+		if d.variance[i] > 0 {
+			z := diff / math.Sqrt(d.variance[i])
+			sum += z * z
+		}
+		incr := d.alpha * diff
+		d.mean[i] += incr
+		d.variance[i] = (1 - d.alpha) * (d.variance[i] + diff*incr)
+	}
+	d.numSamples++
+	if d.numSamples <= d.warmup {
+		return 0
+	}
+	return bitflow.Value(math.Sqrt(sum / float64(len(d.indices))))
+}
+
+func (source *SampleSource) addAnomalyScore(fields []string, getValues func() []bitflow.Value) ([]string, func() []bitflow.Value) {
+	if len(source.AnomalyMetrics) == 0 {
+		return fields, getValues
+	}
+	windowSamples := int(float64(source.AnomalyWindow) / float64(source.SinkInterval))
+	detector := newZScoreDetector(fields, source.AnomalyMetrics, windowSamples)
+	if detector == nil {
+		return fields, getValues
+	}
+	fields = append(fields, AnomalyScoreMetric)
+	source.currentMetadata[AnomalyScoreMetric] = GaugeMetric(UnitNone, "Root mean square of the z-scores of the observed metrics")
+	return fields, func() []bitflow.Value {
+		values := getValues()
+		return append(values, detector.score(values))
+	}
+}
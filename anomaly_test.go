@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type AnomalyTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestAnomaly(t *testing.T) {
+	suite.Run(t, new(AnomalyTestSuite))
+}
+
+func (suite *AnomalyTestSuite) TestNoMatchingMetrics() {
+	suite.Nil(newZScoreDetector([]string{"cpu", "mem"}, []*regexp.Regexp{regexp.MustCompile("^disk")}, 5))
+}
+
+func (suite *AnomalyTestSuite) TestScore() {
+	fields := []string{"cpu", "mem", "ignored"}
+	regexes := []*regexp.Regexp{regexp.MustCompile("^(cpu|mem)$")}
+	training := [][]bitflow.Value{{10, 100, 0}, {12, 100, 0}, {10, 100, 0}, {12, 100, 0}, {10, 100, 0}, {12, 100, 0}}
+
+	for _, test := range []struct {
+		name    string
+		window  int
+		sample  []bitflow.Value
+		minimum float64
+		maximum float64
+	}{
+		{"warmup", 10, []bitflow.Value{1000, 100, 0}, 0, 0},
+		{"normal", 5, []bitflow.Value{11, 100, 0}, 0, 1},
+		{"ignored metric", 5, []bitflow.Value{11, 100, 1000}, 0, 1},
+		{"constant metric", 5, []bitflow.Value{11, 5000, 0}, 0, 1},
+		{"outlier", 5, []bitflow.Value{100, 100, 0}, 10, 1000},
+	} {
+		detector := newZScoreDetector(fields, regexes, test.window)
+		suite.NotNil(detector, test.name)
+		for _, values := range training {
+			detector.score(values)
+		}
+		score := float64(detector.score(test.sample))
+		suite.True(score >= test.minimum && score <= test.maximum,
+			"%v: score %v not in [%v, %v]", test.name, score, test.minimum, test.maximum)
+	}
+}
@@ -0,0 +1,194 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
+
+// Audit record types from linux/audit.h, with the names used in the metrics
+var recordTypes = map[uint16]string{
+	1100: "user_auth",
+	1101: "user_acct",
+	1105: "user_start",
+	1106: "user_end",
+	1107: "user_avc",
+	1112: "user_login",
+	1130: "service_start",
+	1131: "service_stop",
+	1300: "syscall",
+	1309: "execve",
+	1326: "seccomp",
+	1400: "avc",
+	1503: "apparmor_denied",
+	1701: "anom_abend",
+}
+
+// RecordTypes returns the names of all supported audit record types
+func RecordTypes() []string {
+	res := make([]string, 0, len(recordTypes))
+	for _, name := range recordTypes {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Collector reports the rates of audit records received from the kernel audit subsystem. The records are received
+// through the read-only multicast group of the audit netlink socket, so the collector can run next to auditd.
+// This requires Linux 3.16 and the CAP_AUDIT_READ capability. Most record types, like execve, are only generated
+// for configured audit rules (e.g. 'auditctl -a always,exit -F arch=b64 -S execve').
+// Metrics are named audit/records (all records), audit/type/<type> for the selected record types,
+// audit/denied for system calls that failed with EACCES or EPERM, and audit/mac-denied/{selinux,apparmor}
+// for access denials of the mandatory access control systems. MAC denials are reported by the kernel
+// without any configured audit rules.
+type Collector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	socket         *auditSocket
+	total          counter
+	denied         counter
+	selinuxDenied  counter
+	apparmorDenied counter
+	counters       map[uint16]*counter
+}
+
+type counter struct {
+	// Accessed atomically. Must be the first field to guarantee 64-bit alignment on 32-bit platforms.
+	value uint64
+	ring  *collector.ValueRing
+}
+
+func (c *counter) increment() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *counter) update() {
+	c.ring.Add(collector.StoredValue(atomic.LoadUint64(&c.value)))
+}
+
+// NewAuditCollector returns a collector for the given audit record types (see RecordTypes()).
+// All supported record types are selected if the list is empty.
+func NewAuditCollector(types []string, factory *collector.ValueRingFactory) (*Collector, error) {
+	col := &Collector{
+		AbstractCollector: collector.RootCollector("audit"),
+		factory:           factory,
+		total:             counter{ring: factory.NewValueRing()},
+		denied:            counter{ring: factory.NewValueRing()},
+		selinuxDenied:     counter{ring: factory.NewValueRing()},
+		apparmorDenied:    counter{ring: factory.NewValueRing()},
+		counters:          make(map[uint16]*counter),
+	}
+	selected := make(map[string]bool, len(types))
+	for _, name := range types {
+		selected[strings.ToLower(name)] = true
+	}
+	all := len(selected) == 0
+	for recordType, name := range recordTypes {
+		if all || selected[name] {
+			col.counters[recordType] = &counter{ring: factory.NewValueRing()}
+			delete(selected, name)
+		}
+	}
+	for name := range selected {
+		return nil, fmt.Errorf("Unknown audit record type '%v', available: %v", name, strings.Join(RecordTypes(), ", "))
+	}
+	return col, nil
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if col.socket == nil {
+		// The socket stays open when the collectors are restarted, to avoid missing records
+		socket, err := openAuditSocket()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open the audit netlink socket: %v", err)
+		}
+		col.socket = socket
+		go col.socket.receive(col.handleRecord)
+	}
+	return nil, nil
+}
+
+func (col *Collector) handleRecord(recordType uint16, data []byte) {
+	col.total.increment()
+	if c, ok := col.counters[recordType]; ok {
+		c.increment()
+	}
+	switch recordType {
+	case 1300:
+		if isDenied(string(data)) {
+			col.denied.increment()
+		}
+	case 1107, 1400, 1503:
+		switch macDenial(recordType, string(data)) {
+		case "selinux":
+			col.selinuxDenied.increment()
+		case "apparmor":
+			col.apparmorDenied.increment()
+		}
+	}
+}
+
+// macDenial checks if the given AVC, USER_AVC or APPARMOR_DENIED record describes an access denial, and returns
+// the name of the responsible access control system. AppArmor reports denials as AVC records with
+// apparmor="DENIED" on recent kernels, while SELinux AVC records contain "avc:  denied".
+func macDenial(recordType uint16, record string) string {
+	switch {
+	case recordType == 1503 || strings.Contains(record, `apparmor="DENIED"`):
+		return "apparmor"
+	case strings.Contains(record, "avc:  denied") || strings.Contains(record, "avc: denied"):
+		return "selinux"
+	}
+	return ""
+}
+
+// isDenied checks if the given SYSCALL record describes a system call that failed with EACCES (-13) or EPERM (-1)
+func isDenied(record string) bool {
+	if !strings.Contains(record, " success=no ") {
+		return false
+	}
+	return strings.Contains(record, " exit=-13 ") || strings.Contains(record, " exit=-1 ")
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	col.total.update()
+	col.denied.update()
+	col.selinuxDenied.update()
+	col.apparmorDenied.update()
+	for _, c := range col.counters {
+		c.update()
+	}
+	return nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	res := collector.MetricReaderMap{
+		"audit/records":             col.total.ring.GetDiff,
+		"audit/denied":              col.denied.ring.GetDiff,
+		"audit/mac-denied/selinux":  col.selinuxDenied.ring.GetDiff,
+		"audit/mac-denied/apparmor": col.apparmorDenied.ring.GetDiff,
+	}
+	for recordType, c := range col.counters {
+		res["audit/type/"+recordTypes[recordType]] = c.ring.GetDiff
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	res := collector.MetricMetadataMap{
+		"audit/records":             collector.DerivedMetric(collector.UnitPerSecond, "Received audit records"),
+		"audit/denied":              collector.DerivedMetric(collector.UnitPerSecond, "Audited system calls that failed with EACCES or EPERM"),
+		"audit/mac-denied/selinux":  collector.DerivedMetric(collector.UnitPerSecond, "Access denials of SELinux (AVC records)"),
+		"audit/mac-denied/apparmor": collector.DerivedMetric(collector.UnitPerSecond, "Access denials of AppArmor"),
+	}
+	for recordType := range col.counters {
+		name := recordTypes[recordType]
+		res["audit/type/"+name] = collector.DerivedMetric(collector.UnitPerSecond, "Received audit records of type "+strings.ToUpper(name))
+	}
+	return res
+}
@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestAudit(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) TestIsDenied() {
+	for _, test := range []struct {
+		record string
+		denied bool
+	}{
+		{"audit(1700000000.123:42): arch=c000003e syscall=2 success=no exit=-13 a0=7ffd items=1 pid=100 comm=\"cat\"", true},
+		{"audit(1700000000.123:43): arch=c000003e syscall=105 success=no exit=-1 a0=0 items=0 pid=101 comm=\"su\"", true},
+		{"audit(1700000000.123:44): arch=c000003e syscall=2 success=no exit=-2 a0=7ffd items=1 pid=100 comm=\"cat\"", false},
+		{"audit(1700000000.123:45): arch=c000003e syscall=2 success=yes exit=3 a0=7ffd items=1 pid=100 comm=\"cat\"", false},
+		{"audit(1700000000.123:46): arch=c000003e syscall=2 success=no exit=-130 a0=7ffd items=1 pid=100", false},
+		{"", false},
+	} {
+		suite.Equal(test.denied, isDenied(test.record), test.record)
+	}
+}
+
+func (suite *AuditTestSuite) TestMacDenial() {
+	for _, test := range []struct {
+		recordType uint16
+		record     string
+		system     string
+	}{
+		{1400, `audit(1700000000.123:42): avc:  denied  { read } for  pid=100 comm="httpd" name="index.html" scontext=system_u:system_r:httpd_t:s0`, "selinux"},
+		{1107, `audit(1700000000.123:43): pid=1 uid=0 msg='avc: denied { start } for auid=n/a uid=0 gid=0 cmdline=""'`, "selinux"},
+		{1400, `audit(1700000000.123:44): avc:  granted  { setsecparam } for  pid=100 comm="load_policy"`, ""},
+		{1400, `audit(1700000000.123:45): apparmor="DENIED" operation="open" profile="/usr/sbin/cupsd" name="/etc/shadow"`, "apparmor"},
+		{1400, `audit(1700000000.123:46): apparmor="ALLOWED" operation="open" profile="/usr/sbin/cupsd"`, ""},
+		{1503, `audit(1700000000.123:47): operation="open" profile="/usr/sbin/cupsd" name="/etc/shadow"`, "apparmor"},
+	} {
+		suite.Equal(test.system, macDenial(test.recordType, test.record), test.record)
+	}
+}
+
+func (suite *AuditTestSuite) TestSelectRecordTypes() {
+	factory := &collector.ValueRingFactory{Length: 10, Interval: time.Second}
+	for _, test := range []struct {
+		types   []string
+		metrics int
+		err     bool
+	}{
+		{nil, len(recordTypes) + 4, false},
+		{[]string{"EXECVE", "avc"}, 6, false},
+		{[]string{"execve", "unknown"}, 0, true},
+	} {
+		col, err := NewAuditCollector(test.types, factory)
+		if test.err {
+			suite.Error(err, "%v", test.types)
+			continue
+		}
+		suite.NoError(err, "%v", test.types)
+		suite.Len(col.Metrics(), test.metrics, "%v", test.types)
+		suite.Len(col.MetricsMetadata(), test.metrics, "%v", test.types)
+	}
+}
+
+func (suite *AuditTestSuite) TestHandleRecord() {
+	col, err := NewAuditCollector([]string{"syscall", "execve"}, &collector.ValueRingFactory{Length: 10, Interval: time.Minute})
+	suite.NoError(err)
+	for _, record := range []struct {
+		recordType uint16
+		data       string
+	}{
+		{1300, "arch=c000003e syscall=59 success=yes exit=0 items=2"},
+		{1309, "argc=1 a0=\"ls\""},
+		{1300, "arch=c000003e syscall=2 success=no exit=-13 items=1"},
+		{1400, "avc: denied { read }"},
+		{1300, "arch=c000003e syscall=2 success=no exit=-1 items=1"},
+		{1503, "apparmor=\"DENIED\" operation=\"open\""},
+	} {
+		col.handleRecord(record.recordType, []byte(record.data))
+	}
+	suite.NoError(col.Update(context.Background()))
+	suite.Equal(uint64(6), col.total.value)
+	suite.Equal(uint64(2), col.denied.value)
+	suite.Equal(uint64(1), col.selinuxDenied.value)
+	suite.Equal(uint64(1), col.apparmorDenied.value)
+	suite.Equal(uint64(3), col.counters[1300].value)
+	suite.Equal(uint64(1), col.counters[1309].value)
+	suite.Equal(collector.StoredValue(6), col.total.ring.GetHead())
+	suite.Equal(collector.StoredValue(2), col.denied.ring.GetHead())
+}
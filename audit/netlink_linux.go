@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	netlinkAudit = 9 // NETLINK_AUDIT from linux/netlink.h
+
+	// AUDIT_NLGRP_READLOG from linux/audit.h, the multicast group for read-only listeners
+	auditGroupReadlog = 1
+)
+
+type auditSocket struct {
+	fd  int
+	buf []byte
+}
+
+func openAuditSocket() (*auditSocket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkAudit)
+	if err != nil {
+		return nil, err
+	}
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: 1 << (auditGroupReadlog - 1),
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("Failed to join the audit multicast group (requires CAP_AUDIT_READ): %v", err)
+	}
+	return &auditSocket{
+		fd:  fd,
+		buf: make([]byte, os.Getpagesize()*4),
+	}, nil
+}
+
+// receive reads audit records from the socket and passes them to the given handler, until the socket fails
+func (s *auditSocket) receive(handler func(recordType uint16, data []byte)) {
+	for {
+		n, _, err := syscall.Recvfrom(s.fd, s.buf, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			if err == syscall.ENOBUFS {
+				log.Warnln("Audit records were lost, the receive buffer of the audit socket overflowed")
+				continue
+			}
+			log.Errorln("Stopped receiving audit records:", err)
+			return
+		}
+		messages, err := syscall.ParseNetlinkMessage(s.buf[:n])
+		if err != nil {
+			log.Warnln("Failed to parse audit netlink message:", err)
+			continue
+		}
+		for _, msg := range messages {
+			handler(msg.Header.Type, msg.Data)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// +build !linux
+
+package audit
+
+import "errors"
+
+type auditSocket struct {
+}
+
+func openAuditSocket() (*auditSocket, error) {
+	return nil, errors.New("The audit subsystem is only available on Linux")
+}
+
+func (s *auditSocket) receive(handler func(recordType uint16, data []byte)) {
+}
@@ -0,0 +1,175 @@
+package bcache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const DefaultSysBlock = "/sys/block"
+
+// Collector reports the cache statistics of all bcache devices, read from /sys/block/bcache*/bcache.
+// Metrics are named bcache/<device>/...
+type Collector struct {
+	collector.AbstractCollector
+	SysBlock string
+	factory  *collector.ValueRingFactory
+
+	devices []string
+}
+
+func NewBcacheCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("bcache"),
+		SysBlock:          DefaultSysBlock,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	devices, err := col.listDevices()
+	if err != nil {
+		return nil, err
+	}
+	col.devices = devices
+	res := make([]collector.Collector, len(devices))
+	for i, device := range devices {
+		res[i] = &deviceCollector{
+			AbstractCollector: col.Child(device),
+			parent:            col,
+			dir:               filepath.Join(col.SysBlock, device, "bcache"),
+			hits:              col.factory.NewValueRing(),
+			misses:            col.factory.NewValueRing(),
+			bypassHits:        col.factory.NewValueRing(),
+			bypassMisses:      col.factory.NewValueRing(),
+			bypassed:          col.factory.NewValueRing(),
+		}
+	}
+	return res, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	devices, err := col.listDevices()
+	if err != nil {
+		return err
+	}
+	if strings.Join(devices, ",") != strings.Join(col.devices, ",") {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) listDevices() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(col.SysBlock, "bcache*", "bcache"))
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]string, len(dirs))
+	for i, dir := range dirs {
+		devices[i] = filepath.Base(filepath.Dir(dir))
+	}
+	return devices, nil
+}
+
+type deviceCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+	dir    string
+
+	hits, misses, bypassHits, bypassMisses, bypassed *collector.ValueRing
+	dirty                                            float64
+}
+
+func (col *deviceCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *deviceCollector) Update(ctx context.Context) error {
+	var values [6]float64
+	for i, file := range []string{"stats_total/cache_hits", "stats_total/cache_misses", "stats_total/cache_bypass_hits",
+		"stats_total/cache_bypass_misses", "stats_total/bypassed", "dirty_data"} {
+		data, err := ioutil.ReadFile(filepath.Join(col.dir, file))
+		if err != nil {
+			return err
+		}
+		if values[i], err = parseSize(strings.TrimSpace(string(data))); err != nil {
+			return fmt.Errorf("Failed to parse %v of %v: %v", file, col.Name, err)
+		}
+	}
+	col.hits.Add(collector.StoredValue(values[0]))
+	col.misses.Add(collector.StoredValue(values[1]))
+	col.bypassHits.Add(collector.StoredValue(values[2]))
+	col.bypassMisses.Add(collector.StoredValue(values[3]))
+	col.bypassed.Add(collector.StoredValue(values[4]))
+	col.dirty = values[5]
+	return nil
+}
+
+func (col *deviceCollector) prefix() string {
+	return "bcache/" + col.Name + "/"
+}
+
+func (col *deviceCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.prefix()
+	return collector.MetricReaderMap{
+		prefix + "hits":          col.hits.GetDiff,
+		prefix + "misses":        col.misses.GetDiff,
+		prefix + "hit-ratio":     readHitRatio(col.hits, col.misses),
+		prefix + "bypass/hits":   col.bypassHits.GetDiff,
+		prefix + "bypass/misses": col.bypassMisses.GetDiff,
+		prefix + "bypass/bytes":  col.bypassed.GetDiff,
+		prefix + "dirty": func() bitflow.Value {
+			return bitflow.Value(col.dirty)
+		},
+	}
+}
+
+func (col *deviceCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.prefix()
+	return collector.MetricMetadataMap{
+		prefix + "hits":          collector.DerivedMetric(collector.UnitPerSecond, "Requests served from the cache"),
+		prefix + "misses":        collector.DerivedMetric(collector.UnitPerSecond, "Requests not served from the cache"),
+		prefix + "hit-ratio":     collector.DerivedMetric(collector.UnitPercent, "Share of cache hits among all cached requests"),
+		prefix + "bypass/hits":   collector.DerivedMetric(collector.UnitPerSecond, "Requests bypassing the cache that were cached"),
+		prefix + "bypass/misses": collector.DerivedMetric(collector.UnitPerSecond, "Requests bypassing the cache that were not cached"),
+		prefix + "bypass/bytes":  collector.DerivedMetric(collector.UnitBytesPerSecond, "IO bypassing the cache, e.g. sequential IO"),
+		prefix + "dirty":         collector.GaugeMetric(collector.UnitBytes, "Dirty data in the cache that is not yet written to the backing device"),
+	}
+}
+
+// readHitRatio returns the hit ratio in percent within the time window of the value rings
+func readHitRatio(hits *collector.ValueRing, misses *collector.ValueRing) collector.MetricReader {
+	return func() bitflow.Value {
+		numHits := hits.GetDiff()
+		total := numHits + misses.GetDiff()
+		if total <= 0 {
+			return 0
+		}
+		return numHits / total * 100
+	}
+}
+
+// parseSize parses numbers that are formatted by bcache with binary unit suffixes, e.g. 1.5M
+func parseSize(str string) (float64, error) {
+	factor := 1.0
+	if len(str) > 0 {
+		if index := strings.IndexByte("kMGTPEZY", str[len(str)-1]); index >= 0 {
+			for i := 0; i <= index; i++ {
+				factor *= 1024
+			}
+			str = str[:len(str)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	return value * factor, err
+}
@@ -0,0 +1,152 @@
+package bcache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type BcacheTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestBcache(t *testing.T) {
+	suite.Run(t, new(BcacheTestSuite))
+}
+
+// writeDevice creates the sysfs statistics files of a bcache device
+func (suite *BcacheTestSuite) writeDevice(sysBlock string, device string, stats map[string]string) {
+	for file, content := range stats {
+		path := filepath.Join(sysBlock, device, "bcache", file)
+		suite.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		suite.NoError(ioutil.WriteFile(path, []byte(content+"\n"), 0644))
+	}
+}
+
+func (suite *BcacheTestSuite) deviceStats(hits string, misses string, dirty string) map[string]string {
+	return map[string]string{
+		"stats_total/cache_hits":          hits,
+		"stats_total/cache_misses":        misses,
+		"stats_total/cache_bypass_hits":   "0",
+		"stats_total/cache_bypass_misses": "0",
+		"stats_total/bypassed":            "1.5k",
+		"dirty_data":                      dirty,
+	}
+}
+
+func (suite *BcacheTestSuite) TestParseSize() {
+	for _, test := range []struct {
+		input    string
+		expected float64
+		err      bool
+	}{
+		{input: "0", expected: 0},
+		{input: "123", expected: 123},
+		{input: "1.5k", expected: 1536},
+		{input: "2M", expected: 2 * 1024 * 1024},
+		{input: "1G", expected: 1024 * 1024 * 1024},
+		{input: "", err: true},
+		{input: "k", err: true},
+		{input: "abc", err: true},
+	} {
+		value, err := parseSize(test.input)
+		if test.err {
+			suite.Error(err, test.input)
+		} else {
+			suite.NoError(err, test.input)
+			suite.Equal(test.expected, value, test.input)
+		}
+	}
+}
+
+func (suite *BcacheTestSuite) TestCollect() {
+	for _, test := range []struct {
+		name    string
+		devices map[string]map[string]string
+		dirty   map[string]float64
+		err     bool
+	}{
+		{
+			name: "devices",
+			devices: map[string]map[string]string{
+				"bcache0": suite.deviceStats("100", "50", "2M"),
+				"bcache1": suite.deviceStats("0", "0", "0"),
+			},
+			dirty: map[string]float64{
+				"bcache/bcache0/dirty": 2 * 1024 * 1024,
+				"bcache/bcache1/dirty": 0,
+			},
+		},
+		{
+			name:  "no devices",
+			dirty: map[string]float64{},
+		},
+		{
+			name: "invalid counter",
+			devices: map[string]map[string]string{
+				"bcache0": suite.deviceStats("100", "x", "0"),
+			},
+			err: true,
+		},
+	} {
+		func() {
+			dir, err := ioutil.TempDir("", "bcache")
+			suite.NoError(err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			for device, stats := range test.devices {
+				suite.writeDevice(dir, device, stats)
+			}
+			col := NewBcacheCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+			col.SysBlock = dir
+
+			children, err := col.Init(context.Background())
+			suite.NoError(err, test.name)
+			suite.Len(children, len(test.devices), test.name)
+			suite.NoError(col.Update(context.Background()), test.name)
+			values := make(map[string]float64)
+			for _, child := range children {
+				err := child.Update(context.Background())
+				if test.err {
+					suite.Error(err, test.name)
+					return
+				}
+				suite.NoError(err, test.name)
+				for name, reader := range child.Metrics() {
+					values[name] = float64(reader())
+				}
+			}
+			suite.Len(values, 7*len(test.devices), test.name)
+			for name, expected := range test.dirty {
+				suite.Equal(expected, values[name], test.name+": "+name)
+			}
+		}()
+	}
+}
+
+func (suite *BcacheTestSuite) TestDevicesChanged() {
+	dir, err := ioutil.TempDir("", "bcache")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	suite.writeDevice(dir, "bcache0", suite.deviceStats("1", "1", "0"))
+	col := NewBcacheCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.SysBlock = dir
+
+	children, err := col.Init(context.Background())
+	suite.NoError(err)
+	suite.Len(children, 1)
+	suite.NoError(col.MetricsChanged(context.Background()))
+
+	suite.writeDevice(dir, "bcache1", suite.deviceStats("1", "1", "0"))
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	aggregate_endpoints golib.StringSlice
+	aggregate_host_tag  = "host"
+	aggregate_retry     = 5 * time.Second
+)
+
+func init() {
+	flag.Var(&aggregate_endpoints, "aggregate", "Aggregator mode: instead of collecting local metrics, pull samples from the given remote collector "+
+		"(format: host:port or name=host:port, remote collectors must be started with '-o listen://:port')")
+	flag.StringVar(&aggregate_host_tag, "aggregate-tag", aggregate_host_tag, "Tag that is set to the name (or address) of the remote collector for samples received in aggregator mode (see -aggregate)")
+	flag.DurationVar(&aggregate_retry, "aggregate-retry", aggregate_retry, "Interval for reconnecting to remote collectors in aggregator mode (see -aggregate)")
+}
+
+func aggregatorMode() bool {
+	return len(aggregate_endpoints) > 0 || mdns_discover
+}
+
+// createAggregatorSource returns a source that connects to all remote collectors configured through -aggregate
+// and merges their sample streams. Every received sample is tagged with the name of the remote collector.
+// With -mdns-discover, the collectors announced on the local network are added to the configured ones.
+// The discovery is repeated every -mdns-rediscover interval to add new and remove vanished collectors.
+func createAggregatorSource() (bitflow.SampleSource, error) {
+	source := &aggregatorSource{
+		tag:           aggregate_host_tag,
+		retryInterval: aggregate_retry,
+	}
+	for _, endpoint := range aggregate_endpoints {
+		remote, err := parseRemoteCollector(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if err := source.addRemote(remote, false); err != nil {
+			return nil, err
+		}
+	}
+	if mdns_discover {
+		source.discover = discoverCollectors
+		source.discoverInterval = mdns_rediscover_interval
+		if err := source.updateDiscovered(); err != nil {
+			return nil, err
+		}
+		if len(source.remotes) == 0 && source.discoverInterval <= 0 {
+			return nil, fmt.Errorf("No remote collectors configured or discovered through mDNS")
+		}
+	}
+	log.Printf("Aggregating samples from %v remote collector(s): %v", len(source.remotes), strings.Join(source.remoteNames(), ", "))
+	return source, nil
+}
+
+// remoteCollector is a remote collector endpoint in the format name=host:port. The name defaults to the address.
+type remoteCollector struct {
+	name string
+	addr string
+}
+
+func parseRemoteCollector(endpoint string) (remoteCollector, error) {
+	remote := remoteCollector{name: endpoint, addr: endpoint}
+	if index := strings.IndexRune(endpoint, '='); index >= 0 {
+		remote.name, remote.addr = endpoint[:index], endpoint[index+1:]
+	}
+	if remote.name == "" || remote.addr == "" {
+		return remote, fmt.Errorf("Invalid remote collector endpoint '%v', expected format: host:port or name=host:port", endpoint)
+	}
+	return remote, nil
+}
+
+// aggregatorSource downloads samples from multiple remote collectors. Every remote collector is handled by a separate
+// TCPSource, so that its samples can be tagged with the configured name, independent of the resolved address
+// of the connection. The samples are forwarded sequentially into the outgoing sink.
+// If discover is set, it is called every discoverInterval to add new remote collectors and remove the
+// previously discovered ones that are not returned anymore.
+type aggregatorSource struct {
+	bitflow.AbstractSampleSource
+	tag              string
+	retryInterval    time.Duration
+	discover         func() ([]string, error)
+	discoverInterval time.Duration
+
+	lock    sync.Mutex
+	remotes map[string]*aggregatedRemote // Keyed by the configured address
+	open    int
+	closed  bool
+	wg      *sync.WaitGroup
+	stopped golib.StopChan
+}
+
+type aggregatedRemote struct {
+	remoteCollector
+	source     *bitflow.TCPSource
+	discovered bool
+}
+
+func (a *aggregatorSource) String() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return fmt.Sprintf("Aggregator (%v remote collectors)", len(a.remotes))
+}
+
+func (a *aggregatorSource) remoteNames() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	names := make([]string, 0, len(a.remotes))
+	for _, remote := range a.remotes {
+		names = append(names, remote.name+"="+remote.addr)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addRemote creates a TCPSource for the given remote collector. If the aggregatorSource is already running,
+// the TCPSource is started immediately.
+func (a *aggregatorSource) addRemote(remote remoteCollector, discovered bool) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, ok := a.remotes[remote.addr]; ok {
+		if !discovered {
+			log.Warnf("Ignoring duplicate remote collector endpoint %v=%v", remote.name, remote.addr)
+		}
+		return nil
+	}
+	input, err := bitflow.NewEndpointFactory().CreateInput("tcp://" + remote.addr)
+	if err != nil {
+		return err
+	}
+	source, ok := input.(*bitflow.TCPSource)
+	if !ok {
+		return fmt.Errorf("Unexpected data source for remote collector %v: %v", remote.addr, input)
+	}
+	source.RetryInterval = a.retryInterval
+	source.Reader.Handler = &remoteCollectorTagger{
+		tag:  a.tag,
+		name: remote.name,
+		next: source.Reader.Handler,
+	}
+	source.SetSink(&aggregatorSourceSink{aggregator: a})
+	if a.remotes == nil {
+		a.remotes = make(map[string]*aggregatedRemote)
+	}
+	a.remotes[remote.addr] = &aggregatedRemote{remoteCollector: remote, source: source, discovered: discovered}
+	if a.wg != nil && !a.closed {
+		log.Printf("Aggregating samples from new remote collector %v=%v", remote.name, remote.addr)
+		a.startRemote(source)
+	}
+	return nil
+}
+
+// updateDiscovered adds all newly discovered remote collectors and closes the connections to previously
+// discovered remote collectors that are not discovered anymore. Configured remote collectors are never removed.
+func (a *aggregatorSource) updateDiscovered() error {
+	endpoints, err := a.discover()
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		remote, err := parseRemoteCollector(endpoint)
+		if err != nil {
+			return err
+		}
+		found[remote.addr] = true
+		if err := a.addRemote(remote, true); err != nil {
+			return err
+		}
+	}
+
+	var removed []*aggregatedRemote
+	a.lock.Lock()
+	for addr, remote := range a.remotes {
+		if remote.discovered && !found[addr] {
+			delete(a.remotes, addr)
+			removed = append(removed, remote)
+		}
+	}
+	started := a.wg != nil
+	a.lock.Unlock()
+	for _, remote := range removed {
+		log.Printf("Remote collector %v=%v has vanished, closing the connection", remote.name, remote.addr)
+		if started {
+			// The TCPSource forwards the remaining samples, so it must be closed outside of the lock
+			remote.source.Close()
+		}
+	}
+	return nil
+}
+
+func (a *aggregatorSource) rediscover() {
+	defer a.wg.Done()
+	for a.stopped.WaitTimeout(a.discoverInterval) {
+		if err := a.updateDiscovered(); err != nil {
+			log.Warnln(err)
+		}
+	}
+}
+
+func (a *aggregatorSource) startRemote(source *bitflow.TCPSource) {
+	a.open++
+	source.Start(a.wg)
+}
+
+func (a *aggregatorSource) Start(wg *sync.WaitGroup) golib.StopChan {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.wg = wg
+	a.stopped = golib.NewStopChan()
+	for _, remote := range a.remotes {
+		a.startRemote(remote.source)
+	}
+	if a.discover != nil && a.discoverInterval > 0 {
+		wg.Add(1)
+		go a.rediscover()
+	}
+	return a.stopped
+}
+
+func (a *aggregatorSource) Close() {
+	a.lock.Lock()
+	if a.closed || a.wg == nil {
+		a.lock.Unlock()
+		return
+	}
+	a.closed = true
+	remotes := make([]*bitflow.TCPSource, 0, len(a.remotes))
+	for _, remote := range a.remotes {
+		remotes = append(remotes, remote.source)
+	}
+	closeSink := a.open == 0
+	a.lock.Unlock()
+
+	// Closing the remote sources outside of the lock, since they might still forward samples
+	for _, source := range remotes {
+		source.Close()
+	}
+	if closeSink {
+		a.CloseSinkParallel(a.wg)
+	}
+	a.stopped.Stop()
+}
+
+func (a *aggregatorSource) sample(sample *bitflow.Sample, header *bitflow.Header) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.GetSink().Sample(sample, header)
+}
+
+func (a *aggregatorSource) remoteClosed() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.open--
+	if a.open == 0 && a.closed {
+		a.CloseSinkParallel(a.wg)
+	}
+}
+
+type aggregatorSourceSink struct {
+	bitflow.NoopProcessor
+	aggregator *aggregatorSource
+}
+
+func (s *aggregatorSourceSink) Sample(sample *bitflow.Sample, header *bitflow.Header) error {
+	return s.aggregator.sample(sample, header)
+}
+
+func (s *aggregatorSourceSink) Close() {
+	s.aggregator.remoteClosed()
+}
+
+func (s *aggregatorSourceSink) String() string {
+	return "Aggregator input"
+}
+
+// remoteCollectorTagger tags samples received from remote collectors with the configured name of the collector,
+// or with the remote address if no name is configured. Samples that already contain the tag, e.g. from chained
+// aggregators, are not modified.
+type remoteCollectorTagger struct {
+	tag  string
+	name string
+	next bitflow.ReadSampleHandler
+}
+
+func (t *remoteCollectorTagger) HandleSample(sample *bitflow.Sample, source string) {
+	if !sample.HasTag(t.tag) {
+		name := t.name
+		if name == "" {
+			name = source
+		}
+		sample.SetTag(t.tag, name)
+	}
+	if t.next != nil {
+		t.next.HandleSample(sample, source)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type AggregatorTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestAggregator(t *testing.T) {
+	suite.Run(t, new(AggregatorTestSuite))
+}
+
+// receivedSamples collects all samples forwarded by the aggregatorSource
+type receivedSamples struct {
+	bitflow.NoopProcessor
+	lock    sync.Mutex
+	samples []*bitflow.Sample
+	closed  bool
+}
+
+func (r *receivedSamples) Sample(sample *bitflow.Sample, _ *bitflow.Header) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.samples = append(r.samples, sample)
+	return nil
+}
+
+func (r *receivedSamples) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+}
+
+func (r *receivedSamples) tags(tag string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	res := make([]string, len(r.samples))
+	for i, sample := range r.samples {
+		res[i] = sample.Tag(tag)
+	}
+	return res
+}
+
+// serveSamples accepts connections on a local port and sends one sample on every connection
+func (suite *AggregatorTestSuite) serveSamples() (int, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.NoError(err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			header := &bitflow.Header{Fields: []string{"value"}}
+			sample := &bitflow.Sample{Time: time.Now(), Values: []bitflow.Value{1}}
+			var m bitflow.BinaryMarshaller
+			suite.NoError(m.WriteHeader(header, true, conn))
+			suite.NoError(m.WriteSample(sample, header, true, conn))
+			_ = conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, func() {
+		_ = listener.Close()
+	}
+}
+
+func (suite *AggregatorTestSuite) TestParseRemoteCollector() {
+	for _, test := range []struct {
+		endpoint string
+		expected remoteCollector
+		err      bool
+	}{
+		{"host:7777", remoteCollector{name: "host:7777", addr: "host:7777"}, false},
+		{"name=host:7777", remoteCollector{name: "name", addr: "host:7777"}, false},
+		{"=host:7777", remoteCollector{}, true},
+		{"name=", remoteCollector{}, true},
+	} {
+		remote, err := parseRemoteCollector(test.endpoint)
+		if test.err {
+			suite.Error(err, test.endpoint)
+		} else {
+			suite.NoError(err, test.endpoint)
+			suite.Equal(test.expected, remote)
+		}
+	}
+}
+
+func (suite *AggregatorTestSuite) TestTagWithConfiguredName() {
+	port, stop := suite.serveSamples()
+	defer stop()
+
+	// The connection is established to 127.0.0.1, but the configured name must be used for the tag
+	source := &aggregatorSource{tag: "host", retryInterval: 10 * time.Millisecond}
+	suite.NoError(source.addRemote(remoteCollector{name: "remote-name", addr: "localhost:" + strconv.Itoa(port)}, false))
+	suite.NoError(source.addRemote(remoteCollector{name: "127.0.0.1:" + strconv.Itoa(port), addr: "127.0.0.1:" + strconv.Itoa(port)}, false))
+	received := new(receivedSamples)
+	source.SetSink(received)
+
+	var wg sync.WaitGroup
+	source.Start(&wg)
+	suite.Eventually(func() bool {
+		tags := received.tags("host")
+		return containsString(tags, "remote-name") && containsString(tags, "127.0.0.1:"+strconv.Itoa(port))
+	}, 5*time.Second, 10*time.Millisecond)
+	source.Close()
+	wg.Wait()
+
+	for _, tag := range received.tags("host") {
+		suite.Contains([]string{"remote-name", "127.0.0.1:" + strconv.Itoa(port)}, tag)
+	}
+	suite.True(received.closed, "The outgoing sink was not closed")
+}
+
+func (suite *AggregatorTestSuite) TestRediscover() {
+	port, stop := suite.serveSamples()
+	defer stop()
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	var lock sync.Mutex
+	discovered := []string{"discovered=" + addr}
+	source := &aggregatorSource{
+		tag:           "host",
+		retryInterval: 10 * time.Millisecond,
+		discover: func() ([]string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return discovered, nil
+		},
+		discoverInterval: 10 * time.Millisecond,
+	}
+	suite.NoError(source.addRemote(remoteCollector{name: "static", addr: "localhost:" + strconv.Itoa(port)}, false))
+	suite.NoError(source.updateDiscovered())
+	suite.Equal([]string{"discovered=" + addr, "static=localhost:" + strconv.Itoa(port)}, source.remoteNames())
+	received := new(receivedSamples)
+	source.SetSink(received)
+
+	var wg sync.WaitGroup
+	source.Start(&wg)
+	suite.Eventually(func() bool {
+		return containsString(received.tags("host"), "discovered")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Previously discovered collectors are removed, configured ones are kept
+	lock.Lock()
+	discovered = []string{"new=127.0.0.1:1"}
+	lock.Unlock()
+	suite.Eventually(func() bool {
+		names := source.remoteNames()
+		return len(names) == 2 && names[0] == "new=127.0.0.1:1" && names[1] == "static=localhost:"+strconv.Itoa(port)
+	}, 5*time.Second, 10*time.Millisecond)
+	suite.Eventually(func() bool {
+		source.lock.Lock()
+		defer source.lock.Unlock()
+		return source.open == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	source.Close()
+	wg.Wait()
+	suite.True(received.closed, "The outgoing sink was not closed")
+}
+
+func (suite *AggregatorTestSuite) TestKeepExistingTag() {
+	sample := &bitflow.Sample{}
+	sample.SetTag("host", "chained")
+	tagger := &remoteCollectorTagger{tag: "host", name: "remote"}
+	tagger.HandleSample(sample, "10.0.0.1:7777")
+	suite.Equal("chained", sample.Tag("host"))
+
+	// Without a configured name, the address of the connection is used
+	sample = &bitflow.Sample{}
+	tagger = &remoteCollectorTagger{tag: "host"}
+	tagger.HandleSample(sample, "10.0.0.1:7777")
+	suite.Equal("10.0.0.1:7777", sample.Tag("host"))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow/cmd"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// loopbackApiEndpoint is the endpoint of the plain HTTP server of cmd.CmdDataCollector, when the REST API is served over HTTPS
+const loopbackApiEndpoint = "127.0.0.1:0"
+
+var restApiSecurity RestApiSecurity
+
+func init() {
+	flag.StringVar(&restApiSecurity.certFile, "api-tls-cert", "", "Serve the REST API (-api) over HTTPS with the given PEM certificate file (requires -api-tls-key, and -api-token or -api-basic-auth)")
+	flag.StringVar(&restApiSecurity.keyFile, "api-tls-key", "", "PEM private key file for -api-tls-cert")
+	flag.StringVar(&restApiSecurity.token, "api-token", "", "Require the given bearer token (header 'Authorization: Bearer <token>') for all requests to the REST API. "+
+		"Use a placeholder like ${file:///path} to avoid exposing the token in the process list")
+	flag.StringVar(&restApiSecurity.basicAuth, "api-basic-auth", "", "Require HTTP basic authentication with the given user:password for all requests to the REST API. "+
+		"If -api-token is set as well, either of them is accepted")
+}
+
+// RestApiSecurity protects the REST API with TLS and authentication. The REST API server of cmd.CmdDataCollector only
+// supports plain HTTP, so with TLS, the server is moved to an unused port on the loopback interface and the same
+// handlers are served over HTTPS on the -api endpoint instead. Since the loopback server cannot be stopped, TLS requires
+// authentication, so that local processes cannot use the API through the plain HTTP server. The authentication applies
+// to all endpoints and both servers, including the tags and file output endpoints of cmd.CmdDataCollector.
+type RestApiSecurity struct {
+	certFile  string
+	keyFile   string
+	token     string
+	basicAuth string
+
+	// Set by configure()
+	endpoint    string
+	certificate tls.Certificate
+}
+
+// configureRestApiSecurity validates the TLS and authentication flags and registers restApiSecurity in the REST API.
+// Must be called with the flag set returned by cmd.ParseFlags(), after applyConfigFile() and before building the pipeline.
+func configureRestApiSecurity(flagSet *flag.FlagSet, helper *cmd.CmdDataCollector) error {
+	api := &restApiSecurity
+	apiFlag := flagSet.Lookup("api")
+	if apiFlag == nil || apiFlag.Value.String() == "" {
+		if api.certFile != "" || api.keyFile != "" || api.token != "" || api.basicAuth != "" {
+			return errors.New("-api-tls-cert, -api-tls-key, -api-token and -api-basic-auth require -api")
+		}
+		return nil
+	}
+	if api.basicAuth != "" && !strings.Contains(api.basicAuth, ":") {
+		return errors.New("-api-basic-auth must have the format user:password")
+	}
+	if (api.certFile == "") != (api.keyFile == "") {
+		return errors.New("-api-tls-cert and -api-tls-key must be given together")
+	}
+	if api.certFile != "" && api.token == "" && api.basicAuth == "" {
+		return errors.New("-api-tls-cert requires -api-token or -api-basic-auth, because the REST API is also served over plain HTTP on the loopback interface")
+	}
+	if api.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(api.certFile, api.keyFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load the TLS certificate of the REST API: %v", err)
+		}
+		api.certificate = cert
+		api.endpoint = apiFlag.Value.String()
+		if err := flagSet.Set("api", loopbackApiEndpoint); err != nil {
+			return err
+		}
+	} else if api.token == "" && api.basicAuth == "" {
+		return nil
+	}
+	helper.RestApis = append(helper.RestApis, api)
+	return nil
+}
+
+func (api *RestApiSecurity) Register(pathPrefix string, router *mux.Router) {
+	if api.token != "" || api.basicAuth != "" {
+		router.Use(api.authenticate)
+	}
+	if api.endpoint != "" {
+		server := &http.Server{
+			Addr:    api.endpoint,
+			Handler: router,
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{api.certificate},
+				MinVersion:   tls.VersionTLS12,
+			},
+		}
+		log.Println("Serving the REST API over HTTPS on", api.endpoint)
+		// Do not add this routine to any wait group, as it cannot be stopped
+		go func() {
+			log.Errorln("REST API server failed:", server.ListenAndServeTLS("", ""))
+		}()
+	}
+}
+
+func (api *RestApiSecurity) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.isAuthorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if api.basicAuth != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="bitflow-collector"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized\n"))
+	})
+}
+
+func (api *RestApiSecurity) isAuthorized(r *http.Request) bool {
+	if api.token != "" {
+		const prefix = "Bearer "
+		header := r.Header.Get("Authorization")
+		if strings.HasPrefix(header, prefix) && secretEquals(header[len(prefix):], api.token) {
+			return true
+		}
+	}
+	if api.basicAuth != "" {
+		if user, password, ok := r.BasicAuth(); ok && secretEquals(user+":"+password, api.basicAuth) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretEquals compares the given strings in constant time, to avoid leaking the secret through the response time
+func secretEquals(given, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1
+}
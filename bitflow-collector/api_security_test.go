@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/cmd"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+)
+
+type ApiSecurityTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestApiSecurity(t *testing.T) {
+	suite.Run(t, new(ApiSecurityTestSuite))
+}
+
+func (suite *ApiSecurityTestSuite) configure(api string, security RestApiSecurity) (*cmd.CmdDataCollector, error) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.String("api", api, "")
+	restApiSecurity = security
+	helper := new(cmd.CmdDataCollector)
+	return helper, configureRestApiSecurity(flagSet, helper)
+}
+
+func (suite *ApiSecurityTestSuite) TestConfigure() {
+	defer func() {
+		restApiSecurity = RestApiSecurity{}
+	}()
+	helper, err := suite.configure("", RestApiSecurity{})
+	suite.NoError(err)
+	suite.Empty(helper.RestApis)
+	helper, err = suite.configure(":7777", RestApiSecurity{})
+	suite.NoError(err)
+	suite.Empty(helper.RestApis)
+
+	_, err = suite.configure("", RestApiSecurity{token: "secret"})
+	suite.Error(err)
+	_, err = suite.configure(":7777", RestApiSecurity{basicAuth: "user"})
+	suite.Error(err)
+	_, err = suite.configure(":7777", RestApiSecurity{certFile: "cert.pem"})
+	suite.Error(err)
+	_, err = suite.configure(":7777", RestApiSecurity{certFile: "cert.pem", keyFile: "key.pem"})
+	suite.Error(err)
+	suite.Contains(err.Error(), "-api-token")
+	_, err = suite.configure(":7777", RestApiSecurity{certFile: "missing-cert.pem", keyFile: "missing-key.pem", token: "secret"})
+	suite.Error(err)
+	suite.Contains(err.Error(), "certificate")
+
+	helper, err = suite.configure(":7777", RestApiSecurity{token: "secret", basicAuth: "user:pass"})
+	suite.NoError(err)
+	suite.Equal([]cmd.RestApiPath{&restApiSecurity}, helper.RestApis)
+	suite.Empty(restApiSecurity.endpoint)
+}
+
+func (suite *ApiSecurityTestSuite) TestAuthentication() {
+	api := &RestApiSecurity{token: "secret", basicAuth: "user:pass"}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	api.Register(cmd.RestApiPathPrefix, router)
+
+	request := func(modify func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/tags", nil)
+		modify(r)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	w := request(func(r *http.Request) {})
+	suite.Equal(http.StatusUnauthorized, w.Code)
+	suite.Equal(`Basic realm="bitflow-collector"`, w.Header().Get("WWW-Authenticate"))
+	w = request(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer wrong")
+	})
+	suite.Equal(http.StatusUnauthorized, w.Code)
+	w = request(func(r *http.Request) {
+		r.SetBasicAuth("user", "wrong")
+	})
+	suite.Equal(http.StatusUnauthorized, w.Code)
+
+	w = request(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer secret")
+	})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal("ok", w.Body.String())
+	w = request(func(r *http.Request) {
+		r.SetBasicAuth("user", "pass")
+	})
+	suite.Equal(http.StatusOK, w.Code)
+}
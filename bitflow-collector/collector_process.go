@@ -11,36 +11,137 @@ import (
 
 	"github.com/antongulenko/golib"
 	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow-collector/k8s"
 	"github.com/bitflow-stream/go-bitflow-collector/psutil"
-	"github.com/bitflow-stream/go-bitflow/cmd"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
 	proc_update_pids time.Duration
+	proc_taskstats   bool
+	proc_mem_detail  bool
+	proc_conntrack   bool
+	proc_metric_name = psutil.DefaultProcessMetricName
 	multiProcApi     MonitorProcessesRestApi
+
+	proc_discover          = 0
+	proc_discover_interval = psutil.DiscoveryInterval
+	proc_discover_idle     = psutil.DiscoveryIdleThreshold
+	proc_discover_retire   = psutil.DiscoveryRetireAfter
+
+	container_disk_usage     golib.KeyValueStringSlice
+	container_layer_interval time.Duration
+
+	k8s_enabled    = false
+	k8s_kubelet    = k8s.DefaultKubeletUrl
+	k8s_token_file = k8s.DefaultTokenFile
+	k8s_insecure   = false
+	k8s_timeout    = 10 * time.Second
+
+	host_proc = hostfs.DefaultProcRoot
+	host_sys  = hostfs.DefaultSysRoot
+	host_root = ""
 )
 
 func init() {
 	flag.DurationVar(&proc_update_pids, "proc-interval", 1500*time.Millisecond, "Interval for updating list of observed pids")
+	flag.BoolVar(&proc_taskstats, "proc-taskstats", false, "Obtain process CPU times and delay accounting (/proc/.../delay/...) through one netlink taskstats request per process, instead of /proc/<pid>/stat. "+
+		"Without taskstats, only the block IO delay is available. Disk IO is still read from /proc/<pid>/io (requires CAP_NET_ADMIN)")
+	flag.BoolVar(&proc_mem_detail, "proc-mem-detail", false, "Report the proportional (PSS) and unique (USS) memory and the swap usage of processes (/proc/.../mem/pss, mem/uss, mem/swap), "+
+		"read from /proc/<pid>/smaps_rollup. Unlike the RSS, these do not count shared pages multiple times, but reading them is more expensive")
+	flag.BoolVar(&proc_conntrack, "proc-conntrack", false, "Report the network traffic of the TCP and UDP connections of processes (/proc/.../net-conntrack/...), "+
+		"obtained from the netfilter connection tracking table. Requires the nf_conntrack kernel module and sysctl net.netfilter.nf_conntrack_acct=1")
+	flag.StringVar(&proc_metric_name, "proc-metric-name", proc_metric_name, "Template for the metric names of the process groups (-proc, -proc-children). "+
+		"{group} and {metric} are replaced by the group name and the metric (e.g. mem/rss), {pid} by the lowest PID in the group and {container} by the short ID of its container. "+
+		"Without {group}, only one process group can be configured, e.g. '{metric}' with -tag proc=<group> to identify the group through a tag")
+	flag.IntVar(&proc_discover, "proc-discover", proc_discover, "Automatically create process groups (proc/auto/<executable>/...) for up to the given number of executables "+
+		"with the highest CPU usage, grouping all processes by their executable name. 0 disables the discovery")
+	flag.DurationVar(&proc_discover_interval, "proc-discover-interval", proc_discover_interval, "Interval for ranking the CPU usage of executables for -proc-discover")
+	flag.Float64Var(&proc_discover_idle, "proc-discover-idle", proc_discover_idle, "CPU usage (percent of all CPUs) below which a process group of -proc-discover is considered idle")
+	flag.DurationVar(&proc_discover_retire, "proc-discover-retire", proc_discover_retire, "Retire the process groups of -proc-discover after being idle for the given duration")
+	flag.Var(&container_disk_usage, "container-disk-usage", "Evaluate the disk usage inside the mount namespace of a container, including the size of its writable overlay layer "+
+		"(format: name=regex, the container is identified by the first process with a command line matching the regex). Can be repeated")
+	flag.DurationVar(&container_layer_interval, "container-layer-interval", psutil.WritableLayerUpdateInterval, "Interval for recomputing the size of the writable overlay layers of containers (see -container-disk-usage)")
+	flag.BoolVar(&k8s_enabled, "k8s", k8s_enabled, "Collect the process metrics of all running Kubernetes pods on the local node, named k8s/<namespace>/<pod>/... "+
+		"The pods are queried from the kubelet API (see -k8s-kubelet) and the processes are mapped to the pods through their cgroups")
+	flag.StringVar(&k8s_kubelet, "k8s-kubelet", k8s_kubelet, "URL of the kubelet API queried for -k8s, e.g. http://127.0.0.1:10255 for the read-only port")
+	flag.StringVar(&k8s_token_file, "k8s-token-file", k8s_token_file, "Bearer token for authenticating against the kubelet API (see -k8s), ignored if the file does not exist. "+
+		"The service account requires the permission to get nodes/proxy")
+	flag.BoolVar(&k8s_insecure, "k8s-insecure", k8s_insecure, "Skip the verification of the TLS certificate of the kubelet API (see -k8s)")
+	flag.DurationVar(&k8s_timeout, "k8s-timeout", k8s_timeout, "Timeout for requests to the kubelet API (see -k8s)")
+	flag.StringVar(&host_proc, "host-proc", host_proc, "Mount point of the proc filesystem read by the psutil collectors. When running in a container, "+
+		"mount the /proc of the host elsewhere (e.g. /host/proc) to collect host metrics instead of container-local metrics")
+	flag.StringVar(&host_sys, "host-sys", host_sys, "Mount point of the sys filesystem read by the psutil collectors (see -host-proc)")
+	flag.StringVar(&host_root, "host-root", host_root, "Containerized mode: mount point of the root filesystem of the host (e.g. /host). The proc and sys filesystems, "+
+		"the mounts and disk usage of the host partitions, and the network counters of the host are read below this path. Requires the PID namespace of the host "+
+		"(e.g. docker run --pid host). Cannot be combined with -host-proc and -host-sys")
 	multiProcApi.RegisterFlags()
 }
 
-func createProcessCollectors(helper *cmd.CmdDataCollector) []collector.Collector {
-	psutilRoot := psutil.NewPsutilRootCollector(&ringFactory)
+// configureHostFilesystem applies -host-root, -host-proc and -host-sys. Must be called before creating any collectors.
+func configureHostFilesystem() error {
+	customRoots := host_proc != hostfs.DefaultProcRoot || host_sys != hostfs.DefaultSysRoot
+	if host_root != "" {
+		if customRoots {
+			return fmt.Errorf("-host-root cannot be combined with -host-proc and -host-sys")
+		}
+		log.Println("Collecting the metrics of the host with the root filesystem mounted at", host_root)
+		return hostfs.Set(hostfs.NewHostRootFilesystem(host_root))
+	}
+	if !customRoots {
+		return nil
+	}
+	log.Printf("Reading the proc and sys filesystems from %v and %v", host_proc, host_sys)
+	return hostfs.Set(hostfs.RootFilesystem{ProcRoot: host_proc, SysRoot: host_sys})
+}
+
+// createProcessCollectors creates a new psutil root collector with the process collectors configured through
+// -proc and -proc-children, which are updated by multiProcApi, the discovered process groups of -proc-discover,
+// and the pod collector of -k8s.
+// Must be followed by multiProcApi.updateCollectors().
+func createProcessCollectors(factory *collector.ValueRingFactory) []collector.Collector {
+	psutilRoot := psutil.NewPsutilRootCollector(factory)
+	psutilRoot.PidUpdateInterval = proc_update_pids
+	psutilRoot.PcapNics = pcap_nics
+	psutilRoot.TaskstatsBackend = proc_taskstats
+	psutilRoot.MemoryDetail = proc_mem_detail
+	psutilRoot.ConntrackTraffic = proc_conntrack
+	golib.Checkerr(psutil.ValidateProcessMetricName(proc_metric_name))
+	psutilRoot.ProcessMetricName = proc_metric_name
+	psutilRoot.WritableLayerUpdateInterval = container_layer_interval
+	psutilRoot.ContainerDiskUsage = make(map[string]*regexp.Regexp, len(container_disk_usage.Keys))
+	for name, value := range container_disk_usage.Map() {
+		regex, err := regexp.Compile(value)
+		if err != nil {
+			golib.Checkerr(fmt.Errorf("Error compiling process regex for container %v: %v", name, err))
+		}
+		psutilRoot.ContainerDiskUsage[name] = regex
+	}
 	psutilProcesses := psutilRoot.NewMultiProcessCollector("processes")
-	multiProcApi.procs = psutilProcesses
-	if err := multiProcApi.updateCollectors(); err != nil {
-		golib.Checkerr(err)
+	multiProcApi.procs = append(multiProcApi.procs, psutilProcesses)
+	res := []collector.Collector{psutilRoot, psutilProcesses}
+	if proc_discover > 0 {
+		discovery := psutilRoot.NewProcessDiscoveryCollector(proc_discover)
+		discovery.Interval = proc_discover_interval
+		discovery.IdleThreshold = proc_discover_idle
+		discovery.RetireAfter = proc_discover_retire
+		res = append(res, discovery)
+	}
+	if k8s_enabled {
+		res = append(res, k8s.NewPodCollector(psutilRoot, k8s.KubeletConfig{
+			Url:       k8s_kubelet,
+			TokenFile: k8s_token_file,
+			Insecure:  k8s_insecure,
+			Timeout:   k8s_timeout,
+		}))
 	}
-	helper.RestApis = append(helper.RestApis, &multiProcApi)
-	psutil.PidUpdateInterval = proc_update_pids
-	return []collector.Collector{psutilRoot, psutilProcesses}
+	return res
 }
 
 type MonitorProcessesRestApi struct {
-	procs *psutil.MultiProcessCollector
+	procs []*psutil.MultiProcessCollector
 	lock  sync.Mutex
 
 	proc_collectors          golib.KeyValueStringSlice
@@ -51,7 +152,8 @@ type MonitorProcessesRestApi struct {
 func (api *MonitorProcessesRestApi) RegisterFlags() {
 	flag.Var(&api.proc_collectors, "proc", "'key=regex' Processes to collect metrics for (regex match on entire command line)")
 	flag.Var(&api.proc_children_collectors, "proc-children", "'key=regex' Processes to collect metrics for (regex match on entire command line). Include all child processes of matched processes.")
-	flag.BoolVar(&api.proc_show_errors, "proc-show-errors", false, "Verbose: show errors encountered while getting process metrics")
+	flag.BoolVar(&api.proc_show_errors, "proc-show-errors", false, "Verbose: log errors encountered while getting process metrics (rate-limited, see -error-summary-interval). "+
+		"The errors are always available through the REST API at /api/errors")
 }
 
 func (api *MonitorProcessesRestApi) Register(pathPrefix string, router *mux.Router) {
@@ -70,8 +172,10 @@ func (api *MonitorProcessesRestApi) updateCollectors() error {
 	if err != nil {
 		return err
 	}
-	api.procs.Processes = append(desc1, desc2...)
-	api.procs.UpdateProcesses()
+	for _, procs := range api.procs {
+		procs.Processes = append(desc1, desc2...)
+		procs.UpdateProcesses()
+	}
 	return nil
 }
 
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+var config_file = ""
+
+func init() {
+	flag.StringVar(&config_file, "config", config_file, "YAML or JSON file with values of command line flags, e.g. 'mdraid: true', 'ci: 500ms' or 'include: [^cpu, ^mem]'. "+
+		"Lists set repeatable flags multiple times. Flags given on the command line or through -source take precedence over the file. "+
+		"Placeholders like ${NAME} are replaced like in command line arguments. The logging flags (e.g. -v) are only effective on the command line")
+}
+
+// applyConfigFile sets the command line flags configured in the given file, except for the flags that were already set
+// on the command line or through -source. Must be called with the flag set returned by cmd.ParseFlags(), after applySourceUris().
+func applyConfigFile(flagSet *flag.FlagSet, filename string) error {
+	if filename == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	flags, err := parseConfigFile(flagSet, data)
+	if err != nil {
+		return fmt.Errorf("Failed to parse config file %v: %v", filename, err)
+	}
+	isSet := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		isSet[f.Name] = true
+	})
+	configReloader.flagSet = flagSet
+	configReloader.commandLine = isSet
+	for _, f := range flags {
+		if isSet[f.name] {
+			log.Debugf("Config file %v: -%v is overridden by the command line", filename, f.name)
+			continue
+		}
+		log.Debugf("Config file %v: setting -%v=%v", filename, f.name, f.value)
+		if err := flagSet.Set(f.name, f.value); err != nil {
+			return fmt.Errorf("Invalid value '%v' for -%v in config file %v: %v", f.value, f.name, filename, err)
+		}
+	}
+	return nil
+}
+
+// parseConfigFile translates a config file into command line flags, in the order of the file. The file contains
+// a map from flag names to values. Lists produce one flag per element, and empty values enable boolean flags.
+// JSON files are parsed as well, since JSON is a subset of YAML.
+func parseConfigFile(flagSet *flag.FlagSet, data []byte) ([]sourceFlag, error) {
+	var entries yaml.MapSlice
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	var res []sourceFlag
+	for _, entry := range entries {
+		name := fmt.Sprint(entry.Key)
+		if flagSet.Lookup(name) == nil {
+			return nil, fmt.Errorf("Unknown flag '%v'", name)
+		} else if name == "config" {
+			return nil, fmt.Errorf("Config files cannot be nested")
+		}
+		values, isList := entry.Value.([]interface{})
+		if !isList {
+			values = []interface{}{entry.Value}
+		}
+		for _, value := range values {
+			var str string
+			switch value := value.(type) {
+			case nil:
+				if !isBoolFlag(flagSet, name) {
+					return nil, fmt.Errorf("Missing value for flag '%v'", name)
+				}
+				str = "true"
+			case []interface{}, map[interface{}]interface{}, yaml.MapSlice:
+				return nil, fmt.Errorf("Invalid value for flag '%v': expected a single value or a list of values", name)
+			default:
+				str = fmt.Sprint(value)
+			}
+			str, err := interpolate(str)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to interpolate value of flag '%v': %v", name, err)
+			}
+			res = append(res, sourceFlag{name: name, value: str})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigFileTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestConfigFile(t *testing.T) {
+	suite.Run(t, new(ConfigFileTestSuite))
+}
+
+func (suite *ConfigFileTestSuite) TestParseConfigFile() {
+	suite.NoError(os.Setenv("BITFLOW_CONFIG_TEST", "staging"))
+	defer os.Unsetenv("BITFLOW_CONFIG_TEST")
+	for _, test := range []struct {
+		config   string
+		expected []sourceFlag
+		err      bool
+	}{
+		{"ci: 500ms\nmdraid:\ninclude: [^cpu, ^mem]\ntag:\n  - env=${BITFLOW_CONFIG_TEST}\n", []sourceFlag{
+			{"ci", "500ms"},
+			{"mdraid", "true"},
+			{"include", "^cpu"},
+			{"include", "^mem"},
+			{"tag", "env=staging"},
+		}, false},
+		{`{"si": "2s", "wireguard": false, "proc": ["web=nginx", "db=postgres"]}`, []sourceFlag{
+			{"si", "2s"},
+			{"wireguard", "false"},
+			{"proc", "web=nginx"},
+			{"proc", "db=postgres"},
+		}, false},
+		{"", nil, false},
+		{"unknown-flag: 1", nil, true},
+		{"config: other.yml", nil, true},
+		{"ci:", nil, true},
+		{"include: [[^cpu]]", nil, true},
+		{"include: {cpu: 1}", nil, true},
+		{"- ci", nil, true},
+	} {
+		flags, err := parseConfigFile(flag.CommandLine, []byte(test.config))
+		if test.err {
+			suite.Error(err, test.config)
+		} else {
+			suite.NoError(err, test.config)
+			suite.Equal(test.expected, flags, test.config)
+		}
+	}
+}
+
+func (suite *ConfigFileTestSuite) TestApplyConfigFile() {
+	dir, err := ioutil.TempDir("", "bitflow-config-test")
+	suite.NoError(err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "collector.yml")
+	suite.NoError(ioutil.WriteFile(filename, []byte("ci: 2s\nsi: 3s\nmdraid: true\n"), 0644))
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	ci := flagSet.Duration("ci", time.Second, "")
+	si := flagSet.Duration("si", time.Second, "")
+	mdraid := flagSet.Bool("mdraid", false, "")
+	suite.NoError(flagSet.Parse([]string{"-ci", "100ms"}))
+
+	suite.NoError(applyConfigFile(flagSet, filename))
+	suite.Equal(100*time.Millisecond, *ci)
+	suite.Equal(3*time.Second, *si)
+	suite.True(*mdraid)
+
+	suite.NoError(applyConfigFile(flagSet, ""))
+	suite.Error(applyConfigFile(flagSet, filepath.Join(dir, "missing.yml")))
+	suite.NoError(ioutil.WriteFile(filename, []byte("si: never\n"), 0644))
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.Duration("si", time.Second, "")
+	suite.Error(applyConfigFile(flagSet, filename))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/mdns"
+	log "github.com/sirupsen/logrus"
+)
+
+// DNS-SD service type used for announcing and discovering collector instances
+const mdnsService = "_bitflow-collector._tcp"
+
+var (
+	mdns_announce_port    = 0
+	mdns_discover         = false
+	mdns_discover_timeout = 3 * time.Second
+
+	mdns_rediscover_interval = 30 * time.Second
+)
+
+func init() {
+	flag.IntVar(&mdns_announce_port, "mdns-announce", mdns_announce_port, "Announce this collector through mDNS (service "+mdnsService+") with the given port. "+
+		"Should be the port of a listen:// output, so that aggregators can discover this collector (0 to disable)")
+	flag.BoolVar(&mdns_discover, "mdns-discover", mdns_discover, "Enable aggregator mode (see -aggregate) and pull samples from all collectors discovered through mDNS (see -mdns-announce)")
+	flag.DurationVar(&mdns_discover_timeout, "mdns-timeout", mdns_discover_timeout, "Time to wait for responses when discovering collectors through mDNS (see -mdns-discover)")
+	flag.DurationVar(&mdns_rediscover_interval, "mdns-rediscover", mdns_rediscover_interval, "Interval for repeating the mDNS discovery to connect to new collectors and disconnect from vanished ones (see -mdns-discover, 0 to disable)")
+}
+
+func mdnsInstanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "bitflow-collector"
+	}
+	return hostname
+}
+
+// startMdnsAnnouncement announces this collector instance on the local network, if configured through -mdns-announce.
+// The returned function stops the announcement.
+func startMdnsAnnouncement() (func(), error) {
+	if mdns_announce_port <= 0 {
+		return func() {}, nil
+	}
+	service, err := mdns.NewMDNSService(mdnsInstanceName(), mdnsService, "", "", mdns_announce_port, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create mDNS service: %v", err)
+	}
+	server, err := mdns.NewServer(&mdns.Config{Zone: service})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start mDNS server: %v", err)
+	}
+	log.Printf("Announcing collector %v (port %v) through mDNS", service.Instance, mdns_announce_port)
+	return func() {
+		if err := server.Shutdown(); err != nil {
+			log.Warnln("Failed to stop mDNS server:", err)
+		}
+	}, nil
+}
+
+// discoverCollectors queries the local network for announced collector instances and returns their endpoints
+// in the format accepted by -aggregate.
+func discoverCollectors() ([]string, error) {
+	entries := make(chan *mdns.ServiceEntry, 16)
+	var result []string
+	found := make(map[string]bool)
+	done := make(chan struct{})
+	ownName := mdnsInstanceName()
+	go func() {
+		defer close(done)
+		for entry := range entries {
+			name := strings.TrimSuffix(entry.Name, "."+mdnsService+".local.")
+			if name == ownName && entry.Port == mdns_announce_port {
+				continue
+			}
+			var ip net.IP
+			if entry.AddrV4 != nil {
+				ip = entry.AddrV4
+			} else if entry.AddrV6 != nil {
+				ip = entry.AddrV6
+			} else {
+				log.Warnln("Ignoring discovered collector without address:", entry.Name)
+				continue
+			}
+			addr := net.JoinHostPort(ip.String(), strconv.Itoa(entry.Port))
+			if !found[addr] {
+				found[addr] = true
+				log.Printf("Discovered collector %v at %v", name, addr)
+				result = append(result, name+"="+addr)
+			}
+		}
+	}()
+	err := mdns.Query(&mdns.QueryParam{
+		Service: mdnsService,
+		Timeout: mdns_discover_timeout,
+		Entries: entries,
+	})
+	close(entries)
+	<-done
+	if err != nil {
+		return nil, fmt.Errorf("Failed to discover collectors through mDNS: %v", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const interpolationFilePrefix = "file://"
+
+// Other placeholders, e.g. ${metric:name} of -tag, are left unchanged
+var interpolationRegex = regexp.MustCompile(`\$\$\{|\$\{((?i:env:)?[a-zA-Z_][a-zA-Z0-9_]*|file://[^}]*)\}`)
+
+// interpolateArgs replaces placeholders in all command line arguments, so that secrets and per-host values can be
+// injected by the deployment system: ${NAME} or ${ENV:NAME} is replaced by the value of the environment variable NAME, and
+// ${file:///path} by the contents of the file, without trailing newlines. $${ produces a literal ${.
+func interpolateArgs(args []string) ([]string, error) {
+	res := make([]string, len(args))
+	for i, arg := range args {
+		value, err := interpolate(arg)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to interpolate command line argument %v: %v", i+1, err)
+		}
+		res[i] = value
+	}
+	return res, nil
+}
+
+func interpolate(value string) (string, error) {
+	var err error
+	res := interpolationRegex.ReplaceAllStringFunc(value, func(placeholder string) string {
+		if err != nil {
+			return ""
+		}
+		if placeholder == "$${" {
+			return "${"
+		}
+		name := placeholder[2 : len(placeholder)-1]
+		if strings.HasPrefix(name, interpolationFilePrefix) {
+			path := name[len(interpolationFilePrefix):]
+			var content []byte
+			if content, err = ioutil.ReadFile(path); err != nil {
+				return ""
+			}
+			return strings.TrimRight(string(content), "\r\n")
+		}
+		if index := strings.IndexRune(name, ':'); index >= 0 {
+			name = name[index+1:]
+		}
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			err = fmt.Errorf("Environment variable %v is not set", name)
+		}
+		return env
+	})
+	return res, err
+}
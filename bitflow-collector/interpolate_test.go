@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type InterpolateTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestInterpolate(t *testing.T) {
+	suite.Run(t, new(InterpolateTestSuite))
+}
+
+func (suite *InterpolateTestSuite) TestInterpolateArgs() {
+	dir, err := ioutil.TempDir("", "interpolate")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	tokenFile := filepath.Join(dir, "token")
+	suite.NoError(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+	suite.NoError(os.Setenv("BITFLOW_TEST_HOST", "db1"))
+	defer func() {
+		_ = os.Unsetenv("BITFLOW_TEST_HOST")
+	}()
+
+	args, err := interpolateArgs([]string{
+		"-libvirt=qemu+ssh://${BITFLOW_TEST_HOST}/system",
+		"-ovsdb", "${env:BITFLOW_TEST_HOST}:6640",
+		"-api-token=${file://" + tokenFile + "}",
+		"-tag", "value={metric:cpu} ${metric:mem}",
+		"-exclude", "^cpu$",
+		"$${BITFLOW_TEST_HOST}",
+	})
+	suite.NoError(err)
+	suite.Equal([]string{
+		"-libvirt=qemu+ssh://db1/system",
+		"-ovsdb", "db1:6640",
+		"-api-token=secret",
+		"-tag", "value={metric:cpu} ${metric:mem}",
+		"-exclude", "^cpu$",
+		"${BITFLOW_TEST_HOST}",
+	}, args)
+
+	_, err = interpolateArgs([]string{"-ovsdb", "${BITFLOW_TEST_NOT_SET}"})
+	suite.Error(err)
+	_, err = interpolateArgs([]string{"${file://" + filepath.Join(dir, "missing") + "}"})
+	suite.Error(err)
+}
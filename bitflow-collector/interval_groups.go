@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// DefaultIntervalGroup is the value of the -interval-group-tag for samples of the main collector source
+const DefaultIntervalGroup = "default"
+
+var (
+	interval_groups    golib.StringSlice
+	interval_group_tag = "interval-group"
+
+	intervalGroupNameRegex = regexp.MustCompile("^[a-zA-Z0-9_.-]+$")
+)
+
+func init() {
+	flag.Var(&interval_groups, "interval-group", "'name=collect-interval[/sink-interval]:regex' Collect the metrics matching the regex in a separate collector source "+
+		"with its own intervals, e.g. 'fast=100ms:^(cpu|mem/)' or 'slow=5s/30s:^libvirt/'. The sink interval defaults to the collect interval. "+
+		"Every metric belongs to the first matching group, the remaining metrics are collected with -ci and -si. The samples of all sources are "+
+		"merged and tagged with the group name (see -interval-group-tag). The CPU budget, memory limit and -stats-log only apply to the main source, "+
+		"which also collects the metrics of -audit and -ingest (they cannot be selected by interval groups). Can be repeated")
+	flag.StringVar(&interval_group_tag, "interval-group-tag", interval_group_tag, "Tag that is set to the name of the interval group (see -interval-group), or to '"+
+		DefaultIntervalGroup+"' for the main collector source (empty to disable)")
+}
+
+// intervalGroup is a subset of metrics that is collected by a separate SampleSource with its own intervals
+type intervalGroup struct {
+	name            string
+	collectInterval time.Duration
+	sinkInterval    time.Duration
+	metrics         *regexp.Regexp
+}
+
+// parseIntervalGroup parses the format name=collect-interval[/sink-interval]:regex
+func parseIntervalGroup(spec string) (*intervalGroup, error) {
+	index := strings.IndexRune(spec, '=')
+	if index < 0 {
+		return nil, fmt.Errorf("Invalid interval group '%v', expected format: name=collect-interval[/sink-interval]:regex", spec)
+	}
+	group := &intervalGroup{name: spec[:index]}
+	if !intervalGroupNameRegex.MatchString(group.name) || group.name == DefaultIntervalGroup {
+		return nil, fmt.Errorf("Invalid name of interval group '%v' (must match %v and must not be '%v')", spec, intervalGroupNameRegex, DefaultIntervalGroup)
+	}
+	rest := spec[index+1:]
+	index = strings.IndexRune(rest, ':')
+	if index < 0 || index == len(rest)-1 {
+		return nil, fmt.Errorf("Invalid interval group '%v', missing the metric regex (format: name=collect-interval[/sink-interval]:regex)", spec)
+	}
+	intervals, regexStr := rest[:index], rest[index+1:]
+	collectStr, sinkStr := intervals, intervals
+	if index := strings.IndexRune(intervals, '/'); index >= 0 {
+		collectStr, sinkStr = intervals[:index], intervals[index+1:]
+	}
+	var err error
+	if group.collectInterval, err = time.ParseDuration(collectStr); err != nil {
+		return nil, fmt.Errorf("Invalid collect interval of interval group '%v': %v", spec, err)
+	}
+	if group.sinkInterval, err = time.ParseDuration(sinkStr); err != nil {
+		return nil, fmt.Errorf("Invalid sink interval of interval group '%v': %v", spec, err)
+	}
+	if group.collectInterval <= 0 || group.sinkInterval <= 0 {
+		return nil, fmt.Errorf("The intervals of interval group '%v' must be positive", spec)
+	}
+	if group.metrics, err = regexp.Compile(regexStr); err != nil {
+		return nil, fmt.Errorf("Error compiling regex of interval group '%v': %v", spec, err)
+	}
+	return group, nil
+}
+
+func parseIntervalGroups() ([]*intervalGroup, error) {
+	groups := make([]*intervalGroup, 0, len(interval_groups))
+	names := make(map[string]bool, len(interval_groups))
+	for _, spec := range interval_groups {
+		group, err := parseIntervalGroup(spec)
+		if err != nil {
+			return nil, err
+		}
+		if names[group.name] {
+			return nil, fmt.Errorf("Duplicate name of interval group: %v", spec)
+		}
+		names[group.name] = true
+		groups = append(groups, group)
+	}
+	if len(groups) > 0 && hf_interval > 0 {
+		return nil, fmt.Errorf("-interval-group cannot be combined with the high-frequency mode (-hf)")
+	}
+	return groups, nil
+}
+
+// newGroupSource creates a SampleSource for the metrics of one interval group, configured like the main source.
+// The metrics of the previous groups are excluded, since every metric belongs to the first matching group.
+// The collectors must be registered separately.
+func newGroupSource(main *collector.SampleSource, groups []*intervalGroup, index int) (*collector.SampleSource, error) {
+	group := groups[index]
+	config, err := intervalGroupConfig(main.ExcludeMetrics, main.IncludeMetrics, main.Tags, groups, index)
+	if err != nil {
+		return nil, err
+	}
+	return &collector.SampleSource{
+		UpdateFrequencies:               main.UpdateFrequencies,
+		CollectInterval:                 group.collectInterval,
+		SinkInterval:                    group.sinkInterval,
+		ExcludeMetrics:                  config.ExcludeMetrics,
+		IncludeMetrics:                  config.IncludeMetrics,
+		SelectMetrics:                   []*regexp.Regexp{group.metrics},
+		DisabledCollectors:              main.DisabledCollectors,
+		FilterFile:                      main.FilterFile,
+		FilterFileCheckInterval:         main.FilterFileCheckInterval,
+		UpdateParallelism:               main.UpdateParallelism,
+		WarmupSamples:                   main.WarmupSamples,
+		TagWarmupSamples:                main.TagWarmupSamples,
+		PartialSamples:                  main.PartialSamples,
+		UpdateTimeout:                   main.UpdateTimeout,
+		HeaderChangeInterval:            main.HeaderChangeInterval,
+		MissingMetricGracePeriod:        main.MissingMetricGracePeriod,
+		StdDevMetrics:                   main.StdDevMetrics,
+		StdDevWindow:                    main.StdDevWindow,
+		AnomalyMetrics:                  main.AnomalyMetrics,
+		AnomalyWindow:                   main.AnomalyWindow,
+		AlertRules:                      intervalGroupAlertRules(main.AlertRules, groups, index),
+		AlertActions:                    main.AlertActions,
+		Tags:                            config.Tags,
+		Chaos:                           main.Chaos,
+		UnitNormalization:               main.UnitNormalization,
+		CollectorErrorRings:             main.CollectorErrorRings,
+		FailedCollectorCheckInterval:    main.FailedCollectorCheckInterval,
+		FailedCollectorMaxCheckInterval: main.FailedCollectorMaxCheckInterval,
+		FilteredCollectorCheckInterval:  main.FilteredCollectorCheckInterval,
+	}, nil
+}
+
+// applyIntervalGroups excludes the metrics of all interval groups from the main source and tags its samples
+func applyIntervalGroups(main *collector.SampleSource, groups []*intervalGroup) error {
+	config, err := intervalGroupConfig(main.ExcludeMetrics, main.IncludeMetrics, main.Tags, groups, -1)
+	if err != nil {
+		return err
+	}
+	main.ExcludeMetrics = config.ExcludeMetrics
+	main.Tags = config.Tags
+	main.AlertRules = intervalGroupAlertRules(main.AlertRules, groups, -1)
+	return nil
+}
+
+// intervalGroupAlertRules returns the alert rules for the metrics of the interval group with the given index,
+// or for the main source if the index is -1. This avoids warnings about rules for metrics of other sources.
+func intervalGroupAlertRules(rules []collector.AlertRule, groups []*intervalGroup, index int) []collector.AlertRule {
+	var res []collector.AlertRule
+	for _, rule := range rules {
+		ruleIndex := -1
+		for i, group := range groups {
+			if group.metrics.MatchString(rule.Metric) {
+				ruleIndex = i
+				break
+			}
+		}
+		if ruleIndex == index {
+			res = append(res, rule)
+		}
+	}
+	return res
+}
+
+// intervalGroupConfig returns the metric filters and tags for the source of the interval group with the given index,
+// or for the main source if the index is -1. The metrics of the previous groups are excluded from the group sources,
+// and the metrics of all groups are excluded from the main source.
+func intervalGroupConfig(exclude []*regexp.Regexp, include []*regexp.Regexp, tags []*collector.TagTemplate, groups []*intervalGroup, index int) (collector.ReloadableConfig, error) {
+	name, excludedGroups := DefaultIntervalGroup, groups
+	if index >= 0 {
+		name, excludedGroups = groups[index].name, groups[:index]
+	}
+	allExclude := append([]*regexp.Regexp{}, exclude...)
+	for _, group := range excludedGroups {
+		allExclude = append(allExclude, group.metrics)
+	}
+	groupTags, err := intervalGroupTags(tags, name)
+	if err != nil {
+		return collector.ReloadableConfig{}, err
+	}
+	return collector.ReloadableConfig{ExcludeMetrics: allExclude, IncludeMetrics: include, Tags: groupTags}, nil
+}
+
+func intervalGroupTags(tags []*collector.TagTemplate, name string) ([]*collector.TagTemplate, error) {
+	if interval_group_tag == "" {
+		return tags, nil
+	}
+	tag, err := collector.ParseTagTemplate(interval_group_tag + "=" + name)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]*collector.TagTemplate{}, tags...), tag), nil
+}
+
+// mergeCollectorSources returns a source that forwards the samples of all collector sources to the same sink
+func mergeCollectorSources(sources []*collector.SampleSource) bitflow.SampleSource {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+	merged := &mergedSource{sources: make([]bitflow.SampleSource, len(sources))}
+	for i, source := range sources {
+		merged.sources[i] = source
+	}
+	return merged
+}
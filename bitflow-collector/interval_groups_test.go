@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type IntervalGroupTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestIntervalGroup(t *testing.T) {
+	suite.Run(t, new(IntervalGroupTestSuite))
+}
+
+func (suite *IntervalGroupTestSuite) TestParse() {
+	group, err := parseIntervalGroup("fast=100ms:^(cpu|mem/)")
+	suite.NoError(err)
+	suite.Equal("fast", group.name)
+	suite.Equal(100*time.Millisecond, group.collectInterval)
+	suite.Equal(100*time.Millisecond, group.sinkInterval)
+	suite.Equal("^(cpu|mem/)", group.metrics.String())
+
+	group, err = parseIntervalGroup("slow=5s/30s:^libvirt/")
+	suite.NoError(err)
+	suite.Equal(5*time.Second, group.collectInterval)
+	suite.Equal(30*time.Second, group.sinkInterval)
+
+	for _, spec := range []string{
+		"fast",
+		"=1s:cpu",
+		"default=1s:cpu",
+		"a b=1s:cpu",
+		"fast=1s",
+		"fast=1s:",
+		"fast=x:cpu",
+		"fast=1s/x:cpu",
+		"fast=0s:cpu",
+		"fast=1s:(",
+	} {
+		_, err := parseIntervalGroup(spec)
+		suite.Error(err, spec)
+	}
+}
+
+func (suite *IntervalGroupTestSuite) TestGroupSources() {
+	groups := []*intervalGroup{
+		{name: "fast", collectInterval: 100 * time.Millisecond, sinkInterval: time.Second, metrics: regexp.MustCompile("^cpu")},
+		{name: "slow", collectInterval: 5 * time.Second, sinkInterval: 5 * time.Second, metrics: regexp.MustCompile("^(cpu|libvirt)")},
+	}
+	main := &collector.SampleSource{
+		CollectInterval: 500 * time.Millisecond,
+		ExcludeMetrics:  []*regexp.Regexp{regexp.MustCompile("^net-proto/")},
+		AlertRules: []collector.AlertRule{
+			{Name: "cpu", Metric: "cpu"},
+			{Name: "vms", Metric: "libvirt/num_domains"},
+			{Name: "mem", Metric: "mem/percent"},
+		},
+	}
+
+	fast, err := newGroupSource(main, groups, 0)
+	suite.NoError(err)
+	suite.Equal(100*time.Millisecond, fast.CollectInterval)
+	suite.Equal(time.Second, fast.SinkInterval)
+	suite.Len(fast.ExcludeMetrics, 1)
+	suite.Equal([]*regexp.Regexp{groups[0].metrics}, fast.SelectMetrics)
+	suite.Equal([]collector.AlertRule{main.AlertRules[0]}, fast.AlertRules)
+	suite.Len(fast.Tags, 1)
+	suite.Equal("interval-group=fast", fast.Tags[0].String())
+
+	slow, err := newGroupSource(main, groups, 1)
+	suite.NoError(err)
+	suite.Equal([]*regexp.Regexp{main.ExcludeMetrics[0], groups[0].metrics}, slow.ExcludeMetrics)
+	suite.Equal([]collector.AlertRule{main.AlertRules[1]}, slow.AlertRules)
+
+	suite.NoError(applyIntervalGroups(main, groups))
+	suite.Len(main.ExcludeMetrics, 3)
+	suite.Equal([]collector.AlertRule{{Name: "mem", Metric: "mem/percent"}}, main.AlertRules)
+	suite.Len(main.Tags, 1)
+	suite.Equal("interval-group=default", main.Tags[0].String())
+	suite.Len(fast.ExcludeMetrics, 1)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector/inventory"
+	"github.com/bitflow-stream/go-bitflow/cmd"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	inventory_file     = ""
+	inventory_interval = time.Minute
+)
+
+func init() {
+	flag.StringVar(&inventory_file, "inventory-file", inventory_file, "Write the host inventory facts (CPU model, cores, RAM, disks, NICs, kernel, virtualization) as JSON "+
+		"to the given file at startup and whenever they change. The facts are also available through the REST API at /api/inventory")
+	flag.DurationVar(&inventory_interval, "inventory-interval", inventory_interval, "Interval for checking the host inventory facts for changes (see -inventory-file, 0 to disable)")
+}
+
+// InventoryApi serves the host inventory facts through the REST API
+type InventoryApi struct {
+	lock  sync.Mutex
+	facts *inventory.Facts
+}
+
+// startInventory writes the host inventory facts to the -inventory-file and keeps it updated. The inventory REST API
+// is registered with the helper in any case. The returned function stops the periodic checks.
+func startInventory(helper *cmd.CmdDataCollector) (func(), error) {
+	api := new(InventoryApi)
+	helper.RestApis = append(helper.RestApis, api)
+	if inventory_file == "" {
+		return func() {}, nil
+	}
+	if err := api.refresh(); err != nil {
+		return nil, err
+	}
+	if inventory_interval <= 0 {
+		return func() {}, nil
+	}
+	stopper := golib.NewStopChan()
+	go func() {
+		for !stopper.WaitTimeout(inventory_interval) {
+			if err := api.refresh(); err != nil {
+				log.Warnln("Failed to update the host inventory:", err)
+			}
+		}
+	}()
+	return stopper.Stop, nil
+}
+
+// refresh collects the host inventory facts and writes them to the -inventory-file, if they have changed
+func (api *InventoryApi) refresh() error {
+	facts, err := inventory.Collect()
+	if err != nil {
+		return err
+	}
+	api.lock.Lock()
+	previous := api.facts
+	api.facts = facts
+	api.lock.Unlock()
+	if previous != nil && reflect.DeepEqual(previous, facts) {
+		return nil
+	}
+	if previous != nil {
+		log.Println("The host inventory has changed, updating", inventory_file)
+	}
+	return writeInventoryFile(inventory_file, facts)
+}
+
+// writeInventoryFile replaces the file atomically, so that readers never see partially written facts
+func writeInventoryFile(filename string, facts *inventory.Facts) error {
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := filename + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("Failed to write host inventory: %v", err)
+	}
+	if err := os.Rename(tmpFile, filename); err != nil {
+		return fmt.Errorf("Failed to write host inventory: %v", err)
+	}
+	return nil
+}
+
+func (api *InventoryApi) Register(rootPath string, router *mux.Router) {
+	router.HandleFunc(rootPath+"/inventory", api.handleGetInventory).Methods("GET")
+}
+
+func (api *InventoryApi) handleGetInventory(w http.ResponseWriter, r *http.Request) {
+	api.lock.Lock()
+	facts := api.facts
+	api.lock.Unlock()
+	if facts == nil {
+		// The facts are not kept updated without -inventory-file, collect them on demand
+		var err error
+		if facts, err = inventory.Collect(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error: " + err.Error()))
+			return
+		}
+	}
+	writeJson(w, "host inventory", facts)
+}
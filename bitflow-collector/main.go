@@ -16,22 +16,44 @@ func main() {
 
 func do_main() int {
 	print_metrics := flag.Bool("print-metrics", false, "Print all available metrics and exit")
+	print_metrics_json := flag.Bool("print-metrics-json", false, "Print all available metrics including their metadata (unit, type, description) as JSON and exit")
 	print_root_collectors := flag.Bool("print-root-collectors", false, "Print the available root collectors and exit")
 	print_graph := flag.String("graph", "", "Create png-file for the collector-graph and exit")
 	print_graph_dot := flag.String("graph-dot", "", "Create dot-file for the collector-graph and exit")
 
-	// Parse command line flags
+	// Parse command line flags, after replacing environment variables and files
+	args, err := interpolateArgs(os.Args[1:])
+	golib.Checkerr(err)
+	os.Args = append(os.Args[:1], args...)
 	helper := cmd.CmdDataCollector{DefaultOutput: "box://-"}
 	helper.RegisterFlags()
-	_, args := cmd.ParseFlags()
+	flags, args := cmd.ParseFlags()
 	if len(args) > 0 {
 		log.Fatalln("Stray command line argument(s):", args)
 	}
+	golib.Checkerr(applySourceUris(flags))
+	golib.Checkerr(applyConfigFile(flags, config_file))
+	golib.Checkerr(configureHostFilesystem())
+	golib.Checkerr(configureRestApiSecurity(flags, &helper))
 	defer golib.ProfileCpu()()
+	stopAnnouncement, err := startMdnsAnnouncement()
+	golib.Checkerr(err)
+	defer stopAnnouncement()
+	stopInventory, err := startInventory(&helper)
+	golib.Checkerr(err)
+	defer stopInventory()
+
+	if aggregatorMode() {
+		return runAggregator(&helper)
+	}
 
 	// Configure the data collector pipeline
-	collector := createCollectorSource(&helper)
-	p, err := helper.BuildPipeline(collector)
+	sources := createCollectorSources(&helper)
+	defer configReloader.watchSignal()()
+	collector := sources[0]
+	source, err := addReceivedSamples(mergeCollectorSources(sources))
+	golib.Checkerr(err)
+	p, err := helper.BuildPipeline(source)
 	golib.Checkerr(err)
 	if p == nil {
 		return 0
@@ -51,6 +73,10 @@ func do_main() int {
 		golib.Checkerr(collector.PrintMetrics())
 		stop = true
 	}
+	if *print_metrics_json {
+		golib.Checkerr(collector.PrintMetricsJson())
+		stop = true
+	}
 	if *print_graph != "" {
 		golib.Checkerr(collector.PrintGraph(*print_graph, all_metrics))
 		stop = true
@@ -65,3 +91,14 @@ func do_main() int {
 
 	return p.StartAndWait()
 }
+
+func runAggregator(helper *cmd.CmdDataCollector) int {
+	source, err := createAggregatorSource()
+	golib.Checkerr(err)
+	p, err := helper.BuildPipeline(source)
+	golib.Checkerr(err)
+	if p == nil {
+		return 0
+	}
+	return p.StartAndWait()
+}
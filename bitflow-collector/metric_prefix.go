@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
+
+var (
+	metric_prefixes golib.StringSlice
+
+	// Parsed from metric_prefixes by parseMetricPrefixes(). The empty key holds the prefix for all other collectors.
+	rootCollectorPrefixes map[string]string
+)
+
+func init() {
+	flag.Var(&metric_prefixes, "metric-prefix", "'[collector=]prefix' Prefix the names of all metrics of the given root collector (e.g. 'libvirt=hv1' produces 'hv1/libvirt/...'), "+
+		"or of all root collectors without their own prefix if the collector name is omitted (e.g. 'staging'). The prefix is applied before filtering "+
+		"the metrics, so -include, -exclude and -interval-group must match the prefixed names. Can be repeated")
+}
+
+// parseMetricPrefixes parses the format [collector=]prefix of the -metric-prefix flag
+func parseMetricPrefixes() (map[string]string, error) {
+	prefixes := make(map[string]string, len(metric_prefixes))
+	for _, spec := range metric_prefixes {
+		name, prefix := "", spec
+		if index := strings.IndexRune(spec, '='); index >= 0 {
+			name, prefix = spec[:index], spec[index+1:]
+			if name == "" {
+				return nil, fmt.Errorf("Invalid metric prefix '%v', expected format: [collector=]prefix", spec)
+			}
+		}
+		prefix = strings.Trim(prefix, "/")
+		if prefix == "" {
+			return nil, fmt.Errorf("Invalid metric prefix '%v', the prefix must not be empty", spec)
+		}
+		if _, ok := prefixes[name]; ok {
+			return nil, fmt.Errorf("Duplicate metric prefix: %v", spec)
+		}
+		prefixes[name] = prefix
+	}
+	return prefixes, nil
+}
+
+// registerRootCollectors registers the given root collectors with the prefixes configured through -metric-prefix
+func registerRootCollectors(source *collector.SampleSource, cols ...collector.Collector) error {
+	for _, col := range cols {
+		prefix, ok := rootCollectorPrefixes[col.String()]
+		if !ok {
+			prefix = rootCollectorPrefixes[""]
+		}
+		if err := source.RegisterPrefixedCollector(prefix, col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricPrefixTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestMetricPrefix(t *testing.T) {
+	suite.Run(t, new(MetricPrefixTestSuite))
+}
+
+func (suite *MetricPrefixTestSuite) TestParse() {
+	defer func() {
+		metric_prefixes = nil
+	}()
+	metric_prefixes = golib.StringSlice{"libvirt=hv1/", "staging"}
+	prefixes, err := parseMetricPrefixes()
+	suite.NoError(err)
+	suite.Equal(map[string]string{"libvirt": "hv1", "": "staging"}, prefixes)
+
+	for _, invalid := range [][]string{
+		{"=hv1"},
+		{"libvirt="},
+		{"/"},
+		{"a", "b"},
+		{"libvirt=a", "libvirt=b"},
+	} {
+		metric_prefixes = invalid
+		_, err := parseMetricPrefixes()
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *MetricPrefixTestSuite) TestRegister() {
+	defer func() {
+		rootCollectorPrefixes = nil
+	}()
+	rootCollectorPrefixes = map[string]string{"libvirt": "hv1", "": "staging"}
+	source := new(collector.SampleSource)
+	libvirt := collector.RootCollector("libvirt")
+	psutil := collector.RootCollector("psutil")
+	suite.NoError(registerRootCollectors(source, &libvirt, &psutil))
+	suite.Len(source.RootCollectors, 2)
+	suite.Error(registerRootCollectors(source, &libvirt))
+}
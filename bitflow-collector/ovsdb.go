@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/ovsdb"
+)
+
+var ovsdb_targets golib.StringSlice
+
+func init() {
+	flag.Var(&ovsdb_targets, "ovsdb", "OVSDB server to connect to (format: host, host:port or name=host:port, default port "+strconv.Itoa(ovsdb.DefaultOvsdbPort)+"). "+
+		"Can be repeated to monitor multiple servers. The metrics of a named server are prefixed with 'ovsdb-<name>' instead of 'ovsdb'. Default: localhost")
+}
+
+// createOvsdbCollectors returns one collector for every -ovsdb parameter. Only one unnamed server is allowed,
+// since its metrics are reported under the default 'ovsdb' prefix. The names of all collectors are added
+// to the 'ovsdb' collector subsystem.
+func createOvsdbCollectors(factory *collector.ValueRingFactory) ([]collector.Collector, error) {
+	targets := ovsdb_targets
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+	var res []collector.Collector
+	names := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		name, addr := "", target
+		if index := strings.IndexRune(target, '='); index >= 0 {
+			name, addr = target[:index], target[index+1:]
+			if name == "" {
+				return nil, fmt.Errorf("Invalid OVSDB server '%v', expected format: host, host:port or name=host:port", target)
+			}
+		}
+		if names[name] {
+			if name == "" {
+				return nil, fmt.Errorf("Multiple unnamed OVSDB servers configured, use the format name=host:port (%v)", target)
+			}
+			return nil, fmt.Errorf("Duplicate name of OVSDB server: %v", target)
+		}
+		names[name] = true
+
+		host, port := addr, 0
+		if splitHost, portStr, err := net.SplitHostPort(addr); err == nil {
+			if port, err = strconv.Atoi(portStr); err != nil {
+				return nil, fmt.Errorf("Invalid port of OVSDB server '%v': %v", target, err)
+			}
+			host = splitHost
+		}
+		var col *ovsdb.Collector
+		if name == "" {
+			col = ovsdb.NewOvsdbCollectorPort(host, port, factory)
+		} else {
+			col = ovsdb.NewNamedOvsdbCollector(name, host, port, factory)
+		}
+		if col.Name != "ovsdb" && !containsString(collectorSubsystems["ovsdb"], col.Name) {
+			// Avoid duplicates when creating the collectors for multiple sources, see -interval-group
+			collectorSubsystems["ovsdb"] = append(collectorSubsystems["ovsdb"], col.Name)
+		}
+		res = append(res, col)
+	}
+	return res, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	receive_endpoint = ""
+	receive_tag      = ""
+)
+
+func init() {
+	flag.StringVar(&receive_endpoint, "receive", receive_endpoint, "In addition to collecting local metrics, receive samples from other agents on the given TCP endpoint (e.g. ':7777') and forward them to the same outputs")
+	flag.StringVar(&receive_tag, "receive-tag", receive_tag, "Tag that is set to the remote address for samples received through -receive (empty to disable)")
+}
+
+// addReceivedSamples returns a source that merges the locally collected samples with the samples received
+// through the endpoint configured by -receive. If no endpoint is configured, the local source is returned unchanged.
+func addReceivedSamples(local bitflow.SampleSource) (bitflow.SampleSource, error) {
+	if receive_endpoint == "" {
+		return local, nil
+	}
+	input, err := bitflow.NewEndpointFactory().CreateInput("listen://" + receive_endpoint)
+	if err != nil {
+		return nil, err
+	}
+	listener, ok := input.(*bitflow.TCPListenerSource)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected data source for receiving samples on %v: %v", receive_endpoint, input)
+	}
+	if receive_tag != "" {
+		listener.Reader.Handler = &remoteCollectorTagger{
+			tag:  receive_tag,
+			next: listener.Reader.Handler,
+		}
+	}
+	log.Println("Receiving additional samples on", receive_endpoint)
+	return &mergedSource{sources: []bitflow.SampleSource{local, listener}}, nil
+}
+
+// mergedSource funnels the samples of multiple sources into one sink. The samples are forwarded sequentially,
+// so the following pipeline steps are never called concurrently. The sink is closed after all sources are finished.
+type mergedSource struct {
+	bitflow.AbstractSampleSource
+	sources []bitflow.SampleSource
+
+	lock sync.Mutex
+	open int
+	wg   *sync.WaitGroup
+}
+
+func (m *mergedSource) String() string {
+	names := make([]string, len(m.sources))
+	for i, source := range m.sources {
+		names[i] = source.String()
+	}
+	return "Merged sources (" + strings.Join(names, ", ") + ")"
+}
+
+func (m *mergedSource) Start(wg *sync.WaitGroup) golib.StopChan {
+	m.wg = wg
+	m.open = len(m.sources)
+	stopped := golib.NewStopChan()
+	for _, source := range m.sources {
+		source.SetSink(&mergedSourceSink{merged: m})
+		sourceStopped := source.Start(wg)
+		go func() {
+			// Stop all sources when one of them stops
+			sourceStopped.Wait()
+			stopped.StopErr(sourceStopped.Err())
+		}()
+	}
+	return stopped
+}
+
+func (m *mergedSource) Close() {
+	for _, source := range m.sources {
+		source.Close()
+	}
+}
+
+func (m *mergedSource) sample(sample *bitflow.Sample, header *bitflow.Header) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.GetSink().Sample(sample, header)
+}
+
+func (m *mergedSource) sourceClosed() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.open--
+	if m.open == 0 {
+		m.CloseSinkParallel(m.wg)
+	}
+}
+
+type mergedSourceSink struct {
+	bitflow.NoopProcessor
+	merged *mergedSource
+}
+
+func (s *mergedSourceSink) Sample(sample *bitflow.Sample, header *bitflow.Header) error {
+	return s.merged.sample(sample, header)
+}
+
+func (s *mergedSourceSink) Close() {
+	s.merged.sourceClosed()
+}
+
+func (s *mergedSourceSink) String() string {
+	return "Merged source input"
+}
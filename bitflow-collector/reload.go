@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadableFlags are the flags that are applied again when the -config file is reloaded. All other flags
+// in the file only take effect when the collector is restarted.
+var reloadableFlags = []string{"include", "exclude", "tag", "proc", "proc-children"}
+
+var configReloader ConfigReloadApi
+
+// ConfigReloadApi reloads the -config file on SIGHUP or through the REST API. The metric filters and tags are
+// replaced in all sample sources, which restart their metric collection in place without interrupting the output.
+type ConfigReloadApi struct {
+	lock    sync.Mutex
+	sources []*collector.SampleSource
+	groups  []*intervalGroup
+
+	// Set by applyConfigFile()
+	flagSet     *flag.FlagSet
+	commandLine map[string]bool
+}
+
+// reloadableValues is a snapshot of the variables of the reloadable flags, used to roll back a failed reload
+type reloadableValues struct {
+	include, exclude, tags golib.StringSlice
+	procs, procChildren    golib.KeyValueStringSlice
+}
+
+func currentReloadableValues() reloadableValues {
+	return reloadableValues{
+		include:      user_include_metrics,
+		exclude:      user_exclude_metrics,
+		tags:         sample_tags,
+		procs:        multiProcApi.proc_collectors,
+		procChildren: multiProcApi.proc_children_collectors,
+	}
+}
+
+func (v reloadableValues) set() {
+	user_include_metrics = v.include
+	user_exclude_metrics = v.exclude
+	sample_tags = v.tags
+	multiProcApi.proc_collectors = v.procs
+	multiProcApi.proc_children_collectors = v.procChildren
+}
+
+// reset clears the value of the given flag in the snapshot
+func (v *reloadableValues) reset(name string) {
+	switch name {
+	case "include":
+		v.include = nil
+	case "exclude":
+		v.exclude = nil
+	case "tag":
+		v.tags = nil
+	case "proc":
+		v.procs = golib.KeyValueStringSlice{}
+	case "proc-children":
+		v.procChildren = golib.KeyValueStringSlice{}
+	}
+}
+
+func (api *ConfigReloadApi) Register(pathPrefix string, router *mux.Router) {
+	router.HandleFunc(pathPrefix+"/reload", api.handleReload).Methods("POST")
+}
+
+func (api *ConfigReloadApi) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := api.reload(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Failed to reload the configuration: " + err.Error() + "\n"))
+		return
+	}
+	w.Write([]byte("Reloaded configuration file " + config_file + "\n"))
+}
+
+// watchSignal reloads the configuration whenever the process receives SIGHUP. The returned function stops
+// the signal handling.
+func (api *ConfigReloadApi) watchSignal() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			log.Println("Received SIGHUP, reloading the configuration")
+			if err := api.reload(); err != nil {
+				log.Errorln("Failed to reload the configuration:", err)
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+// reload reads the -config file again and applies the reloadable flags, except for those given on the command line.
+// If the new configuration is invalid, the previous values are kept.
+func (api *ConfigReloadApi) reload() error {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	if config_file == "" || api.flagSet == nil {
+		return errors.New("No configuration file given (-config)")
+	}
+	if len(api.sources) == 0 {
+		return errors.New("No sample sources to reconfigure")
+	}
+	data, err := ioutil.ReadFile(config_file)
+	if err != nil {
+		return err
+	}
+	flags, err := parseConfigFile(api.flagSet, data)
+	if err != nil {
+		return fmt.Errorf("Failed to parse config file %v: %v", config_file, err)
+	}
+
+	multiProcApi.lock.Lock()
+	defer multiProcApi.lock.Unlock()
+	previous := currentReloadableValues()
+	values := previous
+	reloadable := make(map[string]bool)
+	for _, name := range reloadableFlags {
+		if !api.commandLine[name] {
+			values.reset(name)
+			reloadable[name] = true
+		}
+	}
+	values.set()
+	if err := api.apply(flags, reloadable); err != nil {
+		previous.set()
+		return err
+	}
+	log.Println("Reloaded configuration file", config_file)
+	return nil
+}
+
+func (api *ConfigReloadApi) apply(flags []sourceFlag, reloadable map[string]bool) error {
+	for _, f := range flags {
+		if !reloadable[f.name] {
+			log.Debugf("Config file %v: ignoring -%v, it is only applied on restart or overridden by the command line", config_file, f.name)
+			continue
+		}
+		if err := api.flagSet.Set(f.name, f.value); err != nil {
+			return fmt.Errorf("Invalid value '%v' for -%v in config file %v: %v", f.value, f.name, config_file, err)
+		}
+	}
+	exclude, include, err := metricFilterRegexes()
+	if err != nil {
+		return err
+	}
+	tags, err := sampleTagTemplates()
+	if err != nil {
+		return err
+	}
+	configs := make([]collector.ReloadableConfig, len(api.sources))
+	if len(api.groups) == 0 {
+		configs[0] = collector.ReloadableConfig{ExcludeMetrics: exclude, IncludeMetrics: include, Tags: tags}
+	} else {
+		for i := range api.sources {
+			// The first source is the main source, followed by the source of each interval group
+			if configs[i], err = intervalGroupConfig(exclude, include, tags, api.groups, i-1); err != nil {
+				return err
+			}
+		}
+	}
+	if err := multiProcApi.updateCollectors(); err != nil {
+		return err
+	}
+	for i, source := range api.sources {
+		source.Reconfigure(configs[i])
+	}
+	return nil
+}
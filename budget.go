@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	log "github.com/sirupsen/logrus"
+)
+
+// BudgetStatus describes the state of the CPU budget enforcement of a SampleSource.
+type BudgetStatus struct {
+	// CPU usage of the collector process in the last check interval, as a fraction of one core
+	CpuUsage  float64 `json:"cpu-usage"`
+	CpuBudget float64 `json:"cpu-budget"`
+
+	// Memory obtained from the OS by the collector process in bytes, and the configured MemoryLimit
+	MemoryUsage uint64 `json:"memory-usage"`
+	MemoryLimit uint64 `json:"memory-limit"`
+
+	// Collectors that have been disabled in order to stay within the CPU budget or the memory limit
+	ShedCollectors  []string `json:"shed-collectors"`
+	CollectInterval string   `json:"collect-interval"`
+}
+
+// MemoryLimitThreshold is the fraction of SampleSource.MemoryLimit, above which collectors are shed.
+const MemoryLimitThreshold = 0.9
+
+// The changes made to stay within the CPU budget or memory limit are reverted one by one, after the usage was below
+// BudgetRecoveryThreshold of the budget (or of the memory threshold) in BudgetRecoveryChecks consecutive checks.
+// The gap to the budget avoids shedding and re-enabling the same collector over and over again.
+const (
+	BudgetRecoveryThreshold = 0.5
+	BudgetRecoveryChecks    = 5
+)
+
+type budgetState struct {
+	lock            sync.Mutex
+	cpuShed         []string
+	memoryShed      []string
+	collectInterval time.Duration
+	cpuUsage        float64
+	memoryUsage     uint64
+	lastCpuTime     time.Duration
+	lastCheck       time.Time
+
+	// Number of consecutive checks with a usage below BudgetRecoveryThreshold
+	cpuRecoveryChecks    int
+	memoryRecoveryChecks int
+}
+
+// recovered counts the given check towards BudgetRecoveryChecks and returns true when a change can be reverted
+func (b *budgetState) recovered(checks *int, belowThreshold bool) bool {
+	if !belowThreshold {
+		*checks = 0
+		return false
+	}
+	*checks++
+	if *checks < BudgetRecoveryChecks {
+		return false
+	}
+	*checks = 0
+	return true
+}
+
+// BudgetStatus returns the current state of the CPU budget enforcement, see SampleSource.CpuBudget.
+func (source *SampleSource) BudgetStatus() BudgetStatus {
+	interval := source.currentCollectInterval()
+	b := &source.budget
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return BudgetStatus{
+		CpuUsage:        b.cpuUsage,
+		CpuBudget:       source.CpuBudget,
+		MemoryUsage:     b.memoryUsage,
+		MemoryLimit:     source.MemoryLimit,
+		ShedCollectors:  append(append([]string(nil), b.cpuShed...), b.memoryShed...),
+		CollectInterval: interval.String(),
+	}
+}
+
+// currentCollectInterval returns CollectInterval, unless it has been increased to stay within the CPU budget.
+func (source *SampleSource) currentCollectInterval() time.Duration {
+	source.budget.lock.Lock()
+	defer source.budget.lock.Unlock()
+	if interval := source.budget.collectInterval; interval > 0 {
+		return interval
+	}
+	return source.CollectInterval
+}
+
+func (source *SampleSource) disabledCollectors() []string {
+	source.budget.lock.Lock()
+	defer source.budget.lock.Unlock()
+	b := &source.budget
+	res := make([]string, 0, len(source.DisabledCollectors)+len(b.cpuShed)+len(b.memoryShed))
+	res = append(res, source.DisabledCollectors...)
+	res = append(res, b.cpuShed...)
+	return append(res, b.memoryShed...)
+}
+
+func (source *SampleSource) watchCpuBudget(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
+	if source.CpuBudget <= 0 {
+		return
+	}
+	if _, err := processCpuTime(); err != nil {
+		log.Warnln("Cannot enforce CPU budget:", err)
+		return
+	}
+	source.measureCpuUsage()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checkTime := time.Now()
+		for stopper.WaitTimeoutPrecise(source.BudgetCheckInterval, timeoutLoopFactor, &checkTime) {
+			if !source.checkCpuBudget(graph, source.measureCpuUsage()) {
+				graph.metricsChanged(stopper)
+				return
+			}
+		}
+	}()
+}
+
+func (source *SampleSource) measureCpuUsage() float64 {
+	b := &source.budget
+	cpuTime, err := processCpuTime()
+	if err != nil {
+		log.Warnln("Failed to measure CPU usage:", err)
+		return 0
+	}
+	now := time.Now()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.lastCheck.IsZero() {
+		if elapsed := now.Sub(b.lastCheck); elapsed > 0 {
+			b.cpuUsage = float64(cpuTime-b.lastCpuTime) / float64(elapsed)
+		}
+	}
+	b.lastCpuTime = cpuTime
+	b.lastCheck = now
+	return b.cpuUsage
+}
+
+// checkCpuBudget returns false, if the metric collection must be restarted to apply a change. Collectors are shed
+// without a restart, see shedCollector(), but re-enabling a collector requires a restart.
+func (source *SampleSource) checkCpuBudget(graph *collectorGraph, usage float64) bool {
+	if usage <= source.CpuBudget {
+		return source.recoverCpuBudget(usage)
+	}
+	source.budget.lock.Lock()
+	source.budget.cpuRecoveryChecks = 0
+	source.budget.lock.Unlock()
+
+	if node := graph.mostExpensiveLeaf(source.EssentialCollectors); node != nil {
+		log.Warnf("CPU usage of %.2f%% exceeds the budget of %.2f%%, disabling collector %v",
+			usage*100, source.CpuBudget*100, node)
+		source.budget.lock.Lock()
+		source.budget.cpuShed = append(source.budget.cpuShed, node.String())
+		source.budget.lock.Unlock()
+		graph.shedCollector(node)
+		return true
+	}
+
+	interval := source.currentCollectInterval()
+	if source.MaxCollectInterval > interval {
+		newInterval := interval * 2
+		if newInterval > source.MaxCollectInterval {
+			newInterval = source.MaxCollectInterval
+		}
+		log.Warnf("CPU usage of %.2f%% exceeds the budget of %.2f%%, increasing the collect interval to %v",
+			usage*100, source.CpuBudget*100, newInterval)
+		source.budget.lock.Lock()
+		source.budget.collectInterval = newInterval
+		source.budget.lock.Unlock()
+	} else {
+		log.Warnf("CPU usage of %.2f%% exceeds the budget of %.2f%%, but there are no more collectors to disable",
+			usage*100, source.CpuBudget*100)
+	}
+	return true
+}
+
+// recoverCpuBudget reverts the changes made by checkCpuBudget() in reverse order: first the collect interval is halved
+// until reaching CollectInterval again, then the shed collectors are re-enabled one by one, which requires a restart.
+func (source *SampleSource) recoverCpuBudget(usage float64) bool {
+	b := &source.budget
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if (b.collectInterval == 0 && len(b.cpuShed) == 0) ||
+		!b.recovered(&b.cpuRecoveryChecks, usage < source.CpuBudget*BudgetRecoveryThreshold) {
+		return true
+	}
+	if b.collectInterval > 0 {
+		newInterval := b.collectInterval / 2
+		if newInterval <= source.CollectInterval {
+			newInterval, b.collectInterval = source.CollectInterval, 0
+		} else {
+			b.collectInterval = newInterval
+		}
+		log.Printf("CPU usage of %.2f%% is well below the budget of %.2f%%, decreasing the collect interval to %v",
+			usage*100, source.CpuBudget*100, newInterval)
+		return true
+	}
+	name := b.cpuShed[len(b.cpuShed)-1]
+	b.cpuShed = b.cpuShed[:len(b.cpuShed)-1]
+	log.Printf("CPU usage of %.2f%% is well below the budget of %.2f%%, re-enabling collector %v",
+		usage*100, source.CpuBudget*100, name)
+	return false
+}
+
+// shedCollector removes the given node from the update rounds without restarting the metric collection. Its metrics
+// are reported as missing (NaN) until the next restart removes them from the header, see disabledCollectors().
+func (g *collectorGraph) shedCollector(node *collectorNode) {
+	g.modificationLock.Lock()
+	defer g.modificationLock.Unlock()
+	g.deleteCollector(node)
+	node.setFailing(true)
+	node.clearSampleValues()
+	g.pruneAndRepair()
+}
+
+func (source *SampleSource) watchMemoryLimit(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
+	if source.MemoryLimit == 0 {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checkTime := time.Now()
+		for stopper.WaitTimeoutPrecise(source.BudgetCheckInterval, timeoutLoopFactor, &checkTime) {
+			if !source.checkMemoryLimit(graph) {
+				graph.metricsChanged(stopper)
+				return
+			}
+		}
+	}()
+}
+
+// measureMemoryUsage returns the memory obtained from the OS by the Go runtime, excluding memory that has been
+// returned to the OS already. If forceRelease is set, unused memory is released to the OS before measuring.
+func (source *SampleSource) measureMemoryUsage(forceRelease bool) uint64 {
+	if forceRelease {
+		debug.FreeOSMemory()
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	usage := stats.Sys - stats.HeapReleased
+	source.budget.lock.Lock()
+	source.budget.memoryUsage = usage
+	source.budget.lock.Unlock()
+	return usage
+}
+
+// checkMemoryLimit returns false, if the metric collection must be restarted to apply a change. Unlike with the
+// CPU budget, shed collectors require a restart, because their memory is only released with the collector graph.
+func (source *SampleSource) checkMemoryLimit(graph *collectorGraph) bool {
+	threshold := uint64(float64(source.MemoryLimit) * MemoryLimitThreshold)
+	if usage := source.measureMemoryUsage(false); usage <= threshold {
+		return source.recoverMemoryLimit(usage, threshold)
+	}
+	source.budget.lock.Lock()
+	source.budget.memoryRecoveryChecks = 0
+	source.budget.lock.Unlock()
+
+	// Only shed collectors if the memory cannot be reclaimed through garbage collection
+	usage := source.measureMemoryUsage(true)
+	if usage <= threshold {
+		return true
+	}
+	if node := graph.largestLeaf(source.EssentialCollectors); node != nil {
+		log.Warnf("Memory usage of %v bytes approaches the limit of %v bytes, disabling collector %v with %v metrics",
+			usage, source.MemoryLimit, node, len(node.metrics))
+		source.budget.lock.Lock()
+		source.budget.memoryShed = append(source.budget.memoryShed, node.String())
+		source.budget.lock.Unlock()
+		return false
+	}
+	log.Warnf("Memory usage of %v bytes approaches the limit of %v bytes, but there are no more collectors to disable",
+		usage, source.MemoryLimit)
+	return true
+}
+
+// recoverMemoryLimit re-enables the collectors shed by checkMemoryLimit() one by one, which requires a restart.
+func (source *SampleSource) recoverMemoryLimit(usage, threshold uint64) bool {
+	b := &source.budget
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.memoryShed) == 0 ||
+		!b.recovered(&b.memoryRecoveryChecks, float64(usage) < float64(threshold)*BudgetRecoveryThreshold) {
+		return true
+	}
+	name := b.memoryShed[len(b.memoryShed)-1]
+	b.memoryShed = b.memoryShed[:len(b.memoryShed)-1]
+	log.Printf("Memory usage of %v bytes is well below the limit of %v bytes, re-enabling collector %v",
+		usage, source.MemoryLimit, name)
+	return false
+}
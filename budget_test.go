@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+func (suite *SchedulerTestSuite) TestCpuBudgetShedding() {
+	for _, test := range []struct {
+		name      string
+		essential string
+		shed      []string
+	}{
+		{"most expensive first", "", []string{"root/b", "root/c"}},
+		{"essential collector", "^root/b$", []string{"root/c", "root/a"}},
+		{"all essential", "^root/", nil},
+	} {
+		l := newUpdateLog()
+		root := suite.newCollector(l, "root", nil)
+		costs := map[*mockCollector]int64{
+			suite.newCollector(l, "a", root): 10,
+			suite.newCollector(l, "b", root): 30,
+			suite.newCollector(l, "c", root): 20,
+		}
+		graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+		suite.NoError(err)
+		nodes := make(map[string]*collectorNode)
+		for col, cost := range costs {
+			node := graph.resolve(col)
+			node.updateCost = cost
+			nodes[node.String()] = node
+		}
+		source := &SampleSource{CpuBudget: 0.1, CollectInterval: time.Second}
+		if test.essential != "" {
+			source.EssentialCollectors = []*regexp.Regexp{regexp.MustCompile(test.essential)}
+		}
+
+		// Shed one collector per check without restarting, as long as more than one leaf is left
+		for _, name := range test.shed {
+			suite.True(source.checkCpuBudget(graph, 0.5), test.name)
+			suite.False(graph.containsNode(nodes[name]), test.name)
+		}
+		suite.True(source.checkCpuBudget(graph, 0.5), test.name)
+		suite.Equal(test.shed, source.BudgetStatus().ShedCollectors, test.name)
+	}
+}
+
+func (suite *SchedulerTestSuite) TestCpuBudgetCollectInterval() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	suite.newCollector(l, "a", root)
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+	source := &SampleSource{CpuBudget: 0.1, CollectInterval: time.Second, MaxCollectInterval: 5 * time.Second}
+
+	// Usage within the budget does not change anything
+	suite.True(source.checkCpuBudget(graph, 0.05))
+	suite.Equal(time.Second, source.currentCollectInterval())
+
+	// The only leaf is not shed, instead the interval is doubled up to the maximum
+	for _, expected := range []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		suite.True(source.checkCpuBudget(graph, 0.5))
+		suite.Equal(expected, source.currentCollectInterval())
+	}
+	suite.Empty(source.BudgetStatus().ShedCollectors)
+	suite.Equal("5s", source.BudgetStatus().CollectInterval)
+}
+
+func (suite *SchedulerTestSuite) TestMemoryLimit() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	suite.newCollector(l, "a", root)
+	b := suite.newCollector(l, "b", root)
+	suite.newCollector(l, "c", root)
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+	graph.resolve(b).metrics["b/extra"] = nil
+
+	source := &SampleSource{MemoryLimit: 1}
+	suite.False(source.checkMemoryLimit(graph))
+	suite.Equal([]string{"root/b"}, source.disabledCollectors())
+	suite.True(source.BudgetStatus().MemoryUsage > 0)
+
+	// Essential collectors are not disabled, and the last leaf is kept
+	source = &SampleSource{MemoryLimit: 1, EssentialCollectors: []*regexp.Regexp{regexp.MustCompile("^root/(a|b)$")}}
+	suite.False(source.checkMemoryLimit(graph))
+	suite.Equal([]string{"root/c"}, source.disabledCollectors())
+
+	source = &SampleSource{MemoryLimit: 1 << 50}
+	suite.True(source.checkMemoryLimit(graph))
+	suite.Empty(source.disabledCollectors())
+}
+
+func (suite *SchedulerTestSuite) TestShedCollector() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	a := suite.newCollector(l, "a", root)
+	b := suite.newCollector(l, "b", root)
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+	_, getValues := graph.getMetrics().ConstructSample(&SampleSource{}, 0)
+	suite.Equal([]bitflow.Value{0, 0, 0}, getValues())
+
+	// The shed collector is not updated anymore and its metric is reported as missing
+	graph.shedCollector(graph.resolve(b))
+	newUpdateScheduler(graph, 0).runRound(context.Background(), golib.NewStopChan())
+	suite.Contains(l.finished, a.String())
+	suite.NotContains(l.finished, b.String())
+	values := getValues()
+	suite.Equal([]bitflow.Value{0, 0}, values[:2])
+	suite.True(math.IsNaN(float64(values[2])))
+}
+
+func (suite *SchedulerTestSuite) TestCpuBudgetRecovery() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	suite.newCollector(l, "a", root)
+	suite.newCollector(l, "b", root)
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+	source := &SampleSource{CpuBudget: 0.1, CollectInterval: time.Second, MaxCollectInterval: 4 * time.Second}
+
+	// Shed one collector, then increase the collect interval twice
+	for i := 0; i < 3; i++ {
+		suite.True(source.checkCpuBudget(graph, 0.5))
+	}
+	suite.Len(source.BudgetStatus().ShedCollectors, 1)
+	suite.Equal(4*time.Second, source.currentCollectInterval())
+
+	recoverChecks := func() {
+		for i := 0; i < BudgetRecoveryChecks-1; i++ {
+			suite.True(source.checkCpuBudget(graph, 0.01))
+		}
+	}
+
+	// A usage below the budget, but above the recovery threshold, resets the recovery
+	recoverChecks()
+	suite.True(source.checkCpuBudget(graph, 0.08))
+	recoverChecks()
+	suite.Equal(4*time.Second, source.currentCollectInterval())
+
+	// The collect interval is reverted first, then the shed collector is re-enabled through a restart
+	suite.True(source.checkCpuBudget(graph, 0.01))
+	suite.Equal(2*time.Second, source.currentCollectInterval())
+	recoverChecks()
+	suite.True(source.checkCpuBudget(graph, 0.01))
+	suite.Equal(time.Second, source.currentCollectInterval())
+	suite.Len(source.BudgetStatus().ShedCollectors, 1)
+	recoverChecks()
+	suite.False(source.checkCpuBudget(graph, 0.01))
+	suite.Empty(source.BudgetStatus().ShedCollectors)
+	suite.Equal("1s", source.BudgetStatus().CollectInterval)
+
+	// Without any changes to revert, nothing happens
+	recoverChecks()
+	suite.True(source.checkCpuBudget(graph, 0.01))
+}
+
+func (suite *SchedulerTestSuite) TestMemoryLimitRecovery() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	suite.newCollector(l, "a", root)
+	suite.newCollector(l, "b", root)
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+
+	source := &SampleSource{MemoryLimit: 1}
+	suite.False(source.checkMemoryLimit(graph))
+	suite.Len(source.disabledCollectors(), 1)
+
+	// The shed collectors are re-enabled through a restart, after the usage is well below the limit
+	source.MemoryLimit = 1 << 50
+	for i := 0; i < BudgetRecoveryChecks-1; i++ {
+		suite.True(source.checkMemoryLimit(graph))
+		suite.Len(source.disabledCollectors(), 1)
+	}
+	suite.False(source.checkMemoryLimit(graph))
+	suite.Empty(source.disabledCollectors())
+	suite.True(source.checkMemoryLimit(graph))
+}
@@ -0,0 +1,10 @@
+#!/usr/bin/env sh
+# Builds a fully static binary without cgo, which runs on minimal images like alpine or scratch without any libraries.
+# The libvirt collector uses the virsh backend, the pcap collector fails with an error, and plugins cannot be loaded.
+home=`dirname $(readlink -f $0)`
+root=`readlink -f "$home/.."`
+cd "$home"
+
+target="$home/_output/static"
+mkdir -p "$target"
+CGO_ENABLED=0 go build -o "$target/bitflow-collector" $@ "$root/bitflow-collector"
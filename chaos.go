@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// errInjectedFailure is returned instead of the result of Collector.Update(), when an error is injected by ChaosSpec
+var errInjectedFailure = errors.New("Injected failure (chaos mode)")
+
+// ChaosSpec configures the fault injection of a SampleSource, used to test the robustness of the collector
+// scheduling and of downstream pipelines. Every collector update fails with ErrorProbability, and is delayed by
+// a random duration up to MaxDelay with DelayProbability. Every metric is dropped from the header with
+// DropProbability when the metric collection is (re)started, and every read metric value is replaced by NaN
+// with NaNProbability.
+type ChaosSpec struct {
+	ErrorProbability float64
+	DelayProbability float64
+	MaxDelay         time.Duration
+	DropProbability  float64
+	NaNProbability   float64
+}
+
+// ParseChaosSpec parses a comma-separated list of key=value pairs, e.g. error=0.01,delay=0.1,max-delay=2s,drop=0.05,nan=0.01.
+// The probabilities must be between 0 and 1. The default MaxDelay is 1 second.
+func ParseChaosSpec(spec string) (*ChaosSpec, error) {
+	res := &ChaosSpec{MaxDelay: time.Second}
+	for _, part := range strings.Split(spec, ",") {
+		index := strings.IndexRune(part, '=')
+		if index <= 0 {
+			return nil, fmt.Errorf("Invalid chaos spec '%v', expected format: key=value,...", spec)
+		}
+		key, value := part[:index], part[index+1:]
+		var probability *float64
+		switch key {
+		case "error":
+			probability = &res.ErrorProbability
+		case "delay":
+			probability = &res.DelayProbability
+		case "drop":
+			probability = &res.DropProbability
+		case "nan":
+			probability = &res.NaNProbability
+		case "max-delay":
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay <= 0 {
+				return nil, fmt.Errorf("Invalid max-delay '%v' in chaos spec, expected positive duration", value)
+			}
+			res.MaxDelay = delay
+			continue
+		default:
+			return nil, fmt.Errorf("Unknown key '%v' in chaos spec, available: error, delay, max-delay, drop, nan", key)
+		}
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil || val < 0 || val > 1 {
+			return nil, fmt.Errorf("Invalid probability '%v' for %v in chaos spec, expected value between 0 and 1", value, key)
+		}
+		*probability = val
+	}
+	return res, nil
+}
+
+func (spec *ChaosSpec) String() string {
+	return fmt.Sprintf("error=%v,delay=%v,max-delay=%v,drop=%v,nan=%v",
+		spec.ErrorProbability, spec.DelayProbability, spec.MaxDelay, spec.DropProbability, spec.NaNProbability)
+}
+
+// applyChaos drops random metrics from all nodes and replaces the remaining metric readers with readers that
+// randomly return NaN. The nodes inject errors and delays into their updates.
+func (g *collectorGraph) applyChaos(spec *ChaosSpec) {
+	if spec == nil {
+		return
+	}
+	dropped := 0
+	for node := range g.nodes {
+		node.chaos = spec
+		for name, reader := range node.metrics {
+			if rand.Float64() < spec.DropProbability {
+				delete(node.metrics, name)
+				dropped++
+			} else if spec.NaNProbability > 0 {
+				node.metrics[name] = spec.nanReader(reader)
+			}
+		}
+	}
+	log.Warnf("Chaos mode enabled (%v), dropped %v metrics", spec, dropped)
+}
+
+func (spec *ChaosSpec) nanReader(reader MetricReader) MetricReader {
+	return func() bitflow.Value {
+		if rand.Float64() < spec.NaNProbability {
+			return bitflow.Value(math.NaN())
+		}
+		return reader()
+	}
+}
+
+// inject delays the current update and returns an injected error, according to the probabilities of the spec.
+func (spec *ChaosSpec) inject(ctx context.Context) error {
+	if spec == nil {
+		return nil
+	}
+	if spec.MaxDelay > 0 && rand.Float64() < spec.DelayProbability {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(spec.MaxDelay)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if rand.Float64() < spec.ErrorProbability {
+		return errInjectedFailure
+	}
+	return nil
+}
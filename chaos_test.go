@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type ChaosTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestChaos(t *testing.T) {
+	suite.Run(t, new(ChaosTestSuite))
+}
+
+func (suite *ChaosTestSuite) TestParseChaosSpec() {
+	spec, err := ParseChaosSpec("error=0.1,delay=0.5,max-delay=2s,drop=0.05,nan=1")
+	suite.NoError(err)
+	suite.Equal(&ChaosSpec{
+		ErrorProbability: 0.1,
+		DelayProbability: 0.5,
+		MaxDelay:         2 * time.Second,
+		DropProbability:  0.05,
+		NaNProbability:   1,
+	}, spec)
+
+	for _, invalid := range []string{"", "error", "error=abc", "error=1.5", "nan=-1", "max-delay=0s", "unknown=0.1"} {
+		_, err := ParseChaosSpec(invalid)
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ChaosTestSuite) TestInjection() {
+	l := newUpdateLog()
+	cols := []Collector{
+		&mockCollector{AbstractCollector: RootCollector("a"), log: l},
+		&mockCollector{AbstractCollector: RootCollector("b"), log: l},
+	}
+
+	graph, err := initCollectorGraph(context.Background(), cols, nil)
+	suite.NoError(err)
+	graph.applyChaos(&ChaosSpec{NaNProbability: 1, ErrorProbability: 1})
+	metrics := graph.getMetrics()
+	suite.Len(metrics, 2)
+	for _, metric := range metrics {
+		suite.True(math.IsNaN(float64(metric.reader())))
+	}
+	for node := range graph.nodes {
+		node.update(context.Background(), golib.NewStopChan())
+		suite.Equal(1, node.failedUpdates)
+	}
+	suite.Empty(l.started)
+
+	graph, err = initCollectorGraph(context.Background(), cols, nil)
+	suite.NoError(err)
+	graph.applyChaos(&ChaosSpec{DropProbability: 1})
+	suite.Empty(graph.getMetrics())
+}
@@ -1,9 +1,9 @@
 package collector
 
 import (
+	"context"
 	"errors"
 	"sort"
-	"sync"
 
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 )
@@ -26,13 +26,16 @@ var MetricsChanged = errors.New("Metrics of this collector have changed")
 // Typically, each collector will returns its parent-collector as sole dependency, but it
 // can also return an empty slice or multiple dependencies. All collectors returned from any
 // Depends() method must already have been initialized in the Init() sequence.
+// The context.Context parameters of Init(), Update() and MetricsChanged() are canceled when
+// the collection is stopped or restarted. Long-running implementations should check the context
+// and return early when it is canceled.
 type Collector interface {
 
 	// Init prepares this collector for collecting metrics and instantiates sub-collectors.
 	// If there is no error, the sub-collectors will also be initialized, until there are
 	// no more sub-nodes. The metrics in the MetricReaderMap are all stored in one flat list,
 	// the keys must be globally unique.
-	Init() (subCollectors []Collector, err error)
+	Init(ctx context.Context) (subCollectors []Collector, err error)
 
 	// Metrics will only be called after Init() returned successfully. It returns the metrics
 	// that are provided by this collector.
@@ -49,13 +52,13 @@ type Collector interface {
 	// An error stops descending down the tree. After a collector has been updated,
 	// the metrics associated with that collector will be read. Collectors with only excluded metrics
 	// will not be updated.
-	Update() error
+	Update(ctx context.Context) error
 
 	// MetricsChanged should check if the collector can produce a different set of metrics, and if so,
 	// the MetricsChanged error instance. Many collectors have a fixed set of metrics, so nil should
 	// be returned here (as in AbstractCollector). Collectors that potentially return MetricsChanged from
 	// Update(), should use Update() as implementation for MetricsChanged().
-	MetricsChanged() error
+	MetricsChanged(ctx context.Context) error
 
 	// String returns a short but unique label for the collector.
 	String() string
@@ -88,7 +91,7 @@ func (col *AbstractCollector) String() string {
 	return parentName + col.Name
 }
 
-func (col *AbstractCollector) Init() ([]Collector, error) {
+func (col *AbstractCollector) Init(_ context.Context) ([]Collector, error) {
 	return nil, nil
 }
 
@@ -100,34 +103,20 @@ func (col *AbstractCollector) Metrics() MetricReaderMap {
 	return nil
 }
 
-func (col *AbstractCollector) Update() error {
+func (col *AbstractCollector) Update(_ context.Context) error {
 	return nil
 }
 
-func (col *AbstractCollector) MetricsChanged() error {
+func (col *AbstractCollector) MetricsChanged(_ context.Context) error {
 	return nil
 }
 
 // ==================== Metric ====================
 type Metric struct {
-	name   string
-	index  int
-	sample []bitflow.Value
-	reader MetricReader
-
-	// The use of this RWMutex is inverted: the Metric.Update() routine uses
-	// the read-lock, even though it writes data, because we every instance of Metric
-	// accesses another index in the []bitflow.Value slice. The copy function returned by
-	// ConstructSample() uses the writer-lock, even though it reads (copies) the sample slice,
-	// because we need its access to be exclusive from the write accesses by all Metric instances.
-	sampleLock *sync.RWMutex
-}
-
-func (metric *Metric) Update() {
-	metric.sampleLock.RLock()
-	defer metric.sampleLock.RUnlock()
-
-	metric.sample[metric.index] = metric.reader()
+	name     string
+	reader   MetricReader
+	metadata MetricMetadata
+	node     *collectorNode
 }
 
 // ==================== Metric Slice ====================
@@ -145,32 +134,43 @@ func (s MetricSlice) Less(i, j int) bool {
 	return s[i].name < s[j].name
 }
 
-func (s MetricSlice) ConstructSample(source *SampleSource) ([]string, func() []bitflow.Value) {
-	var sampleLock sync.RWMutex // See comment at Metric.sampleLock
-
+// ConstructSample sorts the metrics and returns the resulting header fields, along with a function that reads all
+// metrics into a new value slice. The only allocation per sample is the value slice, which has enough capacity
+// to avoid further allocations in the sink of the given source, and for appending extraValues additional values.
+// The returned function only reads the values that were snapshot after the latest update of every collector, so it
+// can be invoked concurrently to the updates. Static metrics are read from their cached values.
+func (s MetricSlice) ConstructSample(source *SampleSource, extraValues int) ([]string, func() []bitflow.Value) {
 	sort.Sort(s)
 	fields := make([]string, len(s))
-	values := make([]bitflow.Value, len(s))
+	readers := make([]MetricReader, len(s))
 	for i, metric := range s {
 		fields[i] = metric.name
-		metric.index = i
-		metric.sample = values
-		metric.sampleLock = &sampleLock
+		readers[i] = metric.reader
+		if metric.node != nil && !metric.node.isStaticMetric(metric.name) {
+			readers[i] = metric.node.sampleReader(metric.reader)
+		}
+		if source.PartialSamples && metric.node != nil {
+			readers[i] = metric.node.partialReader(readers[i])
+		}
+		if source.UpdateTimeout > 0 && metric.node != nil {
+			readers[i] = metric.node.failureReader(readers[i])
+		}
 	}
 
-	valueLen := len(values)
-	valueCap := bitflow.RequiredValues(valueLen, source.GetSink())
+	valueCap := bitflow.RequiredValues(len(readers)+extraValues, source.GetSink())
 	return fields, func() []bitflow.Value {
-		sampleCopy := make([]bitflow.Value, valueLen, valueCap)
-		sampleLock.Lock()
-		defer sampleLock.Unlock()
-		copy(sampleCopy, values)
-		return sampleCopy
+		values := make([]bitflow.Value, len(readers), valueCap)
+		for i, reader := range readers {
+			values[i] = reader()
+		}
+		return values
 	}
 }
 
-func (s MetricSlice) UpdateAll() {
+func (s MetricSlice) Metadata() MetricMetadataMap {
+	res := make(MetricMetadataMap, len(s))
 	for _, metric := range s {
-		metric.Update()
+		res[metric.name] = metric.metadata
 	}
+	return res
 }
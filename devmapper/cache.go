@@ -0,0 +1,197 @@
+package devmapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// cacheCollector reports the statistics of all dm-cache devices, e.g. LVM cache volumes
+type cacheCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+
+	lock   sync.Mutex
+	caches map[string]*cacheStats
+}
+
+type cacheStats struct {
+	readHits, readMisses, writeHits, writeMisses *collector.ValueRing
+	promotions, demotions                        *collector.ValueRing
+	usage                                        float64
+	dirty                                        float64
+}
+
+// cacheStatus is one line of 'dmsetup status --target cache'
+type cacheStatus struct {
+	readHits, readMisses, writeHits, writeMisses float64
+	promotions, demotions                        float64
+	usage                                        float64
+	dirty                                        float64
+}
+
+func (col *cacheCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	status, err := col.readStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	factory := col.parent.factory
+	col.caches = make(map[string]*cacheStats, len(status))
+	for name := range status {
+		col.caches[name] = &cacheStats{
+			readHits:    factory.NewValueRing(),
+			readMisses:  factory.NewValueRing(),
+			writeHits:   factory.NewValueRing(),
+			writeMisses: factory.NewValueRing(),
+			promotions:  factory.NewValueRing(),
+			demotions:   factory.NewValueRing(),
+		}
+	}
+	return nil, nil
+}
+
+func (col *cacheCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *cacheCollector) Update(ctx context.Context) error {
+	status, err := col.readStatus(ctx)
+	if err != nil {
+		return err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	if len(status) != len(col.caches) {
+		return collector.MetricsChanged
+	}
+	for name, s := range status {
+		stats, ok := col.caches[name]
+		if !ok {
+			return collector.MetricsChanged
+		}
+		stats.readHits.Add(collector.StoredValue(s.readHits))
+		stats.readMisses.Add(collector.StoredValue(s.readMisses))
+		stats.writeHits.Add(collector.StoredValue(s.writeHits))
+		stats.writeMisses.Add(collector.StoredValue(s.writeMisses))
+		stats.promotions.Add(collector.StoredValue(s.promotions))
+		stats.demotions.Add(collector.StoredValue(s.demotions))
+		stats.usage = s.usage
+		stats.dirty = s.dirty
+	}
+	return nil
+}
+
+func (col *cacheCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *cacheCollector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap)
+	for name, stats := range col.caches {
+		stats := stats
+		prefix := "dm/" + name + "/cache/"
+		res[prefix+"read/hits"] = stats.readHits.GetDiff
+		res[prefix+"read/misses"] = stats.readMisses.GetDiff
+		res[prefix+"write/hits"] = stats.writeHits.GetDiff
+		res[prefix+"write/misses"] = stats.writeMisses.GetDiff
+		res[prefix+"promotions"] = stats.promotions.GetDiff
+		res[prefix+"demotions"] = stats.demotions.GetDiff
+		res[prefix+"hit-ratio"] = func() bitflow.Value {
+			hits := stats.readHits.GetDiff() + stats.writeHits.GetDiff()
+			total := hits + stats.readMisses.GetDiff() + stats.writeMisses.GetDiff()
+			if total <= 0 {
+				return 0
+			}
+			return hits / total * 100
+		}
+		res[prefix+"usage"] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(stats.usage)
+		}
+		res[prefix+"dirty"] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(stats.dirty)
+		}
+	}
+	return res
+}
+
+func (col *cacheCollector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricMetadataMap)
+	for name := range col.caches {
+		prefix := "dm/" + name + "/cache/"
+		res[prefix+"read/hits"] = collector.DerivedMetric(collector.UnitPerSecond, "Read requests served from the cache")
+		res[prefix+"read/misses"] = collector.DerivedMetric(collector.UnitPerSecond, "Read requests served from the origin device")
+		res[prefix+"write/hits"] = collector.DerivedMetric(collector.UnitPerSecond, "Write requests to cached blocks")
+		res[prefix+"write/misses"] = collector.DerivedMetric(collector.UnitPerSecond, "Write requests to blocks that are not cached")
+		res[prefix+"promotions"] = collector.DerivedMetric(collector.UnitPerSecond, "Blocks moved into the cache")
+		res[prefix+"demotions"] = collector.DerivedMetric(collector.UnitPerSecond, "Blocks removed from the cache")
+		res[prefix+"hit-ratio"] = collector.DerivedMetric(collector.UnitPercent, "Share of cache hits among all read and write requests")
+		res[prefix+"usage"] = collector.GaugeMetric(collector.UnitPercent, "Used cache blocks")
+		res[prefix+"dirty"] = collector.GaugeMetric(collector.UnitBytes, "Dirty data in the cache that is not yet written to the origin device")
+	}
+	return res
+}
+
+func (col *cacheCollector) readStatus(ctx context.Context) (map[string]cacheStatus, error) {
+	output, err := col.parent.dmsetupStatus(ctx, "cache")
+	if err != nil {
+		return nil, err
+	}
+	return parseCacheStatus(output)
+}
+
+// parseCacheStatus parses the output of 'dmsetup status --target cache', see Documentation/admin-guide/device-mapper/cache.rst
+// in the kernel sources. Every line has the format:
+// <name>: <start> <length> cache <metadata block size> <used>/<total metadata blocks> <cache block size> <used>/<total cache blocks>
+// <read hits> <read misses> <write hits> <write misses> <demotions> <promotions> <dirty blocks> ...
+func parseCacheStatus(output []byte) (map[string]cacheStatus, error) {
+	var err error
+	res := make(map[string]cacheStatus)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 15 || fields[3] != "cache" {
+			// Also skips the output "No devices found", and failed caches
+			continue
+		}
+		var values [7]float64
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(fields[8+i], 64); err != nil {
+				return nil, fmt.Errorf("Failed to parse cache status: %v", scanner.Text())
+			}
+		}
+		usage, err := parseUsage(fields[7])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse cache status: %v", scanner.Text())
+		}
+		blockSize, err := strconv.ParseFloat(fields[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse cache status: %v", scanner.Text())
+		}
+		res[strings.TrimSuffix(fields[0], ":")] = cacheStatus{
+			readHits:    values[0],
+			readMisses:  values[1],
+			writeHits:   values[2],
+			writeMisses: values[3],
+			demotions:   values[4],
+			promotions:  values[5],
+			dirty:       values[6] * blockSize * sectorSize,
+			usage:       usage,
+		}
+	}
+	return res, scanner.Err()
+}
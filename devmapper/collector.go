@@ -0,0 +1,214 @@
+package devmapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	DefaultSysBlock = "/sys/block"
+	DefaultDmsetup  = "dmsetup"
+
+	sectorSize = 512
+)
+
+// Collector reports IO statistics of all device-mapper devices, e.g. LVM logical volumes, the data and metadata
+// usage of thin pools, and the statistics of dm-cache devices. Metrics are named dm/<name>/..., where <name> is the
+// device-mapper name (e.g. <vg>-<lv> for LVM volumes). The status of thin pools and caches is obtained through
+// 'dmsetup status', which requires root privileges.
+type Collector struct {
+	collector.AbstractCollector
+	SysBlock string
+	Dmsetup  string
+	factory  *collector.ValueRingFactory
+
+	// Maps the kernel names (dm-0, dm-1, ...) to the device-mapper names
+	devices map[string]string
+}
+
+func NewDevmapperCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("dm"),
+		SysBlock:          DefaultSysBlock,
+		Dmsetup:           DefaultDmsetup,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	devices, err := col.listDevices()
+	if err != nil {
+		return nil, err
+	}
+	col.devices = devices
+	res := make([]collector.Collector, 0, len(devices)+2)
+	for kernelName, name := range devices {
+		res = append(res, &deviceCollector{
+			AbstractCollector: col.Child(name),
+			parent:            col,
+			statFile:          filepath.Join(col.SysBlock, kernelName, "stat"),
+			reads:             col.factory.NewValueRing(),
+			writes:            col.factory.NewValueRing(),
+			readBytes:         col.factory.NewValueRing(),
+			writeBytes:        col.factory.NewValueRing(),
+			readTime:          col.factory.NewValueRing(),
+			writeTime:         col.factory.NewValueRing(),
+			ioTime:            col.factory.NewValueRing(),
+		})
+	}
+	res = append(res, &thinPoolCollector{
+		AbstractCollector: col.Child("thin-pools"),
+		parent:            col,
+	}, &cacheCollector{
+		AbstractCollector: col.Child("caches"),
+		parent:            col,
+	})
+	return res, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	devices, err := col.listDevices()
+	if err != nil {
+		return err
+	}
+	if len(devices) != len(col.devices) {
+		return collector.MetricsChanged
+	}
+	for kernelName, name := range devices {
+		if col.devices[kernelName] != name {
+			return collector.MetricsChanged
+		}
+	}
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) listDevices() (map[string]string, error) {
+	names, err := filepath.Glob(filepath.Join(col.SysBlock, "dm-*", "dm", "name"))
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]string, len(names))
+	for _, file := range names {
+		name, err := ioutil.ReadFile(file)
+		if err != nil {
+			// The device has been removed in the meantime
+			continue
+		}
+		kernelName := filepath.Base(filepath.Dir(filepath.Dir(file)))
+		devices[kernelName] = strings.TrimSpace(string(name))
+	}
+	return devices, nil
+}
+
+// ===================================== device IO =====================================
+
+type deviceCollector struct {
+	collector.AbstractCollector
+	parent   *Collector
+	statFile string
+
+	reads, writes, readBytes, writeBytes *collector.ValueRing
+	readTime, writeTime, ioTime          *collector.ValueRing
+	inFlight                             float64
+}
+
+func (col *deviceCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+// Update reads the block device statistics, see Documentation/block/stat.rst in the kernel sources
+func (col *deviceCollector) Update(ctx context.Context) error {
+	data, err := ioutil.ReadFile(col.statFile)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 11 {
+		return fmt.Errorf("Unexpected format of %v: %v", col.statFile, string(data))
+	}
+	values := make([]float64, 11)
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return fmt.Errorf("Failed to parse %v: %v", col.statFile, err)
+		}
+	}
+	col.reads.Add(collector.StoredValue(values[0]))
+	col.readBytes.Add(collector.StoredValue(values[2] * sectorSize))
+	col.readTime.Add(collector.StoredValue(values[3]))
+	col.writes.Add(collector.StoredValue(values[4]))
+	col.writeBytes.Add(collector.StoredValue(values[6] * sectorSize))
+	col.writeTime.Add(collector.StoredValue(values[7]))
+	col.inFlight = values[8]
+	col.ioTime.Add(collector.StoredValue(values[9]))
+	return nil
+}
+
+func (col *deviceCollector) prefix() string {
+	return "dm/" + col.Name + "/"
+}
+
+func (col *deviceCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.prefix()
+	return collector.MetricReaderMap{
+		prefix + "read":          col.reads.GetDiff,
+		prefix + "write":         col.writes.GetDiff,
+		prefix + "readBytes":     col.readBytes.GetDiff,
+		prefix + "writeBytes":    col.writeBytes.GetDiff,
+		prefix + "ioTime":        col.ioTime.GetDiff,
+		prefix + "latency/read":  readLatency(col.readTime, col.reads),
+		prefix + "latency/write": readLatency(col.writeTime, col.writes),
+		prefix + "inFlight": func() bitflow.Value {
+			return bitflow.Value(col.inFlight)
+		},
+	}
+}
+
+func (col *deviceCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.prefix()
+	return collector.MetricMetadataMap{
+		prefix + "read":          collector.DerivedMetric(collector.UnitPerSecond, "Read operations"),
+		prefix + "write":         collector.DerivedMetric(collector.UnitPerSecond, "Write operations"),
+		prefix + "readBytes":     collector.DerivedMetric(collector.UnitBytesPerSecond, "Read throughput"),
+		prefix + "writeBytes":    collector.DerivedMetric(collector.UnitBytesPerSecond, "Write throughput"),
+		prefix + "ioTime":        collector.DerivedMetric(collector.UnitMillisPerSec, "Time spent doing IO"),
+		prefix + "latency/read":  collector.DerivedMetric(collector.UnitMillis, "Average service time of read requests"),
+		prefix + "latency/write": collector.DerivedMetric(collector.UnitMillis, "Average service time of write requests"),
+		prefix + "inFlight":      collector.GaugeMetric(collector.UnitCount, "IO requests currently in flight"),
+	}
+}
+
+// readLatency returns the average time per request in milliseconds within the time window of the value rings
+func readLatency(times *collector.ValueRing, requests *collector.ValueRing) collector.MetricReader {
+	return func() bitflow.Value {
+		numRequests := requests.GetDiff()
+		if numRequests <= 0 {
+			return 0
+		}
+		return times.GetDiff() / numRequests
+	}
+}
+
+// dmsetupStatus returns the output of 'dmsetup status' for all devices with the given target type
+func (col *Collector) dmsetupStatus(ctx context.Context, target string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, col.Dmsetup, "status", "--target", target)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v status failed: %v (%v)", col.Dmsetup, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
@@ -0,0 +1,115 @@
+package devmapper
+
+import (
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type StatusTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestStatus(t *testing.T) {
+	suite.Run(t, new(StatusTestSuite))
+}
+
+func (suite *StatusTestSuite) TestParseThinPoolStatus() {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected map[string]thinPoolUsage
+		err      bool
+	}{
+		{
+			name: "pools",
+			output: "vg0-pool0-tpool: 0 2097152 thin-pool 3 1152/4608 8/32 - rw no_discard_passdown queue_if_no_space - 1024\n" +
+				"docker-253:1-1049-pool: 0 209715200 thin-pool 12 2304/524288 1600/1600 - out_of_data_space discard_passdown error_if_no_space needs_check 1024\n",
+			expected: map[string]thinPoolUsage{
+				"vg0-pool0-tpool":        {data: 25, metadata: 25},
+				"docker-253:1-1049-pool": {data: 100, metadata: 2304.0 / 524288 * 100},
+			},
+		},
+		{
+			name:     "no devices",
+			output:   "No devices found\n",
+			expected: map[string]thinPoolUsage{},
+		},
+		{
+			name: "failed pools",
+			output: "vg0-pool0-tpool: 0 2097152 thin-pool Fail\n" +
+				"vg0-pool1-tpool: 0 2097152 thin-pool Error\n" +
+				"vg0-pool2-tpool: 0 2097152 thin-pool 0 11/4608 0/32 - rw no_discard_passdown queue_if_no_space - 1024\n",
+			expected: map[string]thinPoolUsage{
+				"vg0-pool2-tpool": {data: 0, metadata: 11.0 / 4608 * 100},
+			},
+		},
+		{
+			name:   "invalid usage",
+			output: "vg0-pool0-tpool: 0 2097152 thin-pool 3 1152 8/32 - rw no_discard_passdown queue_if_no_space - 1024\n",
+			err:    true,
+		},
+		{
+			name:     "truncated line",
+			output:   "vg0-pool0-tpool: 0 2097152 thin-pool 3 1152/4608\n",
+			expected: map[string]thinPoolUsage{},
+		},
+	} {
+		pools, err := parseThinPoolStatus([]byte(test.output))
+		if test.err {
+			suite.Error(err, test.name)
+		} else {
+			suite.NoError(err, test.name)
+			suite.Equal(test.expected, pools, test.name)
+		}
+	}
+}
+
+func (suite *StatusTestSuite) TestParseCacheStatus() {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected map[string]cacheStatus
+		err      bool
+	}{
+		{
+			name: "caches",
+			output: "vg0-data: 0 20971520 cache 8 1234/8192 128 4096/16384 1000 200 300 400 10 20 5 1 writeback 2 migration_threshold 2048 smq 0 rw -\n" +
+				"vg0-home: 0 41943040 cache 8 210/8192 128 0/16384 0 0 0 0 0 0 0 1 writethrough 2 migration_threshold 2048 smq 0 rw needs_check\n",
+			expected: map[string]cacheStatus{
+				"vg0-data": {readHits: 1000, readMisses: 200, writeHits: 300, writeMisses: 400, demotions: 10, promotions: 20,
+					dirty: 5 * 128 * sectorSize, usage: 25},
+				"vg0-home": {},
+			},
+		},
+		{
+			name:     "no devices",
+			output:   "No devices found\n",
+			expected: map[string]cacheStatus{},
+		},
+		{
+			name:     "failed cache",
+			output:   "vg0-data: 0 20971520 cache Fail\n",
+			expected: map[string]cacheStatus{},
+		},
+		{
+			name:   "invalid counter",
+			output: "vg0-data: 0 20971520 cache 8 1234/8192 128 4096/16384 1000 - 300 400 10 20 5 1 writeback 2 migration_threshold 2048 smq 0 rw -\n",
+			err:    true,
+		},
+		{
+			name:     "truncated line",
+			output:   "vg0-data: 0 20971520 cache 8 1234/8192 128 4096/16384 1000 200\n",
+			expected: map[string]cacheStatus{},
+		},
+	} {
+		caches, err := parseCacheStatus([]byte(test.output))
+		if test.err {
+			suite.Error(err, test.name)
+		} else {
+			suite.NoError(err, test.name)
+			suite.Equal(test.expected, caches, test.name)
+		}
+	}
+}
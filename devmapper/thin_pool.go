@@ -0,0 +1,143 @@
+package devmapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// thinPoolCollector reports the data and metadata usage of all thin pools in percent
+type thinPoolCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+
+	lock  sync.Mutex
+	pools map[string]thinPoolUsage
+}
+
+type thinPoolUsage struct {
+	data     float64
+	metadata float64
+}
+
+func (col *thinPoolCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	pools, err := col.readPools(ctx)
+	col.pools = pools
+	return nil, err
+}
+
+func (col *thinPoolCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *thinPoolCollector) Update(ctx context.Context) error {
+	pools, err := col.readPools(ctx)
+	if err != nil {
+		return err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	if len(pools) != len(col.pools) {
+		return collector.MetricsChanged
+	}
+	for name := range pools {
+		if _, ok := col.pools[name]; !ok {
+			return collector.MetricsChanged
+		}
+	}
+	col.pools = pools
+	return nil
+}
+
+func (col *thinPoolCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *thinPoolCollector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.pools)*2)
+	for name := range col.pools {
+		name := name
+		res["dm/"+name+"/thin-pool/data"] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.pools[name].data)
+		}
+		res["dm/"+name+"/thin-pool/metadata"] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.pools[name].metadata)
+		}
+	}
+	return res
+}
+
+func (col *thinPoolCollector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricMetadataMap, len(col.pools)*2)
+	for name := range col.pools {
+		res["dm/"+name+"/thin-pool/data"] = collector.GaugeMetric(collector.UnitPercent, "Used data blocks of the thin pool")
+		res["dm/"+name+"/thin-pool/metadata"] = collector.GaugeMetric(collector.UnitPercent, "Used metadata blocks of the thin pool")
+	}
+	return res
+}
+
+func (col *thinPoolCollector) readPools(ctx context.Context) (map[string]thinPoolUsage, error) {
+	output, err := col.parent.dmsetupStatus(ctx, "thin-pool")
+	if err != nil {
+		return nil, err
+	}
+	return parseThinPoolStatus(output)
+}
+
+// parseThinPoolStatus parses the output of 'dmsetup status --target thin-pool', see
+// Documentation/admin-guide/device-mapper/thin-provisioning.rst in the kernel sources. Every line has the format:
+// <name>: <start> <length> thin-pool <transaction id> <used metadata blocks>/<total metadata blocks> <used data blocks>/<total data blocks> ...
+func parseThinPoolStatus(output []byte) (map[string]thinPoolUsage, error) {
+	pools := make(map[string]thinPoolUsage)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 || fields[3] != "thin-pool" {
+			// Also skips the output "No devices found"
+			continue
+		}
+		if fields[4] == "Fail" || fields[4] == "Error" {
+			// The status of failed pools does not contain the usage
+			continue
+		}
+		metadata, err1 := parseUsage(fields[5])
+		data, err2 := parseUsage(fields[6])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("Failed to parse thin pool status: %v", scanner.Text())
+		}
+		pools[strings.TrimSuffix(fields[0], ":")] = thinPoolUsage{data: data, metadata: metadata}
+	}
+	return pools, scanner.Err()
+}
+
+// parseUsage parses a fraction in the format <used>/<total> and returns it in percent
+func parseUsage(str string) (float64, error) {
+	index := strings.IndexRune(str, '/')
+	if index < 0 {
+		return 0, fmt.Errorf("Invalid usage: %v", str)
+	}
+	used, err := strconv.ParseFloat(str[:index], 64)
+	if err != nil {
+		return 0, err
+	}
+	total, err := strconv.ParseFloat(str[index+1:], 64)
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	return used / total * 100, nil
+}
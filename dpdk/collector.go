@@ -0,0 +1,191 @@
+package dpdk
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultTimeout = 2 * time.Second
+
+// Collector queries the telemetry sockets of all DPDK applications found through SocketGlob. For every
+// application, the extended statistics of all ethdev ports are reported as rates under dpdk/<app>/ethdev/<port>/<xstat>,
+// and the occupancy of all rte_ring instances under dpdk/<app>/ring/<ring>/... The application name is the
+// --file-prefix of the application ("rte" by default).
+type Collector struct {
+	collector.AbstractCollector
+	SocketGlob string
+	Timeout    time.Duration
+	factory    *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewDpdkCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("dpdk"),
+		SocketGlob:        DefaultSocketGlob,
+		Timeout:           DefaultTimeout,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+type dpdkValues struct {
+	counters map[string]float64
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func (col *Collector) update(checkChange bool) error {
+	sockets, err := filepath.Glob(col.SocketGlob)
+	if err != nil {
+		return err
+	}
+	if len(sockets) == 0 {
+		return fmt.Errorf("No DPDK telemetry sockets found (%v)", col.SocketGlob)
+	}
+	values := &dpdkValues{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		metadata: make(collector.MetricMetadataMap),
+	}
+	for _, socket := range sockets {
+		if err := col.queryApp(socket, values); err != nil {
+			// The application might have been terminated, while the socket file still exists
+			log.Debugf("Failed to query DPDK telemetry socket %v: %v", socket, err)
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(values.counters) != len(col.counters) || len(values.gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(values.counters))
+	}
+	for name, value := range values.counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := values.counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range values.gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = values.gauges
+	col.metadata = values.metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *Collector) queryApp(socket string, values *dpdkValues) error {
+	client, err := dialTelemetry(socket, col.Timeout)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	prefix := "dpdk/" + metricName(appName(socket)) + "/"
+
+	ports, err := client.ethdevs()
+	if err != nil {
+		return err
+	}
+	for _, port := range ports {
+		stats, err := client.ethdevXstats(port)
+		if err != nil {
+			return err
+		}
+		portPrefix := fmt.Sprintf("%vethdev/%v/", prefix, port)
+		for stat, value := range stats {
+			values.counters[portPrefix+stat] = value
+			values.metadata[portPrefix+stat] = collector.DerivedMetric(collector.UnitPerSecond, "Rate of the ethdev extended statistic "+stat)
+		}
+	}
+
+	rings, err := client.rings()
+	if err != nil {
+		// Older DPDK versions do not support the ring commands
+		log.Debugf("Failed to list rte_rings of DPDK application %v: %v", socket, err)
+		return nil
+	}
+	for _, ring := range rings {
+		info, err := client.ringInfo(ring)
+		if err != nil {
+			return err
+		}
+		ringPrefix := prefix + "ring/" + metricName(ring) + "/"
+		values.gauges[ringPrefix+"used"] = info.UsedCount
+		values.gauges[ringPrefix+"capacity"] = info.Capacity
+		percent := 0.0
+		if info.Capacity > 0 {
+			percent = info.UsedCount / info.Capacity * 100
+		}
+		values.gauges[ringPrefix+"percent"] = percent
+		values.metadata[ringPrefix+"used"] = collector.GaugeMetric(collector.UnitCount, "Entries currently stored in the ring")
+		values.metadata[ringPrefix+"capacity"] = collector.GaugeMetric(collector.UnitCount, "Maximum number of entries in the ring")
+		values.metadata[ringPrefix+"percent"] = collector.GaugeMetric(collector.UnitPercent, "Occupancy of the ring")
+	}
+	return nil
+}
+
+func metricName(name string) string {
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
@@ -0,0 +1,129 @@
+package dpdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DefaultSocketGlob = "/var/run/dpdk/*/dpdk_telemetry.v2"
+
+	// Used if the server does not announce its maximum output length
+	defaultMaxOutputLen = 16384
+)
+
+// telemetryClient sends commands to the telemetry socket (version 2) of a DPDK application.
+// The socket uses the SOCK_SEQPACKET type, every reply is contained in a single JSON message.
+type telemetryClient struct {
+	conn   net.Conn
+	buf    []byte
+	closed bool
+}
+
+type telemetryInfo struct {
+	Version      string `json:"version"`
+	Pid          int    `json:"pid"`
+	MaxOutputLen int    `json:"max_output_len"`
+}
+
+// appName returns the name of the DPDK application, which is the --file-prefix parameter given to the
+// application and also the name of the directory containing the telemetry socket.
+func appName(socketPath string) string {
+	return filepath.Base(filepath.Dir(socketPath))
+}
+
+func dialTelemetry(socketPath string, timeout time.Duration) (*telemetryClient, error) {
+	conn, err := net.DialTimeout("unixpacket", socketPath, timeout)
+	if err != nil {
+		return nil, err
+	}
+	client := &telemetryClient{
+		conn: conn,
+		buf:  make([]byte, defaultMaxOutputLen),
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	// The server sends its info message directly after accepting the connection
+	var info telemetryInfo
+	if err := client.read(&info); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("Failed to read info message from DPDK telemetry socket %v: %v", socketPath, err)
+	}
+	if info.MaxOutputLen > len(client.buf) {
+		client.buf = make([]byte, info.MaxOutputLen)
+	}
+	return client, nil
+}
+
+func (client *telemetryClient) Close() {
+	if !client.closed {
+		client.closed = true
+		_ = client.conn.Close()
+	}
+}
+
+func (client *telemetryClient) read(result interface{}) error {
+	n, err := client.conn.Read(client.buf)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(client.buf[:n], result)
+}
+
+// command sends the given command and parameter (optional) and parses the reply into the result value.
+// Replies have the form {"<command>": <result>}.
+func (client *telemetryClient) command(command string, param string, result interface{}) error {
+	request := command
+	if param != "" {
+		request += "," + param
+	}
+	if _, err := client.conn.Write([]byte(request)); err != nil {
+		return err
+	}
+	var reply map[string]json.RawMessage
+	if err := client.read(&reply); err != nil {
+		return fmt.Errorf("Failed to parse reply to DPDK telemetry command %v: %v", request, err)
+	}
+	data, ok := reply[command]
+	if !ok || string(data) == "null" {
+		return fmt.Errorf("DPDK telemetry command %v failed or is not supported", request)
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (client *telemetryClient) ethdevs() ([]int, error) {
+	var ports []int
+	err := client.command("/ethdev/list", "", &ports)
+	return ports, err
+}
+
+func (client *telemetryClient) ethdevXstats(port int) (map[string]float64, error) {
+	var stats map[string]float64
+	err := client.command("/ethdev/xstats", fmt.Sprint(port), &stats)
+	return stats, err
+}
+
+// rings returns the names of all rte_ring instances. The /ring commands are only available since DPDK 23.11.
+func (client *telemetryClient) rings() ([]string, error) {
+	var rings []string
+	err := client.command("/ring/list", "", &rings)
+	return rings, err
+}
+
+type ringInfo struct {
+	Size      float64 `json:"size"`
+	Capacity  float64 `json:"capacity"`
+	UsedCount float64 `json:"used_count"`
+}
+
+func (client *telemetryClient) ringInfo(name string) (ringInfo, error) {
+	var info ringInfo
+	err := client.command("/ring/info", name, &info)
+	return info, err
+}
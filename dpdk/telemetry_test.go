@@ -0,0 +1,144 @@
+package dpdk
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type TelemetryTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestTelemetry(t *testing.T) {
+	suite.Run(t, new(TelemetryTestSuite))
+}
+
+// serveTelemetry simulates the telemetry socket of a DPDK application. The replies are indexed by the requests.
+func (suite *TelemetryTestSuite) serveTelemetry(socket string, replies map[string]string) net.Listener {
+	suite.NoError(os.MkdirAll(filepath.Dir(socket), 0755))
+	listener, err := net.Listen("unixpacket", socket)
+	suite.NoError(err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte(`{"version": "DPDK 23.11.0", "pid": 100, "max_output_len": 16384}`))
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					reply, ok := replies[string(buf[:n])]
+					if !ok {
+						reply = `{"` + string(buf[:n]) + `": null}`
+					}
+					_, _ = conn.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func (suite *TelemetryTestSuite) TestCollect() {
+	dir, err := ioutil.TempDir("", "dpdk")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	for _, test := range []struct {
+		name    string
+		replies map[string]string
+		gauges  map[string]float64
+		rates   []string
+	}{
+		{
+			name: "ethdev and rings",
+			replies: map[string]string{
+				"/ethdev/list":     `{"/ethdev/list": [0, 1]}`,
+				"/ethdev/xstats,0": `{"/ethdev/xstats": {"rx_good_packets": 100, "tx_good_packets": 50}}`,
+				"/ethdev/xstats,1": `{"/ethdev/xstats": {"rx_missed_errors": 3}}`,
+				"/ring/list":       `{"/ring/list": ["MP_mbuf_pool", "rx/ring 0"]}`,
+				"/ring/info,MP_mbuf_pool": `{"/ring/info": {"name": "MP_mbuf_pool", "size": 8192, "capacity": 8191, ` +
+					`"used_count": 0}}`,
+				"/ring/info,rx/ring 0": `{"/ring/info": {"size": 1024, "capacity": 1000, "used_count": 250}}`,
+			},
+			gauges: map[string]float64{
+				"dpdk/app1/ring/MP_mbuf_pool/used":     0,
+				"dpdk/app1/ring/MP_mbuf_pool/capacity": 8191,
+				"dpdk/app1/ring/MP_mbuf_pool/percent":  0,
+				"dpdk/app1/ring/rx_ring_0/used":        250,
+				"dpdk/app1/ring/rx_ring_0/capacity":    1000,
+				"dpdk/app1/ring/rx_ring_0/percent":     25,
+			},
+			rates: []string{
+				"dpdk/app1/ethdev/0/rx_good_packets",
+				"dpdk/app1/ethdev/0/tx_good_packets",
+				"dpdk/app1/ethdev/1/rx_missed_errors",
+			},
+		},
+		{
+			name: "no ring commands",
+			replies: map[string]string{
+				"/ethdev/list":     `{"/ethdev/list": [0]}`,
+				"/ethdev/xstats,0": `{"/ethdev/xstats": {"rx_good_packets": 100}}`,
+			},
+			gauges: map[string]float64{},
+			rates:  []string{"dpdk/app1/ethdev/0/rx_good_packets"},
+		},
+		{
+			name: "failed application",
+			replies: map[string]string{
+				"/ethdev/list": `{"/ethdev/list": [0]}`,
+			},
+			gauges: map[string]float64{},
+		},
+	} {
+		socket := filepath.Join(dir, "app1", "dpdk_telemetry.v2")
+		listener := suite.serveTelemetry(socket, test.replies)
+		col := NewDpdkCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+		col.SocketGlob = filepath.Join(dir, "*", "dpdk_telemetry.v2")
+		_, err := col.Init(context.Background())
+		suite.NoError(err, test.name)
+
+		metrics := col.Metrics()
+		suite.Len(metrics, len(test.gauges)+len(test.rates), test.name)
+		for name, value := range test.gauges {
+			suite.Contains(metrics, name, test.name)
+			suite.InDelta(value, float64(metrics[name]()), 0.0001, "%v: %v", test.name, name)
+		}
+		for _, name := range test.rates {
+			suite.Contains(metrics, name, test.name)
+			suite.Equal(collector.Derived, col.MetricsMetadata()[name].Type, "%v: %v", test.name, name)
+		}
+		suite.NoError(col.Update(context.Background()), test.name)
+		_ = listener.Close()
+	}
+}
+
+func (suite *TelemetryTestSuite) TestNoSockets() {
+	col := NewDpdkCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.SocketGlob = "/nonexistent/*/dpdk_telemetry.v2"
+	_, err := col.Init(context.Background())
+	suite.Error(err)
+}
+
+func (suite *TelemetryTestSuite) TestAppName() {
+	suite.Equal("rte", appName("/var/run/dpdk/rte/dpdk_telemetry.v2"))
+	suite.Equal("vpp", appName("/run/dpdk/vpp/dpdk_telemetry.v2"))
+}
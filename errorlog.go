@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Repetitions of an error reported through ReportError() are not logged individually, but summarized
+	// with their count at most once per ErrorSummaryInterval.
+	ErrorSummaryInterval = time.Minute
+
+	// Number of distinct errors kept for RecentErrors(). When the limit is exceeded, the error that
+	// occurred least recently is dropped.
+	RecentErrorsLimit = 100
+
+	reportedErrors = errorLog{records: make(map[errorKey]*ErrorRecord)}
+)
+
+// ErrorRecord summarizes the occurrences of one distinct error of a collector or metric reader
+type ErrorRecord struct {
+	Source string    `json:"source"`
+	Error  string    `json:"error"`
+	Count  int       `json:"count"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+
+	lastLogged time.Time
+	suppressed int
+}
+
+type errorKey struct {
+	source  string
+	message string
+}
+
+type errorLog struct {
+	lock    sync.Mutex
+	records map[errorKey]*ErrorRecord
+}
+
+// ReportError logs an error of the given source (e.g. the name of a collector). Errors are deduplicated by their
+// source and message: the first occurrence is logged immediately, repetitions are summarized with their count
+// at most once per ErrorSummaryInterval. All errors are available through RecentErrors().
+func ReportError(source string, err error) {
+	reportedErrors.report(source, err.Error(), true)
+}
+
+// RecordError stores an error for RecentErrors() without logging it. This is intended for errors that are expected
+// in normal operation and only logged in verbose mode, like processes disappearing while being observed.
+func RecordError(source string, err error) {
+	reportedErrors.report(source, err.Error(), false)
+}
+
+// RecentErrors returns the recently reported errors, starting with the most recent one.
+func RecentErrors() []ErrorRecord {
+	return reportedErrors.recent()
+}
+
+func (l *errorLog) report(source string, message string, doLog bool) {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := errorKey{source: source, message: message}
+	record, ok := l.records[key]
+	if !ok {
+		record = &ErrorRecord{Source: source, Error: message, First: now, Last: now}
+		l.records[key] = record
+		l.evict()
+	}
+	record.Count++
+	record.Last = now
+	if !doLog {
+		return
+	}
+	entry := log.WithField("source", source)
+	if record.lastLogged.IsZero() {
+		entry.Warnln(message)
+		record.lastLogged = now
+		return
+	}
+	record.suppressed++
+	if elapsed := now.Sub(record.lastLogged); elapsed >= ErrorSummaryInterval {
+		entry.Warnf("%v (occurred %v time(s) in the last %v)", message, record.suppressed, elapsed.Round(time.Second))
+		record.lastLogged = now
+		record.suppressed = 0
+	}
+}
+
+func (l *errorLog) evict() {
+	for len(l.records) > RecentErrorsLimit {
+		var oldest errorKey
+		var oldestTime time.Time
+		for key, record := range l.records {
+			if oldestTime.IsZero() || record.Last.Before(oldestTime) {
+				oldest, oldestTime = key, record.Last
+			}
+		}
+		delete(l.records, oldest)
+	}
+}
+
+func (l *errorLog) recent() []ErrorRecord {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	res := make([]ErrorRecord, 0, len(l.records))
+	for _, record := range l.records {
+		res = append(res, *record)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Last.After(res[j].Last)
+	})
+	return res
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorLogTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestErrorLog(t *testing.T) {
+	suite.Run(t, new(ErrorLogTestSuite))
+}
+
+func (suite *ErrorLogTestSuite) TestDeduplication() {
+	oldInterval := ErrorSummaryInterval
+	ErrorSummaryInterval = 20 * time.Millisecond
+	defer func() {
+		ErrorSummaryInterval = oldInterval
+	}()
+	l := errorLog{records: make(map[errorKey]*ErrorRecord)}
+	l.report("a", "failed", true)
+	l.report("a", "failed", true)
+	l.report("a", "failed", true)
+	l.report("b", "failed", false)
+	records := l.recent()
+	suite.Len(records, 2)
+	suite.Equal("b", records[0].Source)
+	suite.Equal(1, records[0].Count)
+	suite.True(records[0].lastLogged.IsZero())
+	suite.Equal("a", records[1].Source)
+	suite.Equal(3, records[1].Count)
+	suite.Equal(2, records[1].suppressed)
+
+	// After the summary interval, the suppressed repetitions are logged and reset
+	time.Sleep(25 * time.Millisecond)
+	l.report("a", "failed", true)
+	records = l.recent()
+	suite.Equal("a", records[0].Source)
+	suite.Equal(4, records[0].Count)
+	suite.Equal(0, records[0].suppressed)
+}
+
+func (suite *ErrorLogTestSuite) TestEviction() {
+	oldLimit := RecentErrorsLimit
+	RecentErrorsLimit = 2
+	defer func() {
+		RecentErrorsLimit = oldLimit
+	}()
+	l := errorLog{records: make(map[errorKey]*ErrorRecord)}
+	for _, message := range []string{"a", "b", "c"} {
+		l.report("source", message, false)
+		time.Sleep(time.Millisecond)
+	}
+	records := l.recent()
+	suite.Len(records, 2)
+	suite.Equal("c", records[0].Error)
+	suite.Equal("b", records[1].Error)
+}
@@ -0,0 +1,200 @@
+package fchost
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	DefaultFcHostDir = "/sys/class/fc_host"
+
+	// The statistics count transmission words, which have 4 bytes
+	wordSize = 4
+)
+
+// Collector reports the port statistics of all Fibre Channel host bus adapters, read from /sys/class/fc_host/host*.
+// Metrics are named fc/<host>/...
+type Collector struct {
+	collector.AbstractCollector
+	FcHostDir string
+	factory   *collector.ValueRingFactory
+
+	hosts []string
+}
+
+func NewFcHostCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("fc"),
+		FcHostDir:         DefaultFcHostDir,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	hosts, err := col.listHosts()
+	if err != nil {
+		return nil, err
+	}
+	col.hosts = hosts
+	res := make([]collector.Collector, len(hosts))
+	for i, host := range hosts {
+		res[i] = &hostCollector{
+			AbstractCollector: col.Child(host),
+			parent:            col,
+			dir:               filepath.Join(col.FcHostDir, host),
+			txFrames:          col.factory.NewValueRing(),
+			rxFrames:          col.factory.NewValueRing(),
+			txBytes:           col.factory.NewValueRing(),
+			rxBytes:           col.factory.NewValueRing(),
+			linkFailures:      col.factory.NewValueRing(),
+			lossOfSync:        col.factory.NewValueRing(),
+			lossOfSignal:      col.factory.NewValueRing(),
+			invalidCrc:        col.factory.NewValueRing(),
+			invalidTxWords:    col.factory.NewValueRing(),
+			errorFrames:       col.factory.NewValueRing(),
+			dumpedFrames:      col.factory.NewValueRing(),
+		}
+	}
+	return res, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	hosts, err := col.listHosts()
+	if err != nil {
+		return err
+	}
+	if strings.Join(hosts, ",") != strings.Join(col.hosts, ",") {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) listHosts() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(col.FcHostDir, "host*", "statistics"))
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, len(dirs))
+	for i, dir := range dirs {
+		hosts[i] = filepath.Base(filepath.Dir(dir))
+	}
+	return hosts, nil
+}
+
+type hostCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+	dir    string
+
+	txFrames, rxFrames, txBytes, rxBytes                  *collector.ValueRing
+	linkFailures, lossOfSync, lossOfSignal                *collector.ValueRing
+	invalidCrc, invalidTxWords, errorFrames, dumpedFrames *collector.ValueRing
+	online                                                float64
+}
+
+func (col *hostCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *hostCollector) Update(ctx context.Context) error {
+	files := []string{"tx_frames", "rx_frames", "tx_words", "rx_words", "link_failure_count", "loss_of_sync_count",
+		"loss_of_signal_count", "invalid_crc_count", "invalid_tx_word_count", "error_frames", "dumped_frames"}
+	values := make([]float64, len(files))
+	for i, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(col.dir, "statistics", file))
+		if err != nil {
+			return err
+		}
+		if values[i], err = parseCounter(strings.TrimSpace(string(data))); err != nil {
+			return fmt.Errorf("Failed to parse %v of %v: %v", file, col.Name, err)
+		}
+	}
+	state, err := ioutil.ReadFile(filepath.Join(col.dir, "port_state"))
+	if err != nil {
+		return err
+	}
+
+	col.txFrames.Add(collector.StoredValue(values[0]))
+	col.rxFrames.Add(collector.StoredValue(values[1]))
+	col.txBytes.Add(collector.StoredValue(values[2] * wordSize))
+	col.rxBytes.Add(collector.StoredValue(values[3] * wordSize))
+	col.linkFailures.Add(collector.StoredValue(values[4]))
+	col.lossOfSync.Add(collector.StoredValue(values[5]))
+	col.lossOfSignal.Add(collector.StoredValue(values[6]))
+	col.invalidCrc.Add(collector.StoredValue(values[7]))
+	col.invalidTxWords.Add(collector.StoredValue(values[8]))
+	col.errorFrames.Add(collector.StoredValue(values[9]))
+	col.dumpedFrames.Add(collector.StoredValue(values[10]))
+	if strings.TrimSpace(string(state)) == "Online" {
+		col.online = 1
+	} else {
+		col.online = 0
+	}
+	return nil
+}
+
+func (col *hostCollector) prefix() string {
+	return "fc/" + col.Name + "/"
+}
+
+func (col *hostCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.prefix()
+	return collector.MetricReaderMap{
+		prefix + "frames/tx":              col.txFrames.GetDiff,
+		prefix + "frames/rx":              col.rxFrames.GetDiff,
+		prefix + "bytes/tx":               col.txBytes.GetDiff,
+		prefix + "bytes/rx":               col.rxBytes.GetDiff,
+		prefix + "errors/link-failure":    col.linkFailures.GetDiff,
+		prefix + "errors/loss-of-sync":    col.lossOfSync.GetDiff,
+		prefix + "errors/loss-of-signal":  col.lossOfSignal.GetDiff,
+		prefix + "errors/invalid-crc":     col.invalidCrc.GetDiff,
+		prefix + "errors/invalid-tx-word": col.invalidTxWords.GetDiff,
+		prefix + "errors/frames":          col.errorFrames.GetDiff,
+		prefix + "errors/dumped":          col.dumpedFrames.GetDiff,
+		prefix + "online": func() bitflow.Value {
+			return bitflow.Value(col.online)
+		},
+	}
+}
+
+func (col *hostCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.prefix()
+	return collector.MetricMetadataMap{
+		prefix + "frames/tx":              collector.DerivedMetric(collector.UnitPerSecond, "Transmitted frames"),
+		prefix + "frames/rx":              collector.DerivedMetric(collector.UnitPerSecond, "Received frames"),
+		prefix + "bytes/tx":               collector.DerivedMetric(collector.UnitBytesPerSecond, "Transmit throughput"),
+		prefix + "bytes/rx":               collector.DerivedMetric(collector.UnitBytesPerSecond, "Receive throughput"),
+		prefix + "errors/link-failure":    collector.DerivedMetric(collector.UnitPerSecond, "Link failures"),
+		prefix + "errors/loss-of-sync":    collector.DerivedMetric(collector.UnitPerSecond, "Losses of synchronization"),
+		prefix + "errors/loss-of-signal":  collector.DerivedMetric(collector.UnitPerSecond, "Losses of signal"),
+		prefix + "errors/invalid-crc":     collector.DerivedMetric(collector.UnitPerSecond, "Frames received with an invalid CRC"),
+		prefix + "errors/invalid-tx-word": collector.DerivedMetric(collector.UnitPerSecond, "Invalid transmission words received"),
+		prefix + "errors/frames":          collector.DerivedMetric(collector.UnitPerSecond, "Frames received with errors"),
+		prefix + "errors/dumped":          collector.DerivedMetric(collector.UnitPerSecond, "Frames dropped because of missing resources"),
+		prefix + "online":                 collector.GaugeMetric(collector.UnitNone, "1 if the port is online, 0 otherwise"),
+	}
+}
+
+// parseCounter parses the hexadecimal statistics counters, e.g. 0x1a. Unsupported counters are reported as
+// 0xffffffffffffffff, they are treated as 0.
+func parseCounter(str string) (float64, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(str, "0x"), 16, 64)
+	if err != nil {
+		return 0, err
+	}
+	if value == ^uint64(0) {
+		return 0, nil
+	}
+	return float64(value), nil
+}
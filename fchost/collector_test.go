@@ -0,0 +1,145 @@
+package fchost
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type FcHostTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestFcHost(t *testing.T) {
+	suite.Run(t, new(FcHostTestSuite))
+}
+
+var statisticsFiles = []string{"tx_frames", "rx_frames", "tx_words", "rx_words", "link_failure_count", "loss_of_sync_count",
+	"loss_of_signal_count", "invalid_crc_count", "invalid_tx_word_count", "error_frames", "dumped_frames"}
+
+// writeHost creates the sysfs files of a Fibre Channel host, all statistics counters have the given value
+func (suite *FcHostTestSuite) writeHost(fcHostDir string, host string, state string, counter string) {
+	dir := filepath.Join(fcHostDir, host, "statistics")
+	suite.NoError(os.MkdirAll(dir, 0755))
+	for _, file := range statisticsFiles {
+		suite.NoError(ioutil.WriteFile(filepath.Join(dir, file), []byte(counter+"\n"), 0644))
+	}
+	suite.NoError(ioutil.WriteFile(filepath.Join(fcHostDir, host, "port_state"), []byte(state+"\n"), 0644))
+}
+
+func (suite *FcHostTestSuite) TestParseCounter() {
+	for _, test := range []struct {
+		input    string
+		expected float64
+		err      bool
+	}{
+		{input: "0x0", expected: 0},
+		{input: "0x1a", expected: 26},
+		{input: "0x10000", expected: 65536},
+		{input: "0xffffffffffffffff", expected: 0},
+		{input: "ff", expected: 255},
+		{input: "", err: true},
+		{input: "0xzz", err: true},
+	} {
+		value, err := parseCounter(test.input)
+		if test.err {
+			suite.Error(err, test.input)
+		} else {
+			suite.NoError(err, test.input)
+			suite.Equal(test.expected, value, test.input)
+		}
+	}
+}
+
+func (suite *FcHostTestSuite) TestCollect() {
+	for _, test := range []struct {
+		name    string
+		hosts   map[string]string
+		counter string
+		online  map[string]float64
+		err     bool
+	}{
+		{
+			name:    "hosts",
+			hosts:   map[string]string{"host1": "Online", "host2": "Linkdown"},
+			counter: "0x10",
+			online: map[string]float64{
+				"fc/host1/online": 1,
+				"fc/host2/online": 0,
+			},
+		},
+		{
+			name:   "no hosts",
+			online: map[string]float64{},
+		},
+		{
+			name:    "invalid counter",
+			hosts:   map[string]string{"host1": "Online"},
+			counter: "invalid",
+			err:     true,
+		},
+	} {
+		func() {
+			dir, err := ioutil.TempDir("", "fchost")
+			suite.NoError(err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			for host, state := range test.hosts {
+				suite.writeHost(dir, host, state, test.counter)
+			}
+			col := NewFcHostCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+			col.FcHostDir = dir
+
+			children, err := col.Init(context.Background())
+			suite.NoError(err, test.name)
+			suite.Len(children, len(test.hosts), test.name)
+			suite.NoError(col.Update(context.Background()), test.name)
+			values := make(map[string]float64)
+			for _, child := range children {
+				err := child.Update(context.Background())
+				if test.err {
+					suite.Error(err, test.name)
+					return
+				}
+				suite.NoError(err, test.name)
+				for name, reader := range child.Metrics() {
+					values[name] = float64(reader())
+				}
+				host := child.(*hostCollector)
+				suite.Equal(collector.StoredValue(16*wordSize), host.txBytes.GetHead(), test.name)
+				suite.Equal(collector.StoredValue(16), host.txFrames.GetHead(), test.name)
+			}
+			suite.Len(values, 12*len(test.hosts), test.name)
+			for name, expected := range test.online {
+				suite.Equal(expected, values[name], test.name+": "+name)
+			}
+		}()
+	}
+}
+
+func (suite *FcHostTestSuite) TestHostsChanged() {
+	dir, err := ioutil.TempDir("", "fchost")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	suite.writeHost(dir, "host1", "Online", "0x0")
+	col := NewFcHostCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.FcHostDir = dir
+
+	children, err := col.Init(context.Background())
+	suite.NoError(err)
+	suite.Len(children, 1)
+	suite.NoError(col.MetricsChanged(context.Background()))
+
+	suite.NoError(os.RemoveAll(filepath.Join(dir, "host1")))
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+}
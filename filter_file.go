@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	log "github.com/sirupsen/logrus"
+)
+
+// metricFilterFile holds the regexes read from SampleSource.FilterFile
+type metricFilterFile struct {
+	lock    sync.Mutex
+	content []byte
+	invalid []byte
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// parseMetricFilters parses the contents of a filter file. Every line contains the keyword include or exclude,
+// followed by a regex. Empty lines and lines starting with # are ignored.
+func parseMetricFilters(data []byte) (include []*regexp.Regexp, exclude []*regexp.Regexp, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[1]) == "" {
+			return nil, nil, fmt.Errorf("Line %v: expected format 'include <regex>' or 'exclude <regex>'", lineNum)
+		}
+		regex, err := regexp.Compile(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("Line %v: %v", lineNum, err)
+		}
+		switch fields[0] {
+		case "include":
+			include = append(include, regex)
+		case "exclude":
+			exclude = append(exclude, regex)
+		default:
+			return nil, nil, fmt.Errorf("Line %v: unknown keyword '%v', expected include or exclude", lineNum, fields[0])
+		}
+	}
+	return include, exclude, scanner.Err()
+}
+
+// load reads and parses the filter file. If the file cannot be read or parsed, but was loaded successfully before,
+// the previous filters are kept.
+func (f *metricFilterFile) load(path string) ([]*regexp.Regexp, []*regexp.Regexp, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	data, err := ioutil.ReadFile(path)
+	var include, exclude []*regexp.Regexp
+	if err == nil {
+		include, exclude, err = parseMetricFilters(data)
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed to load metric filter file %v: %v", path, err)
+		if f.content == nil {
+			return nil, nil, err
+		}
+		log.Warnf("%v, keeping the previous filters", err)
+	} else {
+		f.content, f.invalid = data, nil
+		f.include, f.exclude = include, exclude
+	}
+	return f.include, f.exclude, nil
+}
+
+// changed returns true, if the filter file contains different, valid filters than when it was loaded the last time
+func (f *metricFilterFile) changed(path string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Debugf("Failed to read metric filter file %v: %v", path, err)
+		return false
+	}
+	if bytes.Equal(data, f.content) || bytes.Equal(data, f.invalid) {
+		return false
+	}
+	if _, _, err := parseMetricFilters(data); err != nil {
+		// Warn only once about every invalid version of the file
+		log.Warnf("Ignoring invalid metric filter file %v: %v", path, err)
+		f.invalid = data
+		return false
+	}
+	return true
+}
+
+// metricFilters returns the configured exclude and include regexes, extended by the contents of the FilterFile
+func (source *SampleSource) metricFilters() ([]*regexp.Regexp, []*regexp.Regexp, error) {
+	if source.FilterFile == "" {
+		return source.ExcludeMetrics, source.IncludeMetrics, nil
+	}
+	include, exclude, err := source.filterFile.load(source.FilterFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	allExclude := append(append([]*regexp.Regexp{}, source.ExcludeMetrics...), exclude...)
+	allInclude := append(append([]*regexp.Regexp{}, source.IncludeMetrics...), include...)
+	return allExclude, allInclude, nil
+}
+
+func (source *SampleSource) watchFilterFile(wg *sync.WaitGroup, stopper golib.StopChan) {
+	if source.FilterFile == "" {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checkTime := time.Now()
+		for stopper.WaitTimeoutPrecise(source.FilterFileCheckInterval, timeoutLoopFactor, &checkTime) {
+			if source.filterFile.changed(source.FilterFile) {
+				log.Warnf("Metric filter file %v has changed! Restarting metric collection.", source.FilterFile)
+				stopper.Stop()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterFileTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestFilterFile(t *testing.T) {
+	suite.Run(t, new(FilterFileTestSuite))
+}
+
+func (suite *FilterFileTestSuite) TestParseMetricFilters() {
+	include, exclude, err := parseMetricFilters([]byte("# Comment\n\ninclude ^cpu$\n  exclude ^disk-io/ \ninclude ^mem/.*\n"))
+	suite.NoError(err)
+	suite.Len(include, 2)
+	suite.Equal("^cpu$", include[0].String())
+	suite.Equal("^mem/.*", include[1].String())
+	suite.Len(exclude, 1)
+	suite.Equal("^disk-io/", exclude[0].String())
+
+	for _, invalid := range []string{"include", "exclude (", "drop ^cpu$", "^cpu$"} {
+		_, _, err := parseMetricFilters([]byte(invalid))
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *FilterFileTestSuite) TestReload() {
+	dir, err := ioutil.TempDir("", "filter-file")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "filters")
+	var f metricFilterFile
+
+	// The file must be readable initially
+	_, _, err = f.load(path)
+	suite.Error(err)
+
+	suite.NoError(ioutil.WriteFile(path, []byte("exclude ^cpu$\n"), 0644))
+	_, exclude, err := f.load(path)
+	suite.NoError(err)
+	suite.Len(exclude, 1)
+	suite.False(f.changed(path))
+
+	// Invalid changes are ignored, the previous filters are kept
+	suite.NoError(ioutil.WriteFile(path, []byte("exclude (\n"), 0644))
+	suite.False(f.changed(path))
+	_, exclude, err = f.load(path)
+	suite.NoError(err)
+	suite.Len(exclude, 1)
+
+	suite.NoError(ioutil.WriteFile(path, []byte("exclude ^cpu$\nexclude ^mem$\n"), 0644))
+	suite.True(f.changed(path))
+	_, exclude, err = f.load(path)
+	suite.NoError(err)
+	suite.Len(exclude, 2)
+	suite.False(f.changed(path))
+}
+
+func (suite *SchedulerTestSuite) TestSelectMetrics() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	suite.newCollector(l, "a", root)
+	suite.newCollector(l, "b", root)
+	source := &SampleSource{
+		RootCollectors: []Collector{root},
+		ExcludeMetrics: []*regexp.Regexp{regexp.MustCompile("^root$")},
+		SelectMetrics:  []*regexp.Regexp{regexp.MustCompile("^root/a$"), regexp.MustCompile("^root$")},
+	}
+	graph, err := source.createFilteredGraph(context.Background())
+	suite.NoError(err)
+	fields, _ := graph.getMetrics().ConstructSample(source, 0)
+	suite.Equal([]string{"root/a"}, fields)
+}
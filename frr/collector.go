@@ -0,0 +1,160 @@
+package frr
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const DefaultVtysh = "vtysh"
+
+// Collector queries the BGP daemon of the FRR routing suite through vtysh. Metrics are reported per VRF and address
+// family as frr/bgp/<vrf>/<family>/..., per-peer metrics as frr/bgp/<vrf>/<family>/peer/<peer>/...
+// The route churn is the rate of changes of the BGP table version, which is incremented for every route update.
+type Collector struct {
+	collector.AbstractCollector
+	Vtysh   string
+	factory *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewFrrCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("frr"),
+		Vtysh:             DefaultVtysh,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+type frrValues struct {
+	counters map[string]float64
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func (v *frrValues) counter(name string, value float64, description string) {
+	v.counters[name] = value
+	v.metadata[name] = collector.DerivedMetric(collector.UnitPerSecond, description)
+}
+
+func (v *frrValues) gauge(name string, value float64, unit string, description string) {
+	v.gauges[name] += value
+	v.metadata[name] = collector.GaugeMetric(unit, description)
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	summary, err := col.bgpSummary(ctx)
+	if err != nil {
+		return err
+	}
+	values := &frrValues{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		metadata: make(collector.MetricMetadataMap),
+	}
+	for vrf, families := range summary {
+		for family, af := range families {
+			prefix := "frr/bgp/" + metricName(vrf) + "/" + family + "/"
+			values.counter(prefix+"churn", af.TableVersion, "Route updates in the BGP table")
+			values.gauge(prefix+"routes", af.RibCount, collector.UnitCount, "Routes in the BGP RIB")
+			values.gauge(prefix+"peers", float64(len(af.Peers)), collector.UnitCount, "Configured BGP peers")
+			values.gauge(prefix+"peers/established", 0, collector.UnitCount, "BGP sessions in the Established state")
+			for peerName, peer := range af.Peers {
+				established := 0.0
+				if peer.State == "Established" {
+					established = 1
+				}
+				values.gauge(prefix+"peers/established", established, collector.UnitCount, "BGP sessions in the Established state")
+
+				peerPrefix := prefix + "peer/" + metricName(peerName) + "/"
+				values.gauge(peerPrefix+"established", established, collector.UnitNone, "1 if the BGP session is in the Established state, 0 otherwise")
+				values.gauge(peerPrefix+"uptime", peer.PeerUptimeMsec/1000, collector.UnitSeconds, "Time since the BGP session was established")
+				values.gauge(peerPrefix+"prefixes/received", peer.PfxRcd, collector.UnitCount, "Prefixes accepted from the peer")
+				values.gauge(peerPrefix+"prefixes/sent", peer.PfxSnt, collector.UnitCount, "Prefixes advertised to the peer")
+				values.counter(peerPrefix+"messages/received", peer.MsgRcvd, "BGP messages received from the peer")
+				values.counter(peerPrefix+"messages/sent", peer.MsgSent, "BGP messages sent to the peer")
+				values.counter(peerPrefix+"drops", peer.ConnectionsDropped, "Dropped BGP sessions")
+			}
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(values.counters) != len(col.counters) || len(values.gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(values.counters))
+	}
+	for name, value := range values.counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := values.counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range values.gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = values.gauges
+	col.metadata = values.metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func metricName(name string) string {
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
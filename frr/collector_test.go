@@ -0,0 +1,133 @@
+package frr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type FrrTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestFrr(t *testing.T) {
+	suite.Run(t, new(FrrTestSuite))
+}
+
+const testSummary = `{
+  "default": {
+    "ipv4Unicast": {
+      "routerId": "10.0.0.1", "as": 65000, "tableVersion": 42, "ribCount": 10,
+      "peers": {
+        "10.0.0.2": {"remoteAs": 65001, "msgRcvd": 100, "msgSent": 90, "peerUptimeMsec": 5000, "pfxRcd": 8, "pfxSnt": 2,
+          "state": "Established", "connectionsEstablished": 2, "connectionsDropped": 1},
+        "10.0.0.3": {"remoteAs": 65002, "state": "Active", "peerUptimeMsec": 0}
+      }
+    },
+    "ipv6Unicast": {}
+  },
+  "red vrf": {
+    "vrfId": 5,
+    "ipv4Unicast": {"tableVersion": 1, "ribCount": 0, "peers": {}}
+  }
+}`
+
+// newCollector returns a Collector that executes a script printing the content of a file instead of vtysh
+func (suite *FrrTestSuite) newCollector(dir string, output string) *Collector {
+	outputFile := filepath.Join(dir, "output")
+	script := filepath.Join(dir, "vtysh")
+	suite.NoError(ioutil.WriteFile(outputFile, []byte(output), 0644))
+	suite.NoError(ioutil.WriteFile(script, []byte("#!/bin/sh\ncat '"+outputFile+"'\n"), 0755))
+	col := NewFrrCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.Vtysh = script
+	return col
+}
+
+func (suite *FrrTestSuite) TestBgpSummary() {
+	dir, err := ioutil.TempDir("", "frr")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected bgpSummary
+		err      bool
+	}{
+		{
+			name:   "summary",
+			output: testSummary,
+			expected: bgpSummary{
+				"default": {
+					"ipv4Unicast": {TableVersion: 42, RibCount: 10, Peers: map[string]bgpPeer{
+						"10.0.0.2": {State: "Established", RemoteAs: 65001, MsgRcvd: 100, MsgSent: 90, PfxRcd: 8, PfxSnt: 2,
+							PeerUptimeMsec: 5000, ConnectionsEstablished: 2, ConnectionsDropped: 1},
+						"10.0.0.3": {State: "Active", RemoteAs: 65002},
+					}},
+				},
+				"red vrf": {
+					"ipv4Unicast": {TableVersion: 1, Peers: map[string]bgpPeer{}},
+				},
+			},
+		},
+		{name: "empty", output: "{}", expected: bgpSummary{}},
+		{name: "invalid", output: "% BGP instance not found", err: true},
+	} {
+		summary, err := suite.newCollector(dir, test.output).bgpSummary(context.Background())
+		if test.err {
+			suite.Error(err, test.name)
+		} else {
+			suite.NoError(err, test.name)
+			suite.Equal(test.expected, summary, test.name)
+		}
+	}
+}
+
+func (suite *FrrTestSuite) TestCollect() {
+	dir, err := ioutil.TempDir("", "frr")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	col := suite.newCollector(dir, testSummary)
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+
+	metrics := col.Metrics()
+	for name, value := range map[string]float64{
+		"frr/bgp/default/ipv4Unicast/routes":                          10,
+		"frr/bgp/default/ipv4Unicast/peers":                           2,
+		"frr/bgp/default/ipv4Unicast/peers/established":               1,
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.2/established":       1,
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.2/uptime":            5,
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.2/prefixes/received": 8,
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.2/prefixes/sent":     2,
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.3/established":       0,
+		"frr/bgp/red_vrf/ipv4Unicast/peers":                           0,
+		"frr/bgp/red_vrf/ipv4Unicast/peers/established":               0,
+	} {
+		suite.Contains(metrics, name)
+		suite.Equal(value, float64(metrics[name]()), name)
+	}
+	for _, name := range []string{
+		"frr/bgp/default/ipv4Unicast/churn",
+		"frr/bgp/red_vrf/ipv4Unicast/churn",
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.2/messages/received",
+		"frr/bgp/default/ipv4Unicast/peer/10.0.0.3/drops",
+	} {
+		suite.Contains(metrics, name)
+		suite.Equal(collector.Derived, col.MetricsMetadata()[name].Type, name)
+	}
+	// 4 metrics per address family, 7 metrics per peer
+	suite.Len(metrics, 2*4+2*7)
+	suite.NoError(col.Update(context.Background()))
+}
@@ -0,0 +1,65 @@
+package frr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// bgpSummary is the output of 'show bgp vrf all summary json', which maps VRF names to address families
+// (ipv4Unicast, ipv6Unicast, ...). Address families without any configured peer are not included.
+type bgpSummary map[string]map[string]bgpAddressFamily
+
+type bgpAddressFamily struct {
+	TableVersion float64            `json:"tableVersion"`
+	RibCount     float64            `json:"ribCount"`
+	Peers        map[string]bgpPeer `json:"peers"`
+}
+
+type bgpPeer struct {
+	State                  string  `json:"state"`
+	RemoteAs               float64 `json:"remoteAs"`
+	MsgRcvd                float64 `json:"msgRcvd"`
+	MsgSent                float64 `json:"msgSent"`
+	PfxRcd                 float64 `json:"pfxRcd"`
+	PfxSnt                 float64 `json:"pfxSnt"`
+	PeerUptimeMsec         float64 `json:"peerUptimeMsec"`
+	ConnectionsEstablished float64 `json:"connectionsEstablished"`
+	ConnectionsDropped     float64 `json:"connectionsDropped"`
+}
+
+func (col *Collector) vtysh(ctx context.Context, command string, result interface{}) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, col.Vtysh, "-c", command)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%v -c '%v' failed: %v (%v)", col.Vtysh, command, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(output, result); err != nil {
+		return fmt.Errorf("Failed to parse output of '%v': %v", command, err)
+	}
+	return nil
+}
+
+func (col *Collector) bgpSummary(ctx context.Context) (bgpSummary, error) {
+	// Some FRR versions include additional non-object fields in the per-VRF objects, which are ignored here
+	var raw map[string]map[string]json.RawMessage
+	if err := col.vtysh(ctx, "show bgp vrf all summary json", &raw); err != nil {
+		return nil, err
+	}
+	summary := make(bgpSummary, len(raw))
+	for vrf, families := range raw {
+		summary[vrf] = make(map[string]bgpAddressFamily, len(families))
+		for family, data := range families {
+			var af bgpAddressFamily
+			if err := json.Unmarshal(data, &af); err == nil && af.Peers != nil {
+				summary[vrf][family] = af
+			}
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,229 @@
+package fsevents
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// Directory is a directory observed for file system events. If Recursive is set, all subdirectories are observed
+// as well, including subdirectories created later.
+type Directory struct {
+	Name      string
+	Path      string
+	Recursive bool
+}
+
+// ParseDirectory parses a directory in the format [name=]path, optionally suffixed with /... to observe the
+// directory recursively. The name defaults to the path with slashes replaced.
+func ParseDirectory(spec string) (Directory, error) {
+	dir := Directory{Path: spec}
+	if index := strings.IndexRune(spec, '='); index >= 0 {
+		dir.Name, dir.Path = spec[:index], spec[index+1:]
+	}
+	if strings.HasSuffix(dir.Path, "/...") {
+		dir.Path = strings.TrimSuffix(dir.Path, "/...")
+		dir.Recursive = true
+	}
+	if dir.Path != "" {
+		dir.Path = filepath.Clean(dir.Path)
+	}
+	if dir.Name == "" {
+		dir.Name = strings.Replace(strings.Trim(dir.Path, "/"), "/", "_", -1)
+	}
+	if dir.Path == "" || dir.Name == "" {
+		return dir, fmt.Errorf("Invalid directory '%v', expected format: [name=]path or [name=]path/... (recursive)", spec)
+	}
+	return dir, nil
+}
+
+// Collector reports the rates of file system events in the configured directories through inotify.
+// Metrics are named fs-events/<name>/{create,modify,delete,rename}. The number of inotify watches per user
+// is limited by fs.inotify.max_user_watches, which might have to be increased for recursively observed directories.
+type Collector struct {
+	collector.AbstractCollector
+	Directories []Directory
+	factory     *collector.ValueRingFactory
+
+	watcher *fsnotify.Watcher
+}
+
+func NewFsEventsCollector(directories []Directory, factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("fs-events"),
+		Directories:       directories,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.Close()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	col.watcher = watcher
+	res := make([]collector.Collector, len(col.Directories))
+	dirs := make([]*directoryCollector, len(col.Directories))
+	for i, dir := range col.Directories {
+		dirCol := &directoryCollector{
+			AbstractCollector: col.Child(dir.Name),
+			parent:            col,
+			watcher:           watcher,
+			dir:               dir,
+			create:            col.factory.NewValueRing(),
+			modify:            col.factory.NewValueRing(),
+			remove:            col.factory.NewValueRing(),
+			rename:            col.factory.NewValueRing(),
+		}
+		if err := dirCol.watch(dir.Path); err != nil {
+			col.Close()
+			return nil, fmt.Errorf("Failed to watch directory %v: %v", dir.Path, err)
+		}
+		dirs[i] = dirCol
+		res[i] = dirCol
+	}
+	go col.handleEvents(watcher, dirs)
+	return res, nil
+}
+
+func (col *Collector) Close() {
+	if col.watcher != nil {
+		if err := col.watcher.Close(); err != nil {
+			log.Warnln("Failed to close inotify watcher:", err)
+		}
+		col.watcher = nil
+	}
+}
+
+func (col *Collector) handleEvents(watcher *fsnotify.Watcher, dirs []*directoryCollector) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			for _, dir := range dirs {
+				if dir.contains(event.Name) {
+					dir.handle(event)
+				}
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// Usually an overflow of the inotify event queue
+			log.Warnln("Error observing file system events:", err)
+		}
+	}
+}
+
+type directoryCollector struct {
+	// Accessed atomically. Must be the first fields to guarantee 64-bit alignment on 32-bit platforms.
+	numCreate uint64
+	numModify uint64
+	numRemove uint64
+	numRename uint64
+
+	collector.AbstractCollector
+	parent  *Collector
+	watcher *fsnotify.Watcher
+	dir     Directory
+
+	create *collector.ValueRing
+	modify *collector.ValueRing
+	remove *collector.ValueRing
+	rename *collector.ValueRing
+}
+
+// watch adds an inotify watch for the given directory, and all its subdirectories if the directory is observed recursively
+func (col *directoryCollector) watch(path string) error {
+	if !col.dir.Recursive {
+		return col.watcher.Add(path)
+	}
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == col.dir.Path {
+				return err
+			}
+			// The directory might have been deleted in the meantime
+			return nil
+		}
+		if info.IsDir() {
+			return col.watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+func (col *directoryCollector) contains(path string) bool {
+	if col.dir.Recursive {
+		return path == col.dir.Path || strings.HasPrefix(path, col.dir.Path+string(filepath.Separator))
+	}
+	return filepath.Dir(path) == col.dir.Path
+}
+
+func (col *directoryCollector) handle(event fsnotify.Event) {
+	if event.Op&fsnotify.Create != 0 {
+		atomic.AddUint64(&col.numCreate, 1)
+		if col.dir.Recursive {
+			if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+				if err := col.watch(event.Name); err != nil {
+					log.Warnf("Failed to watch new directory %v: %v", event.Name, err)
+				}
+			}
+		}
+	}
+	if event.Op&fsnotify.Write != 0 {
+		atomic.AddUint64(&col.numModify, 1)
+	}
+	if event.Op&fsnotify.Remove != 0 {
+		atomic.AddUint64(&col.numRemove, 1)
+	}
+	if event.Op&fsnotify.Rename != 0 {
+		atomic.AddUint64(&col.numRename, 1)
+	}
+}
+
+func (col *directoryCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *directoryCollector) Update(ctx context.Context) error {
+	col.create.Add(collector.StoredValue(atomic.LoadUint64(&col.numCreate)))
+	col.modify.Add(collector.StoredValue(atomic.LoadUint64(&col.numModify)))
+	col.remove.Add(collector.StoredValue(atomic.LoadUint64(&col.numRemove)))
+	col.rename.Add(collector.StoredValue(atomic.LoadUint64(&col.numRename)))
+	return nil
+}
+
+func (col *directoryCollector) prefix() string {
+	return "fs-events/" + col.dir.Name + "/"
+}
+
+func (col *directoryCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.prefix()
+	return collector.MetricReaderMap{
+		prefix + "create": col.create.GetDiff,
+		prefix + "modify": col.modify.GetDiff,
+		prefix + "delete": col.remove.GetDiff,
+		prefix + "rename": col.rename.GetDiff,
+	}
+}
+
+func (col *directoryCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.prefix()
+	return collector.MetricMetadataMap{
+		prefix + "create": collector.DerivedMetric(collector.UnitPerSecond, "Created files and directories"),
+		prefix + "modify": collector.DerivedMetric(collector.UnitPerSecond, "Write events on files"),
+		prefix + "delete": collector.DerivedMetric(collector.UnitPerSecond, "Deleted files and directories"),
+		prefix + "rename": collector.DerivedMetric(collector.UnitPerSecond, "Renamed or moved files and directories"),
+	}
+}
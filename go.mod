@@ -3,7 +3,8 @@ module github.com/bitflow-stream/go-bitflow-collector
 go 1.14
 
 require (
-	github.com/StackExchange/wmi v0.0.0-20190523213315-cbe66965904d // indirect
+	git.fd.io/govpp.git v0.3.5
+	github.com/StackExchange/wmi v0.0.0-20190523213315-cbe66965904d
 	github.com/antongulenko/golib v0.0.25
 	github.com/bitflow-stream/bitflow-k8s-operator/bitflow-controller v0.0.2
 	github.com/bitflow-stream/go-bitflow v0.0.58
@@ -11,19 +12,24 @@ require (
 	github.com/cenkalti/hub v1.0.1 // indirect
 	github.com/cenkalti/rpc2 v0.0.0-20180727162946-9642ea02d0aa // indirect
 	github.com/go-ole/go-ole v1.2.4 // indirect
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/gogo/protobuf v1.3.1 // indirect
 	github.com/google/gopacket v1.1.17
 	github.com/gorilla/mux v1.7.3
-	github.com/libvirt/libvirt-go v5.0.0+incompatible
+	github.com/hashicorp/mdns v1.0.3
+	github.com/libvirt/libvirt-go v7.4.0+incompatible
 	github.com/shirou/gopsutil v2.18.12+incompatible
 	github.com/shirou/w32 v0.0.0-20160930032740-bb4de0191aa4 // indirect
 	github.com/sirupsen/logrus v1.4.2
 	github.com/socketplane/libovsdb v0.0.0-20170116174820-4de3618546de
 	github.com/stretchr/testify v1.4.0
+	github.com/vmware/govmomi v0.22.2
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
+	golang.org/x/sys v0.0.0-20200116001909-b77594299b42
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
 	gonum.org/v1/gonum v0.0.0-20190911200027-40d3308efe80
 	gopkg.in/xmlpath.v1 v1.0.0-20140413065638-a146725ea6e7
+	gopkg.in/yaml.v2 v2.2.2
 	k8s.io/api v0.17.4
 	k8s.io/apimachinery v0.17.4
 	k8s.io/klog v1.0.0 // indirect
@@ -1,9 +1,11 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -19,7 +21,14 @@ type collectorGraph struct {
 	filtered   map[*collectorNode]bool
 
 	collectors       map[Collector]*collectorNode
+	disabled         map[string]bool
+	prefixes         map[Collector]string
 	modificationLock sync.Mutex
+	lastNodeID       int64
+
+	// Restarts caused by changed metrics are delayed until restartAfter, see metricsChanged()
+	restartAfter   time.Time
+	delayedRestart sync.Once
 }
 
 func newEmptyGraph() *collectorGraph {
@@ -29,14 +38,27 @@ func newEmptyGraph() *collectorGraph {
 		failed:     make(map[*collectorNode]bool),
 		filtered:   make(map[*collectorNode]bool),
 		collectors: make(map[Collector]*collectorNode),
+		disabled:   make(map[string]bool),
 	}
 }
 
-func initCollectorGraph(collectors []Collector) (*collectorGraph, error) {
+// initCollectorGraph initializes the given collectors and all their sub-collectors. Collectors with a name contained
+// in the disabled slice are not initialized, and all collectors depending on them will be removed by pruneAndRepair().
+func initCollectorGraph(ctx context.Context, collectors []Collector, disabled []string) (*collectorGraph, error) {
+	return initPrefixedCollectorGraph(ctx, collectors, disabled, nil)
+}
+
+// initPrefixedCollectorGraph is like initCollectorGraph, but additionally prefixes the names of all metrics produced by
+// the given root collectors and their sub-collectors, see SampleSource.RegisterPrefixedCollector().
+func initPrefixedCollectorGraph(ctx context.Context, collectors []Collector, disabled []string, prefixes map[Collector]string) (*collectorGraph, error) {
 	g := newEmptyGraph()
-	g.initNodes(collectors)
+	for _, name := range disabled {
+		g.disabled[name] = true
+	}
+	g.prefixes = prefixes
+	g.initNodes(ctx, collectors, "")
 	if len(g.nodes) == 0 {
-		return nil, fmt.Errorf("All %v collectors have failed", len(g.failed))
+		return nil, allCollectorsFailedError(len(g.failed))
 	}
 	if err := g.checkMissingDependencies(); err != nil {
 		return nil, err
@@ -48,33 +70,53 @@ func initCollectorGraph(collectors []Collector) (*collectorGraph, error) {
 	return g, nil
 }
 
-func (g *collectorGraph) initNodes(collectors []Collector) {
+// allCollectorsFailedError is returned by initCollectorGraph, if no collector could be initialized.
+// This can be a temporary condition, e.g. when remote data sources are not available yet.
+type allCollectorsFailedError int
+
+func (err allCollectorsFailedError) Error() string {
+	return fmt.Sprintf("All %v collectors have failed", int(err))
+}
+
+func (g *collectorGraph) initNodes(ctx context.Context, collectors []Collector, prefix string) {
 	for _, col := range collectors {
-		g.initNode(col)
+		g.initNode(ctx, col, prefix)
 	}
 }
 
-func (g *collectorGraph) initNode(col Collector) {
+// initNode initializes the given collector. Sub-collectors inherit the metric prefix of their parent,
+// unless they are registered with their own prefix.
+func (g *collectorGraph) initNode(ctx context.Context, col Collector, prefix string) {
 	if _, ok := g.collectors[col]; ok {
 		// This collector has already been added
 		return
 	}
+	if ownPrefix, ok := g.prefixes[col]; ok {
+		prefix = ownPrefix
+	}
 	node := g.newCollectorNode(col)
-	children, err := node.init()
+	node.prefix = prefix
+	if g.disabled[node.String()] || g.disabled[col.String()] {
+		// Disabled collectors are not initialized, which also avoids the initialization of the entire subtree
+		log.Debugln("Disabling collector", node)
+		g.deleteCollector(node)
+		return
+	}
+	children, err := node.init(ctx)
 	if err == nil {
-		g.initNodes(children)
+		g.initNodes(ctx, children, prefix)
 	} else {
 		g.collectorFailed(node)
-		log.Warnf("Collector %v failed: %v", node, err)
+		ReportError(node.String(), fmt.Errorf("Initialization failed: %v", err))
 	}
 }
 
 func (g *collectorGraph) newCollectorNode(collector Collector) *collectorNode {
-	__nodeID++
+	g.lastNodeID++
 	node := &collectorNode{
 		collector: collector,
 		graph:     g,
-		uniqueID:  __nodeID,
+		uniqueID:  g.lastNodeID,
 	}
 	g.insertCollectorNode(node)
 	return node
@@ -139,15 +181,13 @@ func (g *collectorGraph) applyMetricFilters(exclude []*regexp.Regexp, include []
 	}
 }
 
-func (g *collectorGraph) applyCollectorFilters(deleteNames []string) {
+// applyErrorMetrics adds a metric with the rate of failed updates to every collector, see CollectorErrorsPrefix
+func (g *collectorGraph) applyErrorMetrics(factory *ValueRingFactory) {
+	if factory == nil {
+		return
+	}
 	for node := range g.nodes {
-		for _, deleteName := range deleteNames {
-			if deleteName == node.String() {
-				log.Debugln("Disabling collector", deleteName)
-				g.deleteCollector(node)
-				break
-			}
-		}
+		node.applyErrorMetric(factory)
 	}
 }
 
@@ -155,7 +195,8 @@ func (g *collectorGraph) applyUpdateFrequencies(frequencies map[*regexp.Regexp]t
 	for regex, freq := range frequencies {
 		count := 0
 		for node := range g.nodes {
-			if regex.MatchString(node.String()) {
+			// Update frequencies apply to all instances of a collector, regardless of their metric prefix
+			if regex.MatchString(node.collector.String()) {
 				node.UpdateFrequency = freq
 				count++
 			}
@@ -164,6 +205,15 @@ func (g *collectorGraph) applyUpdateFrequencies(frequencies map[*regexp.Regexp]t
 	}
 }
 
+func (g *collectorGraph) applyStdDevMetrics(regexes []*regexp.Regexp, factory *ValueRingFactory) {
+	if len(regexes) == 0 {
+		return
+	}
+	for node := range g.nodes {
+		node.applyStdDevMetrics(regexes, factory)
+	}
+}
+
 func (g *collectorGraph) dependsOnFailedOrFiltered(node *collectorNode) bool {
 	for _, dependencyCol := range node.collector.Depends() {
 		dependency := g.resolve(dependencyCol)
@@ -184,6 +234,8 @@ func (g *collectorGraph) pruneAndRepair() {
 		if g.dependsOnFailedOrFiltered(node) {
 			log.Warnln("Deleting collector", node, "because of a failed/filtered dependency")
 			g.deleteCollector(node)
+			// The node is not updated anymore, see failureReader()
+			node.setFailing(true)
 			sorted[i] = nil
 		}
 	}
@@ -244,6 +296,16 @@ func (g *collectorGraph) listMetricNames() []string {
 	return res
 }
 
+func (g *collectorGraph) listMetricMetadata() MetricMetadataMap {
+	res := make(MetricMetadataMap)
+	for node := range g.nodes {
+		for metric := range node.metrics {
+			res[metric] = node.metadata[metric]
+		}
+	}
+	return res
+}
+
 func (g *collectorGraph) fillMetricNames(all map[string]bool) {
 	for node := range g.nodes {
 		for metric := range node.metrics {
@@ -259,8 +321,10 @@ func (g *collectorGraph) getMetrics() (res MetricSlice) {
 	for node := range g.nodes {
 		for name, reader := range node.metrics {
 			res = append(res, &Metric{
-				name:   name,
-				reader: reader,
+				name:     name,
+				reader:   reader,
+				metadata: node.metadata[name],
+				node:     node,
 			})
 		}
 	}
@@ -332,15 +396,58 @@ func makeNodeList(sets ...map[*collectorNode]bool) []graph.Node {
 	return res
 }
 
-func (g *collectorGraph) getRootsAndLeafs() (roots []*collectorNode, leafs []*collectorNode) {
-	reverse := g.reverseDependencies()
+// mostExpensiveLeaf returns the node with the highest accumulated update duration, that no other node depends on.
+// Nodes matching one of the given regexes are not considered. If only one leaf node is left, nil is returned.
+func (g *collectorGraph) mostExpensiveLeaf(exclude []*regexp.Regexp) *collectorNode {
+	return g.maxLeaf(exclude, func(node *collectorNode) int64 {
+		return atomic.LoadInt64(&node.updateCost)
+	})
+}
+
+// largestLeaf returns the node with the highest number of metrics, that no other node depends on.
+// Like mostExpensiveLeaf(), excluded nodes are not considered and nil is returned if only one leaf node is left.
+func (g *collectorGraph) largestLeaf(exclude []*regexp.Regexp) *collectorNode {
+	return g.maxLeaf(exclude, func(node *collectorNode) int64 {
+		return int64(len(node.metrics))
+	})
+}
+
+func (g *collectorGraph) maxLeaf(exclude []*regexp.Regexp, cost func(node *collectorNode) int64) *collectorNode {
+	g.modificationLock.Lock()
+	defer g.modificationLock.Unlock()
+	incoming := g.reverseDependencies()
+	var result *collectorNode
+	var maxCost int64 = -1
+	numLeafs := 0
 	for node := range g.nodes {
-		if len(node.collector.Depends()) == 0 {
-			roots = append(roots, node)
+		if len(incoming[node]) > 0 || len(node.metrics) == 0 {
+			continue
 		}
-		if len(reverse[node]) == 0 {
-			leafs = append(leafs, node)
+		numLeafs++
+		if matchesAny(node.String(), exclude) {
+			continue
+		}
+		if cost := cost(node); cost > maxCost {
+			result, maxCost = node, cost
 		}
 	}
-	return
+	if numLeafs <= 1 {
+		return nil
+	}
+	return result
+}
+
+func matchesAny(name string, regexes []*regexp.Regexp) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *collectorGraph) containsNode(node *collectorNode) bool {
+	g.modificationLock.Lock()
+	defer g.modificationLock.Unlock()
+	return g.nodes[node]
 }
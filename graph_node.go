@@ -1,42 +1,73 @@
 package collector
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	ToleratedUpdateFailures = 2
+	StdDevMetricSuffix      = "/stddev"
+	CollectorErrorsPrefix   = "collector-errors/"
 )
 
-var __nodeID = int64(0)
-
 type collectorNode struct {
+	// Accumulated duration of all updates in nanoseconds, accessed atomically.
+	// Must be the first field to ensure 64 bit alignment.
+	updateCost int64
+
 	collector Collector
 	graph     *collectorGraph
 	uniqueID  int64
+	prefix    string // Prefix of all metric names, see SampleSource.RegisterPrefixedCollector()
 
 	failedUpdates int
 	hasFailed     bool
 
-	metrics MetricReaderMap
-
-	preconditions  []*golib.BoolCondition
-	postconditions []*golib.BoolCondition
+	metrics       MetricReaderMap
+	metadata      MetricMetadataMap
+	stdDevMetrics []stdDevMetric
+	staticMetrics []*staticMetric
 
 	UpdateFrequency time.Duration
+	lastUpdate      time.Time
+
+	chaos *ChaosSpec
+	stats nodeStats
+
+	errorRing  *ValueRing
+	errorCount int
+
+	// Values of the metrics that are part of the emitted samples, read after every update, see sampleReader()
+	sampleLock    sync.Mutex
+	sampleReaders []MetricReader
+	sampleValues  []bitflow.Value
+
+	// Set while the node is waiting for its update in the current round, accessed atomically
+	roundPending int32
+	late         bool
+
+	// See SampleSource.UpdateTimeout. The flags are accessed atomically.
+	updateTimeout time.Duration
+	updateRunning int32
+	failing       int32
 }
 
 func (node *collectorNode) String() string {
-	return node.collector.String()
+	return prefixedName(node.prefix, node.collector.String())
 }
 
-func (node *collectorNode) init() ([]Collector, error) {
-	children, err := node.collector.Init()
+func (node *collectorNode) init(ctx context.Context) ([]Collector, error) {
+	children, err := node.collector.Init(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +76,14 @@ func (node *collectorNode) init() ([]Collector, error) {
 		// Implement isInitialized: make sure a successful init() leaves a non-nil metrics map.
 		node.metrics = make(MetricReaderMap)
 	}
+	if metadataCol, ok := node.collector.(MetadataCollector); ok {
+		node.metadata = metadataCol.MetricsMetadata()
+	}
+	if node.metadata == nil {
+		node.metadata = make(MetricMetadataMap)
+	}
+	node.applyPrefix()
+	node.applyStaticMetrics()
 	return children, nil
 }
 
@@ -85,68 +124,141 @@ func (node *collectorNode) getFilteredMetrics(exclude []*regexp.Regexp, include
 	return filtered
 }
 
-func (node *collectorNode) loopUpdate(wg *sync.WaitGroup, stopper golib.StopChan) {
-	for _, dependsCol := range node.collector.Depends() {
-		depends := node.graph.resolve(dependsCol)
-		cond := golib.NewBoolCondition()
-		node.preconditions = append(node.preconditions, cond)
-		depends.postconditions = append(depends.postconditions, cond)
-	}
-	var lastUpdate time.Time
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for node.updateAndBroadcast(stopper, &lastUpdate) {
-		}
-	}()
+type stdDevMetric struct {
+	reader MetricReader
+	ring   *ValueRing
 }
 
-func (node *collectorNode) updateAndBroadcast(stopper golib.StopChan, lastUpdate *time.Time) bool {
-	defer func() {
-		// Make sure we notify our post-conditions regardless of our own state
-		for _, cond := range node.postconditions {
-			cond.Broadcast()
+func (node *collectorNode) applyStdDevMetrics(regexes []*regexp.Regexp, factory *ValueRingFactory) {
+	var names []string
+	for name := range node.metrics {
+		for _, regex := range regexes {
+			if regex.MatchString(name) {
+				names = append(names, name)
+				break
+			}
 		}
-	}()
-	for _, cond := range node.preconditions {
-		cond.WaitAndUnset()
 	}
-
-	if stopper.Stopped() {
-		return false
+	for _, name := range names {
+		metric := stdDevMetric{
+			reader: node.metrics[name],
+			ring:   factory.NewValueRing(),
+		}
+		node.stdDevMetrics = append(node.stdDevMetrics, metric)
+		node.metrics[name+StdDevMetricSuffix] = metric.ring.GetStdDev
+		if metadata, ok := node.metadata[name]; ok {
+			metadata.Type = Derived
+			metadata.Description = "Standard deviation of " + name
+			node.metadata[name+StdDevMetricSuffix] = metadata
+		}
 	}
-	if !node.graph.nodes[node] {
-		// Node has been deleted due to failed dependency
-		return false
+}
+
+func (node *collectorNode) updateStdDevMetrics() {
+	// Sample the metric values after every update, so the standard deviation
+	// is based on the collect interval instead of the sink interval.
+	for _, metric := range node.stdDevMetrics {
+		metric.ring.AddValue(metric.reader())
 	}
+}
 
-	successfulUpdate := true
+// scheduledUpdate updates the collector, unless the configured UpdateFrequency prevents it.
+func (node *collectorNode) scheduledUpdate(ctx context.Context, stopper golib.StopChan) {
 	if node.UpdateFrequency > 0 {
 		now := time.Now()
-		if now.Sub(*lastUpdate) >= node.UpdateFrequency {
-			successfulUpdate = node.update(stopper)
-			*lastUpdate = now
+		if now.Sub(node.lastUpdate) < node.UpdateFrequency {
+			return
 		}
-	} else {
-		successfulUpdate = node.update(stopper)
+		node.lastUpdate = now
 	}
-	return successfulUpdate && !stopper.Stopped()
+	node.update(ctx, stopper)
 }
 
-func (node *collectorNode) update(stopper golib.StopChan) bool {
-	err := node.collector.Update()
+func (node *collectorNode) update(ctx context.Context, stopper golib.StopChan) {
+	start := time.Now()
+	err := node.runUpdate(ctx, func(ctx context.Context) error {
+		if err := node.chaos.inject(ctx); err != nil {
+			return err
+		}
+		return node.collector.Update(ctx)
+	})
+	duration := time.Since(start)
+	atomic.AddInt64(&node.updateCost, int64(duration))
+	if stopper.Stopped() {
+		// Errors caused by the canceled context are expected
+		return
+	}
+	node.stats.record(duration, err != nil && err != MetricsChanged)
+	node.recordErrors(err != nil && err != MetricsChanged)
+	node.setFailing(err != nil && err != MetricsChanged)
 	if err == MetricsChanged {
 		log.Warnln("Metrics of", node, "have changed! Restarting metric collection.")
-		stopper.Stop()
-		return false
+		node.graph.metricsChanged(stopper)
 	} else if err != nil {
-		log.Warnln("Update of", node, "failed:", err)
-		return !node.updateFailed()
+		ReportError(node.String(), fmt.Errorf("Update failed: %v", err))
+		node.updateFailed()
 	} else {
 		node.failedUpdates = 0
-		return true
+		node.updateStdDevMetrics()
+	}
+	node.updateSampleValues()
+}
+
+// sampleReader returns a reader for the value of the given metric in the latest snapshot of the node. The snapshot
+// is taken after every update, so the metrics are only read by the routine updating the collector, and not
+// concurrently by the sink routine. Must be called before the updates of the node are started.
+func (node *collectorNode) sampleReader(reader MetricReader) MetricReader {
+	index := len(node.sampleReaders)
+	node.sampleReaders = append(node.sampleReaders, reader)
+	node.sampleValues = append(node.sampleValues, reader())
+	return func() bitflow.Value {
+		node.sampleLock.Lock()
+		defer node.sampleLock.Unlock()
+		return node.sampleValues[index]
+	}
+}
+
+// updateSampleValues reads the metrics registered through sampleReader(), unless an abandoned update is still
+// modifying the collector, see runUpdate().
+func (node *collectorNode) updateSampleValues() {
+	if atomic.LoadInt32(&node.updateRunning) != 0 {
+		return
+	}
+	node.sampleLock.Lock()
+	defer node.sampleLock.Unlock()
+	for i, reader := range node.sampleReaders {
+		node.sampleValues[i] = reader()
+	}
+}
+
+// clearSampleValues reports all metrics of the node as missing (NaN) and stops updating the snapshot, e.g. because
+// the node has been removed from the update rounds without restarting the metric collection.
+func (node *collectorNode) clearSampleValues() {
+	node.sampleLock.Lock()
+	defer node.sampleLock.Unlock()
+	node.sampleReaders = nil
+	for i := range node.sampleValues {
+		node.sampleValues[i] = bitflow.Value(math.NaN())
+	}
+}
+
+func (node *collectorNode) applyErrorMetric(factory *ValueRingFactory) {
+	node.errorRing = factory.NewValueRing()
+	node.errorRing.Add(StoredValue(0))
+	name := CollectorErrorsPrefix + node.String()
+	node.metrics[name] = node.errorRing.GetDiff
+	node.metadata[name] = CounterMetric(UnitPerSecond, "Failed updates of the collector "+node.String())
+}
+
+// recordErrors updates the number of failed updates, if applyErrorMetric() has been called
+func (node *collectorNode) recordErrors(failed bool) {
+	if node.errorRing == nil {
+		return
+	}
+	if failed {
+		node.errorCount++
 	}
+	node.errorRing.Add(StoredValue(node.errorCount))
 }
 
 func (node *collectorNode) updateFailed() bool {
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// headerState keeps track of the metrics of previous headers, in order to pad missing metrics
+type headerState struct {
+	previous MetricMetadataMap
+	missing  map[string]time.Time // Time when the metric has disappeared
+}
+
+// metricsChanged restarts the metric collection after the metrics of a collector have changed. If the restart is
+// rate-limited (see SampleSource.HeaderChangeInterval), it is delayed and all further changes are batched into it.
+func (g *collectorGraph) metricsChanged(stopper golib.StopChan) {
+	delay := time.Until(g.restartAfter)
+	if delay <= 0 {
+		stopper.Stop()
+		return
+	}
+	g.delayedRestart.Do(func() {
+		log.Warnf("Delaying the restart of the metric collection by %v to limit header changes", delay.Round(time.Millisecond))
+		time.AfterFunc(delay, stopper.Stop)
+	})
+}
+
+// padMissingMetrics appends a NaN metric for every metric that was contained in a previous header, but is missing in
+// the given metrics since less than the MissingMetricGracePeriod. The returned duration is the remaining time until the
+// grace period of the first padded metric expires, or zero if no metrics are padded.
+func (source *SampleSource) padMissingMetrics(metrics MetricSlice) (MetricSlice, time.Duration) {
+	state := &source.header
+	if source.MissingMetricGracePeriod <= 0 {
+		state.previous, state.missing = nil, nil
+		return metrics, 0
+	}
+	if state.missing == nil {
+		state.missing = make(map[string]time.Time)
+	}
+	now := time.Now()
+	current := metrics.Metadata()
+	for name := range current {
+		delete(state.missing, name)
+	}
+	var expiry time.Duration
+	var padded []string
+	for name, metadata := range state.previous {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		since, ok := state.missing[name]
+		if !ok {
+			since = now
+			state.missing[name] = now
+		}
+		remaining := source.MissingMetricGracePeriod - now.Sub(since)
+		if remaining <= 0 {
+			delete(state.missing, name)
+			continue
+		}
+		if expiry == 0 || remaining < expiry {
+			expiry = remaining
+		}
+		metrics = append(metrics, &Metric{
+			name:     name,
+			reader:   missingMetricReader,
+			metadata: metadata,
+		})
+		current[name] = metadata
+		padded = append(padded, name)
+	}
+	if len(padded) > 0 {
+		log.Printf("Padding %v missing metric(s) with NaN for up to %v: %v", len(padded), source.MissingMetricGracePeriod, padded)
+	}
+	state.previous = current
+	return metrics, expiry
+}
+
+func missingMetricReader() bitflow.Value {
+	return bitflow.Value(math.NaN())
+}
+
+// watchMissingMetrics restarts the metric collection when the grace period of the first padded metric expires,
+// in order to remove it from the header.
+func (source *SampleSource) watchMissingMetrics(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph, expiry time.Duration) {
+	if expiry <= 0 {
+		return
+	}
+	if delay := time.Until(graph.restartAfter); delay > expiry {
+		expiry = delay
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if stopper.WaitTimeout(expiry) {
+			log.Println("The grace period of missing metrics has expired, restarting metric collection")
+			stopper.Stop()
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type HeaderTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestHeader(t *testing.T) {
+	suite.Run(t, new(HeaderTestSuite))
+}
+
+func testMetrics(names ...string) MetricSlice {
+	res := make(MetricSlice, len(names))
+	for i, name := range names {
+		res[i] = &Metric{
+			name: name,
+			reader: func() bitflow.Value {
+				return 1
+			},
+		}
+	}
+	return res
+}
+
+func (suite *HeaderTestSuite) TestPadMissingMetrics() {
+	source := &SampleSource{MissingMetricGracePeriod: 50 * time.Millisecond}
+	metrics, expiry := source.padMissingMetrics(testMetrics("a", "b", "c"))
+	suite.Len(metrics, 3)
+	suite.Equal(time.Duration(0), expiry)
+
+	metrics, expiry = source.padMissingMetrics(testMetrics("a"))
+	fields, getValues := metrics.ConstructSample(source, 0)
+	suite.Equal([]string{"a", "b", "c"}, fields)
+	values := getValues()
+	suite.Equal(bitflow.Value(1), values[0])
+	suite.True(math.IsNaN(float64(values[1])))
+	suite.True(math.IsNaN(float64(values[2])))
+	suite.InDelta(50*time.Millisecond, expiry, float64(5*time.Millisecond))
+
+	// b reappears, c stays missing until the grace period expires
+	time.Sleep(30 * time.Millisecond)
+	metrics, expiry = source.padMissingMetrics(testMetrics("a", "b"))
+	fields, _ = metrics.ConstructSample(source, 0)
+	suite.Equal([]string{"a", "b", "c"}, fields)
+	suite.InDelta(20*time.Millisecond, expiry, float64(5*time.Millisecond))
+
+	time.Sleep(30 * time.Millisecond)
+	metrics, expiry = source.padMissingMetrics(testMetrics("a", "b"))
+	fields, _ = metrics.ConstructSample(source, 0)
+	suite.Equal([]string{"a", "b"}, fields)
+	suite.Equal(time.Duration(0), expiry)
+
+	// Without grace period, nothing is padded
+	source.MissingMetricGracePeriod = 0
+	metrics, _ = source.padMissingMetrics(testMetrics("a"))
+	suite.Len(metrics, 1)
+}
+
+func (suite *HeaderTestSuite) TestDelayedRestart() {
+	graph := newEmptyGraph()
+	stopper := golib.NewStopChan()
+	graph.metricsChanged(stopper)
+	suite.True(stopper.Stopped())
+
+	graph = newEmptyGraph()
+	graph.restartAfter = time.Now().Add(30 * time.Millisecond)
+	stopper = golib.NewStopChan()
+	graph.metricsChanged(stopper)
+	graph.metricsChanged(stopper)
+	suite.False(stopper.Stopped())
+	time.Sleep(15 * time.Millisecond)
+	suite.False(stopper.Stopped())
+	time.Sleep(30 * time.Millisecond)
+	suite.True(stopper.Stopped())
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MinHighFrequencyInterval is the smallest supported SampleSource.HighFrequencyInterval
+	MinHighFrequencyInterval = 5 * time.Millisecond
+
+	// Before every tick of the high-frequency loop, this duration is spent busy-waiting instead of sleeping,
+	// since the wake-up accuracy of the OS scheduler is in the range of milliseconds.
+	highFrequencySpinTime = 2 * time.Millisecond
+
+	// The values of this many samples are allocated at once
+	highFrequencyBufferSamples = 1024
+
+	// Failures in the high-frequency loop are not logged individually, but summarized in this interval
+	highFrequencyReportInterval = 10 * time.Second
+)
+
+// highFrequencyLoop updates all collectors sequentially and emits a sample after every round, every
+// SampleSource.HighFrequencyInterval. Besides the collectors and metric filters, no other features of
+// the SampleSource are applied.
+type highFrequencyLoop struct {
+	source  *SampleSource
+	nodes   []*collectorNode
+	readers []MetricReader
+	header  *bitflow.Header
+
+	buffer         []bitflow.Value
+	updateFailures int
+	sinkFailures   int
+	overruns       int
+}
+
+func (source *SampleSource) startHighFrequencyLoop(ctx context.Context, wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph, metrics MetricSlice) {
+	sort.Sort(metrics)
+	loop := &highFrequencyLoop{
+		source:  source,
+		nodes:   sortGraph(graph),
+		readers: make([]MetricReader, len(metrics)),
+		header:  &bitflow.Header{Fields: make([]string, len(metrics))},
+	}
+	for i, metric := range metrics {
+		loop.header.Fields[i] = metric.name
+		loop.readers[i] = metric.reader
+	}
+	source.currentMetrics = loop.header.Fields
+	source.metricStatus.setSample(loop.header.Fields, nil)
+	log.Printf("Collecting %v metrics every %v in high-frequency mode", len(metrics), source.HighFrequencyInterval)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer loop.report()
+		loop.run(ctx, stopper)
+	}()
+}
+
+func (loop *highFrequencyLoop) run(ctx context.Context, stopper golib.StopChan) {
+	sink := loop.source.GetSink()
+	interval := loop.source.HighFrequencyInterval
+	tick := time.Now()
+	lastReport := tick
+	for {
+		for _, node := range loop.nodes {
+			if err := node.collector.Update(ctx); err == MetricsChanged {
+				if !stopper.Stopped() {
+					log.Warnln("Metrics of", node, "have changed! Restarting metric collection.")
+					stopper.Stop()
+				}
+				return
+			} else if err != nil {
+				loop.updateFailures++
+			}
+		}
+
+		sample := &bitflow.Sample{
+			Time:   time.Now(),
+			Values: loop.nextValues(),
+		}
+		for i, reader := range loop.readers {
+			sample.Values[i] = reader()
+		}
+		loop.source.metricStatus.setSample(loop.header.Fields, sample.Values)
+		if err := sink.Sample(sample, loop.header); err != nil {
+			loop.sinkFailures++
+		} else {
+			loop.source.sampleCounter.increment()
+		}
+
+		if sample.Time.Sub(lastReport) >= highFrequencyReportInterval {
+			loop.report()
+			lastReport = sample.Time
+		}
+		tick = tick.Add(interval)
+		if now := time.Now(); now.After(tick) {
+			// Skip the missed ticks instead of emitting a burst of samples
+			loop.overruns++
+			tick = now
+		}
+		if !busyWaitUntil(tick, stopper) {
+			return
+		}
+	}
+}
+
+// nextValues returns a value slice for the next sample. The slices are cut from a larger buffer to reduce the
+// number of allocations. The capacity is limited, so that appending to a slice does not overwrite the next slice.
+func (loop *highFrequencyLoop) nextValues() []bitflow.Value {
+	num := len(loop.readers)
+	if len(loop.buffer) < num {
+		loop.buffer = make([]bitflow.Value, num*highFrequencyBufferSamples)
+	}
+	values := loop.buffer[:num:num]
+	loop.buffer = loop.buffer[num:]
+	return values
+}
+
+func (loop *highFrequencyLoop) report() {
+	if loop.updateFailures > 0 || loop.sinkFailures > 0 || loop.overruns > 0 {
+		log.Warnf("High-frequency collection: %v failed updates, %v failed samples, %v overrun intervals",
+			loop.updateFailures, loop.sinkFailures, loop.overruns)
+		loop.updateFailures, loop.sinkFailures, loop.overruns = 0, 0, 0
+	}
+}
+
+// busyWaitUntil sleeps until shortly before the deadline, and then busy-waits for the deadline.
+// Returns false, if the stopper was stopped.
+func busyWaitUntil(deadline time.Time, stopper golib.StopChan) bool {
+	if sleep := time.Until(deadline) - highFrequencySpinTime; sleep > 0 {
+		if !stopper.WaitTimeout(sleep) {
+			return false
+		}
+	}
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+	return !stopper.Stopped()
+}
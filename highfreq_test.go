@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+func (suite *SchedulerTestSuite) TestHighFrequencyLoop() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	child := suite.newCollector(l, "child", root)
+	updates := 0
+	child.onUpdate = func() {
+		updates++
+	}
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+
+	var lock sync.Mutex
+	var samples []*bitflow.Sample
+	var header *bitflow.Header
+	source := &SampleSource{HighFrequencyInterval: 10 * time.Millisecond}
+	source.SetSink(&bitflow.SimpleProcessor{
+		Process: func(sample *bitflow.Sample, h *bitflow.Header) (*bitflow.Sample, *bitflow.Header, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			samples = append(samples, sample)
+			header = h
+			return nil, nil, nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	stopper := golib.NewStopChan()
+	source.startHighFrequencyLoop(context.Background(), &wg, stopper, graph, graph.getMetrics())
+	time.Sleep(105 * time.Millisecond)
+	stopper.Stop()
+	wg.Wait()
+
+	suite.Equal([]string{"root", "root/child"}, header.Fields)
+	suite.InDelta(11, len(samples), 2)
+	suite.Equal(len(samples), updates)
+	for i := 1; i < len(samples); i++ {
+		suite.Len(samples[i].Values, 2)
+		suite.Equal(2, cap(samples[i].Values))
+		suite.InDelta(10*time.Millisecond, samples[i].Time.Sub(samples[i-1].Time), float64(3*time.Millisecond))
+	}
+}
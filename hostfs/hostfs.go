@@ -0,0 +1,151 @@
+// Package hostfs locates the filesystems of the monitored host. When the collector runs inside a container, the proc
+// and sys filesystems (or the entire root filesystem) of the host can be mounted at a different location
+// (e.g. /host/proc or /host), so that the collectors deliver the metrics of the host instead of the container.
+package hostfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+const (
+	DefaultProcRoot = "/proc"
+	DefaultSysRoot  = "/sys"
+)
+
+// Filesystem locates the files of the monitored host.
+type Filesystem interface {
+	// ProcPath returns the path of the given file in the proc filesystem, or the root of the filesystem without parts
+	ProcPath(parts ...string) string
+
+	// SysPath returns the path of the given file in the sys filesystem, or the root of the filesystem without parts
+	SysPath(parts ...string) string
+
+	// HostPath maps an absolute path of the host (e.g. a mount point) to the path where it is accessible
+	HostPath(path string) string
+
+	// NamespaceProcPath returns the path of a proc file that depends on the namespaces of the reading process, like
+	// mounts or net/dev. When the root filesystem of the host is mounted in a container, the files of the init
+	// process of the host are used instead of the own process, which lives in the namespaces of the container.
+	NamespaceProcPath(parts ...string) string
+}
+
+// RootFilesystem locates the proc and sys filesystems at the given mount points. If HostRoot is set, the root
+// filesystem of the host is expected at that location, see NewHostRootFilesystem.
+type RootFilesystem struct {
+	ProcRoot string
+	SysRoot  string
+	HostRoot string
+}
+
+// NewHostRootFilesystem returns a Filesystem for the root filesystem of the host mounted at the given path, e.g. /host.
+// This requires running the collector in the PID namespace of the host.
+func NewHostRootFilesystem(root string) RootFilesystem {
+	return RootFilesystem{
+		ProcRoot: filepath.Join(root, DefaultProcRoot),
+		SysRoot:  filepath.Join(root, DefaultSysRoot),
+		HostRoot: root,
+	}
+}
+
+func (fs RootFilesystem) ProcPath(parts ...string) string {
+	return filepath.Join(append([]string{fs.ProcRoot}, parts...)...)
+}
+
+func (fs RootFilesystem) SysPath(parts ...string) string {
+	return filepath.Join(append([]string{fs.SysRoot}, parts...)...)
+}
+
+func (fs RootFilesystem) HostPath(path string) string {
+	if fs.HostRoot == "" {
+		return path
+	}
+	return filepath.Join(fs.HostRoot, path)
+}
+
+func (fs RootFilesystem) NamespaceProcPath(parts ...string) string {
+	process := "self"
+	if fs.HostRoot != "" {
+		process = "1"
+	}
+	return fs.ProcPath(append([]string{process}, parts...)...)
+}
+
+var current Filesystem = RootFilesystem{ProcRoot: DefaultProcRoot, SysRoot: DefaultSysRoot}
+
+// Current returns the Filesystem configured through Set()
+func Current() Filesystem {
+	return current
+}
+
+// Set changes the location of the host filesystems for all collectors. The gopsutil library is configured through
+// the HOST_PROC, HOST_SYS and HOST_ETC environment variables. Must be called before creating collectors.
+func Set(fs Filesystem) error {
+	for env, root := range map[string]string{"HOST_PROC": fs.ProcPath(), "HOST_SYS": fs.SysPath(), "HOST_ETC": fs.HostPath("/etc")} {
+		if err := os.Setenv(env, root); err != nil {
+			return fmt.Errorf("Failed to set %v=%v: %v", env, root, err)
+		}
+	}
+	current = fs
+	return nil
+}
+
+// Partitions returns the mounted physical partitions of the host, like disk.Partitions(false). In contrast to
+// disk.Partitions(), the mounts are read from Filesystem.NamespaceProcPath(), so that the partitions of the host
+// are returned when running inside a container. The mount points are paths of the host, see Filesystem.HostPath().
+func Partitions() ([]disk.PartitionStat, error) {
+	physical, err := physicalFilesystems()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(current.NamespaceProcPath("mounts"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var res []disk.PartitionStat
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] == "none" || !physical[fields[2]] {
+			continue
+		}
+		res = append(res, disk.PartitionStat{
+			Device:     fields[0],
+			Mountpoint: unescapeMountPoint(fields[1]),
+			Fstype:     fields[2],
+			Opts:       fields[3],
+		})
+	}
+	return res, scanner.Err()
+}
+
+// physicalFilesystems returns the filesystem types listed in /proc/filesystems that are backed by a device
+func physicalFilesystems() (map[string]bool, error) {
+	file, err := os.Open(current.ProcPath("filesystems"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	res := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 1 {
+			res[fields[0]] = true
+		} else if len(fields) == 2 && fields[0] == "nodev" && fields[1] == "zfs" {
+			res[fields[1]] = true
+		}
+	}
+	return res, scanner.Err()
+}
+
+// unescapeMountPoint replaces the octal escapes of whitespace in /proc/.../mounts
+func unescapeMountPoint(path string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(path)
+}
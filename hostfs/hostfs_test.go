@@ -0,0 +1,79 @@
+package hostfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/stretchr/testify/suite"
+)
+
+type HostfsTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestHostfs(t *testing.T) {
+	suite.Run(t, new(HostfsTestSuite))
+}
+
+func (suite *HostfsTestSuite) TestRootFilesystem() {
+	fs := RootFilesystem{ProcRoot: "/host/proc", SysRoot: "/host/sys/"}
+	suite.Equal("/host/proc", fs.ProcPath())
+	suite.Equal("/host/proc/12/cmdline", fs.ProcPath("12", "cmdline"))
+	suite.Equal("/host/sys", fs.SysPath())
+	suite.Equal("/host/sys/block/sda", fs.SysPath("block", "sda"))
+	suite.Equal("/var/lib", fs.HostPath("/var/lib"))
+	suite.Equal("/host/proc/self/net/dev", fs.NamespaceProcPath("net", "dev"))
+
+	fs = NewHostRootFilesystem("/host")
+	suite.Equal("/host/proc/stat", fs.ProcPath("stat"))
+	suite.Equal("/host/sys/block", fs.SysPath("block"))
+	suite.Equal("/host/var/lib", fs.HostPath("/var/lib"))
+	suite.Equal("/host/proc/1/mounts", fs.NamespaceProcPath("mounts"))
+}
+
+func (suite *HostfsTestSuite) TestSet() {
+	previous := current
+	defer func() {
+		current = previous
+		for _, env := range []string{"HOST_PROC", "HOST_SYS", "HOST_ETC"} {
+			_ = os.Unsetenv(env)
+		}
+	}()
+	suite.NoError(Set(NewHostRootFilesystem("/host")))
+	suite.Equal("/host/proc", os.Getenv("HOST_PROC"))
+	suite.Equal("/host/sys", os.Getenv("HOST_SYS"))
+	suite.Equal("/host/etc", os.Getenv("HOST_ETC"))
+	suite.Equal("/host/proc/1/status", Current().ProcPath("1", "status"))
+}
+
+func (suite *HostfsTestSuite) TestPartitions() {
+	dir, err := ioutil.TempDir("", "hostfs")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	suite.NoError(os.MkdirAll(filepath.Join(dir, "1"), 0755))
+	suite.NoError(ioutil.WriteFile(filepath.Join(dir, "filesystems"), []byte("nodev\tsysfs\nnodev\tproc\n\text4\nnodev\tzfs\n"), 0644))
+	suite.NoError(ioutil.WriteFile(filepath.Join(dir, "1", "mounts"), []byte(
+		"sysfs /sys sysfs rw 0 0\n"+
+			"/dev/sda1 / ext4 rw,relatime 0 0\n"+
+			"/dev/sdb1 /mnt/my\\040disk ext4 ro 0 0\n"+
+			"tank /tank zfs rw 0 0\n"), 0644))
+
+	previous := current
+	defer func() {
+		current = previous
+	}()
+	current = RootFilesystem{ProcRoot: dir, HostRoot: "/host"}
+	partitions, err := Partitions()
+	suite.NoError(err)
+	suite.Equal([]disk.PartitionStat{
+		{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Opts: "rw,relatime"},
+		{Device: "/dev/sdb1", Mountpoint: "/mnt/my disk", Fstype: "ext4", Opts: "ro"},
+		{Device: "tank", Mountpoint: "/tank", Fstype: "zfs", Opts: "rw"},
+	}, partitions)
+}
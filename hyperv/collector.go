@@ -0,0 +1,104 @@
+package hyperv
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Collector reads the Hyper-V performance counters of the local Windows host through WMI. VM metrics are
+// reported as hyperv/<vm>/..., similar to the libvirt collector, virtual switch metrics as hyperv-switch/<switch>/...
+// The collector is only available on Windows.
+type Collector struct {
+	collector.AbstractCollector
+
+	lock     sync.Mutex
+	values   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewHypervCollector() *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("hyperv"),
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	return nil, col.update(false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.values))
+	for name := range col.values {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.values[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+type hypervValues struct {
+	values   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func (v *hypervValues) add(name string, value float64, metadata collector.MetricMetadata) {
+	v.values[name] += value
+	v.metadata[name] = metadata
+}
+
+func (col *Collector) update(checkChange bool) error {
+	values := &hypervValues{
+		values:   make(map[string]float64),
+		metadata: make(collector.MetricMetadataMap),
+	}
+	if err := queryCounters(values); err != nil {
+		return err
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := len(values.values) != len(col.values)
+	for name := range values.values {
+		if _, ok := col.values[name]; !ok {
+			changed = true
+		}
+	}
+	col.values = values.values
+	col.metadata = values.metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+// instanceName returns the name of a performance counter instance that can be used in a metric name,
+// or an empty string if the instance should be ignored.
+func instanceName(name string) string {
+	if name == "" || name == "_Total" {
+		return ""
+	}
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
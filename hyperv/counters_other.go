@@ -0,0 +1,9 @@
+// +build !windows
+
+package hyperv
+
+import "errors"
+
+func queryCounters(values *hypervValues) error {
+	return errors.New("The Hyper-V collector is only supported on Windows")
+}
@@ -0,0 +1,88 @@
+package hyperv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/StackExchange/wmi"
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
+
+// Formatted performance counters already contain rates and percentages
+type win32_HyperVVirtualProcessor struct {
+	Name                     string // Format: <vm>:Hv VP <index>
+	PercentTotalRunTime      uint64
+	PercentGuestRunTime      uint64
+	PercentHypervisorRunTime uint64
+}
+
+type win32_HyperVDynamicMemoryVM struct {
+	Name                       string
+	PhysicalMemory             uint64 // MB
+	GuestVisiblePhysicalMemory uint64 // MB
+	CurrentPressure            uint64
+}
+
+type win32_HyperVVirtualSwitch struct {
+	Name                         string
+	BytesReceivedPersec          uint64
+	BytesSentPersec              uint64
+	PacketsReceivedPersec        uint64
+	PacketsSentPersec            uint64
+	DroppedPacketsIncomingPersec uint64
+	DroppedPacketsOutgoingPersec uint64
+}
+
+func queryCounters(values *hypervValues) error {
+	var processors []win32_HyperVVirtualProcessor
+	if err := wmi.Query("SELECT Name, PercentTotalRunTime, PercentGuestRunTime, PercentHypervisorRunTime FROM Win32_PerfFormattedData_HvStats_HyperVHypervisorVirtualProcessor", &processors); err != nil {
+		return fmt.Errorf("Failed to query Hyper-V virtual processors: %v", err)
+	}
+	for _, proc := range processors {
+		vm := proc.Name
+		if index := strings.LastIndex(vm, ":"); index >= 0 {
+			vm = vm[:index]
+		}
+		if vm = instanceName(vm); vm == "" {
+			continue
+		}
+		prefix := "hyperv/" + vm + "/"
+		values.add(prefix+"cpu", float64(proc.PercentTotalRunTime), collector.GaugeMetric(collector.UnitPercent, "CPU usage of all virtual processors of the VM"))
+		values.add(prefix+"cpu/guest", float64(proc.PercentGuestRunTime), collector.GaugeMetric(collector.UnitPercent, "CPU time of the virtual processors spent in the guest"))
+		values.add(prefix+"cpu/hypervisor", float64(proc.PercentHypervisorRunTime), collector.GaugeMetric(collector.UnitPercent, "CPU time of the virtual processors spent in the hypervisor"))
+	}
+
+	var memory []win32_HyperVDynamicMemoryVM
+	if err := wmi.Query("SELECT Name, PhysicalMemory, GuestVisiblePhysicalMemory, CurrentPressure FROM Win32_PerfFormattedData_BalancerStats_HyperVDynamicMemoryVM", &memory); err != nil {
+		return fmt.Errorf("Failed to query Hyper-V VM memory: %v", err)
+	}
+	for _, mem := range memory {
+		vm := instanceName(mem.Name)
+		if vm == "" {
+			continue
+		}
+		prefix := "hyperv/" + vm + "/"
+		values.add(prefix+"mem", float64(mem.PhysicalMemory)*1024*1024, collector.GaugeMetric(collector.UnitBytes, "Physical memory assigned to the VM"))
+		values.add(prefix+"mem/visible", float64(mem.GuestVisiblePhysicalMemory)*1024*1024, collector.GaugeMetric(collector.UnitBytes, "Physical memory visible to the guest"))
+		values.add(prefix+"mem/pressure", float64(mem.CurrentPressure), collector.GaugeMetric(collector.UnitPercent, "Memory demand of the VM relative to its assigned memory"))
+	}
+
+	var switches []win32_HyperVVirtualSwitch
+	if err := wmi.Query("SELECT Name, BytesReceivedPersec, BytesSentPersec, PacketsReceivedPersec, PacketsSentPersec, DroppedPacketsIncomingPersec, DroppedPacketsOutgoingPersec FROM Win32_PerfFormattedData_NvspSwitchStats_HyperVVirtualSwitch", &switches); err != nil {
+		return fmt.Errorf("Failed to query Hyper-V virtual switches: %v", err)
+	}
+	for _, sw := range switches {
+		name := instanceName(sw.Name)
+		if name == "" {
+			continue
+		}
+		prefix := "hyperv-switch/" + name + "/"
+		values.add(prefix+"bytes/rx", float64(sw.BytesReceivedPersec), collector.DerivedMetric(collector.UnitBytesPerSecond, "Bytes received by the virtual switch"))
+		values.add(prefix+"bytes/tx", float64(sw.BytesSentPersec), collector.DerivedMetric(collector.UnitBytesPerSecond, "Bytes sent by the virtual switch"))
+		values.add(prefix+"packets/rx", float64(sw.PacketsReceivedPersec), collector.DerivedMetric(collector.UnitPerSecond, "Packets received by the virtual switch"))
+		values.add(prefix+"packets/tx", float64(sw.PacketsSentPersec), collector.DerivedMetric(collector.UnitPerSecond, "Packets sent by the virtual switch"))
+		values.add(prefix+"dropped/rx", float64(sw.DroppedPacketsIncomingPersec), collector.DerivedMetric(collector.UnitPerSecond, "Incoming packets dropped by the virtual switch"))
+		values.add(prefix+"dropped/tx", float64(sw.DroppedPacketsOutgoingPersec), collector.DerivedMetric(collector.UnitPerSecond, "Outgoing packets dropped by the virtual switch"))
+	}
+	return nil
+}
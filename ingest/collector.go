@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Collector receives metrics from co-located applications through named pipes (FIFOs) or unix sockets.
+// Every source is either the path of an existing FIFO, or unix:///path for a unix socket that is created
+// by the collector. Applications write newline-delimited name=value pairs (multiple pairs per line can be
+// separated by whitespace), or bitflow CSV data starting with a header line ("time,...", see parseCsvLine()).
+// The received metrics are named ingest/<name> and report the latest received value. When new metric names are
+// received, the metric collection is restarted to include them in the sample header.
+type Collector struct {
+	collector.AbstractCollector
+	Sources []string
+
+	lock      sync.Mutex
+	values    map[string]float64
+	published map[string]bool
+	opened    map[string]bool
+}
+
+func NewIngestCollector(sources []string) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("ingest"),
+		Sources:           sources,
+		values:            make(map[string]float64),
+		opened:            make(map[string]bool),
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	// The sources stay open when the collectors are restarted, so applications can keep writing.
+	// After a failed Init, only the sources that could not be opened are retried.
+	for _, source := range col.Sources {
+		if col.opened[source] {
+			continue
+		}
+		if err := col.open(source); err != nil {
+			return nil, fmt.Errorf("Failed to open ingest source %v: %v", source, err)
+		}
+		col.opened[source] = true
+	}
+	col.published = make(map[string]bool, len(col.values))
+	for name := range col.values {
+		col.published[name] = true
+	}
+	return nil, nil
+}
+
+func (col *Collector) open(source string) error {
+	if strings.HasPrefix(source, "unix://") {
+		return col.listenUnix(strings.TrimPrefix(source, "unix://"))
+	}
+	return col.readFifo(source)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	for name := range col.values {
+		if !col.published[name] {
+			return collector.MetricsChanged
+		}
+	}
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.published))
+	for name := range col.published {
+		name := name
+		res["ingest/"+name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.values[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricMetadataMap, len(col.published))
+	for name := range col.published {
+		res["ingest/"+name] = collector.GaugeMetric(collector.UnitNone, "Metric received from a local application")
+	}
+	return res
+}
+
+func (col *Collector) setValues(values map[string]float64) {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	for name, value := range values {
+		col.values[name] = value
+	}
+}
@@ -0,0 +1,143 @@
+package ingest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	csvTimeColumn = "time"
+	csvTagsColumn = "tags"
+)
+
+func (col *Collector) readFifo(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("Not a named pipe (create it with 'mkfifo %v')", path)
+	}
+	// Opening the FIFO for writing as well prevents EOF when writers close the FIFO, and does not block until
+	// the first writer opens it.
+	fifo, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	go col.readLines(path, fifo)
+	return nil
+}
+
+func (col *Collector) listenUnix(path string) error {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// Remove a stale socket of a previous run
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Errorf("Stopped accepting connections on %v: %v", path, err)
+				return
+			}
+			go func() {
+				col.readLines(path, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return nil
+}
+
+// readLines parses the lines of one stream until it is closed. Invalid lines are logged and skipped.
+func (col *Collector) readLines(source string, reader io.Reader) {
+	var csvHeader []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var values map[string]float64
+		var err error
+		if strings.HasPrefix(line, csvTimeColumn+",") {
+			csvHeader = parseCsvHeader(line)
+			continue
+		} else if csvHeader != nil {
+			values, err = parseCsvLine(csvHeader, line)
+		} else {
+			values, err = parseKeyValueLine(line)
+		}
+		if err != nil {
+			log.Warnf("Ingest source %v: %v", source, err)
+		} else {
+			col.setValues(values)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Warnf("Error reading ingest source %v: %v", source, err)
+	}
+}
+
+// parseKeyValueLine parses whitespace-separated name=value pairs
+func parseKeyValueLine(line string) (map[string]float64, error) {
+	fields := strings.Fields(line)
+	values := make(map[string]float64, len(fields))
+	for _, field := range fields {
+		index := strings.IndexRune(field, '=')
+		if index <= 0 {
+			return nil, fmt.Errorf("Expected name=value, got: %v", field)
+		}
+		value, err := strconv.ParseFloat(field[index+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse value of %v: %v", field[:index], err)
+		}
+		values[field[:index]] = value
+	}
+	return values, nil
+}
+
+// parseCsvHeader returns the metric names of a bitflow CSV header. The columns for the timestamp and tags
+// are represented by empty names.
+func parseCsvHeader(line string) []string {
+	header := strings.Split(line, ",")
+	header[0] = ""
+	if len(header) > 1 && header[1] == csvTagsColumn {
+		header[1] = ""
+	}
+	return header
+}
+
+// parseCsvLine parses a bitflow CSV sample. The timestamp and tags are ignored, since received metrics are
+// included in the samples of the collector.
+func parseCsvLine(header []string, line string) (map[string]float64, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != len(header) {
+		return nil, fmt.Errorf("CSV line has %v fields, but the header has %v", len(fields), len(header))
+	}
+	values := make(map[string]float64, len(fields))
+	for i, name := range header {
+		if name == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse value of %v: %v", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
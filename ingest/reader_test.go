@@ -0,0 +1,121 @@
+package ingest
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type IngestTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestIngest(t *testing.T) {
+	suite.Run(t, new(IngestTestSuite))
+}
+
+func (suite *IngestTestSuite) TestParseKeyValueLine() {
+	for _, test := range []struct {
+		line     string
+		expected map[string]float64
+		err      bool
+	}{
+		{"requests=10", map[string]float64{"requests": 10}, false},
+		{"a=1.5  b=-2\tc=1e3", map[string]float64{"a": 1.5, "b": -2, "c": 1000}, false},
+		{"queue/length=0", map[string]float64{"queue/length": 0}, false},
+		{"a=1 b", nil, true},
+		{"=1", nil, true},
+		{"a=x", nil, true},
+	} {
+		values, err := parseKeyValueLine(test.line)
+		if test.err {
+			suite.Error(err, test.line)
+		} else {
+			suite.NoError(err, test.line)
+			suite.Equal(test.expected, values, test.line)
+		}
+	}
+}
+
+func (suite *IngestTestSuite) TestReadLines() {
+	for _, test := range []struct {
+		name     string
+		input    string
+		expected map[string]float64
+	}{
+		{
+			name:     "key value lines",
+			input:    "a=1 b=2\n\n  a=3  \ninvalid\nc=4\n",
+			expected: map[string]float64{"a": 3, "b": 2, "c": 4},
+		},
+		{
+			name: "csv with tags",
+			input: "time,tags,x,y\n" +
+				"2026-10-15 10:00:00.000,host=a,1,2\n" +
+				"2026-10-15 10:00:01.000,host=a,3,4\n",
+			expected: map[string]float64{"x": 3, "y": 4},
+		},
+		{
+			name: "csv without tags",
+			input: "time,x\n" +
+				"2026-10-15 10:00:00.000,5\n" +
+				"2026-10-15 10:00:00.000,6,7\n" +
+				"2026-10-15 10:00:00.000,abc\n",
+			expected: map[string]float64{"x": 5},
+		},
+		{
+			name: "new csv header",
+			input: "time,x\n" +
+				"2026-10-15 10:00:00.000,5\n" +
+				"time,z\n" +
+				"2026-10-15 10:00:00.000,6\n",
+			expected: map[string]float64{"x": 5, "z": 6},
+		},
+	} {
+		col := NewIngestCollector(nil)
+		col.readLines(test.name, strings.NewReader(test.input))
+		suite.Equal(test.expected, col.values, test.name)
+	}
+}
+
+func (suite *IngestTestSuite) TestUnixSocket() {
+	dir, err := ioutil.TempDir("", "ingest")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "ingest.sock")
+	col := NewIngestCollector([]string{"unix://" + path})
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+	suite.Empty(col.Metrics())
+	suite.NoError(col.Update(context.Background()))
+
+	conn, err := net.Dial("unix", path)
+	suite.NoError(err)
+	_, err = conn.Write([]byte("requests=42\n"))
+	suite.NoError(err)
+	suite.NoError(conn.Close())
+
+	// The new metric requires a restart of the collection
+	for start := time.Now(); col.Update(context.Background()) == nil && time.Since(start) < time.Second; {
+		time.Sleep(5 * time.Millisecond)
+	}
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+	suite.NoError(col.Update(context.Background()))
+	metrics := col.Metrics()
+	suite.Len(metrics, 1)
+	suite.Contains(metrics, "ingest/requests")
+	suite.Equal(42.0, float64(metrics["ingest/requests"]()))
+}
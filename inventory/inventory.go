@@ -0,0 +1,138 @@
+// Package inventory gathers static facts about the hardware and operating system of the local host, like the CPU
+// model, the amount of RAM, disks and network interfaces. Downstream analysis can use these facts to normalize metrics
+// by the capacity of the host.
+package inventory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	log "github.com/sirupsen/logrus"
+)
+
+type Facts struct {
+	Hostname       string `json:"hostname"`
+	CpuModel       string `json:"cpu_model,omitempty"`
+	CpuCores       int    `json:"cpu_cores"`   // Physical cores
+	CpuThreads     int    `json:"cpu_threads"` // Logical CPUs
+	MemoryBytes    uint64 `json:"memory_bytes"`
+	Kernel         string `json:"kernel,omitempty"`
+	OS             string `json:"os,omitempty"`
+	Platform       string `json:"platform,omitempty"`
+	Virtualization string `json:"virtualization,omitempty"` // E.g. "kvm/guest", empty for bare-metal hosts
+
+	Disks []Disk `json:"disks"`
+	Nics  []Nic  `json:"nics"`
+}
+
+type Disk struct {
+	Device     string `json:"device"`
+	Mountpoint string `json:"mountpoint"`
+	Fstype     string `json:"fstype"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+type Nic struct {
+	Name      string   `json:"name"`
+	Mac       string   `json:"mac,omitempty"`
+	Mtu       int      `json:"mtu"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Collect gathers the facts of the local host. Facts that cannot be obtained are left empty and logged,
+// an error is only returned if the basic host information is not available.
+func Collect() (*Facts, error) {
+	info, err := host.Info()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read host information: %v", err)
+	}
+	facts := &Facts{
+		Hostname: info.Hostname,
+		Kernel:   info.KernelVersion,
+		OS:       info.OS,
+		Platform: info.Platform + " " + info.PlatformVersion,
+	}
+	if info.VirtualizationSystem != "" {
+		facts.Virtualization = info.VirtualizationSystem + "/" + info.VirtualizationRole
+	}
+	facts.readCpu()
+	if memory, err := mem.VirtualMemory(); err != nil {
+		log.Warnln("Failed to read the total memory:", err)
+	} else {
+		facts.MemoryBytes = memory.Total
+	}
+	facts.readDisks()
+	facts.readNics()
+	return facts, nil
+}
+
+func (facts *Facts) readCpu() {
+	var err error
+	if facts.CpuThreads, err = cpu.Counts(true); err != nil {
+		log.Warnln("Failed to read the number of logical CPUs:", err)
+	}
+	if facts.CpuCores, err = cpu.Counts(false); err != nil {
+		log.Warnln("Failed to read the number of CPU cores:", err)
+	}
+	if infos, err := cpu.Info(); err != nil {
+		log.Warnln("Failed to read the CPU model:", err)
+	} else if len(infos) > 0 {
+		facts.CpuModel = infos[0].ModelName
+	}
+}
+
+func (facts *Facts) readDisks() {
+	partitions, err := hostfs.Partitions()
+	if err != nil {
+		log.Warnln("Failed to read disk partitions:", err)
+		return
+	}
+	facts.Disks = make([]Disk, 0, len(partitions))
+	for _, partition := range partitions {
+		d := Disk{Device: partition.Device, Mountpoint: partition.Mountpoint, Fstype: partition.Fstype}
+		if usage, err := disk.Usage(hostfs.Current().HostPath(partition.Mountpoint)); err == nil {
+			d.TotalBytes = usage.Total
+		}
+		facts.Disks = append(facts.Disks, d)
+	}
+	sort.Slice(facts.Disks, func(i, j int) bool {
+		return facts.Disks[i].Mountpoint < facts.Disks[j].Mountpoint
+	})
+}
+
+func (facts *Facts) readNics() {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Warnln("Failed to read network interfaces:", err)
+		return
+	}
+	facts.Nics = make([]Nic, 0, len(interfaces))
+	for _, iface := range interfaces {
+		if isLoopback(iface) {
+			continue
+		}
+		nic := Nic{Name: iface.Name, Mac: iface.HardwareAddr, Mtu: iface.MTU}
+		for _, addr := range iface.Addrs {
+			nic.Addresses = append(nic.Addresses, addr.Addr)
+		}
+		facts.Nics = append(facts.Nics, nic)
+	}
+	sort.Slice(facts.Nics, func(i, j int) bool {
+		return facts.Nics[i].Name < facts.Nics[j].Name
+	})
+}
+
+func isLoopback(iface net.InterfaceStat) bool {
+	for _, flag := range iface.Flags {
+		if flag == "loopback" {
+			return true
+		}
+	}
+	return false
+}
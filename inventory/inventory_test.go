@@ -0,0 +1,33 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/suite"
+)
+
+type InventoryTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestInventory(t *testing.T) {
+	suite.Run(t, new(InventoryTestSuite))
+}
+
+func (suite *InventoryTestSuite) TestCollect() {
+	facts, err := Collect()
+	suite.NoError(err)
+	suite.NotEmpty(facts.Hostname)
+	suite.True(facts.CpuThreads > 0)
+	suite.True(facts.MemoryBytes > 0)
+	for _, nic := range facts.Nics {
+		suite.NotEqual("lo", nic.Name)
+	}
+}
+
+func (suite *InventoryTestSuite) TestLoopback() {
+	suite.True(isLoopback(net.InterfaceStat{Name: "lo", Flags: []string{"up", "loopback"}}))
+	suite.False(isLoopback(net.InterfaceStat{Name: "eth0", Flags: []string{"up", "broadcast", "multicast"}}))
+}
@@ -0,0 +1,198 @@
+package iscsi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	DefaultSessionDir = "/sys/class/iscsi_session"
+	DefaultIscsiadm   = "iscsiadm"
+)
+
+var (
+	// PDUs sent and received by the initiator, as reported by 'iscsiadm -m session -s'
+	txPdus = []string{"noptx_pdus", "scsicmd_pdus", "tmfcmd_pdus", "login_pdus", "text_pdus", "dataout_pdus", "logout_pdus", "snack_pdus"}
+	rxPdus = []string{"noprx_pdus", "scsirsp_pdus", "tmfrsp_pdus", "textrsp_pdus", "datain_pdus", "logoutrsp_pdus", "r2t_pdus", "async_pdus", "rjt_pdus"}
+)
+
+// Collector reports the state and traffic statistics of all iSCSI initiator sessions. The sessions are listed in
+// /sys/class/iscsi_session, their statistics are queried from the kernel through 'iscsiadm -m session -r <sid> -s'.
+// Metrics are named iscsi/<session>/..., e.g. iscsi/session1/rx.
+type Collector struct {
+	collector.AbstractCollector
+	SessionDir string
+	Iscsiadm   string
+	factory    *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewIscsiCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("iscsi"),
+		SessionDir:        DefaultSessionDir,
+		Iscsiadm:          DefaultIscsiadm,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+// readStats parses the output of 'iscsiadm -m session -r <sid> -s', which contains lines in the format "<name>: <value>"
+func (col *Collector) readStats(ctx context.Context, sid string) (map[string]float64, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, col.Iscsiadm, "-m", "session", "-r", sid, "-s")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v -m session -r %v -s failed: %v (%v)", col.Iscsiadm, sid, err, strings.TrimSpace(stderr.String()))
+	}
+	stats := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			stats[strings.TrimSuffix(fields[0], ":")] = value
+		}
+	}
+	return stats, scanner.Err()
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	sessions, err := filepath.Glob(filepath.Join(col.SessionDir, "session*"))
+	if err != nil {
+		return err
+	}
+	counters := make(map[string]float64)
+	gauges := map[string]float64{"iscsi/sessions": 0}
+	metadata := collector.MetricMetadataMap{
+		"iscsi/sessions": collector.GaugeMetric(collector.UnitCount, "Logged in iSCSI sessions"),
+	}
+	for _, dir := range sessions {
+		session := filepath.Base(dir)
+		state, err := ioutil.ReadFile(filepath.Join(dir, "state"))
+		if err != nil {
+			// The session has been removed in the meantime
+			continue
+		}
+		stats, err := col.readStats(ctx, strings.TrimPrefix(session, "session"))
+		if err != nil {
+			return err
+		}
+
+		prefix := "iscsi/" + session + "/"
+		loggedIn := 0.0
+		if strings.TrimSpace(string(state)) == "LOGGED_IN" {
+			loggedIn = 1
+		}
+		gauges[prefix+"logged-in"] = loggedIn
+		gauges["iscsi/sessions"] += loggedIn
+		metadata[prefix+"logged-in"] = collector.GaugeMetric(collector.UnitNone, "1 if the session is logged in, 0 otherwise")
+
+		counters[prefix+"tx"] = stats["txdata_octets"]
+		counters[prefix+"rx"] = stats["rxdata_octets"]
+		for _, pdu := range txPdus {
+			counters[prefix+"pdus/tx"] += stats[pdu]
+		}
+		for _, pdu := range rxPdus {
+			counters[prefix+"pdus/rx"] += stats[pdu]
+		}
+		counters[prefix+"retransmit-requests"] = stats["snack_pdus"]
+		counters[prefix+"errors/digest"] = stats["digest_err"]
+		counters[prefix+"errors/timeout"] = stats["timeout_err"]
+		counters[prefix+"aborts"] = stats["eh_abort_cnt"]
+		metadata[prefix+"tx"] = collector.DerivedMetric(collector.UnitBytesPerSecond, "Data sent to the target")
+		metadata[prefix+"rx"] = collector.DerivedMetric(collector.UnitBytesPerSecond, "Data received from the target")
+		metadata[prefix+"pdus/tx"] = collector.DerivedMetric(collector.UnitPerSecond, "PDUs sent to the target")
+		metadata[prefix+"pdus/rx"] = collector.DerivedMetric(collector.UnitPerSecond, "PDUs received from the target")
+		metadata[prefix+"retransmit-requests"] = collector.DerivedMetric(collector.UnitPerSecond, "SNACK PDUs requesting the retransmission of data")
+		metadata[prefix+"errors/digest"] = collector.DerivedMetric(collector.UnitPerSecond, "Header or data digest errors")
+		metadata[prefix+"errors/timeout"] = collector.DerivedMetric(collector.UnitPerSecond, "Connection timeouts")
+		metadata[prefix+"aborts"] = collector.DerivedMetric(collector.UnitPerSecond, "SCSI commands aborted by the error handler")
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(counters) != len(col.counters) || len(gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(counters))
+	}
+	for name, value := range counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package iscsi
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type IscsiTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestIscsi(t *testing.T) {
+	suite.Run(t, new(IscsiTestSuite))
+}
+
+const testStats = `Stats for session [sid: 1, target: iqn.2001-05.com.example:disk0, portal: 10.0.0.1,3260]
+iSCSI SNMP:
+	txdata_octets: 1000
+	rxdata_octets: 2000
+	noptx_pdus: 1
+	scsicmd_pdus: 10
+	tmfcmd_pdus: 0
+	login_pdus: 0
+	text_pdus: 0
+	dataout_pdus: 5
+	logout_pdus: 0
+	snack_pdus: 2
+	noprx_pdus: 1
+	scsirsp_pdus: 10
+	tmfrsp_pdus: 0
+	textrsp_pdus: 0
+	datain_pdus: 20
+	logoutrsp_pdus: 0
+	r2t_pdus: 3
+	async_pdus: 0
+	rjt_pdus: 0
+	digest_err: 4
+	timeout_err: 5
+iSCSI Extended:
+	tx_sendpage_failures: 0
+	rx_discontiguous_hdr: 0
+	eh_abort_cnt: 6
+`
+
+// newCollector returns a Collector reading the given sessions from a temporary session directory. The iscsiadm command
+// is replaced by a script printing the file stats<sid> in the same directory.
+func (suite *IscsiTestSuite) newCollector(dir string, states map[string]string, stats map[string]string) *Collector {
+	sessionDir := filepath.Join(dir, "iscsi_session")
+	suite.NoError(os.MkdirAll(sessionDir, 0755))
+	for session, state := range states {
+		suite.NoError(os.MkdirAll(filepath.Join(sessionDir, session), 0755))
+		suite.NoError(ioutil.WriteFile(filepath.Join(sessionDir, session, "state"), []byte(state+"\n"), 0644))
+	}
+	for sid, output := range stats {
+		suite.NoError(ioutil.WriteFile(filepath.Join(dir, "stats"+sid), []byte(output), 0644))
+	}
+	script := filepath.Join(dir, "iscsiadm")
+	suite.NoError(ioutil.WriteFile(script, []byte("#!/bin/sh\ncat \"$(dirname \"$0\")/stats$4\"\n"), 0755))
+
+	col := NewIscsiCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.SessionDir = sessionDir
+	col.Iscsiadm = script
+	return col
+}
+
+func (suite *IscsiTestSuite) TestCollect() {
+	for _, test := range []struct {
+		name     string
+		states   map[string]string
+		stats    map[string]string
+		gauges   map[string]float64
+		counters []string
+		err      bool
+	}{
+		{
+			name:   "sessions",
+			states: map[string]string{"session1": "LOGGED_IN", "session2": "FAILED"},
+			stats:  map[string]string{"1": testStats, "2": ""},
+			gauges: map[string]float64{
+				"iscsi/sessions":           1,
+				"iscsi/session1/logged-in": 1,
+				"iscsi/session2/logged-in": 0,
+			},
+			counters: []string{
+				"iscsi/session1/tx", "iscsi/session1/rx", "iscsi/session1/pdus/tx", "iscsi/session1/pdus/rx",
+				"iscsi/session1/retransmit-requests", "iscsi/session1/errors/digest", "iscsi/session1/errors/timeout",
+				"iscsi/session1/aborts",
+				"iscsi/session2/tx", "iscsi/session2/rx", "iscsi/session2/pdus/tx", "iscsi/session2/pdus/rx",
+				"iscsi/session2/retransmit-requests", "iscsi/session2/errors/digest", "iscsi/session2/errors/timeout",
+				"iscsi/session2/aborts",
+			},
+		},
+		{
+			name:   "no sessions",
+			gauges: map[string]float64{"iscsi/sessions": 0},
+		},
+		{
+			name:   "iscsiadm failure",
+			states: map[string]string{"session3": "LOGGED_IN"},
+			err:    true,
+		},
+	} {
+		func() {
+			dir, err := ioutil.TempDir("", "iscsi")
+			suite.NoError(err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			col := suite.newCollector(dir, test.states, test.stats)
+			_, err = col.Init(context.Background())
+			if test.err {
+				suite.Error(err, test.name)
+				return
+			}
+			suite.NoError(err, test.name)
+
+			metrics := col.Metrics()
+			suite.Len(metrics, len(test.gauges)+len(test.counters), test.name)
+			for name, expected := range test.gauges {
+				suite.Contains(metrics, name, test.name)
+				suite.Equal(expected, float64(metrics[name]()), test.name+": "+name)
+			}
+			metadata := col.MetricsMetadata()
+			for _, name := range test.counters {
+				suite.Contains(metrics, name, test.name)
+				suite.Equal(collector.Derived, metadata[name].Type, test.name+": "+name)
+			}
+			suite.NoError(col.Update(context.Background()), test.name)
+		}()
+	}
+}
+
+func (suite *IscsiTestSuite) TestSessionCounters() {
+	dir, err := ioutil.TempDir("", "iscsi")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	col := suite.newCollector(dir, map[string]string{"session1": "LOGGED_IN"}, map[string]string{"1": testStats})
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+
+	col.lock.Lock()
+	values := make(map[string]float64)
+	for name, ring := range col.counters {
+		values[name] = float64(ring.GetHead().(collector.StoredValue))
+	}
+	col.lock.Unlock()
+	suite.Equal(map[string]float64{
+		"iscsi/session1/tx":                  1000,
+		"iscsi/session1/rx":                  2000,
+		"iscsi/session1/pdus/tx":             18,
+		"iscsi/session1/pdus/rx":             34,
+		"iscsi/session1/retransmit-requests": 2,
+		"iscsi/session1/errors/digest":       4,
+		"iscsi/session1/errors/timeout":      5,
+		"iscsi/session1/aborts":              6,
+	}, values)
+}
+
+func (suite *IscsiTestSuite) TestSessionsChanged() {
+	dir, err := ioutil.TempDir("", "iscsi")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	col := suite.newCollector(dir, map[string]string{"session1": "LOGGED_IN"}, map[string]string{"1": testStats, "2": testStats})
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+	suite.NoError(col.MetricsChanged(context.Background()))
+
+	// A new session adds new metrics
+	suite.newCollector(dir, map[string]string{"session2": "LOGGED_IN"}, nil)
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+	suite.NoError(col.Update(context.Background()))
+
+	// A removed session removes its metrics
+	suite.NoError(os.RemoveAll(filepath.Join(dir, "iscsi_session", "session1")))
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+	suite.Len(col.Metrics(), 10)
+}
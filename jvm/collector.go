@@ -0,0 +1,204 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultPerfDataGlob = "/tmp/hsperfdata_*/[0-9]*"
+
+	// Counters of the JVM that are not reported as metrics, but used to identify the JVMs
+	javaCommandCounter = "sun.rt.javaCommand"
+	tickFrequency      = "sun.os.hrt.frequency"
+)
+
+// DefaultCounters selects the instrumentation counters for garbage collection, heap and metaspace usage, threads,
+// class loading, safepoints and JIT compilation.
+var DefaultCounters = regexp.MustCompile(`^(sun\.gc\.(collector\.\d+\.(invocations|time)|generation\.\d+\.(capacity|space\.\d+\.used)|metaspace\.(used|capacity))` +
+	`|java\.threads\.(live|daemon)|java\.cls\.(loaded|unloaded)Classes|sun\.rt\.safepoint(s|Time)|sun\.ci\.totalTime)$`)
+
+// Collector reports the instrumentation counters of local HotSpot JVMs, without requiring a JMX agent like Jolokia.
+// The JVMs publish the counters, which are also the source of the memory, GC, threading and class loading MBeans,
+// through memory-mapped hsperfdata files (disabled by -XX:-UsePerfData). The JVMs map contains the names of the
+// monitored JVMs, and regexes matched against their main class or JAR file and arguments. The selected counters are
+// reported as jvm/<name>/<counter>. Monotonic counters are reported as rates, time counters in seconds.
+// JVMs running in containers write the hsperfdata files into the /tmp directory of the container.
+type Collector struct {
+	collector.AbstractCollector
+	JVMs         map[string]*regexp.Regexp
+	Counters     *regexp.Regexp
+	PerfDataGlob string
+	factory      *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewJvmCollector(jvms map[string]*regexp.Regexp, factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("jvm"),
+		JVMs:              jvms,
+		Counters:          DefaultCounters,
+		PerfDataGlob:      DefaultPerfDataGlob,
+		factory:           factory,
+	}
+}
+
+// ParseJvm parses a JVM specification in the format name=regex
+func ParseJvm(spec string) (string, *regexp.Regexp, error) {
+	index := strings.IndexRune(spec, '=')
+	if index <= 0 || index == len(spec)-1 {
+		return "", nil, fmt.Errorf("Invalid JVM '%v', expected format: name=regex", spec)
+	}
+	regex, err := regexp.Compile(spec[index+1:])
+	if err != nil {
+		return "", nil, fmt.Errorf("Error compiling regex of JVM %v: %v", spec[:index], err)
+	}
+	return spec[:index], regex, nil
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+// findJvms returns the parsed hsperfdata files of the JVMs matching the configured regexes. If multiple JVMs match
+// the same regex, only the first one is used.
+func (col *Collector) findJvms() (map[string]map[string]perfCounter, error) {
+	files, err := filepath.Glob(col.PerfDataGlob)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]map[string]perfCounter, len(col.JVMs))
+	for _, file := range files {
+		counters, err := readPerfData(file)
+		if err != nil {
+			// The JVM has exited, is still starting, or belongs to a different user
+			log.Debugln("Skipping hsperfdata file:", err)
+			continue
+		}
+		command := counters[javaCommandCounter].str
+		for name, regex := range col.JVMs {
+			if _, ok := res[name]; !ok && regex.MatchString(command) {
+				res[name] = counters
+			}
+		}
+	}
+	for name := range col.JVMs {
+		if _, ok := res[name]; !ok {
+			return nil, fmt.Errorf("No running JVM found for %v (regex %v)", name, col.JVMs[name])
+		}
+	}
+	return res, nil
+}
+
+func (col *Collector) update(checkChange bool) error {
+	jvms, err := col.findJvms()
+	if err != nil {
+		return err
+	}
+	counters := make(map[string]float64)
+	gauges := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	for name, perfCounters := range jvms {
+		frequency := float64(perfCounters[tickFrequency].value)
+		for counterName, counter := range perfCounters {
+			if counter.isString || !col.Counters.MatchString(counterName) {
+				continue
+			}
+			metric := "jvm/" + name + "/" + counterName
+			value, unit := float64(counter.value), collector.UnitNone
+			if counter.ticks && frequency > 0 {
+				value, unit = value/frequency, collector.UnitSeconds
+			}
+			if counter.monotonic {
+				counters[metric] = value
+				if unit == collector.UnitSeconds {
+					// Time spent per second
+					unit = collector.UnitNone
+				} else {
+					unit = collector.UnitPerSecond
+				}
+				metadata[metric] = collector.DerivedMetric(unit, "Rate of the JVM counter "+counterName)
+			} else {
+				gauges[metric] = value
+				metadata[metric] = collector.GaugeMetric(unit, "JVM counter "+counterName)
+			}
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(counters) != len(col.counters) || len(gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(counters))
+	}
+	for name, value := range counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
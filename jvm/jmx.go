@@ -0,0 +1,410 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultJmxTimeout = 5 * time.Second
+
+	// This client does not take part in the distributed garbage collection of RMI, so the JVM keeps the RMIConnection
+	// object only until the acknowledgement timeout expires (sun.rmi.dgc.ackTimeout, 5 minutes by default).
+	// The connection is re-established regularly before that happens.
+	DefaultJmxReconnectInterval = 2 * time.Minute
+)
+
+// DefaultJmxAttributes selects the attributes of the platform MBeans for memory usage, garbage collection,
+// threads, class loading and the CPU and file descriptor usage of the process.
+var DefaultJmxAttributes = []string{
+	"java.lang:type=Memory/HeapMemoryUsage,NonHeapMemoryUsage",
+	"java.lang:type=GarbageCollector,name=*/CollectionCount,CollectionTime",
+	"java.lang:type=Threading/ThreadCount,DaemonThreadCount",
+	"java.lang:type=ClassLoading/LoadedClassCount",
+	"java.lang:type=OperatingSystem/ProcessCpuLoad,OpenFileDescriptorCount",
+}
+
+// DefaultJmxRates selects the monotonic attributes of the platform MBeans, which are reported as rates
+var DefaultJmxRates = regexp.MustCompile(`/(CollectionCount|CollectionTime|TotalStartedThreadCount|TotalLoadedClassCount|UnloadedClassCount|ProcessCpuTime)$`)
+
+// JmxEndpoint is the address of the RMI registry of a JVM with an enabled JMX agent
+// (-Dcom.sun.management.jmxremote.port=<port>), and the optional credentials for password authentication.
+type JmxEndpoint struct {
+	Name     string
+	Addr     string
+	User     string
+	Password string
+}
+
+// ParseJmxEndpoint parses a JMX endpoint in the format [name=][user:password@]host:port. The name defaults to the host.
+func ParseJmxEndpoint(spec string) (JmxEndpoint, error) {
+	var endpoint JmxEndpoint
+	addr := spec
+	if index := strings.IndexRune(addr, '='); index >= 0 {
+		endpoint.Name, addr = addr[:index], addr[index+1:]
+		if endpoint.Name == "" {
+			return endpoint, fmt.Errorf("Invalid JMX endpoint '%v': empty name", spec)
+		}
+	}
+	if index := strings.LastIndex(addr, "@"); index >= 0 {
+		credentials := addr[:index]
+		addr = addr[index+1:]
+		colon := strings.IndexRune(credentials, ':')
+		if colon <= 0 {
+			return endpoint, fmt.Errorf("Invalid JMX endpoint '%v': expected credentials in the format user:password", spec)
+		}
+		endpoint.User, endpoint.Password = credentials[:colon], credentials[colon+1:]
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return endpoint, fmt.Errorf("Invalid JMX endpoint '%v', expected format: [name=][user:password@]host:port", spec)
+	}
+	endpoint.Addr = addr
+	if endpoint.Name == "" {
+		endpoint.Name = host
+	}
+	return endpoint, nil
+}
+
+// JmxAttributes is a list of attributes of one MBean, or of all MBeans matching an ObjectName pattern
+type JmxAttributes struct {
+	ObjectName string
+	Attributes []string
+}
+
+// ParseJmxAttributes parses a list of MBean attributes in the format <ObjectName>/<attribute>[,<attribute>...]
+func ParseJmxAttributes(spec string) (JmxAttributes, error) {
+	index := strings.LastIndex(spec, "/")
+	if index <= 0 || index == len(spec)-1 || !strings.ContainsRune(spec[:index], ':') {
+		return JmxAttributes{}, fmt.Errorf("Invalid JMX attributes '%v', expected format: <ObjectName>/<attribute>[,<attribute>...]", spec)
+	}
+	res := JmxAttributes{ObjectName: spec[:index]}
+	for _, attr := range strings.Split(spec[index+1:], ",") {
+		if attr = strings.TrimSpace(attr); attr != "" {
+			res.Attributes = append(res.Attributes, attr)
+		}
+	}
+	return res, nil
+}
+
+func (a JmxAttributes) isPattern() bool {
+	return strings.ContainsAny(a.ObjectName, "*?")
+}
+
+// JmxCollector reads MBean attributes from the JMX agents of remote or local JVMs through the RMI protocol,
+// without requiring a Java runtime or an agent like Jolokia. Numeric and boolean attributes are reported as
+// jmx/<jvm>/<domain>/<key property values>/<attribute>, the items of composite attributes (like the MemoryUsage of
+// the Memory MBean) as additional path elements. Attributes matching the Rates regex are reported as rates.
+// Only plain RMI connections are supported, not connections secured through SSL.
+type JmxCollector struct {
+	collector.AbstractCollector
+	JVMs              []JmxEndpoint
+	Attributes        []JmxAttributes
+	Rates             *regexp.Regexp
+	Timeout           time.Duration
+	ReconnectInterval time.Duration
+	factory           *collector.ValueRingFactory
+
+	lock        sync.Mutex
+	connections map[string]*jmxConnection
+	counters    map[string]*collector.ValueRing
+	gauges      map[string]float64
+	metadata    collector.MetricMetadataMap
+}
+
+func NewJmxCollector(jvms []JmxEndpoint, attributes []JmxAttributes, factory *collector.ValueRingFactory) *JmxCollector {
+	return &JmxCollector{
+		AbstractCollector: collector.RootCollector("jmx"),
+		JVMs:              jvms,
+		Attributes:        attributes,
+		Rates:             DefaultJmxRates,
+		Timeout:           DefaultJmxTimeout,
+		ReconnectInterval: DefaultJmxReconnectInterval,
+		factory:           factory,
+	}
+}
+
+func (col *JmxCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	col.closeConnections()
+	return nil, col.update(false)
+}
+
+func (col *JmxCollector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *JmxCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *JmxCollector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *JmxCollector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *JmxCollector) closeConnections() {
+	for name, conn := range col.connections {
+		conn.close()
+		delete(col.connections, name)
+	}
+}
+
+// connection returns the established JMX connection to the given JVM, or creates a new one
+func (col *JmxCollector) connection(jvm JmxEndpoint) (*jmxConnection, error) {
+	if conn, ok := col.connections[jvm.Name]; ok {
+		if time.Since(conn.created) < col.ReconnectInterval {
+			return conn, nil
+		}
+		conn.close()
+		delete(col.connections, jvm.Name)
+	}
+	conn, err := connectJmx(jvm.Addr, jvm.User, jvm.Password, col.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to the JMX agent of %v: %v", jvm.Name, err)
+	}
+	if col.connections == nil {
+		col.connections = make(map[string]*jmxConnection)
+	}
+	col.connections[jvm.Name] = conn
+	return conn, nil
+}
+
+// readJvm reads the configured attributes of one JVM. If the connection fails, it is closed and re-established
+// in the next update.
+func (col *JmxCollector) readJvm(jvm JmxEndpoint, values map[string]float64) error {
+	conn, err := col.connection(jvm)
+	if err != nil {
+		return err
+	}
+	err = col.readAttributes(conn, "jmx/"+jvm.Name, values)
+	if err != nil {
+		conn.close()
+		delete(col.connections, jvm.Name)
+		err = fmt.Errorf("Failed to read JMX attributes of %v: %v", jvm.Name, err)
+	}
+	return err
+}
+
+func (col *JmxCollector) readAttributes(conn *jmxConnection, prefix string, values map[string]float64) error {
+	for _, attributes := range col.Attributes {
+		names := []string{attributes.ObjectName}
+		if attributes.isPattern() {
+			var err error
+			if names, err = conn.queryNames(attributes.ObjectName); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			mbeanPath := jmxMetricPath(name)
+			for _, attr := range attributes.Attributes {
+				value, err := conn.getAttribute(name, attr)
+				if remoteErr, ok := err.(*rmiRemoteException); ok && isMissingMBeanException(remoteErr) {
+					// Optional MBeans or attributes, e.g. depending on the JVM version or operating system
+					log.Debugf("Skipping JMX attribute %v of %v: %v", attr, name, err)
+					continue
+				} else if err != nil {
+					return err
+				}
+				flattenJavaValue(prefix+"/"+mbeanPath+"/"+sanitizeJmxName(attr), value, values)
+			}
+		}
+	}
+	return nil
+}
+
+func isMissingMBeanException(err *rmiRemoteException) bool {
+	switch err.class {
+	case "javax.management.InstanceNotFoundException", "javax.management.AttributeNotFoundException":
+		return true
+	}
+	return false
+}
+
+func (col *JmxCollector) update(checkChange bool) error {
+	values := make(map[string]float64)
+	for _, jvm := range col.JVMs {
+		if err := col.readJvm(jvm, values); err != nil {
+			return err
+		}
+	}
+	counters := make(map[string]float64)
+	gauges := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap, len(values))
+	for metric, value := range values {
+		if col.Rates != nil && col.Rates.MatchString(metric) {
+			counters[metric] = value
+			metadata[metric] = collector.DerivedMetric(collector.UnitPerSecond, "Rate of the JMX attribute "+metric)
+		} else {
+			gauges[metric] = value
+			metadata[metric] = collector.GaugeMetric(collector.UnitNone, "JMX attribute "+metric)
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(counters) != len(col.counters) || len(gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(counters))
+	}
+	for name, value := range counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+// jmxMetricPath converts an ObjectName like java.lang:type=GarbageCollector,name=G1 Young Generation into the
+// metric path java.lang/GarbageCollector/G1_Young_Generation. Quoted values are unquoted.
+func jmxMetricPath(objectName string) string {
+	index := strings.IndexRune(objectName, ':')
+	if index < 0 {
+		return sanitizeJmxName(objectName)
+	}
+	parts := []string{sanitizeJmxName(objectName[:index])}
+	for _, property := range splitJmxProperties(objectName[index+1:]) {
+		value := property
+		if eq := strings.IndexRune(property, '='); eq >= 0 {
+			value = property[eq+1:]
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		parts = append(parts, sanitizeJmxName(value))
+	}
+	return strings.Join(parts, "/")
+}
+
+// splitJmxProperties splits the key properties of an ObjectName at commas outside of quoted values
+func splitJmxProperties(properties string) []string {
+	var res []string
+	start, quoted := 0, false
+	for i := 0; i < len(properties); i++ {
+		switch properties[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				res = append(res, properties[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, properties[start:])
+}
+
+var jmxNameReplacer = strings.NewReplacer(" ", "_", "/", "_")
+
+func sanitizeJmxName(name string) string {
+	return jmxNameReplacer.Replace(name)
+}
+
+// flattenJavaValue stores the numeric value of a deserialized attribute value. Number and Boolean objects are
+// reported directly, the items of CompositeData objects as sub-paths of the metric. Other values are ignored.
+func flattenJavaValue(metric string, value interface{}, values map[string]float64) {
+	switch value := value.(type) {
+	case int64:
+		values[metric] = float64(value)
+	case float64:
+		values[metric] = value
+	case bool:
+		if value {
+			values[metric] = 1
+		} else {
+			values[metric] = 0
+		}
+	case *javaObject:
+		if value.class.isSubclassOf("java.lang.Number") || value.class.name == "java.lang.Boolean" {
+			if primitive, ok := value.field("value"); ok {
+				switch primitive.(type) {
+				case int64, float64, bool:
+					flattenJavaValue(metric, primitive, values)
+				}
+			}
+		} else if value.class.isSubclassOf("javax.management.openmbean.CompositeDataSupport") {
+			if contents, ok := value.field("contents"); ok {
+				for key, item := range javaMapEntries(contents) {
+					flattenJavaValue(metric+"/"+sanitizeJmxName(key), item, values)
+				}
+			}
+		}
+	}
+}
+
+// javaMapEntries returns the entries with string keys of a deserialized java.util.TreeMap or java.util.HashMap,
+// which write their entries as alternating keys and values after the block data of their writeObject() methods.
+func javaMapEntries(value interface{}) map[string]interface{} {
+	obj, ok := value.(*javaObject)
+	if !ok {
+		return nil
+	}
+	var annotation []interface{}
+	for _, class := range []string{"java.util.TreeMap", "java.util.HashMap"} {
+		if annotation = obj.annotation(class); annotation != nil {
+			break
+		}
+	}
+	var objects []interface{}
+	for _, content := range annotation {
+		if _, isBlock := content.([]byte); !isBlock {
+			objects = append(objects, content)
+		}
+	}
+	res := make(map[string]interface{}, len(objects)/2)
+	for i := 0; i+1 < len(objects); i += 2 {
+		if key, ok := objects[i].(string); ok {
+			res[key] = objects[i+1]
+		}
+	}
+	return res
+}
@@ -0,0 +1,360 @@
+package jvm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type JmxTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestJmx(t *testing.T) {
+	suite.Run(t, new(JmxTestSuite))
+}
+
+// javaStream builds serialized Java objects as written by an ObjectOutputStream
+type javaStream struct {
+	bytes.Buffer
+}
+
+func (s *javaStream) write(values ...interface{}) *javaStream {
+	for _, val := range values {
+		_ = binary.Write(s, binary.BigEndian, val)
+	}
+	return s
+}
+
+func (s *javaStream) utf(str string) *javaStream {
+	s.write(uint16(len(str)))
+	s.WriteString(str)
+	return s
+}
+
+func (s *javaStream) str(str string) *javaStream {
+	s.WriteByte(tcString)
+	return s.utf(str)
+}
+
+func (s *javaStream) block(data func(s *javaStream)) *javaStream {
+	var content javaStream
+	data(&content)
+	s.write(byte(tcBlockData), byte(content.Len()))
+	s.Write(content.Bytes())
+	return s
+}
+
+// classDesc writes a class descriptor, the superclass descriptor is written by super (TC_NULL if nil)
+func (s *javaStream) classDesc(name string, flags byte, fields []javaField, super func(s *javaStream)) *javaStream {
+	s.WriteByte(tcClassDesc)
+	s.utf(name).write(int64(0), flags, uint16(len(fields)))
+	for _, field := range fields {
+		s.WriteByte(field.typeCode)
+		s.utf(field.name)
+		if field.typeCode == 'L' || field.typeCode == '[' {
+			s.str(field.className)
+		}
+	}
+	s.write(byte(tcNull), byte(tcEndBlockData))
+	if super == nil {
+		s.WriteByte(tcNull)
+	} else {
+		super(s)
+	}
+	return s
+}
+
+// stub writes an RMI stub with a UnicastRef, as returned by the registry and by RMIServer.newClient()
+func (s *javaStream) stub(class string, port int32, objNum int64) *javaStream {
+	s.WriteByte(tcObject)
+	s.classDesc(class, scSerializable, nil, func(s *javaStream) {
+		s.classDesc("java.rmi.server.RemoteStub", scSerializable, nil, func(s *javaStream) {
+			s.classDesc("java.rmi.server.RemoteObject", scSerializable|scWriteMethod, nil, nil)
+		})
+	})
+	s.block(func(s *javaStream) {
+		s.utf("UnicastRef").utf("unreachable.invalid").write(port, objNum, int32(1), int64(2), int16(3), false)
+	})
+	s.WriteByte(tcEndBlockData)
+	return s
+}
+
+func (s *javaStream) long(val int64) *javaStream {
+	s.WriteByte(tcObject)
+	s.classDesc("java.lang.Long", scSerializable, []javaField{{typeCode: 'J', name: "value"}}, func(s *javaStream) {
+		s.classDesc("java.lang.Number", scSerializable, nil, nil)
+	})
+	return s.write(val)
+}
+
+func (s *javaStream) composite(items map[string]int64) *javaStream {
+	s.WriteByte(tcObject)
+	s.classDesc("javax.management.openmbean.CompositeDataSupport", scSerializable,
+		[]javaField{{typeCode: 'L', name: "contents", className: "Ljava/util/SortedMap;"}}, nil)
+	s.WriteByte(tcObject)
+	s.classDesc("java.util.TreeMap", scSerializable|scWriteMethod,
+		[]javaField{{typeCode: 'L', name: "comparator", className: "Ljava/util/Comparator;"}}, nil)
+	s.WriteByte(tcNull)
+	s.block(func(s *javaStream) {
+		s.write(int32(len(items)))
+	})
+	for key, val := range items {
+		s.str(key).long(val)
+	}
+	s.WriteByte(tcEndBlockData)
+	return s
+}
+
+func (s *javaStream) nameSet(names ...string) *javaStream {
+	s.WriteByte(tcObject)
+	s.classDesc("java.util.HashSet", scSerializable|scWriteMethod, nil, nil)
+	s.block(func(s *javaStream) {
+		s.write(int32(16), float32(0.75), int32(len(names)))
+	})
+	for _, name := range names {
+		s.WriteByte(tcObject)
+		s.classDesc("javax.management.ObjectName", scSerializable|scWriteMethod, nil, nil)
+		s.str(name).WriteByte(tcEndBlockData)
+	}
+	s.WriteByte(tcEndBlockData)
+	return s
+}
+
+func (s *javaStream) exception(class string, message string) *javaStream {
+	s.WriteByte(tcObject)
+	s.classDesc(class, scSerializable, []javaField{{typeCode: 'L', name: "detailMessage", className: "Ljava/lang/String;"}}, nil)
+	return s.str(message)
+}
+
+// jmxServer is a JMX agent serving the RMI registry, the RMIServer and the RMIConnection on one port
+type jmxServer struct {
+	suite       *JmxTestSuite
+	listener    net.Listener
+	attributes  map[string]func(s *javaStream)
+	names       []string
+	credentials []interface{}
+	closed      bool
+}
+
+func (suite *JmxTestSuite) startServer(attributes map[string]func(s *javaStream), names []string) *jmxServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.NoError(err)
+	server := &jmxServer{suite: suite, listener: listener, attributes: attributes, names: names}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *jmxServer) port() int32 {
+	return int32(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *jmxServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	in := bufio.NewReader(conn)
+	r := newJavaObjectReader(in)
+	header, err := r.readBytes(7)
+	s.suite.NoError(err)
+	s.suite.Equal([]byte{'J', 'R', 'M', 'I', 0, 2, jrmpStreamProtocol}, header)
+	var ack javaStream
+	ack.write(byte(jrmpProtocolAck)).utf("127.0.0.1").write(int32(12345))
+	_, _ = conn.Write(ack.Bytes())
+	_, err = r.readUTF()
+	s.suite.NoError(err)
+	_, err = r.readInt32()
+	s.suite.NoError(err)
+
+	for {
+		msg, err := in.ReadByte()
+		if err != nil {
+			return
+		}
+		s.suite.Equal(byte(jrmpCall), msg)
+		r := newJavaObjectReader(in)
+		s.suite.NoError(r.readStreamHeader())
+		content, err := r.readContent()
+		s.suite.NoError(err)
+		call := bytes.NewReader(content.([]byte))
+		var objNum, hash int64
+		var op int32
+		var rest [14]byte
+		s.suite.NoError(binary.Read(call, binary.BigEndian, &objNum))
+		s.suite.NoError(binary.Read(call, binary.BigEndian, &rest))
+		s.suite.NoError(binary.Read(call, binary.BigEndian, &op))
+		s.suite.NoError(binary.Read(call, binary.BigEndian, &hash))
+
+		var result javaStream
+		hasResult := true
+		switch {
+		case objNum == 0 && op == registryLookupOp && hash == registryInterfaceHash:
+			name, err := r.readObject()
+			s.suite.NoError(err)
+			s.suite.Equal(jmxRegistryName, name)
+			result.stub("javax.management.remote.rmi.RMIServerImpl_Stub", s.port(), 10)
+		case objNum == 10 && hash == rmiNewClientHash:
+			credentials, err := r.readObject()
+			s.suite.NoError(err)
+			s.credentials = credentials.(*javaArray).values
+			result.stub("javax.management.remote.rmi.RMIConnectionImpl_Stub", s.port(), 11)
+		case objNum == 11 && hash == rmiGetAttributeHash:
+			name, err := r.readObject()
+			s.suite.NoError(err)
+			attr, err := r.readObject()
+			s.suite.NoError(err)
+			_, err = r.readObject()
+			s.suite.NoError(err)
+			key := name.(*javaObject).annotation("javax.management.ObjectName")[0].(string) + "/" + attr.(string)
+			if value, ok := s.attributes[key]; ok {
+				value(&result)
+			} else {
+				result.exception("javax.management.AttributeNotFoundException", "No such attribute: "+attr.(string))
+				s.respond(conn, jrmpExceptionalReturn, &result)
+				continue
+			}
+		case objNum == 11 && hash == rmiQueryNamesHash:
+			for i := 0; i < 3; i++ {
+				_, err := r.readObject()
+				s.suite.NoError(err)
+			}
+			result.nameSet(s.names...)
+		case objNum == 11 && hash == rmiCloseHash:
+			s.closed = true
+			hasResult = false
+		default:
+			s.suite.Fail("Unexpected RMI call", "object %v, operation %v, hash %v", objNum, op, hash)
+			return
+		}
+		if hasResult {
+			s.respond(conn, jrmpNormalReturn, &result)
+		} else {
+			s.respond(conn, jrmpNormalReturn, nil)
+		}
+	}
+}
+
+func (s *jmxServer) respond(conn net.Conn, returnType byte, result *javaStream) {
+	var response javaStream
+	response.write(byte(jrmpReturn), uint16(javaStreamMagic), uint16(javaStreamVersion))
+	response.block(func(s *javaStream) {
+		s.write(returnType, int32(1), int64(2), int16(3))
+	})
+	if result != nil {
+		response.Write(result.Bytes())
+	}
+	_, err := conn.Write(response.Bytes())
+	s.suite.NoError(err)
+}
+
+func (suite *JmxTestSuite) TestMethodHashes() {
+	// Hashes used by the stubs generated by rmic for RMIServer and RMIConnection
+	suite.Equal(int64(-1089742558549201240), rmiNewClientHash)
+	suite.Equal(int64(-4742752445160157748), rmiCloseHash)
+}
+
+func (suite *JmxTestSuite) TestCollector() {
+	server := suite.startServer(map[string]func(s *javaStream){
+		"java.lang:type=Memory/HeapMemoryUsage": func(s *javaStream) {
+			s.composite(map[string]int64{"used": 100, "max": 200})
+		},
+		"java.lang:type=GarbageCollector,name=G1 Young Generation/CollectionCount": func(s *javaStream) {
+			s.long(5)
+		},
+		"java.lang:type=GarbageCollector,name=G1 Old Generation/CollectionCount": func(s *javaStream) {
+			s.long(1)
+		},
+	}, []string{
+		"java.lang:type=GarbageCollector,name=G1 Young Generation",
+		"java.lang:type=GarbageCollector,name=G1 Old Generation",
+	})
+	defer func() {
+		_ = server.listener.Close()
+	}()
+
+	var attributes []JmxAttributes
+	for _, spec := range []string{
+		"java.lang:type=Memory/HeapMemoryUsage",
+		"java.lang:type=GarbageCollector,name=*/CollectionCount",
+		"java.lang:type=Threading/ThreadCount",
+	} {
+		attr, err := ParseJmxAttributes(spec)
+		suite.NoError(err)
+		attributes = append(attributes, attr)
+	}
+	endpoint, err := ParseJmxEndpoint("app=user:secret@127.0.0.1:" + strconv.Itoa(int(server.port())))
+	suite.NoError(err)
+	col := NewJmxCollector([]JmxEndpoint{endpoint}, attributes, &collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+	suite.Equal([]interface{}{"user", "secret"}, server.credentials)
+
+	metrics := col.Metrics()
+	suite.Len(metrics, 4)
+	suite.Equal(100.0, float64(metrics["jmx/app/java.lang/Memory/HeapMemoryUsage/used"]()))
+	suite.Equal(200.0, float64(metrics["jmx/app/java.lang/Memory/HeapMemoryUsage/max"]()))
+	suite.Contains(metrics, "jmx/app/java.lang/GarbageCollector/G1_Young_Generation/CollectionCount")
+	suite.Contains(metrics, "jmx/app/java.lang/GarbageCollector/G1_Old_Generation/CollectionCount")
+	suite.Contains(col.counters, "jmx/app/java.lang/GarbageCollector/G1_Old_Generation/CollectionCount")
+
+	// The metrics do not change, and the connection is reused
+	suite.NoError(col.Update(context.Background()))
+	col.ReconnectInterval = 0
+	suite.NoError(col.Update(context.Background()))
+	suite.True(server.closed, "The expired JMX connection was not closed")
+	col.closeConnections()
+}
+
+func (suite *JmxTestSuite) TestParseEndpoint() {
+	for _, test := range []struct {
+		spec     string
+		expected JmxEndpoint
+		err      bool
+	}{
+		{"host:9010", JmxEndpoint{Name: "host", Addr: "host:9010"}, false},
+		{"app=host:9010", JmxEndpoint{Name: "app", Addr: "host:9010"}, false},
+		{"app=user:pass@word@host:9010", JmxEndpoint{Name: "app", Addr: "host:9010", User: "user", Password: "pass@word"}, false},
+		{"=host:9010", JmxEndpoint{}, true},
+		{"app=host", JmxEndpoint{}, true},
+		{"app=user@host:9010", JmxEndpoint{}, true},
+	} {
+		endpoint, err := ParseJmxEndpoint(test.spec)
+		if test.err {
+			suite.Error(err, test.spec)
+		} else {
+			suite.NoError(err, test.spec)
+			suite.Equal(test.expected, endpoint)
+		}
+	}
+}
+
+func (suite *JmxTestSuite) TestMetricPath() {
+	for _, test := range []struct {
+		objectName string
+		expected   string
+	}{
+		{"java.lang:type=Memory", "java.lang/Memory"},
+		{"java.lang:type=GarbageCollector,name=G1 Young Generation", "java.lang/GarbageCollector/G1_Young_Generation"},
+		{`app:type=Cache,name="a,b"`, "app/Cache/a,b"},
+		{"app:path=/var/lib", "app/_var_lib"},
+	} {
+		suite.Equal(test.expected, jmxMetricPath(test.objectName))
+	}
+}
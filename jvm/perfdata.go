@@ -0,0 +1,94 @@
+package jvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+)
+
+// Format of the hsperfdata files, see src/hotspot/share/runtime/perfMemory.hpp and perfData.hpp in the OpenJDK sources
+const (
+	perfDataMagic      = 0xcafec0c0
+	perfDataPrologue   = 32 // Size of the file header
+	perfDataEntrySize  = 20 // Size of the fixed part of every entry header
+	perfDataTypeLong   = 'J'
+	perfDataTypeByte   = 'B'
+	perfDataUnitsTicks = 3
+
+	perfDataVariabilityMonotonic = 2
+)
+
+type perfCounter struct {
+	value     int64
+	str       string
+	isString  bool
+	ticks     bool
+	monotonic bool
+}
+
+// readPerfData parses the instrumentation counters that a HotSpot JVM exports through its hsperfdata file
+func readPerfData(path string) (map[string]perfCounter, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < perfDataPrologue || binary.BigEndian.Uint32(data) != perfDataMagic {
+		return nil, fmt.Errorf("%v is not a valid hsperfdata file", path)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if data[4] == 1 {
+		order = binary.LittleEndian
+	}
+	if major := data[5]; major != 2 {
+		return nil, fmt.Errorf("%v: unsupported hsperfdata version %v", path, major)
+	}
+	if data[7] == 0 {
+		return nil, fmt.Errorf("%v: the JVM has not finished initializing the hsperfdata file", path)
+	}
+	offset := int(int32(order.Uint32(data[24:])))
+	numEntries := int(int32(order.Uint32(data[28:])))
+
+	res := make(map[string]perfCounter, numEntries)
+	for i := 0; i < numEntries; i++ {
+		if offset < 0 || offset+perfDataEntrySize > len(data) {
+			return nil, fmt.Errorf("%v: truncated hsperfdata entry %v", path, i)
+		}
+		entry := data[offset:]
+		length := int(int32(order.Uint32(entry)))
+		nameOffset := int(int32(order.Uint32(entry[4:])))
+		vectorLength := int(int32(order.Uint32(entry[8:])))
+		dataType, units, variability := entry[12], entry[14], entry[15]
+		dataOffset := int(int32(order.Uint32(entry[16:])))
+		// The name and data follow the fixed-size entry header
+		if length < perfDataEntrySize || length > len(entry) ||
+			nameOffset < perfDataEntrySize || nameOffset >= length ||
+			dataOffset < perfDataEntrySize || dataOffset >= length {
+			return nil, fmt.Errorf("%v: invalid hsperfdata entry %v", path, i)
+		}
+		entry = entry[:length]
+		name := entry[nameOffset:]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+
+		counter := perfCounter{
+			ticks:     units == perfDataUnitsTicks,
+			monotonic: variability == perfDataVariabilityMonotonic,
+		}
+		switch {
+		case dataType == perfDataTypeLong && vectorLength == 0 && dataOffset+8 <= length:
+			counter.value = int64(order.Uint64(entry[dataOffset:]))
+			res[string(name)] = counter
+		case dataType == perfDataTypeByte && vectorLength > 0:
+			str := entry[dataOffset:]
+			if end := bytes.IndexByte(str, 0); end >= 0 {
+				str = str[:end]
+			}
+			counter.str, counter.isString = string(str), true
+			res[string(name)] = counter
+		}
+		offset += length
+	}
+	return res, nil
+}
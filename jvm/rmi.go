@@ -0,0 +1,425 @@
+package jvm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Constants of the Java RMI wire protocol (JRMP), see sun.rmi.transport.TransportConstants
+const (
+	jrmpMagic             = 0x4a524d49 // "JRMI"
+	jrmpVersion           = 2
+	jrmpStreamProtocol    = 0x4b
+	jrmpProtocolAck       = 0x4e
+	jrmpCall              = 0x50
+	jrmpReturn            = 0x51
+	jrmpNormalReturn      = 1
+	jrmpExceptionalReturn = 2
+
+	// Calls through stubs generated for the 1.2 stub protocol use the operation number -1 and the method hash
+	rmiMethodOp = -1
+
+	// RegistryImpl_Stub uses the 1.1 stub protocol with operation numbers and an interface hash
+	registryLookupOp      = 2
+	registryInterfaceHash = 4905912898345647071
+
+	// Name of the RMIServer of the JMX agent in the RMI registry
+	jmxRegistryName = "jmxrmi"
+)
+
+// Method signatures of the javax.management.remote.rmi.RMIServer and RMIConnection interfaces
+var (
+	rmiNewClientHash    = rmiMethodHash("newClient(Ljava/lang/Object;)Ljavax/management/remote/rmi/RMIConnection;")
+	rmiGetAttributeHash = rmiMethodHash("getAttribute(Ljavax/management/ObjectName;Ljava/lang/String;Ljavax/security/auth/Subject;)Ljava/lang/Object;")
+	rmiQueryNamesHash   = rmiMethodHash("queryNames(Ljavax/management/ObjectName;Ljava/rmi/MarshalledObject;Ljavax/security/auth/Subject;)Ljava/util/Set;")
+	rmiCloseHash        = rmiMethodHash("close()V")
+)
+
+// rmiMethodHash computes the hash identifying a remote method, see sun.rmi.server.Util.computeMethodHash()
+func rmiMethodHash(signature string) int64 {
+	var buf bytes.Buffer
+	data := encodeModifiedUTF8(signature)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(data)))
+	buf.Write(data)
+	sum := sha1.Sum(buf.Bytes())
+	var hash int64
+	for i := 0; i < 8; i++ {
+		hash += int64(sum[i]) << uint(8*i)
+	}
+	return hash
+}
+
+// rmiObjID identifies a remote object within a JVM, see java.rmi.server.ObjID
+type rmiObjID struct {
+	objNum int64
+	unique int32
+	time   int64
+	count  int16
+}
+
+var rmiRegistryID = rmiObjID{}
+
+// rmiRef is the endpoint and identifier of a remote object, as contained in a serialized stub
+type rmiRef struct {
+	host string
+	port int
+	id   rmiObjID
+}
+
+// rmiRemoteException is an exception thrown by a remote method
+type rmiRemoteException struct {
+	class   string
+	message string
+}
+
+func (e *rmiRemoteException) Error() string {
+	if e.message == "" {
+		return e.class
+	}
+	return e.class + ": " + e.message
+}
+
+// rmiConn is a JRMP connection using the stream protocol, which allows sequential calls over one TCP connection
+type rmiConn struct {
+	conn    net.Conn
+	in      *bufio.Reader
+	timeout time.Duration
+}
+
+func dialRmi(addr string, timeout time.Duration) (*rmiConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &rmiConn{
+		conn:    conn,
+		in:      bufio.NewReader(conn),
+		timeout: timeout,
+	}
+	if err := c.handshake(); err != nil {
+		c.close()
+		return nil, fmt.Errorf("RMI handshake with %v failed: %v", addr, err)
+	}
+	return c, nil
+}
+
+func (c *rmiConn) handshake() error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	var header bytes.Buffer
+	_ = binary.Write(&header, binary.BigEndian, uint32(jrmpMagic))
+	_ = binary.Write(&header, binary.BigEndian, uint16(jrmpVersion))
+	header.WriteByte(jrmpStreamProtocol)
+	if _, err := c.conn.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	// The server acknowledges with the address of the client as seen by the server
+	r := newJavaObjectReader(c.in)
+	ack, err := r.readByte()
+	if err != nil {
+		return err
+	}
+	if ack != jrmpProtocolAck {
+		return fmt.Errorf("Unexpected protocol acknowledgement %x", ack)
+	}
+	host, err := r.readUTF()
+	if err != nil {
+		return err
+	}
+	if _, err := r.readInt32(); err != nil {
+		return err
+	}
+
+	// Send the client endpoint, which is not used by the server
+	var endpoint bytes.Buffer
+	data := encodeModifiedUTF8(host)
+	_ = binary.Write(&endpoint, binary.BigEndian, uint16(len(data)))
+	endpoint.Write(data)
+	_ = binary.Write(&endpoint, binary.BigEndian, int32(0))
+	_, err = c.conn.Write(endpoint.Bytes())
+	return err
+}
+
+func (c *rmiConn) close() {
+	if err := c.conn.Close(); err != nil {
+		log.Debugln("Error closing RMI connection:", err)
+	}
+}
+
+// call invokes a remote method and returns its result, if hasResult is set. The arguments are written by writeArgs.
+func (c *rmiConn) call(id rmiObjID, op int32, hash int64, writeArgs func(w *javaObjectWriter), hasResult bool) (interface{}, error) {
+	w := newJavaObjectWriter()
+	w.writeInt64(id.objNum)
+	w.writeInt32(id.unique)
+	w.writeInt64(id.time)
+	w.writeInt16(id.count)
+	w.writeInt32(op)
+	w.writeInt64(hash)
+	if writeArgs != nil {
+		writeArgs(w)
+	}
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(append([]byte{jrmpCall}, w.bytes()...)); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.in.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if msg != jrmpReturn {
+		return nil, fmt.Errorf("Unexpected RMI message type %x", msg)
+	}
+	r := newJavaObjectReader(c.in)
+	if err := r.readStreamHeader(); err != nil {
+		return nil, err
+	}
+	// The return header contains the return type and the UID used for acknowledging received remote references
+	header, err := r.readContent()
+	if err != nil {
+		return nil, err
+	}
+	headerData, ok := header.([]byte)
+	if !ok || len(headerData) < 1 {
+		return nil, fmt.Errorf("Invalid RMI return header")
+	}
+	switch headerData[0] {
+	case jrmpNormalReturn:
+		if !hasResult {
+			return nil, nil
+		}
+		return r.readObject()
+	case jrmpExceptionalReturn:
+		exception, err := r.readObject()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read exception of remote method: %v", err)
+		}
+		return nil, newRmiRemoteException(exception)
+	default:
+		return nil, fmt.Errorf("Invalid RMI return type %v", headerData[0])
+	}
+}
+
+func newRmiRemoteException(exception interface{}) error {
+	obj, ok := exception.(*javaObject)
+	if !ok {
+		return fmt.Errorf("Remote method threw an invalid exception: %v", exception)
+	}
+	err := &rmiRemoteException{class: obj.class.name}
+	if msg, ok := obj.field("detailMessage"); ok {
+		err.message, _ = msg.(string)
+	}
+	if cause, ok := obj.field("cause"); ok && cause != nil && cause != exception {
+		if causeErr := newRmiRemoteException(cause); causeErr != nil {
+			err.message += " (caused by " + causeErr.Error() + ")"
+		}
+	}
+	return err
+}
+
+// findRemoteRef returns the remote reference contained in a deserialized stub, or in a dynamic proxy with
+// a RemoteObjectInvocationHandler.
+func findRemoteRef(stub interface{}) (*rmiRef, error) {
+	visited := make(map[*javaObject]bool)
+	var find func(val interface{}) *javaObject
+	find = func(val interface{}) *javaObject {
+		obj, ok := val.(*javaObject)
+		if !ok || visited[obj] {
+			return nil
+		}
+		visited[obj] = true
+		if obj.class.isSubclassOf("java.rmi.server.RemoteObject") {
+			return obj
+		}
+		for _, data := range obj.data {
+			for _, field := range data.fields {
+				if res := find(field); res != nil {
+					return res
+				}
+			}
+		}
+		return nil
+	}
+	obj := find(stub)
+	if obj == nil {
+		return nil, fmt.Errorf("The remote object does not contain an RMI stub")
+	}
+	return parseRemoteRef(obj.annotation("java.rmi.server.RemoteObject"))
+}
+
+// parseRemoteRef parses the data written by RemoteObject.writeObject(), which contains the class name of the
+// reference and the data written by UnicastRef.writeExternal(), see sun.rmi.transport.LiveRef.write().
+func parseRemoteRef(annotation []interface{}) (*rmiRef, error) {
+	var data bytes.Buffer
+	for _, content := range annotation {
+		switch content := content.(type) {
+		case []byte:
+			data.Write(content)
+		case nil:
+		default:
+			// Only present when a custom socket factory is used, which also writes the object
+			if obj, ok := content.(*javaObject); ok {
+				return nil, fmt.Errorf("RMI connections through the socket factory %v (e.g. SSL) are not supported", obj.class.name)
+			}
+		}
+	}
+	r := newJavaObjectReader(bufio.NewReader(&data))
+	refClass, err := r.readUTF()
+	if err != nil {
+		return nil, err
+	}
+	if refClass == "UnicastRef2" {
+		format, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if format != 0 && format != 1 {
+			return nil, fmt.Errorf("Unknown RMI endpoint format %v", format)
+		}
+	} else if refClass != "UnicastRef" {
+		return nil, fmt.Errorf("Unsupported RMI reference type '%v'", refClass)
+	}
+	ref := new(rmiRef)
+	if ref.host, err = r.readUTF(); err != nil {
+		return nil, err
+	}
+	port, err := r.readInt32()
+	if err != nil {
+		return nil, err
+	}
+	ref.port = int(port)
+	if ref.id.objNum, err = r.readInt64(); err != nil {
+		return nil, err
+	}
+	if ref.id.unique, err = r.readInt32(); err != nil {
+		return nil, err
+	}
+	if ref.id.time, err = r.readInt64(); err != nil {
+		return nil, err
+	}
+	count, err := r.readUint16()
+	ref.id.count = int16(count)
+	return ref, err
+}
+
+// jmxConnection is a client connection to the JMX agent of a JVM, established through the RMI registry.
+// The RMIServer and RMIConnection objects are accessed through the configured host, since the host names in the
+// stubs (java.rmi.server.hostname) are often not reachable from the outside, e.g. for JVMs in containers.
+type jmxConnection struct {
+	conn       *rmiConn
+	connection rmiObjID
+	created    time.Time
+}
+
+// connectJmx looks up the JMX RMIServer in the RMI registry at the given address and creates a new RMIConnection.
+// The credentials are omitted if the username is empty.
+func connectJmx(addr string, username, password string, timeout time.Duration) (*jmxConnection, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := dialRmi(addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	stub, err := registry.call(rmiRegistryID, registryLookupOp, registryInterfaceHash, func(w *javaObjectWriter) {
+		w.writeString(jmxRegistryName)
+	}, true)
+	registry.close()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to look up %v in the RMI registry at %v: %v", jmxRegistryName, addr, err)
+	}
+	server, err := findRemoteRef(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	serverAddr := net.JoinHostPort(host, strconv.Itoa(server.port))
+	conn, err := dialRmi(serverAddr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	result, err := conn.call(server.id, rmiMethodOp, rmiNewClientHash, func(w *javaObjectWriter) {
+		if username == "" {
+			w.writeNull()
+		} else {
+			w.writeStringArray([]string{username, password})
+		}
+	}, true)
+	if err != nil {
+		conn.close()
+		return nil, fmt.Errorf("Failed to create JMX connection to %v: %v", serverAddr, err)
+	}
+	connection, err := findRemoteRef(result)
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+	if connection.port != server.port {
+		// The RMIConnection is usually exported on the same port as the RMIServer
+		conn.close()
+		if conn, err = dialRmi(net.JoinHostPort(host, strconv.Itoa(connection.port)), timeout); err != nil {
+			return nil, err
+		}
+	}
+	return &jmxConnection{
+		conn:       conn,
+		connection: connection.id,
+		created:    time.Now(),
+	}, nil
+}
+
+func (c *jmxConnection) getAttribute(objectName string, attribute string) (interface{}, error) {
+	return c.conn.call(c.connection, rmiMethodOp, rmiGetAttributeHash, func(w *javaObjectWriter) {
+		w.writeObjectName(objectName)
+		w.writeString(attribute)
+		w.writeNull()
+	}, true)
+}
+
+// queryNames returns the names of all MBeans matching the given ObjectName pattern
+func (c *jmxConnection) queryNames(pattern string) ([]string, error) {
+	result, err := c.conn.call(c.connection, rmiMethodOp, rmiQueryNamesHash, func(w *javaObjectWriter) {
+		w.writeObjectName(pattern)
+		w.writeNull()
+		w.writeNull()
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	set, ok := result.(*javaObject)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected result of queryNames(): %T", result)
+	}
+	// The elements of the HashSet are written by its writeObject() method
+	var names []string
+	for _, element := range set.annotation("java.util.HashSet") {
+		if name, ok := element.(*javaObject); ok && name.class.name == "javax.management.ObjectName" {
+			for _, content := range name.annotation("javax.management.ObjectName") {
+				if str, ok := content.(string); ok {
+					names = append(names, str)
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+func (c *jmxConnection) close() {
+	if _, err := c.conn.call(c.connection, rmiMethodOp, rmiCloseHash, nil, false); err != nil {
+		log.Debugln("Failed to close JMX connection:", err)
+	}
+	c.conn.close()
+}
@@ -0,0 +1,680 @@
+package jvm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// Constants of the Java Object Serialization Stream Protocol, see java.io.ObjectStreamConstants
+const (
+	javaStreamMagic   = 0xaced
+	javaStreamVersion = 5
+
+	tcNull           = 0x70
+	tcReference      = 0x71
+	tcClassDesc      = 0x72
+	tcObject         = 0x73
+	tcString         = 0x74
+	tcArray          = 0x75
+	tcClass          = 0x76
+	tcBlockData      = 0x77
+	tcEndBlockData   = 0x78
+	tcReset          = 0x79
+	tcBlockDataLong  = 0x7a
+	tcException      = 0x7b
+	tcLongString     = 0x7c
+	tcProxyClassDesc = 0x7d
+	tcEnum           = 0x7e
+
+	javaBaseHandle = 0x7e0000
+
+	scWriteMethod    = 0x01
+	scSerializable   = 0x02
+	scExternalizable = 0x04
+	scBlockData      = 0x08
+
+	// Limits protecting against corrupt streams
+	maxJavaArrayLength = 1 << 20
+	maxJavaObjectDepth = 256
+)
+
+// javaClassDesc is a deserialized class descriptor. For proxy classes, the name is empty.
+type javaClassDesc struct {
+	name       string
+	suid       int64
+	flags      byte
+	fields     []javaField
+	interfaces []string
+	super      *javaClassDesc
+}
+
+type javaField struct {
+	typeCode  byte
+	name      string
+	className string
+}
+
+// hierarchy returns the class descriptors from the topmost superclass down to this class
+func (desc *javaClassDesc) hierarchy() []*javaClassDesc {
+	var res []*javaClassDesc
+	for c := desc; c != nil; c = c.super {
+		res = append([]*javaClassDesc{c}, res...)
+	}
+	return res
+}
+
+func (desc *javaClassDesc) isSubclassOf(name string) bool {
+	for c := desc; c != nil; c = c.super {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// javaObject is a deserialized instance of a serializable class. The fields and the data written by writeObject()
+// methods are stored for every class in the hierarchy.
+type javaObject struct {
+	class *javaClassDesc
+	data  []javaClassData
+}
+
+type javaClassData struct {
+	class  *javaClassDesc
+	fields map[string]interface{}
+
+	// Block data ([]byte) and objects written by the writeObject() method of the class
+	annotation []interface{}
+}
+
+// field returns the value of the given field, searching from the most specific class upwards
+func (obj *javaObject) field(name string) (interface{}, bool) {
+	for i := len(obj.data) - 1; i >= 0; i-- {
+		if val, ok := obj.data[i].fields[name]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// annotation returns the data written by the writeObject() method of the given class
+func (obj *javaObject) annotation(className string) []interface{} {
+	for _, data := range obj.data {
+		if data.class.name == className {
+			return data.annotation
+		}
+	}
+	return nil
+}
+
+type javaArray struct {
+	class  *javaClassDesc
+	values []interface{}
+}
+
+type javaEnum struct {
+	class *javaClassDesc
+	name  string
+}
+
+// javaObjectReader deserializes a Java object stream. Primitive values are returned as Go values (int64 for all
+// integer types, float64 for float and double, bool, uint16 for char), strings as string, block data as []byte.
+type javaObjectReader struct {
+	in      *bufio.Reader
+	handles []interface{}
+	depth   int
+}
+
+func newJavaObjectReader(in *bufio.Reader) *javaObjectReader {
+	return &javaObjectReader{in: in}
+}
+
+func (r *javaObjectReader) readStreamHeader() error {
+	var header [4]byte
+	if _, err := io.ReadFull(r.in, header[:]); err != nil {
+		return err
+	}
+	if magic, version := binary.BigEndian.Uint16(header[:]), binary.BigEndian.Uint16(header[2:]); magic != javaStreamMagic || version != javaStreamVersion {
+		return fmt.Errorf("Invalid Java object stream header %x", header)
+	}
+	r.handles = r.handles[:0]
+	return nil
+}
+
+func (r *javaObjectReader) readByte() (byte, error) {
+	return r.in.ReadByte()
+}
+
+func (r *javaObjectReader) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > maxJavaArrayLength {
+		return nil, fmt.Errorf("Invalid length %v in Java object stream", n)
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r.in, buf)
+	return buf, err
+}
+
+func (r *javaObjectReader) readUint16() (uint16, error) {
+	buf, err := r.readBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(buf), nil
+}
+
+func (r *javaObjectReader) readInt32() (int32, error) {
+	buf, err := r.readBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(buf)), nil
+}
+
+func (r *javaObjectReader) readInt64() (int64, error) {
+	buf, err := r.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
+
+func (r *javaObjectReader) readUTF() (string, error) {
+	length, err := r.readUint16()
+	if err != nil {
+		return "", err
+	}
+	buf, err := r.readBytes(int(length))
+	if err != nil {
+		return "", err
+	}
+	return decodeModifiedUTF8(buf), nil
+}
+
+func (r *javaObjectReader) newHandle(obj interface{}) int {
+	r.handles = append(r.handles, obj)
+	return len(r.handles) - 1
+}
+
+func (r *javaObjectReader) setHandle(handle int, obj interface{}) {
+	r.handles[handle] = obj
+}
+
+// readContent reads the next object or block data from the stream. Block data is returned as []byte.
+func (r *javaObjectReader) readContent() (interface{}, error) {
+	tc, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tc {
+	case tcBlockData:
+		length, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return r.readBytes(int(length))
+	case tcBlockDataLong:
+		length, err := r.readInt32()
+		if err != nil {
+			return nil, err
+		}
+		return r.readBytes(int(length))
+	default:
+		return r.readObjectTc(tc)
+	}
+}
+
+// readObject reads the next object from the stream and fails if block data is encountered.
+func (r *javaObjectReader) readObject() (interface{}, error) {
+	tc, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	return r.readObjectTc(tc)
+}
+
+func (r *javaObjectReader) readObjectTc(tc byte) (interface{}, error) {
+	r.depth++
+	defer func() {
+		r.depth--
+	}()
+	if r.depth > maxJavaObjectDepth {
+		return nil, fmt.Errorf("Java object stream exceeds the maximum nesting depth of %v", maxJavaObjectDepth)
+	}
+	switch tc {
+	case tcNull:
+		return nil, nil
+	case tcReference:
+		handle, err := r.readInt32()
+		if err != nil {
+			return nil, err
+		}
+		index := int(handle) - javaBaseHandle
+		if index < 0 || index >= len(r.handles) {
+			return nil, fmt.Errorf("Invalid handle %x in Java object stream", handle)
+		}
+		return r.handles[index], nil
+	case tcClassDesc, tcProxyClassDesc:
+		return r.readClassDescTc(tc)
+	case tcObject:
+		return r.readNewObject()
+	case tcString:
+		length, err := r.readUint16()
+		if err != nil {
+			return nil, err
+		}
+		return r.readNewString(int(length))
+	case tcLongString:
+		length, err := r.readInt64()
+		if err != nil {
+			return nil, err
+		}
+		if length > maxJavaArrayLength {
+			return nil, fmt.Errorf("Invalid string length %v in Java object stream", length)
+		}
+		return r.readNewString(int(length))
+	case tcArray:
+		return r.readNewArray()
+	case tcEnum:
+		return r.readNewEnum()
+	case tcClass:
+		desc, err := r.readClassDesc()
+		if err != nil {
+			return nil, err
+		}
+		r.newHandle(desc)
+		return desc, nil
+	case tcReset:
+		r.handles = r.handles[:0]
+		return r.readObject()
+	case tcException:
+		return nil, fmt.Errorf("The Java object stream was aborted by an exception during serialization")
+	default:
+		return nil, fmt.Errorf("Unexpected type code %x in Java object stream", tc)
+	}
+}
+
+func (r *javaObjectReader) readNewString(length int) (interface{}, error) {
+	buf, err := r.readBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	str := decodeModifiedUTF8(buf)
+	r.newHandle(str)
+	return str, nil
+}
+
+// readClassDesc reads a class descriptor, a reference to one, or null
+func (r *javaObjectReader) readClassDesc() (*javaClassDesc, error) {
+	tc, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tc {
+	case tcNull:
+		return nil, nil
+	case tcReference:
+		obj, err := r.readObjectTc(tc)
+		if err != nil {
+			return nil, err
+		}
+		desc, ok := obj.(*javaClassDesc)
+		if !ok {
+			return nil, fmt.Errorf("Expected a class descriptor in Java object stream, got %T", obj)
+		}
+		return desc, nil
+	case tcClassDesc, tcProxyClassDesc:
+		return r.readClassDescTc(tc)
+	default:
+		return nil, fmt.Errorf("Expected a class descriptor in Java object stream, got type code %x", tc)
+	}
+}
+
+func (r *javaObjectReader) readClassDescTc(tc byte) (*javaClassDesc, error) {
+	desc := new(javaClassDesc)
+	if tc == tcProxyClassDesc {
+		r.newHandle(desc)
+		count, err := r.readInt32()
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 || count > 0xffff {
+			return nil, fmt.Errorf("Invalid number of proxy interfaces %v in Java object stream", count)
+		}
+		for i := 0; i < int(count); i++ {
+			name, err := r.readUTF()
+			if err != nil {
+				return nil, err
+			}
+			desc.interfaces = append(desc.interfaces, name)
+		}
+		desc.flags = scSerializable
+	} else {
+		var err error
+		if desc.name, err = r.readUTF(); err != nil {
+			return nil, err
+		}
+		if desc.suid, err = r.readInt64(); err != nil {
+			return nil, err
+		}
+		r.newHandle(desc)
+		if desc.flags, err = r.readByte(); err != nil {
+			return nil, err
+		}
+		numFields, err := r.readUint16()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(numFields); i++ {
+			var field javaField
+			if field.typeCode, err = r.readByte(); err != nil {
+				return nil, err
+			}
+			if field.name, err = r.readUTF(); err != nil {
+				return nil, err
+			}
+			if field.typeCode == '[' || field.typeCode == 'L' {
+				className, err := r.readObject()
+				if err != nil {
+					return nil, err
+				}
+				if field.className, _ = className.(string); field.className == "" {
+					return nil, fmt.Errorf("Invalid class name of field %v.%v in Java object stream", desc.name, field.name)
+				}
+			}
+			desc.fields = append(desc.fields, field)
+		}
+	}
+	// Class annotations, e.g. the codebase written by RMI
+	if _, err := r.readAnnotation(); err != nil {
+		return nil, err
+	}
+	var err error
+	desc.super, err = r.readClassDesc()
+	return desc, err
+}
+
+// readAnnotation reads objects and block data until the end of the block data
+func (r *javaObjectReader) readAnnotation() ([]interface{}, error) {
+	var res []interface{}
+	for {
+		tc, err := r.in.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if tc[0] == tcEndBlockData {
+			_, _ = r.in.ReadByte()
+			return res, nil
+		}
+		content, err := r.readContent()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, content)
+	}
+}
+
+func (r *javaObjectReader) readNewObject() (interface{}, error) {
+	desc, err := r.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil {
+		return nil, fmt.Errorf("Missing class descriptor of object in Java object stream")
+	}
+	obj := &javaObject{class: desc}
+	handle := r.newHandle(obj)
+	for _, class := range desc.hierarchy() {
+		data := javaClassData{class: class, fields: make(map[string]interface{}, len(class.fields))}
+		switch {
+		case class.flags&scExternalizable != 0:
+			if class.flags&scBlockData == 0 {
+				return nil, fmt.Errorf("Cannot read externalizable class %v written with the old protocol", class.name)
+			}
+			if data.annotation, err = r.readAnnotation(); err != nil {
+				return nil, err
+			}
+		case class.flags&scSerializable != 0:
+			for _, field := range class.fields {
+				if data.fields[field.name], err = r.readFieldValue(field.typeCode); err != nil {
+					return nil, err
+				}
+			}
+			if class.flags&scWriteMethod != 0 {
+				if data.annotation, err = r.readAnnotation(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		obj.data = append(obj.data, data)
+	}
+	r.setHandle(handle, obj)
+	return obj, nil
+}
+
+func (r *javaObjectReader) readFieldValue(typeCode byte) (interface{}, error) {
+	switch typeCode {
+	case 'B':
+		b, err := r.readByte()
+		return int64(int8(b)), err
+	case 'C':
+		c, err := r.readUint16()
+		return c, err
+	case 'S':
+		s, err := r.readUint16()
+		return int64(int16(s)), err
+	case 'I':
+		i, err := r.readInt32()
+		return int64(i), err
+	case 'J':
+		return r.readInt64()
+	case 'F':
+		i, err := r.readInt32()
+		return float64(math.Float32frombits(uint32(i))), err
+	case 'D':
+		i, err := r.readInt64()
+		return math.Float64frombits(uint64(i)), err
+	case 'Z':
+		b, err := r.readByte()
+		return b != 0, err
+	case 'L', '[':
+		return r.readObject()
+	default:
+		return nil, fmt.Errorf("Invalid field type code %q in Java object stream", typeCode)
+	}
+}
+
+func (r *javaObjectReader) readNewArray() (interface{}, error) {
+	desc, err := r.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil || len(desc.name) < 2 || desc.name[0] != '[' {
+		return nil, fmt.Errorf("Invalid array class descriptor in Java object stream")
+	}
+	arr := &javaArray{class: desc}
+	handle := r.newHandle(arr)
+	length, err := r.readInt32()
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || length > maxJavaArrayLength {
+		return nil, fmt.Errorf("Invalid array length %v in Java object stream", length)
+	}
+	arr.values = make([]interface{}, length)
+	for i := range arr.values {
+		if arr.values[i], err = r.readFieldValue(desc.name[1]); err != nil {
+			return nil, err
+		}
+	}
+	r.setHandle(handle, arr)
+	return arr, nil
+}
+
+func (r *javaObjectReader) readNewEnum() (interface{}, error) {
+	desc, err := r.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	enum := &javaEnum{class: desc}
+	handle := r.newHandle(enum)
+	name, err := r.readObject()
+	if err != nil {
+		return nil, err
+	}
+	enum.name, _ = name.(string)
+	r.setHandle(handle, enum)
+	return enum, nil
+}
+
+// decodeModifiedUTF8 decodes the modified UTF-8 encoding used by DataInput.readUTF(), which encodes
+// supplementary characters as surrogate pairs and the null character with two bytes.
+func decodeModifiedUTF8(buf []byte) string {
+	chars := make([]uint16, 0, len(buf))
+	for i := 0; i < len(buf); {
+		b := buf[i]
+		switch {
+		case b < 0x80:
+			chars = append(chars, uint16(b))
+			i++
+		case b&0xe0 == 0xc0 && i+1 < len(buf):
+			chars = append(chars, uint16(b&0x1f)<<6|uint16(buf[i+1]&0x3f))
+			i += 2
+		case b&0xf0 == 0xe0 && i+2 < len(buf):
+			chars = append(chars, uint16(b&0x0f)<<12|uint16(buf[i+1]&0x3f)<<6|uint16(buf[i+2]&0x3f))
+			i += 3
+		default:
+			chars = append(chars, 0xfffd)
+			i++
+		}
+	}
+	return string(utf16.Decode(chars))
+}
+
+func encodeModifiedUTF8(str string) []byte {
+	var buf bytes.Buffer
+	for _, c := range utf16.Encode([]rune(str)) {
+		switch {
+		case c >= 1 && c < 0x80:
+			buf.WriteByte(byte(c))
+		case c < 0x800:
+			buf.WriteByte(byte(0xc0 | c>>6))
+			buf.WriteByte(byte(0x80 | c&0x3f))
+		default:
+			buf.WriteByte(byte(0xe0 | c>>12))
+			buf.WriteByte(byte(0x80 | (c>>6)&0x3f))
+			buf.WriteByte(byte(0x80 | c&0x3f))
+		}
+	}
+	return buf.Bytes()
+}
+
+// javaObjectWriter serializes the few object types required for invoking JMX methods through RMI.
+// Primitive values are buffered and written as block data before the next object.
+type javaObjectWriter struct {
+	out   bytes.Buffer
+	block bytes.Buffer
+}
+
+func newJavaObjectWriter() *javaObjectWriter {
+	w := new(javaObjectWriter)
+	_ = binary.Write(&w.out, binary.BigEndian, []uint16{javaStreamMagic, javaStreamVersion})
+	return w
+}
+
+func (w *javaObjectWriter) flushBlock() {
+	if w.block.Len() > 0 {
+		// Short block data records with up to 255 bytes each
+		data := w.block.Bytes()
+		for len(data) > 0 {
+			n := len(data)
+			if n > 255 {
+				n = 255
+			}
+			w.out.WriteByte(tcBlockData)
+			w.out.WriteByte(byte(n))
+			w.out.Write(data[:n])
+			data = data[n:]
+		}
+		w.block.Reset()
+	}
+}
+
+func (w *javaObjectWriter) writeInt32(val int32) {
+	_ = binary.Write(&w.block, binary.BigEndian, val)
+}
+
+func (w *javaObjectWriter) writeInt64(val int64) {
+	_ = binary.Write(&w.block, binary.BigEndian, val)
+}
+
+func (w *javaObjectWriter) writeInt16(val int16) {
+	_ = binary.Write(&w.block, binary.BigEndian, val)
+}
+
+func (w *javaObjectWriter) writeNull() {
+	w.flushBlock()
+	w.out.WriteByte(tcNull)
+}
+
+func (w *javaObjectWriter) writeString(str string) {
+	w.flushBlock()
+	data := encodeModifiedUTF8(str)
+	w.out.WriteByte(tcString)
+	_ = binary.Write(&w.out, binary.BigEndian, uint16(len(data)))
+	w.out.Write(data)
+}
+
+func (w *javaObjectWriter) writeUTF(str string) {
+	data := encodeModifiedUTF8(str)
+	_ = binary.Write(&w.out, binary.BigEndian, uint16(len(data)))
+	w.out.Write(data)
+}
+
+// writeClassDesc writes the descriptor of a class without fields and without superclass.
+// The class annotation contains a null codebase, as expected by the RMI MarshalInputStream.
+func (w *javaObjectWriter) writeClassDesc(name string, suid int64, flags byte) {
+	w.out.WriteByte(tcClassDesc)
+	w.writeUTF(name)
+	_ = binary.Write(&w.out, binary.BigEndian, suid)
+	w.out.WriteByte(flags)
+	_ = binary.Write(&w.out, binary.BigEndian, uint16(0))
+	w.out.WriteByte(tcNull)
+	w.out.WriteByte(tcEndBlockData)
+	w.out.WriteByte(tcNull)
+}
+
+// writeStringArray writes a java.lang.String[] array
+func (w *javaObjectWriter) writeStringArray(values []string) {
+	w.flushBlock()
+	w.out.WriteByte(tcArray)
+	w.writeClassDesc("[Ljava.lang.String;", stringArraySuid, scSerializable)
+	_ = binary.Write(&w.out, binary.BigEndian, int32(len(values)))
+	for _, val := range values {
+		w.writeString(val)
+	}
+}
+
+// writeObjectName writes a javax.management.ObjectName, which serializes itself through writeObject()
+// as its string representation.
+func (w *javaObjectWriter) writeObjectName(name string) {
+	w.flushBlock()
+	w.out.WriteByte(tcObject)
+	w.writeClassDesc("javax.management.ObjectName", objectNameSuid, scSerializable|scWriteMethod)
+	w.writeString(name)
+	w.out.WriteByte(tcEndBlockData)
+}
+
+func (w *javaObjectWriter) bytes() []byte {
+	w.flushBlock()
+	return w.out.Bytes()
+}
+
+const (
+	stringArraySuid = -5921575005990323385
+	objectNameSuid  = 1081892073854801359
+)
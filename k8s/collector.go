@@ -0,0 +1,146 @@
+// Package k8s groups the process metrics of the local node by Kubernetes pods, which makes the collector usable
+// as a per-node agent in Kubernetes clusters.
+package k8s
+
+import (
+	"context"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow-collector/psutil"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Pod UIDs in the cgroup paths of the pod processes, e.g. kubepods/burstable/pod<uid>/<container> with the cgroupfs
+// driver, or kubepods-burstable-pod<uid>.slice with the systemd driver, where the dashes of the UID are replaced by underscores
+var podUidRegex = regexp.MustCompile("pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})")
+
+// Collector resolves the processes of the local node to the running Kubernetes pods, as reported by the kubelet API.
+// For every pod, a process collector of the psutil root collector reports the summed up metrics of all processes of
+// the pod, named k8s/<namespace>/<pod>/..., with the same metrics as the process collectors (proc/<name>/...).
+// The processes are mapped to the pods through the pod UID in their cgroup paths. When pods are created or deleted,
+// the metric collection is restarted.
+type Collector struct {
+	collector.AbstractCollector
+	root   *psutil.RootCollector
+	client *kubeletClient
+
+	lock sync.Mutex
+	pods map[string]Pod   // Pod UID -> Pod
+	uids map[int32]string // Cached pod UIDs of processes, empty for processes outside of pods
+}
+
+func NewPodCollector(root *psutil.RootCollector, config KubeletConfig) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("k8s"),
+		root:              root,
+		client:            newKubeletClient(config),
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	pods, err := col.client.runningPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	col.pods = pods
+	col.uids = make(map[int32]string)
+
+	uids := make([]string, 0, len(pods))
+	for uid := range pods {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	res := make([]collector.Collector, len(uids))
+	for i, uid := range uids {
+		uid := uid
+		prefix := pods[uid].metricPrefix()
+		res[i] = col.root.NewPidProcessCollector(prefix, prefix, func(pid int32) bool {
+			return col.podUid(pid) == uid
+		}, false)
+	}
+	return res, nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		"k8s/pods": col.readNumPods,
+	}
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"k8s/pods": collector.GaugeMetric(collector.UnitCount, "Running pods on the local node"),
+	}
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	pods, err := col.client.runningPods(ctx)
+	if err != nil {
+		return err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+
+	// Forget the cached pod UIDs, in case PIDs have been reused
+	col.uids = make(map[int32]string)
+	if len(pods) != len(col.pods) {
+		return collector.MetricsChanged
+	}
+	for uid := range pods {
+		if _, ok := col.pods[uid]; !ok {
+			return collector.MetricsChanged
+		}
+	}
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) readNumPods() bitflow.Value {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return bitflow.Value(len(col.pods))
+}
+
+// podUid returns the UID of the pod the given process belongs to, or an empty string
+func (col *Collector) podUid(pid int32) string {
+	col.lock.Lock()
+	uid, ok := col.uids[pid]
+	col.lock.Unlock()
+	if ok {
+		return uid
+	}
+	// Processes that cannot be read anymore are skipped
+	data, err := ioutil.ReadFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "cgroup"))
+	if err == nil {
+		uid = parseCgroupPodUid(string(data))
+	}
+	col.lock.Lock()
+	col.uids[pid] = uid
+	col.lock.Unlock()
+	return uid
+}
+
+// parseCgroupPodUid returns the pod UID contained in the given content of a /proc/<pid>/cgroup file, or an empty string
+func parseCgroupPodUid(cgroup string) string {
+	match := podUidRegex.FindStringSubmatch(cgroup)
+	if match == nil {
+		return ""
+	}
+	return strings.Replace(match[1], "_", "-", -1)
+}
+
+func (pod Pod) metricPrefix() string {
+	return "k8s/" + pod.Namespace + "/" + pod.Name
+}
@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type K8sTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestK8s(t *testing.T) {
+	suite.Run(t, new(K8sTestSuite))
+}
+
+func (suite *K8sTestSuite) TestParseCgroupPodUid() {
+	suite.Equal("0c7ec1b4-6e35-4b8a-9f32-0a2b3c4d5e6f", parseCgroupPodUid(
+		"12:memory:/kubepods/burstable/pod0c7ec1b4-6e35-4b8a-9f32-0a2b3c4d5e6f/"+
+			"2b4c3f9a8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a3928170f6e5d4c\n"+
+			"0::/\n"))
+	suite.Equal("0c7ec1b4-6e35-4b8a-9f32-0a2b3c4d5e6f", parseCgroupPodUid(
+		"0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0c7ec1b4_6e35_4b8a_9f32_0a2b3c4d5e6f.slice/"+
+			"cri-containerd-2b4c3f9a8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a3928170f6e5d4c.scope\n"))
+	suite.Equal("", parseCgroupPodUid("0::/system.slice/docker.service\n"))
+}
+
+func (suite *K8sTestSuite) TestRunningPods() {
+	dir, err := ioutil.TempDir("", "k8s")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	tokenFile := filepath.Join(dir, "token")
+	suite.NoError(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pods" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"kind": "PodList", "items": [
+			{"metadata": {"name": "web-1", "namespace": "default", "uid": "uid-1"}, "status": {"phase": "Running"}},
+			{"metadata": {"name": "job-1", "namespace": "batch", "uid": "uid-2"}, "status": {"phase": "Succeeded"}},
+			{"metadata": {"name": "dns", "namespace": "kube-system", "uid": "uid-3"}, "status": {"phase": "Running"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := newKubeletClient(KubeletConfig{Url: server.URL + "/", TokenFile: tokenFile})
+	pods, err := client.runningPods(context.Background())
+	suite.NoError(err)
+	suite.Equal(map[string]Pod{
+		"uid-1": {Namespace: "default", Name: "web-1", Uid: "uid-1"},
+		"uid-3": {Namespace: "kube-system", Name: "dns", Uid: "uid-3"},
+	}, pods)
+	suite.Equal("k8s/default/web-1", pods["uid-1"].metricPrefix())
+
+	client = newKubeletClient(KubeletConfig{Url: server.URL, TokenFile: filepath.Join(dir, "missing")})
+	_, err = client.runningPods(context.Background())
+	suite.Error(err)
+}
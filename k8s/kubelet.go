@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultKubeletUrl is the authenticated API of the kubelet on the local node
+	DefaultKubeletUrl = "https://127.0.0.1:10250"
+
+	// DefaultTokenFile contains the service account token of a pod, which is used to authenticate against the kubelet
+	DefaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// KubeletConfig describes how to access the /pods endpoint of the kubelet. The token is optional, e.g. when using the
+// read-only port (http://127.0.0.1:10255). Otherwise, the service account requires the permission to get nodes/proxy.
+type KubeletConfig struct {
+	Url       string
+	TokenFile string
+
+	// Skip the verification of the TLS certificate of the kubelet, which is often self-signed
+	Insecure bool
+	Timeout  time.Duration
+}
+
+// Pod is a pod running on the local node
+type Pod struct {
+	Namespace string
+	Name      string
+	Uid       string
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			Uid       string `json:"uid"`
+		} `json:"metadata"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type kubeletClient struct {
+	config KubeletConfig
+	http   http.Client
+}
+
+func newKubeletClient(config KubeletConfig) *kubeletClient {
+	client := &kubeletClient{
+		config: config,
+		http:   http.Client{Timeout: config.Timeout},
+	}
+	if config.Insecure {
+		client.http.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return client
+}
+
+// runningPods returns the running pods of the local node, indexed by their UID
+func (c *kubeletClient) runningPods(ctx context.Context) (map[string]Pod, error) {
+	url := strings.TrimSuffix(c.config.Url, "/") + "/pods"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.TokenFile != "" {
+		token, err := ioutil.ReadFile(c.config.TokenFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Request to %v failed: %v (%v)", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var pods podList
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, fmt.Errorf("Failed to parse response of %v: %v", url, err)
+	}
+	return pods.running(), nil
+}
+
+func (pods *podList) running() map[string]Pod {
+	res := make(map[string]Pod, len(pods.Items))
+	for _, item := range pods.Items {
+		if item.Status.Phase != "Running" || item.Metadata.Uid == "" {
+			continue
+		}
+		res[item.Metadata.Uid] = Pod{
+			Namespace: item.Metadata.Namespace,
+			Name:      item.Metadata.Name,
+			Uid:       item.Metadata.Uid,
+		}
+	}
+	return res
+}
@@ -1,7 +1,10 @@
 package libvirt
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
 	log "github.com/sirupsen/logrus"
@@ -38,6 +41,16 @@ type Collector struct {
 	driver     Driver
 	factory    *collector.ValueRingFactory
 	domains    map[string]Domain
+
+	// If true, in-guest metrics are queried through the QEMU guest agent, which must be running inside the VMs
+	GuestAgent bool
+
+	// If set, only the domains with matching names are monitored
+	Domains *regexp.Regexp
+
+	// If positive, the memory dirty rate of all VMs is calculated continuously with the given calculation period.
+	// The calculation enables dirty page logging for the VM, which has a slight performance overhead.
+	DirtyRatePeriod time.Duration
 }
 
 func NewLibvirtCollector(uri string, driver Driver, factory *collector.ValueRingFactory) *Collector {
@@ -49,53 +62,72 @@ func NewLibvirtCollector(uri string, driver Driver, factory *collector.ValueRing
 	}
 }
 
-func (parent *Collector) Init() ([]collector.Collector, error) {
+func (parent *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
 	parent.Close()
 	parent.domains = make(map[string]Domain)
 	if err := parent.fetchDomains(false); err != nil {
 		return nil, err
 	}
-	res := make([]collector.Collector, 0, len(parent.domains))
+	res := make([]collector.Collector, 0, len(parent.domains)+3)
+	vms := make(map[string]*vmCollector, len(parent.domains))
+	stats := parent.newDomainStatsCollector(vms)
 	for name, domain := range parent.domains {
-		res = append(res, parent.newVmCollector(name, domain))
+		vm := parent.newVmCollector(name, domain, stats)
+		vms[name] = vm
+		res = append(res, vm)
 	}
+	res = append(res, stats, parent.newStoragePoolCollector(), parent.newNetworkCollector())
 	return res, nil
 }
 
-func (parent *Collector) Update() error {
+func (parent *Collector) Update(ctx context.Context) error {
 	return parent.fetchDomains(true)
 }
 
-func (parent *Collector) MetricsChanged() error {
-	return parent.Update()
+func (parent *Collector) MetricsChanged(ctx context.Context) error {
+	return parent.Update(ctx)
 }
 
 func (parent *Collector) fetchDomains(checkChange bool) error {
 	if err := parent.driver.Connect(parent.connectUri); err != nil {
 		return err
 	}
-	domains, err := parent.driver.ListDomains()
+	domains, err := parent.listDomains()
 	if err != nil {
 		return err
 	}
 	if checkChange && len(parent.domains) != len(domains) {
 		return collector.MetricsChanged
 	}
-	for _, domain := range domains {
-		if name, err := domain.GetName(); err != nil {
-			return err
-		} else {
-			if checkChange {
-				if _, ok := parent.domains[name]; !ok {
-					return collector.MetricsChanged
-				}
+	for name, domain := range domains {
+		if checkChange {
+			if _, ok := parent.domains[name]; !ok {
+				return collector.MetricsChanged
 			}
-			parent.domains[name] = domain
 		}
+		parent.domains[name] = domain
 	}
 	return nil
 }
 
+func (parent *Collector) listDomains() (map[string]Domain, error) {
+	domains, err := parent.driver.ListDomains()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]Domain, len(domains))
+	for _, domain := range domains {
+		name, err := domain.GetName()
+		if err != nil {
+			return nil, err
+		}
+		if parent.Domains == nil || parent.Domains.MatchString(name) {
+			res[name] = domain
+		}
+	}
+	return res, nil
+}
+
 func (parent *Collector) Close() {
 	if err := parent.driver.Close(); err != nil {
 		log.Errorln("Error closing libvirt connection:", err)
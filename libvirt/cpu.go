@@ -1,6 +1,10 @@
 package libvirt
 
-import "github.com/bitflow-stream/go-bitflow-collector"
+import (
+	"context"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
 
 type cpuCollector struct {
 	vmSubCollectorImpl
@@ -31,14 +35,11 @@ func (col *cpuCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *cpuCollector) Update() error {
-	if stats, err := col.parent.domain.CpuStats(); err != nil {
-		return err
-	} else {
-		col.cpu_total.Add(LogbackCpuVal(stats.CpuTime))
-		col.cpu_user.Add(LogbackCpuVal(stats.UserTime))
-		col.cpu_system.Add(LogbackCpuVal(stats.SystemTime))
-		col.cpu_virtual.Add(LogbackCpuVal(stats.VcpuTime))
-		return nil
-	}
+func (col *cpuCollector) Update(ctx context.Context) error {
+	stats := col.parent.stats.Cpu
+	col.cpu_total.Add(LogbackCpuVal(stats.CpuTime))
+	col.cpu_user.Add(LogbackCpuVal(stats.UserTime))
+	col.cpu_system.Add(LogbackCpuVal(stats.SystemTime))
+	col.cpu_virtual.Add(LogbackCpuVal(stats.VcpuTime))
+	return nil
 }
@@ -0,0 +1,54 @@
+package libvirt
+
+import (
+	"context"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// dirtyRateCollector continuously measures the rate at which the guest memory of a VM is modified. This is
+// an important input for planning live migrations. Whenever a calculation finishes, the next one is started,
+// so the metric libvirt/<vm>/mem/dirty-rate is updated once per calculation period.
+type dirtyRateCollector struct {
+	vmSubCollectorImpl
+	rate VirDomainDirtyRate
+}
+
+func NewDirtyRateCollector(parent *vmCollector) *dirtyRateCollector {
+	return &dirtyRateCollector{
+		vmSubCollectorImpl: parent.child("dirty-rate"),
+	}
+}
+
+func (col *dirtyRateCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		col.parent.prefix() + "mem/dirty-rate": col.readRate,
+	}
+}
+
+func (col *dirtyRateCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		col.parent.prefix() + "mem/dirty-rate": collector.GaugeMetric(collector.UnitBytesPerSecond, "Rate of modified guest memory, measured in the last calculation period"),
+	}
+}
+
+func (col *dirtyRateCollector) Update(ctx context.Context) error {
+	rate := col.parent.stats.DirtyRate
+	if rate.Measured {
+		col.rate = rate
+	}
+	if !rate.Measuring {
+		seconds := int(col.parent.parent.DirtyRatePeriod / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		return col.parent.domain.StartDirtyRateCalc(seconds)
+	}
+	return nil
+}
+
+func (col *dirtyRateCollector) readRate() bitflow.Value {
+	return bitflow.Value(col.rate.BytesPerSecond)
+}
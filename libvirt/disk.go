@@ -1,7 +1,9 @@
 package libvirt
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
@@ -22,11 +24,27 @@ func NewBlockCollector(parent *vmCollector) *vmBlockCollector {
 	}
 }
 
-func (col *vmBlockCollector) Init() ([]collector.Collector, error) {
+func (col *vmBlockCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	// The devices are required for defining the per-device latency metrics
+	xmlData, err := col.parent.domain.GetXML()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve XML domain description of %s: %v", col.parent.name, err)
+	}
+	xmlDesc, err := xmlpath.Parse(strings.NewReader(xmlData))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse XML domain description of %s: %v", col.parent.name, err)
+	}
+	col.description(xmlDesc)
+
+	io := &vmBlockIoCollector{
+		AbstractCollector: col.Child("block-io"),
+		parent:            col,
+	}
 	return []collector.Collector{
-		&vmBlockIoCollector{
-			AbstractCollector: col.Child("block-io"),
-			parent:            col,
+		io,
+		&vmBlockLatencyCollector{
+			AbstractCollector: col.Child("block-latency"),
+			io:                io,
 		},
 		&vmBlockStatsCollector{
 			AbstractCollector: col.Child("block-stats"),
@@ -47,12 +65,13 @@ func (col *vmBlockCollector) description(xmlDesc *xmlpath.Node) {
 type vmBlockIoCollector struct {
 	collector.AbstractCollector
 	parent      *vmBlockCollector
+	devices     []string
 	stats       []VirDomainBlockStats
 	ioRing      *collector.ValueRing
 	ioBytesRing *collector.ValueRing
 }
 
-func (col *vmBlockIoCollector) Init() ([]collector.Collector, error) {
+func (col *vmBlockIoCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	factory := col.parent.parent.parent.factory
 	col.ioRing = factory.NewValueRing()
 	col.ioBytesRing = factory.NewValueRing()
@@ -67,16 +86,16 @@ func (col *vmBlockIoCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *vmBlockIoCollector) Update() error {
+func (col *vmBlockIoCollector) Update(ctx context.Context) error {
 	new_stats := make([]VirDomainBlockStats, 0, len(col.parent.devices))
 	for _, dev := range col.parent.devices {
-		// More detailed alternative: domain.BlockStatsFlags()
-		if block_stats, err := col.parent.parent.domain.BlockStats(dev); err == nil {
+		if block_stats, ok := col.parent.parent.stats.Block[dev]; ok {
 			new_stats = append(new_stats, block_stats)
 		} else {
-			return fmt.Errorf("Failed to get block-device stats for %s: %v", dev, err)
+			return fmt.Errorf("No block-device stats for %s", dev)
 		}
 	}
+	col.devices = append(col.devices[0:0], col.parent.devices...)
 	col.stats = new_stats
 	return nil
 }
@@ -103,6 +122,131 @@ func (col *vmBlockIoCollector) readIoBytes() bitflow.Value {
 	return col.ioBytesRing.GetDiff()
 }
 
+// ===================================== block latency =====================================
+
+// vmBlockLatencyCollector computes the average service time of read, write and flush requests
+// for every virtual disk (block/<dev>/...) and for all disks (block/...). The statistics are
+// obtained by the block-io collector.
+type vmBlockLatencyCollector struct {
+	collector.AbstractCollector
+	io      *vmBlockIoCollector
+	devices map[string]*blockLatencyRings
+	total   *blockLatencyRings
+}
+
+type blockLatencyRings struct {
+	rdReq, wrReq, flushReq       *collector.ValueRing
+	rdTimes, wrTimes, flushTimes *collector.ValueRing
+}
+
+func newBlockLatencyRings(factory *collector.ValueRingFactory) *blockLatencyRings {
+	return &blockLatencyRings{
+		rdReq:      factory.NewValueRing(),
+		wrReq:      factory.NewValueRing(),
+		flushReq:   factory.NewValueRing(),
+		rdTimes:    factory.NewValueRing(),
+		wrTimes:    factory.NewValueRing(),
+		flushTimes: factory.NewValueRing(),
+	}
+}
+
+func (rings *blockLatencyRings) add(stats VirDomainBlockStats) {
+	rings.rdReq.Add(collector.StoredValue(stats.RdReq))
+	rings.wrReq.Add(collector.StoredValue(stats.WrReq))
+	rings.flushReq.Add(collector.StoredValue(stats.FlushReq))
+	rings.rdTimes.Add(collector.StoredValue(stats.RdTotalTimes))
+	rings.wrTimes.Add(collector.StoredValue(stats.WrTotalTimes))
+	rings.flushTimes.Add(collector.StoredValue(stats.FlushTotalTimes))
+}
+
+func (rings *blockLatencyRings) metrics(prefix string) collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		prefix + "flush":         rings.flushReq.GetDiff,
+		prefix + "latency/read":  readLatency(rings.rdTimes, rings.rdReq),
+		prefix + "latency/write": readLatency(rings.wrTimes, rings.wrReq),
+		prefix + "latency/flush": readLatency(rings.flushTimes, rings.flushReq),
+	}
+}
+
+func (rings *blockLatencyRings) metadata(prefix string, what string) collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		prefix + "flush":         collector.DerivedMetric(collector.UnitPerSecond, "Flush requests of "+what),
+		prefix + "latency/read":  collector.DerivedMetric(collector.UnitMillis, "Average service time of read requests of "+what),
+		prefix + "latency/write": collector.DerivedMetric(collector.UnitMillis, "Average service time of write requests of "+what),
+		prefix + "latency/flush": collector.DerivedMetric(collector.UnitMillis, "Average service time of flush requests of "+what),
+	}
+}
+
+// readLatency returns the average time per request in milliseconds within the time window of the value rings
+func readLatency(times *collector.ValueRing, requests *collector.ValueRing) collector.MetricReader {
+	return func() bitflow.Value {
+		numRequests := requests.GetDiff()
+		if numRequests <= 0 {
+			return 0
+		}
+		return times.GetDiff() / numRequests / 1e6
+	}
+}
+
+func (col *vmBlockLatencyCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	factory := col.io.parent.parent.parent.factory
+	col.total = newBlockLatencyRings(factory)
+	col.devices = make(map[string]*blockLatencyRings)
+	for _, dev := range col.io.parent.devices {
+		col.devices[dev] = newBlockLatencyRings(factory)
+	}
+	return nil, nil
+}
+
+func (col *vmBlockLatencyCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.io}
+}
+
+func (col *vmBlockLatencyCollector) Update(ctx context.Context) error {
+	if len(col.io.devices) != len(col.devices) {
+		return collector.MetricsChanged
+	}
+	var total VirDomainBlockStats
+	for i, dev := range col.io.devices {
+		rings, ok := col.devices[dev]
+		if !ok {
+			return collector.MetricsChanged
+		}
+		stats := col.io.stats[i]
+		rings.add(stats)
+		total.RdReq += stats.RdReq
+		total.WrReq += stats.WrReq
+		total.FlushReq += stats.FlushReq
+		total.RdTotalTimes += stats.RdTotalTimes
+		total.WrTotalTimes += stats.WrTotalTimes
+		total.FlushTotalTimes += stats.FlushTotalTimes
+	}
+	col.total.add(total)
+	return nil
+}
+
+func (col *vmBlockLatencyCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.io.parent.parent.prefix() + "block/"
+	res := col.total.metrics(prefix)
+	for dev, rings := range col.devices {
+		for name, reader := range rings.metrics(prefix + dev + "/") {
+			res[name] = reader
+		}
+	}
+	return res
+}
+
+func (col *vmBlockLatencyCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.io.parent.parent.prefix() + "block/"
+	res := col.total.metadata(prefix, "all virtual disks")
+	for dev, rings := range col.devices {
+		for name, metadata := range rings.metadata(prefix+dev+"/", "the virtual disk") {
+			res[name] = metadata
+		}
+	}
+	return res
+}
+
 // ===================================== block usage =====================================
 
 type vmBlockStatsCollector struct {
@@ -120,13 +264,13 @@ func (col *vmBlockStatsCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *vmBlockStatsCollector) Update() error {
+func (col *vmBlockStatsCollector) Update(ctx context.Context) error {
 	new_info := make([]VirDomainBlockInfo, 0, len(col.parent.devices))
 	for _, dev := range col.parent.devices {
-		if block_info, err := col.parent.parent.domain.BlockInfo(dev); err == nil {
+		if block_info, ok := col.parent.parent.stats.BlockInfo[dev]; ok {
 			new_info = append(new_info, block_info)
 		} else {
-			return fmt.Errorf("Failed to get block-device info for %s: %v", dev, err)
+			return fmt.Errorf("No block-device info for %s", dev)
 		}
 	}
 	col.info = new_info
@@ -0,0 +1,50 @@
+package libvirt
+
+import (
+	"context"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
+
+// domainStatsCollector retrieves the statistics of all VMs through one bulk request in every update round,
+// instead of issuing multiple requests per VM for the individual statistics. The VM collectors and their
+// sub-collectors depend on this collector and read the statistics obtained in the current round.
+// The collector is not named as a child of the libvirt collector, because it must not be affected by the update
+// frequencies of the VM collectors.
+type domainStatsCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+	vms    map[string]*vmCollector
+}
+
+func (parent *Collector) newDomainStatsCollector(vms map[string]*vmCollector) *domainStatsCollector {
+	return &domainStatsCollector{
+		AbstractCollector: collector.RootCollector("libvirt-stats"),
+		parent:            parent,
+		vms:               vms,
+	}
+}
+
+func (col *domainStatsCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *domainStatsCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	return nil, col.Update(ctx)
+}
+
+func (col *domainStatsCollector) Update(ctx context.Context) error {
+	stats, err := col.parent.driver.AllDomainStats(col.parent.DirtyRatePeriod > 0)
+	if err != nil {
+		return err
+	}
+	for name, vm := range col.vms {
+		vmStats, ok := stats[name]
+		if !ok {
+			// The VM has been stopped, the VM collectors must be recreated
+			return collector.MetricsChanged
+		}
+		vm.stats = vmStats
+	}
+	return nil
+}
@@ -1,8 +1,64 @@
 package libvirt
 
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Backends of the Driver, see NewBackendDriver
+const (
+	BackendAuto     = "auto"
+	BackendNative   = "native"
+	BackendVirsh    = "virsh"
+	BackendDisabled = "disabled"
+
+	volumeMonitorCommand    = "info block"
+	iothreadsMonitorCommand = `{"execute":"query-iothreads"}`
+)
+
+var (
+	Backends = []string{BackendAuto, BackendNative, BackendVirsh, BackendDisabled}
+
+	volumeJsonRegex = regexp.MustCompile("json:{(.*)}")
+)
+
+// NewDriver returns a Driver for the BackendAuto backend
+func NewDriver() Driver {
+	if driver, err := newNativeDriver(); err == nil {
+		return driver
+	}
+	return NewVirshDriver()
+}
+
+// NewBackendDriver returns a Driver for the given backend. BackendNative uses the libvirt C library through libvirt-go,
+// which is only available in binaries built with cgo and without the nolibvirt build tag. BackendVirsh executes the
+// virsh command line tool, which does not require linking against libvirt. BackendAuto uses the native backend
+// if it is available, and virsh otherwise. BackendDisabled is not a valid parameter, but should be handled by the
+// caller by not creating a libvirt Collector at all.
+func NewBackendDriver(backend string) (Driver, error) {
+	switch backend {
+	case BackendAuto:
+		return NewDriver(), nil
+	case BackendNative:
+		return newNativeDriver()
+	case BackendVirsh:
+		return NewVirshDriver(), nil
+	default:
+		return nil, fmt.Errorf("Unknown libvirt backend '%v', must be one of %v", backend, strings.Join(Backends[:3], ", "))
+	}
+}
+
 type Driver interface {
 	Connect(uri string) error
 	ListDomains() ([]Domain, error)
+
+	// AllDomainStats retrieves the statistics of all running domains in one bulk request, indexed by the domain names.
+	// The dirty rate is only included if requested.
+	AllDomainStats(dirtyRate bool) (map[string]VirDomainStats, error)
+	StoragePoolStats() ([]VirStoragePoolStats, error)
+	NetworkInfos() ([]VirNetworkInfo, error)
 	Close() error
 }
 
@@ -17,6 +73,24 @@ type Domain interface {
 	BlockInfo(dev string) (VirDomainBlockInfo, error)
 	InterfaceStats(interfaceName string) (VirDomainInterfaceStats, error)
 	MemoryStats() (VirDomainMemoryStat, error)
+	IOThreads() ([]VirDomainIOThread, error)
+	StartDirtyRateCalc(seconds int) error
+
+	// QemuAgentCommand executes a command of the QEMU guest agent and returns the raw JSON response
+	QemuAgentCommand(command string) (string, error)
+}
+
+// VirDomainStats contains all statistics of one domain, which are obtained through one bulk request for all domains.
+// Block devices and network interfaces are indexed by their target device names.
+type VirDomainStats struct {
+	Info      DomainInfo
+	Cpu       VirDomainCpuStats
+	Vcpu      VirDomainVcpuStats
+	Memory    VirDomainMemoryStat
+	Block     map[string]VirDomainBlockStats
+	BlockInfo map[string]VirDomainBlockInfo
+	Net       map[string]VirDomainInterfaceStats
+	DirtyRate VirDomainDirtyRate
 }
 
 type DomainInfo struct {
@@ -32,6 +106,26 @@ type VolumeInfo struct {
 	User   string
 }
 
+type VirStoragePoolStats struct {
+	Name       string
+	Capacity   uint64
+	Allocation uint64
+	Available  uint64
+	Volumes    []VirStorageVolumeStats
+}
+
+type VirStorageVolumeStats struct {
+	Name       string
+	Capacity   uint64
+	Allocation uint64
+}
+
+type VirNetworkInfo struct {
+	Name       string
+	Bridge     string
+	DhcpLeases int
+}
+
 type VirDomainCpuStats struct {
 	CpuTime    uint64
 	UserTime   uint64
@@ -40,10 +134,16 @@ type VirDomainCpuStats struct {
 }
 
 type VirDomainBlockStats struct {
-	RdReq   int64
-	WrReq   int64
-	RdBytes int64
-	WrBytes int64
+	RdReq    int64
+	WrReq    int64
+	FlushReq int64
+	RdBytes  int64
+	WrBytes  int64
+
+	// Accumulated service times of all requests in nanoseconds
+	RdTotalTimes    int64
+	WrTotalTimes    int64
+	FlushTotalTimes int64
 }
 
 type VirDomainBlockInfo struct {
@@ -52,6 +152,30 @@ type VirDomainBlockInfo struct {
 	Physical   uint64
 }
 
+// VirDomainVcpuStats contains the accumulated statistics of all vCPUs of a domain. All times are in nanoseconds.
+type VirDomainVcpuStats struct {
+	Vcpus  uint64
+	Halted uint64
+	Time   uint64
+	Wait   uint64 // Time the vCPUs were ready to run, but not scheduled by the host (steal time)
+
+	HaltPollSuccess uint64
+	HaltPollFail    uint64
+}
+
+type VirDomainIOThread struct {
+	Id        string
+	ThreadId  int
+	PollMaxNs uint64
+}
+
+// VirDomainDirtyRate contains the result of the last memory dirty rate calculation (requires libvirt 7.2 or newer)
+type VirDomainDirtyRate struct {
+	Measuring      bool // A calculation is currently running
+	Measured       bool // The last calculation has finished
+	BytesPerSecond uint64
+}
+
 type VirDomainMemoryStat struct {
 	Available uint64
 	Unused    uint64
@@ -67,3 +191,46 @@ type VirDomainInterfaceStats struct {
 	TxErrs    int64
 	TxDrop    int64
 }
+
+// parseIOThreads parses the response of the query-iothreads QEMU monitor command
+func parseIOThreads(response string) ([]VirDomainIOThread, error) {
+	var result struct {
+		Return []struct {
+			Id        string `json:"id"`
+			ThreadId  int    `json:"thread-id"`
+			PollMaxNs uint64 `json:"poll-max-ns"`
+		} `json:"return"`
+	}
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("Failed to parse response of %v: %v", iothreadsMonitorCommand, err)
+	}
+	res := make([]VirDomainIOThread, len(result.Return))
+	for i, thread := range result.Return {
+		res[i] = VirDomainIOThread{Id: thread.Id, ThreadId: thread.ThreadId, PollMaxNs: thread.PollMaxNs}
+	}
+	return res, nil
+}
+
+// parseVolumeInfo parses the response of the 'info block' HMP command of the QEMU monitor
+func parseVolumeInfo(volumeInfoStr string) []VolumeInfo {
+	var result []VolumeInfo
+	split := strings.Split(volumeInfoStr, "\n")
+	for _, line := range split {
+		if match := volumeJsonRegex.FindString(line); match != "" {
+			var objmap1 map[string]json.RawMessage
+			var objmap2 map[string]string
+			b := []byte(match[5:]) // match without the "json:" prefix
+			if err := json.Unmarshal(b, &objmap1); err == nil {
+				if err := json.Unmarshal(objmap1["file"], &objmap2); err == nil {
+					result = append(result, VolumeInfo{
+						Pool:   objmap2["pool"],
+						Image:  objmap2["image"],
+						Driver: objmap2["driver"],
+						User:   objmap2["user"],
+					})
+				}
+			}
+		}
+	}
+	return result
+}
@@ -1,13 +1,10 @@
-// +build !nolibvirt
+// +build cgo,!nolibvirt
 
 package libvirt
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
-	"strings"
 
 	lib "github.com/libvirt/libvirt-go"
 	log "github.com/sirupsen/logrus"
@@ -18,14 +15,13 @@ const (
 	FetchDomainsFlags = lib.CONNECT_LIST_DOMAINS_ACTIVE | lib.CONNECT_LIST_DOMAINS_RUNNING
 	MaxNumMemoryStats = 8
 
-	volumeMonitorCommand      = "info block"
+	AllDomainStatsFlags = lib.CONNECT_GET_ALL_DOMAINS_STATS_ACTIVE | lib.CONNECT_GET_ALL_DOMAINS_STATS_RUNNING
+
 	volumeMonitorCommandFlags = lib.DOMAIN_QEMU_MONITOR_COMMAND_HMP
 )
 
-var volumeJsonRegex = regexp.MustCompile("json:{(.*)}")
-
-func NewDriver() Driver {
-	return new(DriverImpl)
+func newNativeDriver() (Driver, error) {
+	return new(DriverImpl), nil
 }
 
 type DriverImpl struct {
@@ -49,11 +45,211 @@ func (d *DriverImpl) ListDomains() ([]Domain, error) {
 	}
 	domains := make([]Domain, len(virDomains))
 	for i, domain := range virDomains {
-		domains[i] = &DomainImpl{domain}
+		domains[i] = &DomainImpl{domain: domain, conn: conn}
 	}
 	return domains, nil
 }
 
+func (d *DriverImpl) AllDomainStats(dirtyRate bool) (map[string]VirDomainStats, error) {
+	conn, err := d.connection()
+	if err != nil {
+		return nil, err
+	}
+	statsTypes := lib.DOMAIN_STATS_CPU_TOTAL | lib.DOMAIN_STATS_BALLOON | lib.DOMAIN_STATS_VCPU |
+		lib.DOMAIN_STATS_INTERFACE | lib.DOMAIN_STATS_BLOCK
+	if dirtyRate {
+		statsTypes |= lib.DOMAIN_STATS_DIRTYRATE
+	}
+	stats, err := conn.GetAllDomainStats(nil, statsTypes, AllDomainStatsFlags)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, stat := range stats {
+			if stat.Domain != nil {
+				_ = stat.Domain.Free()
+			}
+		}
+	}()
+	res := make(map[string]VirDomainStats, len(stats))
+	for i := range stats {
+		name, err := stats[i].Domain.GetName()
+		if err != nil {
+			return nil, err
+		}
+		res[name] = convertDomainStats(&stats[i])
+	}
+	return res, nil
+}
+
+func convertDomainStats(stats *lib.DomainStats) VirDomainStats {
+	res := VirDomainStats{
+		Block:     make(map[string]VirDomainBlockStats, len(stats.Block)),
+		BlockInfo: make(map[string]VirDomainBlockInfo, len(stats.Block)),
+		Net:       make(map[string]VirDomainInterfaceStats, len(stats.Net)),
+	}
+	// Fields that are not supported by the hypervisor are left at zero
+	if cpu := stats.Cpu; cpu != nil {
+		res.Cpu.CpuTime = cpu.Time
+		res.Cpu.UserTime = cpu.User
+		res.Cpu.SystemTime = cpu.System
+		res.Info.CpuTime = cpu.Time
+		res.Vcpu.HaltPollSuccess = cpu.HaltPollSuccessTime
+		res.Vcpu.HaltPollFail = cpu.HaltPollFailTime
+	}
+	for _, vcpu := range stats.Vcpu {
+		res.Vcpu.Vcpus++
+		if vcpu.HaltedSet && vcpu.Halted {
+			res.Vcpu.Halted++
+		}
+		res.Vcpu.Time += vcpu.Time
+		res.Vcpu.Wait += vcpu.Wait
+	}
+	res.Cpu.VcpuTime = res.Vcpu.Time
+	if balloon := stats.Balloon; balloon != nil {
+		res.Info.MaxMem = balloon.Maximum
+		res.Info.Mem = balloon.Current
+		res.Memory.Unused = balloon.Unused
+		res.Memory.Available = balloon.Available
+	}
+	for _, block := range stats.Block {
+		if !block.NameSet {
+			continue
+		}
+		res.Block[block.Name] = VirDomainBlockStats{
+			RdReq:           int64(block.RdReqs),
+			WrReq:           int64(block.WrReqs),
+			FlushReq:        int64(block.FlReqs),
+			RdBytes:         int64(block.RdBytes),
+			WrBytes:         int64(block.WrBytes),
+			RdTotalTimes:    int64(block.RdTimes),
+			WrTotalTimes:    int64(block.WrTimes),
+			FlushTotalTimes: int64(block.FlTimes),
+		}
+		res.BlockInfo[block.Name] = VirDomainBlockInfo{
+			Allocation: block.Allocation,
+			Capacity:   block.Capacity,
+			Physical:   block.Physical,
+		}
+	}
+	for _, net := range stats.Net {
+		if !net.NameSet {
+			continue
+		}
+		res.Net[net.Name] = VirDomainInterfaceStats{
+			RxBytes:   int64(net.RxBytes),
+			RxPackets: int64(net.RxPkts),
+			RxErrs:    int64(net.RxErrs),
+			RxDrop:    int64(net.RxDrop),
+			TxBytes:   int64(net.TxBytes),
+			TxPackets: int64(net.TxPkts),
+			TxErrs:    int64(net.TxErrs),
+			TxDrop:    int64(net.TxDrop),
+		}
+	}
+	if rate := stats.DirtyRate; rate != nil && rate.CalcStatusSet {
+		res.DirtyRate.Measuring = rate.CalcStatus == int(lib.DOMAIN_DIRTYRATE_MEASURING)
+		res.DirtyRate.Measured = rate.CalcStatus == int(lib.DOMAIN_DIRTYRATE_MEASURED)
+		res.DirtyRate.BytesPerSecond = uint64(rate.MegabytesPerSecond) * 1024 * 1024
+	}
+	return res
+}
+
+func (d *DriverImpl) StoragePoolStats() ([]VirStoragePoolStats, error) {
+	conn, err := d.connection()
+	if err != nil {
+		return nil, err
+	}
+	pools, err := conn.ListAllStoragePools(lib.CONNECT_LIST_STORAGE_POOLS_ACTIVE)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, pool := range pools {
+			_ = pool.Free()
+		}
+	}()
+	res := make([]VirStoragePoolStats, 0, len(pools))
+	for _, pool := range pools {
+		stats, err := d.storagePoolStats(&pool)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, stats)
+	}
+	return res, nil
+}
+
+func (d *DriverImpl) storagePoolStats(pool *lib.StoragePool) (res VirStoragePoolStats, err error) {
+	if res.Name, err = pool.GetName(); err != nil {
+		return
+	}
+	var info *lib.StoragePoolInfo
+	if info, err = pool.GetInfo(); err != nil {
+		return
+	}
+	res.Capacity = info.Capacity
+	res.Allocation = info.Allocation
+	res.Available = info.Available
+
+	var volumes []lib.StorageVol
+	if volumes, err = pool.ListAllStorageVolumes(NoFlags); err != nil {
+		return
+	}
+	defer func() {
+		for _, vol := range volumes {
+			_ = vol.Free()
+		}
+	}()
+	for _, vol := range volumes {
+		var volStats VirStorageVolumeStats
+		if volStats.Name, err = vol.GetName(); err != nil {
+			return
+		}
+		var volInfo *lib.StorageVolInfo
+		if volInfo, err = vol.GetInfo(); err != nil {
+			return
+		}
+		volStats.Capacity = volInfo.Capacity
+		volStats.Allocation = volInfo.Allocation
+		res.Volumes = append(res.Volumes, volStats)
+	}
+	return
+}
+
+func (d *DriverImpl) NetworkInfos() ([]VirNetworkInfo, error) {
+	conn, err := d.connection()
+	if err != nil {
+		return nil, err
+	}
+	networks, err := conn.ListAllNetworks(lib.CONNECT_LIST_NETWORKS_ACTIVE)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, network := range networks {
+			_ = network.Free()
+		}
+	}()
+	res := make([]VirNetworkInfo, 0, len(networks))
+	for _, network := range networks {
+		var info VirNetworkInfo
+		if info.Name, err = network.GetName(); err != nil {
+			return nil, err
+		}
+		if info.Bridge, err = network.GetBridgeName(); err != nil {
+			return nil, err
+		}
+		leases, err := network.GetDHCPLeases()
+		if err != nil {
+			return nil, err
+		}
+		info.DhcpLeases = len(leases)
+		res = append(res, info)
+	}
+	return res, nil
+}
+
 func (d *DriverImpl) connection() (*lib.Connect, error) {
 	conn := d.conn
 	if conn != nil {
@@ -89,6 +285,7 @@ func (d *DriverImpl) Close() (err error) {
 
 type DomainImpl struct {
 	domain lib.Domain
+	conn   *lib.Connect
 }
 
 func (d *DomainImpl) GetName() (string, error) {
@@ -115,13 +312,18 @@ func (d *DomainImpl) CpuStats() (res VirDomainCpuStats, err error) {
 
 func (d *DomainImpl) BlockStats(dev string) (res VirDomainBlockStats, err error) {
 	var stats *lib.DomainBlockStats
-	stats, err = d.domain.BlockStats(dev)
+	stats, err = d.domain.BlockStatsFlags(dev, NoFlags)
 	if err == nil {
+		// Fields that are not supported by the hypervisor are left at zero
 		res = VirDomainBlockStats{
-			RdReq:   stats.RdReq,
-			WrReq:   stats.WrReq,
-			RdBytes: stats.RdBytes,
-			WrBytes: stats.WrBytes,
+			RdReq:           stats.RdReq,
+			WrReq:           stats.WrReq,
+			FlushReq:        stats.FlushReq,
+			RdBytes:         stats.RdBytes,
+			WrBytes:         stats.WrBytes,
+			RdTotalTimes:    stats.RdTotalTimes,
+			WrTotalTimes:    stats.WrTotalTimes,
+			FlushTotalTimes: stats.FlushTotalTimes,
 		}
 	}
 	return
@@ -156,6 +358,19 @@ func (d *DomainImpl) MemoryStats() (res VirDomainMemoryStat, err error) {
 	return
 }
 
+func (d *DomainImpl) IOThreads() ([]VirDomainIOThread, error) {
+	// The thread IDs are only available through the QEMU monitor
+	response, err := d.domain.QemuMonitorCommand(iothreadsMonitorCommand, lib.DOMAIN_QEMU_MONITOR_COMMAND_DEFAULT)
+	if err != nil {
+		return nil, err
+	}
+	return parseIOThreads(response)
+}
+
+func (d *DomainImpl) StartDirtyRateCalc(seconds int) error {
+	return d.domain.StartDirtyRateCalc(seconds, NoFlags)
+}
+
 func (d *DomainImpl) InterfaceStats(interfaceName string) (res VirDomainInterfaceStats, err error) {
 	var stats *lib.DomainInterfaceStats
 	stats, err = d.domain.InterfaceStats(interfaceName)
@@ -174,6 +389,10 @@ func (d *DomainImpl) InterfaceStats(interfaceName string) (res VirDomainInterfac
 	return
 }
 
+func (d *DomainImpl) QemuAgentCommand(command string) (string, error) {
+	return d.domain.QemuAgentCommand(command, lib.DOMAIN_QEMU_AGENT_COMMAND_DEFAULT, NoFlags)
+}
+
 func (d *DomainImpl) GetXML() (string, error) {
 	return d.domain.GetXMLDesc(NoFlags)
 }
@@ -191,30 +410,7 @@ func (d *DomainImpl) GetInfo() (res DomainInfo, err error) {
 
 func (d *DomainImpl) GetVolumeInfo() (res []VolumeInfo, err error) {
 	if volumeInfoStr, err := d.domain.QemuMonitorCommand(volumeMonitorCommand, volumeMonitorCommandFlags); err == nil {
-		res = d.parseVolumeInfo(volumeInfoStr)
+		res = parseVolumeInfo(volumeInfoStr)
 	}
 	return
 }
-
-func (d *DomainImpl) parseVolumeInfo(volumeInfoStr string) []VolumeInfo {
-	var result []VolumeInfo
-	split := strings.Split(volumeInfoStr, "\n")
-	for _, line := range split {
-		if match := volumeJsonRegex.FindString(line); match != "" {
-			var objmap1 map[string]json.RawMessage
-			var objmap2 map[string]string
-			b := []byte(match[5:]) // match without the "json:" prefix
-			if err := json.Unmarshal(b, &objmap1); err == nil {
-				if err := json.Unmarshal(objmap1["file"], &objmap2); err == nil {
-					result = append(result, VolumeInfo{
-						Pool:   objmap2["pool"],
-						Image:  objmap2["image"],
-						Driver: objmap2["driver"],
-						User:   objmap2["user"],
-					})
-				}
-			}
-		}
-	}
-	return result
-}
@@ -0,0 +1,9 @@
+// +build !cgo nolibvirt
+
+package libvirt
+
+import "errors"
+
+func newNativeDriver() (Driver, error) {
+	return nil, errors.New("The native libvirt backend is not available, because this binary was built without cgo or with the nolibvirt build tag")
+}
@@ -0,0 +1,489 @@
+package libvirt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultVirsh        = "virsh"
+	DefaultVirshTimeout = 30 * time.Second
+
+	// Values of dirtyrate.calc_status, see virDomainDirtyRateStatus
+	dirtyRateMeasuring = 1
+	dirtyRateMeasured  = 2
+)
+
+var _ Driver = new(VirshDriver)
+var _ Domain = new(virshDomain)
+
+// VirshDriver implements Driver by executing the virsh command line tool and parsing its output. It is slower than
+// the native backend, since every request starts a new virsh process, but does not require linking the collector
+// against the libvirt C library.
+type VirshDriver struct {
+	Virsh   string
+	Timeout time.Duration
+
+	uri string
+}
+
+func NewVirshDriver() *VirshDriver {
+	return &VirshDriver{
+		Virsh:   DefaultVirsh,
+		Timeout: DefaultVirshTimeout,
+	}
+}
+
+func (d *VirshDriver) Connect(uri string) error {
+	if _, err := exec.LookPath(d.Virsh); err != nil {
+		return err
+	}
+	d.uri = uri
+	return nil
+}
+
+func (d *VirshDriver) Close() error {
+	d.uri = ""
+	return nil
+}
+
+// virsh executes the given virsh command in quiet mode, which omits the headers of tables
+func (d *VirshDriver) virsh(args ...string) ([]byte, error) {
+	if d.uri == "" {
+		return nil, errors.New("Driver.Connect() has not yet been called.")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, d.Virsh, append([]string{"--quiet", "--connect", d.uri}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v %v failed: %v (%v)", d.Virsh, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// virshLines executes the given virsh command and returns the non-empty lines of the output
+func (d *VirshDriver) virshLines(args ...string) ([]string, error) {
+	output, err := d.virsh(args...)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(output), nil
+}
+
+func (d *VirshDriver) ListDomains() ([]Domain, error) {
+	names, err := d.virshLines("list", "--name", "--state-running")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Domain, len(names))
+	for i, name := range names {
+		res[i] = &virshDomain{driver: d, name: name}
+	}
+	return res, nil
+}
+
+func (d *VirshDriver) AllDomainStats(dirtyRate bool) (map[string]VirDomainStats, error) {
+	args := []string{"domstats", "--state-running", "--cpu-total", "--balloon", "--vcpu", "--interface", "--block"}
+	if dirtyRate {
+		args = append(args, "--dirtyrate")
+	}
+	output, err := d.virsh(args...)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := parseVirshDomstats(output)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]VirDomainStats, len(domains))
+	for name, values := range domains {
+		res[name] = convertVirshDomstats(values)
+	}
+	return res, nil
+}
+
+func (d *VirshDriver) StoragePoolStats() ([]VirStoragePoolStats, error) {
+	pools, err := d.virshLines("pool-list", "--name")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]VirStoragePoolStats, 0, len(pools))
+	for _, pool := range pools {
+		output, err := d.virsh("pool-info", "--bytes", "--pool", pool)
+		if err != nil {
+			return nil, err
+		}
+		info := parseVirshKeyValues(output)
+		stats := VirStoragePoolStats{
+			Name:       pool,
+			Capacity:   virshUint(info["Capacity"]),
+			Allocation: virshUint(info["Allocation"]),
+			Available:  virshUint(info["Available"]),
+		}
+		volumes, err := d.virshLines("vol-list", "--pool", pool)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range volumes {
+			// Every line contains the volume name and path. Volume names with spaces are not supported.
+			fields := strings.Fields(line)
+			if strings.HasPrefix(line, "---") || len(fields) == 0 {
+				continue
+			}
+			output, err := d.virsh("vol-info", "--bytes", "--pool", pool, "--vol", fields[0])
+			if err != nil {
+				return nil, err
+			}
+			info := parseVirshKeyValues(output)
+			stats.Volumes = append(stats.Volumes, VirStorageVolumeStats{
+				Name:       fields[0],
+				Capacity:   virshUint(info["Capacity"]),
+				Allocation: virshUint(info["Allocation"]),
+			})
+		}
+		res = append(res, stats)
+	}
+	return res, nil
+}
+
+func (d *VirshDriver) NetworkInfos() ([]VirNetworkInfo, error) {
+	networks, err := d.virshLines("net-list", "--name")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]VirNetworkInfo, 0, len(networks))
+	for _, network := range networks {
+		output, err := d.virsh("net-info", "--network", network)
+		if err != nil {
+			return nil, err
+		}
+		leases, err := d.virshLines("net-dhcp-leases", "--network", network)
+		if err != nil {
+			return nil, err
+		}
+		info := VirNetworkInfo{
+			Name:   network,
+			Bridge: parseVirshKeyValues(output)["Bridge"],
+		}
+		for _, lease := range leases {
+			if !strings.HasPrefix(lease, "---") {
+				info.DhcpLeases++
+			}
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+type virshDomain struct {
+	driver *VirshDriver
+	name   string
+}
+
+func (d *virshDomain) virsh(command string, args ...string) ([]byte, error) {
+	return d.driver.virsh(append([]string{command, "--domain", d.name}, args...)...)
+}
+
+func (d *virshDomain) GetName() (string, error) {
+	return d.name, nil
+}
+
+func (d *virshDomain) GetXML() (string, error) {
+	output, err := d.virsh("dumpxml")
+	return string(output), err
+}
+
+func (d *virshDomain) GetInfo() (res DomainInfo, err error) {
+	var output []byte
+	if output, err = d.virsh("dominfo"); err == nil {
+		info := parseVirshKeyValues(output)
+		res.CpuTime = virshSeconds(info["CPU time"])
+		res.MaxMem = virshUint(info["Max memory"])
+		res.Mem = virshUint(info["Used memory"])
+	}
+	return
+}
+
+func (d *virshDomain) GetVolumeInfo() (res []VolumeInfo, err error) {
+	if output, err := d.virsh("qemu-monitor-command", "--hmp", volumeMonitorCommand); err == nil {
+		res = parseVolumeInfo(string(output))
+	}
+	return
+}
+
+func (d *virshDomain) CpuStats() (res VirDomainCpuStats, err error) {
+	var output []byte
+	if output, err = d.virsh("cpu-stats", "--total"); err == nil {
+		stats := make(map[string]string)
+		for _, line := range splitLines(output) {
+			if fields := strings.Fields(line); len(fields) >= 2 {
+				stats[fields[0]] = fields[1]
+			}
+		}
+		res = VirDomainCpuStats{
+			CpuTime:    virshSeconds(stats["cpu_time"]),
+			UserTime:   virshSeconds(stats["user_time"]),
+			SystemTime: virshSeconds(stats["system_time"]),
+			VcpuTime:   virshSeconds(stats["vcpu_time"]),
+		}
+	}
+	return
+}
+
+func (d *virshDomain) BlockStats(dev string) (res VirDomainBlockStats, err error) {
+	var output []byte
+	if output, err = d.virsh("domblkstat", "--device", dev); err == nil {
+		stats := parseVirshFieldValues(output)
+		res = VirDomainBlockStats{
+			RdReq:           stats["rd_req"],
+			WrReq:           stats["wr_req"],
+			FlushReq:        stats["flush_operations"],
+			RdBytes:         stats["rd_bytes"],
+			WrBytes:         stats["wr_bytes"],
+			RdTotalTimes:    stats["rd_total_times"],
+			WrTotalTimes:    stats["wr_total_times"],
+			FlushTotalTimes: stats["flush_total_times"],
+		}
+	}
+	return
+}
+
+func (d *virshDomain) BlockInfo(dev string) (res VirDomainBlockInfo, err error) {
+	var output []byte
+	if output, err = d.virsh("domblkinfo", "--device", dev); err == nil {
+		info := parseVirshKeyValues(output)
+		res = VirDomainBlockInfo{
+			Allocation: virshUint(info["Allocation"]),
+			Capacity:   virshUint(info["Capacity"]),
+			Physical:   virshUint(info["Physical"]),
+		}
+	}
+	return
+}
+
+func (d *virshDomain) InterfaceStats(interfaceName string) (res VirDomainInterfaceStats, err error) {
+	var output []byte
+	if output, err = d.virsh("domifstat", "--interface", interfaceName); err == nil {
+		stats := parseVirshFieldValues(output)
+		res = VirDomainInterfaceStats{
+			RxBytes:   stats["rx_bytes"],
+			RxPackets: stats["rx_packets"],
+			RxErrs:    stats["rx_errs"],
+			RxDrop:    stats["rx_drop"],
+			TxBytes:   stats["tx_bytes"],
+			TxPackets: stats["tx_packets"],
+			TxErrs:    stats["tx_errs"],
+			TxDrop:    stats["tx_drop"],
+		}
+	}
+	return
+}
+
+func (d *virshDomain) MemoryStats() (res VirDomainMemoryStat, err error) {
+	var output []byte
+	if output, err = d.virsh("dommemstat"); err == nil {
+		stats := parseVirshFieldValues(output)
+		res.Unused = uint64(stats["unused"])
+		res.Available = uint64(stats["available"])
+	}
+	return
+}
+
+func (d *virshDomain) IOThreads() ([]VirDomainIOThread, error) {
+	output, err := d.virsh("qemu-monitor-command", iothreadsMonitorCommand)
+	if err != nil {
+		return nil, err
+	}
+	return parseIOThreads(string(output))
+}
+
+func (d *virshDomain) StartDirtyRateCalc(seconds int) error {
+	_, err := d.virsh("domdirtyrate-calc", "--seconds", strconv.Itoa(seconds))
+	return err
+}
+
+func (d *virshDomain) QemuAgentCommand(command string) (string, error) {
+	output, err := d.virsh("qemu-agent-command", command)
+	return strings.TrimSpace(string(output)), err
+}
+
+func splitLines(output []byte) []string {
+	var res []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// parseVirshKeyValues parses output lines in the format "<key>: <value>", e.g. of dominfo or pool-info
+func parseVirshKeyValues(output []byte) map[string]string {
+	res := make(map[string]string)
+	for _, line := range splitLines(output) {
+		if index := strings.Index(line, ":"); index > 0 {
+			res[strings.TrimSpace(line[:index])] = strings.TrimSpace(line[index+1:])
+		}
+	}
+	return res
+}
+
+// parseVirshFieldValues parses output lines in the format "[<device>] <field> <value>", e.g. of domblkstat or dommemstat.
+// Values that are not integers are ignored.
+func parseVirshFieldValues(output []byte) map[string]int64 {
+	res := make(map[string]int64)
+	for _, line := range splitLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[len(fields)-1], 10, 64); err == nil {
+			res[fields[len(fields)-2]] = value
+		}
+	}
+	return res
+}
+
+// parseVirshDomstats parses the output of domstats, which contains a "Domain: '<name>'" line for every domain,
+// followed by its statistics in the format "<key>=<value>"
+func parseVirshDomstats(output []byte) (map[string]map[string]string, error) {
+	res := make(map[string]map[string]string)
+	var current map[string]string
+	for i, line := range splitLines(output) {
+		if strings.HasPrefix(line, "Domain:") {
+			name := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "Domain:")), "'")
+			current = make(map[string]string)
+			res[name] = current
+			continue
+		}
+		index := strings.Index(line, "=")
+		if current == nil || index <= 0 {
+			return nil, fmt.Errorf("Unexpected line %v in output of virsh domstats: %v", i+1, line)
+		}
+		current[line[:index]] = line[index+1:]
+	}
+	return res, nil
+}
+
+// convertVirshDomstats converts the statistics of one domain returned by domstats, named like the typed parameters
+// of virConnectGetAllDomainStats(). Fields that are not supported by the hypervisor are left at zero.
+func convertVirshDomstats(values map[string]string) VirDomainStats {
+	get := func(key string) uint64 {
+		return virshUint(values[key])
+	}
+	res := VirDomainStats{
+		Info: DomainInfo{
+			CpuTime: get("cpu.time"),
+			MaxMem:  get("balloon.maximum"),
+			Mem:     get("balloon.current"),
+		},
+		Cpu: VirDomainCpuStats{
+			CpuTime:    get("cpu.time"),
+			UserTime:   get("cpu.user"),
+			SystemTime: get("cpu.system"),
+		},
+		Vcpu: VirDomainVcpuStats{
+			HaltPollSuccess: get("cpu.haltpoll.success.time"),
+			HaltPollFail:    get("cpu.haltpoll.fail.time"),
+		},
+		Memory: VirDomainMemoryStat{
+			Unused:    get("balloon.unused"),
+			Available: get("balloon.available"),
+		},
+		Block:     make(map[string]VirDomainBlockStats),
+		BlockInfo: make(map[string]VirDomainBlockInfo),
+		Net:       make(map[string]VirDomainInterfaceStats),
+	}
+	for i := uint64(0); i < get("vcpu.maximum"); i++ {
+		prefix := fmt.Sprintf("vcpu.%v.", i)
+		if _, ok := values[prefix+"state"]; !ok {
+			continue
+		}
+		res.Vcpu.Vcpus++
+		if values[prefix+"halted"] == "yes" {
+			res.Vcpu.Halted++
+		}
+		res.Vcpu.Time += get(prefix + "time")
+		res.Vcpu.Wait += get(prefix + "wait")
+	}
+	res.Cpu.VcpuTime = res.Vcpu.Time
+	for i := uint64(0); i < get("block.count"); i++ {
+		prefix := fmt.Sprintf("block.%v.", i)
+		name, ok := values[prefix+"name"]
+		if !ok {
+			continue
+		}
+		res.Block[name] = VirDomainBlockStats{
+			RdReq:           int64(get(prefix + "rd.reqs")),
+			WrReq:           int64(get(prefix + "wr.reqs")),
+			FlushReq:        int64(get(prefix + "fl.reqs")),
+			RdBytes:         int64(get(prefix + "rd.bytes")),
+			WrBytes:         int64(get(prefix + "wr.bytes")),
+			RdTotalTimes:    int64(get(prefix + "rd.times")),
+			WrTotalTimes:    int64(get(prefix + "wr.times")),
+			FlushTotalTimes: int64(get(prefix + "fl.times")),
+		}
+		res.BlockInfo[name] = VirDomainBlockInfo{
+			Allocation: get(prefix + "allocation"),
+			Capacity:   get(prefix + "capacity"),
+			Physical:   get(prefix + "physical"),
+		}
+	}
+	for i := uint64(0); i < get("net.count"); i++ {
+		prefix := fmt.Sprintf("net.%v.", i)
+		name, ok := values[prefix+"name"]
+		if !ok {
+			continue
+		}
+		res.Net[name] = VirDomainInterfaceStats{
+			RxBytes:   int64(get(prefix + "rx.bytes")),
+			RxPackets: int64(get(prefix + "rx.pkts")),
+			RxErrs:    int64(get(prefix + "rx.errs")),
+			RxDrop:    int64(get(prefix + "rx.drop")),
+			TxBytes:   int64(get(prefix + "tx.bytes")),
+			TxPackets: int64(get(prefix + "tx.pkts")),
+			TxErrs:    int64(get(prefix + "tx.errs")),
+			TxDrop:    int64(get(prefix + "tx.drop")),
+		}
+	}
+	if status, ok := values["dirtyrate.calc_status"]; ok {
+		res.DirtyRate.Measuring = virshUint(status) == dirtyRateMeasuring
+		res.DirtyRate.Measured = virshUint(status) == dirtyRateMeasured
+		res.DirtyRate.BytesPerSecond = get("dirtyrate.megabytes_per_second") * 1024 * 1024
+	}
+	return res
+}
+
+// virshUint parses the first field of the given value as an integer, e.g. "2097152 KiB". Invalid values result in zero.
+func virshUint(value string) uint64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	res, _ := strconv.ParseUint(fields[0], 10, 64)
+	return res
+}
+
+// virshSeconds parses a duration in seconds, e.g. "12.5s" or "12.500000000 seconds", and returns it in nanoseconds
+func virshSeconds(value string) uint64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "s"), 64)
+	if err != nil {
+		return 0
+	}
+	return uint64(seconds * float64(time.Second))
+}
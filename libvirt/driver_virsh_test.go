@@ -0,0 +1,85 @@
+package libvirt
+
+import (
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type VirshTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestVirsh(t *testing.T) {
+	suite.Run(t, new(VirshTestSuite))
+}
+
+func (suite *VirshTestSuite) TestDomstats() {
+	domains, err := parseVirshDomstats([]byte(`Domain: 'vm1'
+  cpu.time=5000000000
+  cpu.user=1000000000
+  cpu.system=2000000000
+  balloon.current=1048576
+  balloon.maximum=2097152
+  balloon.unused=524288
+  balloon.available=1000000
+  vcpu.current=2
+  vcpu.maximum=4
+  vcpu.0.state=1
+  vcpu.0.time=1500000000
+  vcpu.0.wait=100
+  vcpu.0.halted=yes
+  vcpu.1.state=1
+  vcpu.1.time=500000000
+  vcpu.1.wait=200
+  vcpu.1.halted=no
+  net.count=1
+  net.0.name=vnet0
+  net.0.rx.bytes=1000
+  net.0.rx.pkts=10
+  net.0.tx.bytes=2000
+  net.0.tx.drop=3
+  block.count=1
+  block.0.name=vda
+  block.0.rd.reqs=5
+  block.0.rd.bytes=4096
+  block.0.wr.times=700
+  block.0.capacity=10737418240
+  dirtyrate.calc_status=2
+  dirtyrate.megabytes_per_second=3
+
+Domain: 'vm2'
+  cpu.time=1
+`))
+	suite.NoError(err)
+	suite.Len(domains, 2)
+	suite.Equal(map[string]string{"cpu.time": "1"}, domains["vm2"])
+
+	stats := convertVirshDomstats(domains["vm1"])
+	suite.Equal(DomainInfo{CpuTime: 5000000000, MaxMem: 2097152, Mem: 1048576}, stats.Info)
+	suite.Equal(VirDomainCpuStats{CpuTime: 5000000000, UserTime: 1000000000, SystemTime: 2000000000, VcpuTime: 2000000000}, stats.Cpu)
+	suite.Equal(VirDomainVcpuStats{Vcpus: 2, Halted: 1, Time: 2000000000, Wait: 300}, stats.Vcpu)
+	suite.Equal(VirDomainMemoryStat{Unused: 524288, Available: 1000000}, stats.Memory)
+	suite.Equal(map[string]VirDomainInterfaceStats{"vnet0": {RxBytes: 1000, RxPackets: 10, TxBytes: 2000, TxDrop: 3}}, stats.Net)
+	suite.Equal(map[string]VirDomainBlockStats{"vda": {RdReq: 5, RdBytes: 4096, WrTotalTimes: 700}}, stats.Block)
+	suite.Equal(map[string]VirDomainBlockInfo{"vda": {Capacity: 10737418240}}, stats.BlockInfo)
+	suite.Equal(VirDomainDirtyRate{Measured: true, BytesPerSecond: 3 * 1024 * 1024}, stats.DirtyRate)
+
+	_, err = parseVirshDomstats([]byte("cpu.time=1\n"))
+	suite.Error(err)
+}
+
+func (suite *VirshTestSuite) TestOutputFormats() {
+	info := parseVirshKeyValues([]byte("Id:             3\nName:           vm1\nCPU time:       12.5s\nMax memory:     2097152 KiB\n"))
+	suite.Equal("vm1", info["Name"])
+	suite.Equal(uint64(12500000000), virshSeconds(info["CPU time"]))
+	suite.Equal(uint64(2097152), virshUint(info["Max memory"]))
+	suite.Equal(uint64(0), virshUint(info["Missing"]))
+
+	suite.Equal(uint64(1500000000), virshSeconds("1.500000000 seconds"))
+	suite.Equal(uint64(0), virshSeconds("invalid"))
+
+	suite.Equal(map[string]int64{"rd_req": 10, "rd_bytes": 4096}, parseVirshFieldValues([]byte("vda rd_req 10\nvda rd_bytes 4096\nvda invalid x\n")))
+	suite.Equal(map[string]int64{"actual": 1024, "unused": 512}, parseVirshFieldValues([]byte("actual 1024\nunused 512\n")))
+}
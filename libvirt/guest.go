@@ -0,0 +1,234 @@
+package libvirt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	guestFsInfoCommand          = `{"execute":"guest-get-fsinfo"}`
+	guestMemoryBlocksCommand    = `{"execute":"guest-get-memory-blocks"}`
+	guestMemoryBlockInfoCommand = `{"execute":"guest-get-memory-block-info"}`
+	guestLoadCommand            = `{"execute":"guest-get-load"}`
+)
+
+// guestAgentCollector queries in-guest metrics through the QEMU guest agent: file system usage, online memory
+// and, if supported by the guest agent (QEMU 9.1 and newer), the load average of the guest OS.
+type guestAgentCollector struct {
+	vmSubCollectorImpl
+	fs           map[string]guestFs
+	memoryOnline uint64
+	blocksOnline uint64
+	load         guestLoad
+	hasLoad      bool
+}
+
+type guestFs struct {
+	used  uint64
+	total uint64
+}
+
+type guestLoad struct {
+	Load1  float64 `json:"load1m"`
+	Load5  float64 `json:"load5m"`
+	Load15 float64 `json:"load15m"`
+}
+
+func NewGuestAgentCollector(parent *vmCollector) *guestAgentCollector {
+	return &guestAgentCollector{
+		vmSubCollectorImpl: parent.child("guest"),
+	}
+}
+
+func (col *guestAgentCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.fs = nil
+	return nil, col.update(false)
+}
+
+func (col *guestAgentCollector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *guestAgentCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *guestAgentCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.parent.prefix() + "guest/"
+	res := collector.MetricReaderMap{
+		prefix + "mem/online":        col.readMemoryOnline,
+		prefix + "mem/blocks/online": col.readBlocksOnline,
+		prefix + "fs/all/used":       col.readFsUsed(""),
+		prefix + "fs/all/total":      col.readFsTotal(""),
+	}
+	for name := range col.fs {
+		res[prefix+"fs/"+name+"/used"] = col.readFsUsed(name)
+		res[prefix+"fs/"+name+"/total"] = col.readFsTotal(name)
+	}
+	if col.hasLoad {
+		res[prefix+"load/1"] = col.readLoad(&col.load.Load1)
+		res[prefix+"load/5"] = col.readLoad(&col.load.Load5)
+		res[prefix+"load/15"] = col.readLoad(&col.load.Load15)
+	}
+	return res
+}
+
+func (col *guestAgentCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.parent.prefix() + "guest/"
+	res := collector.MetricMetadataMap{
+		prefix + "mem/online":        collector.GaugeMetric(collector.UnitBytes, "Memory online inside the guest"),
+		prefix + "mem/blocks/online": collector.GaugeMetric(collector.UnitCount, "Number of memory blocks online inside the guest"),
+		prefix + "fs/all/used":       collector.GaugeMetric(collector.UnitBytes, "Used space of all file systems inside the guest"),
+		prefix + "fs/all/total":      collector.GaugeMetric(collector.UnitBytes, "Total space of all file systems inside the guest"),
+	}
+	for name := range col.fs {
+		res[prefix+"fs/"+name+"/used"] = collector.GaugeMetric(collector.UnitBytes, "Used space of a file system inside the guest")
+		res[prefix+"fs/"+name+"/total"] = collector.GaugeMetric(collector.UnitBytes, "Total space of a file system inside the guest")
+	}
+	if col.hasLoad {
+		for _, load := range []string{"1", "5", "15"} {
+			res[prefix+"load/"+load] = collector.GaugeMetric(collector.UnitNone, "Load average of the guest OS ("+load+" minute(s))")
+		}
+	}
+	return res
+}
+
+func (col *guestAgentCollector) update(checkChange bool) error {
+	fs, err := col.queryFsInfo()
+	if err != nil {
+		return err
+	}
+	if err := col.queryMemory(); err != nil {
+		return err
+	}
+	// The guest-get-load command is not supported by older guest agents
+	var load guestLoad
+	hasLoad := col.agentCommand(guestLoadCommand, &load) == nil
+
+	changed := hasLoad != col.hasLoad || len(fs) != len(col.fs)
+	for name := range fs {
+		if _, ok := col.fs[name]; !ok {
+			changed = true
+		}
+	}
+	col.fs, col.load, col.hasLoad = fs, load, hasLoad
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *guestAgentCollector) queryFsInfo() (map[string]guestFs, error) {
+	var fsInfo []struct {
+		Mountpoint string `json:"mountpoint"`
+		Type       string `json:"type"`
+		UsedBytes  uint64 `json:"used-bytes"`
+		TotalBytes uint64 `json:"total-bytes"`
+	}
+	if err := col.agentCommand(guestFsInfoCommand, &fsInfo); err != nil {
+		return nil, err
+	}
+	fs := make(map[string]guestFs, len(fsInfo))
+	for _, info := range fsInfo {
+		if info.TotalBytes == 0 {
+			// Pseudo file systems, or guest agent older than QEMU 5.0
+			continue
+		}
+		fs[guestMountpointName(info.Mountpoint)] = guestFs{used: info.UsedBytes, total: info.TotalBytes}
+	}
+	return fs, nil
+}
+
+func (col *guestAgentCollector) queryMemory() error {
+	var blocks []struct {
+		Online bool `json:"online"`
+	}
+	if err := col.agentCommand(guestMemoryBlocksCommand, &blocks); err != nil {
+		return err
+	}
+	var blockInfo struct {
+		Size uint64 `json:"size"`
+	}
+	if err := col.agentCommand(guestMemoryBlockInfoCommand, &blockInfo); err != nil {
+		return err
+	}
+	col.blocksOnline = 0
+	for _, block := range blocks {
+		if block.Online {
+			col.blocksOnline++
+		}
+	}
+	col.memoryOnline = col.blocksOnline * blockInfo.Size
+	return nil
+}
+
+// agentCommand executes the given guest agent command and parses the "return" field of the response into the result.
+func (col *guestAgentCollector) agentCommand(command string, result interface{}) error {
+	response, err := col.parent.domain.QemuAgentCommand(command)
+	if err != nil {
+		return fmt.Errorf("Guest agent command %v failed for %v: %v", command, col.parent.name, err)
+	}
+	var parsed struct {
+		Return json.RawMessage `json:"return"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return fmt.Errorf("Failed to parse response of guest agent command %v for %v: %v", command, col.parent.name, err)
+	}
+	if err := json.Unmarshal(parsed.Return, result); err != nil {
+		return fmt.Errorf("Unexpected response of guest agent command %v for %v: %v", command, col.parent.name, err)
+	}
+	return nil
+}
+
+func guestMountpointName(mountpoint string) string {
+	name := strings.Trim(mountpoint, "/\\")
+	if name == "" {
+		return "root"
+	}
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "", " ", "_").Replace(name)
+}
+
+func (col *guestAgentCollector) readFsUsed(name string) collector.MetricReader {
+	return func() bitflow.Value {
+		if name == "" {
+			var sum uint64
+			for _, fs := range col.fs {
+				sum += fs.used
+			}
+			return bitflow.Value(sum)
+		}
+		return bitflow.Value(col.fs[name].used)
+	}
+}
+
+func (col *guestAgentCollector) readFsTotal(name string) collector.MetricReader {
+	return func() bitflow.Value {
+		if name == "" {
+			var sum uint64
+			for _, fs := range col.fs {
+				sum += fs.total
+			}
+			return bitflow.Value(sum)
+		}
+		return bitflow.Value(col.fs[name].total)
+	}
+}
+
+func (col *guestAgentCollector) readLoad(value *float64) collector.MetricReader {
+	return func() bitflow.Value {
+		return bitflow.Value(*value)
+	}
+}
+
+func (col *guestAgentCollector) readMemoryOnline() bitflow.Value {
+	return bitflow.Value(col.memoryOnline)
+}
+
+func (col *guestAgentCollector) readBlocksOnline() bitflow.Value {
+	return bitflow.Value(col.blocksOnline)
+}
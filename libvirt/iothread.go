@@ -0,0 +1,116 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/shirou/gopsutil/process"
+)
+
+// ioThreadCollector reports the CPU usage of the IOThreads of a VM as libvirt/<vm>/iothread/<id>/cpu.
+// The thread IDs are obtained from the QEMU monitor, the CPU times are read from the /proc filesystem,
+// so the metrics are only available when libvirt is running on the local host.
+type ioThreadCollector struct {
+	vmSubCollectorImpl
+	threads map[string]*ioThread
+	total   *collector.ValueRing
+}
+
+type ioThread struct {
+	VirDomainIOThread
+	cpu *collector.ValueRing
+}
+
+func NewIOThreadCollector(parent *vmCollector) *ioThreadCollector {
+	return &ioThreadCollector{
+		vmSubCollectorImpl: parent.child("iothread"),
+	}
+}
+
+func (col *ioThreadCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	threads, err := col.parent.domain.IOThreads()
+	if err != nil {
+		return nil, err
+	}
+	factory := col.parent.parent.factory
+	col.total = factory.NewValueRing()
+	col.threads = make(map[string]*ioThread, len(threads))
+	for _, thread := range threads {
+		col.threads[thread.Id] = &ioThread{VirDomainIOThread: thread, cpu: factory.NewValueRing()}
+	}
+	return nil, nil
+}
+
+func (col *ioThreadCollector) Update(ctx context.Context) error {
+	threads, err := col.parent.domain.IOThreads()
+	if err != nil {
+		return err
+	}
+	if len(threads) != len(col.threads) {
+		return collector.MetricsChanged
+	}
+	var total float64
+	for _, info := range threads {
+		thread, ok := col.threads[info.Id]
+		if !ok || thread.ThreadId != info.ThreadId {
+			return collector.MetricsChanged
+		}
+		thread.VirDomainIOThread = info
+		proc, err := process.NewProcess(int32(info.ThreadId))
+		if err != nil {
+			return fmt.Errorf("IOThread %v (thread %v) of %v not found: %v", info.Id, info.ThreadId, col.parent.name, err)
+		}
+		times, err := proc.Times()
+		if err != nil {
+			return fmt.Errorf("Failed to read CPU times of IOThread %v (thread %v) of %v: %v", info.Id, info.ThreadId, col.parent.name, err)
+		}
+		cpu := times.User + times.System
+		thread.cpu.Add(collector.StoredValue(cpu))
+		total += cpu
+	}
+	col.total.Add(collector.StoredValue(total))
+	return nil
+}
+
+func (col *ioThreadCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.parent.prefix() + "iothread/"
+	res := collector.MetricReaderMap{
+		prefix + "count": col.readCount,
+		prefix + "cpu":   readCpuPercent(col.total),
+	}
+	for id, thread := range col.threads {
+		res[prefix+id+"/cpu"] = readCpuPercent(thread.cpu)
+		res[prefix+id+"/poll-max"] = thread.readPollMax
+	}
+	return res
+}
+
+func (col *ioThreadCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.parent.prefix() + "iothread/"
+	res := collector.MetricMetadataMap{
+		prefix + "count": collector.GaugeMetric(collector.UnitCount, "Number of IOThreads of the VM"),
+		prefix + "cpu":   collector.DerivedMetric(collector.UnitPercent, "CPU usage of all IOThreads of the VM"),
+	}
+	for id := range col.threads {
+		res[prefix+id+"/cpu"] = collector.DerivedMetric(collector.UnitPercent, "CPU usage of the IOThread")
+		res[prefix+id+"/poll-max"] = collector.GaugeMetric(collector.UnitNone, "Maximum polling time of the IOThread in nanoseconds")
+	}
+	return res
+}
+
+func (col *ioThreadCollector) readCount() bitflow.Value {
+	return bitflow.Value(len(col.threads))
+}
+
+func (thread *ioThread) readPollMax() bitflow.Value {
+	return bitflow.Value(thread.PollMaxNs)
+}
+
+// readCpuPercent converts the rate of a CPU time in seconds to percent
+func readCpuPercent(ring *collector.ValueRing) collector.MetricReader {
+	return func() bitflow.Value {
+		return ring.GetDiff() * 100
+	}
+}
@@ -1,6 +1,7 @@
 package libvirt
 
 import (
+	"context"
 	"github.com/bitflow-stream/go-bitflow-collector"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 )
@@ -26,14 +27,11 @@ func (col *memoryStatCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *memoryStatCollector) Update() error {
-	if memStats, err := col.parent.domain.MemoryStats(); err != nil {
-		return err
-	} else {
-		col.unused = memStats.Unused
-		col.available = memStats.Available
-		return nil
-	}
+func (col *memoryStatCollector) Update(ctx context.Context) error {
+	memStats := col.parent.stats.Memory
+	col.unused = memStats.Unused
+	col.available = memStats.Available
+	return nil
 }
 
 func (col *memoryStatCollector) readAvailable() bitflow.Value {
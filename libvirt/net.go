@@ -1,6 +1,7 @@
 package libvirt
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
@@ -27,12 +28,11 @@ func (col *interfaceStatCollector) Metrics() collector.MetricReaderMap {
 	return col.net.Metrics(col.parent.prefix() + "net-io")
 }
 
-func (col *interfaceStatCollector) Update() error {
+func (col *interfaceStatCollector) Update(ctx context.Context) error {
 	for _, interfaceName := range col.interfaces {
-		// More detailed alternative: domain.GetInterfaceParameters()
-		stats, err := col.parent.domain.InterfaceStats(interfaceName)
-		if err != nil {
-			return fmt.Errorf("VM %v to update vNIC stats for %s: %v", col.parent.name, interfaceName, err)
+		stats, ok := col.parent.stats.Net[interfaceName]
+		if !ok {
+			return fmt.Errorf("VM %v: no vNIC stats for %s", col.parent.name, interfaceName)
 		}
 		col.net.Bytes.Add(collector.StoredValue(stats.RxBytes + stats.TxBytes))
 		col.net.Packets.Add(collector.StoredValue(stats.RxPackets + stats.TxPackets))
@@ -0,0 +1,138 @@
+package libvirt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow-collector/psutil"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	psnet "github.com/shirou/gopsutil/net"
+)
+
+// networkCollector reports the number of DHCP leases of all active libvirt-managed virtual networks as
+// libvirt/net/<network>/dhcp-leases. If the bridge of a network is a local interface (i.e. libvirt is
+// running on the local host), the traffic of the bridge is reported as libvirt/net/<network>/net-io/...
+type networkCollector struct {
+	collector.AbstractCollector
+	parent   *Collector
+	networks map[string]*virtualNetwork
+}
+
+type virtualNetwork struct {
+	info       VirNetworkInfo
+	hasTraffic bool
+	net        psutil.NetIoCounters
+}
+
+func (parent *Collector) newNetworkCollector() *networkCollector {
+	return &networkCollector{
+		AbstractCollector: parent.Child("networks"),
+		parent:            parent,
+	}
+}
+
+func (col *networkCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *networkCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.networks = make(map[string]*virtualNetwork)
+	return nil, col.update(false)
+}
+
+func (col *networkCollector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *networkCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *networkCollector) update(checkChange bool) error {
+	infos, err := col.parent.driver.NetworkInfos()
+	if err != nil {
+		return err
+	}
+	localInterfaces := make(map[string]*psnet.IOCountersStat)
+	if counters, err := psnet.IOCountersByFile(true, hostfs.Current().NamespaceProcPath("net", "dev")); err == nil {
+		for i := range counters {
+			localInterfaces[counters[i].Name] = &counters[i]
+		}
+	}
+
+	changed := len(infos) != len(col.networks)
+	for _, info := range infos {
+		name := strings.Replace(info.Name, "/", "_", -1)
+		network, ok := col.networks[name]
+		if !ok {
+			if checkChange {
+				return collector.MetricsChanged
+			}
+			network = &virtualNetwork{net: psutil.NewNetIoCounters(col.parent.factory)}
+			col.networks[name] = network
+		}
+		network.info = info
+		stats, hasTraffic := localInterfaces[info.Bridge]
+		if hasTraffic != network.hasTraffic {
+			changed = true
+			network.hasTraffic = hasTraffic
+		}
+		if hasTraffic {
+			network.net.Add(stats)
+		}
+	}
+	if changed {
+		if !checkChange {
+			col.removeInactive(infos)
+		} else {
+			return collector.MetricsChanged
+		}
+	}
+	return nil
+}
+
+func (col *networkCollector) removeInactive(infos []VirNetworkInfo) {
+	active := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		active[strings.Replace(info.Name, "/", "_", -1)] = true
+	}
+	for name := range col.networks {
+		if !active[name] {
+			delete(col.networks, name)
+		}
+	}
+}
+
+func (col *networkCollector) Metrics() collector.MetricReaderMap {
+	res := make(collector.MetricReaderMap)
+	for name, network := range col.networks {
+		prefix := "libvirt/net/" + name + "/"
+		res[prefix+"dhcp-leases"] = network.readLeases
+		if network.hasTraffic {
+			for metric, reader := range network.net.Metrics(prefix + "net-io") {
+				res[metric] = reader
+			}
+		}
+	}
+	return res
+}
+
+func (col *networkCollector) MetricsMetadata() collector.MetricMetadataMap {
+	res := make(collector.MetricMetadataMap)
+	for name, network := range col.networks {
+		prefix := "libvirt/net/" + name + "/"
+		res[prefix+"dhcp-leases"] = collector.GaugeMetric(collector.UnitCount, "Number of active DHCP leases of the virtual network")
+		if network.hasTraffic {
+			for metric, metadata := range network.net.MetricsMetadata(prefix + "net-io") {
+				res[metric] = metadata
+			}
+		}
+	}
+	return res
+}
+
+func (network *virtualNetwork) readLeases() bitflow.Value {
+	return bitflow.Value(network.info.DhcpLeases)
+}
@@ -0,0 +1,137 @@
+package libvirt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// storagePoolCollector reports the capacity and allocation of all active storage pools as libvirt/pool/<pool>/...,
+// and the allocation of all volumes in the pools as libvirt/pool/<pool>/volume/<volume>/...
+type storagePoolCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+	pools  map[string]VirStoragePoolStats
+}
+
+func (parent *Collector) newStoragePoolCollector() *storagePoolCollector {
+	return &storagePoolCollector{
+		AbstractCollector: parent.Child("storage-pools"),
+		parent:            parent,
+	}
+}
+
+func (col *storagePoolCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *storagePoolCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.pools = nil
+	return nil, col.update(false)
+}
+
+func (col *storagePoolCollector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *storagePoolCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *storagePoolCollector) update(checkChange bool) error {
+	stats, err := col.parent.driver.StoragePoolStats()
+	if err != nil {
+		return err
+	}
+	pools := make(map[string]VirStoragePoolStats, len(stats))
+	changed := len(stats) != len(col.pools)
+	for _, pool := range stats {
+		name := poolMetricName(pool.Name)
+		pools[name] = pool
+		if previous, ok := col.pools[name]; !ok || !sameVolumes(previous, pool) {
+			changed = true
+		}
+	}
+	col.pools = pools
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func sameVolumes(pool1, pool2 VirStoragePoolStats) bool {
+	if len(pool1.Volumes) != len(pool2.Volumes) {
+		return false
+	}
+	for i := range pool1.Volumes {
+		if pool1.Volumes[i].Name != pool2.Volumes[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+func (col *storagePoolCollector) Metrics() collector.MetricReaderMap {
+	res := make(collector.MetricReaderMap)
+	for name, pool := range col.pools {
+		prefix := "libvirt/pool/" + name + "/"
+		res[prefix+"capacity"] = col.readPool(name, func(pool VirStoragePoolStats) uint64 { return pool.Capacity })
+		res[prefix+"allocation"] = col.readPool(name, func(pool VirStoragePoolStats) uint64 { return pool.Allocation })
+		res[prefix+"available"] = col.readPool(name, func(pool VirStoragePoolStats) uint64 { return pool.Available })
+		res[prefix+"percent"] = col.readPercent(name)
+		for i, vol := range pool.Volumes {
+			volPrefix := prefix + "volume/" + poolMetricName(vol.Name) + "/"
+			res[volPrefix+"capacity"] = col.readVolume(name, i, func(vol VirStorageVolumeStats) uint64 { return vol.Capacity })
+			res[volPrefix+"allocation"] = col.readVolume(name, i, func(vol VirStorageVolumeStats) uint64 { return vol.Allocation })
+		}
+	}
+	return res
+}
+
+func (col *storagePoolCollector) MetricsMetadata() collector.MetricMetadataMap {
+	res := make(collector.MetricMetadataMap)
+	for name, pool := range col.pools {
+		prefix := "libvirt/pool/" + name + "/"
+		res[prefix+"capacity"] = collector.GaugeMetric(collector.UnitBytes, "Capacity of the storage pool")
+		res[prefix+"allocation"] = collector.GaugeMetric(collector.UnitBytes, "Allocated space of the storage pool")
+		res[prefix+"available"] = collector.GaugeMetric(collector.UnitBytes, "Free space of the storage pool")
+		res[prefix+"percent"] = collector.GaugeMetric(collector.UnitPercent, "Allocated space of the storage pool relative to its capacity")
+		for _, vol := range pool.Volumes {
+			volPrefix := prefix + "volume/" + poolMetricName(vol.Name) + "/"
+			res[volPrefix+"capacity"] = collector.GaugeMetric(collector.UnitBytes, "Virtual capacity of the volume")
+			res[volPrefix+"allocation"] = collector.GaugeMetric(collector.UnitBytes, "Space allocated by the volume in the storage pool")
+		}
+	}
+	return res
+}
+
+func (col *storagePoolCollector) readPool(name string, value func(pool VirStoragePoolStats) uint64) collector.MetricReader {
+	return func() bitflow.Value {
+		return bitflow.Value(value(col.pools[name]))
+	}
+}
+
+func (col *storagePoolCollector) readPercent(name string) collector.MetricReader {
+	return func() bitflow.Value {
+		pool := col.pools[name]
+		if pool.Capacity == 0 {
+			return 0
+		}
+		return bitflow.Value(pool.Allocation) / bitflow.Value(pool.Capacity) * 100
+	}
+}
+
+func (col *storagePoolCollector) readVolume(name string, index int, value func(vol VirStorageVolumeStats) uint64) collector.MetricReader {
+	return func() bitflow.Value {
+		if volumes := col.pools[name].Volumes; index < len(volumes) {
+			return bitflow.Value(value(volumes[index]))
+		}
+		return 0
+	}
+}
+
+func poolMetricName(name string) string {
+	return strings.Replace(name, "/", "_", -1)
+}
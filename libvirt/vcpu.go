@@ -0,0 +1,76 @@
+package libvirt
+
+import (
+	"context"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// vcpuCollector reports indicators for host CPU overcommitment: the steal time of the vCPUs (time they were
+// ready to run, but not scheduled by the host) and the success of halt polling (requires libvirt 6.3 or newer).
+type vcpuCollector struct {
+	vmSubCollectorImpl
+	stats           VirDomainVcpuStats
+	wait            *collector.ValueRing
+	haltPollSuccess *collector.ValueRing
+	haltPollFail    *collector.ValueRing
+}
+
+func NewVcpuCollector(parent *vmCollector) *vcpuCollector {
+	factory := parent.parent.factory
+	return &vcpuCollector{
+		vmSubCollectorImpl: parent.child("vcpu"),
+		wait:               factory.NewValueRing(),
+		haltPollSuccess:    factory.NewValueRing(),
+		haltPollFail:       factory.NewValueRing(),
+	}
+}
+
+func (col *vcpuCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.parent.prefix()
+	return collector.MetricReaderMap{
+		prefix + "vcpu/count":            col.readCount,
+		prefix + "vcpu/halted":           col.readHalted,
+		prefix + "vcpu/steal":            col.wait.GetDiff,
+		prefix + "vcpu/steal/per-vcpu":   col.readStealPerVcpu,
+		prefix + "vcpu/haltpoll/success": col.haltPollSuccess.GetDiff,
+		prefix + "vcpu/haltpoll/fail":    col.haltPollFail.GetDiff,
+	}
+}
+
+func (col *vcpuCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.parent.prefix()
+	return collector.MetricMetadataMap{
+		prefix + "vcpu/count":            collector.GaugeMetric(collector.UnitCount, "Number of vCPUs of the VM"),
+		prefix + "vcpu/halted":           collector.GaugeMetric(collector.UnitCount, "Number of halted vCPUs of the VM"),
+		prefix + "vcpu/steal":            collector.DerivedMetric(collector.UnitPercent, "Time the vCPUs were ready to run, but not scheduled by the host, summed up for all vCPUs"),
+		prefix + "vcpu/steal/per-vcpu":   collector.DerivedMetric(collector.UnitPercent, "Average steal time of one vCPU"),
+		prefix + "vcpu/haltpoll/success": collector.DerivedMetric(collector.UnitPercent, "CPU time spent in successful halt polling"),
+		prefix + "vcpu/haltpoll/fail":    collector.DerivedMetric(collector.UnitPercent, "CPU time spent in unsuccessful halt polling"),
+	}
+}
+
+func (col *vcpuCollector) Update(ctx context.Context) error {
+	stats := col.parent.stats.Vcpu
+	col.stats = stats
+	col.wait.Add(LogbackCpuVal(stats.Wait))
+	col.haltPollSuccess.Add(LogbackCpuVal(stats.HaltPollSuccess))
+	col.haltPollFail.Add(LogbackCpuVal(stats.HaltPollFail))
+	return nil
+}
+
+func (col *vcpuCollector) readCount() bitflow.Value {
+	return bitflow.Value(col.stats.Vcpus)
+}
+
+func (col *vcpuCollector) readHalted() bitflow.Value {
+	return bitflow.Value(col.stats.Halted)
+}
+
+func (col *vcpuCollector) readStealPerVcpu() bitflow.Value {
+	if col.stats.Vcpus == 0 {
+		return 0
+	}
+	return col.wait.GetDiff() / bitflow.Value(col.stats.Vcpus)
+}
@@ -1,6 +1,7 @@
 package libvirt
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -14,25 +15,38 @@ type vmCollector struct {
 	name          string
 	domain        Domain
 	subCollectors []vmSubCollector
+
+	// Updated by the domainStatsCollector in every update round
+	statsCollector *domainStatsCollector
+	stats          VirDomainStats
 }
 
-func (parent *Collector) newVmCollector(name string, domain Domain) *vmCollector {
+func (parent *Collector) newVmCollector(name string, domain Domain, stats *domainStatsCollector) *vmCollector {
 	return &vmCollector{
 		AbstractCollector: parent.Child(name),
 		parent:            parent,
 		name:              name,
 		domain:            domain,
+		statsCollector:    stats,
 	}
 }
 
-func (col *vmCollector) Init() ([]collector.Collector, error) {
+func (col *vmCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	col.subCollectors = []vmSubCollector{
 		NewVmGeneralCollector(col),
 		NewMemoryCollector(col),
 		NewCpuCollector(col),
+		NewVcpuCollector(col),
+		NewIOThreadCollector(col),
 		NewBlockCollector(col),
 		NewInterfaceStatCollector(col),
 	}
+	if col.parent.DirtyRatePeriod > 0 {
+		col.subCollectors = append(col.subCollectors, NewDirtyRateCollector(col))
+	}
+	if col.parent.GuestAgent {
+		col.subCollectors = append(col.subCollectors, NewGuestAgentCollector(col))
+	}
 	collectors := make([]collector.Collector, len(col.subCollectors))
 	for i, subCollector := range col.subCollectors {
 		collectors[i] = subCollector
@@ -40,7 +54,7 @@ func (col *vmCollector) Init() ([]collector.Collector, error) {
 	return collectors, nil
 }
 
-func (col *vmCollector) Update() error {
+func (col *vmCollector) Update(ctx context.Context) error {
 	xmlData, err := col.domain.GetXML()
 	if err != nil {
 		return fmt.Errorf("Failed to retrieve XML domain description of %s: %v", col.name, err)
@@ -56,7 +70,7 @@ func (col *vmCollector) Update() error {
 }
 
 func (col *vmCollector) Depends() []collector.Collector {
-	return []collector.Collector{col.parent}
+	return []collector.Collector{col.parent, col.statsCollector}
 }
 
 func (col *vmCollector) prefix() string {
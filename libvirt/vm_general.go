@@ -1,6 +1,7 @@
 package libvirt
 
 import (
+	"context"
 	"time"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
@@ -30,12 +31,10 @@ func (col *vmGeneralCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *vmGeneralCollector) Update() (err error) {
-	col.info, err = col.parent.domain.GetInfo()
-	if err == nil {
-		col.cpu.Add(LogbackCpuVal(col.info.CpuTime))
-	}
-	return
+func (col *vmGeneralCollector) Update(ctx context.Context) error {
+	col.info = col.parent.stats.Info
+	col.cpu.Add(LogbackCpuVal(col.info.CpuTime))
+	return nil
 }
 
 func (col *vmGeneralCollector) readMaxMem() bitflow.Value {
@@ -0,0 +1,150 @@
+package mdraid
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	DefaultMdstatFile = "/proc/mdstat"
+	DefaultSysBlock   = "/sys/block"
+)
+
+// Collector reports the state of all Linux software RAID arrays, as shown in /proc/mdstat. Metrics of the individual
+// arrays are named mdraid/<array>/... The number of mismatched sectors found by the latest check of an array is read
+// from /sys/block/<array>/md/mismatch_cnt.
+type Collector struct {
+	collector.AbstractCollector
+	MdstatFile string
+	SysBlock   string
+
+	lock     sync.Mutex
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewMdraidCollector() *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("mdraid"),
+		MdstatFile:        DefaultMdstatFile,
+		SysBlock:          DefaultSysBlock,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.gauges = nil
+	return nil, col.update(false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.gauges))
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) update(checkChange bool) error {
+	file, err := os.Open(col.MdstatFile)
+	if err != nil {
+		return err
+	}
+	arrays, err := parseMdstat(file)
+	_ = file.Close()
+	if err != nil {
+		return err
+	}
+
+	gauges := map[string]float64{
+		"mdraid/arrays":   float64(len(arrays)),
+		"mdraid/degraded": 0,
+	}
+	metadata := collector.MetricMetadataMap{
+		"mdraid/arrays":   collector.GaugeMetric(collector.UnitCount, "Number of software RAID arrays"),
+		"mdraid/degraded": collector.GaugeMetric(collector.UnitCount, "Number of degraded software RAID arrays"),
+	}
+	for _, array := range arrays {
+		prefix := "mdraid/" + array.name + "/"
+		active, degraded, syncing := 0.0, 0.0, 0.0
+		if array.active {
+			active = 1
+		}
+		if array.activeDisks < array.disks {
+			degraded = 1
+			gauges["mdraid/degraded"]++
+		}
+		if array.syncAction != "" {
+			syncing = 1
+		}
+		gauges[prefix+"active"] = active
+		gauges[prefix+"degraded"] = degraded
+		gauges[prefix+"disks"] = float64(array.disks)
+		gauges[prefix+"disks/active"] = float64(array.activeDisks)
+		gauges[prefix+"disks/failed"] = float64(array.disks - array.activeDisks)
+		gauges[prefix+"sync"] = syncing
+		gauges[prefix+"sync/progress"] = array.syncPercent
+		metadata[prefix+"active"] = collector.GaugeMetric(collector.UnitNone, "1 if the array is active, 0 otherwise")
+		metadata[prefix+"degraded"] = collector.GaugeMetric(collector.UnitNone, "1 if devices of the array are missing or failed, 0 otherwise")
+		metadata[prefix+"disks"] = collector.GaugeMetric(collector.UnitCount, "Number of devices in the array")
+		metadata[prefix+"disks/active"] = collector.GaugeMetric(collector.UnitCount, "Number of active devices in the array")
+		metadata[prefix+"disks/failed"] = collector.GaugeMetric(collector.UnitCount, "Number of missing or failed devices in the array")
+		metadata[prefix+"sync"] = collector.GaugeMetric(collector.UnitNone, "1 if a resync, recovery, reshape or check is running, 0 otherwise")
+		metadata[prefix+"sync/progress"] = collector.GaugeMetric(collector.UnitPercent, "Progress of the running resync, recovery, reshape or check")
+
+		if mismatches, err := col.readMismatches(array.name); err == nil {
+			gauges[prefix+"mismatches"] = mismatches
+			metadata[prefix+"mismatches"] = collector.GaugeMetric(collector.UnitCount, "Number of mismatched sectors found by the latest check")
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.gauges == nil || len(gauges) != len(col.gauges)
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *Collector) readMismatches(array string) (float64, error) {
+	data, err := ioutil.ReadFile(col.SysBlock + "/" + array + "/md/mismatch_cnt")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
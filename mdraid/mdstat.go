@@ -0,0 +1,85 @@
+package mdraid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Status line of an array, e.g. "1048512 blocks super 1.2 [2/1] [U_]"
+	statusRegex = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+
+	// Progress line of a running resync, recovery, reshape or check, e.g. "[=>...]  recovery = 12.6% (...)"
+	progressRegex = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*([0-9.]+)%`)
+)
+
+type mdArray struct {
+	name        string
+	active      bool
+	level       string
+	disks       int
+	activeDisks int
+	syncAction  string
+	syncPercent float64
+}
+
+// parseMdstat parses the contents of /proc/mdstat
+func parseMdstat(reader io.Reader) ([]*mdArray, error) {
+	var arrays []*mdArray
+	var current *mdArray
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[1] == ":" && strings.HasPrefix(fields[0], "md") {
+			// e.g. "md0 : active raid1 sdb1[1] sda1[0]", or "md1 : inactive sdc1[0](S)"
+			current = &mdArray{
+				name:        fields[0],
+				active:      fields[2] == "active",
+				syncPercent: 100,
+			}
+			if current.active && len(fields) >= 4 {
+				level := fields[3]
+				if strings.HasPrefix(level, "(") && len(fields) >= 5 {
+					// Skip "(read-only)" or "(auto-read-only)"
+					level = fields[4]
+				}
+				current.level = level
+			}
+			// Arrays without redundancy (e.g. RAID0 or linear) have no [n/m] status line, so the devices are counted here
+			for _, field := range fields[3:] {
+				if !strings.Contains(field, "[") || (current.active && strings.HasSuffix(field, "(S)")) {
+					continue
+				}
+				current.disks++
+				if !strings.HasSuffix(field, "(F)") {
+					current.activeDisks++
+				}
+			}
+			arrays = append(arrays, current)
+			continue
+		}
+		if current == nil || strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if match := statusRegex.FindStringSubmatch(line); match != nil && strings.Contains(line, "blocks") {
+			disks, err1 := strconv.Atoi(match[1])
+			active, err2 := strconv.Atoi(match[2])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("Failed to parse mdstat line: %v", line)
+			}
+			current.disks, current.activeDisks = disks, active
+		} else if match := progressRegex.FindStringSubmatch(line); match != nil {
+			percent, err := strconv.ParseFloat(match[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse mdstat line: %v", line)
+			}
+			current.syncAction, current.syncPercent = match[1], percent
+		}
+	}
+	return arrays, scanner.Err()
+}
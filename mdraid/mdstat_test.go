@@ -0,0 +1,121 @@
+package mdraid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type MdstatTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestMdstat(t *testing.T) {
+	suite.Run(t, new(MdstatTestSuite))
+}
+
+func (suite *MdstatTestSuite) TestParseMdstat() {
+	for _, test := range []struct {
+		name     string
+		mdstat   string
+		expected []*mdArray
+	}{
+		{
+			name: "degraded with recovery",
+			mdstat: `Personalities : [raid1] [raid6] [raid5] [raid4]
+md0 : active raid1 sdb1[2] sda1[0]
+      1048512 blocks super 1.2 [2/1] [U_]
+      [=>...................]  recovery =  8.5% (89600/1048512) finish=0.1min speed=89600K/sec
+
+md3 : active raid1 sdi1[1](F) sdh1[0]
+      1048512 blocks super 1.2 [2/1] [U_]
+
+unused devices: <none>
+`,
+			expected: []*mdArray{
+				{name: "md0", active: true, level: "raid1", disks: 2, activeDisks: 1, syncAction: "recovery", syncPercent: 8.5},
+				{name: "md3", active: true, level: "raid1", disks: 2, activeDisks: 1, syncPercent: 100},
+			},
+		},
+		{
+			name: "resync and check",
+			mdstat: `Personalities : [raid6] [raid5] [raid4] [raid1]
+md1 : active raid5 sde1[3] sdd1[1] sdc1[0]
+      2093056 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/3] [UUU]
+      [==========>..........]  resync = 52.1% (545792/1046528) finish=0.1min speed=109158K/sec
+      bitmap: 1/1 pages [4KB], 65536KB chunk
+
+md2 : active raid1 sdg1[1] sdf1[0]
+      1048512 blocks super 1.2 [2/2] [UU]
+      [>....................]  check =  0.4% (4480/1048512) finish=0.3min speed=4480K/sec
+
+md4 : active raid1 sdk1[1] sdj1[0]
+      1048512 blocks super 1.2 [2/2] [UU]
+        resync=DELAYED
+
+unused devices: <none>
+`,
+			expected: []*mdArray{
+				{name: "md1", active: true, level: "raid5", disks: 3, activeDisks: 3, syncAction: "resync", syncPercent: 52.1},
+				{name: "md2", active: true, level: "raid1", disks: 2, activeDisks: 2, syncAction: "check", syncPercent: 0.4},
+				{name: "md4", active: true, level: "raid1", disks: 2, activeDisks: 2, syncPercent: 100},
+			},
+		},
+		{
+			name: "raid0 and linear without status",
+			mdstat: `Personalities : [raid0] [linear]
+md0 : active raid0 sdb1[1] sda1[0]
+      2093056 blocks super 1.2 512k chunks
+
+md1 : active raid0 sdd1[1](F) sdc1[0]
+      2093056 blocks super 1.2 512k chunks
+
+md2 : active (auto-read-only) linear sde1[0]
+      1048512 blocks super 1.2 0k rounding
+
+md3 : inactive sdf1[0](S) sdg1[1](S)
+      2097024 blocks super 1.2
+
+unused devices: <none>
+`,
+			expected: []*mdArray{
+				{name: "md0", active: true, level: "raid0", disks: 2, activeDisks: 2, syncPercent: 100},
+				{name: "md1", active: true, level: "raid0", disks: 2, activeDisks: 1, syncPercent: 100},
+				{name: "md2", active: true, level: "linear", disks: 1, activeDisks: 1, syncPercent: 100},
+				{name: "md3", active: false, disks: 2, activeDisks: 2, syncPercent: 100},
+			},
+		},
+		{
+			name: "spare in redundant array",
+			mdstat: `md0 : active raid1 sdc1[2](S) sdb1[1] sda1[0]
+      1048512 blocks super 1.2 [2/2] [UU]
+`,
+			expected: []*mdArray{
+				{name: "md0", active: true, level: "raid1", disks: 2, activeDisks: 2, syncPercent: 100},
+			},
+		},
+		{
+			name: "truncated lines",
+			mdstat: `Personalities : [raid1]
+md0 : active raid1 sdb1[1] sda1[0]
+      1048512 blocks super 1.2 [2/
+      [=>......  recovery =
+md1 : active raid1 sdd1[1](F) sdc1[0]
+md2 :`,
+			expected: []*mdArray{
+				{name: "md0", active: true, level: "raid1", disks: 2, activeDisks: 2, syncPercent: 100},
+				{name: "md1", active: true, level: "raid1", disks: 2, activeDisks: 1, syncPercent: 100},
+			},
+		},
+		{
+			name:   "no arrays",
+			mdstat: "Personalities : \nunused devices: <none>\n",
+		},
+	} {
+		arrays, err := parseMdstat(strings.NewReader(test.mdstat))
+		suite.NoError(err, test.name)
+		suite.Equal(test.expected, arrays, test.name)
+	}
+}
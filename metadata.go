@@ -0,0 +1,76 @@
+package collector
+
+type MetricType string
+
+const (
+	// Gauge metrics report a current value that can arbitrarily go up and down, e.g. free memory.
+	Gauge = MetricType("gauge")
+
+	// Counter metrics report a monotonically increasing value, e.g. the total number of started processes.
+	Counter = MetricType("counter")
+
+	// Derived metrics are computed from other values, typically the rate of a counter, e.g. bytes per second.
+	Derived = MetricType("derived")
+)
+
+// Commonly used units for MetricMetadata.Unit
+const (
+	UnitNone           = ""
+	UnitCount          = "count"
+	UnitPercent        = "percent"
+	UnitBytes          = "bytes"
+	UnitBytesPerSecond = "bytes/sec"
+	UnitPerSecond      = "1/sec"
+	UnitMillisPerSec   = "ms/sec"
+	UnitSeconds        = "sec"
+	UnitMillis         = "ms"
+)
+
+// MetricMetadata describes a single metric. It can be used by consumers of the collected data,
+// for example to emit the correct metric types when exporting to other monitoring systems.
+type MetricMetadata struct {
+	Unit        string     `json:"unit,omitempty"`
+	Type        MetricType `json:"type,omitempty"`
+	Description string     `json:"description,omitempty"`
+
+	// Tags describe the entity a metric belongs to, e.g. the container of per-container metrics. Since tags of
+	// samples apply to all contained metrics, these tags are only available through the metadata.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+type MetricMetadataMap map[string]MetricMetadata
+
+// MetadataCollector can optionally be implemented by a Collector to describe the metrics it delivers.
+// The keys of the returned map should correspond to the keys of the map returned by Collector.Metrics().
+// Like Metrics(), MetricsMetadata() is only called after Init() returned successfully.
+type MetadataCollector interface {
+	MetricsMetadata() MetricMetadataMap
+}
+
+// GaugeMetric is a shortcut for creating a MetricMetadata instance of type Gauge.
+func GaugeMetric(unit string, description string) MetricMetadata {
+	return MetricMetadata{Unit: unit, Type: Gauge, Description: description}
+}
+
+// CounterMetric is a shortcut for creating a MetricMetadata instance of type Counter.
+func CounterMetric(unit string, description string) MetricMetadata {
+	return MetricMetadata{Unit: unit, Type: Counter, Description: description}
+}
+
+// DerivedMetric is a shortcut for creating a MetricMetadata instance of type Derived.
+func DerivedMetric(unit string, description string) MetricMetadata {
+	return MetricMetadata{Unit: unit, Type: Derived, Description: description}
+}
+
+// WithTags returns a copy of the metadata with the given tags added.
+func (m MetricMetadata) WithTags(tags map[string]string) MetricMetadata {
+	res := make(map[string]string, len(m.Tags)+len(tags))
+	for key, val := range m.Tags {
+		res[key] = val
+	}
+	for key, val := range tags {
+		res[key] = val
+	}
+	m.Tags = res
+	return m
+}
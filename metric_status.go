@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// MetricStatus describes a metric that is delivered by the collectors of a SampleSource, see AvailableMetrics()
+type MetricStatus struct {
+	Name string `json:"name"`
+	MetricMetadata
+
+	// Excluded is true if the metric is not collected, e.g. because of the metric filters or the FilterFile
+	Excluded bool `json:"excluded,omitempty"`
+
+	// Value of the metric in the last sample. Nil if the metric is excluded, if no sample was produced yet,
+	// or if the value is not a number.
+	Value *bitflow.Value `json:"value,omitempty"`
+}
+
+// metricStatusState stores the metrics of the current collector graph, before applying the metric filters,
+// and the values of the last sample. It is accessed by the REST API.
+type metricStatusState struct {
+	lock      sync.Mutex
+	available MetricMetadataMap
+	fields    []string
+	values    []bitflow.Value
+}
+
+func (state *metricStatusState) setAvailable(metrics MetricMetadataMap) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.available = metrics
+	state.fields, state.values = nil, nil
+}
+
+// setSample copies the values of the given sample, since they can be modified by the sink
+func (state *metricStatusState) setSample(fields []string, values []bitflow.Value) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.fields = fields
+	state.values = append(state.values[:0], values...)
+}
+
+// AvailableMetrics returns all metrics delivered by the collectors of the current metric collection, sorted by name,
+// including the metrics that are excluded by the metric filters. Collected metrics contain their value in the last
+// sample. Metrics that are added to the samples independently of the collectors (e.g. error metrics or anomaly scores)
+// are listed as well. Returns nil before the SampleSource is started.
+func (source *SampleSource) AvailableMetrics() []MetricStatus {
+	state := &source.metricStatus
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	metrics := make(map[string]*MetricStatus, len(state.available))
+	for name, metadata := range state.available {
+		metrics[name] = &MetricStatus{Name: name, MetricMetadata: metadata, Excluded: true}
+	}
+	current := source.CurrentMetadata()
+	for i, name := range state.fields {
+		metric, ok := metrics[name]
+		if !ok {
+			metric = &MetricStatus{Name: name, MetricMetadata: current[name]}
+			metrics[name] = metric
+		}
+		metric.Excluded = false
+		if i < len(state.values) {
+			if value := state.values[i]; !math.IsNaN(float64(value)) && !math.IsInf(float64(value), 0) {
+				metric.Value = &value
+			}
+		}
+	}
+
+	if len(metrics) == 0 {
+		return nil
+	}
+	res := make([]MetricStatus, 0, len(metrics))
+	for _, metric := range metrics {
+		res = append(res, *metric)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"regexp"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+func (suite *SchedulerTestSuite) TestAvailableMetrics() {
+	col := &staticMockCollector{mockCollector: mockCollector{log: newUpdateLog(), AbstractCollector: RootCollector("static")}}
+	source := &SampleSource{ExcludeMetrics: []*regexp.Regexp{regexp.MustCompile("^hv1/excluded$")}}
+	suite.NoError(source.RegisterPrefixedCollector("hv1", col))
+	suite.Nil(source.AvailableMetrics())
+
+	graph, err := source.createFilteredGraph(context.Background())
+	suite.NoError(err)
+	fields, getValues := graph.getMetrics().ConstructSample(source, 0)
+	suite.Equal([]MetricStatus{
+		{Name: "hv1/excluded", Excluded: true},
+		{Name: "hv1/total", Excluded: true},
+	}, source.AvailableMetrics())
+
+	// The collected metrics are known when the sink loop starts, their values with the first sample
+	source.metricStatus.setSample(fields, nil)
+	suite.Equal([]MetricStatus{
+		{Name: "hv1/excluded", Excluded: true},
+		{Name: "hv1/total"},
+	}, source.AvailableMetrics())
+
+	source.metricStatus.setSample(fields, getValues())
+	value := bitflow.Value(1)
+	suite.Equal([]MetricStatus{
+		{Name: "hv1/excluded", Excluded: true},
+		{Name: "hv1/total", Value: &value},
+	}, source.AvailableMetrics())
+
+	// Values that cannot be encoded as JSON are omitted, additional fields of the samples are listed as well
+	source.metricStatus.setSample(append(fields, AnomalyScoreMetric), []bitflow.Value{bitflow.Value(math.NaN()), 0.5})
+	score := bitflow.Value(0.5)
+	suite.Equal([]MetricStatus{
+		{Name: AnomalyScoreMetric, Value: &score},
+		{Name: "hv1/excluded", Excluded: true},
+		{Name: "hv1/total"},
+	}, source.AvailableMetrics())
+}
@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -18,30 +19,42 @@ func init() {
 	rand.Seed(int64(time.Now().Nanosecond()))
 }
 
-func NewMockCollector(factory *collector.ValueRingFactory) collector.Collector {
+// NewMockCollector returns a collector producing the metrics mock/1, mock/2 and mock/3, and the configured
+// synthetic signals (see Signal).
+func NewMockCollector(factory *collector.ValueRingFactory, signals []*Signal) collector.Collector {
 	return &RootCollector{
 		AbstractCollector: collector.RootCollector("mock"),
 		factory:           factory,
+		signals:           signals,
 	}
 }
 
 type RootCollector struct {
 	collector.AbstractCollector
 	factory     *collector.ValueRingFactory
+	signals     []*Signal
 	externalVal int
 	val         bitflow.Value
 	startOnce   sync.Once
+
+	signalCollectors []collector.Collector
 }
 
-func (root *RootCollector) Init() ([]collector.Collector, error) {
-	return []collector.Collector{
+func (root *RootCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	// Keep the signal collectors, so that the signals continue when the metric collection is restarted
+	if root.signalCollectors == nil {
+		for _, signal := range root.signals {
+			root.signalCollectors = append(root.signalCollectors, newSignalCollector(root, signal))
+		}
+	}
+	return append([]collector.Collector{
 		newMockCollector(root, root.factory, 1),
 		newMockCollector(root, root.factory, 2),
 		newMockCollector(root, root.factory, 3),
-	}, nil
+	}, root.signalCollectors...), nil
 }
 
-func (root *RootCollector) Update() error {
+func (root *RootCollector) Update(ctx context.Context) error {
 	root.startOnce.Do(func() {
 		millis := time.Millisecond * time.Duration(rand.Intn(500)+100) // 100..500
 		log.Printf("Incrementing mock values %.4f times per second", float64(time.Second)/float64(millis))
@@ -83,11 +96,11 @@ func newMockCollector(root *RootCollector, factory *collector.ValueRingFactory,
 	}
 }
 
-func (col *Collector) Init() ([]collector.Collector, error) {
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
 	return nil, nil
 }
 
-func (col *Collector) Update() error {
+func (col *Collector) Update(ctx context.Context) error {
 	col.ring.Add(collector.StoredValue(col.root.val * bitflow.Value(col.factor)))
 	return nil
 }
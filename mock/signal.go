@@ -0,0 +1,210 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Waveforms supported by Signal
+const (
+	WaveformSine       = "sine"
+	WaveformRamp       = "ramp"
+	WaveformStep       = "step"
+	WaveformRandomWalk = "random-walk"
+)
+
+// Signal describes a synthetic metric generated by the mock collector. The value follows the Waveform with the
+// given Period, oscillating between Offset and Offset+Amplitude (sine: Offset±Amplitude), and is overlaid with
+// normally distributed noise with the standard deviation Noise. For the random walk, the standard deviation of the
+// change within one Period equals the Amplitude.
+// Anomalies are injected with the average rate AnomalyRate (per second). During an anomaly, AnomalyMagnitude times
+// the Amplitude is added to the value for AnomalyDuration. An additional metric labels the injected anomalies.
+type Signal struct {
+	Name             string
+	Waveform         string
+	Period           time.Duration
+	Amplitude        float64
+	Offset           float64
+	Noise            float64
+	AnomalyRate      float64
+	AnomalyMagnitude float64
+	AnomalyDuration  time.Duration
+}
+
+// ParseSignal parses a signal in the format name=waveform[,param=value...], e.g.
+// load=sine,period=5m,amplitude=10,offset=50,noise=1,anomaly-rate=0.001. The available parameters are
+// period, amplitude, offset, noise, anomaly-rate, anomaly-magnitude and anomaly-duration.
+func ParseSignal(spec string) (*Signal, error) {
+	index := strings.IndexRune(spec, '=')
+	if index <= 0 {
+		return nil, fmt.Errorf("Invalid mock signal '%v', expected format: name=waveform[,param=value...]", spec)
+	}
+	parts := strings.Split(spec[index+1:], ",")
+	signal := &Signal{
+		Name:             spec[:index],
+		Waveform:         parts[0],
+		Period:           time.Minute,
+		Amplitude:        1,
+		AnomalyMagnitude: 3,
+		AnomalyDuration:  10 * time.Second,
+	}
+	switch signal.Waveform {
+	case WaveformSine, WaveformRamp, WaveformStep, WaveformRandomWalk:
+	default:
+		return nil, fmt.Errorf("Unknown waveform '%v' of mock signal %v, available: %v", signal.Waveform, signal.Name,
+			strings.Join([]string{WaveformSine, WaveformRamp, WaveformStep, WaveformRandomWalk}, ", "))
+	}
+	for _, param := range parts[1:] {
+		index := strings.IndexRune(param, '=')
+		if index <= 0 {
+			return nil, fmt.Errorf("Invalid parameter '%v' of mock signal %v, expected format: param=value", param, signal.Name)
+		}
+		key, value := param[:index], param[index+1:]
+		var err error
+		switch key {
+		case "period":
+			signal.Period, err = time.ParseDuration(value)
+		case "anomaly-duration":
+			signal.AnomalyDuration, err = time.ParseDuration(value)
+		case "amplitude":
+			signal.Amplitude, err = strconv.ParseFloat(value, 64)
+		case "offset":
+			signal.Offset, err = strconv.ParseFloat(value, 64)
+		case "noise":
+			signal.Noise, err = strconv.ParseFloat(value, 64)
+		case "anomaly-rate":
+			signal.AnomalyRate, err = strconv.ParseFloat(value, 64)
+		case "anomaly-magnitude":
+			signal.AnomalyMagnitude, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("Unknown parameter '%v' of mock signal %v", key, signal.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value of parameter %v of mock signal %v: %v", key, signal.Name, err)
+		}
+	}
+	if signal.Period <= 0 {
+		return nil, fmt.Errorf("The period of mock signal %v must be positive", signal.Name)
+	}
+	return signal, nil
+}
+
+// value returns the value of the waveform at the given time since the start of the signal.
+// The random walk is handled by signalCollector.
+func (signal *Signal) value(elapsed time.Duration) float64 {
+	phase := math.Mod(float64(elapsed)/float64(signal.Period), 1)
+	switch signal.Waveform {
+	case WaveformSine:
+		return signal.Offset + signal.Amplitude*math.Sin(2*math.Pi*phase)
+	case WaveformRamp:
+		return signal.Offset + signal.Amplitude*phase
+	case WaveformStep:
+		if phase >= 0.5 {
+			return signal.Offset + signal.Amplitude
+		}
+		return signal.Offset
+	default:
+		return signal.Offset
+	}
+}
+
+type signalCollector struct {
+	collector.AbstractCollector
+	signal *Signal
+	random *rand.Rand
+
+	lock         sync.Mutex
+	start        time.Time
+	last         time.Time
+	walk         float64
+	anomalyUntil time.Time
+	val          bitflow.Value
+	anomaly      bitflow.Value
+}
+
+func newSignalCollector(root *RootCollector, signal *Signal) *signalCollector {
+	return &signalCollector{
+		AbstractCollector: root.Child(signal.Name),
+		signal:            signal,
+		random:            rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (col *signalCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	// The signal continues when the metric collection is restarted
+	if col.start.IsZero() {
+		col.start = time.Now()
+		col.last = col.start
+	}
+	return nil, nil
+}
+
+func (col *signalCollector) Update(ctx context.Context) error {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	col.update(time.Now())
+	return nil
+}
+
+func (col *signalCollector) update(now time.Time) {
+	signal := col.signal
+	elapsed := now.Sub(col.last)
+	col.last = now
+
+	value := signal.value(now.Sub(col.start))
+	if signal.Waveform == WaveformRandomWalk && elapsed > 0 {
+		col.walk += col.random.NormFloat64() * signal.Amplitude * math.Sqrt(float64(elapsed)/float64(signal.Period))
+		value += col.walk
+	}
+	if signal.Noise > 0 {
+		value += col.random.NormFloat64() * signal.Noise
+	}
+
+	if signal.AnomalyRate > 0 && !now.Before(col.anomalyUntil) {
+		// Probability of at least one anomaly within the elapsed time, for anomalies occurring with a constant rate
+		if col.random.Float64() < 1-math.Exp(-signal.AnomalyRate*elapsed.Seconds()) {
+			col.anomalyUntil = now.Add(signal.AnomalyDuration)
+		}
+	}
+	col.anomaly = 0
+	if now.Before(col.anomalyUntil) {
+		value += signal.AnomalyMagnitude * signal.Amplitude
+		col.anomaly = 1
+	}
+	col.val = bitflow.Value(value)
+}
+
+func (col *signalCollector) Metrics() collector.MetricReaderMap {
+	prefix := "mock/signal/" + col.signal.Name
+	return collector.MetricReaderMap{
+		prefix: func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return col.val
+		},
+		prefix + "/anomaly": func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return col.anomaly
+		},
+	}
+}
+
+func (col *signalCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := "mock/signal/" + col.signal.Name
+	return collector.MetricMetadataMap{
+		prefix:              collector.GaugeMetric(collector.UnitNone, "Synthetic "+col.signal.Waveform+" signal"),
+		prefix + "/anomaly": collector.GaugeMetric(collector.UnitNone, "1 while an anomaly is injected into the synthetic signal, otherwise 0"),
+	}
+}
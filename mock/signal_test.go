@@ -0,0 +1,83 @@
+package mock
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type SignalTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSignal(t *testing.T) {
+	suite.Run(t, new(SignalTestSuite))
+}
+
+func (suite *SignalTestSuite) TestParseSignal() {
+	signal, err := ParseSignal("load=sine,period=5m,amplitude=10,offset=50,noise=1.5,anomaly-rate=0.01,anomaly-magnitude=4,anomaly-duration=30s")
+	suite.NoError(err)
+	suite.Equal(&Signal{
+		Name:             "load",
+		Waveform:         WaveformSine,
+		Period:           5 * time.Minute,
+		Amplitude:        10,
+		Offset:           50,
+		Noise:            1.5,
+		AnomalyRate:      0.01,
+		AnomalyMagnitude: 4,
+		AnomalyDuration:  30 * time.Second,
+	}, signal)
+
+	signal, err = ParseSignal("x=random-walk")
+	suite.NoError(err)
+	suite.Equal(time.Minute, signal.Period)
+	suite.Equal(1.0, signal.Amplitude)
+
+	for _, invalid := range []string{"sine", "=sine", "x=square", "x=sine,period", "x=sine,period=abc", "x=sine,period=0s", "x=sine,unknown=1"} {
+		_, err := ParseSignal(invalid)
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *SignalTestSuite) TestWaveforms() {
+	period := 4 * time.Second
+	for _, test := range []struct {
+		waveform string
+		values   []float64
+	}{
+		{WaveformSine, []float64{10, 12, 10, 8}},
+		{WaveformRamp, []float64{10, 10.5, 11, 11.5}},
+		{WaveformStep, []float64{10, 10, 12, 12}},
+	} {
+		signal := &Signal{Waveform: test.waveform, Period: period, Amplitude: 2, Offset: 10}
+		for i, expected := range test.values {
+			suite.InDelta(expected, signal.value(time.Duration(i)*time.Second+period), 0.0001, test.waveform)
+		}
+	}
+}
+
+func (suite *SignalTestSuite) TestAnomalies() {
+	start := time.Now()
+	col := &signalCollector{
+		signal: &Signal{Waveform: WaveformStep, Period: time.Minute, Amplitude: 2, Offset: 10,
+			AnomalyRate: 1000, AnomalyMagnitude: 3, AnomalyDuration: 5 * time.Second},
+		random: rand.New(rand.NewSource(1)),
+		start:  start,
+		last:   start,
+	}
+	col.update(start.Add(time.Second))
+	suite.Equal(bitflow.Value(16), col.val)
+	suite.Equal(bitflow.Value(1), col.anomaly)
+
+	col.signal.AnomalyRate = 0
+	col.update(start.Add(5 * time.Second))
+	suite.Equal(bitflow.Value(1), col.anomaly)
+	col.update(start.Add(6 * time.Second))
+	suite.Equal(bitflow.Value(10), col.val)
+	suite.Equal(bitflow.Value(0), col.anomaly)
+}
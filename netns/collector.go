@@ -0,0 +1,320 @@
+package netns
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/psutil"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/shirou/gopsutil/process"
+	log "github.com/sirupsen/logrus"
+)
+
+const NamedNamespaceDir = "/run/netns"
+
+// Protocol counters that are reported as absolute values instead of rates
+var absoluteProtoValues = map[string]bool{
+	"tcp/CurrEstab":    true,
+	"tcp/MaxConn":      true,
+	"tcp/RtoAlgorithm": true,
+	"tcp/RtoMax":       true,
+	"tcp/RtoMin":       true,
+	"ip/DefaultTTL":    true,
+	"ip/Forwarding":    true,
+}
+
+// Namespace identifies a network namespace either through its nsfs file (Path, e.g. /run/netns/<name> for
+// namespaces created through 'ip netns'), or through the first process with a command line matching
+// ProcessRegex (e.g. the main process of a container).
+type Namespace struct {
+	Name         string
+	Path         string
+	ProcessRegex *regexp.Regexp
+}
+
+// ParseNamespace parses a namespace in the format <name>, <name>=<nsfs path> or <name>=proc:<regex>.
+// A plain name refers to a namespace created through 'ip netns'.
+func ParseNamespace(spec string) (Namespace, error) {
+	index := strings.IndexRune(spec, '=')
+	if index < 0 {
+		return Namespace{Name: spec, Path: filepath.Join(NamedNamespaceDir, spec)}, nil
+	}
+	ns := Namespace{Name: spec[:index]}
+	target := spec[index+1:]
+	if strings.HasPrefix(target, "proc:") {
+		regex, err := regexp.Compile(strings.TrimPrefix(target, "proc:"))
+		if err != nil {
+			return ns, fmt.Errorf("Invalid process regex in network namespace %v: %v", spec, err)
+		}
+		ns.ProcessRegex = regex
+	} else {
+		ns.Path = target
+	}
+	if ns.Name == "" || target == "" {
+		return ns, fmt.Errorf("Invalid network namespace '%v', expected format: name, name=path or name=proc:regex", spec)
+	}
+	return ns, nil
+}
+
+// Collector reports the network metrics of the given network namespaces, which are not visible in the network
+// metrics of the host namespace. For every namespace, the metrics are named like the metrics of the host namespace,
+// prefixed with netns/<name>/: net-io (for all interfaces and per interface), net-proto and sockets.
+type Collector struct {
+	collector.AbstractCollector
+	Namespaces []Namespace
+	factory    *collector.ValueRingFactory
+}
+
+func NewNetnsCollector(namespaces []Namespace, factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("netns"),
+		Namespaces:        namespaces,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	res := make([]collector.Collector, len(col.Namespaces))
+	for i, ns := range col.Namespaces {
+		res[i] = &namespaceCollector{
+			AbstractCollector: col.Child(ns.Name),
+			parent:            col,
+			ns:                ns,
+		}
+	}
+	return res, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+type namespaceCollector struct {
+	collector.AbstractCollector
+	parent *Collector
+	ns     Namespace
+	pid    int32
+
+	lock     sync.Mutex
+	all      psutil.NetIoCounters
+	nics     map[string]*psutil.NetIoCounters
+	proto    map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func (col *namespaceCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.pid = 0
+	col.all = psutil.NewNetIoCounters(col.parent.factory)
+	col.nics = nil
+	return nil, col.update(false)
+}
+
+func (col *namespaceCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *namespaceCollector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *namespaceCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *namespaceCollector) prefix() string {
+	return "netns/" + col.ns.Name + "/"
+}
+
+func (col *namespaceCollector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	prefix := col.prefix()
+	res := col.all.Metrics(prefix + "net-io")
+	for nic, counters := range col.nics {
+		for name, reader := range counters.Metrics(prefix + "net-io/nic/" + nic) {
+			res[name] = reader
+		}
+	}
+	for name, ring := range col.proto {
+		res[prefix+"net-proto/"+name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *namespaceCollector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	prefix := col.prefix()
+	res := col.all.MetricsMetadata(prefix + "net-io")
+	for nic, counters := range col.nics {
+		for name, metadata := range counters.MetricsMetadata(prefix + "net-io/nic/" + nic) {
+			res[name] = metadata
+		}
+	}
+	for name, metadata := range col.metadata {
+		res[name] = metadata
+	}
+	return res
+}
+
+func (col *namespaceCollector) readFiles() (map[string][]byte, error) {
+	if col.ns.ProcessRegex == nil {
+		return readInNamespace(col.ns.Path)
+	}
+	if col.pid != 0 {
+		if files, err := readProcNetFiles(fmt.Sprintf("/proc/%v/net", col.pid)); err == nil {
+			return files, nil
+		}
+		// The process has probably terminated, search for a new matching process
+		log.Debugf("Failed to read network statistics of process %v for network namespace %v, searching for a new process", col.pid, col.ns.Name)
+	}
+	pid, err := findProcess(col.ns.ProcessRegex)
+	if err != nil {
+		return nil, err
+	}
+	col.pid = pid
+	return readProcNetFiles(fmt.Sprintf("/proc/%v/net", col.pid))
+}
+
+func (col *namespaceCollector) update(checkChange bool) error {
+	files, err := col.readFiles()
+	if err != nil {
+		return err
+	}
+	nics, err := parseNetDev(files["dev"])
+	if err != nil {
+		return err
+	}
+	proto := parseKeyValueLines(files["snmp"], true)
+	sockets := parseKeyValueLines(files["sockstat"], false)
+
+	prefix := col.prefix()
+	gauges := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	for name, value := range proto {
+		if absoluteProtoValues[name] {
+			gauges[prefix+"net-proto/"+name] = value
+			metadata[prefix+"net-proto/"+name] = collector.GaugeMetric(collector.UnitNone, "Value of "+name+" in net/snmp of the namespace")
+		} else {
+			metadata[prefix+"net-proto/"+name] = collector.DerivedMetric(collector.UnitPerSecond, "Rate of "+name+" in net/snmp of the namespace")
+		}
+	}
+	for name, value := range sockets {
+		if strings.HasSuffix(name, "/mem") {
+			// Memory usage is reported in pages and is not relevant for other namespaces
+			continue
+		}
+		gauges[prefix+"sockets/"+name] = value
+		metadata[prefix+"sockets/"+name] = collector.GaugeMetric(collector.UnitCount, "Value of "+name+" in net/sockstat of the namespace")
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.nics == nil || len(nics) != len(col.nics) || len(gauges) != len(col.gauges) || len(proto)-countAbsolute(proto) != len(col.proto)
+	if col.nics == nil {
+		col.nics = make(map[string]*psutil.NetIoCounters, len(nics))
+		col.proto = make(map[string]*collector.ValueRing)
+	}
+	newNics := make(map[string]*psutil.NetIoCounters, len(nics))
+	for i, nic := range nics {
+		counters, ok := col.nics[nic.Name]
+		if !ok {
+			changed = true
+			newCounters := psutil.NewNetIoCounters(col.parent.factory)
+			counters = &newCounters
+		}
+		counters.Add(&nics[i])
+		col.all.AddToHead(&nics[i])
+		newNics[nic.Name] = counters
+	}
+	col.all.FlushHead()
+	col.nics = newNics
+	for name, value := range proto {
+		if absoluteProtoValues[name] {
+			continue
+		}
+		ring, ok := col.proto[name]
+		if !ok {
+			changed = true
+			ring = col.parent.factory.NewValueRing()
+			col.proto[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func countAbsolute(proto map[string]float64) int {
+	num := 0
+	for name := range proto {
+		if absoluteProtoValues[name] {
+			num++
+		}
+	}
+	return num
+}
+
+func readProcNetFiles(dir string) (map[string][]byte, error) {
+	res := make(map[string][]byte, len(namespaceFiles))
+	for _, file := range namespaceFiles {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		res[file] = data
+	}
+	return res, nil
+}
+
+// findProcess returns the lowest PID of all processes with a command line matching the given regex.
+// The lowest PID usually belongs to the main process of a container.
+func findProcess(regex *regexp.Regexp) (int32, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		return pids[i] < pids[j]
+	})
+	for _, pid := range pids {
+		cmdline, err := ioutil.ReadFile("/proc/" + strconv.Itoa(int(pid)) + "/cmdline")
+		if err != nil {
+			continue
+		}
+		if regex.MatchString(strings.Replace(string(cmdline), "\x00", " ", -1)) {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("No process matching %v found", regex)
+}
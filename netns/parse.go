@@ -0,0 +1,90 @@
+package netns
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	psnet "github.com/shirou/gopsutil/net"
+)
+
+// The files read from the net/ directory of the procfs for every namespace
+var namespaceFiles = []string{"dev", "snmp", "sockstat"}
+
+// parseNetDev parses the contents of /proc/net/dev. The first two lines are headers.
+func parseNetDev(data []byte) ([]psnet.IOCountersStat, error) {
+	var res []psnet.IOCountersStat
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if i < 2 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		index := strings.IndexRune(line, ':')
+		if index < 0 {
+			return nil, fmt.Errorf("Invalid line in net/dev: %v", line)
+		}
+		fields := strings.Fields(line[index+1:])
+		if len(fields) < 12 {
+			return nil, fmt.Errorf("Invalid line in net/dev: %v", line)
+		}
+		values := make([]uint64, 12)
+		for j := range values {
+			value, err := strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value in net/dev (%v): %v", line, err)
+			}
+			values[j] = value
+		}
+		res = append(res, psnet.IOCountersStat{
+			Name:        strings.TrimSpace(line[:index]),
+			BytesRecv:   values[0],
+			PacketsRecv: values[1],
+			Errin:       values[2],
+			Dropin:      values[3],
+			BytesSent:   values[8],
+			PacketsSent: values[9],
+			Errout:      values[10],
+			Dropout:     values[11],
+		})
+	}
+	return res, nil
+}
+
+// parseKeyValueLines parses files like /proc/net/snmp and /proc/net/sockstat. The result maps
+// <lower case protocol>/<field> to the numeric values.
+// snmp consists of pairs of lines with the same prefix, where the first line contains the field names
+// and the second line contains the values ("Tcp: RtoAlgorithm RtoMin ..." and "Tcp: 1 200 ...").
+// sockstat contains alternating field names and values in every line ("TCP: inuse 5 orphan 0 ...").
+func parseKeyValueLines(data []byte, pairsOfLines bool) map[string]float64 {
+	res := make(map[string]float64)
+	var header []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		proto := strings.ToLower(strings.TrimSuffix(fields[0], ":"))
+		if pairsOfLines {
+			if header == nil || header[0] != fields[0] {
+				header = fields
+				continue
+			}
+			for i := 1; i < len(fields) && i < len(header); i++ {
+				if value, err := strconv.ParseFloat(fields[i], 64); err == nil {
+					res[proto+"/"+header[i]] = value
+				}
+			}
+			header = nil
+		} else {
+			for i := 1; i+1 < len(fields); i += 2 {
+				if value, err := strconv.ParseFloat(fields[i+1], 64); err == nil {
+					res[proto+"/"+fields[i]] = value
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,144 @@
+package netns
+
+import (
+	"testing"
+
+	"github.com/antongulenko/golib"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/suite"
+)
+
+type ParseTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestParse(t *testing.T) {
+	suite.Run(t, new(ParseTestSuite))
+}
+
+const netDevHeader = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+`
+
+func (suite *ParseTestSuite) TestParseNetDev() {
+	for _, test := range []struct {
+		name     string
+		data     string
+		expected []psnet.IOCountersStat
+		err      bool
+	}{
+		{
+			name: "interfaces",
+			data: netDevHeader +
+				"    lo:    1200      12    0    0    0     0          0         0     1200      12    0    0    0     0       0          0\n" +
+				"  eth0:123456789 98765    1    2    0     0          0         0 987654321  56789    3    4    0     0       0          0\n",
+			expected: []psnet.IOCountersStat{
+				{Name: "lo", BytesRecv: 1200, PacketsRecv: 12, BytesSent: 1200, PacketsSent: 12},
+				{Name: "eth0", BytesRecv: 123456789, PacketsRecv: 98765, Errin: 1, Dropin: 2,
+					BytesSent: 987654321, PacketsSent: 56789, Errout: 3, Dropout: 4},
+			},
+		},
+		{
+			name:     "no interfaces",
+			data:     netDevHeader,
+			expected: nil,
+		},
+		{
+			name: "truncated line",
+			data: netDevHeader + "  eth0: 1234 12 0 0 0 0 0 0 1234\n",
+			err:  true,
+		},
+		{
+			name: "missing interface name",
+			data: netDevHeader + "  1234 12 0 0 0 0 0 0 1234 12 0 0 0 0 0 0\n",
+			err:  true,
+		},
+		{
+			name: "invalid value",
+			data: netDevHeader + "  eth0: 1234 -12 0 0 0 0 0 0 1234 12 0 0 0 0 0 0\n",
+			err:  true,
+		},
+	} {
+		stats, err := parseNetDev([]byte(test.data))
+		if test.err {
+			suite.Error(err, test.name)
+		} else {
+			suite.NoError(err, test.name)
+			suite.Equal(test.expected, stats, test.name)
+		}
+	}
+}
+
+func (suite *ParseTestSuite) TestParseKeyValueLines() {
+	for _, test := range []struct {
+		name         string
+		data         string
+		pairsOfLines bool
+		expected     map[string]float64
+	}{
+		{
+			name: "snmp",
+			data: `Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 12345
+Icmp: InMsgs InErrors
+Icmp: 45 0
+IcmpMsg: InType3 OutType3
+IcmpMsg: 10 12
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens CurrEstab
+Tcp: 1 200 120000 -1 500 3
+Udp: InDatagrams NoPorts
+Udp: 100 2
+`,
+			pairsOfLines: true,
+			expected: map[string]float64{
+				"ip/Forwarding": 1, "ip/DefaultTTL": 64, "ip/InReceives": 12345,
+				"icmp/InMsgs": 45, "icmp/InErrors": 0,
+				"icmpmsg/InType3": 10, "icmpmsg/OutType3": 12,
+				"tcp/RtoAlgorithm": 1, "tcp/RtoMin": 200, "tcp/RtoMax": 120000, "tcp/MaxConn": -1, "tcp/ActiveOpens": 500, "tcp/CurrEstab": 3,
+				"udp/InDatagrams": 100, "udp/NoPorts": 2,
+			},
+		},
+		{
+			name: "truncated snmp",
+			data: `Ip: Forwarding DefaultTTL
+Ip: 1 64
+Tcp: RtoAlgorithm RtoMin ActiveOpens
+Udp: InDatagrams NoPorts
+Udp: 100
+`,
+			pairsOfLines: true,
+			expected: map[string]float64{
+				"ip/Forwarding": 1, "ip/DefaultTTL": 64,
+				"udp/InDatagrams": 100,
+			},
+		},
+		{
+			name: "sockstat",
+			data: `sockets: used 290
+TCP: inuse 5 orphan 0 tw 2 alloc 7 mem 1
+UDP: inuse 3 mem 2
+UDPLITE: inuse 0
+RAW: inuse 0
+FRAG: inuse 0 memory 0
+`,
+			expected: map[string]float64{
+				"sockets/used": 290,
+				"tcp/inuse":    5, "tcp/orphan": 0, "tcp/tw": 2, "tcp/alloc": 7, "tcp/mem": 1,
+				"udp/inuse": 3, "udp/mem": 2,
+				"udplite/inuse": 0,
+				"raw/inuse":     0,
+				"frag/inuse":    0, "frag/memory": 0,
+			},
+		},
+		{
+			name: "truncated sockstat",
+			data: "sockets: used 290\nTCP: inuse 5 orphan\n",
+			expected: map[string]float64{
+				"sockets/used": 290,
+				"tcp/inuse":    5,
+			},
+		},
+	} {
+		suite.Equal(test.expected, parseKeyValueLines([]byte(test.data), test.pairsOfLines), test.name)
+	}
+}
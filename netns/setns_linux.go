@@ -0,0 +1,94 @@
+package netns
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// readInNamespace enters the network namespace referenced by the given nsfs file (e.g. /run/netns/<name>)
+// with the current OS thread, reads the procfs files of the namespace, and switches back to the original namespace.
+// Requires the CAP_SYS_ADMIN capability.
+func readInNamespace(nsPath string) (files map[string][]byte, err error) {
+	err = runInNamespace(nsPath, func() (readErr error) {
+		files, readErr = readProcNetFiles("/proc/thread-self/net")
+		return
+	})
+	return
+}
+
+// readPeerIndices returns the interface indices of the peers of all interfaces in the given network namespace
+// that are linked to an interface in another namespace, e.g. the host side of the veth pair of a container.
+// Requires the CAP_SYS_ADMIN capability.
+func readPeerIndices(nsPath string) (peers []int, err error) {
+	err = runInNamespace(nsPath, func() error {
+		// The netlink socket is created in the namespace of the current thread
+		rib, err := syscall.NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+		if err != nil {
+			return err
+		}
+		messages, err := syscall.ParseNetlinkMessage(rib)
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			if messages[i].Header.Type != syscall.RTM_NEWLINK {
+				continue
+			}
+			attrs, err := syscall.ParseNetlinkRouteAttr(&messages[i])
+			if err != nil {
+				return err
+			}
+			peer, otherNamespace := -1, false
+			for _, attr := range attrs {
+				switch {
+				case attr.Attr.Type == syscall.IFLA_LINK && len(attr.Value) >= 4:
+					peer = int(*(*int32)(unsafe.Pointer(&attr.Value[0])))
+				case attr.Attr.Type == unix.IFLA_LINK_NETNSID:
+					otherNamespace = true
+				}
+			}
+			if peer > 0 && otherNamespace {
+				peers = append(peers, peer)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// runInNamespace executes the given function with the current OS thread inside the network namespace referenced by
+// the given nsfs file, and switches back to the original namespace afterwards.
+func runInNamespace(nsPath string, do func() error) error {
+	runtime.LockOSThread()
+	origNs, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer origNs.Close()
+	targetNs, err := os.Open(nsPath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer targetNs.Close()
+
+	if err := unix.Setns(int(targetNs.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("Failed to enter network namespace %v: %v", nsPath, err)
+	}
+	doErr := do()
+	if err := unix.Setns(int(origNs.Fd()), unix.CLONE_NEWNET); err != nil {
+		// The thread stays locked, so it is terminated together with the goroutine instead of being reused
+		log.Errorf("Failed to leave network namespace %v: %v", nsPath, err)
+		return err
+	}
+	runtime.UnlockOSThread()
+	return doErr
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package netns
+
+import "errors"
+
+func readInNamespace(nsPath string) (map[string][]byte, error) {
+	return nil, errors.New("Entering network namespaces is only supported on Linux")
+}
+
+func readPeerIndices(nsPath string) ([]int, error) {
+	return nil, errors.New("Entering network namespaces is only supported on Linux")
+}
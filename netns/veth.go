@@ -0,0 +1,284 @@
+package netns
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/psutil"
+	psnet "github.com/shirou/gopsutil/net"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ContainerTag is set in the metadata of container-net metrics to the container the metric belongs to
+	ContainerTag = "container"
+
+	// InterfacesTag is set in the metadata of container-net metrics to the comma-separated host side veth interfaces
+	InterfacesTag = "interfaces"
+
+	containerNetPrefix = "container-net/"
+)
+
+// Container IDs of Docker, containerd, CRI-O and Podman in the cgroup paths of the container processes
+var containerIdRegex = regexp.MustCompile("[0-9a-f]{64}")
+
+// VethCollector reports the network traffic of all containers with their own network namespace, based on the
+// statistics of the host side of their veth pairs. The veth interfaces are mapped to the containers by entering the
+// network namespace of every container and reading the interface index of the peer of every interface (the iflink).
+// Containers are named after the first 12 characters of the container ID found in the cgroup of their processes,
+// or after the command name of their first process. Since sample tags apply to all metrics of a sample, the container
+// name is part of the metric names: container-net/<container>/..., with the same metrics as net-io. The metadata of
+// every metric carries the tags ContainerTag and InterfacesTag. The directions are reported from the perspective
+// of the container, i.e. rx_bytes is the traffic received by the container.
+// Entering network namespaces requires the CAP_SYS_ADMIN capability. Namespaces that cannot be entered are skipped.
+type VethCollector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	lock       sync.Mutex
+	namespaces containerNamespaces
+	containers map[string]*containerCounters
+}
+
+type containerCounters struct {
+	psutil.NetIoCounters
+	interfaces []string
+}
+
+// containerNamespaces maps the links of network namespaces (e.g. net:[4026532281]) to the containers they belong to
+type containerNamespaces map[string]*containerNamespace
+
+func (namespaces containerNamespaces) containerNameExists(name string) bool {
+	for _, ns := range namespaces {
+		if ns.container == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containerNamespace is a network namespace that is not the host namespace. The peers are empty if the namespace
+// could not be entered.
+type containerNamespace struct {
+	container string
+	peers     []int
+}
+
+func NewVethCollector(factory *collector.ValueRingFactory) *VethCollector {
+	return &VethCollector{
+		AbstractCollector: collector.RootCollector("container-net"),
+		factory:           factory,
+	}
+}
+
+func (col *VethCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	// Retry entering all namespaces after a restart of the collection
+	col.namespaces = nil
+	col.containers = nil
+	return nil, col.update(false)
+}
+
+func (col *VethCollector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *VethCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *VethCollector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap)
+	for name, counters := range col.containers {
+		for metric, reader := range counters.Metrics(containerNetPrefix + name) {
+			res[metric] = reader
+		}
+	}
+	return res
+}
+
+func (col *VethCollector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricMetadataMap)
+	for name, counters := range col.containers {
+		tags := map[string]string{
+			ContainerTag:  name,
+			InterfacesTag: strings.Join(counters.interfaces, ","),
+		}
+		for metric, metadata := range counters.MetricsMetadata(containerNetPrefix + name) {
+			res[metric] = metadata.WithTags(tags)
+		}
+	}
+	return res
+}
+
+func (col *VethCollector) update(checkChange bool) error {
+	namespaces, err := col.readNamespaces()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile("/proc/self/net/dev")
+	if err != nil {
+		return err
+	}
+	nics, err := parseNetDev(data)
+	if err != nil {
+		return err
+	}
+	nicsByIndex := make(map[int]*psnet.IOCountersStat, len(nics))
+	for i, nic := range nics {
+		index, err := readInterfaceIndex(nic.Name)
+		if err != nil {
+			// The interface has been removed in the meantime
+			continue
+		}
+		nicsByIndex[index] = &nics[i]
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.containers == nil
+	containers := make(map[string]*containerCounters)
+	for _, ns := range namespaces {
+		var stats []*psnet.IOCountersStat
+		for _, peer := range ns.peers {
+			if nic, ok := nicsByIndex[peer]; ok {
+				stats = append(stats, nic)
+			}
+		}
+		if len(stats) == 0 {
+			continue
+		}
+		counters, ok := col.containers[ns.container]
+		if !ok {
+			changed = true
+			counters = &containerCounters{NetIoCounters: psutil.NewNetIoCounters(col.factory)}
+		}
+		counters.interfaces = counters.interfaces[:0]
+		for _, stat := range stats {
+			counters.interfaces = append(counters.interfaces, stat.Name)
+			counters.AddToHead(&psnet.IOCountersStat{
+				// Traffic sent by the host side of the veth pair is received by the container, and vice versa
+				BytesRecv:   stat.BytesSent,
+				PacketsRecv: stat.PacketsSent,
+				Errin:       stat.Errout,
+				Dropin:      stat.Dropout,
+				BytesSent:   stat.BytesRecv,
+				PacketsSent: stat.PacketsRecv,
+				Errout:      stat.Errin,
+				Dropout:     stat.Dropin,
+			})
+		}
+		counters.FlushHead()
+		containers[ns.container] = counters
+	}
+	if len(containers) != len(col.containers) {
+		changed = true
+	}
+	col.containers = containers
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+// readNamespaces returns all network namespaces of running processes, except the host namespace. The peer indices
+// of new namespaces are read by entering the namespace through its first process, known namespaces are reused.
+func (col *VethCollector) readNamespaces() (containerNamespaces, error) {
+	hostNs, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		return nil, err
+	}
+	pids, err := listPids()
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make(containerNamespaces)
+	for _, pid := range pids {
+		link, err := os.Readlink(fmt.Sprintf("/proc/%v/ns/net", pid))
+		if err != nil || link == hostNs {
+			continue
+		}
+		if _, ok := namespaces[link]; ok {
+			continue
+		}
+		ns, ok := col.namespaces[link]
+		if !ok {
+			peers, err := readPeerIndices(fmt.Sprintf("/proc/%v/ns/net", pid))
+			if os.IsNotExist(err) {
+				// The process has terminated in the meantime
+				continue
+			}
+			ns = &containerNamespace{container: containerName(pid), peers: peers}
+			if err != nil {
+				// Remember the namespace without peers, so it is not entered again until the collection is restarted
+				ns.peers = nil
+				log.Warnf("Failed to read the veth peers of network namespace %v (pid %v, container %v): %v", link, pid, ns.container, err)
+			}
+			if col.namespaces.containerNameExists(ns.container) || namespaces.containerNameExists(ns.container) {
+				// Multiple namespaces with the same process name
+				ns.container += "-" + strings.Trim(strings.TrimPrefix(link, "net:"), "[]")
+			}
+			log.Debugf("Network namespace %v belongs to container %v with veth peers %v", link, ns.container, peers)
+		}
+		namespaces[link] = ns
+	}
+	col.namespaces = namespaces
+	return namespaces, nil
+}
+
+// containerName returns the shortened container ID from the cgroup of the given process, or the command name of the process
+func containerName(pid int) string {
+	if cgroup, err := ioutil.ReadFile(fmt.Sprintf("/proc/%v/cgroup", pid)); err == nil {
+		if id := containerId(string(cgroup)); id != "" {
+			return id
+		}
+	}
+	if comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%v/comm", pid)); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+	return strconv.Itoa(pid)
+}
+
+// containerId returns the first 12 characters of the innermost container ID in the given cgroup file, or an empty string
+func containerId(cgroup string) string {
+	if ids := containerIdRegex.FindAllString(cgroup, -1); len(ids) > 0 {
+		return ids[len(ids)-1][:12]
+	}
+	return ""
+}
+
+// listPids returns the PIDs of all processes in ascending order, so that the main process of a container is found first
+func listPids() ([]int, error) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(dirs))
+	for _, dir := range dirs {
+		if pid, err := strconv.Atoi(filepath.Base(dir)); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+func readInterfaceIndex(nic string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join("/sys/class/net", nic, "ifindex"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,85 @@
+package netns
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type VethTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestVeth(t *testing.T) {
+	suite.Run(t, new(VethTestSuite))
+}
+
+const (
+	testContainerId = "4f66ad9a0b2b9c4e1a3bde1e1f1c0a7d3b6f8e2a9c5d4b3a2f1e0d9c8b7a6f5e"
+	testPodId       = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+)
+
+func (suite *VethTestSuite) TestContainerId() {
+	for _, test := range []struct {
+		name     string
+		cgroup   string
+		expected string
+	}{
+		{
+			name:     "docker cgroup v1",
+			cgroup:   "12:memory:/docker/" + testContainerId + "\n11:cpu,cpuacct:/docker/" + testContainerId + "\n",
+			expected: testContainerId[:12],
+		},
+		{
+			name:     "docker cgroup v2",
+			cgroup:   "0::/system.slice/docker-" + testContainerId + ".scope\n",
+			expected: testContainerId[:12],
+		},
+		{
+			name:     "kubernetes pod",
+			cgroup:   "0::/kubepods/besteffort/pod" + testPodId + "/cri-containerd-" + testContainerId + ".scope\n",
+			expected: testContainerId[:12],
+		},
+		{
+			name:     "no container",
+			cgroup:   "0::/user.slice/user-1000.slice/session-2.scope\n",
+			expected: "",
+		},
+		{
+			name:     "short id",
+			cgroup:   "0::/docker/4f66ad9a0b2b\n",
+			expected: "",
+		},
+	} {
+		suite.Equal(test.expected, containerId(test.cgroup), test.name)
+	}
+}
+
+func (suite *VethTestSuite) TestContainerNameExists() {
+	namespaces := containerNamespaces{
+		"net:[4026532281]": {container: "nginx", peers: []int{5}},
+		"net:[4026532282]": {container: testContainerId[:12]},
+	}
+	suite.True(namespaces.containerNameExists("nginx"))
+	suite.True(namespaces.containerNameExists(testContainerId[:12]))
+	suite.False(namespaces.containerNameExists("redis"))
+	suite.False(containerNamespaces(nil).containerNameExists("nginx"))
+}
+
+func (suite *VethTestSuite) TestListPids() {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		suite.T().Skip("/proc is not available")
+	}
+	pids, err := listPids()
+	suite.NoError(err)
+	suite.Contains(pids, os.Getpid())
+	suite.True(sort.IntsAreSorted(pids))
+}
+
+func (suite *VethTestSuite) TestReadInterfaceIndex() {
+	_, err := readInterfaceIndex("does-not-exist")
+	suite.True(os.IsNotExist(err))
+}
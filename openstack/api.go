@@ -0,0 +1,121 @@
+package openstack
+
+import (
+	"context"
+)
+
+type link struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+func nextLink(links []link) string {
+	for _, l := range links {
+		if l.Rel == "next" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+type hypervisor struct {
+	Hostname     string  `json:"hypervisor_hostname"`
+	State        string  `json:"state"`
+	Status       string  `json:"status"`
+	Vcpus        float64 `json:"vcpus"`
+	VcpusUsed    float64 `json:"vcpus_used"`
+	MemoryMb     float64 `json:"memory_mb"`
+	MemoryMbUsed float64 `json:"memory_mb_used"`
+	LocalGb      float64 `json:"local_gb"`
+	LocalGbUsed  float64 `json:"local_gb_used"`
+	RunningVms   float64 `json:"running_vms"`
+}
+
+func (c *client) listHypervisors(ctx context.Context) ([]hypervisor, error) {
+	var result struct {
+		Hypervisors []hypervisor `json:"hypervisors"`
+	}
+	err := c.get(ctx, "compute", "/os-hypervisors/detail", &result)
+	return result.Hypervisors, err
+}
+
+type server struct {
+	TenantId string `json:"tenant_id"`
+	Status   string `json:"status"`
+	Flavor   struct {
+		Name  string  `json:"original_name"`
+		Vcpus float64 `json:"vcpus"`
+		Ram   float64 `json:"ram"`
+		Disk  float64 `json:"disk"`
+	} `json:"flavor"`
+}
+
+func (c *client) listServers(ctx context.Context) ([]server, error) {
+	var servers []server
+	path := "/servers/detail?all_tenants=1"
+	for path != "" {
+		var result struct {
+			Servers []server `json:"servers"`
+			Links   []link   `json:"servers_links"`
+		}
+		if err := c.get(ctx, "compute", path, &result); err != nil {
+			return nil, err
+		}
+		servers = append(servers, result.Servers...)
+		path = nextLink(result.Links)
+	}
+	return servers, nil
+}
+
+// listProjectNames returns the names of all projects visible to the user, indexed by their ID.
+func (c *client) listProjectNames(ctx context.Context) (map[string]string, error) {
+	var result struct {
+		Projects []struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"projects"`
+	}
+	if err := c.get(ctx, "identity", "/projects", &result); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(result.Projects))
+	for _, project := range result.Projects {
+		names[project.Id] = project.Name
+	}
+	return names, nil
+}
+
+type resourceProvider struct {
+	Uuid string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type inventory struct {
+	Total           float64 `json:"total"`
+	Reserved        float64 `json:"reserved"`
+	AllocationRatio float64 `json:"allocation_ratio"`
+}
+
+func (c *client) listResourceProviders(ctx context.Context) ([]resourceProvider, error) {
+	var result struct {
+		Providers []resourceProvider `json:"resource_providers"`
+	}
+	err := c.get(ctx, "placement", "/resource_providers", &result)
+	return result.Providers, err
+}
+
+func (c *client) providerInventories(ctx context.Context, uuid string) (map[string]inventory, error) {
+	var result struct {
+		Inventories map[string]inventory `json:"inventories"`
+	}
+	err := c.get(ctx, "placement", "/resource_providers/"+uuid+"/inventories", &result)
+	return result.Inventories, err
+}
+
+func (c *client) providerUsages(ctx context.Context, uuid string) (map[string]float64, error) {
+	var result struct {
+		Usages map[string]float64 `json:"usages"`
+	}
+	err := c.get(ctx, "placement", "/resource_providers/"+uuid+"/usages", &result)
+	return result.Usages, err
+}
@@ -0,0 +1,200 @@
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Microversion of the Nova API. Version 2.47 embeds the flavor details in server descriptions,
+// while the hypervisor capacity fields are still available (they were removed in version 2.88).
+const novaMicroversion = "2.47"
+
+// Config contains the credentials for authenticating against the Keystone v3 API.
+type Config struct {
+	AuthUrl       string
+	Username      string
+	Password      string
+	ProjectName   string
+	UserDomain    string
+	ProjectDomain string
+	Region        string
+	Timeout       time.Duration
+}
+
+// ConfigFromEnv reads the standard OpenStack environment variables OS_AUTH_URL, OS_USERNAME, OS_PASSWORD,
+// OS_PROJECT_NAME, OS_USER_DOMAIN_NAME, OS_PROJECT_DOMAIN_NAME and OS_REGION_NAME.
+func ConfigFromEnv() Config {
+	return Config{
+		AuthUrl:       os.Getenv("OS_AUTH_URL"),
+		Username:      os.Getenv("OS_USERNAME"),
+		Password:      os.Getenv("OS_PASSWORD"),
+		ProjectName:   os.Getenv("OS_PROJECT_NAME"),
+		UserDomain:    envDefault("OS_USER_DOMAIN_NAME", "Default"),
+		ProjectDomain: envDefault("OS_PROJECT_DOMAIN_NAME", "Default"),
+		Region:        os.Getenv("OS_REGION_NAME"),
+	}
+}
+
+func envDefault(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultValue
+}
+
+// client performs authenticated requests against the OpenStack APIs. The token is renewed when it expires
+// or when a request is rejected as unauthorized.
+type client struct {
+	config Config
+	http   http.Client
+
+	lock      sync.Mutex
+	token     string
+	expires   time.Time
+	endpoints map[string]string // Service type -> public endpoint URL
+}
+
+func newClient(config Config) *client {
+	return &client{
+		config: config,
+		http:   http.Client{Timeout: config.Timeout},
+	}
+}
+
+type tokenResponse struct {
+	Token struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		Catalog   []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				Url       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+func (c *client) authenticate(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.token != "" && time.Now().Add(time.Minute).Before(c.expires) {
+		return nil
+	}
+	body := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"password"},
+				"password": map[string]interface{}{
+					"user": map[string]interface{}{
+						"name":     c.config.Username,
+						"password": c.config.Password,
+						"domain":   map[string]string{"name": c.config.UserDomain},
+					},
+				},
+			},
+			"scope": map[string]interface{}{
+				"project": map[string]interface{}{
+					"name":   c.config.ProjectName,
+					"domain": map[string]string{"name": c.config.ProjectDomain},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(c.config.AuthUrl, "/")+"/auth/tokens", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("Keystone authentication failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("Keystone authentication failed: %v", resp.Status)
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("Failed to parse Keystone token response: %v", err)
+	}
+
+	endpoints := make(map[string]string)
+	for _, service := range token.Token.Catalog {
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Interface == "public" && (c.config.Region == "" || endpoint.Region == c.config.Region) {
+				endpoints[service.Type] = strings.TrimSuffix(endpoint.Url, "/")
+				break
+			}
+		}
+	}
+	c.token = resp.Header.Get("X-Subject-Token")
+	c.expires = token.Token.ExpiresAt
+	c.endpoints = endpoints
+	return nil
+}
+
+func (c *client) invalidateToken() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.token = ""
+}
+
+// get requests the given path of the given service type and parses the JSON response into the result.
+// If the path is an absolute URL, it is used unchanged.
+func (c *client) get(ctx context.Context, serviceType string, path string, result interface{}) error {
+	if err := c.authenticate(ctx); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	token, endpoint := c.token, c.endpoints[serviceType]
+	c.lock.Unlock()
+	if endpoint == "" {
+		return fmt.Errorf("No public endpoint for OpenStack service type '%v' in the service catalog", serviceType)
+	}
+	url := path
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = endpoint + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Accept", "application/json")
+	switch serviceType {
+	case "compute":
+		req.Header.Set("X-OpenStack-Nova-API-Version", novaMicroversion)
+	case "placement":
+		req.Header.Set("OpenStack-API-Version", "placement 1.9")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.invalidateToken()
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Request to %v failed: %v (%v)", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("Failed to parse response of %v: %v", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package openstack
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	mbToBytes = 1024 * 1024
+	gbToBytes = 1024 * mbToBytes
+)
+
+// Collector queries the Nova and Placement APIs of an OpenStack cloud. It reports the capacity and usage
+// of all hypervisors as seen by the scheduler, and the resource usage of all projects, also split by flavor.
+// Project and flavor names are part of the metric names (openstack/project/<project>/flavor/<flavor>/...).
+// Listing all servers and hypervisors requires admin privileges.
+type Collector struct {
+	collector.AbstractCollector
+	client *client
+
+	lock         sync.Mutex
+	values       map[string]float64
+	metadata     collector.MetricMetadataMap
+	projectNames map[string]string
+	namesFailed  bool
+}
+
+func NewOpenstackCollector(config Config) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("openstack"),
+		client:            newClient(config),
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.projectNames = nil
+	col.namesFailed = false
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.values))
+	for name := range col.values {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.values[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	values := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	set := func(name string, value float64, unit string, description string) {
+		values[name] += value
+		metadata[name] = collector.GaugeMetric(unit, description)
+	}
+	if err := col.updateHypervisors(ctx, set); err != nil {
+		return err
+	}
+	if err := col.updatePlacement(ctx, set); err != nil {
+		return err
+	}
+	if err := col.updateProjects(ctx, set); err != nil {
+		return err
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := len(values) != len(col.values)
+	for name := range values {
+		if _, ok := col.values[name]; !ok {
+			changed = true
+		}
+	}
+	col.values = values
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+type setValueFunc func(name string, value float64, unit string, description string)
+
+func (col *Collector) updateHypervisors(ctx context.Context, set setValueFunc) error {
+	hypervisors, err := col.client.listHypervisors(ctx)
+	if err != nil {
+		return err
+	}
+	for _, h := range hypervisors {
+		prefix := "openstack/hypervisor/" + metricName(h.Hostname) + "/"
+		up := 0.0
+		if h.State == "up" && h.Status == "enabled" {
+			up = 1
+		}
+		set(prefix+"up", up, collector.UnitNone, "1 if the hypervisor is up and enabled, otherwise 0")
+		set(prefix+"vcpus", h.Vcpus, collector.UnitCount, "Number of physical CPUs of the hypervisor")
+		set(prefix+"vcpus-used", h.VcpusUsed, collector.UnitCount, "Number of virtual CPUs allocated on the hypervisor")
+		set(prefix+"mem", h.MemoryMb*mbToBytes, collector.UnitBytes, "Memory of the hypervisor")
+		set(prefix+"mem-used", h.MemoryMbUsed*mbToBytes, collector.UnitBytes, "Memory allocated on the hypervisor")
+		set(prefix+"disk", h.LocalGb*gbToBytes, collector.UnitBytes, "Local disk space of the hypervisor")
+		set(prefix+"disk-used", h.LocalGbUsed*gbToBytes, collector.UnitBytes, "Local disk space allocated on the hypervisor")
+		set(prefix+"vms", h.RunningVms, collector.UnitCount, "Number of VMs running on the hypervisor")
+	}
+	return nil
+}
+
+func (col *Collector) updatePlacement(ctx context.Context, set setValueFunc) error {
+	providers, err := col.client.listResourceProviders(ctx)
+	if err != nil {
+		return err
+	}
+	for _, provider := range providers {
+		inventories, err := col.client.providerInventories(ctx, provider.Uuid)
+		if err != nil {
+			return err
+		}
+		usages, err := col.client.providerUsages(ctx, provider.Uuid)
+		if err != nil {
+			return err
+		}
+		prefix := "openstack/hypervisor/" + metricName(provider.Name) + "/placement/"
+		for class, inv := range inventories {
+			ratio := inv.AllocationRatio
+			if ratio <= 0 {
+				ratio = 1
+			}
+			set(prefix+class+"/capacity", (inv.Total-inv.Reserved)*ratio, collector.UnitCount, "Capacity of the resource class available to the scheduler, including the allocation ratio")
+			set(prefix+class+"/used", usages[class], collector.UnitCount, "Allocated amount of the resource class")
+		}
+	}
+	return nil
+}
+
+func (col *Collector) updateProjects(ctx context.Context, set setValueFunc) error {
+	servers, err := col.client.listServers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, server := range servers {
+		prefix := "openstack/project/" + metricName(col.projectName(ctx, server.TenantId)) + "/"
+		flavorPrefix := prefix + "flavor/" + metricName(server.Flavor.Name) + "/"
+		set(prefix+"instances", 1, collector.UnitCount, "Number of instances of the project")
+		set(flavorPrefix+"instances", 1, collector.UnitCount, "Number of instances of the project with the flavor")
+		if server.Status == "SHUTOFF" || server.Status == "SHELVED_OFFLOADED" {
+			continue
+		}
+		set(prefix+"active", 1, collector.UnitCount, "Number of instances of the project that are not shut off")
+		set(prefix+"vcpus", server.Flavor.Vcpus, collector.UnitCount, "Virtual CPUs of all active instances of the project")
+		set(prefix+"ram", server.Flavor.Ram*mbToBytes, collector.UnitBytes, "Memory of all active instances of the project")
+		set(prefix+"disk", server.Flavor.Disk*gbToBytes, collector.UnitBytes, "Root disk size of all active instances of the project")
+	}
+	return nil
+}
+
+// projectName resolves the name of a project through Keystone. The names are cached until the next Init().
+// If the project names cannot be listed due to missing permissions, the project ID is used instead.
+func (col *Collector) projectName(ctx context.Context, id string) string {
+	if name, ok := col.projectNames[id]; ok || col.namesFailed {
+		if !ok {
+			name = id
+		}
+		return name
+	}
+	names, err := col.client.listProjectNames(ctx)
+	if err != nil {
+		log.Warnln("Failed to list OpenStack projects, using project IDs in metric names:", err)
+		names = make(map[string]string)
+		col.namesFailed = true
+	}
+	if _, ok := names[id]; !ok {
+		names[id] = id
+	}
+	col.projectNames = names
+	return names[id]
+}
+
+func metricName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
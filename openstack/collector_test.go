@@ -0,0 +1,160 @@
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenstackTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestOpenstack(t *testing.T) {
+	suite.Run(t, new(OpenstackTestSuite))
+}
+
+// newTestServer simulates Keystone, Nova and Placement. The responses are indexed by the request URI.
+func (suite *OpenstackTestSuite) newTestServer(responses map[string]string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/identity/auth/tokens" {
+			w.Header().Set("X-Subject-Token", "secret")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"token": {"expires_at": %q, "catalog": [
+				{"type": "compute", "endpoints": [{"interface": "public", "region": "r1", "url": "%v/compute/"}]},
+				{"type": "placement", "endpoints": [{"interface": "internal", "region": "r1", "url": "%v/internal"},
+					{"interface": "public", "region": "r1", "url": "%v/placement"}]},
+				{"type": "identity", "endpoints": [{"interface": "public", "region": "r1", "url": "%v/identity"}]}
+			]}}`, time.Now().Add(time.Hour).Format(time.RFC3339), server.URL, server.URL, server.URL, server.URL)
+			return
+		}
+		if r.Header.Get("X-Auth-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		response, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	return server
+}
+
+func (suite *OpenstackTestSuite) TestCollect() {
+	responses := map[string]string{
+		"/compute/os-hypervisors/detail": `{"hypervisors": [
+			{"hypervisor_hostname": "node1", "state": "up", "status": "enabled", "vcpus": 16, "vcpus_used": 6,
+				"memory_mb": 2048, "memory_mb_used": 1024, "local_gb": 100, "local_gb_used": 10, "running_vms": 3},
+			{"hypervisor_hostname": "node 2", "state": "down", "status": "enabled", "vcpus": 8}
+		]}`,
+		"/placement/resource_providers": `{"resource_providers": [{"uuid": "p1", "name": "node1"}]}`,
+		"/placement/resource_providers/p1/inventories": `{"inventories": {
+			"VCPU": {"total": 16, "reserved": 2, "allocation_ratio": 4},
+			"MEMORY_MB": {"total": 2048, "reserved": 512, "allocation_ratio": 0}
+		}}`,
+		"/placement/resource_providers/p1/usages": `{"usages": {"VCPU": 6}}`,
+		"/compute/servers/detail?all_tenants=1": `{"servers": [
+			{"tenant_id": "t1", "status": "ACTIVE", "flavor": {"original_name": "m1.small", "vcpus": 2, "ram": 512, "disk": 1}},
+			{"tenant_id": "t1", "status": "SHUTOFF", "flavor": {"original_name": "m1.small", "vcpus": 2, "ram": 512, "disk": 1}}
+		], "servers_links": [{"rel": "next", "href": "SERVER/compute/servers/detail?all_tenants=1&marker=2"}]}`,
+		"/compute/servers/detail?all_tenants=1&marker=2": `{"servers": [
+			{"tenant_id": "t2", "status": "ACTIVE", "flavor": {"original_name": "m1/large", "vcpus": 4, "ram": 1024, "disk": 2}}
+		]}`,
+		"/identity/projects": `{"projects": [{"id": "t1", "name": "demo"}]}`,
+	}
+	server := suite.newTestServer(responses)
+	defer server.Close()
+	key := "/compute/servers/detail?all_tenants=1"
+	responses[key] = strings.Replace(responses[key], "SERVER", server.URL, 1)
+
+	col := NewOpenstackCollector(Config{AuthUrl: server.URL + "/identity/", Region: "r1", Timeout: time.Second})
+	children, err := col.Init(context.Background())
+	suite.NoError(err)
+	suite.Empty(children)
+
+	values := make(map[string]float64)
+	for name, reader := range col.Metrics() {
+		values[name] = float64(reader())
+	}
+	suite.Equal(map[string]float64{
+		"openstack/hypervisor/node1/up":          1,
+		"openstack/hypervisor/node1/vcpus":       16,
+		"openstack/hypervisor/node1/vcpus-used":  6,
+		"openstack/hypervisor/node1/mem":         2048 * mbToBytes,
+		"openstack/hypervisor/node1/mem-used":    1024 * mbToBytes,
+		"openstack/hypervisor/node1/disk":        100 * gbToBytes,
+		"openstack/hypervisor/node1/disk-used":   10 * gbToBytes,
+		"openstack/hypervisor/node1/vms":         3,
+		"openstack/hypervisor/node_2/up":         0,
+		"openstack/hypervisor/node_2/vcpus":      8,
+		"openstack/hypervisor/node_2/vcpus-used": 0,
+		"openstack/hypervisor/node_2/mem":        0,
+		"openstack/hypervisor/node_2/mem-used":   0,
+		"openstack/hypervisor/node_2/disk":       0,
+		"openstack/hypervisor/node_2/disk-used":  0,
+		"openstack/hypervisor/node_2/vms":        0,
+
+		"openstack/hypervisor/node1/placement/VCPU/capacity":      56,
+		"openstack/hypervisor/node1/placement/VCPU/used":          6,
+		"openstack/hypervisor/node1/placement/MEMORY_MB/capacity": 1536,
+		"openstack/hypervisor/node1/placement/MEMORY_MB/used":     0,
+
+		"openstack/project/demo/instances":                 2,
+		"openstack/project/demo/active":                    1,
+		"openstack/project/demo/vcpus":                     2,
+		"openstack/project/demo/ram":                       512 * mbToBytes,
+		"openstack/project/demo/disk":                      1 * gbToBytes,
+		"openstack/project/demo/flavor/m1.small/instances": 2,
+		"openstack/project/t2/instances":                   1,
+		"openstack/project/t2/active":                      1,
+		"openstack/project/t2/vcpus":                       4,
+		"openstack/project/t2/ram":                         1024 * mbToBytes,
+		"openstack/project/t2/disk":                        2 * gbToBytes,
+		"openstack/project/t2/flavor/m1_large/instances":   1,
+	}, values)
+
+	// A new hypervisor changes the set of metrics
+	responses["/compute/os-hypervisors/detail"] = `{"hypervisors": [{"hypervisor_hostname": "node3"}]}`
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+	suite.NoError(col.Update(context.Background()))
+}
+
+func (suite *OpenstackTestSuite) TestErrors() {
+	for _, test := range []struct {
+		name      string
+		responses map[string]string
+		config    Config
+	}{
+		{"wrong region", map[string]string{}, Config{Region: "r2"}},
+		{"missing hypervisors", map[string]string{}, Config{}},
+		{"invalid json", map[string]string{"/compute/os-hypervisors/detail": `{"hypervisors": [`}, Config{}},
+	} {
+		server := suite.newTestServer(test.responses)
+		test.config.AuthUrl = server.URL + "/identity"
+		col := NewOpenstackCollector(test.config)
+		_, err := col.Init(context.Background())
+		suite.Error(err, test.name)
+		server.Close()
+	}
+}
+
+func (suite *OpenstackTestSuite) TestMetricName() {
+	for input, expected := range map[string]string{
+		"":                "unknown",
+		"node1":           "node1",
+		"my project/test": "my_project_test",
+	} {
+		suite.Equal(expected, metricName(input))
+	}
+}
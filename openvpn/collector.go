@@ -0,0 +1,140 @@
+package openvpn
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const DefaultTimeout = 2 * time.Second
+
+// Collector reads the client lists of OpenVPN servers from their status files or management interfaces.
+// The Servers map contains the name and the status source of every server (see readStatus()).
+// Metrics are reported as openvpn/<server>/..., per-client metrics as openvpn/<server>/client/<common name>/...
+// Multiple connections with the same common name are summed up. The traffic of a client is reported as a rate of
+// the byte counters of its current connections.
+type Collector struct {
+	collector.AbstractCollector
+	Servers map[string]string
+	Timeout time.Duration
+	factory *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewOpenvpnCollector(servers map[string]string, factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("openvpn"),
+		Servers:           servers,
+		Timeout:           DefaultTimeout,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	counters := make(map[string]float64)
+	gauges := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	now := time.Now()
+	for server, source := range col.Servers {
+		clients, err := readStatus(ctx, source, col.Timeout)
+		if err != nil {
+			return err
+		}
+		prefix := "openvpn/" + server + "/"
+		gauges[prefix+"clients"] = float64(len(clients))
+		metadata[prefix+"clients"] = collector.GaugeMetric(collector.UnitCount, "Connected clients")
+		for _, client := range clients {
+			clientPrefix := prefix + "client/" + strings.NewReplacer("/", "_", " ", "_").Replace(client.commonName) + "/"
+			counters[clientPrefix+"rx"] += client.rxBytes
+			counters[clientPrefix+"tx"] += client.txBytes
+			gauges[clientPrefix+"connections"]++
+			if !client.connectedSince.IsZero() {
+				// The longest connection is reported for multiple connections with the same common name
+				if duration := now.Sub(client.connectedSince).Seconds(); duration > gauges[clientPrefix+"connected-time"] {
+					gauges[clientPrefix+"connected-time"] = duration
+				}
+				metadata[clientPrefix+"connected-time"] = collector.GaugeMetric(collector.UnitSeconds, "Time since the client connected")
+			}
+			metadata[clientPrefix+"rx"] = collector.DerivedMetric(collector.UnitBytesPerSecond, "Bytes received from the client")
+			metadata[clientPrefix+"tx"] = collector.DerivedMetric(collector.UnitBytesPerSecond, "Bytes sent to the client")
+			metadata[clientPrefix+"connections"] = collector.GaugeMetric(collector.UnitCount, "Connections of the client")
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(counters) != len(col.counters) || len(gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(counters))
+	}
+	for name, value := range counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
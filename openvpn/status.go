@@ -0,0 +1,122 @@
+package openvpn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type openvpnClient struct {
+	commonName     string
+	rxBytes        float64
+	txBytes        float64
+	connectedSince time.Time
+}
+
+// readStatus reads the client list of one OpenVPN server. The source is either the path of a status file
+// written with 'status-version 2' or 'status-version 3', or the address of the management interface in
+// the form tcp://host:port or unix:///path.
+func readStatus(ctx context.Context, source string, timeout time.Duration) ([]openvpnClient, error) {
+	if strings.HasPrefix(source, "tcp://") || strings.HasPrefix(source, "unix://") {
+		return readManagementStatus(ctx, source, timeout)
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseStatus(file)
+}
+
+func readManagementStatus(ctx context.Context, address string, timeout time.Duration) ([]openvpnClient, error) {
+	network, addr := "tcp", strings.TrimPrefix(address, "tcp://")
+	if strings.HasPrefix(address, "unix://") {
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte("status 2\nquit\n")); err != nil {
+		return nil, err
+	}
+	return parseStatus(conn)
+}
+
+// parseStatus parses the status format version 2 (comma-separated) or 3 (tab-separated). The columns of
+// the client list are identified through the preceding HEADER line. Other lines, like the greeting
+// of the management interface, are ignored.
+func parseStatus(input io.Reader) ([]openvpnClient, error) {
+	var (
+		clients []openvpnClient
+		columns map[string]int
+	)
+	column := func(fields []string, name string) string {
+		if index, ok := columns[name]; ok && index < len(fields) {
+			return fields[index]
+		}
+		return ""
+	}
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "END" {
+			if columns == nil {
+				// The client list of status-version 1 has no HEADER line
+				return nil, fmt.Errorf("OpenVPN status does not contain a client list header, make sure to configure 'status-version 2' or 'status-version 3'")
+			}
+			return clients, nil
+		}
+		separator := ","
+		if strings.ContainsRune(line, '\t') {
+			separator = "\t"
+		}
+		fields := strings.Split(line, separator)
+		switch fields[0] {
+		case "HEADER":
+			if len(fields) > 1 && fields[1] == "CLIENT_LIST" {
+				// The column indices refer to the CLIENT_LIST lines, which do not contain the HEADER field
+				columns = make(map[string]int, len(fields))
+				for i, name := range fields[1:] {
+					columns[name] = i
+				}
+			}
+		case "CLIENT_LIST":
+			if columns == nil {
+				return nil, fmt.Errorf("OpenVPN status contains CLIENT_LIST without preceding HEADER")
+			}
+			rx, err := strconv.ParseFloat(column(fields, "Bytes Received"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse received bytes of OpenVPN client: %v", err)
+			}
+			tx, err := strconv.ParseFloat(column(fields, "Bytes Sent"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse sent bytes of OpenVPN client: %v", err)
+			}
+			client := openvpnClient{
+				commonName: column(fields, "Common Name"),
+				rxBytes:    rx,
+				txBytes:    tx,
+			}
+			if since, err := strconv.ParseInt(column(fields, "Connected Since (time_t)"), 10, 64); err == nil {
+				client.connectedSince = time.Unix(since, 0)
+			}
+			clients = append(clients, client)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("OpenVPN status is incomplete (missing END line), make sure to configure 'status-version 2' or 'status-version 3'")
+}
@@ -0,0 +1,123 @@
+package openvpn
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type StatusTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestStatus(t *testing.T) {
+	suite.Run(t, new(StatusTestSuite))
+}
+
+func (suite *StatusTestSuite) TestParseStatus() {
+	for _, test := range []struct {
+		name     string
+		status   string
+		expected []openvpnClient
+		err      bool
+	}{
+		{
+			name: "status-version 2",
+			status: `TITLE,OpenVPN 2.5.5 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [PKCS11] [MH/PKTINFO] [AEAD]
+TIME,Thu Oct 15 10:05:00 2026,1792058700
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID,Data Channel Cipher
+CLIENT_LIST,client1,203.0.113.5:51234,10.8.0.2,,1234,5678,Thu Oct 15 10:00:00 2026,1792058400,UNDEF,0,0,AES-256-GCM
+CLIENT_LIST,client2,198.51.100.7:1194,10.8.0.3,,10,20,Thu Oct 15 10:01:00 2026,1792058460,UNDEF,1,1,AES-256-GCM
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+ROUTING_TABLE,10.8.0.2,client1,203.0.113.5:51234,Thu Oct 15 10:04:59 2026,1792058699
+GLOBAL_STATS,Max bcast/mcast queue length,0
+END
+`,
+			expected: []openvpnClient{
+				{commonName: "client1", rxBytes: 1234, txBytes: 5678, connectedSince: time.Unix(1792058400, 0)},
+				{commonName: "client2", rxBytes: 10, txBytes: 20, connectedSince: time.Unix(1792058460, 0)},
+			},
+		},
+		{
+			name: "status-version 3",
+			status: "TITLE\tOpenVPN 2.4.12 x86_64-pc-linux-gnu\n" +
+				"TIME\tThu Oct 15 10:05:00 2026\t1792058700\n" +
+				"HEADER\tCLIENT_LIST\tCommon Name\tReal Address\tVirtual Address\tVirtual IPv6 Address\tBytes Received\tBytes Sent\tConnected Since\tConnected Since (time_t)\tUsername\tClient ID\tPeer ID\n" +
+				"CLIENT_LIST\tJohn Doe, Laptop\t203.0.113.5:51234\t10.8.0.2\t\t100\t200\tThu Oct 15 10:00:00 2026\t1792058400\tUNDEF\t0\t0\n" +
+				"END\n",
+			expected: []openvpnClient{
+				{commonName: "John Doe, Laptop", rxBytes: 100, txBytes: 200, connectedSince: time.Unix(1792058400, 0)},
+			},
+		},
+		{
+			name: "management interface",
+			status: ">INFO:OpenVPN Management Interface Version 3 -- type 'help' for more info\r\n" +
+				"TITLE,OpenVPN 2.5.5 x86_64-pc-linux-gnu\r\n" +
+				"HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID,Data Channel Cipher\r\n" +
+				"CLIENT_LIST,client1,203.0.113.5:51234,10.8.0.2,,1,2,Thu Oct 15 10:00:00 2026,1792058400,UNDEF,0,0,AES-256-GCM\r\n" +
+				"END\r\n",
+			expected: []openvpnClient{
+				{commonName: "client1", rxBytes: 1, txBytes: 2, connectedSince: time.Unix(1792058400, 0)},
+			},
+		},
+		{
+			name: "older version without time_t column",
+			status: `HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Bytes Received,Bytes Sent,Connected Since
+CLIENT_LIST,client1,203.0.113.5:51234,10.8.0.2,5,6,Thu Oct 15 10:00:00 2026
+END
+`,
+			expected: []openvpnClient{
+				{commonName: "client1", rxBytes: 5, txBytes: 6},
+			},
+		},
+		{
+			name: "no clients",
+			status: `HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+END
+`,
+		},
+		{
+			name: "status-version 1",
+			status: `OpenVPN CLIENT LIST
+Updated,Thu Oct 15 10:05:00 2026
+Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
+client1,203.0.113.5:51234,1234,5678,Thu Oct 15 10:00:00 2026
+ROUTING TABLE
+END
+`,
+			err: true,
+		},
+		{
+			name: "missing END",
+			status: `HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent
+CLIENT_LIST,client1,203.0.113.5:51234,10.8.0.2,,1234,5678
+`,
+			err: true,
+		},
+		{
+			name:   "CLIENT_LIST without HEADER",
+			status: "CLIENT_LIST,client1,203.0.113.5:51234,10.8.0.2,,1234,5678\nEND\n",
+			err:    true,
+		},
+		{
+			name: "truncated CLIENT_LIST",
+			status: `HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent
+CLIENT_LIST,client1,203.0.113.5:51234
+END
+`,
+			err: true,
+		},
+	} {
+		clients, err := parseStatus(strings.NewReader(test.status))
+		if test.err {
+			suite.Error(err, test.name)
+		} else {
+			suite.NoError(err, test.name)
+			suite.Equal(test.expected, clients, test.name)
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package ovsdb
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"sync"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
@@ -17,11 +16,12 @@ type Collector struct {
 	Port    int
 	factory *collector.ValueRingFactory
 
-	client              *libovsdb.OvsdbClient
-	lastUpdateError     error
-	notifier            ovsdbNotifier
-	interfaceCollectors map[string]*ovsdbInterfaceCollector
-	readersLock         sync.Mutex
+	client          *libovsdb.OvsdbClient
+	lastUpdateError error
+	notifier        ovsdbNotifier
+	tables          ovsdbTables
+	portCollectors  map[string]*ovsdbPortCollector
+	readersLock     sync.Mutex
 }
 
 func NewOvsdbCollector(host string, factory *collector.ValueRingFactory) *Collector {
@@ -36,28 +36,37 @@ func NewOvsdbCollectorPort(host string, port int, factory *collector.ValueRingFa
 		factory:           factory}
 }
 
-func (parent *Collector) Init() ([]collector.Collector, error) {
+// NewNamedOvsdbCollector returns a collector for one of multiple monitored OVSDB servers. The collector
+// is named "ovsdb-<name>", which is also the prefix of all its metrics.
+func NewNamedOvsdbCollector(name string, host string, port int, factory *collector.ValueRingFactory) *Collector {
+	col := NewOvsdbCollectorPort(host, port, factory)
+	col.Name = "ovsdb-" + name
+	return col
+}
+
+func (parent *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
 	parent.Close()
 	parent.notifier.col = parent
 	parent.lastUpdateError = nil
-	parent.interfaceCollectors = make(map[string]*ovsdbInterfaceCollector)
+	parent.tables = newOvsdbTables()
+	parent.portCollectors = make(map[string]*ovsdbPortCollector)
 	if err := parent.update(false); err != nil {
 		return nil, err
 	}
 
-	readers := make([]collector.Collector, 0, len(parent.interfaceCollectors))
-	for _, reader := range parent.interfaceCollectors {
+	readers := make([]collector.Collector, 0, len(parent.portCollectors))
+	for _, reader := range parent.portCollectors {
 		readers = append(readers, reader)
 	}
 	return readers, nil
 }
 
-func (parent *Collector) Update() error {
+func (parent *Collector) Update(ctx context.Context) error {
 	return parent.update(true)
 }
 
-func (parent *Collector) MetricsChanged() error {
-	return parent.Update()
+func (parent *Collector) MetricsChanged(ctx context.Context) error {
+	return parent.Update(ctx)
 }
 
 func (parent *Collector) Close() {
@@ -98,11 +107,10 @@ func (parent *Collector) openConnection() (*libovsdb.TableUpdates, *libovsdb.Ovs
 	}
 	ovs.Register(&parent.notifier)
 
-	// Request all updates for all Interface statistics
-	requests := map[string]libovsdb.MonitorRequest{
-		"Interface": {
-			Columns: []string{"name", "statistics"},
-		},
+	// Request all updates for the interface statistics and the bridge/port/interface hierarchy
+	requests := make(map[string]libovsdb.MonitorRequest, len(monitoredColumns))
+	for table, columns := range monitoredColumns {
+		requests[table] = libovsdb.MonitorRequest{Columns: columns}
 	}
 
 	initial, err := ovs.Monitor("Open_vSwitch", "", requests)
@@ -114,59 +122,43 @@ func (parent *Collector) openConnection() (*libovsdb.TableUpdates, *libovsdb.Ovs
 }
 
 func (parent *Collector) updateTables(checkChange bool, updates map[string]libovsdb.TableUpdate) error {
-	update, ok := updates["Interface"]
-	if !ok {
-		return fmt.Errorf("OVSDB update did not contain requested table 'Interface'. Instead: %v", updates)
-	}
-
 	parent.readersLock.Lock()
 	defer parent.readersLock.Unlock()
-	updatedInterfaces := make(map[string]bool)
-	for _, rowUpdate := range update.Rows {
-		if name, stats, err := parent.parseRowUpdate(rowUpdate.New); err != nil {
-			return err
-		} else {
-			updatedInterfaces[name] = true
-			reader, ok := parent.interfaceCollectors[name]
+	if err := parent.tables.apply(updates); err != nil {
+		return err
+	}
+
+	numPorts := 0
+	for _, bridge := range parent.tables.bridges {
+		for _, portUuid := range bridge.ports {
+			port, ok := parent.tables.ports[portUuid]
+			if !ok {
+				continue
+			}
+			interfaces := make([]ovsdbInterface, 0, len(port.interfaces))
+			for _, ifaceUuid := range port.interfaces {
+				if iface, ok := parent.tables.interfaces[ifaceUuid]; ok {
+					interfaces = append(interfaces, iface)
+				}
+			}
+			name := bridge.name + "/" + port.name
+			numPorts++
+			reader, ok := parent.portCollectors[name]
 			if !ok {
 				if checkChange {
 					return collector.MetricsChanged
 				} else {
-					reader = parent.newCollector(name)
-					parent.interfaceCollectors[name] = reader
+					reader = parent.newCollector(name, interfaces)
+					parent.portCollectors[name] = reader
 				}
 			}
-			reader.update(stats)
+			reader.update(interfaces)
 		}
 	}
 
-	// TODO regularly check, if one of the observed interfaces does not exist anymore
-	// Not every updated includes all interfaces.
-	return nil
-}
-
-func (parent *Collector) parseRowUpdate(row libovsdb.Row) (name string, stats map[string]float64, err error) {
-	defer func() {
-		// Allow panics for less explicit type checks
-		if rec := recover(); rec != nil {
-			err = fmt.Errorf("Parsing OVSDB row updated failed: %v", rec)
-		}
-	}()
-
-	if nameObj, ok := row.Fields["name"]; !ok {
-		err = errors.New("Row update did not include 'name' field")
-		return
-	} else {
-		name = nameObj.(string)
+	if checkChange && numPorts != len(parent.portCollectors) {
+		// Since the cached tables are complete, a port that has been removed is detected here
+		return collector.MetricsChanged
 	}
-	if statsObj, ok := row.Fields["statistics"]; !ok {
-		err = errors.New("Row update did not include 'statistics' field")
-	} else {
-		statMap := statsObj.(libovsdb.OvsMap)
-		stats = make(map[string]float64)
-		for keyObj, valObj := range statMap.GoMap {
-			stats[keyObj.(string)] = valObj.(float64)
-		}
-	}
-	return
+	return nil
 }
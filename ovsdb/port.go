@@ -0,0 +1,138 @@
+package ovsdb
+
+import (
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/psutil"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// ovsdbPortCollector reports the statistics of one port of an Open vSwitch bridge. The statistics of
+// all interfaces of the port (multiple for bonded ports) are summed up.
+type ovsdbPortCollector struct {
+	collector.AbstractCollector
+	parent   *Collector
+	counters psutil.NetIoCounters
+
+	// Every key of the statistics column, as reported when the collector was created
+	stats      map[string]*collector.ValueRing
+	linkResets *collector.ValueRing
+	linkSpeed  float64
+	adminUp    int
+	linkUp     int
+}
+
+func (parent *Collector) newCollector(name string, interfaces []ovsdbInterface) *ovsdbPortCollector {
+	col := &ovsdbPortCollector{
+		AbstractCollector: parent.Child(name),
+		parent:            parent,
+		counters:          psutil.NewNetIoCounters(parent.factory),
+		stats:             make(map[string]*collector.ValueRing),
+		linkResets:        parent.factory.NewValueRing(),
+	}
+	for _, iface := range interfaces {
+		for key := range iface.stats {
+			if _, ok := col.stats[key]; !ok {
+				col.stats[key] = parent.factory.NewValueRing()
+			}
+		}
+	}
+	return col
+}
+
+func (col *ovsdbPortCollector) prefix() string {
+	return col.parent.Name + "/" + col.Name
+}
+
+func (col *ovsdbPortCollector) Metrics() collector.MetricReaderMap {
+	prefix := col.prefix()
+	res := col.counters.Metrics(prefix)
+	for key, ring := range col.stats {
+		res[prefix+"/stats/"+key] = ring.GetDiff
+	}
+	res[prefix+"/link-resets"] = col.linkResets.GetDiff
+	res[prefix+"/link-speed"] = col.readLinkSpeed
+	res[prefix+"/admin-up"] = col.readAdminUp
+	res[prefix+"/link-up"] = col.readLinkUp
+	return res
+}
+
+func (col *ovsdbPortCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.prefix()
+	res := col.counters.MetricsMetadata(prefix)
+	for key := range col.stats {
+		res[prefix+"/stats/"+key] = collector.DerivedMetric(collector.UnitPerSecond, "Rate of the OVS interface statistic "+key)
+	}
+	res[prefix+"/link-resets"] = collector.DerivedMetric(collector.UnitPerSecond, "Changes of the link state")
+	res[prefix+"/link-speed"] = collector.GaugeMetric(collector.UnitNone, "Negotiated link speed in bits per second")
+	res[prefix+"/admin-up"] = collector.GaugeMetric(collector.UnitCount, "Interfaces with administrative state up")
+	res[prefix+"/link-up"] = collector.GaugeMetric(collector.UnitCount, "Interfaces with operational link state up")
+	return res
+}
+
+func (col *ovsdbPortCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+// update is called while holding parent.readersLock
+func (col *ovsdbPortCollector) update(interfaces []ovsdbInterface) {
+	col.fillValues(interfaces, []string{
+		"collisions",
+		"rx_crc_err",
+		"rx_errors",
+		"rx_frame_err",
+		"rx_over_err",
+		"tx_errors",
+	}, col.counters.Errors)
+	col.fillValues(interfaces, []string{"rx_dropped", "tx_dropped"}, col.counters.Dropped)
+	col.fillValues(interfaces, []string{"rx_bytes", "tx_bytes"}, col.counters.Bytes)
+	col.fillValues(interfaces, []string{"rx_packets", "tx_packets"}, col.counters.Packets)
+	col.fillValues(interfaces, []string{"rx_bytes"}, col.counters.RxBytes)
+	col.fillValues(interfaces, []string{"rx_packets"}, col.counters.RxPackets)
+	col.fillValues(interfaces, []string{"tx_bytes"}, col.counters.TxBytes)
+	col.fillValues(interfaces, []string{"tx_packets"}, col.counters.TxPackets)
+	for key, ring := range col.stats {
+		col.fillValues(interfaces, []string{key}, ring)
+	}
+
+	col.linkSpeed, col.adminUp, col.linkUp = 0, 0, 0
+	for _, iface := range interfaces {
+		col.linkResets.AddToHead(collector.StoredValue(iface.linkResets))
+		col.linkSpeed += iface.linkSpeed
+		if iface.adminUp {
+			col.adminUp++
+		}
+		if iface.linkUp {
+			col.linkUp++
+		}
+	}
+	col.linkResets.FlushHead()
+}
+
+func (col *ovsdbPortCollector) fillValues(interfaces []ovsdbInterface, names []string, ring *collector.ValueRing) {
+	for _, iface := range interfaces {
+		for _, name := range names {
+			if value, ok := iface.stats[name]; ok {
+				ring.AddToHead(collector.StoredValue(value))
+			}
+		}
+	}
+	ring.FlushHead()
+}
+
+func (col *ovsdbPortCollector) readLinkSpeed() bitflow.Value {
+	col.parent.readersLock.Lock()
+	defer col.parent.readersLock.Unlock()
+	return bitflow.Value(col.linkSpeed)
+}
+
+func (col *ovsdbPortCollector) readAdminUp() bitflow.Value {
+	col.parent.readersLock.Lock()
+	defer col.parent.readersLock.Unlock()
+	return bitflow.Value(col.adminUp)
+}
+
+func (col *ovsdbPortCollector) readLinkUp() bitflow.Value {
+	col.parent.readersLock.Lock()
+	defer col.parent.readersLock.Unlock()
+	return bitflow.Value(col.linkUp)
+}
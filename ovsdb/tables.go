@@ -0,0 +1,153 @@
+package ovsdb
+
+import (
+	"fmt"
+
+	"github.com/socketplane/libovsdb"
+)
+
+// The monitored tables and columns of the Open_vSwitch database. The Bridge and Port tables are required
+// to map every interface to its port and bridge.
+var monitoredColumns = map[string][]string{
+	"Bridge":    {"name", "ports"},
+	"Port":      {"name", "interfaces"},
+	"Interface": {"name", "statistics", "link_resets", "link_speed", "admin_state", "link_state"},
+}
+
+type ovsdbBridge struct {
+	name  string
+	ports []string
+}
+
+type ovsdbPort struct {
+	name       string
+	interfaces []string
+}
+
+type ovsdbInterface struct {
+	name       string
+	stats      map[string]float64
+	linkResets float64
+	linkSpeed  float64
+	adminUp    bool
+	linkUp     bool
+}
+
+// ovsdbTables caches the rows of the monitored tables, indexed by their UUIDs. Table updates only contain
+// the rows that changed since the previous update, so the full state of the database is maintained here.
+type ovsdbTables struct {
+	bridges    map[string]ovsdbBridge
+	ports      map[string]ovsdbPort
+	interfaces map[string]ovsdbInterface
+}
+
+func newOvsdbTables() ovsdbTables {
+	return ovsdbTables{
+		bridges:    make(map[string]ovsdbBridge),
+		ports:      make(map[string]ovsdbPort),
+		interfaces: make(map[string]ovsdbInterface),
+	}
+}
+
+func (tables *ovsdbTables) apply(updates map[string]libovsdb.TableUpdate) (err error) {
+	defer func() {
+		// Allow panics for less explicit type checks
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("Parsing OVSDB row updated failed: %v", rec)
+		}
+	}()
+
+	for uuid, rowUpdate := range updates["Bridge"].Rows {
+		if row := rowUpdate.New; len(row.Fields) == 0 {
+			delete(tables.bridges, uuid)
+		} else {
+			tables.bridges[uuid] = ovsdbBridge{
+				name:  rowString(row, "name"),
+				ports: rowUuids(row, "ports"),
+			}
+		}
+	}
+	for uuid, rowUpdate := range updates["Port"].Rows {
+		if row := rowUpdate.New; len(row.Fields) == 0 {
+			delete(tables.ports, uuid)
+		} else {
+			tables.ports[uuid] = ovsdbPort{
+				name:       rowString(row, "name"),
+				interfaces: rowUuids(row, "interfaces"),
+			}
+		}
+	}
+	for uuid, rowUpdate := range updates["Interface"].Rows {
+		if row := rowUpdate.New; len(row.Fields) == 0 {
+			delete(tables.interfaces, uuid)
+		} else {
+			linkResets, _ := rowOptionalNumber(row, "link_resets")
+			linkSpeed, _ := rowOptionalNumber(row, "link_speed")
+			adminState, _ := rowOptionalString(row, "admin_state")
+			linkState, _ := rowOptionalString(row, "link_state")
+			tables.interfaces[uuid] = ovsdbInterface{
+				name:       rowString(row, "name"),
+				stats:      rowNumberMap(row, "statistics"),
+				linkResets: linkResets,
+				linkSpeed:  linkSpeed,
+				adminUp:    adminState == "up",
+				linkUp:     linkState == "up",
+			}
+		}
+	}
+	return nil
+}
+
+func rowField(row libovsdb.Row, field string) interface{} {
+	value, ok := row.Fields[field]
+	if !ok {
+		panic(fmt.Sprintf("Row update did not include '%v' field", field))
+	}
+	return value
+}
+
+func rowString(row libovsdb.Row, field string) string {
+	return rowField(row, field).(string)
+}
+
+// rowSet returns the elements of a set column. OVSDB encodes sets with exactly one element as the plain element.
+func rowSet(row libovsdb.Row, field string) []interface{} {
+	value := rowField(row, field)
+	if set, ok := value.(libovsdb.OvsSet); ok {
+		return set.GoSet
+	}
+	return []interface{}{value}
+}
+
+func rowUuids(row libovsdb.Row, field string) []string {
+	set := rowSet(row, field)
+	uuids := make([]string, len(set))
+	for i, elem := range set {
+		uuids[i] = elem.(libovsdb.UUID).GoUUID
+	}
+	return uuids
+}
+
+// Optional columns are represented by sets with zero or one element.
+func rowOptionalNumber(row libovsdb.Row, field string) (float64, bool) {
+	if set := rowSet(row, field); len(set) > 0 {
+		return set[0].(float64), true
+	}
+	return 0, false
+}
+
+func rowOptionalString(row libovsdb.Row, field string) (string, bool) {
+	if set := rowSet(row, field); len(set) > 0 {
+		return set[0].(string), true
+	}
+	return "", false
+}
+
+func rowNumberMap(row libovsdb.Row, field string) map[string]float64 {
+	statMap := rowField(row, field).(libovsdb.OvsMap)
+	result := make(map[string]float64, len(statMap.GoMap))
+	for keyObj, valObj := range statMap.GoMap {
+		result[keyObj.(string)] = valObj.(float64)
+	}
+	return result
+}
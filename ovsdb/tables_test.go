@@ -0,0 +1,165 @@
+package ovsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/socketplane/libovsdb"
+	"github.com/stretchr/testify/suite"
+)
+
+type TablesTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestTables(t *testing.T) {
+	suite.Run(t, new(TablesTestSuite))
+}
+
+func uuids(ids ...string) libovsdb.OvsSet {
+	set := libovsdb.OvsSet{}
+	for _, id := range ids {
+		set.GoSet = append(set.GoSet, libovsdb.UUID{GoUUID: id})
+	}
+	return set
+}
+
+func rows(newRows map[string]map[string]interface{}) libovsdb.TableUpdate {
+	update := libovsdb.TableUpdate{Rows: make(map[string]libovsdb.RowUpdate)}
+	for uuid, fields := range newRows {
+		update.Rows[uuid] = libovsdb.RowUpdate{New: libovsdb.Row{Fields: fields}}
+	}
+	return update
+}
+
+func interfaceRow(name string, stats map[interface{}]interface{}, speed interface{}, state string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"statistics":  libovsdb.OvsMap{GoMap: stats},
+		"link_resets": libovsdb.OvsSet{GoSet: []interface{}{float64(2)}},
+		"link_speed":  speed,
+		"admin_state": "up",
+		"link_state":  libovsdb.OvsSet{GoSet: []interface{}{state}},
+	}
+}
+
+func (suite *TablesTestSuite) TestApply() {
+	for _, test := range []struct {
+		name       string
+		updates    []map[string]libovsdb.TableUpdate
+		bridges    map[string]ovsdbBridge
+		ports      map[string]ovsdbPort
+		interfaces map[string]ovsdbInterface
+		err        bool
+	}{
+		{
+			name: "initial state",
+			updates: []map[string]libovsdb.TableUpdate{{
+				"Bridge": rows(map[string]map[string]interface{}{
+					"b1": {"name": "br0", "ports": uuids("p1", "p2")},
+				}),
+				"Port": rows(map[string]map[string]interface{}{
+					"p1": {"name": "eth0", "interfaces": libovsdb.UUID{GoUUID: "i1"}},
+					"p2": {"name": "bond0", "interfaces": uuids("i2", "i3")},
+				}),
+				"Interface": rows(map[string]map[string]interface{}{
+					"i1": interfaceRow("eth0", map[interface{}]interface{}{"rx_bytes": float64(10)}, float64(1e9), "up"),
+					"i2": interfaceRow("eth1", map[interface{}]interface{}{}, libovsdb.OvsSet{}, "down"),
+				}),
+			}},
+			bridges: map[string]ovsdbBridge{"b1": {name: "br0", ports: []string{"p1", "p2"}}},
+			ports: map[string]ovsdbPort{
+				"p1": {name: "eth0", interfaces: []string{"i1"}},
+				"p2": {name: "bond0", interfaces: []string{"i2", "i3"}},
+			},
+			interfaces: map[string]ovsdbInterface{
+				"i1": {name: "eth0", stats: map[string]float64{"rx_bytes": 10}, linkResets: 2, linkSpeed: 1e9, adminUp: true, linkUp: true},
+				"i2": {name: "eth1", stats: map[string]float64{}, linkResets: 2, adminUp: true},
+			},
+		},
+		{
+			name: "modified and deleted rows",
+			updates: []map[string]libovsdb.TableUpdate{
+				{
+					"Bridge": rows(map[string]map[string]interface{}{"b1": {"name": "br0", "ports": uuids("p1")}}),
+					"Port":   rows(map[string]map[string]interface{}{"p1": {"name": "eth0", "interfaces": uuids("i1")}}),
+				},
+				{
+					"Bridge": rows(map[string]map[string]interface{}{"b1": {"name": "br1", "ports": uuids()}}),
+					"Port":   rows(map[string]map[string]interface{}{"p1": {}}),
+				},
+			},
+			bridges:    map[string]ovsdbBridge{"b1": {name: "br1", ports: []string{}}},
+			ports:      map[string]ovsdbPort{},
+			interfaces: map[string]ovsdbInterface{},
+		},
+		{
+			name: "missing field",
+			updates: []map[string]libovsdb.TableUpdate{{
+				"Bridge": rows(map[string]map[string]interface{}{"b1": {"name": "br0"}}),
+			}},
+			err: true,
+		},
+		{
+			name: "wrong type",
+			updates: []map[string]libovsdb.TableUpdate{{
+				"Interface": rows(map[string]map[string]interface{}{
+					"i1": interfaceRow("eth0", map[interface{}]interface{}{"rx_bytes": "10"}, float64(0), "up"),
+				}),
+			}},
+			err: true,
+		},
+	} {
+		tables := newOvsdbTables()
+		var err error
+		for _, update := range test.updates {
+			if err = tables.apply(update); err != nil {
+				break
+			}
+		}
+		if test.err {
+			suite.Error(err, test.name)
+			continue
+		}
+		suite.NoError(err, test.name)
+		suite.Equal(test.bridges, tables.bridges, test.name)
+		suite.Equal(test.ports, tables.ports, test.name)
+		suite.Equal(test.interfaces, tables.interfaces, test.name)
+	}
+}
+
+func (suite *TablesTestSuite) TestPortCollectors() {
+	parent := NewOvsdbCollector("localhost", &collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	parent.tables = newOvsdbTables()
+	parent.portCollectors = make(map[string]*ovsdbPortCollector)
+	updates := map[string]libovsdb.TableUpdate{
+		"Bridge": rows(map[string]map[string]interface{}{"b1": {"name": "br0", "ports": uuids("p1")}}),
+		"Port":   rows(map[string]map[string]interface{}{"p1": {"name": "bond0", "interfaces": uuids("i1", "i2")}}),
+		"Interface": rows(map[string]map[string]interface{}{
+			"i1": interfaceRow("eth0", map[interface{}]interface{}{"rx_bytes": float64(10)}, float64(1e9), "up"),
+			"i2": interfaceRow("eth1", map[interface{}]interface{}{"tx_bytes": float64(5)}, float64(1e9), "down"),
+		}),
+	}
+	suite.NoError(parent.updateTables(false, updates))
+	suite.Len(parent.portCollectors, 1)
+	port := parent.portCollectors["br0/bond0"]
+	suite.NotNil(port)
+	suite.Len(port.stats, 2)
+	suite.Equal(2e9, port.linkSpeed)
+	suite.Equal(2, port.adminUp)
+	suite.Equal(1, port.linkUp)
+
+	// Unchanged ports do not change the metrics
+	suite.NoError(parent.updateTables(true, map[string]libovsdb.TableUpdate{}))
+
+	// New and deleted ports are detected
+	suite.Equal(collector.MetricsChanged, parent.updateTables(true, map[string]libovsdb.TableUpdate{
+		"Bridge": rows(map[string]map[string]interface{}{"b1": {"name": "br0", "ports": uuids("p1", "p2")}}),
+		"Port":   rows(map[string]map[string]interface{}{"p2": {"name": "eth2", "interfaces": uuids()}}),
+	}))
+	suite.Equal(collector.MetricsChanged, parent.updateTables(true, map[string]libovsdb.TableUpdate{
+		"Bridge": rows(map[string]map[string]interface{}{"b1": {"name": "br0", "ports": uuids()}}),
+	}))
+}
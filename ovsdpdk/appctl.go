@@ -0,0 +1,106 @@
+package ovsdpdk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	pmdThreadRegex = regexp.MustCompile(`^pmd thread numa_id (\d+) core_id (\d+):$`)
+	dpctlPortRegex = regexp.MustCompile(`^port \d+: ([^ ]+) \(([^:)]+)`)
+	dpctlStatRegex = regexp.MustCompile(`(\w+):(\d+)`)
+)
+
+// appctl executes the given ovs-appctl command and returns its output
+func (col *Collector) appctl(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, col.Appctl, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v %v failed: %v (%v)", col.Appctl, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// parsePmdStats parses the output of 'dpif-netdev/pmd-stats-show' and 'dpif-netdev/pmd-perf-show'.
+// The result maps the name of every PMD thread (numa<N>-core<C>) to its statistics. The statistics of the
+// main thread are ignored, since it does not perform any polling. The numeric value of every line
+// of the form 'key: value (...)' is included under the given key.
+func parsePmdStats(output []byte) map[string]map[string]float64 {
+	res := make(map[string]map[string]float64)
+	var current map[string]float64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := pmdThreadRegex.FindStringSubmatch(line); match != nil {
+			name := "numa" + match[1] + "-core" + match[2]
+			if current = res[name]; current == nil {
+				current = make(map[string]float64)
+				res[name] = current
+			}
+			continue
+		} else if strings.HasSuffix(line, "thread:") {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		index := strings.IndexRune(line, ':')
+		if index < 0 {
+			continue
+		}
+		key := strings.TrimSpace(strings.TrimPrefix(line[:index], "- "))
+		valueFields := strings.Fields(line[index+1:])
+		if key == "" || len(valueFields) == 0 {
+			continue
+		}
+		if value, err := strconv.ParseFloat(valueFields[0], 64); err == nil {
+			current[key] = value
+		}
+	}
+	return res
+}
+
+// parseVhostPortStats parses the output of 'dpctl/show -s' and returns the statistics of all vhost-user ports.
+// The keys of the result are the names of the ports, the statistics are prefixed with RX or TX (e.g. "RX dropped").
+func parseVhostPortStats(output []byte) map[string]map[string]float64 {
+	res := make(map[string]map[string]float64)
+	var current map[string]float64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := dpctlPortRegex.FindStringSubmatch(line); match != nil {
+			current = nil
+			if strings.HasPrefix(match[2], "dpdkvhostuser") {
+				current = make(map[string]float64)
+				res[match[1]] = current
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		var direction string
+		if strings.HasPrefix(line, "RX packets:") {
+			direction = "RX"
+		} else if strings.HasPrefix(line, "TX packets:") {
+			direction = "TX"
+		} else {
+			continue
+		}
+		for _, match := range dpctlStatRegex.FindAllStringSubmatch(line, -1) {
+			if value, err := strconv.ParseFloat(match[2], 64); err == nil {
+				current[direction+" "+match[1]] = value
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,115 @@
+package ovsdpdk
+
+import (
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppctlTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestAppctl(t *testing.T) {
+	suite.Run(t, new(AppctlTestSuite))
+}
+
+func (suite *AppctlTestSuite) TestParsePmdStats() {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected map[string]map[string]float64
+	}{
+		{
+			name: "pmd-stats-show",
+			output: "pmd thread numa_id 0 core_id 1:\n" +
+				"  packets received: 1000\n" +
+				"  packet recirculations: 0\n" +
+				"  avg. datapath passes per packet: 1.00\n" +
+				"  emc hits: 900\n" +
+				"  idle cycles: 500 (50.00%)\n" +
+				"  processing cycles: 500 (50.00%)\n" +
+				"main thread:\n" +
+				"  packets received: 7\n" +
+				"pmd thread numa_id 1 core_id 9:\n" +
+				"  packets received: 0\n" +
+				"  avg cycles per packet: N/A\n",
+			expected: map[string]map[string]float64{
+				"numa0-core1": {
+					"packets received":                1000,
+					"packet recirculations":           0,
+					"avg. datapath passes per packet": 1,
+					"emc hits":                        900,
+					"idle cycles":                     500,
+					"processing cycles":               500,
+				},
+				"numa1-core9": {"packets received": 0},
+			},
+		},
+		{
+			name: "pmd-perf-show",
+			output: "Time: 12:00:00.000\n" +
+				"Measurement duration: 10.000 s\n" +
+				"\n" +
+				"pmd thread numa_id 0 core_id 1:\n" +
+				"\n" +
+				"  Iterations:             1000  (10.00 us/it)\n" +
+				"  - Used TSC cycles:      2000  ( 99.0 % of total cycles)\n" +
+				"  - idle iterations:       800  ( 40.0 % of used cycles)\n",
+			expected: map[string]map[string]float64{
+				"numa0-core1": {
+					"Iterations":      1000,
+					"Used TSC cycles": 2000,
+					"idle iterations": 800,
+				},
+			},
+		},
+		{
+			name:     "no pmd threads",
+			output:   "main thread:\n  packets received: 7\n",
+			expected: map[string]map[string]float64{},
+		},
+	} {
+		suite.Equal(test.expected, parsePmdStats([]byte(test.output)), test.name)
+	}
+}
+
+func (suite *AppctlTestSuite) TestParseVhostPortStats() {
+	for _, test := range []struct {
+		name     string
+		output   string
+		expected map[string]map[string]float64
+	}{
+		{
+			name: "vhost-user ports",
+			output: "netdev@ovs-netdev:\n" +
+				"  lookups: hit:0 missed:0 lost:0\n" +
+				"  port 0: ovs-netdev (tap)\n" +
+				"    RX packets:0 errors:0 dropped:0 overruns:0 frame:0\n" +
+				"  port 1: vhost-vm1 (dpdkvhostuser: configured_rx_queues=1)\n" +
+				"    RX packets:100 errors:0 dropped:3 overruns:? frame:?\n" +
+				"    TX packets:200 errors:1 dropped:4 aborted:? carrier:?\n" +
+				"    collisions:?\n" +
+				"    RX bytes:6400  TX bytes:12800\n" +
+				"  port 2: vhost-vm2 (dpdkvhostuserclient: configured_rx_queues=1)\n" +
+				"    TX packets:5 errors:0 dropped:0 aborted:? carrier:?\n" +
+				"  port 3: dpdk0 (dpdk: configured_rx_queues=2)\n" +
+				"    RX packets:1000 errors:0 dropped:0 overruns:? frame:?\n",
+			expected: map[string]map[string]float64{
+				"vhost-vm1": {
+					"RX packets": 100, "RX errors": 0, "RX dropped": 3,
+					"TX packets": 200, "TX errors": 1, "TX dropped": 4,
+				},
+				"vhost-vm2": {"TX packets": 5, "TX errors": 0, "TX dropped": 0},
+			},
+		},
+		{
+			name:     "no ports",
+			output:   "netdev@ovs-netdev:\n  lookups: hit:0 missed:0 lost:0\n",
+			expected: map[string]map[string]float64{},
+		},
+	} {
+		suite.Equal(test.expected, parseVhostPortStats([]byte(test.output)), test.name)
+	}
+}
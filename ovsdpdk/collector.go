@@ -0,0 +1,202 @@
+package ovsdpdk
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultAppctl = "ovs-appctl"
+
+type counter struct {
+	key         string
+	metric      string
+	description string
+}
+
+var (
+	pmdCounters = []counter{
+		{"packets received", "packets", "Packets received by the PMD thread"},
+		{"idle cycles", "cycles/idle", "CPU cycles spent for polling without receiving packets"},
+		{"processing cycles", "cycles/processing", "CPU cycles spent for processing received packets"},
+		{"emc hits", "emc-hits", "Packets matched in the exact match cache"},
+		{"smc hits", "smc-hits", "Packets matched in the signature match cache"},
+		{"megaflow hits", "megaflow-hits", "Packets matched in the megaflow classifier"},
+		{"miss with success upcall", "upcalls", "Packets that required a successful upcall"},
+		{"miss with failed upcall", "failed-upcalls", "Packets that required an upcall that failed"},
+		{"busy iterations", "iterations/busy", "Polling iterations that received at least one packet"},
+		{"idle iterations", "iterations/idle", "Polling iterations without any received packet"},
+	}
+	vhostCounters = []counter{
+		{"RX packets", "rx-packets", "Packets received from the VM"},
+		{"TX packets", "tx-packets", "Packets sent to the VM"},
+		{"RX dropped", "rx-dropped", "Packets from the VM that were dropped"},
+		{"TX dropped", "tx-dropped", "Packets to the VM that were dropped, usually due to full vhost queues"},
+	}
+)
+
+// Collector reads the statistics of the poll mode driver (PMD) threads and vhost-user ports of an OVS-DPDK
+// datapath through ovs-appctl. The busy percentage of the PMD threads reveals their saturation, which is
+// hidden by the regular interface counters. PMD metrics are reported as ovs-dpdk/pmd/numa<N>-core<C>/...,
+// vhost-user port metrics as ovs-dpdk/vhost/<port>/...
+type Collector struct {
+	collector.AbstractCollector
+	Appctl  string
+	factory *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	threads  map[string]bool
+	metadata collector.MetricMetadataMap
+}
+
+func NewOvsDpdkCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("ovs-dpdk"),
+		Appctl:            DefaultAppctl,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+2*len(col.threads))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for thread := range col.threads {
+		prefix := "ovs-dpdk/pmd/" + thread + "/"
+		if col.hasCounters(prefix+"cycles/processing", prefix+"cycles/idle") {
+			res[prefix+"busy"] = col.ratio(prefix+"cycles/processing", prefix+"cycles/idle", true)
+		}
+		if col.hasCounters(prefix+"packets", prefix+"iterations/busy") {
+			res[prefix+"rx-batch-size"] = col.ratio(prefix+"packets", prefix+"iterations/busy", false)
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) hasCounters(names ...string) bool {
+	for _, name := range names {
+		if _, ok := col.counters[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ratio returns a reader for the ratio of the rates of two counters. If percent is set, the result is the
+// percentage of the first counter in the sum of both counters.
+func (col *Collector) ratio(counter, other string, percent bool) collector.MetricReader {
+	counterRing, otherRing := col.counters[counter], col.counters[other]
+	return func() bitflow.Value {
+		value, otherValue := counterRing.GetDiff(), otherRing.GetDiff()
+		if percent {
+			otherValue += value
+		}
+		if otherValue == 0 {
+			return 0
+		}
+		if percent {
+			return value / otherValue * 100
+		}
+		return value / otherValue
+	}
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	output, err := col.appctl(ctx, "dpif-netdev/pmd-stats-show")
+	if err != nil {
+		return err
+	}
+	pmdStats := parsePmdStats(output)
+	if output, err := col.appctl(ctx, "dpif-netdev/pmd-perf-show"); err != nil {
+		// Not supported by older OVS versions, only the rx batch sizes are missing in that case
+		log.Debugln("Failed to query PMD performance statistics:", err)
+	} else {
+		for thread, stats := range parsePmdStats(output) {
+			if existing, ok := pmdStats[thread]; ok {
+				for key, value := range stats {
+					existing[key] = value
+				}
+			}
+		}
+	}
+	output, err = col.appctl(ctx, "dpctl/show", "-s")
+	if err != nil {
+		return err
+	}
+	vhostStats := parseVhostPortStats(output)
+
+	values := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	threads := make(map[string]bool, len(pmdStats))
+	addCounters := func(prefix string, stats map[string]float64, counters []counter) {
+		for _, counter := range counters {
+			if value, ok := stats[counter.key]; ok {
+				values[prefix+counter.metric] = value
+				metadata[prefix+counter.metric] = collector.DerivedMetric(collector.UnitPerSecond, counter.description)
+			}
+		}
+	}
+	for thread, stats := range pmdStats {
+		prefix := "ovs-dpdk/pmd/" + thread + "/"
+		threads[thread] = true
+		addCounters(prefix, stats, pmdCounters)
+		metadata[prefix+"busy"] = collector.GaugeMetric(collector.UnitPercent, "Share of CPU cycles used for processing packets instead of idle polling")
+		metadata[prefix+"rx-batch-size"] = collector.GaugeMetric(collector.UnitCount, "Average number of packets received per busy polling iteration")
+	}
+	for port, stats := range vhostStats {
+		addCounters("ovs-dpdk/vhost/"+port+"/", stats, vhostCounters)
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(values) != len(col.counters)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(values))
+	}
+	for name, value := range values {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := values[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	col.threads = threads
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// StaleTag is set in samples emitted while the update round of some collectors was not finished, if
+// SampleSource.PartialSamples is enabled. The value is a comma-separated list of the names of the late collectors.
+const StaleTag = "stale"
+
+// setRoundPending marks whether the node still has to be updated in the currently running update round
+func (node *collectorNode) setRoundPending(pending bool) {
+	var val int32
+	if pending {
+		val = 1
+	}
+	atomic.StoreInt32(&node.roundPending, val)
+}
+
+// partialReader returns a reader that repeats the previous value of the metric while the node is late,
+// instead of reading a possibly incomplete or blocked value. The node.late flag is only accessed by the sink routine.
+func (node *collectorNode) partialReader(reader MetricReader) MetricReader {
+	var last bitflow.Value
+	hasLast := false
+	return func() bitflow.Value {
+		if !node.late || !hasLast {
+			last, hasLast = reader(), true
+		}
+		return last
+	}
+}
+
+// sampleNodes returns the nodes of all metrics in the slice, which are checked by markLateNodes()
+func (s MetricSlice) sampleNodes() []*collectorNode {
+	contained := make(map[*collectorNode]bool)
+	var res []*collectorNode
+	for _, metric := range s {
+		if metric.node != nil && !contained[metric.node] {
+			contained[metric.node] = true
+			res = append(res, metric.node)
+		}
+	}
+	return res
+}
+
+// markLateNodes sets the late flag of all nodes that have not finished the current update round,
+// and returns the sorted names of the late nodes.
+func markLateNodes(nodes []*collectorNode) []string {
+	var late []string
+	for _, node := range nodes {
+		node.late = atomic.LoadInt32(&node.roundPending) != 0
+		if node.late {
+			late = append(late, node.String())
+		}
+	}
+	sort.Strings(late)
+	return late
+}
+
+func setStaleTag(sample *bitflow.Sample, late []string) {
+	if len(late) > 0 {
+		sample.SetTag(StaleTag, strings.Join(late, ","))
+	}
+}
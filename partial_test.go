@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+func (suite *SchedulerTestSuite) TestPartialSamples() {
+	l := newUpdateLog()
+	fast := &mockCollector{AbstractCollector: RootCollector("fast"), log: l}
+	slow := &mockCollector{AbstractCollector: RootCollector("slow"), log: l}
+	var value bitflow.Value
+	graph, err := initCollectorGraph(context.Background(), []Collector{fast, slow}, nil)
+	suite.NoError(err)
+	metrics := graph.getMetrics()
+	for _, metric := range metrics {
+		if metric.node.String() == "slow" {
+			metric.reader = func() bitflow.Value {
+				return value
+			}
+		}
+	}
+	value = 1
+	fields, getValues := metrics.ConstructSample(&SampleSource{PartialSamples: true}, 0)
+	suite.Equal([]string{"fast", "slow"}, fields)
+	nodes := metrics.sampleNodes()
+	suite.Len(nodes, 2)
+
+	suite.Empty(markLateNodes(nodes))
+	suite.Equal([]bitflow.Value{0, 1}, getValues())
+
+	finished := make(chan bool)
+	slow.onUpdate = func() {
+		time.Sleep(50 * time.Millisecond)
+		value = 2
+	}
+	go func() {
+		newUpdateScheduler(graph, 0).runRound(context.Background(), golib.NewStopChan())
+		close(finished)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	suite.Equal([]string{"slow"}, markLateNodes(nodes))
+	suite.Equal([]bitflow.Value{0, 1}, getValues())
+
+	<-finished
+	suite.Empty(markLateNodes(nodes))
+	suite.Equal([]bitflow.Value{0, 2}, getValues())
+}
@@ -0,0 +1,20 @@
+// +build !cgo,!nopcap
+
+package pcap_impl
+
+import (
+	"errors"
+
+	"github.com/bitflow-stream/go-bitflow-collector/pcap"
+)
+
+// ErrNoCgo is returned when capturing packets in binaries built without cgo, e.g. static builds
+var ErrNoCgo = errors.New("Packet capturing is not supported by this binary, because it was built without cgo")
+
+func OpenSources(_ string, _ []string, _ bool) ([]pcap.PacketSource, error) {
+	return nil, ErrNoCgo
+}
+
+func TestLiveCapture(_ []string) error {
+	return ErrNoCgo
+}
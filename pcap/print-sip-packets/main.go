@@ -1,4 +1,4 @@
-// +build !nopcap
+// +build cgo,!nopcap
 
 package main
 
@@ -1,9 +1,9 @@
-// +build nopcap
+// +build !cgo nopcap
 
 package main
 
 import log "github.com/sirupsen/logrus"
 
 func main() {
-	log.Fatalln("This package cannot be built with the 'nopcap' build tag")
+	log.Fatalln("This package cannot be built with the 'nopcap' build tag or without cgo")
 }
@@ -0,0 +1,181 @@
+// Package pipeline makes the collector available as a data source inside bitflow-script pipelines,
+// so that metrics can be collected without running a separate bitflow-collector process.
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/psutil"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/bitflow-stream/go-bitflow/script/reg"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Default values for the data sources created through RegisterCollectSource
+	DefaultCollectInterval = 500 * time.Millisecond
+	DefaultSinkInterval    = 500 * time.Millisecond
+	DefaultRingInterval    = 1000 * time.Millisecond
+
+	FailedCollectorCheckInterval    = 5 * time.Second
+	FailedCollectorMaxCheckInterval = 2 * time.Minute
+	FilteredCollectorCheckInterval  = 3 * time.Second
+)
+
+// SourceConfig contains the parameters of a collector data source, as parsed from an endpoint description like
+// collect://?ci=1s&si=1s&include=^cpu&exclude=^net-proto/&proc=db=postgres&proc-children=web=nginx
+// The parameters include, exclude, disable, proc and proc-children can be repeated.
+type SourceConfig struct {
+	CollectInterval time.Duration
+	SinkInterval    time.Duration
+
+	// Time window for computing rates of counter metrics
+	RingInterval time.Duration
+
+	IncludeMetrics     []*regexp.Regexp
+	ExcludeMetrics     []*regexp.Regexp
+	DisabledCollectors []string
+
+	// Prefix of all metric names, see collector.SampleSource.RegisterPrefixedCollector()
+	MetricPrefix string
+
+	Processes         []psutil.ProcessCollectorDescription
+	PidUpdateInterval time.Duration
+}
+
+// ParseSourceConfig parses the target of a collect:// endpoint, i.e. the part after ://
+func ParseSourceConfig(target string) (*SourceConfig, error) {
+	config := &SourceConfig{
+		CollectInterval:   DefaultCollectInterval,
+		SinkInterval:      DefaultSinkInterval,
+		RingInterval:      DefaultRingInterval,
+		PidUpdateInterval: psutil.PidUpdateInterval,
+	}
+	query := target
+	if index := strings.IndexRune(target, '?'); index >= 0 {
+		if target[:index] != "" {
+			return nil, fmt.Errorf("Unexpected address '%v', expected format: ?<param>=<value>&...", target[:index])
+		}
+		query = target[index+1:]
+	} else if target != "" {
+		return nil, fmt.Errorf("Unexpected address '%v', expected format: ?<param>=<value>&...", target)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Create the process collectors in a deterministic order
+	for _, key := range keys {
+		for _, value := range params[key] {
+			if err := config.setParam(key, value); err != nil {
+				return nil, reg.ParameterError(key, err)
+			}
+		}
+	}
+	return config, nil
+}
+
+func (config *SourceConfig) setParam(key, value string) error {
+	var err error
+	switch key {
+	case "ci":
+		config.CollectInterval, err = time.ParseDuration(value)
+	case "si":
+		config.SinkInterval, err = time.ParseDuration(value)
+	case "ring-interval":
+		config.RingInterval, err = time.ParseDuration(value)
+	case "proc-update-pids":
+		config.PidUpdateInterval, err = time.ParseDuration(value)
+	case "include":
+		var regex *regexp.Regexp
+		if regex, err = regexp.Compile(value); err == nil {
+			config.IncludeMetrics = append(config.IncludeMetrics, regex)
+		}
+	case "exclude":
+		var regex *regexp.Regexp
+		if regex, err = regexp.Compile(value); err == nil {
+			config.ExcludeMetrics = append(config.ExcludeMetrics, regex)
+		}
+	case "disable":
+		config.DisabledCollectors = append(config.DisabledCollectors, value)
+	case "prefix":
+		config.MetricPrefix = value
+	case "proc", "proc-children":
+		index := strings.IndexRune(value, '=')
+		if index <= 0 {
+			return fmt.Errorf("Expected format <name>=<regex>, have '%v'", value)
+		}
+		var regex *regexp.Regexp
+		if regex, err = regexp.Compile(value[index+1:]); err == nil {
+			config.Processes = append(config.Processes, psutil.ProcessCollectorDescription{
+				Name:                  value[:index],
+				Filter:                []*regexp.Regexp{regex},
+				IncludeChildProcesses: key == "proc-children",
+			})
+		}
+	default:
+		return fmt.Errorf("Unknown parameter, available: ci, si, ring-interval, include, exclude, disable, prefix, proc, proc-children, proc-update-pids")
+	}
+	return err
+}
+
+// NewSampleSource creates a SampleSource with the psutil collectors and the configured process groups
+func (config *SourceConfig) NewSampleSource() (*collector.SampleSource, error) {
+	if config.CollectInterval <= 0 || config.SinkInterval <= 0 || config.RingInterval <= 0 {
+		return nil, fmt.Errorf("The parameters ci, si and ring-interval must be positive (have %v, %v, %v)",
+			config.CollectInterval, config.SinkInterval, config.RingInterval)
+	}
+	factory := &collector.ValueRingFactory{
+		Interval: config.RingInterval,
+		Length:   int(float64(config.RingInterval) / float64(config.CollectInterval) * 10), // Make sure enough samples can be buffered
+	}
+	if factory.Length <= 0 {
+		factory.Length = 1
+	}
+	source := &collector.SampleSource{
+		CollectInterval:    config.CollectInterval,
+		SinkInterval:       config.SinkInterval,
+		IncludeMetrics:     config.IncludeMetrics,
+		ExcludeMetrics:     config.ExcludeMetrics,
+		DisabledCollectors: config.DisabledCollectors,
+		RingFactory:        factory,
+
+		FailedCollectorCheckInterval:    FailedCollectorCheckInterval,
+		FailedCollectorMaxCheckInterval: FailedCollectorMaxCheckInterval,
+		FilteredCollectorCheckInterval:  FilteredCollectorCheckInterval,
+	}
+	psutilRoot := psutil.NewPsutilRootCollector(factory)
+	psutilRoot.PidUpdateInterval = config.PidUpdateInterval
+	psutilProcesses := psutilRoot.NewMultiProcessCollector("processes")
+	psutilProcesses.Processes = config.Processes
+	psutilProcesses.UpdateProcesses()
+	for _, col := range []collector.Collector{psutilRoot, psutilProcesses} {
+		if err := source.RegisterPrefixedCollector(config.MetricPrefix, col); err != nil {
+			return nil, err
+		}
+	}
+	return source, nil
+}
+
+// RegisterCollectSource makes the collector available as data source of the given endpoint type, e.g.
+// "collect://?ci=1s&proc=db=postgres" -> avg() -> output.csv
+func RegisterCollectSource(endpointType string, registry reg.ProcessorRegistry) {
+	registry.Endpoints.CustomDataSources[bitflow.EndpointType(endpointType)] = func(target string) (bitflow.SampleSource, error) {
+		config, err := ParseSourceConfig(target)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("Creating %v data source with parameters %v", endpointType, target)
+		return config.NewSampleSource()
+	}
+}
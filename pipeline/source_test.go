@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/bitflow-stream/go-bitflow/script/reg"
+	"github.com/stretchr/testify/suite"
+)
+
+type SourceTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSource(t *testing.T) {
+	suite.Run(t, new(SourceTestSuite))
+}
+
+func (suite *SourceTestSuite) TestParseDefaults() {
+	for _, target := range []string{"", "?"} {
+		config, err := ParseSourceConfig(target)
+		suite.NoError(err)
+		suite.Equal(DefaultCollectInterval, config.CollectInterval)
+		suite.Equal(DefaultSinkInterval, config.SinkInterval)
+		suite.Equal(DefaultRingInterval, config.RingInterval)
+		suite.Empty(config.Processes)
+	}
+}
+
+func (suite *SourceTestSuite) TestParse() {
+	config, err := ParseSourceConfig("?ci=1s&si=2s&ring-interval=3s&include=^cpu&include=^mem&exclude=^net-proto/&disable=psutil/pcap" +
+		"&proc=db=postgres&proc-children=web=nginx|apache&proc-update-pids=10s&prefix=hv1")
+	suite.NoError(err)
+	suite.Equal(time.Second, config.CollectInterval)
+	suite.Equal(2*time.Second, config.SinkInterval)
+	suite.Equal(3*time.Second, config.RingInterval)
+	suite.Equal(10*time.Second, config.PidUpdateInterval)
+	suite.Len(config.IncludeMetrics, 2)
+	suite.Equal("^cpu", config.IncludeMetrics[0].String())
+	suite.Equal("^mem", config.IncludeMetrics[1].String())
+	suite.Len(config.ExcludeMetrics, 1)
+	suite.Equal([]string{"psutil/pcap"}, config.DisabledCollectors)
+	suite.Equal("hv1", config.MetricPrefix)
+	suite.Len(config.Processes, 2)
+	suite.Equal("db", config.Processes[0].Name)
+	suite.False(config.Processes[0].IncludeChildProcesses)
+	suite.Equal("web", config.Processes[1].Name)
+	suite.Equal("nginx|apache", config.Processes[1].Filter[0].String())
+	suite.True(config.Processes[1].IncludeChildProcesses)
+}
+
+func (suite *SourceTestSuite) TestParseErrors() {
+	for _, target := range []string{
+		"host",
+		"host?ci=1s",
+		"?ci=xx",
+		"?include=(",
+		"?proc=postgres",
+		"?proc==postgres",
+		"?unknown=1",
+	} {
+		_, err := ParseSourceConfig(target)
+		suite.Error(err, target)
+	}
+}
+
+func (suite *SourceTestSuite) TestNewSampleSource() {
+	config, err := ParseSourceConfig("?proc=db=postgres")
+	suite.NoError(err)
+	source, err := config.NewSampleSource()
+	suite.NoError(err)
+	suite.Len(source.RootCollectors, 2)
+
+	config.CollectInterval = 0
+	_, err = config.NewSampleSource()
+	suite.Error(err)
+}
+
+func (suite *SourceTestSuite) TestRegister() {
+	registry := reg.NewProcessorRegistry(bitflow.NewEndpointFactory())
+	RegisterCollectSource("collect", registry)
+	source, err := registry.Endpoints.CreateInput("collect://?ci=1s")
+	suite.NoError(err)
+	suite.IsType(new(collector.SampleSource), source)
+	suite.Equal(time.Second, source.(*collector.SampleSource).CollectInterval)
+
+	_, err = registry.Endpoints.CreateInput("collect://?ci=-")
+	suite.Error(err)
+}
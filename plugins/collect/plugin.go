@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/bitflow-stream/go-bitflow-collector/pipeline"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/bitflow-stream/go-bitflow/script/plugin"
+	"github.com/bitflow-stream/go-bitflow/script/reg"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	log.Fatalln("This package is intended to be loaded as a plugin, not executed directly")
+}
+
+// The Symbol to be loaded
+var Plugin plugin.BitflowPlugin = new(pluginImpl)
+
+type pluginImpl struct {
+}
+
+func (*pluginImpl) Name() string {
+	return "collect-plugin"
+}
+
+func (p *pluginImpl) Init(registry reg.ProcessorRegistry) error {
+	plugin.LogPluginDataSource(p, bitflow.EndpointType("collect"))
+	pipeline.RegisterCollectSource("collect", registry)
+	return nil
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegisterPrefixedCollector adds a root collector to this SampleSource, like RegisterCollector(). The names of all
+// metrics produced by the collector and its sub-collectors are prefixed with the given prefix and a slash, e.g.
+// "hv1/libvirt/...". This allows registering multiple instances of the same collector, or distinguishing the
+// metrics of multiple sources. The prefix is applied before filtering the metrics, so the metric filters must match
+// the prefixed names. Collectors can be disabled through their prefixed name (e.g. "hv1/libvirt") or their plain
+// name, which disables all instances. The combination of prefix and name must be unique among all root collectors.
+// An empty prefix leaves the metric names unchanged. Must be called before starting the SampleSource.
+func (source *SampleSource) RegisterPrefixedCollector(prefix string, col Collector) error {
+	prefix = strings.Trim(prefix, "/")
+	name := prefixedName(prefix, col.String())
+	for _, existing := range source.RootCollectors {
+		if source.rootName(existing) == name {
+			return fmt.Errorf("A root collector named '%v' is already registered", name)
+		}
+	}
+	if prefix != "" {
+		if source.prefixes == nil {
+			source.prefixes = make(map[Collector]string)
+		}
+		source.prefixes[col] = prefix
+	}
+	source.RootCollectors = append(source.RootCollectors, col)
+	return nil
+}
+
+func (source *SampleSource) rootName(col Collector) string {
+	return prefixedName(source.prefixes[col], col.String())
+}
+
+func prefixedName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// applyPrefix renames the metrics of the node after initializing it, so that all further processing steps like
+// filtering already see the prefixed names.
+func (node *collectorNode) applyPrefix() {
+	if node.prefix == "" {
+		return
+	}
+	metrics := make(MetricReaderMap, len(node.metrics))
+	for name, reader := range node.metrics {
+		metrics[prefixedName(node.prefix, name)] = reader
+	}
+	metadata := make(MetricMetadataMap, len(node.metadata))
+	for name, meta := range node.metadata {
+		metadata[prefixedName(node.prefix, name)] = meta
+	}
+	node.metrics, node.metadata = metrics, metadata
+}
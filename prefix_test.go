@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+)
+
+func (suite *SchedulerTestSuite) TestPrefixedCollectors() {
+	l := newUpdateLog()
+	newRoot := func() *mockCollector {
+		root := suite.newCollector(l, "root", nil)
+		suite.newCollector(l, "a", root)
+		suite.newCollector(l, "b", root)
+		return root
+	}
+	source := &SampleSource{
+		ExcludeMetrics:     []*regexp.Regexp{regexp.MustCompile("^hv2/root/a$")},
+		DisabledCollectors: []string{"hv1/root/b"},
+	}
+	suite.NoError(source.RegisterPrefixedCollector("hv1", newRoot()))
+	suite.NoError(source.RegisterPrefixedCollector("hv2/", newRoot()))
+	suite.Error(source.RegisterPrefixedCollector("hv1", newRoot()))
+	suite.NoError(source.RegisterCollector(newRoot()))
+	suite.Error(source.RegisterCollector(newRoot()))
+
+	graph, err := source.createFilteredGraph(context.Background())
+	suite.NoError(err)
+	fields, _ := graph.getMetrics().ConstructSample(source, 0)
+	suite.Equal([]string{"hv1/root", "hv1/root/a", "hv2/root", "hv2/root/b", "root", "root/a", "root/b"}, fields)
+
+	// The plain collector name disables all instances
+	source.DisabledCollectors = []string{"root/a"}
+	graph, err = source.createFilteredGraph(context.Background())
+	suite.NoError(err)
+	fields, _ = graph.getMetrics().ConstructSample(source, 0)
+	suite.Equal([]string{"hv1/root", "hv1/root/b", "hv2/root", "hv2/root/b", "root", "root/b"}, fields)
+}
@@ -0,0 +1,17 @@
+// +build !windows
+
+package collector
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCpuTime returns the CPU time (user and system) consumed by the current process.
+func processCpuTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, err
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
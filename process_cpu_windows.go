@@ -0,0 +1,10 @@
+package collector
+
+import (
+	"errors"
+	"time"
+)
+
+func processCpuTime() (time.Duration, error) {
+	return 0, errors.New("Measuring the CPU time of the process is not supported on Windows")
+}
@@ -0,0 +1,114 @@
+package psutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+)
+
+// containerIdRegex matches the container IDs in the cgroup paths of Docker, containerd, CRI-O and Podman,
+// e.g. /docker/<id> or /kubepods/.../cri-containerd-<id>.scope
+var containerIdRegex = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// cgroupDir is the directory of a process in the hierarchy of a cgroup v1 controller, or in the unified hierarchy of cgroup v2
+type cgroupDir struct {
+	dir string
+	v2  bool
+}
+
+// cgroupsAvailable returns true if the cgroup filesystem is mounted
+func cgroupsAvailable() bool {
+	_, err := os.Stat(hostfs.Current().SysPath("fs", "cgroup"))
+	return err == nil
+}
+
+// cgroups returns the distinct cgroups of the given cgroup v1 controller that contain the processes of the collector.
+// Errors are expected for processes that disappear and are only reported through processError().
+func (col *ProcessCollector) cgroups(controller string) map[cgroupDir]bool {
+	col.procsLock.RLock()
+	defer col.procsLock.RUnlock()
+	res := make(map[cgroupDir]bool)
+	for pid := range col.procs {
+		cgroup, err := col.root.snapshot.cgroup(pid, controller)
+		if err != nil {
+			col.processError(fmt.Errorf("Failed to get %v cgroup: %v", controller, err))
+			continue
+		}
+		res[cgroup] = true
+	}
+	return res
+}
+
+func readProcCgroupFile(pid int32) (string, error) {
+	data, err := ioutil.ReadFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "cgroup"))
+	return string(data), err
+}
+
+// parseContainerId returns the short ID (12 characters) of the container in the content of a /proc/<pid>/cgroup file,
+// or an empty string if the process does not run in a container
+func parseContainerId(content string) string {
+	ids := containerIdRegex.FindAllString(content, -1)
+	if len(ids) == 0 {
+		return ""
+	}
+	// Use the innermost container in case of nested cgroups
+	return ids[len(ids)-1][:12]
+}
+
+// parseProcCgroup returns the directory of the given cgroup v1 controller below the cgroup root (usually /sys/fs/cgroup),
+// based on the content of a /proc/<pid>/cgroup file. The cgroup v1 controller is preferred over the unified hierarchy,
+// because in the hybrid layout, the cgroup v2 controllers are not available if they are mounted as cgroup v1.
+func parseProcCgroup(cgroupRoot string, content string, controller string) (cgroupDir, error) {
+	unified, hasUnified := "", false
+	for _, line := range strings.Split(content, "\n") {
+		// Format: hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			unified, hasUnified = parts[2], true
+			continue
+		}
+		for _, name := range strings.Split(parts[1], ",") {
+			if name == controller {
+				return cgroupDir{dir: filepath.Join(cgroupRoot, controller, parts[2])}, nil
+			}
+		}
+	}
+	if !hasUnified {
+		return cgroupDir{}, fmt.Errorf("Neither the %v cgroup nor the unified cgroup hierarchy is available", controller)
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		// Hybrid layout: the unified hierarchy is mounted below the cgroup v1 controllers
+		cgroupRoot = filepath.Join(cgroupRoot, "unified")
+	}
+	return cgroupDir{dir: filepath.Join(cgroupRoot, unified), v2: true}, nil
+}
+
+// readCgroupStatFile parses the given cgroup file with one "key value" pair per line, like cpu.stat
+func readCgroupStatFile(filename string) (map[string]uint64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]uint64)
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+		res[fields[0]] = value
+	}
+	return res, nil
+}
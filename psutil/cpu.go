@@ -1,7 +1,7 @@
 package psutil
 
 import (
-	"fmt"
+	"context"
 	"time"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
@@ -24,7 +24,7 @@ func newCpuCollector(root *RootCollector) *CpuCollector {
 	}
 }
 
-func (col *CpuCollector) Init() ([]collector.Collector, error) {
+func (col *CpuCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	col.cpuTimes = col.factory.NewValueRing()
 	col.cpuJiffies = col.factory.NewValueRing()
 	return nil, nil
@@ -34,22 +34,39 @@ func (col *CpuCollector) Metrics() collector.MetricReaderMap {
 	return collector.MetricReaderMap{
 		"cpu":         col.cpuTimes.GetDiff,
 		"cpu-jiffies": col.cpuJiffies.GetDiff,
+		"cpu/count":   readCpuCount,
 	}
 }
 
-func (col *CpuCollector) Update() (err error) {
-	times, err := cpu.Times(false)
+func (col *CpuCollector) StaticMetrics() []string {
+	return []string{"cpu/count"}
+}
+
+func (col *CpuCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"cpu":         collector.DerivedMetric(collector.UnitPercent, "Overall CPU utilization"),
+		"cpu-jiffies": collector.DerivedMetric("jiffies/sec", "Busy CPU time, summed over all CPUs"),
+		"cpu/count":   collector.GaugeMetric(collector.UnitCount, "Number of logical CPUs"),
+	}
+}
+
+func (col *CpuCollector) Update(ctx context.Context) error {
+	times, err := readCpuTimes()
 	if err == nil {
-		if len(times) != 1 {
-			err = fmt.Errorf("gopsutil/cpu.Times() returned %v cpu.TimesStat instead of %v", len(times), 1)
-		} else {
-			ct := cpuTime{times[0]}
-			col.cpuTimes.Add(&ct)
-			_, busy := ct.getAllBusy()
-			col.cpuJiffies.Add(collector.StoredValue(busy))
-		}
+		ct := cpuTime{times}
+		col.cpuTimes.Add(&ct)
+		_, busy := ct.getAllBusy()
+		col.cpuJiffies.Add(collector.StoredValue(busy))
+	}
+	return err
+}
+
+func readCpuCount() bitflow.Value {
+	count, err := cpu.Counts(true)
+	if err != nil {
+		log.Warnln("Failed to read the number of CPUs:", err)
 	}
-	return
+	return bitflow.Value(count)
 }
 
 type cpuTime struct {
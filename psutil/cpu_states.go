@@ -0,0 +1,203 @@
+package psutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+)
+
+// cpuStateResidency contains the time a CPU spent in its idle states (C-states) and at its frequencies (P-states),
+// converted to hundredths of seconds, so that the rate of the values is the residency in percent
+type cpuStateResidency struct {
+	idle map[string]float64 // Idle state name -> time
+	freq map[string]float64 // Frequency in MHz -> time
+}
+
+// CpuStatesCollector reports the share of time every CPU spent in its idle states and at its frequencies, based on
+// the cpuidle and cpufreq statistics in /sys/devices/system/cpu. Metrics are named cpu-states/<cpu>/idle/<state>,
+// e.g. cpu-states/0/idle/C6, and cpu-states/<cpu>/freq/<MHz>. The frequency statistics are maintained per cpufreq
+// policy, so CPUs sharing a policy report the same values. Both statistics are missing in most virtual machines.
+type CpuStatesCollector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	rings map[string]*collector.ValueRing
+}
+
+func newCpuStatesCollector(root *RootCollector) *CpuStatesCollector {
+	return &CpuStatesCollector{
+		AbstractCollector: root.Child("cpu-states"),
+		factory:           root.Factory,
+	}
+}
+
+func (col *CpuStatesCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	values, err := col.read()
+	if err != nil {
+		return nil, err
+	}
+	col.rings = make(map[string]*collector.ValueRing, len(values))
+	for name := range values {
+		col.rings[name] = col.factory.NewValueRing()
+	}
+	return nil, nil
+}
+
+func (col *CpuStatesCollector) Metrics() collector.MetricReaderMap {
+	res := make(collector.MetricReaderMap, len(col.rings))
+	for name, ring := range col.rings {
+		res[name] = ring.GetDiff
+	}
+	return res
+}
+
+func (col *CpuStatesCollector) MetricsMetadata() collector.MetricMetadataMap {
+	res := make(collector.MetricMetadataMap, len(col.rings))
+	for name := range col.rings {
+		parts := strings.SplitN(name, "/", 4)
+		description := fmt.Sprintf("Residency of CPU %v in idle state %v", parts[1], parts[3])
+		if parts[2] == "freq" {
+			description = fmt.Sprintf("Residency of CPU %v at %v MHz", parts[1], parts[3])
+		}
+		res[name] = collector.DerivedMetric(collector.UnitPercent, description)
+	}
+	return res
+}
+
+func (col *CpuStatesCollector) Update(ctx context.Context) error {
+	values, err := col.read()
+	if err != nil {
+		return err
+	}
+	if len(values) != len(col.rings) {
+		return collector.MetricsChanged
+	}
+	for name, value := range values {
+		ring, ok := col.rings[name]
+		if !ok {
+			return collector.MetricsChanged
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	return nil
+}
+
+func (col *CpuStatesCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *CpuStatesCollector) read() (map[string]float64, error) {
+	cpus, err := readCpuStateResidency(hostfs.Current().SysPath("devices", "system", "cpu"))
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64)
+	for cpu, residency := range cpus {
+		for state, value := range residency.idle {
+			res[fmt.Sprintf("cpu-states/%v/idle/%v", cpu, state)] = value
+		}
+		for freq, value := range residency.freq {
+			res[fmt.Sprintf("cpu-states/%v/freq/%v", cpu, freq)] = value
+		}
+	}
+	return res, nil
+}
+
+// readCpuStateResidency reads the cpuidle and cpufreq statistics of all CPUs in the given directory, usually
+// /sys/devices/system/cpu. CPUs without any statistics, e.g. offline CPUs, are omitted.
+func readCpuStateResidency(dir string) (map[int]cpuStateResidency, error) {
+	cpuDirs, err := filepath.Glob(filepath.Join(dir, "cpu[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int]cpuStateResidency, len(cpuDirs))
+	for _, cpuDir := range cpuDirs {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(cpuDir), "cpu"))
+		if err != nil {
+			continue
+		}
+		idle, err := readCpuIdleTimes(filepath.Join(cpuDir, "cpuidle"))
+		if err != nil {
+			return nil, err
+		}
+		freq, err := readCpuFreqTimes(filepath.Join(cpuDir, "cpufreq", "stats", "time_in_state"))
+		if err != nil {
+			return nil, err
+		}
+		if len(idle) > 0 || len(freq) > 0 {
+			res[cpu] = cpuStateResidency{idle: idle, freq: freq}
+		}
+	}
+	return res, nil
+}
+
+// readCpuIdleTimes reads the name and the time in microseconds of every state<N> directory in the given cpuidle directory
+func readCpuIdleTimes(dir string) (map[string]float64, error) {
+	stateDirs, err := filepath.Glob(filepath.Join(dir, "state[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64, len(stateDirs))
+	for _, stateDir := range stateDirs {
+		name, err := ioutil.ReadFile(filepath.Join(stateDir, "name"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(filepath.Join(stateDir, "time"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		micros, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse idle state time in %v: %v", stateDir, err)
+		}
+		stateName := strings.TrimSpace(string(name))
+		if stateName == "" {
+			stateName = filepath.Base(stateDir)
+		}
+		res[stateName] = float64(micros) / 1e4
+	}
+	return res, nil
+}
+
+// readCpuFreqTimes parses the given time_in_state file, where every line contains a frequency in kHz and the time
+// spent at that frequency in units of 10 ms. A missing file means that the cpufreq statistics are not available.
+func readCpuFreqTimes(filename string) (map[string]float64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64)
+	for i, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		kHz, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+		time, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+		res[strconv.FormatFloat(float64(kHz)/1000, 'f', -1, 64)] = float64(time)
+	}
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type CpuStatesTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestCpuStates(t *testing.T) {
+	suite.Run(t, new(CpuStatesTestSuite))
+}
+
+func (suite *CpuStatesTestSuite) TestReadCpuStateResidency() {
+	dir, err := ioutil.TempDir("", "cpustates")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	write := func(content string, path ...string) {
+		file := filepath.Join(append([]string{dir}, path...)...)
+		suite.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+		suite.NoError(ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	write("POLL\n", "cpu0", "cpuidle", "state0", "name")
+	write("20000\n", "cpu0", "cpuidle", "state0", "time")
+	write("C6\n", "cpu0", "cpuidle", "state1", "name")
+	write("3000000\n", "cpu0", "cpuidle", "state1", "time")
+	write("2400000 150\n1800500 20\n", "cpu0", "cpufreq", "stats", "time_in_state")
+	write("C1\n", "cpu12", "cpuidle", "state0", "name")
+	write("100\n", "cpu12", "cpuidle", "state0", "time")
+	write("1\n", "cpu3", "online")
+	write("0-12\n", "cpufreq", "boost")
+
+	cpus, err := readCpuStateResidency(dir)
+	suite.NoError(err)
+	suite.Equal(map[int]cpuStateResidency{
+		0: {
+			idle: map[string]float64{"POLL": 2, "C6": 300},
+			freq: map[string]float64{"2400": 150, "1800.5": 20},
+		},
+		12: {
+			idle: map[string]float64{"C1": 0.01},
+		},
+	}, cpus)
+
+	write("2400000\n", "cpu0", "cpufreq", "stats", "time_in_state")
+	_, err = readCpuStateResidency(dir)
+	suite.Error(err)
+	write("2400000 x\n", "cpu0", "cpufreq", "stats", "time_in_state")
+	_, err = readCpuStateResidency(dir)
+	suite.Error(err)
+	write("", "cpu0", "cpufreq", "stats", "time_in_state")
+	write("x\n", "cpu0", "cpuidle", "state1", "time")
+	_, err = readCpuStateResidency(dir)
+	suite.Error(err)
+}
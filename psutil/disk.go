@@ -1,6 +1,7 @@
 package psutil
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 
@@ -25,7 +26,7 @@ func newDiskIoCollector(root *RootCollector) *DiskIOCollector {
 	}
 }
 
-func (col *DiskIOCollector) Init() ([]collector.Collector, error) {
+func (col *DiskIOCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	col.disks = make(map[string]disk.IOCountersStat)
 	if err := col.update(false); err != nil {
 		return nil, err
@@ -43,12 +44,12 @@ func (col *DiskIOCollector) Init() ([]collector.Collector, error) {
 	return res, nil
 }
 
-func (col *DiskIOCollector) Update() error {
+func (col *DiskIOCollector) Update(ctx context.Context) error {
 	return col.update(true)
 }
 
-func (col *DiskIOCollector) MetricsChanged() error {
-	return col.Update()
+func (col *DiskIOCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
 }
 
 func (col *DiskIOCollector) newChild(name string, disks []string) *ioDiskCollector {
@@ -70,7 +71,7 @@ func (col *DiskIOCollector) newChild(name string, disks []string) *ioDiskCollect
 }
 
 func (col *DiskIOCollector) update(checkChange bool) error {
-	disks, err := disk.IOCounters()
+	disks, err := readDiskIO()
 	if err != nil {
 		return err
 	}
@@ -112,7 +113,7 @@ func (col *ioDiskCollector) Depends() []collector.Collector {
 	return []collector.Collector{col.parent}
 }
 
-func (col *ioDiskCollector) Update() error {
+func (col *ioDiskCollector) Update(ctx context.Context) error {
 	for _, diskName := range col.disks {
 		d, ok := col.parent.disks[diskName]
 		if !ok {
@@ -154,3 +155,18 @@ func (col *ioDiskCollector) Metrics() collector.MetricReaderMap {
 		name + "ioTime":     col.ioTimeRing.GetDiff,
 	}
 }
+
+func (col *ioDiskCollector) MetricsMetadata() collector.MetricMetadataMap {
+	name := "disk-io/" + col.Name + "/"
+	return collector.MetricMetadataMap{
+		name + "read":       collector.DerivedMetric(collector.UnitPerSecond, "Read operations"),
+		name + "write":      collector.DerivedMetric(collector.UnitPerSecond, "Write operations"),
+		name + "io":         collector.DerivedMetric(collector.UnitPerSecond, "Read and write operations"),
+		name + "readBytes":  collector.DerivedMetric(collector.UnitBytesPerSecond, "Read throughput"),
+		name + "writeBytes": collector.DerivedMetric(collector.UnitBytesPerSecond, "Write throughput"),
+		name + "ioBytes":    collector.DerivedMetric(collector.UnitBytesPerSecond, "Read and write throughput"),
+		name + "readTime":   collector.DerivedMetric(collector.UnitMillisPerSec, "Time spent reading"),
+		name + "writeTime":  collector.DerivedMetric(collector.UnitMillisPerSec, "Time spent writing"),
+		name + "ioTime":     collector.DerivedMetric(collector.UnitMillisPerSec, "Time spent doing IO"),
+	}
+}
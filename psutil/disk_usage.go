@@ -1,10 +1,12 @@
 package psutil
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 	"github.com/shirou/gopsutil/disk"
 )
@@ -16,23 +18,25 @@ const (
 
 type DiskUsageCollector struct {
 	collector.AbstractCollector
+	root       *RootCollector
 	partitions map[string]*diskUsageCollector
 }
 
 func newDiskUsageCollector(root *RootCollector) *DiskUsageCollector {
 	return &DiskUsageCollector{
 		AbstractCollector: root.Child("disk-usage"),
+		root:              root,
 	}
 }
 
-func (col *DiskUsageCollector) Init() ([]collector.Collector, error) {
+func (col *DiskUsageCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	col.partitions = make(map[string]*diskUsageCollector)
 
 	partitions, err := col.getAllPartitions()
 	if err != nil {
 		return nil, err
 	}
-	result := make([]collector.Collector, 0, len(partitions)+1)
+	result := make([]collector.Collector, 0, len(partitions)+len(col.root.ContainerDiskUsage)+1)
 	for name, mountPoint := range partitions {
 		diskCollector := &diskUsageCollector{
 			AbstractCollector: col.Child(name),
@@ -46,11 +50,14 @@ func (col *DiskUsageCollector) Init() ([]collector.Collector, error) {
 		AbstractCollector: col.Child(diskUsageAll),
 		parent:            col,
 	})
+	for name, regex := range col.root.ContainerDiskUsage {
+		result = append(result, col.newContainerCollector(name, regex))
+	}
 	return result, nil
 }
 
-func (col *DiskUsageCollector) Update() error {
-	partitions, err := disk.Partitions(false)
+func (col *DiskUsageCollector) Update(ctx context.Context) error {
+	partitions, err := hostfs.Partitions()
 	if err != nil {
 		return err
 	}
@@ -68,12 +75,12 @@ func (col *DiskUsageCollector) Update() error {
 	return nil
 }
 
-func (col *DiskUsageCollector) MetricsChanged() error {
-	return col.Update()
+func (col *DiskUsageCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
 }
 
 func (col *DiskUsageCollector) getAllPartitions() (map[string]string, error) {
-	partitions, err := disk.Partitions(false)
+	partitions, err := hostfs.Partitions()
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +112,8 @@ func (col *diskUsageCollector) Depends() []collector.Collector {
 	return []collector.Collector{col.parent}
 }
 
-func (col *diskUsageCollector) Update() error {
-	stats, err := disk.Usage(col.mountPoint)
+func (col *diskUsageCollector) Update(ctx context.Context) error {
+	stats, err := disk.Usage(hostfs.Current().HostPath(col.mountPoint))
 	if err != nil || stats == nil {
 		col.stats = disk.UsageStat{}
 		err = fmt.Errorf("Error reading disk-usage of disk mounted at %v: %v", col.mountPoint, err)
@@ -124,6 +131,10 @@ func (col *diskUsageCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
+func (col *diskUsageCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return diskUsageMetadata(diskUsagePrefix + col.Name + "/")
+}
+
 func (col *diskUsageCollector) readFree() bitflow.Value {
 	return bitflow.Value(col.stats.Free)
 }
@@ -154,6 +165,10 @@ func (col *allDiskUsageCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
+func (col *allDiskUsageCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return diskUsageMetadata(diskUsagePrefix + diskUsageAll + "/")
+}
+
 func (col *allDiskUsageCollector) readFree() (res bitflow.Value) {
 	for _, part := range col.parent.partitions {
 		res += bitflow.Value(part.stats.Free)
@@ -173,3 +188,10 @@ func (col *allDiskUsageCollector) readPercent() bitflow.Value {
 		return bitflow.Value(used) / bitflow.Value(total) * 100
 	}
 }
+
+func diskUsageMetadata(name string) collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		name + "free": collector.GaugeMetric(collector.UnitBytes, "Free disk space"),
+		name + "used": collector.GaugeMetric(collector.UnitPercent, "Percentage of used disk space"),
+	}
+}
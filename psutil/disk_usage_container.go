@@ -0,0 +1,206 @@
+package psutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/shirou/gopsutil/disk"
+)
+
+const diskUsageContainerPrefix = "disk-usage-container/"
+
+// containerDiskUsageCollector evaluates the disk usage inside the mount namespace of a container, identified by
+// the first process with a command line matching the configured regex. The root file system is accessed through
+// /proc/<pid>/root. For overlay root file systems, the size of the writable upper layer is reported, which is the
+// disk space consumed by the container itself. Since the entire layer must be walked, its size is only recomputed
+// every RootCollector.WritableLayerUpdateInterval. The metrics are named disk-usage-container/<name>/... so they are
+// not hidden by the default filter on disk-usage/ metrics.
+type containerDiskUsageCollector struct {
+	collector.AbstractCollector
+	parent *DiskUsageCollector
+	name   string
+	regex  *regexp.Regexp
+	pid    int32
+
+	root          disk.UsageStat
+	upperDir      string
+	writableLayer uint64
+	layerUpdated  time.Time
+}
+
+func (col *DiskUsageCollector) newContainerCollector(name string, regex *regexp.Regexp) *containerDiskUsageCollector {
+	return &containerDiskUsageCollector{
+		AbstractCollector: col.Child("container-" + name),
+		parent:            col,
+		name:              name,
+		regex:             regex,
+	}
+}
+
+func (col *containerDiskUsageCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *containerDiskUsageCollector) Update(ctx context.Context) error {
+	if col.pid == 0 || !processExists(col.pid) {
+		pid, err := findProcessByCmdline(col.regex)
+		if err != nil {
+			return err
+		}
+		col.pid = pid
+	}
+	rootPath := hostfs.Current().ProcPath(strconv.Itoa(int(col.pid)), "root") + "/"
+	stats, err := disk.Usage(rootPath)
+	if err != nil || stats == nil {
+		col.root = disk.UsageStat{}
+		return fmt.Errorf("Error reading disk-usage of container %v (pid %v): %v", col.name, col.pid, err)
+	}
+	col.root = *stats
+
+	upperDir, err := overlayUpperDir(col.pid)
+	if err != nil {
+		return err
+	}
+	return col.updateWritableLayer(ctx, upperDir)
+}
+
+func (col *containerDiskUsageCollector) updateWritableLayer(ctx context.Context, upperDir string) error {
+	now := time.Now()
+	if upperDir == col.upperDir && now.Sub(col.layerUpdated) < col.parent.root.WritableLayerUpdateInterval {
+		return nil
+	}
+	size := uint64(0)
+	if upperDir != "" {
+		var err error
+		if size, err = directorySize(ctx, upperDir); err != nil {
+			return err
+		}
+	}
+	col.upperDir = upperDir
+	col.writableLayer = size
+	col.layerUpdated = now
+	return nil
+}
+
+func (col *containerDiskUsageCollector) metricPrefix() string {
+	return diskUsageContainerPrefix + col.name + "/"
+}
+
+func (col *containerDiskUsageCollector) Metrics() collector.MetricReaderMap {
+	name := col.metricPrefix()
+	return collector.MetricReaderMap{
+		name + "free":           col.readFree,
+		name + "used":           col.readPercent,
+		name + "writable-layer": col.readWritableLayer,
+	}
+}
+
+func (col *containerDiskUsageCollector) MetricsMetadata() collector.MetricMetadataMap {
+	name := col.metricPrefix()
+	res := diskUsageMetadata(name)
+	res[name+"writable-layer"] = collector.GaugeMetric(collector.UnitBytes, "Size of the writable overlay layer of the container (0 for other root file systems)")
+	return res
+}
+
+func (col *containerDiskUsageCollector) readFree() bitflow.Value {
+	return bitflow.Value(col.root.Free)
+}
+
+func (col *containerDiskUsageCollector) readPercent() bitflow.Value {
+	return bitflow.Value(col.root.UsedPercent)
+}
+
+func (col *containerDiskUsageCollector) readWritableLayer() bitflow.Value {
+	return bitflow.Value(col.writableLayer)
+}
+
+func processExists(pid int32) bool {
+	_, err := os.Stat(hostfs.Current().ProcPath(strconv.Itoa(int(pid))))
+	return err == nil
+}
+
+// findProcessByCmdline returns the lowest PID of all processes with a command line matching the given regex.
+// The lowest PID usually belongs to the main process of a container.
+func findProcessByCmdline(regex *regexp.Regexp) (int32, error) {
+	pids, err := readPids()
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		return pids[i] < pids[j]
+	})
+	for _, pid := range pids {
+		cmdline, err := ioutil.ReadFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "cmdline"))
+		if err != nil {
+			continue
+		}
+		if regex.MatchString(strings.Replace(string(cmdline), "\x00", " ", -1)) {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("No process matching %v found", regex)
+}
+
+// overlayUpperDir returns the upperdir option of the root file system of the given process, as seen in its
+// mount namespace. An empty string is returned if the root file system is not an overlay.
+// Format of the mountinfo lines: ID parentID major:minor root mountpoint options [optional fields] - fstype source superoptions
+func overlayUpperDir(pid int32) (string, error) {
+	file, err := os.Open(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "mountinfo"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	upperDir := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if len(fields) < 5 || separator < 0 || separator+3 >= len(fields) || fields[4] != "/" {
+			continue
+		}
+		// Later mounts on / shadow the previous ones
+		upperDir = ""
+		if fields[separator+1] == "overlay" {
+			for _, option := range strings.Split(fields[separator+3], ",") {
+				if strings.HasPrefix(option, "upperdir=") {
+					upperDir = strings.TrimPrefix(option, "upperdir=")
+				}
+			}
+		}
+	}
+	return upperDir, scanner.Err()
+}
+
+// directorySize returns the sum of the sizes of all files in the given directory tree.
+// Files that cannot be accessed are ignored. The walk is aborted when the context is canceled.
+func directorySize(ctx context.Context, dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err == nil && info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
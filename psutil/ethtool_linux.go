@@ -0,0 +1,98 @@
+package psutil
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Constants from linux/sockios.h and linux/ethtool.h
+const (
+	siocEthtool      = 0x8946
+	ethtoolGStrings  = 0x1b
+	ethtoolGStats    = 0x1d
+	ethtoolGSSetInfo = 0x37
+	ethSSStats       = 1
+	ethGStringLen    = 32
+)
+
+// ifreq is the struct ifreq of linux/if.h with the ifr_data member of the union
+type ifreq struct {
+	name [syscall.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [16]byte
+}
+
+// ethtoolStats returns the driver-specific statistics of the given NIC, as shown by ethtool -S.
+// The NIC must be in the network namespace of the collector process.
+func ethtoolStats(nic string) (map[string]uint64, error) {
+	if len(nic) >= syscall.IFNAMSIZ {
+		return nil, fmt.Errorf("Invalid interface name: %v", nic)
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = syscall.Close(fd)
+	}()
+
+	// struct ethtool_sset_info with a single element in the data array
+	info := make([]byte, 24)
+	nativeEndian.PutUint32(info[0:4], ethtoolGSSetInfo)
+	nativeEndian.PutUint64(info[8:16], 1<<ethSSStats)
+	if err := ethtoolIoctl(fd, nic, info); err != nil {
+		return nil, err
+	}
+	if nativeEndian.Uint64(info[8:16]) == 0 {
+		return nil, nil // The driver does not provide statistics
+	}
+	count := int(nativeEndian.Uint32(info[16:20]))
+	if count == 0 {
+		return nil, nil
+	}
+
+	// struct ethtool_gstrings
+	names := make([]byte, 12+count*ethGStringLen)
+	nativeEndian.PutUint32(names[0:4], ethtoolGStrings)
+	nativeEndian.PutUint32(names[4:8], ethSSStats)
+	nativeEndian.PutUint32(names[8:12], uint32(count))
+	if err := ethtoolIoctl(fd, nic, names); err != nil {
+		return nil, err
+	}
+
+	// struct ethtool_stats
+	values := make([]byte, 8+count*8)
+	nativeEndian.PutUint32(values[0:4], ethtoolGStats)
+	nativeEndian.PutUint32(values[4:8], uint32(count))
+	if err := ethtoolIoctl(fd, nic, values); err != nil {
+		return nil, err
+	}
+
+	// The kernel reduces the lengths, if the number of statistics has decreased in the meantime
+	if n := int(nativeEndian.Uint32(names[8:12])); n < count {
+		count = n
+	}
+	if n := int(nativeEndian.Uint32(values[4:8])); n < count {
+		count = n
+	}
+	res := make(map[string]uint64, count)
+	for i := 0; i < count; i++ {
+		name := names[12+i*ethGStringLen : 12+(i+1)*ethGStringLen]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		res[string(name)] = nativeEndian.Uint64(values[8+i*8:])
+	}
+	return res, nil
+}
+
+func ethtoolIoctl(fd int, nic string, data []byte) error {
+	req := &ifreq{data: unsafe.Pointer(&data[0])}
+	copy(req.name[:], nic)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(req))); errno != 0 {
+		return errno
+	}
+	return nil
+}
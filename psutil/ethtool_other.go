@@ -0,0 +1,9 @@
+// +build !linux
+
+package psutil
+
+import "errors"
+
+func ethtoolStats(nic string) (map[string]uint64, error) {
+	return nil, errors.New("Driver statistics of network interfaces are only available on Linux")
+}
@@ -1,6 +1,7 @@
 package psutil
 
 import (
+	"context"
 	"sync"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
@@ -28,16 +29,20 @@ func (col *LoadCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *LoadCollector) Update() error {
-	loadAvg, err := load.Avg()
+func (col *LoadCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"load/1":  collector.GaugeMetric(collector.UnitNone, "System load average over 1 minute"),
+		"load/5":  collector.GaugeMetric(collector.UnitNone, "System load average over 5 minutes"),
+		"load/15": collector.GaugeMetric(collector.UnitNone, "System load average over 15 minutes"),
+	}
+}
+
+func (col *LoadCollector) Update(ctx context.Context) error {
+	loadAvg, err := readLoad()
 
 	col.loadLock.Lock()
 	defer col.loadLock.Unlock()
-	if err != nil || loadAvg == nil {
-		col.load = load.AvgStat{}
-	} else {
-		col.load = *loadAvg
-	}
+	col.load = loadAvg
 	return err
 }
 
@@ -1,11 +1,12 @@
 package psutil
 
 import (
-	"path/filepath"
+	"context"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 	"github.com/shirou/gopsutil/mem"
+	log "github.com/sirupsen/logrus"
 )
 
 type MemCollector struct {
@@ -19,13 +20,9 @@ func newMemCollector(root *RootCollector) *MemCollector {
 	}
 }
 
-func (col *MemCollector) Update() error {
-	memory, err := mem.VirtualMemory()
-	if err != nil || memory == nil {
-		col.memory = mem.VirtualMemoryStat{}
-	} else {
-		col.memory = *memory
-	}
+func (col *MemCollector) Update(ctx context.Context) error {
+	memory, err := readMemory()
+	col.memory = memory
 	return err
 }
 
@@ -34,6 +31,20 @@ func (col *MemCollector) Metrics() collector.MetricReaderMap {
 		"mem/free":    col.readFreeMem,
 		"mem/used":    col.readUsedMem,
 		"mem/percent": col.readUsedPercentMem,
+		"mem/total":   readTotalMem,
+	}
+}
+
+func (col *MemCollector) StaticMetrics() []string {
+	return []string{"mem/total"}
+}
+
+func (col *MemCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"mem/free":    collector.GaugeMetric(collector.UnitBytes, "Memory available for starting new applications"),
+		"mem/used":    collector.GaugeMetric(collector.UnitBytes, "Memory used by applications"),
+		"mem/percent": collector.GaugeMetric(collector.UnitPercent, "Percentage of used memory"),
+		"mem/total":   collector.GaugeMetric(collector.UnitBytes, "Total physical memory"),
 	}
 }
 
@@ -49,11 +60,12 @@ func (col *MemCollector) readUsedPercentMem() bitflow.Value {
 	return bitflow.Value(col.memory.UsedPercent)
 }
 
-func hostProcFile(parts ...string) string {
-	// Forbidden import: "github.com/shirou/gopsutil/internal/common"
-	// return common.HostProc(parts...)
-	all := make([]string, len(parts)+1)
-	all[0] = "/proc"
-	copy(all[1:], parts)
-	return filepath.Join(all...)
+// readTotalMem does not use the values obtained in Update(), since it is a static metric
+func readTotalMem() bitflow.Value {
+	memory, err := readMemory()
+	if err != nil {
+		log.Warnln("Failed to read total memory:", err)
+		return 0
+	}
+	return bitflow.Value(memory.Total)
 }
@@ -0,0 +1,207 @@
+package psutil
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// neighborTable contains the size and statistics of one neighbor table of the kernel, e.g. arp_cache or ndisc_cache
+type neighborTable struct {
+	name           string
+	entries        uint64
+	gcThresh3      uint64 // Hard limit of the table size
+	resFailed      uint64
+	periodicGcRuns uint64
+	forcedGcRuns   uint64
+	tableFulls     uint64
+}
+
+// neighbor is one IPv4 or IPv6 entry of a neighbor table
+type neighbor struct {
+	ifindex    int
+	unresolved bool
+}
+
+// NeighborCollector collects the size and garbage collection statistics of the ARP and NDP neighbor tables,
+// and the number of (unresolved) neighbor entries per interface. When a neighbor table reaches gc_thresh3,
+// new neighbors cannot be resolved, which results in connectivity failures that are not visible otherwise.
+// The tables are queried through rtnetlink, so they describe the network namespace of the collector process.
+type NeighborCollector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	lock   sync.RWMutex
+	tables map[string]*neighborTableReader
+	nics   map[string]*neighborNicReader
+}
+
+type neighborTableReader struct {
+	col            *NeighborCollector
+	entries        bitflow.Value
+	gcThresh3      bitflow.Value
+	resFailed      *collector.ValueRing
+	periodicGcRuns *collector.ValueRing
+	forcedGcRuns   *collector.ValueRing
+	tableFulls     *collector.ValueRing
+}
+
+type neighborNicReader struct {
+	col        *NeighborCollector
+	entries    bitflow.Value
+	unresolved bitflow.Value
+}
+
+func newNeighborCollector(root *RootCollector) *NeighborCollector {
+	return &NeighborCollector{
+		AbstractCollector: root.Child("net-neigh"),
+		factory:           root.Factory,
+	}
+}
+
+func (col *NeighborCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	tables, err := readNeighborTables()
+	if err != nil {
+		return nil, err
+	}
+	nics, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	col.tables = make(map[string]*neighborTableReader, len(tables))
+	for _, table := range tables {
+		col.tables[table.name] = &neighborTableReader{
+			col:            col,
+			resFailed:      col.factory.NewValueRing(),
+			periodicGcRuns: col.factory.NewValueRing(),
+			forcedGcRuns:   col.factory.NewValueRing(),
+			tableFulls:     col.factory.NewValueRing(),
+		}
+	}
+	col.nics = make(map[string]*neighborNicReader, len(nics))
+	for _, nic := range nics {
+		col.nics[nic.Name] = &neighborNicReader{col: col}
+	}
+	return nil, nil
+}
+
+func (col *NeighborCollector) Metrics() collector.MetricReaderMap {
+	res := make(collector.MetricReaderMap)
+	for name, table := range col.tables {
+		prefix := "net-neigh/" + name
+		res[prefix+"/entries"] = table.readEntries
+		res[prefix+"/gc_thresh3"] = table.readGcThresh3
+		res[prefix+"/res_failed"] = table.resFailed.GetDiff
+		res[prefix+"/periodic_gc_runs"] = table.periodicGcRuns.GetDiff
+		res[prefix+"/forced_gc_runs"] = table.forcedGcRuns.GetDiff
+		res[prefix+"/table_fulls"] = table.tableFulls.GetDiff
+	}
+	for name, nic := range col.nics {
+		prefix := "net-neigh/nic/" + name
+		res[prefix+"/entries"] = nic.readEntries
+		res[prefix+"/unresolved"] = nic.readUnresolved
+	}
+	return res
+}
+
+func (col *NeighborCollector) MetricsMetadata() collector.MetricMetadataMap {
+	res := make(collector.MetricMetadataMap)
+	for name := range col.tables {
+		prefix := "net-neigh/" + name
+		res[prefix+"/entries"] = collector.GaugeMetric(collector.UnitCount, "Entries in the "+name+" neighbor table")
+		res[prefix+"/gc_thresh3"] = collector.GaugeMetric(collector.UnitCount, "Maximum number of entries in the "+name+" neighbor table")
+		res[prefix+"/res_failed"] = collector.DerivedMetric(collector.UnitPerSecond, "Failed neighbor resolutions in the "+name+" neighbor table")
+		res[prefix+"/periodic_gc_runs"] = collector.DerivedMetric(collector.UnitPerSecond, "Periodic garbage collection runs of the "+name+" neighbor table")
+		res[prefix+"/forced_gc_runs"] = collector.DerivedMetric(collector.UnitPerSecond, "Garbage collection runs of the "+name+" neighbor table forced by exceeding gc_thresh2")
+		res[prefix+"/table_fulls"] = collector.DerivedMetric(collector.UnitPerSecond, "Neighbors that could not be added to the full "+name+" neighbor table")
+	}
+	for name := range col.nics {
+		prefix := "net-neigh/nic/" + name
+		res[prefix+"/entries"] = collector.GaugeMetric(collector.UnitCount, "IPv4 and IPv6 neighbor entries of "+name)
+		res[prefix+"/unresolved"] = collector.GaugeMetric(collector.UnitCount, "Incomplete and failed neighbor entries of "+name)
+	}
+	return res
+}
+
+func (col *NeighborCollector) Update(ctx context.Context) error {
+	tables, err := readNeighborTables()
+	if err != nil {
+		return err
+	}
+	neighbors, err := readNeighbors()
+	if err != nil {
+		return err
+	}
+	nics, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+	if len(tables) != len(col.tables) || len(nics) != len(col.nics) {
+		return collector.MetricsChanged
+	}
+	nicNames := make(map[int]string, len(nics))
+	for _, nic := range nics {
+		if _, ok := col.nics[nic.Name]; !ok {
+			return collector.MetricsChanged
+		}
+		nicNames[nic.Index] = nic.Name
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	for _, table := range tables {
+		reader, ok := col.tables[table.name]
+		if !ok {
+			return collector.MetricsChanged
+		}
+		reader.entries = bitflow.Value(table.entries)
+		reader.gcThresh3 = bitflow.Value(table.gcThresh3)
+		reader.resFailed.Add(collector.StoredValue(table.resFailed))
+		reader.periodicGcRuns.Add(collector.StoredValue(table.periodicGcRuns))
+		reader.forcedGcRuns.Add(collector.StoredValue(table.forcedGcRuns))
+		reader.tableFulls.Add(collector.StoredValue(table.tableFulls))
+	}
+	for _, nic := range col.nics {
+		nic.entries, nic.unresolved = 0, 0
+	}
+	for _, neighbor := range neighbors {
+		if nic, ok := col.nics[nicNames[neighbor.ifindex]]; ok {
+			nic.entries++
+			if neighbor.unresolved {
+				nic.unresolved++
+			}
+		}
+	}
+	return nil
+}
+
+func (col *NeighborCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (reader *neighborTableReader) readEntries() bitflow.Value {
+	reader.col.lock.RLock()
+	defer reader.col.lock.RUnlock()
+	return reader.entries
+}
+
+func (reader *neighborTableReader) readGcThresh3() bitflow.Value {
+	reader.col.lock.RLock()
+	defer reader.col.lock.RUnlock()
+	return reader.gcThresh3
+}
+
+func (reader *neighborNicReader) readEntries() bitflow.Value {
+	reader.col.lock.RLock()
+	defer reader.col.lock.RUnlock()
+	return reader.entries
+}
+
+func (reader *neighborNicReader) readUnresolved() bitflow.Value {
+	reader.col.lock.RLock()
+	defer reader.col.lock.RUnlock()
+	return reader.unresolved
+}
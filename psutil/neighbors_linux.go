@@ -0,0 +1,110 @@
+package psutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Constants from linux/neighbour.h
+const (
+	ndMsgLen  = 12 // Size of struct ndmsg
+	ndtMsgLen = 4  // Size of struct ndtmsg
+
+	nudIncomplete = 0x01
+	nudFailed     = 0x20
+
+	ndtaName    = 1
+	ndtaThresh3 = 4
+	ndtaConfig  = 5
+	ndtaStats   = 7
+
+	ndtConfigEntriesOffset = 4  // Offset of ndtc_entries in struct ndt_config
+	ndtStatsMinSize        = 80 // Size of struct ndt_stats up to ndts_forced_gc_runs, ndts_table_fulls was added in Linux 4.3
+)
+
+func readNeighborTables() ([]neighborTable, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGHTBL, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query neighbor tables through netlink: %v", err)
+	}
+	return parseNeighborTables(data)
+}
+
+func readNeighbors() ([]neighbor, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query neighbors through netlink: %v", err)
+	}
+	return parseNeighbors(data)
+}
+
+// parseNeighborTables parses the response to a RTM_GETNEIGHTBL dump request. The kernel sends one message with
+// the statistics of every table, followed by one message with the parameters of every interface, which are skipped.
+func parseNeighborTables(data []byte) ([]neighborTable, error) {
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	var res []neighborTable
+	for _, msg := range messages {
+		if msg.Header.Type != syscall.RTM_NEWNEIGHTBL || len(msg.Data) < ndtMsgLen {
+			continue
+		}
+		attrs := parseNetlinkAttributes(msg.Data[ndtMsgLen:])
+		stats, ok := attrs[ndtaStats]
+		if !ok {
+			continue
+		}
+		name, thresh3, config := attrs[ndtaName], attrs[ndtaThresh3], attrs[ndtaConfig]
+		if len(name) == 0 || len(thresh3) < 4 || len(config) < ndtConfigEntriesOffset+4 || len(stats) < ndtStatsMinSize {
+			return nil, fmt.Errorf("Received incomplete neighbor table message")
+		}
+		if name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+		u64 := func(offset int) uint64 {
+			return nativeEndian.Uint64(stats[offset : offset+8])
+		}
+		// Offsets of the fields in struct ndt_stats
+		table := neighborTable{
+			name:           string(name),
+			entries:        uint64(nativeEndian.Uint32(config[ndtConfigEntriesOffset:])),
+			gcThresh3:      uint64(nativeEndian.Uint32(thresh3)),
+			resFailed:      u64(24),
+			periodicGcRuns: u64(64),
+			forcedGcRuns:   u64(72),
+		}
+		if len(stats) >= ndtStatsMinSize+8 {
+			table.tableFulls = u64(80)
+		}
+		res = append(res, table)
+	}
+	return res, nil
+}
+
+// parseNeighbors parses the response to a RTM_GETNEIGH dump request. Only IPv4 and IPv6 neighbors are returned,
+// not the forwarding database entries of bridges.
+func parseNeighbors(data []byte) ([]neighbor, error) {
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	var res []neighbor
+	for _, msg := range messages {
+		if msg.Header.Type != syscall.RTM_NEWNEIGH {
+			continue
+		}
+		if len(msg.Data) < ndMsgLen {
+			return nil, fmt.Errorf("Received truncated neighbor message (%v bytes)", len(msg.Data))
+		}
+		if family := msg.Data[0]; family != syscall.AF_INET && family != syscall.AF_INET6 {
+			continue
+		}
+		state := nativeEndian.Uint16(msg.Data[8:10])
+		res = append(res, neighbor{
+			ifindex:    int(int32(nativeEndian.Uint32(msg.Data[4:8]))),
+			unresolved: state&(nudIncomplete|nudFailed) != 0,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,101 @@
+package psutil
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type NeighborsTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestNeighbors(t *testing.T) {
+	suite.Run(t, new(NeighborsTestSuite))
+}
+
+func netlinkMessage(msgType uint16, header []byte, attrs map[uint16][]byte) []byte {
+	data := append([]byte{}, header...)
+	for _, attrType := range []uint16{ndtaName, ndtaThresh3, ndtaConfig, ndtaStats} {
+		value, ok := attrs[attrType]
+		if !ok {
+			continue
+		}
+		attr := make([]byte, nlaAlign(nlaHeaderLen+len(value)))
+		nativeEndian.PutUint16(attr[0:2], uint16(nlaHeaderLen+len(value)))
+		nativeEndian.PutUint16(attr[2:4], attrType)
+		copy(attr[nlaHeaderLen:], value)
+		data = append(data, attr...)
+	}
+	msg := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(data))
+	nativeEndian.PutUint32(msg[0:4], uint32(syscall.NLMSG_HDRLEN+len(data)))
+	nativeEndian.PutUint16(msg[4:6], msgType)
+	return append(msg, data...)
+}
+
+func (suite *NeighborsTestSuite) TestParseNeighborTables() {
+	u32 := func(val uint32) []byte {
+		res := make([]byte, 4)
+		nativeEndian.PutUint32(res, val)
+		return res
+	}
+	config := append(u32(0), u32(12)...)
+	stats := make([]byte, ndtStatsMinSize+8)
+	for i, val := range []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11} {
+		nativeEndian.PutUint64(stats[i*8:], val)
+	}
+	header := make([]byte, ndtMsgLen)
+
+	var data []byte
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGHTBL, header, map[uint16][]byte{
+		ndtaName: []byte("arp_cache\x00"), ndtaThresh3: u32(1024), ndtaConfig: config, ndtaStats: stats,
+	})...)
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGHTBL, header, map[uint16][]byte{
+		ndtaName: []byte("ndisc_cache\x00"), ndtaThresh3: u32(512), ndtaConfig: config, ndtaStats: stats[:ndtStatsMinSize],
+	})...)
+	// Parameters of an interface, without statistics
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGHTBL, header, map[uint16][]byte{
+		ndtaName: []byte("arp_cache\x00"),
+	})...)
+	data = append(data, netlinkMessage(syscall.NLMSG_DONE, u32(0), nil)...)
+
+	tables, err := parseNeighborTables(data)
+	suite.NoError(err)
+	suite.Equal([]neighborTable{
+		{name: "arp_cache", entries: 12, gcThresh3: 1024, resFailed: 4, periodicGcRuns: 9, forcedGcRuns: 10, tableFulls: 11},
+		{name: "ndisc_cache", entries: 12, gcThresh3: 512, resFailed: 4, periodicGcRuns: 9, forcedGcRuns: 10},
+	}, tables)
+
+	_, err = parseNeighborTables(netlinkMessage(syscall.RTM_NEWNEIGHTBL, header, map[uint16][]byte{
+		ndtaName: []byte("arp_cache\x00"), ndtaStats: stats,
+	}))
+	suite.Error(err)
+}
+
+func (suite *NeighborsTestSuite) TestParseNeighbors() {
+	ndmsg := func(family byte, ifindex uint32, state uint16) []byte {
+		res := make([]byte, ndMsgLen)
+		res[0] = family
+		nativeEndian.PutUint32(res[4:8], ifindex)
+		nativeEndian.PutUint16(res[8:10], state)
+		return res
+	}
+	var data []byte
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGH, ndmsg(syscall.AF_INET, 2, 0x02), nil)...)
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGH, ndmsg(syscall.AF_INET6, 3, nudIncomplete), nil)...)
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGH, ndmsg(syscall.AF_INET, 3, nudFailed), nil)...)
+	data = append(data, netlinkMessage(syscall.RTM_NEWNEIGH, ndmsg(syscall.AF_BRIDGE, 4, 0x80), nil)...)
+
+	neighbors, err := parseNeighbors(data)
+	suite.NoError(err)
+	suite.Equal([]neighbor{
+		{ifindex: 2},
+		{ifindex: 3, unresolved: true},
+		{ifindex: 3, unresolved: true},
+	}, neighbors)
+
+	_, err = parseNeighbors(netlinkMessage(syscall.RTM_NEWNEIGH, []byte{syscall.AF_INET}, nil))
+	suite.Error(err)
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package psutil
+
+import "errors"
+
+func readNeighborTables() ([]neighborTable, error) {
+	return nil, errors.New("Neighbor table statistics are only available on Linux")
+}
+
+func readNeighbors() ([]neighbor, error) {
+	return nil, errors.New("Neighbor table statistics are only available on Linux")
+}
@@ -1,17 +1,36 @@
 package psutil
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
 	psnet "github.com/shirou/gopsutil/net"
+	log "github.com/sirupsen/logrus"
 )
 
+// ethtoolTrafficCounters maps the names of driver statistics (see ethtoolStats) to the metrics of the broadcast and
+// multicast traffic of a NIC. The received multicast packets are reported by all drivers through /proc/net/dev,
+// the other counters are only available for some physical NICs. The mlx5 driver uses the vport names.
+var ethtoolTrafficCounters = map[string]string{
+	"rx_broadcast":               "rx_broadcast",
+	"tx_broadcast":               "tx_broadcast",
+	"tx_multicast":               "tx_multicast",
+	"rx_vport_broadcast_packets": "rx_broadcast",
+	"tx_vport_broadcast_packets": "tx_broadcast",
+	"tx_vport_multicast_packets": "tx_multicast",
+}
+
 type NetCollector struct {
 	collector.AbstractCollector
 
-	factory  *collector.ValueRingFactory
-	counters map[string]psnet.IOCountersStat
+	factory   *collector.ValueRingFactory
+	counters  map[string]psnet.IOCountersStat
+	multicast map[string]uint64
 }
 
 func newNetCollector(root *RootCollector) *NetCollector {
@@ -21,7 +40,7 @@ func newNetCollector(root *RootCollector) *NetCollector {
 	}
 }
 
-func (col *NetCollector) Init() ([]collector.Collector, error) {
+func (col *NetCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	col.counters = make(map[string]psnet.IOCountersStat)
 	if err := col.update(false); err != nil {
 		return nil, err
@@ -41,19 +60,21 @@ func (col *NetCollector) newChild(collectorName string, nicName string) collecto
 		parent:            col,
 		nicName:           nicName,
 		counters:          NewNetIoCounters(col.factory),
+		multicast:         col.factory.NewValueRing(),
 	}
 }
 
-func (col *NetCollector) MetricsChanged() error {
-	return col.Update()
+func (col *NetCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
 }
 
-func (col *NetCollector) Update() error {
+func (col *NetCollector) Update(ctx context.Context) error {
 	return col.update(true)
 }
 
 func (col *NetCollector) update(checkChange bool) error {
-	nicsList, err := psnet.IOCounters(true)
+	file := hostfs.Current().NamespaceProcPath("net", "dev")
+	nicsList, multicast, err := readNetDev(file)
 	if err != nil {
 		return err
 	}
@@ -73,42 +94,161 @@ func (col *NetCollector) update(checkChange bool) error {
 		nics[nic.Name] = nic
 	}
 	col.counters = nics
+	col.multicast = multicast
 	return nil
 }
 
+// readNetDev parses the counters of every NIC in the given net/dev file, including the received multicast packets,
+// which are not parsed by gopsutil. The file starts with two header lines, followed by one line per NIC with
+// 8 receive and 8 transmit columns.
+func readNetDev(filename string) ([]psnet.IOCountersStat, map[string]uint64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 {
+		return nil, nil, fmt.Errorf("%v is not formatted correctly, missing header lines", filename)
+	}
+	nics := make([]psnet.IOCountersStat, 0, len(lines)-2)
+	multicast := make(map[string]uint64, len(lines)-2)
+	for i, line := range lines[2:] {
+		index := strings.IndexRune(line, ':')
+		if index < 0 {
+			return nil, nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+3)
+		}
+		fields := strings.Fields(line[index+1:])
+		if len(fields) < 16 {
+			return nil, nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+3)
+		}
+		var values [16]uint64
+		for column := range values {
+			if values[column], err = strconv.ParseUint(fields[column], 10, 64); err != nil {
+				return nil, nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+3, err)
+			}
+		}
+		nic := psnet.IOCountersStat{
+			Name:        strings.TrimSpace(line[:index]),
+			BytesRecv:   values[0],
+			PacketsRecv: values[1],
+			Errin:       values[2],
+			Dropin:      values[3],
+			Fifoin:      values[4],
+			BytesSent:   values[8],
+			PacketsSent: values[9],
+			Errout:      values[10],
+			Dropout:     values[11],
+			Fifoout:     values[12],
+		}
+		multicast[nic.Name] = values[7]
+		nics = append(nics, nic)
+	}
+	return nics, multicast, nil
+}
+
 type psutilNetInterfaceCollector struct {
 	collector.AbstractCollector
-	parent   *NetCollector
-	counters NetIoCounters
-	nicName  string
+	parent    *NetCollector
+	counters  NetIoCounters
+	multicast *collector.ValueRing
+	nicName   string
+
+	// Broadcast and multicast counters of the NIC driver, indexed by the name of the driver statistic
+	driverCounters map[string]*collector.ValueRing
+}
+
+func (col *psutilNetInterfaceCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.driverCounters = make(map[string]*collector.ValueRing)
+	if col.nicName == "" {
+		return nil, nil
+	}
+	stats, err := ethtoolStats(col.nicName)
+	if err != nil {
+		log.Debugf("Failed to read driver statistics of NIC %v, not collecting its broadcast traffic: %v", col.nicName, err)
+		return nil, nil
+	}
+	metrics := make(map[string]string)
+	for name := range stats {
+		if metric, ok := ethtoolTrafficCounters[name]; ok {
+			// Prefer the generic names, in case a driver reports multiple statistics for the same metric
+			if _, exists := metrics[metric]; !exists || name == metric {
+				metrics[metric] = name
+			}
+		}
+	}
+	for _, name := range metrics {
+		col.driverCounters[name] = col.parent.factory.NewValueRing()
+	}
+	return nil, nil
 }
 
 func (col *psutilNetInterfaceCollector) Depends() []collector.Collector {
 	return []collector.Collector{col.parent}
 }
 
-func (col *psutilNetInterfaceCollector) Update() error {
+func (col *psutilNetInterfaceCollector) Update(ctx context.Context) error {
 	if col.nicName == "" {
 		for _, nic := range col.parent.counters {
 			col.counters.AddToHead(&nic)
 		}
 		col.counters.FlushHead()
+		for _, multicast := range col.parent.multicast {
+			col.multicast.AddToHead(collector.StoredValue(multicast))
+		}
+		col.multicast.FlushHead()
 	} else {
 		counters, ok := col.parent.counters[col.nicName]
 		if !ok {
 			return fmt.Errorf("disk-io counters for disk %v not found", col.nicName)
 		}
 		col.counters.Add(&counters)
+		col.multicast.Add(collector.StoredValue(col.parent.multicast[col.nicName]))
+		if len(col.driverCounters) > 0 {
+			return col.updateDriverCounters()
+		}
+	}
+	return nil
+}
+
+func (col *psutilNetInterfaceCollector) updateDriverCounters() error {
+	stats, err := ethtoolStats(col.nicName)
+	if err != nil {
+		return fmt.Errorf("Failed to read driver statistics of NIC %v: %v", col.nicName, err)
+	}
+	for name, ring := range col.driverCounters {
+		value, ok := stats[name]
+		if !ok {
+			return fmt.Errorf("Driver statistic %v of NIC %v not found", name, col.nicName)
+		}
+		ring.Add(collector.StoredValue(value))
 	}
 	return nil
 }
 
 func (col *psutilNetInterfaceCollector) Metrics() collector.MetricReaderMap {
-	prefix := col.nicName
-	if prefix == "" {
-		prefix = "net-io"
-	} else {
-		prefix = "net-io/nic/" + prefix
+	prefix := col.metricPrefix()
+	res := col.counters.Metrics(prefix)
+	res[prefix+"/rx_multicast"] = col.multicast.GetDiff
+	for name, ring := range col.driverCounters {
+		res[prefix+"/"+ethtoolTrafficCounters[name]] = ring.GetDiff
+	}
+	return res
+}
+
+func (col *psutilNetInterfaceCollector) MetricsMetadata() collector.MetricMetadataMap {
+	prefix := col.metricPrefix()
+	res := col.counters.MetricsMetadata(prefix)
+	res[prefix+"/rx_multicast"] = collector.DerivedMetric(collector.UnitPerSecond, "Received multicast packets")
+	for name := range col.driverCounters {
+		metric := ethtoolTrafficCounters[name]
+		res[prefix+"/"+metric] = collector.DerivedMetric(collector.UnitPerSecond, "Driver statistic "+name+" of "+col.nicName)
+	}
+	return res
+}
+
+func (col *psutilNetInterfaceCollector) metricPrefix() string {
+	if col.nicName == "" {
+		return "net-io"
 	}
-	return col.counters.Metrics(prefix)
+	return "net-io/nic/" + col.nicName
 }
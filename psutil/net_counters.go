@@ -59,6 +59,17 @@ func (counters *BaseNetIoCounters) Metrics(prefix string) collector.MetricReader
 	}
 }
 
+func (counters *BaseNetIoCounters) MetricsMetadata(prefix string) collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		prefix + "/bytes":      collector.DerivedMetric(collector.UnitBytesPerSecond, "Received and sent bytes"),
+		prefix + "/packets":    collector.DerivedMetric(collector.UnitPerSecond, "Received and sent packets"),
+		prefix + "/rx_bytes":   collector.DerivedMetric(collector.UnitBytesPerSecond, "Received bytes"),
+		prefix + "/rx_packets": collector.DerivedMetric(collector.UnitPerSecond, "Received packets"),
+		prefix + "/tx_bytes":   collector.DerivedMetric(collector.UnitBytesPerSecond, "Sent bytes"),
+		prefix + "/tx_packets": collector.DerivedMetric(collector.UnitPerSecond, "Sent packets"),
+	}
+}
+
 type NetIoCounters struct {
 	BaseNetIoCounters
 	Errors  *collector.ValueRing
@@ -96,3 +107,10 @@ func (counters *NetIoCounters) Metrics(prefix string) collector.MetricReaderMap
 	m[prefix+"/dropped"] = counters.Dropped.GetDiff
 	return m
 }
+
+func (counters *NetIoCounters) MetricsMetadata(prefix string) collector.MetricMetadataMap {
+	m := counters.BaseNetIoCounters.MetricsMetadata(prefix)
+	m[prefix+"/errors"] = collector.DerivedMetric(collector.UnitPerSecond, "Receive and send errors")
+	m[prefix+"/dropped"] = collector.DerivedMetric(collector.UnitPerSecond, "Dropped incoming and outgoing packets")
+	return m
+}
@@ -1,9 +1,14 @@
 package psutil
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 	psnet "github.com/shirou/gopsutil/net"
 )
@@ -37,7 +42,7 @@ func newNetProtoCollector(root *RootCollector) *NetProtoCollector {
 	}
 }
 
-func (col *NetProtoCollector) Init() ([]collector.Collector, error) {
+func (col *NetProtoCollector) Init(ctx context.Context) ([]collector.Collector, error) {
 	col.protocols = make(map[string]psnet.ProtoCountersStat)
 	col.protoReaders = nil
 
@@ -72,7 +77,7 @@ func (col *NetProtoCollector) Metrics() collector.MetricReaderMap {
 }
 
 func (col *NetProtoCollector) update(checkChange bool) error {
-	counters, err := psnet.ProtoCounters(nil)
+	counters, err := readProtoCounters(hostfs.Current().NamespaceProcPath("net", "snmp"))
 	if err != nil {
 		return err
 	}
@@ -91,7 +96,7 @@ func (col *NetProtoCollector) update(checkChange bool) error {
 	return nil
 }
 
-func (col *NetProtoCollector) Update() (err error) {
+func (col *NetProtoCollector) Update(ctx context.Context) (err error) {
 	if err = col.update(true); err == nil {
 		for _, protoReader := range col.protoReaders {
 			if err := protoReader.update(); err != nil {
@@ -102,8 +107,8 @@ func (col *NetProtoCollector) Update() (err error) {
 	return
 }
 
-func (col *NetProtoCollector) MetricsChanged() error {
-	return col.Update()
+func (col *NetProtoCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
 }
 
 type protoStatReader struct {
@@ -140,3 +145,37 @@ func (reader *protoStatReader) read() bitflow.Value {
 		return reader.value
 	}
 }
+
+// readProtoCounters parses the given /proc/<pid>/net/snmp file, like psnet.ProtoCounters(), which always reads the
+// file of the own network namespace. Every protocol is described by a header line and a value line, prefixed with
+// the protocol name, e.g. "Tcp: RtoAlgorithm RtoMin ..." followed by "Tcp: 1 200 ...".
+func readProtoCounters(filename string) ([]psnet.ProtoCountersStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines)%2 != 0 {
+		return nil, fmt.Errorf("%v is not formatted correctly, expected pairs of header and value lines", filename)
+	}
+	stats := make([]psnet.ProtoCountersStat, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		names, values := strings.Fields(lines[i]), strings.Fields(lines[i+1])
+		if len(names) == 0 || len(names) != len(values) || names[0] != values[0] || !strings.HasSuffix(names[0], ":") {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		stat := psnet.ProtoCountersStat{
+			Protocol: strings.ToLower(strings.TrimSuffix(names[0], ":")),
+			Stats:    make(map[string]int64, len(names)-1),
+		}
+		for j := 1; j < len(names); j++ {
+			value, err := strconv.ParseInt(values[j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+			}
+			stat.Stats[names[j]] = value
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
@@ -0,0 +1,51 @@
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/suite"
+)
+
+type NetProtoTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestNetProto(t *testing.T) {
+	suite.Run(t, new(NetProtoTestSuite))
+}
+
+func (suite *NetProtoTestSuite) TestReadProtoCounters() {
+	dir, err := ioutil.TempDir("", "net-proto")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "snmp")
+	suite.NoError(ioutil.WriteFile(file, []byte(
+		"Tcp: RtoAlgorithm RtoMin MaxConn CurrEstab\n"+
+			"Tcp: 1 200 -1 12\n"+
+			"Udp: InDatagrams NoPorts\n"+
+			"Udp: 100 3\n"), 0644))
+	counters, err := readProtoCounters(file)
+	suite.NoError(err)
+	suite.Equal([]psnet.ProtoCountersStat{
+		{Protocol: "tcp", Stats: map[string]int64{"RtoAlgorithm": 1, "RtoMin": 200, "MaxConn": -1, "CurrEstab": 12}},
+		{Protocol: "udp", Stats: map[string]int64{"InDatagrams": 100, "NoPorts": 3}},
+	}, counters)
+
+	for _, invalid := range []string{
+		"Tcp: RtoAlgorithm\n",
+		"Tcp: RtoAlgorithm RtoMin\nTcp: 1\n",
+		"Tcp: RtoAlgorithm\nUdp: 1\n",
+		"Tcp: RtoAlgorithm\nTcp: x\n",
+	} {
+		suite.NoError(ioutil.WriteFile(file, []byte(invalid), 0644))
+		_, err := readProtoCounters(file)
+		suite.Error(err, invalid)
+	}
+}
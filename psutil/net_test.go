@@ -0,0 +1,52 @@
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/suite"
+)
+
+type NetTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestNet(t *testing.T) {
+	suite.Run(t, new(NetTestSuite))
+}
+
+func (suite *NetTestSuite) TestReadNetDev() {
+	dir, err := ioutil.TempDir("", "net-dev")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "dev")
+	header := "Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"
+	suite.NoError(ioutil.WriteFile(file, []byte(header+
+		"    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0\n"+
+		"  eth0:12345678   54321    1    2    0     0          0       789  8765432   12345    0    0    0     0       0          0\n"), 0644))
+	nics, multicast, err := readNetDev(file)
+	suite.NoError(err)
+	suite.Equal([]psnet.IOCountersStat{
+		{Name: "lo", BytesRecv: 1000, PacketsRecv: 10, BytesSent: 1000, PacketsSent: 10},
+		{Name: "eth0", BytesRecv: 12345678, PacketsRecv: 54321, Errin: 1, Dropin: 2, BytesSent: 8765432, PacketsSent: 12345},
+	}, nics)
+	suite.Equal(map[string]uint64{"lo": 0, "eth0": 789}, multicast)
+
+	for _, invalid := range []string{
+		"eth0: 1 2 3 4 5 6 7 8\n",
+		header + "eth0 1 2 3 4 5 6 7 8\n",
+		header + "eth0: 1 2 3 4 5 6 7\n",
+		header + "eth0: 1 2 3 4 5 6 7 x 9 10 11 12 13 14 15 16\n",
+	} {
+		suite.NoError(ioutil.WriteFile(file, []byte(invalid), 0644))
+		_, _, err := readNetDev(file)
+		suite.Error(err, invalid)
+	}
+}
@@ -1,11 +1,11 @@
 package psutil
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
-	"github.com/shirou/gopsutil/process"
 )
 
 type PidCollector struct {
@@ -26,8 +26,14 @@ func (col *PidCollector) Metrics() collector.MetricReaderMap {
 	}
 }
 
-func (col *PidCollector) Update() (err error) {
-	if col.pids, err = process.Pids(); err != nil {
+func (col *PidCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"num_procs": collector.GaugeMetric(collector.UnitCount, "Number of running processes"),
+	}
+}
+
+func (col *PidCollector) Update(ctx context.Context) (err error) {
+	if col.pids, err = readPids(); err != nil {
 		err = fmt.Errorf("Failed to update PIDs: %v", err)
 	}
 	return
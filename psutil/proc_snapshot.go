@@ -0,0 +1,238 @@
+package psutil
+
+import (
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/shirou/gopsutil/cpu"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// procSnapshot caches per-process data read from the /proc filesystem during one update round.
+// Processes are often matched by multiple process collectors, but every /proc file should only be read
+// and parsed once per round. The snapshot is reset by RootCollector.Update(), which precedes the updates of
+// all process collectors.
+type procSnapshot struct {
+	lock    sync.Mutex
+	entries map[procSnapshotKey]*procSnapshotEntry
+
+	// If non-nil, CPU times and delay accounting data are obtained through the taskstats interface
+	taskstats *taskstatsClient
+}
+
+type procSnapshotKey struct {
+	pid  int32
+	file string
+}
+
+type procSnapshotEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+type procStatus struct {
+	numThreads  int32
+	ctxSwitches process.NumCtxSwitchesStat
+	cpusAllowed []int
+}
+
+func newProcSnapshot() *procSnapshot {
+	return &procSnapshot{
+		entries: make(map[procSnapshotKey]*procSnapshotEntry),
+	}
+}
+
+func (s *procSnapshot) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key := range s.entries {
+		delete(s.entries, key)
+	}
+}
+
+// get returns the cached result of the given read function, or executes it if the given file of the given
+// process has not been read in the current round. Parallel calls for the same key execute the read function only once.
+func (s *procSnapshot) get(pid int32, file string, read func() (interface{}, error)) (interface{}, error) {
+	key := procSnapshotKey{pid: pid, file: file}
+	s.lock.Lock()
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = new(procSnapshotEntry)
+		s.entries[key] = entry
+	}
+	s.lock.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = read()
+	})
+	return entry.value, entry.err
+}
+
+func (s *procSnapshot) cmdline(proc *process.Process) (string, error) {
+	val, err := s.get(proc.Pid, "cmdline", func() (interface{}, error) {
+		return readProcCmdline(proc)
+	})
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+func (s *procSnapshot) comm(pid int32) (string, error) {
+	val, err := s.get(pid, "comm", func() (interface{}, error) {
+		return readProcComm(pid)
+	})
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+func (s *procSnapshot) times(proc *process.Process) (*cpu.TimesStat, error) {
+	if s.taskstats != nil {
+		stats, err := s.taskstatsOf(proc.Pid)
+		if err != nil {
+			return nil, err
+		}
+		return &cpu.TimesStat{
+			CPU:    "cpu",
+			User:   float64(stats.utime) / 1e6,
+			System: float64(stats.stime) / 1e6,
+		}, nil
+	}
+	stat, err := s.stat(proc)
+	if err != nil {
+		return nil, err
+	}
+	return &stat.times, nil
+}
+
+func (s *procSnapshot) stat(proc *process.Process) (*procStat, error) {
+	val, err := s.get(proc.Pid, "stat", func() (interface{}, error) {
+		return readProcStat(proc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*procStat), nil
+}
+
+func (s *procSnapshot) ioCounters(proc *process.Process) (*process.IOCountersStat, error) {
+	val, err := s.get(proc.Pid, "io", func() (interface{}, error) {
+		return readProcIOCounters(proc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*process.IOCountersStat), nil
+}
+
+func (s *procSnapshot) memoryInfo(proc *process.Process) (*process.MemoryInfoStat, error) {
+	val, err := s.get(proc.Pid, "statm", func() (interface{}, error) {
+		return readProcMemoryInfo(proc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*process.MemoryInfoStat), nil
+}
+
+func (s *procSnapshot) memoryDetail(pid int32) (*procMemoryDetail, error) {
+	val, err := s.get(pid, "smaps", func() (interface{}, error) {
+		return readProcMemoryDetail(pid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*procMemoryDetail), nil
+}
+
+func (s *procSnapshot) netIOCounters(proc *process.Process) ([]psnet.IOCountersStat, error) {
+	val, err := s.get(proc.Pid, "net/dev", func() (interface{}, error) {
+		return readProcNetIOCounters(proc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.([]psnet.IOCountersStat), nil
+}
+
+func (s *procSnapshot) numFds(pid int32) (int32, error) {
+	val, err := s.get(pid, "fd", func() (interface{}, error) {
+		return readProcNumFds(pid)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return val.(int32), nil
+}
+
+func (s *procSnapshot) status(pid int32) (procStatus, error) {
+	val, err := s.get(pid, "status", func() (interface{}, error) {
+		return readProcStatus(pid)
+	})
+	if err != nil {
+		return procStatus{}, err
+	}
+	return val.(procStatus), nil
+}
+
+func (s *procSnapshot) taskstatsOf(pid int32) (*taskstats, error) {
+	val, err := s.get(pid, "taskstats", func() (interface{}, error) {
+		return s.taskstats.tgidStats(pid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*taskstats), nil
+}
+
+// cgroup returns the cgroup of the given process for the given cgroup v1 controller, see parseProcCgroup()
+func (s *procSnapshot) cgroup(pid int32, controller string) (cgroupDir, error) {
+	content, err := s.cgroupFile(pid)
+	if err != nil {
+		return cgroupDir{}, err
+	}
+	return parseProcCgroup(hostfs.Current().SysPath("fs", "cgroup"), content, controller)
+}
+
+func (s *procSnapshot) cgroupFile(pid int32) (string, error) {
+	val, err := s.get(pid, "cgroup", func() (interface{}, error) {
+		return readProcCgroupFile(pid)
+	})
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+func (s *procSnapshot) socketInodes(pid int32) ([]uint64, error) {
+	val, err := s.get(pid, "fd/sockets", func() (interface{}, error) {
+		return readProcSocketInodes(pid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.([]uint64), nil
+}
+
+// netTables returns the network namespace of the given process, and the socket and connection tracking tables of
+// that namespace. The tables are only read once per round for all processes in the same network namespace.
+func (s *procSnapshot) netTables(pid int32) (string, *procNetTables, error) {
+	netns, err := s.get(pid, "ns/net", func() (interface{}, error) {
+		return readProcNetNamespace(pid)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	// The tables are not specific to the process, use PID 0 to share them within the namespace
+	val, err := s.get(0, "net/conntrack "+netns.(string), func() (interface{}, error) {
+		return readProcNetTables(pid)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return netns.(string), val.(*procNetTables), nil
+}
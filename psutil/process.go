@@ -1,25 +1,56 @@
 package psutil
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 	"github.com/shirou/gopsutil/process"
-	log "github.com/sirupsen/logrus"
 )
 
 var (
-	PidUpdateInterval = 60 * time.Second
-
 	own_pid    = int32(os.Getpid())
 	cpu_factor = 100 / float64(runtime.NumCPU())
 )
 
+// Placeholders in RootCollector.ProcessMetricName
+const (
+	processNameGroup     = "{group}"
+	processNameMetric    = "{metric}"
+	processNamePid       = "{pid}"
+	processNameContainer = "{container}"
+)
+
+// DefaultProcessMetricName is the default value of RootCollector.ProcessMetricName
+const DefaultProcessMetricName = "proc/" + processNameGroup + "/" + processNameMetric
+
+var processNamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ValidateProcessMetricName checks a template for RootCollector.ProcessMetricName. The template must contain
+// the {metric} placeholder, and no unknown placeholders.
+func ValidateProcessMetricName(template string) error {
+	if !strings.Contains(template, processNameMetric) {
+		return fmt.Errorf("The process metric name template '%v' must contain %v", template, processNameMetric)
+	}
+	for _, placeholder := range processNamePlaceholder.FindAllString(template, -1) {
+		switch placeholder {
+		case processNameGroup, processNameMetric, processNamePid, processNameContainer:
+		default:
+			return fmt.Errorf("Unknown placeholder %v in the process metric name template '%v', expected %v, %v, %v or %v",
+				placeholder, template, processNameGroup, processNameMetric, processNamePid, processNameContainer)
+		}
+	}
+	return nil
+}
+
 type ProcessCollector struct {
 	collector.AbstractCollector
 	factory         *collector.ValueRingFactory
@@ -27,11 +58,24 @@ type ProcessCollector struct {
 	groupName       string
 	printErrors     bool
 	includeChildren bool
+	root            *RootCollector
 	pids            *PidCollector
 
+	// Optional, set by NewPidProcessCollector
+	metricPrefix string
+	pidFilter    func(pid int32) bool
+
 	pidsUpdated bool
 	procs       map[int32]*processInfo
 	procsLock   sync.RWMutex
+
+	// Values of the {pid} and {container} placeholders in the metric names, determined in Init()
+	nameValues processNameValues
+}
+
+type processNameValues struct {
+	pid       string
+	container string
 }
 
 func (col *RootCollector) NewProcessCollector(filter []*regexp.Regexp, name string, printErrors bool, includeChildProcesses bool) *ProcessCollector {
@@ -42,10 +86,20 @@ func (col *RootCollector) NewProcessCollector(filter []*regexp.Regexp, name stri
 		printErrors:       printErrors,
 		includeChildren:   includeChildProcesses,
 		factory:           col.Factory,
+		root:              col,
 		pids:              col.pids,
 	}
 }
 
+// NewPidProcessCollector creates a process collector that observes the processes selected by the given filter function,
+// instead of matching their command lines. The metrics are named <prefix>/cpu, <prefix>/mem/rss, etc.
+func (col *RootCollector) NewPidProcessCollector(name string, prefix string, filter func(pid int32) bool, printErrors bool) *ProcessCollector {
+	proc := col.NewProcessCollector(nil, name, printErrors, false)
+	proc.metricPrefix = prefix
+	proc.pidFilter = filter
+	return proc
+}
+
 func (col *RootCollector) NewMultiProcessCollector(name string) *MultiProcessCollector {
 	return &MultiProcessCollector{
 		AbstractCollector: col.Child(name),
@@ -71,7 +125,11 @@ func (multi *MultiProcessCollector) UpdateProcesses() {
 	multi.descriptionsChanged = true
 }
 
-func (multi *MultiProcessCollector) Init() ([]collector.Collector, error) {
+func (multi *MultiProcessCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if template := multi.root.processMetricName(); len(multi.Processes) > 1 && !strings.Contains(template, processNameGroup) {
+		return nil, fmt.Errorf("The metric names of the %v process groups are not unique, the template %v does not contain %v",
+			len(multi.Processes), template, processNameGroup)
+	}
 	cols := make([]collector.Collector, len(multi.Processes))
 	for i, params := range multi.Processes {
 		cols[i] = multi.root.NewProcessCollector(params.Filter, params.Name, params.PrintErrors, params.IncludeChildProcesses)
@@ -84,46 +142,76 @@ func (multi *MultiProcessCollector) Depends() []collector.Collector {
 	return []collector.Collector{multi.root}
 }
 
-func (multi *MultiProcessCollector) Update() error {
+func (multi *MultiProcessCollector) Update(ctx context.Context) error {
 	if multi.descriptionsChanged {
 		return collector.MetricsChanged
 	}
 	return nil
 }
 
-func (multi *MultiProcessCollector) MetricsChanged() error {
-	return multi.Update()
+func (multi *MultiProcessCollector) MetricsChanged(ctx context.Context) error {
+	return multi.Update(ctx)
 }
 
-func (col *ProcessCollector) Init() ([]collector.Collector, error) {
-	return []collector.Collector{
+func (col *ProcessCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if col.dynamicNames() {
+		if err := col.updatePids(ctx); err != nil {
+			return nil, err
+		}
+		col.nameValues = col.currentNameValues()
+	}
+	children := []collector.Collector{
 		col.Child("cpu", new(processCpuCollector)),
 		col.Child("disk", new(processDiskCollector)),
-		col.Child("mem", new(processMemoryCollector)),
+		col.Child("mem", &processMemoryCollector{detail: col.root.MemoryDetail && procMemoryDetailAvailable}),
 		col.Child("net", new(processNetCollector)),
 		col.newProcessPcapCollector(),
 		col.Child("fd", new(processFdCollector)),
 		col.Child("misc", new(processMiscCollector)),
-	}, nil
+	}
+	if col.root.snapshot.taskstats != nil || procStatBlkioDelay {
+		children = append(children, col.Child("delay", new(processDelayCollector)))
+	}
+	if cgroupsAvailable() {
+		children = append(children, col.newProcessBlkioCollector(), col.newProcessCpuLimitsCollector())
+	}
+	if col.root.ConntrackTraffic {
+		children = append(children, col.newProcessConntrackCollector())
+	}
+	return children, nil
 }
 
 func (col *ProcessCollector) Metrics() collector.MetricReaderMap {
 	return collector.MetricReaderMap{
-		col.prefix() + "/num": func() bitflow.Value {
+		col.metricName("num"): func() bitflow.Value {
 			return bitflow.Value(len(col.procs))
 		},
 	}
 }
 
 func (col *ProcessCollector) Depends() []collector.Collector {
-	return []collector.Collector{col.pids}
+	// Depend on the root collector to make sure the /proc snapshot is reset before every update
+	return []collector.Collector{col.pids, col.root}
+}
+
+func (col *ProcessCollector) Update(ctx context.Context) error {
+	if err := col.updatePids(ctx); err != nil {
+		return err
+	}
+	if col.dynamicNames() && col.currentNameValues() != col.nameValues {
+		return collector.MetricsChanged
+	}
+	return nil
 }
 
-func (col *ProcessCollector) Update() error {
-	return col.updatePids()
+func (col *ProcessCollector) MetricsChanged(ctx context.Context) error {
+	if col.dynamicNames() {
+		return col.Update(ctx)
+	}
+	return nil
 }
 
-func (col *ProcessCollector) updatePids() error {
+func (col *ProcessCollector) updatePids(ctx context.Context) error {
 	if col.pidsUpdated {
 		return nil
 	}
@@ -132,25 +220,30 @@ func (col *ProcessCollector) updatePids() error {
 	errors := 0
 	pids := col.pids.pids
 	for _, pid := range pids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if pid == own_pid {
 			continue
 		}
-		proc, err := process.NewProcess(pid)
+		proc, err := openProcess(pid)
 		if err != nil {
 			// Process does not exist anymore
 			errors++
-			if col.printErrors {
-				log.WithField("pid", pid).Warnln("Checking process failed:", err)
+			col.processError(fmt.Errorf("Checking process failed: %v", err))
+			continue
+		}
+		if col.pidFilter != nil {
+			if col.pidFilter(pid) {
+				newProcs[pid] = col.getProcInfo(pid, proc)
 			}
 			continue
 		}
-		cmdline, err := proc.Cmdline()
+		cmdline, err := col.root.snapshot.cmdline(proc)
 		if err != nil {
 			// Probably a permission error
 			errors++
-			if col.printErrors {
-				log.WithField("pid", pid).Warnln("Obtaining process cmdline failed:", err)
-			}
+			col.processError(fmt.Errorf("Obtaining process cmdline failed: %v", err))
 			continue
 		}
 		for _, regex := range col.cmdlineFilter {
@@ -169,15 +262,15 @@ func (col *ProcessCollector) updatePids() error {
 			col.addChildren(proc.Process, newProcs)
 		}
 	}
-	if len(newProcs) == 0 && errors > 0 && col.printErrors {
-		log.Errorln("Warning: Observing no processes, failed to check", errors, "out of", len(pids), "PIDs")
+	if len(newProcs) == 0 && errors > 0 {
+		col.processError(fmt.Errorf("Observing no processes, failed to check %v out of %v PIDs", errors, len(pids)))
 	}
 
 	col.procs = newProcs
 
-	if PidUpdateInterval > 0 {
+	if interval := col.root.PidUpdateInterval; interval > 0 {
 		col.pidsUpdated = true
-		time.AfterFunc(PidUpdateInterval, func() {
+		time.AfterFunc(interval, func() {
 			col.pidsUpdated = false
 		})
 	} else {
@@ -186,6 +279,16 @@ func (col *ProcessCollector) updatePids() error {
 	return nil
 }
 
+// processError reports errors that are expected when processes disappear while being observed.
+// They are only logged if printErrors is set, but always available through collector.RecentErrors().
+func (col *ProcessCollector) processError(err error) {
+	if col.printErrors {
+		collector.ReportError(col.String(), err)
+	} else {
+		collector.RecordError(col.String(), err)
+	}
+}
+
 func (col *ProcessCollector) getProcInfo(pid int32, proc *process.Process) *processInfo {
 	col.procsLock.RLock()
 	procCollector, ok := col.procs[pid]
@@ -202,7 +305,7 @@ func (col *ProcessCollector) addChildren(proc *process.Process, newProcs map[int
 		return
 	}
 	if err != nil {
-		log.WithField("pid", proc.Pid).Warnln("Obtaining child processes of", proc.Pid, "failed:", err)
+		collector.ReportError(col.String(), fmt.Errorf("Obtaining child processes failed: %v", err))
 		return
 	}
 	for _, child := range children {
@@ -216,6 +319,7 @@ func (col *ProcessCollector) addChildren(proc *process.Process, newProcs map[int
 func (col *ProcessCollector) newProcess(proc *process.Process) *processInfo {
 	return &processInfo{
 		Process:              proc,
+		snapshot:             col.root.snapshot,
 		cpu:                  col.factory.NewValueRing(),
 		cpuJiffies:           col.factory.NewValueRing(),
 		ioRead:               col.factory.NewValueRing(),
@@ -226,6 +330,10 @@ func (col *ProcessCollector) newProcess(proc *process.Process) *processInfo {
 		ioBytesTotal:         col.factory.NewValueRing(),
 		ctxSwitchVoluntary:   col.factory.NewValueRing(),
 		ctxSwitchInvoluntary: col.factory.NewValueRing(),
+		cpuDelay:             col.factory.NewValueRing(),
+		blkioDelay:           col.factory.NewValueRing(),
+		swapinDelay:          col.factory.NewValueRing(),
+		freepagesDelay:       col.factory.NewValueRing(),
 		net:                  NewNetIoCounters(col.factory),
 		net_pcap:             NewBaseNetIoCounters(col.factory),
 	}
@@ -259,8 +367,47 @@ func (col *ProcessCollector) netIoSum(getVal func(*processInfo) bitflow.Value) f
 	}
 }
 
-func (col *ProcessCollector) prefix() string {
-	return "proc/" + col.groupName
+// metricName returns the name of the given metric of the process group (e.g. cpu or mem/rss), by default proc/<group>/<metric>.
+// See RootCollector.ProcessMetricName.
+func (col *ProcessCollector) metricName(metric string) string {
+	if col.metricPrefix != "" {
+		return col.metricPrefix + "/" + metric
+	}
+	return strings.NewReplacer(
+		processNameGroup, col.groupName,
+		processNameMetric, metric,
+		processNamePid, col.nameValues.pid,
+		processNameContainer, col.nameValues.container,
+	).Replace(col.root.processMetricName())
+}
+
+// dynamicNames returns true if the metric names depend on the processes in the group
+func (col *ProcessCollector) dynamicNames() bool {
+	template := col.root.processMetricName()
+	return col.metricPrefix == "" && (strings.Contains(template, processNamePid) || strings.Contains(template, processNameContainer))
+}
+
+// currentNameValues returns the values of the {pid} and {container} placeholders for the current processes of the group
+func (col *ProcessCollector) currentNameValues() processNameValues {
+	col.procsLock.RLock()
+	defer col.procsLock.RUnlock()
+	lowest := int32(-1)
+	for pid := range col.procs {
+		if lowest < 0 || pid < lowest {
+			lowest = pid
+		}
+	}
+	if lowest < 0 {
+		return processNameValues{pid: "none", container: "none"}
+	}
+	values := processNameValues{pid: strconv.Itoa(int(lowest)), container: "host"}
+	cgroup, err := col.root.snapshot.cgroupFile(lowest)
+	if err != nil {
+		col.processError(fmt.Errorf("Failed to get container ID: %v", err))
+	} else if id := parseContainerId(cgroup); id != "" {
+		values.container = id
+	}
+	return values
 }
 
 type processSubCollector struct {
@@ -290,8 +437,8 @@ func (col *processSubCollector) Depends() []collector.Collector {
 	return []collector.Collector{col.parent}
 }
 
-func (col *processSubCollector) Update() error {
-	deletedProcesses := col.doUpdate()
+func (col *processSubCollector) Update(ctx context.Context) error {
+	deletedProcesses, err := col.doUpdate(ctx)
 	if len(deletedProcesses) > 0 {
 		col.parent.procsLock.Lock()
 		defer col.parent.procsLock.Unlock()
@@ -299,19 +446,20 @@ func (col *processSubCollector) Update() error {
 			delete(col.parent.procs, pid)
 		}
 	}
-	return nil
+	return err
 }
 
-func (col *processSubCollector) doUpdate() (deletedProcesses []int32) {
+func (col *processSubCollector) doUpdate(ctx context.Context) (deletedProcesses []int32, err error) {
 	col.parent.procsLock.RLock()
 	defer col.parent.procsLock.RUnlock()
 	for pid, proc := range col.parent.procs {
+		if err = ctx.Err(); err != nil {
+			return
+		}
 		if err := col.impl.updateProc(proc); err != nil {
 			// Process probably does not exist anymore
 			deletedProcesses = append(deletedProcesses, pid)
-			if col.parent.printErrors {
-				log.WithField("pid", pid).Warnln("Process info update failed:", err)
-			}
+			col.parent.processError(fmt.Errorf("Process info update failed: %v", err))
 		}
 	}
 	return
@@ -319,6 +467,7 @@ func (col *processSubCollector) doUpdate() (deletedProcesses []int32) {
 
 type processInfo struct {
 	*process.Process
+	snapshot *procSnapshot
 
 	cpu                  *collector.ValueRing
 	cpuJiffies           *collector.ValueRing
@@ -330,11 +479,17 @@ type processInfo struct {
 	ioBytesTotal         *collector.ValueRing
 	ctxSwitchVoluntary   *collector.ValueRing
 	ctxSwitchInvoluntary *collector.ValueRing
+	cpuDelay             *collector.ValueRing
+	blkioDelay           *collector.ValueRing
+	swapinDelay          *collector.ValueRing
+	freepagesDelay       *collector.ValueRing
 	net                  NetIoCounters
 	net_pcap             BaseNetIoCounters
 	mem_rss              uint64
 	mem_vms              uint64
 	mem_swap             uint64
+	mem_pss              uint64
+	mem_uss              uint64
 	numFds               int32
 	numThreads           int32
 }
@@ -0,0 +1,248 @@
+package psutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// processBlkioCollector reports the block IO statistics of the cgroups containing the processes of a ProcessCollector.
+// With cgroup v1, these are the IOs accounted by the throttling policy of the blkio controller. With cgroup v2,
+// the statistics of the io controller are used, and additionally the time during which processes of the cgroup were
+// stalled waiting for IO (from io.pressure, if the kernel supports pressure stall information).
+// Every cgroup is only counted once, even if it contains multiple processes of the group.
+type processBlkioCollector struct {
+	collector.AbstractCollector
+	parent *ProcessCollector
+
+	cgroups map[cgroupDir]*cgroupBlkio
+	lock    sync.RWMutex
+}
+
+type cgroupBlkio struct {
+	read       *collector.ValueRing
+	write      *collector.ValueRing
+	readBytes  *collector.ValueRing
+	writeBytes *collector.ValueRing
+	stall      *collector.ValueRing
+}
+
+// cgroupBlkioStat contains the counters of a cgroup, summed up over all block devices
+type cgroupBlkioStat struct {
+	reads      uint64
+	writes     uint64
+	readBytes  uint64
+	writeBytes uint64
+
+	// Time in microseconds during which at least one process of the cgroup was stalled waiting for IO (cgroup v2 only)
+	stallTime uint64
+}
+
+func (col *ProcessCollector) newProcessBlkioCollector() *processBlkioCollector {
+	return &processBlkioCollector{
+		AbstractCollector: col.AbstractCollector.Child("blkio"),
+		parent:            col,
+	}
+}
+
+func (col *processBlkioCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *processBlkioCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		col.parent.metricName("blkio/read"): col.sum(func(cgroup *cgroupBlkio) bitflow.Value {
+			return cgroup.read.GetDiff()
+		}),
+		col.parent.metricName("blkio/write"): col.sum(func(cgroup *cgroupBlkio) bitflow.Value {
+			return cgroup.write.GetDiff()
+		}),
+		col.parent.metricName("blkio/readBytes"): col.sum(func(cgroup *cgroupBlkio) bitflow.Value {
+			return cgroup.readBytes.GetDiff()
+		}),
+		col.parent.metricName("blkio/writeBytes"): col.sum(func(cgroup *cgroupBlkio) bitflow.Value {
+			return cgroup.writeBytes.GetDiff()
+		}),
+		col.parent.metricName("blkio/stall"): col.sum(func(cgroup *cgroupBlkio) bitflow.Value {
+			return cgroup.stall.GetDiff()
+		}),
+	}
+}
+
+func (col *processBlkioCollector) sum(getVal func(*cgroupBlkio) bitflow.Value) func() bitflow.Value {
+	return func() (res bitflow.Value) {
+		col.lock.RLock()
+		defer col.lock.RUnlock()
+		for _, cgroup := range col.cgroups {
+			res += getVal(cgroup)
+		}
+		return
+	}
+}
+
+func (col *processBlkioCollector) Update(ctx context.Context) error {
+	cgroups := col.parent.cgroups("blkio")
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	newCgroups := make(map[cgroupDir]*cgroupBlkio, len(cgroups))
+	for cgroup := range cgroups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var stat cgroupBlkioStat
+		var err error
+		if cgroup.v2 {
+			stat, err = readCgroupV2Blkio(cgroup.dir)
+		} else {
+			stat, err = readCgroupV1Blkio(cgroup.dir)
+		}
+		if err != nil {
+			col.parent.processError(fmt.Errorf("Failed to read blkio statistics of cgroup %v: %v", cgroup.dir, err))
+			continue
+		}
+		values, ok := col.cgroups[cgroup]
+		if !ok {
+			factory := col.parent.factory
+			values = &cgroupBlkio{
+				read:       factory.NewValueRing(),
+				write:      factory.NewValueRing(),
+				readBytes:  factory.NewValueRing(),
+				writeBytes: factory.NewValueRing(),
+				stall:      factory.NewValueRing(),
+			}
+		}
+		values.read.Add(collector.StoredValue(stat.reads))
+		values.write.Add(collector.StoredValue(stat.writes))
+		values.readBytes.Add(collector.StoredValue(stat.readBytes))
+		values.writeBytes.Add(collector.StoredValue(stat.writeBytes))
+		// Convert the stall time from microseconds to milliseconds, resulting in ms/sec values
+		values.stall.Add(collector.StoredValue(float64(stat.stallTime) / 1000))
+		newCgroups[cgroup] = values
+	}
+	col.cgroups = newCgroups
+	return nil
+}
+
+func readCgroupV1Blkio(dir string) (cgroupBlkioStat, error) {
+	var stat cgroupBlkioStat
+	var err error
+	stat.readBytes, stat.writeBytes, err = readBlkioThrottleFile(filepath.Join(dir, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return cgroupBlkioStat{}, err
+	}
+	stat.reads, stat.writes, err = readBlkioThrottleFile(filepath.Join(dir, "blkio.throttle.io_serviced"))
+	if err != nil {
+		return cgroupBlkioStat{}, err
+	}
+	return stat, nil
+}
+
+// readBlkioThrottleFile sums up the Read and Write values of all devices in the given blkio.throttle.* file.
+// Every device has the lines <major>:<minor> Read|Write|Sync|Async|Discard|Total <value>, the file ends
+// with the line Total <value>.
+func readBlkioThrottleFile(filename string) (read uint64, write uint64, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		var sum *uint64
+		switch fields[1] {
+		case "Read":
+			sum = &read
+		case "Write":
+			sum = &write
+		default:
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+		*sum += value
+	}
+	return read, write, nil
+}
+
+// readCgroupV2Blkio sums up the counters of all devices in the io.stat file of the given cgroup, which has lines like
+// <major>:<minor> rbytes=<value> wbytes=<value> rios=<value> wios=<value> ... The io.pressure file is optional.
+func readCgroupV2Blkio(dir string) (cgroupBlkioStat, error) {
+	filename := filepath.Join(dir, "io.stat")
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return cgroupBlkioStat{}, err
+	}
+	var stat cgroupBlkioStat
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			index := strings.IndexByte(field, '=')
+			if index < 0 {
+				continue
+			}
+			var sum *uint64
+			switch field[:index] {
+			case "rbytes":
+				sum = &stat.readBytes
+			case "wbytes":
+				sum = &stat.writeBytes
+			case "rios":
+				sum = &stat.reads
+			case "wios":
+				sum = &stat.writes
+			default:
+				continue
+			}
+			value, err := strconv.ParseUint(field[index+1:], 10, 64)
+			if err != nil {
+				return cgroupBlkioStat{}, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+			}
+			*sum += value
+		}
+	}
+	stat.stallTime, err = readIoPressureFile(filepath.Join(dir, "io.pressure"))
+	if err != nil && !os.IsNotExist(err) {
+		return cgroupBlkioStat{}, err
+	}
+	return stat, nil
+}
+
+// readIoPressureFile returns the total stall time in microseconds of the "some" line in the given io.pressure file,
+// e.g. some avg10=0.00 avg60=0.00 avg300=0.00 total=12345
+func readIoPressureFile(filename string) (uint64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "total=") {
+				total, err := strconv.ParseUint(strings.TrimPrefix(field, "total="), 10, 64)
+				if err != nil {
+					return 0, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+				}
+				return total, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%v is not formatted correctly, missing total stall time", filename)
+}
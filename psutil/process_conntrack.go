@@ -0,0 +1,360 @@
+package psutil
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// processConntrackCollector reports the network traffic of the TCP and UDP sockets owned by the processes of a
+// ProcessCollector. The sockets are identified through the /proc/<pid>/fd links and resolved to their addresses
+// through /proc/<pid>/net/{tcp,udp,tcp6,udp6}. The byte and packet counters of the connections are taken from the
+// connection tracking table of netfilter (/proc/<pid>/net/nf_conntrack), which requires the nf_conntrack kernel module
+// and enabled accounting (sysctl net.netfilter.nf_conntrack_acct=1).
+// Unlike the net-io metrics, which contain the traffic of the entire network namespace of the processes, this is only
+// the traffic of the processes themselves. Unconnected UDP sockets and connections bound to a wildcard address cannot
+// be matched, and the traffic of connections closed between two updates is not counted.
+type processConntrackCollector struct {
+	collector.AbstractCollector
+	parent *ProcessCollector
+
+	// The counters of the connections in the previous update, to obtain the traffic since then
+	connections map[netnsConnection]conntrackCounters
+	total       conntrackCounters
+
+	rxBytes   *collector.ValueRing
+	rxPackets *collector.ValueRing
+	txBytes   *collector.ValueRing
+	txPackets *collector.ValueRing
+}
+
+// connTuple identifies a TCP or UDP connection from the point of view of a local socket
+type connTuple struct {
+	proto  string
+	local  string
+	lport  uint16
+	remote string
+	rport  uint16
+}
+
+// netnsConnection identifies a connection across network namespaces
+type netnsConnection struct {
+	netns string
+	connTuple
+}
+
+// conntrackCounters contains the traffic of a connection, received and sent by the local socket
+type conntrackCounters struct {
+	rxBytes   uint64
+	rxPackets uint64
+	txBytes   uint64
+	txPackets uint64
+}
+
+func (c *conntrackCounters) add(other conntrackCounters) {
+	c.rxBytes += other.rxBytes
+	c.rxPackets += other.rxPackets
+	c.txBytes += other.txBytes
+	c.txPackets += other.txPackets
+}
+
+// since returns the traffic since the given previous counters of the same connection. If any counter decreased,
+// the connection was closed and its tuple was reused by a new connection.
+func (c conntrackCounters) since(previous conntrackCounters) conntrackCounters {
+	if c.rxBytes < previous.rxBytes || c.rxPackets < previous.rxPackets ||
+		c.txBytes < previous.txBytes || c.txPackets < previous.txPackets {
+		return c
+	}
+	return conntrackCounters{
+		rxBytes:   c.rxBytes - previous.rxBytes,
+		rxPackets: c.rxPackets - previous.rxPackets,
+		txBytes:   c.txBytes - previous.txBytes,
+		txPackets: c.txPackets - previous.txPackets,
+	}
+}
+
+// procNetTables contains the connected sockets (by inode) and the tracked connections of a network namespace
+type procNetTables struct {
+	sockets   map[uint64]connTuple
+	conntrack map[connTuple]conntrackCounters
+}
+
+func (col *ProcessCollector) newProcessConntrackCollector() *processConntrackCollector {
+	return &processConntrackCollector{
+		AbstractCollector: col.AbstractCollector.Child("conntrack"),
+		parent:            col,
+		rxBytes:           col.factory.NewValueRing(),
+		rxPackets:         col.factory.NewValueRing(),
+		txBytes:           col.factory.NewValueRing(),
+		txPackets:         col.factory.NewValueRing(),
+	}
+}
+
+func (col *processConntrackCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	data, err := ioutil.ReadFile(hostfs.Current().ProcPath("sys", "net", "netfilter", "nf_conntrack_acct"))
+	if os.IsNotExist(err) {
+		return nil, errors.New("Connection tracking is not available, the nf_conntrack kernel module is not loaded")
+	} else if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(data)) != "1" {
+		return nil, errors.New("Connection tracking accounting is disabled, enable it with sysctl net.netfilter.nf_conntrack_acct=1")
+	}
+	return nil, nil
+}
+
+func (col *processConntrackCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *processConntrackCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		col.parent.metricName("net-conntrack/bytes"): func() bitflow.Value {
+			return col.rxBytes.GetDiff() + col.txBytes.GetDiff()
+		},
+		col.parent.metricName("net-conntrack/packets"): func() bitflow.Value {
+			return col.rxPackets.GetDiff() + col.txPackets.GetDiff()
+		},
+		col.parent.metricName("net-conntrack/rx_bytes"):   col.rxBytes.GetDiff,
+		col.parent.metricName("net-conntrack/rx_packets"): col.rxPackets.GetDiff,
+		col.parent.metricName("net-conntrack/tx_bytes"):   col.txBytes.GetDiff,
+		col.parent.metricName("net-conntrack/tx_packets"): col.txPackets.GetDiff,
+	}
+}
+
+func (col *processConntrackCollector) Update(ctx context.Context) error {
+	connections := col.parent.connections()
+	for con, counters := range connections {
+		col.total.add(counters.since(col.connections[con]))
+	}
+	col.connections = connections
+	col.rxBytes.Add(collector.StoredValue(col.total.rxBytes))
+	col.rxPackets.Add(collector.StoredValue(col.total.rxPackets))
+	col.txBytes.Add(collector.StoredValue(col.total.txBytes))
+	col.txPackets.Add(collector.StoredValue(col.total.txPackets))
+	return nil
+}
+
+// connections returns the current counters of the tracked connections of all sockets owned by the processes of
+// the collector. Errors are expected for processes that disappear and are only reported through processError().
+func (col *ProcessCollector) connections() map[netnsConnection]conntrackCounters {
+	col.procsLock.RLock()
+	defer col.procsLock.RUnlock()
+	res := make(map[netnsConnection]conntrackCounters)
+	for pid := range col.procs {
+		netns, tables, err := col.root.snapshot.netTables(pid)
+		if err != nil {
+			col.processError(fmt.Errorf("Failed to read connection tracking table: %v", err))
+			continue
+		}
+		inodes, err := col.root.snapshot.socketInodes(pid)
+		if err != nil {
+			col.processError(fmt.Errorf("Failed to read sockets: %v", err))
+			continue
+		}
+		for _, inode := range inodes {
+			if tuple, ok := tables.sockets[inode]; ok {
+				if counters, ok := tables.conntrack[tuple]; ok {
+					res[netnsConnection{netns: netns, connTuple: tuple}] = counters
+				}
+			}
+		}
+	}
+	return res
+}
+
+// readProcNetTables reads the socket and connection tracking tables of the network namespace of the given process
+func readProcNetTables(pid int32) (*procNetTables, error) {
+	tables := &procNetTables{sockets: make(map[uint64]connTuple)}
+	for _, file := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		filename := hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "net", file)
+		err := readProcNetSocketsFile(filename, strings.TrimSuffix(file, "6"), tables.sockets)
+		if err != nil && !(os.IsNotExist(err) && strings.HasSuffix(file, "6")) {
+			// The IPv6 tables are missing if IPv6 is disabled
+			return nil, err
+		}
+	}
+	conntrack, err := readConntrackFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "net", "nf_conntrack"))
+	if err != nil {
+		return nil, err
+	}
+	tables.conntrack = conntrack
+	return tables, nil
+}
+
+// readProcSocketInodes returns the inodes of all sockets in the file descriptor table of the given process
+func readProcSocketInodes(pid int32) ([]uint64, error) {
+	dir := hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "fd")
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []uint64
+	for _, name := range names {
+		link, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			// The file descriptor was closed in the meantime
+			continue
+		}
+		if strings.HasPrefix(link, "socket:[") && strings.HasSuffix(link, "]") {
+			if inode, err := strconv.ParseUint(link[len("socket:["):len(link)-1], 10, 64); err == nil {
+				res = append(res, inode)
+			}
+		}
+	}
+	return res, nil
+}
+
+func readProcNetNamespace(pid int32) (string, error) {
+	return os.Readlink(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "ns", "net"))
+}
+
+// readProcNetSocketsFile adds the connected sockets in the given /proc/net/{tcp,udp,tcp6,udp6} file to the given
+// map, indexed by their inodes. The lines have the format:
+// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+func readProcNetSocketsFile(filename string, proto string, sockets map[uint64]connTuple) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 10 {
+			return fmt.Errorf("%v is not formatted correctly in line %v", filename, i+2)
+		}
+		local, lport, err := parseProcNetAddress(fields[1])
+		if err != nil {
+			return fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+2, err)
+		}
+		remote, rport, err := parseProcNetAddress(fields[2])
+		if err != nil {
+			return fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+2, err)
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+2, err)
+		}
+		if rport == 0 || inode == 0 {
+			// Listening or unconnected socket, or connection without a socket (e.g. in TIME_WAIT state)
+			continue
+		}
+		sockets[inode] = connTuple{proto: proto, local: local, lport: lport, remote: remote, rport: rport}
+	}
+	return nil
+}
+
+// parseProcNetAddress parses an address like 0100007F:0016. The IP address consists of 32 bit words in host byte order,
+// which is assumed to be little endian.
+func parseProcNetAddress(address string) (string, uint16, error) {
+	index := strings.IndexByte(address, ':')
+	if index < 0 {
+		return "", 0, fmt.Errorf("Invalid address: %v", address)
+	}
+	ip, err := hex.DecodeString(address[:index])
+	if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return "", 0, fmt.Errorf("Invalid IP address: %v", address)
+	}
+	for word := 0; word < len(ip); word += 4 {
+		ip[word], ip[word+1], ip[word+2], ip[word+3] = ip[word+3], ip[word+2], ip[word+1], ip[word]
+	}
+	port, err := strconv.ParseUint(address[index+1:], 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid port: %v", address)
+	}
+	return net.IP(ip).String(), uint16(port), nil
+}
+
+// readConntrackFile reads the TCP and UDP connections in the given nf_conntrack file. Every connection is indexed twice:
+// by its original tuple, as seen by the initiating socket, and by its reply tuple, as seen by the responding socket.
+// The lines have the format:
+// ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=5555 dport=22 packets=10 bytes=1000
+// src=10.0.0.1 dst=10.0.0.2 sport=22 dport=5555 packets=8 bytes=900 [ASSURED] mark=0 use=2
+// The packets and bytes fields are only present with enabled accounting.
+func readConntrackFile(filename string) (map[connTuple]conntrackCounters, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	type direction struct {
+		tuple          connTuple
+		packets, bytes uint64
+	}
+	res := make(map[connTuple]conntrackCounters)
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (fields[2] != "tcp" && fields[2] != "udp") {
+			continue
+		}
+		var dirs [2]direction
+		dir := -1
+		for _, field := range fields[3:] {
+			index := strings.IndexByte(field, '=')
+			if index < 0 {
+				continue
+			}
+			key, value := field[:index], field[index+1:]
+			if key == "src" {
+				// The original tuple is followed by the reply tuple
+				if dir++; dir >= len(dirs) {
+					break
+				}
+			}
+			if dir < 0 {
+				continue
+			}
+			var err error
+			d := &dirs[dir]
+			switch key {
+			case "src":
+				d.tuple.local = net.ParseIP(value).String()
+			case "dst":
+				d.tuple.remote = net.ParseIP(value).String()
+			case "sport":
+				var port uint64
+				port, err = strconv.ParseUint(value, 10, 16)
+				d.tuple.lport = uint16(port)
+			case "dport":
+				var port uint64
+				port, err = strconv.ParseUint(value, 10, 16)
+				d.tuple.rport = uint16(port)
+			case "packets":
+				d.packets, err = strconv.ParseUint(value, 10, 64)
+			case "bytes":
+				d.bytes, err = strconv.ParseUint(value, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+			}
+		}
+		if dir < 1 {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v: missing reply tuple", filename, i+1)
+		}
+		orig, reply := dirs[0], dirs[1]
+		orig.tuple.proto, reply.tuple.proto = fields[2], fields[2]
+		res[orig.tuple] = conntrackCounters{txBytes: orig.bytes, txPackets: orig.packets, rxBytes: reply.bytes, rxPackets: reply.packets}
+		res[reply.tuple] = conntrackCounters{txBytes: reply.bytes, txPackets: reply.packets, rxBytes: orig.bytes, rxPackets: orig.packets}
+	}
+	return res, nil
+}
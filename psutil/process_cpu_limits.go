@@ -0,0 +1,244 @@
+package psutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// processCpuLimitsCollector reports how the CPU usage of the processes of a ProcessCollector is limited: the CFS
+// throttling of their cpu cgroups, the effective CPU quota and the number of CPUs they are allowed to run on.
+// The quota is given in the unit of the cpu metric of the process group (percent of all CPUs of the host), so that both
+// can be compared directly. It is the sum of the quotas of all cgroups (unlimited cgroups count as all CPUs), limited by
+// the CPU affinity of the processes. Every cgroup is only counted once, even if it contains multiple processes of the group.
+type processCpuLimitsCollector struct {
+	collector.AbstractCollector
+	parent *ProcessCollector
+
+	cgroups  map[cgroupDir]*cgroupCpu
+	quota    float64
+	affinity int
+	lock     sync.RWMutex
+}
+
+type cgroupCpu struct {
+	throttled     *collector.ValueRing
+	throttledTime *collector.ValueRing
+}
+
+// cgroupCpuStat contains the CFS bandwidth control statistics and settings of a cgroup
+type cgroupCpuStat struct {
+	// Number of periods in which the cgroup was throttled
+	throttled uint64
+
+	// Time in nanoseconds during which the cgroup was throttled
+	throttledTime uint64
+
+	// Number of CPUs the cgroup can use per period, 0 if unlimited
+	quota float64
+}
+
+func (col *ProcessCollector) newProcessCpuLimitsCollector() *processCpuLimitsCollector {
+	return &processCpuLimitsCollector{
+		AbstractCollector: col.AbstractCollector.Child("cpu-limits"),
+		parent:            col,
+	}
+}
+
+func (col *processCpuLimitsCollector) Depends() []collector.Collector {
+	return []collector.Collector{col.parent}
+}
+
+func (col *processCpuLimitsCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		col.parent.metricName("cpu/throttled"): col.sum(func(cgroup *cgroupCpu) bitflow.Value {
+			return cgroup.throttled.GetDiff()
+		}),
+		col.parent.metricName("cpu/throttledTime"): col.sum(func(cgroup *cgroupCpu) bitflow.Value {
+			return cgroup.throttledTime.GetDiff()
+		}),
+		col.parent.metricName("cpu/quota"): func() bitflow.Value {
+			col.lock.RLock()
+			defer col.lock.RUnlock()
+			return bitflow.Value(col.quota)
+		},
+		col.parent.metricName("cpu/affinity"): func() bitflow.Value {
+			col.lock.RLock()
+			defer col.lock.RUnlock()
+			return bitflow.Value(col.affinity)
+		},
+	}
+}
+
+func (col *processCpuLimitsCollector) sum(getVal func(*cgroupCpu) bitflow.Value) func() bitflow.Value {
+	return func() (res bitflow.Value) {
+		col.lock.RLock()
+		defer col.lock.RUnlock()
+		for _, cgroup := range col.cgroups {
+			res += getVal(cgroup)
+		}
+		return
+	}
+}
+
+func (col *processCpuLimitsCollector) Update(ctx context.Context) error {
+	cgroups := col.parent.cgroups("cpu")
+	affinity := col.updateAffinity()
+	numCpu := runtime.NumCPU()
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	newCgroups := make(map[cgroupDir]*cgroupCpu, len(cgroups))
+	quota := 0.0
+	for cgroup := range cgroups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var stat cgroupCpuStat
+		var err error
+		if cgroup.v2 {
+			stat, err = readCgroupV2Cpu(cgroup.dir)
+		} else {
+			stat, err = readCgroupV1Cpu(cgroup.dir)
+		}
+		if err != nil {
+			col.parent.processError(fmt.Errorf("Failed to read CPU statistics of cgroup %v: %v", cgroup.dir, err))
+			continue
+		}
+		values, ok := col.cgroups[cgroup]
+		if !ok {
+			values = &cgroupCpu{
+				throttled:     col.parent.factory.NewValueRing(),
+				throttledTime: col.parent.factory.NewValueRing(),
+			}
+		}
+		values.throttled.Add(collector.StoredValue(stat.throttled))
+		// Convert the throttled time from nanoseconds to milliseconds, resulting in ms/sec values
+		values.throttledTime.Add(collector.StoredValue(float64(stat.throttledTime) / 1e6))
+		newCgroups[cgroup] = values
+		if stat.quota > 0 {
+			quota += stat.quota
+		} else {
+			quota += float64(numCpu)
+		}
+	}
+	if len(newCgroups) == 0 {
+		// No cgroup information, the processes are only limited by their affinity
+		quota = float64(numCpu)
+	}
+	// Without any processes, the affinity is zero, and so is the quota
+	quota = math.Min(math.Min(quota, float64(numCpu)), float64(affinity))
+	col.cgroups = newCgroups
+	col.quota = quota * cpu_factor
+	col.affinity = affinity
+	return nil
+}
+
+// updateAffinity returns the number of CPUs that any of the processes is allowed to run on
+func (col *processCpuLimitsCollector) updateAffinity() int {
+	col.parent.procsLock.RLock()
+	defer col.parent.procsLock.RUnlock()
+	cpus := make(map[int]bool)
+	for pid := range col.parent.procs {
+		status, err := col.parent.root.snapshot.status(pid)
+		if err != nil {
+			col.parent.processError(fmt.Errorf("Failed to get CPU affinity: %v", err))
+			continue
+		}
+		if len(status.cpusAllowed) == 0 {
+			// Cpus_allowed_list is not available in kernels before 2.6.26
+			return runtime.NumCPU()
+		}
+		for _, cpu := range status.cpusAllowed {
+			cpus[cpu] = true
+		}
+	}
+	return len(cpus)
+}
+
+// readCgroupV1Cpu reads the cpu.stat file and the CFS quota and period of the given cgroup of the cgroup v1 cpu controller
+func readCgroupV1Cpu(dir string) (cgroupCpuStat, error) {
+	stats, err := readCgroupStatFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return cgroupCpuStat{}, err
+	}
+	res := cgroupCpuStat{
+		throttled:     stats["nr_throttled"],
+		throttledTime: stats["throttled_time"],
+	}
+	quota, err := readCgroupIntFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return cgroupCpuStat{}, err
+	}
+	if quota > 0 {
+		period, err := readCgroupIntFile(filepath.Join(dir, "cpu.cfs_period_us"))
+		if err != nil {
+			return cgroupCpuStat{}, err
+		}
+		if period > 0 {
+			res.quota = float64(quota) / float64(period)
+		}
+	}
+	return res, nil
+}
+
+// readCgroupV2Cpu reads the cpu.stat and cpu.max files of the given cgroup of the cgroup v2 cpu controller.
+// The root cgroup has no cpu.max file and no throttling statistics.
+func readCgroupV2Cpu(dir string) (cgroupCpuStat, error) {
+	stats, err := readCgroupStatFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return cgroupCpuStat{}, err
+	}
+	res := cgroupCpuStat{
+		throttled:     stats["nr_throttled"],
+		throttledTime: stats["throttled_usec"] * 1000,
+	}
+	filename := filepath.Join(dir, "cpu.max")
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return res, nil
+	} else if err != nil {
+		return cgroupCpuStat{}, err
+	}
+	// Format: <quota>|max <period>
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return cgroupCpuStat{}, fmt.Errorf("%v is not formatted correctly", filename)
+	}
+	if fields[0] != "max" {
+		quota, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return cgroupCpuStat{}, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+		}
+		period, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return cgroupCpuStat{}, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+		}
+		if period > 0 {
+			res.quota = float64(quota) / float64(period)
+		}
+	}
+	return res, nil
+}
+
+func readCgroupIntFile(filename string) (int64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,240 @@
+package psutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/shirou/gopsutil/process"
+	log "github.com/sirupsen/logrus"
+)
+
+// Default values for the respective fields of newly created ProcessDiscoveryCollector instances
+var (
+	DiscoveryInterval      = 10 * time.Second
+	DiscoveryIdleThreshold = 1.0
+	DiscoveryRetireAfter   = 10 * time.Minute
+)
+
+// discoveredGroupPrefix is prepended to the names of the discovered process groups, to avoid conflicts with configured groups
+const discoveredGroupPrefix = "auto/"
+
+// ProcessDiscoveryCollector automatically creates process groups for the executables with the highest CPU usage,
+// without configured command line regexes. All processes with the same executable name (/proc/<pid>/comm) form
+// one group, named auto/<executable>, which has the same metrics as the configured process groups. Kernel threads
+// are ignored. The CPU usage of all executables is ranked every Interval, and executables above IdleThreshold are
+// added as groups until MaxGroups is reached. Groups are retired after staying below IdleThreshold for RetireAfter,
+// which makes room for new groups. The metric collection is restarted whenever groups are added or retired.
+type ProcessDiscoveryCollector struct {
+	collector.AbstractCollector
+	root *RootCollector
+
+	// Maximum number of discovered process groups
+	MaxGroups int
+
+	// Interval for ranking the CPU usage of the executables
+	Interval time.Duration
+
+	// CPU usage below which a group is considered idle, in the unit of the cpu metric (percent of all CPUs of the host)
+	IdleThreshold float64
+
+	// Duration after which idle groups are retired
+	RetireAfter time.Duration
+
+	lock        sync.Mutex
+	groups      map[string]*discoveredGroup
+	cpuTimes    map[int32]float64 // Busy CPU seconds of all processes at the last ranking
+	lastRanking time.Time
+}
+
+type discoveredGroup struct {
+	lastActive time.Time
+}
+
+func (col *RootCollector) NewProcessDiscoveryCollector(maxGroups int) *ProcessDiscoveryCollector {
+	return &ProcessDiscoveryCollector{
+		AbstractCollector: col.Child("discovery"),
+		root:              col,
+		MaxGroups:         maxGroups,
+		Interval:          DiscoveryInterval,
+		IdleThreshold:     DiscoveryIdleThreshold,
+		RetireAfter:       DiscoveryRetireAfter,
+		groups:            make(map[string]*discoveredGroup),
+	}
+}
+
+func (col *ProcessDiscoveryCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if col.MaxGroups <= 0 {
+		return nil, fmt.Errorf("The maximum number of discovered process groups must be positive, but is %v", col.MaxGroups)
+	}
+	if template := col.root.processMetricName(); !strings.Contains(template, processNameGroup) {
+		return nil, fmt.Errorf("The metric names of discovered process groups are not unique, the template %v does not contain %v",
+			template, processNameGroup)
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	names := make([]string, 0, len(col.groups))
+	for name := range col.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]collector.Collector, len(names))
+	for i, name := range names {
+		name := name
+		proc := col.root.NewProcessCollector(nil, discoveredGroupPrefix+name, false, false)
+		proc.pidFilter = func(pid int32) bool {
+			_, executable, ok := col.executable(pid)
+			return ok && executable == name
+		}
+		res[i] = proc
+	}
+	return res, nil
+}
+
+func (col *ProcessDiscoveryCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		"proc-discovery/groups": func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(len(col.groups))
+		},
+	}
+}
+
+func (col *ProcessDiscoveryCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"proc-discovery/groups": collector.GaugeMetric(collector.UnitCount, "Number of automatically discovered process groups"),
+	}
+}
+
+func (col *ProcessDiscoveryCollector) Depends() []collector.Collector {
+	// Depend on the root collector to make sure the /proc snapshot is reset before every update
+	return []collector.Collector{col.root.pids, col.root}
+}
+
+func (col *ProcessDiscoveryCollector) Update(ctx context.Context) error {
+	now := time.Now()
+	if !col.lastRanking.IsZero() && now.Sub(col.lastRanking) < col.Interval {
+		return nil
+	}
+	usage, err := col.measureUsage(ctx, now)
+	if err != nil || usage == nil {
+		return err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	if col.updateGroups(usage, now) {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *ProcessDiscoveryCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+// measureUsage returns the CPU usage of all executables since the last ranking, or nil for the first ranking
+func (col *ProcessDiscoveryCollector) measureUsage(ctx context.Context, now time.Time) (map[string]float64, error) {
+	times := make(map[int32]float64, len(col.cpuTimes))
+	busyTimes := make(map[string]float64)
+	for _, pid := range col.root.pids.pids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if pid == own_pid {
+			continue
+		}
+		proc, executable, ok := col.executable(pid)
+		if !ok {
+			continue
+		}
+		cpu, err := col.root.snapshot.times(proc)
+		if err != nil {
+			// Process does not exist anymore
+			continue
+		}
+		busy := cpu.Total() - cpu.Idle
+		times[pid] = busy
+		// Processes started since the last ranking count with their entire CPU time
+		busyTimes[executable] += busy - col.cpuTimes[pid]
+	}
+	first := col.lastRanking.IsZero()
+	elapsed := now.Sub(col.lastRanking).Seconds()
+	col.cpuTimes = times
+	col.lastRanking = now
+	if first || elapsed <= 0 {
+		return nil, nil
+	}
+	for executable, busy := range busyTimes {
+		busyTimes[executable] = busy / elapsed * cpu_factor
+	}
+	return busyTimes, nil
+}
+
+// updateGroups retires the groups that have been idle for RetireAfter and adds the executables with the highest
+// CPU usage as new groups, up to MaxGroups. Returns true if groups were added or retired.
+func (col *ProcessDiscoveryCollector) updateGroups(usage map[string]float64, now time.Time) bool {
+	changed := false
+	for name, group := range col.groups {
+		if usage[name] >= col.IdleThreshold {
+			group.lastActive = now
+		} else if now.Sub(group.lastActive) >= col.RetireAfter {
+			log.Printf("Retiring process group %v%v, idle since %v", discoveredGroupPrefix, name, group.lastActive.Format(time.RFC3339))
+			delete(col.groups, name)
+			changed = true
+		}
+	}
+
+	var candidates []string
+	for name, cpu := range usage {
+		if _, ok := col.groups[name]; !ok && cpu >= col.IdleThreshold {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := usage[candidates[i]], usage[candidates[j]]
+		return a > b || (a == b && candidates[i] < candidates[j])
+	})
+	for _, name := range candidates {
+		if len(col.groups) >= col.MaxGroups {
+			break
+		}
+		log.Printf("Discovered process group %v%v with %.2f%% CPU usage", discoveredGroupPrefix, name, usage[name])
+		col.groups[name] = &discoveredGroup{lastActive: now}
+		changed = true
+	}
+	return changed
+}
+
+// executable returns the process with the given PID and the group name of its executable.
+// Returns false for kernel threads, which have an empty command line, and for processes that cannot be read.
+func (col *ProcessDiscoveryCollector) executable(pid int32) (*process.Process, string, bool) {
+	proc, err := openProcess(pid)
+	if err != nil {
+		return nil, "", false
+	}
+	if cmdline, err := col.root.snapshot.cmdline(proc); err != nil || cmdline == "" {
+		return nil, "", false
+	}
+	comm, err := col.root.snapshot.comm(pid)
+	if err != nil || comm == "" {
+		return nil, "", false
+	}
+	return proc, discoveredGroupName(comm), true
+}
+
+// discoveredGroupName replaces the characters of an executable name that are not suitable for metric names
+func discoveredGroupName(executable string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, executable)
+}
@@ -8,8 +8,8 @@ import (
 	"strings"
 
 	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
 	"github.com/bitflow-stream/go-bitflow/bitflow"
-	"github.com/shirou/gopsutil/process"
 )
 
 type processCpuCollector struct {
@@ -17,11 +17,11 @@ type processCpuCollector struct {
 
 func (col *processCpuCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
 	return collector.MetricReaderMap{
-		parent.prefix() + "/cpu": parent.sum(
+		parent.metricName("cpu"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.cpu.GetDiff()
 			}),
-		parent.prefix() + "/cpu-jiffies": parent.sum(
+		parent.metricName("cpu-jiffies"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.cpuJiffies.GetDiff()
 			}),
@@ -29,7 +29,7 @@ func (col *processCpuCollector) metrics(parent *ProcessCollector) collector.Metr
 }
 
 func (col *processCpuCollector) updateProc(info *processInfo) error {
-	if cpu, err := info.Times(); err != nil {
+	if cpu, err := info.snapshot.times(info.Process); err != nil {
 		return fmt.Errorf("Failed to get CPU info: %v", err)
 	} else {
 		busy := cpu.Total() - cpu.Idle
@@ -43,29 +43,28 @@ type processDiskCollector struct {
 }
 
 func (col *processDiskCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
-	prefix := parent.prefix()
 	return collector.MetricReaderMap{
-		prefix + "/disk/read": parent.sum(
+		parent.metricName("disk/read"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ioRead.GetDiff()
 			}),
-		prefix + "/disk/write": parent.sum(
+		parent.metricName("disk/write"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ioWrite.GetDiff()
 			}),
-		prefix + "/disk/io": parent.sum(
+		parent.metricName("disk/io"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ioTotal.GetDiff()
 			}),
-		prefix + "/disk/readBytes": parent.sum(
+		parent.metricName("disk/readBytes"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ioReadBytes.GetDiff()
 			}),
-		prefix + "/disk/writeBytes": parent.sum(
+		parent.metricName("disk/writeBytes"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ioWriteBytes.GetDiff()
 			}),
-		prefix + "/disk/ioBytes": parent.sum(
+		parent.metricName("disk/ioBytes"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ioBytesTotal.GetDiff()
 			}),
@@ -73,7 +72,7 @@ func (col *processDiskCollector) metrics(parent *ProcessCollector) collector.Met
 }
 
 func (col *processDiskCollector) updateProc(info *processInfo) error {
-	if io, err := info.IOCounters(); err != nil {
+	if io, err := info.snapshot.ioCounters(info.Process); err != nil {
 		return fmt.Errorf("Failed to get disk-IO info: %v", err)
 	} else {
 		info.ioRead.Add(collector.StoredValue(io.ReadCount))
@@ -86,36 +85,60 @@ func (col *processDiskCollector) updateProc(info *processInfo) error {
 	return nil
 }
 
+// processMemoryCollector reports the memory usage of the processes. If detail is set, the proportional (PSS) and
+// unique (USS) memory is reported as well, and the swap usage is read from /proc/<pid>/smaps_rollup.
+// Unlike the RSS, the PSS and USS do not count the pages shared by multiple processes (e.g. forked workers)
+// multiple times when summed up for a process group.
 type processMemoryCollector struct {
+	detail bool
 }
 
 func (col *processMemoryCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
-	prefix := parent.prefix()
-	return collector.MetricReaderMap{
-		prefix + "/mem/rss": parent.sum(
+	metrics := collector.MetricReaderMap{
+		parent.metricName("mem/rss"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return bitflow.Value(proc.mem_rss)
 			}),
-		prefix + "/mem/vms": parent.sum(
+		parent.metricName("mem/vms"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return bitflow.Value(proc.mem_vms)
 			}),
-		prefix + "/mem/swap": parent.sum(
+		parent.metricName("mem/swap"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return bitflow.Value(proc.mem_swap)
 			}),
 	}
+	if col.detail {
+		metrics[parent.metricName("mem/pss")] = parent.sum(
+			func(proc *processInfo) bitflow.Value {
+				return bitflow.Value(proc.mem_pss)
+			})
+		metrics[parent.metricName("mem/uss")] = parent.sum(
+			func(proc *processInfo) bitflow.Value {
+				return bitflow.Value(proc.mem_uss)
+			})
+	}
+	return metrics
 }
 
 func (col *processMemoryCollector) updateProc(info *processInfo) error {
 	// Alternative: col.MemoryInfoEx()
-	if mem, err := info.MemoryInfo(); err != nil {
+	if mem, err := info.snapshot.memoryInfo(info.Process); err != nil {
 		return fmt.Errorf("Failed to get memory info: %v", err)
 	} else {
 		info.mem_rss = mem.RSS
 		info.mem_vms = mem.VMS
 		info.mem_swap = mem.Swap
 	}
+	if col.detail {
+		if detail, err := info.snapshot.memoryDetail(info.Pid); err != nil {
+			return fmt.Errorf("Failed to get detailed memory info: %v", err)
+		} else {
+			info.mem_pss = detail.pss
+			info.mem_uss = detail.uss
+			info.mem_swap = detail.swap
+		}
+	}
 	return nil
 }
 
@@ -123,37 +146,36 @@ type processNetCollector struct {
 }
 
 func (col *processNetCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
-	prefix := parent.prefix()
 	return collector.MetricReaderMap{
-		prefix + "/net-io/bytes": parent.netIoSum(
+		parent.metricName("net-io/bytes"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.Bytes.GetDiff()
 			}),
-		prefix + "/net-io/packets": parent.netIoSum(
+		parent.metricName("net-io/packets"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.Packets.GetDiff()
 			}),
-		prefix + "/net-io/rx_bytes": parent.netIoSum(
+		parent.metricName("net-io/rx_bytes"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.RxBytes.GetDiff()
 			}),
-		prefix + "/net-io/rx_packets": parent.netIoSum(
+		parent.metricName("net-io/rx_packets"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.RxPackets.GetDiff()
 			}),
-		prefix + "/net-io/tx_bytes": parent.netIoSum(
+		parent.metricName("net-io/tx_bytes"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.TxBytes.GetDiff()
 			}),
-		prefix + "/net-io/tx_packets": parent.netIoSum(
+		parent.metricName("net-io/tx_packets"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.TxPackets.GetDiff()
 			}),
-		prefix + "/net-io/errors": parent.netIoSum(
+		parent.metricName("net-io/errors"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.Errors.GetDiff()
 			}),
-		prefix + "/net-io/dropped": parent.netIoSum(
+		parent.metricName("net-io/dropped"): parent.netIoSum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.net.Dropped.GetDiff()
 			}),
@@ -162,7 +184,7 @@ func (col *processNetCollector) metrics(parent *ProcessCollector) collector.Metr
 
 func (col *processNetCollector) updateProc(info *processInfo) error {
 	// Alternative: col.Connections()
-	if counters, err := info.NetIOCounters(false); err != nil {
+	if counters, err := info.snapshot.netIOCounters(info.Process); err != nil {
 		return fmt.Errorf("Failed to get net-IO info: %v", err)
 	} else {
 		if len(counters) != 1 {
@@ -178,7 +200,7 @@ type processFdCollector struct {
 
 func (col *processFdCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
 	return collector.MetricReaderMap{
-		parent.prefix() + "/fds": parent.sum(
+		parent.metricName("fds"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return bitflow.Value(proc.numFds)
 			}),
@@ -187,7 +209,7 @@ func (col *processFdCollector) metrics(parent *ProcessCollector) collector.Metri
 
 func (col *processFdCollector) updateProc(info *processInfo) error {
 	// Alternative: col.NumFDs(), proc.OpenFiles()
-	if num, err := col.procNumFds(info); err != nil {
+	if num, err := info.snapshot.numFds(info.Pid); err != nil {
 		return fmt.Errorf("Failed to get number of open files: %v", err)
 	} else {
 		info.numFds = num
@@ -195,10 +217,9 @@ func (col *processFdCollector) updateProc(info *processInfo) error {
 	return nil
 }
 
-func (col *processFdCollector) procNumFds(info *processInfo) (int32, error) {
+func readProcNumFds(pid int32) (int32, error) {
 	// This is part of gopsutil/process.Process.fillFromfd()
-	pid := info.Pid
-	statPath := hostProcFile(strconv.Itoa(int(pid)), "fd")
+	statPath := hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "fd")
 	d, err := os.Open(statPath)
 	if err != nil {
 		return 0, err
@@ -215,22 +236,21 @@ type processMiscCollector struct {
 }
 
 func (col *processMiscCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
-	prefix := parent.prefix()
 	return collector.MetricReaderMap{
-		prefix + "/threads": parent.sum(
+		parent.metricName("threads"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return bitflow.Value(proc.numThreads)
 			}),
 
-		prefix + "/ctxSwitch": parent.sum(
+		parent.metricName("ctxSwitch"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ctxSwitchVoluntary.GetDiff()
 			}),
-		prefix + "/ctxSwitch/voluntary": parent.sum(
+		parent.metricName("ctxSwitch/voluntary"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ctxSwitchInvoluntary.GetDiff()
 			}),
-		prefix + "/ctxSwitch/involuntary": parent.sum(
+		parent.metricName("ctxSwitch/involuntary"): parent.sum(
 			func(proc *processInfo) bitflow.Value {
 				return proc.ctxSwitchInvoluntary.GetDiff() + proc.ctxSwitchVoluntary.GetDiff()
 			}),
@@ -239,27 +259,26 @@ func (col *processMiscCollector) metrics(parent *ProcessCollector) collector.Met
 
 func (col *processMiscCollector) updateProc(info *processInfo) error {
 	// Misc, Alternative: col.NumThreads(), col.NumCtxSwitches()
-	if numThreads, ctxSwitches, err := col.procGetMisc(info); err != nil {
+	if status, err := info.snapshot.status(info.Pid); err != nil {
 		return fmt.Errorf("Failed to get number of threads/ctx-switches: %v", err)
 	} else {
-		info.numThreads = numThreads
-		info.ctxSwitchVoluntary.Add(collector.StoredValue(ctxSwitches.Voluntary))
-		info.ctxSwitchInvoluntary.Add(collector.StoredValue(ctxSwitches.Involuntary))
+		info.numThreads = status.numThreads
+		info.ctxSwitchVoluntary.Add(collector.StoredValue(status.ctxSwitches.Voluntary))
+		info.ctxSwitchInvoluntary.Add(collector.StoredValue(status.ctxSwitches.Involuntary))
 	}
 	return nil
 }
 
-func (col *processMiscCollector) procGetMisc(info *processInfo) (numThreads int32, numCtxSwitches process.NumCtxSwitchesStat, err error) {
+func readProcStatus(pid int32) (status procStatus, err error) {
 	// This is part of gopsutil/process.Process.fillFromStatus()
-	pid := info.Pid
-	statPath := hostProcFile(strconv.Itoa(int(pid)), "status")
+	statPath := hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "status")
 	var contents []byte
 	contents, err = ioutil.ReadFile(statPath)
 	if err != nil {
 		return
 	}
 	lines := strings.Split(string(contents), "\n")
-	leftover_fields := 3
+	leftover_fields := 4
 	for _, line := range lines {
 		tabParts := strings.SplitN(line, "\t", 2)
 		if len(tabParts) < 2 {
@@ -273,21 +292,27 @@ func (col *processMiscCollector) procGetMisc(info *processInfo) (numThreads int3
 			if err != nil {
 				return
 			}
-			numThreads = int32(v)
+			status.numThreads = int32(v)
+			leftover_fields--
+		case "Cpus_allowed_list":
+			status.cpusAllowed, err = parseCpuList(value)
+			if err != nil {
+				return
+			}
 			leftover_fields--
 		case "voluntary_ctxt_switches":
 			v, err = strconv.ParseInt(value, 10, 64)
 			if err != nil {
 				return
 			}
-			numCtxSwitches.Voluntary = v
+			status.ctxSwitches.Voluntary = v
 			leftover_fields--
 		case "nonvoluntary_ctxt_switches":
 			v, err = strconv.ParseInt(value, 10, 64)
 			if err != nil {
 				return
 			}
-			numCtxSwitches.Involuntary = v
+			status.ctxSwitches.Involuntary = v
 			leftover_fields--
 		}
 		if leftover_fields <= 0 {
@@ -296,3 +321,79 @@ func (col *processMiscCollector) procGetMisc(info *processInfo) (numThreads int3
 	}
 	return
 }
+
+// parseCpuList parses a list of CPUs in the format of Cpus_allowed_list and cpuset.cpus, e.g. 0-3,8,10-11
+func parseCpuList(list string) ([]int, error) {
+	var res []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CPU list '%v': %v", list, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("Invalid CPU list '%v': %v", list, err)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			res = append(res, cpu)
+		}
+	}
+	return res, nil
+}
+
+// processDelayCollector reports the delay accounting values of the processes. Without the taskstats interface,
+// only the block IO delay is available, which is read from /proc/<pid>/stat.
+type processDelayCollector struct {
+}
+
+func (col *processDelayCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
+	metrics := collector.MetricReaderMap{
+		parent.metricName("delay/blkio"): parent.sum(
+			func(proc *processInfo) bitflow.Value {
+				return proc.blkioDelay.GetDiff()
+			}),
+	}
+	if parent.root.snapshot.taskstats != nil {
+		metrics[parent.metricName("delay/cpu")] = parent.sum(
+			func(proc *processInfo) bitflow.Value {
+				return proc.cpuDelay.GetDiff()
+			})
+		metrics[parent.metricName("delay/swapin")] = parent.sum(
+			func(proc *processInfo) bitflow.Value {
+				return proc.swapinDelay.GetDiff()
+			})
+		metrics[parent.metricName("delay/freepages")] = parent.sum(
+			func(proc *processInfo) bitflow.Value {
+				return proc.freepagesDelay.GetDiff()
+			})
+	}
+	return metrics
+}
+
+func (col *processDelayCollector) updateProc(info *processInfo) error {
+	if info.snapshot.taskstats == nil {
+		if stat, err := info.snapshot.stat(info.Process); err != nil {
+			return fmt.Errorf("Failed to get block IO delay: %v", err)
+		} else {
+			// Convert the delay from seconds to milliseconds, resulting in ms/sec values
+			info.blkioDelay.Add(collector.StoredValue(stat.blkioDelay * 1000))
+		}
+		return nil
+	}
+	if stats, err := info.snapshot.taskstatsOf(info.Pid); err != nil {
+		return fmt.Errorf("Failed to get taskstats delay accounting info: %v", err)
+	} else {
+		// Convert the delays from nanoseconds to milliseconds, resulting in ms/sec values
+		info.cpuDelay.Add(collector.StoredValue(float64(stats.cpuDelayTotal) / 1e6))
+		info.blkioDelay.Add(collector.StoredValue(float64(stats.blkioDelayTotal) / 1e6))
+		info.swapinDelay.Add(collector.StoredValue(float64(stats.swapinDelayTotal) / 1e6))
+		info.freepagesDelay.Add(collector.StoredValue(float64(stats.freepagesDelayTotal) / 1e6))
+	}
+	return nil
+}
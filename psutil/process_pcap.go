@@ -1,6 +1,7 @@
 package psutil
 
 import (
+	"context"
 	"errors"
 	"io"
 	"sync"
@@ -12,38 +13,41 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	PcapNics []string
+// pcapCollector is shared by all process collectors of one RootCollector
+type pcapCollector struct {
+	collector.AbstractCollector
+	root      *RootCollector
+	startOnce *sync.Once
+	cons      *pcap.Connections
+}
 
-	pcapStartOnce       = new(sync.Once)
-	pcapCons            = pcap.NewConnections()
-	globalPcapCollector = pcapCollector{
+func newPcapCollector(root *RootCollector) *pcapCollector {
+	return &pcapCollector{
 		AbstractCollector: collector.RootCollector("pcap"),
+		root:              root,
+		startOnce:         new(sync.Once),
+		cons:              pcap.NewConnections(),
 	}
-)
-
-type pcapCollector struct {
-	collector.AbstractCollector
 }
 
-func (*pcapCollector) Init() ([]collector.Collector, error) {
-	if len(PcapNics) == 0 {
-		return nil, errors.New("psutil.PcapNics must be set to at least one NIC")
+func (col *pcapCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if len(col.root.PcapNics) == 0 {
+		return nil, errors.New("psutil.RootCollector.PcapNics must be set to at least one NIC")
 	}
-	return nil, pcap_impl.TestLiveCapture(PcapNics)
+	return nil, pcap_impl.TestLiveCapture(col.root.PcapNics)
 }
 
-func (*pcapCollector) Update() (err error) {
-	pcapStartOnce.Do(func() {
+func (col *pcapCollector) Update(ctx context.Context) (err error) {
+	col.startOnce.Do(func() {
 		var sources []pcap.PacketSource
-		sources, err = pcap_impl.OpenSources("", PcapNics, true)
+		sources, err = pcap_impl.OpenSources("", col.root.PcapNics, true)
 		if err == nil {
-			pcapCons.CapturePackets(sources, func(err error) {
+			col.cons.CapturePackets(sources, func(err error) {
 				if captureErr, ok := err.(pcap.CaptureError); ok {
 					log.Debugln("PCAP capture error:", captureErr)
 				} else if err == io.EOF {
 					// Packet capture is finished, restart on next Update()
-					pcapStartOnce = new(sync.Once)
+					col.startOnce = new(sync.Once)
 				} else {
 					log.Warnln("PCAP capture error:", captureErr)
 				}
@@ -51,7 +55,7 @@ func (*pcapCollector) Update() (err error) {
 		}
 	})
 	if err != nil {
-		pcapStartOnce = new(sync.Once)
+		col.startOnce = new(sync.Once)
 	}
 	return
 }
@@ -71,42 +75,41 @@ func (col *ProcessCollector) newProcessPcapCollector() *processPcapCollector {
 	return result
 }
 
-func (col *processPcapCollector) Init() ([]collector.Collector, error) {
-	return []collector.Collector{&globalPcapCollector}, nil
+func (col *processPcapCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	return []collector.Collector{col.parent.root.pcap}, nil
 }
 
 func (col *processPcapCollector) Depends() []collector.Collector {
 	res := col.processSubCollector.Depends()
-	res = append(res, &globalPcapCollector)
+	res = append(res, col.parent.root.pcap)
 	return res
 }
 
 func (col *processPcapCollector) metrics(parent *ProcessCollector) collector.MetricReaderMap {
-	prefix := parent.prefix()
 	return collector.MetricReaderMap{
-		prefix + "/net-pcap/bytes": parent.sum(func(proc *processInfo) bitflow.Value {
+		parent.metricName("net-pcap/bytes"): parent.sum(func(proc *processInfo) bitflow.Value {
 			return proc.net_pcap.Bytes.GetDiff()
 		}),
-		prefix + "/net-pcap/packets": parent.sum(func(proc *processInfo) bitflow.Value {
+		parent.metricName("net-pcap/packets"): parent.sum(func(proc *processInfo) bitflow.Value {
 			return proc.net_pcap.Packets.GetDiff()
 		}),
-		prefix + "/net-pcap/rx_bytes": parent.sum(func(proc *processInfo) bitflow.Value {
+		parent.metricName("net-pcap/rx_bytes"): parent.sum(func(proc *processInfo) bitflow.Value {
 			return proc.net_pcap.RxBytes.GetDiff()
 		}),
-		prefix + "/net-pcap/rx_packets": parent.sum(func(proc *processInfo) bitflow.Value {
+		parent.metricName("net-pcap/rx_packets"): parent.sum(func(proc *processInfo) bitflow.Value {
 			return proc.net_pcap.RxBytes.GetDiff()
 		}),
-		prefix + "/net-pcap/tx_bytes": parent.sum(func(proc *processInfo) bitflow.Value {
+		parent.metricName("net-pcap/tx_bytes"): parent.sum(func(proc *processInfo) bitflow.Value {
 			return proc.net_pcap.TxBytes.GetDiff()
 		}),
-		prefix + "/net-pcap/tx_packets": parent.sum(func(proc *processInfo) bitflow.Value {
+		parent.metricName("net-pcap/tx_packets"): parent.sum(func(proc *processInfo) bitflow.Value {
 			return proc.net_pcap.TxPackets.GetDiff()
 		}),
 	}
 }
 
 func (col *processPcapCollector) updateProc(info *processInfo) error {
-	cons, err := pcapCons.FilterConnections([]int{int(info.Pid)})
+	cons, err := col.parent.root.pcap.cons.FilterConnections([]int{int(info.Pid)})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,78 @@
+package psutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProcessTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestProcess(t *testing.T) {
+	suite.Run(t, new(ProcessTestSuite))
+}
+
+func (suite *ProcessTestSuite) TestValidateProcessMetricName() {
+	for _, valid := range []string{DefaultProcessMetricName, "{metric}", "proc/{container}/{group}-{pid}/{metric}"} {
+		suite.NoError(ValidateProcessMetricName(valid), valid)
+	}
+	for _, invalid := range []string{"", "proc/{group}", "proc/{group}/{name}/{metric}", "{Metric}"} {
+		suite.Error(ValidateProcessMetricName(invalid), invalid)
+	}
+}
+
+func (suite *ProcessTestSuite) TestMetricName() {
+	root := new(RootCollector)
+	col := root.NewProcessCollector(nil, "web", false, false)
+	col.nameValues = processNameValues{pid: "123", container: "3f4e1c2b5a6d"}
+	suite.Equal("proc/web/mem/rss", col.metricName("mem/rss"))
+	suite.False(col.dynamicNames())
+
+	root.ProcessMetricName = "{metric}"
+	suite.Equal("mem/rss", col.metricName("mem/rss"))
+	root.ProcessMetricName = "proc/{container}/{group}-{pid}/{metric}"
+	suite.Equal("proc/3f4e1c2b5a6d/web-123/cpu", col.metricName("cpu"))
+	suite.True(col.dynamicNames())
+
+	// The prefix of NewPidProcessCollector() is not affected by the template
+	col = root.NewPidProcessCollector("pod", "k8s/default/web", nil, false)
+	suite.Equal("k8s/default/web/cpu", col.metricName("cpu"))
+	suite.False(col.dynamicNames())
+}
+
+func (suite *ProcessTestSuite) TestDiscoveredGroupName() {
+	suite.Equal("nginx", discoveredGroupName("nginx"))
+	suite.Equal("kworker_0:1", discoveredGroupName("kworker/0:1"))
+	suite.Equal("Web_Content", discoveredGroupName("Web Content"))
+}
+
+func (suite *ProcessTestSuite) TestDiscoveryUpdateGroups() {
+	col := new(RootCollector).NewProcessDiscoveryCollector(2)
+	col.IdleThreshold = 1
+	col.RetireAfter = time.Minute
+	start := time.Now()
+	groupNames := func() []string {
+		var res []string
+		for name := range col.groups {
+			res = append(res, name)
+		}
+		return res
+	}
+
+	// The executables with the highest usage are added, up to MaxGroups
+	suite.True(col.updateGroups(map[string]float64{"bash": 0.5, "java": 20, "nginx": 5, "postgres": 3}, start))
+	suite.ElementsMatch([]string{"java", "nginx"}, groupNames())
+	suite.False(col.updateGroups(map[string]float64{"java": 10, "nginx": 0.2, "postgres": 30}, start.Add(30*time.Second)))
+	suite.ElementsMatch([]string{"java", "nginx"}, groupNames())
+
+	// Idle groups are retired after RetireAfter and replaced in the same ranking
+	suite.True(col.updateGroups(map[string]float64{"java": 10, "postgres": 30}, start.Add(time.Minute)))
+	suite.ElementsMatch([]string{"java", "postgres"}, groupNames())
+	suite.False(col.updateGroups(map[string]float64{"java": 10, "postgres": 0.5}, start.Add(90*time.Second)))
+	suite.True(col.updateGroups(map[string]float64{"java": 10}, start.Add(2*time.Minute)))
+	suite.ElementsMatch([]string{"java"}, groupNames())
+}
@@ -0,0 +1,382 @@
+package psutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/process"
+)
+
+// The readers in this file parse exactly the fields of the /proc files that are needed by the collectors, but return the
+// data structures of gopsutil, which is used as fallback on other platforms (see procfs_linux.go and procfs_other.go).
+// Unlike gopsutil, they do not perform additional syscalls or read other files, e.g. the boot time for every process
+// or the udev database for every disk.
+
+// userHZ is the unit of the CPU times in /proc/stat and /proc/<pid>/stat. It is fixed to 100 by the kernel ABI,
+// independent of the internal tick rate of the kernel.
+const userHZ = 100
+
+// Columns of /proc/diskstats after the device name, see Documentation/admin-guide/iostats.rst
+const (
+	diskstatsReads        = 3
+	diskstatsReadSectors  = 5
+	diskstatsReadTime     = 6
+	diskstatsWrites       = 7
+	diskstatsWriteSectors = 9
+	diskstatsWriteTime    = 10
+	diskstatsIoTime       = 12
+
+	diskstatsSectorSize = 512
+)
+
+var pageSize = uint64(os.Getpagesize())
+
+// readCpuTimesFile parses the aggregated cpu line of the given stat file. The columns after idle were added in
+// different kernel versions and are optional.
+func readCpuTimesFile(filename string) (cpu.TimesStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return cpu.TimesStat{}, err
+	}
+	line := string(data)
+	if index := strings.IndexByte(line, '\n'); index >= 0 {
+		line = line[:index]
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpu.TimesStat{}, fmt.Errorf("%v is not formatted correctly, missing cpu line", filename)
+	}
+	res := cpu.TimesStat{CPU: "cpu-total"}
+	for i, value := range []*float64{
+		&res.User, &res.Nice, &res.System, &res.Idle, &res.Iowait,
+		&res.Irq, &res.Softirq, &res.Steal, &res.Guest, &res.GuestNice,
+	} {
+		if i+1 >= len(fields) {
+			break
+		}
+		ticks, err := strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return cpu.TimesStat{}, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+		}
+		*value = float64(ticks) / userHZ
+	}
+	return res, nil
+}
+
+// readMemoryFile parses the given meminfo file and computes the used and available memory like gopsutil.
+// Kernels before 3.14 do not report MemAvailable, in which case it is estimated from the free memory and the caches.
+func readMemoryFile(filename string) (mem.VirtualMemoryStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return mem.VirtualMemoryStat{}, err
+	}
+	var res mem.VirtualMemoryStat
+	var sReclaimable uint64
+	hasAvailable := false
+	for i, line := range strings.Split(string(data), "\n") {
+		index := strings.IndexByte(line, ':')
+		if index < 0 {
+			continue
+		}
+		var value *uint64
+		switch line[:index] {
+		case "MemTotal":
+			value = &res.Total
+		case "MemFree":
+			value = &res.Free
+		case "MemAvailable":
+			value = &res.Available
+			hasAvailable = true
+		case "Buffers":
+			value = &res.Buffers
+		case "Cached":
+			value = &res.Cached
+		case "SReclaimable":
+			value = &sReclaimable
+		default:
+			continue
+		}
+		fields := strings.Fields(line[index+1:])
+		if len(fields) == 0 {
+			return mem.VirtualMemoryStat{}, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return mem.VirtualMemoryStat{}, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+		*value = kb * 1024
+	}
+	if res.Total == 0 {
+		return mem.VirtualMemoryStat{}, fmt.Errorf("%v does not contain the total memory", filename)
+	}
+	res.Cached += sReclaimable
+	if !hasAvailable {
+		res.Available = res.Free + res.Buffers + res.Cached
+	}
+	res.Used = res.Total - res.Free - res.Buffers - res.Cached
+	res.UsedPercent = float64(res.Used) / float64(res.Total) * 100
+	return res, nil
+}
+
+func readLoadFile(filename string) (load.AvgStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return load.AvgStat{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load.AvgStat{}, fmt.Errorf("%v is not formatted correctly", filename)
+	}
+	var res load.AvgStat
+	for i, value := range []*float64{&res.Load1, &res.Load5, &res.Load15} {
+		if *value, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load.AvgStat{}, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+		}
+	}
+	return res, nil
+}
+
+// readDiskIOFile parses the given diskstats file. Like in gopsutil, devices without any IO (e.g. unused loop devices)
+// and lines in an unknown format are skipped, and the sector counts are converted to bytes.
+func readDiskIOFile(filename string) (map[string]disk.IOCountersStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]disk.IOCountersStat)
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) <= diskstatsIoTime {
+			// Empty line, or partition statistics in the reduced format of Linux 2.6.25 and older
+			continue
+		}
+		var stat disk.IOCountersStat
+		var readSectors, writeSectors uint64
+		for _, field := range []struct {
+			column int
+			value  *uint64
+		}{
+			{diskstatsReads, &stat.ReadCount},
+			{diskstatsReadSectors, &readSectors},
+			{diskstatsReadTime, &stat.ReadTime},
+			{diskstatsWrites, &stat.WriteCount},
+			{diskstatsWriteSectors, &writeSectors},
+			{diskstatsWriteTime, &stat.WriteTime},
+			{diskstatsIoTime, &stat.IoTime},
+		} {
+			if *field.value, err = strconv.ParseUint(fields[field.column], 10, 64); err != nil {
+				return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+			}
+		}
+		if stat == (disk.IOCountersStat{}) {
+			continue
+		}
+		stat.Name = fields[2]
+		stat.ReadBytes = readSectors * diskstatsSectorSize
+		stat.WriteBytes = writeSectors * diskstatsSectorSize
+		res[stat.Name] = stat
+	}
+	return res, nil
+}
+
+// readPidsDir returns the PIDs of all processes in the given proc directory
+func readPidsDir(dir string) ([]int32, error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int32, 0, len(names))
+	for _, name := range names {
+		if pid, err := strconv.ParseInt(name, 10, 32); err == nil {
+			res = append(res, int32(pid))
+		}
+	}
+	return res, nil
+}
+
+// readProcCmdlineFile returns the arguments in the given cmdline file of a process, separated by spaces
+func readProcCmdlineFile(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.FieldsFunc(string(data), func(r rune) bool {
+		return r == 0
+	}), " "), nil
+}
+
+// readProcCommFile returns the executable name in the given comm file of a process
+func readProcCommFile(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// procStat contains the fields of the stat file of a process that are used by the process collectors
+type procStat struct {
+	times cpu.TimesStat
+
+	// Aggregated block IO delay in seconds (delayacct_blkio_ticks). Remains zero if the delay accounting
+	// of the kernel is disabled (see the kernel.task_delayacct sysctl) or not reported by old kernels.
+	blkioDelay float64
+}
+
+// readProcStatFile parses the user and system CPU times and the block IO delay in the given stat file of a process.
+// The fields are counted from the end of the command name, which can contain spaces and parentheses.
+func readProcStatFile(filename string) (*procStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	str := string(data)
+	index := strings.LastIndexByte(str, ')')
+	if index < 0 {
+		return nil, fmt.Errorf("%v is not formatted correctly, missing command name", filename)
+	}
+	// After the command name: state (3), ppid (4), ..., utime (14), stime (15), ..., delayacct_blkio_ticks (42)
+	fields := strings.Fields(str[index+1:])
+	if len(fields) < 13 {
+		return nil, fmt.Errorf("%v is not formatted correctly", filename)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+	}
+	res := &procStat{
+		times: cpu.TimesStat{
+			CPU:    "cpu",
+			User:   float64(utime) / userHZ,
+			System: float64(stime) / userHZ,
+		},
+	}
+	if len(fields) > 39 {
+		blkio, err := strconv.ParseUint(fields[39], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+		}
+		res.blkioDelay = float64(blkio) / userHZ
+	}
+	return res, nil
+}
+
+// procMemoryDetail contains the memory usage of a process in bytes, obtained from its smaps_rollup or smaps file
+type procMemoryDetail struct {
+	pss  uint64
+	uss  uint64
+	swap uint64
+}
+
+// readProcSmapsFile sums up the proportional, private and swapped memory in the given smaps_rollup file of a process.
+// The smaps file of older kernels has the same format, but one block of lines for every memory mapping.
+// The unique memory (USS) is the sum of the clean and dirty private pages.
+func readProcSmapsFile(filename string) (*procMemoryDetail, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := new(procMemoryDetail)
+	for i, line := range strings.Split(string(data), "\n") {
+		index := strings.IndexByte(line, ':')
+		if index < 0 {
+			continue
+		}
+		var sum *uint64
+		switch line[:index] {
+		case "Pss":
+			sum = &res.pss
+		case "Private_Clean", "Private_Dirty":
+			sum = &res.uss
+		case "Swap":
+			sum = &res.swap
+		default:
+			// Other fields, and the header lines of the memory mappings
+			continue
+		}
+		fields := strings.Fields(line[index+1:])
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+		*sum += kb * 1024
+	}
+	return res, nil
+}
+
+func readProcIOFile(filename string) (*process.IOCountersStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := new(process.IOCountersStat)
+	for i, line := range strings.Split(string(data), "\n") {
+		index := strings.IndexByte(line, ':')
+		if index < 0 {
+			continue
+		}
+		var value *uint64
+		switch line[:index] {
+		case "syscr":
+			value = &res.ReadCount
+		case "syscw":
+			value = &res.WriteCount
+		case "read_bytes":
+			value = &res.ReadBytes
+		case "write_bytes":
+			value = &res.WriteBytes
+		default:
+			continue
+		}
+		if *value, err = strconv.ParseUint(strings.TrimSpace(line[index+1:]), 10, 64); err != nil {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+		}
+	}
+	return res, nil
+}
+
+// readProcMemoryFile parses the virtual and resident memory size of a process from the given statm file.
+// The swap usage is not available in this file and remains zero, as in gopsutil.
+func readProcMemoryFile(filename string) (*process.MemoryInfoStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("%v is not formatted correctly", filename)
+	}
+	vms, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+	}
+	rss, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not formatted correctly: %v", filename, err)
+	}
+	return &process.MemoryInfoStat{
+		RSS: rss * pageSize,
+		VMS: vms * pageSize,
+	}, nil
+}
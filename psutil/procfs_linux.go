@@ -0,0 +1,97 @@
+package psutil
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+func readCpuTimes() (cpu.TimesStat, error) {
+	return readCpuTimesFile(hostfs.Current().ProcPath("stat"))
+}
+
+func readMemory() (mem.VirtualMemoryStat, error) {
+	return readMemoryFile(hostfs.Current().ProcPath("meminfo"))
+}
+
+func readLoad() (load.AvgStat, error) {
+	return readLoadFile(hostfs.Current().ProcPath("loadavg"))
+}
+
+func readDiskIO() (map[string]disk.IOCountersStat, error) {
+	return readDiskIOFile(hostfs.Current().ProcPath("diskstats"))
+}
+
+func readPids() ([]int32, error) {
+	return readPidsDir(hostfs.Current().ProcPath())
+}
+
+func openProcess(pid int32) (*process.Process, error) {
+	if _, err := os.Stat(hostfs.Current().ProcPath(strconv.Itoa(int(pid)))); err != nil {
+		return nil, err
+	}
+	return &process.Process{Pid: pid}, nil
+}
+
+func readProcCmdline(proc *process.Process) (string, error) {
+	return readProcCmdlineFile(hostfs.Current().ProcPath(strconv.Itoa(int(proc.Pid)), "cmdline"))
+}
+
+func readProcComm(pid int32) (string, error) {
+	return readProcCommFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "comm"))
+}
+
+// procStatBlkioDelay is true if readProcStat() reports the block IO delay of processes
+const procStatBlkioDelay = true
+
+func readProcStat(proc *process.Process) (*procStat, error) {
+	return readProcStatFile(hostfs.Current().ProcPath(strconv.Itoa(int(proc.Pid)), "stat"))
+}
+
+func readProcIOCounters(proc *process.Process) (*process.IOCountersStat, error) {
+	return readProcIOFile(hostfs.Current().ProcPath(strconv.Itoa(int(proc.Pid)), "io"))
+}
+
+func readProcMemoryInfo(proc *process.Process) (*process.MemoryInfoStat, error) {
+	return readProcMemoryFile(hostfs.Current().ProcPath(strconv.Itoa(int(proc.Pid)), "statm"))
+}
+
+// procMemoryDetailAvailable is true if readProcMemoryDetail() is supported
+const procMemoryDetailAvailable = true
+
+func readProcMemoryDetail(pid int32) (*procMemoryDetail, error) {
+	detail, err := readProcSmapsFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "smaps_rollup"))
+	if os.IsNotExist(err) && processExists(pid) {
+		// smaps_rollup was added in Linux 4.14
+		detail, err = readProcSmapsFile(hostfs.Current().ProcPath(strconv.Itoa(int(pid)), "smaps"))
+	}
+	return detail, err
+}
+
+// readProcNetIOCounters returns the sum of the counters of all NICs in the network namespace of the process,
+// as a single element named "all", like gopsutil/process.Process.NetIOCounters(false).
+func readProcNetIOCounters(proc *process.Process) ([]psnet.IOCountersStat, error) {
+	nics, _, err := readNetDev(hostfs.Current().ProcPath(strconv.Itoa(int(proc.Pid)), "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	all := psnet.IOCountersStat{Name: "all"}
+	for _, nic := range nics {
+		all.BytesRecv += nic.BytesRecv
+		all.PacketsRecv += nic.PacketsRecv
+		all.Errin += nic.Errin
+		all.Dropin += nic.Dropin
+		all.BytesSent += nic.BytesSent
+		all.PacketsSent += nic.PacketsSent
+		all.Errout += nic.Errout
+		all.Dropout += nic.Dropout
+	}
+	return []psnet.IOCountersStat{all}, nil
+}
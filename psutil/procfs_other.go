@@ -0,0 +1,96 @@
+// +build !linux
+
+package psutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+func readCpuTimes() (cpu.TimesStat, error) {
+	times, err := cpu.Times(false)
+	if err != nil {
+		return cpu.TimesStat{}, err
+	}
+	if len(times) != 1 {
+		return cpu.TimesStat{}, fmt.Errorf("gopsutil/cpu.Times() returned %v cpu.TimesStat instead of %v", len(times), 1)
+	}
+	return times[0], nil
+}
+
+func readMemory() (mem.VirtualMemoryStat, error) {
+	memory, err := mem.VirtualMemory()
+	if err != nil || memory == nil {
+		return mem.VirtualMemoryStat{}, err
+	}
+	return *memory, nil
+}
+
+func readLoad() (load.AvgStat, error) {
+	avg, err := load.Avg()
+	if err != nil || avg == nil {
+		return load.AvgStat{}, err
+	}
+	return *avg, nil
+}
+
+func readDiskIO() (map[string]disk.IOCountersStat, error) {
+	return disk.IOCounters()
+}
+
+func readPids() ([]int32, error) {
+	return process.Pids()
+}
+
+func openProcess(pid int32) (*process.Process, error) {
+	return process.NewProcess(pid)
+}
+
+func readProcCmdline(proc *process.Process) (string, error) {
+	return proc.Cmdline()
+}
+
+func readProcComm(pid int32) (string, error) {
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return "", err
+	}
+	return proc.Name()
+}
+
+// procStatBlkioDelay is true if readProcStat() reports the block IO delay of processes
+const procStatBlkioDelay = false
+
+func readProcStat(proc *process.Process) (*procStat, error) {
+	times, err := proc.Times()
+	if err != nil {
+		return nil, err
+	}
+	return &procStat{times: *times}, nil
+}
+
+func readProcIOCounters(proc *process.Process) (*process.IOCountersStat, error) {
+	return proc.IOCounters()
+}
+
+func readProcMemoryInfo(proc *process.Process) (*process.MemoryInfoStat, error) {
+	return proc.MemoryInfo()
+}
+
+// procMemoryDetailAvailable is true if readProcMemoryDetail() is supported
+const procMemoryDetailAvailable = false
+
+func readProcMemoryDetail(pid int32) (*procMemoryDetail, error) {
+	return nil, errors.New("The memory details of processes are only available on Linux")
+}
+
+func readProcNetIOCounters(proc *process.Process) ([]psnet.IOCountersStat, error) {
+	return proc.NetIOCounters(false)
+}
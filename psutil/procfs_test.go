@@ -0,0 +1,343 @@
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/process"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProcfsTestSuite struct {
+	golib.AbstractTestSuite
+	dir string
+}
+
+func TestProcfs(t *testing.T) {
+	suite.Run(t, new(ProcfsTestSuite))
+}
+
+func (suite *ProcfsTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "procfs")
+	suite.NoError(err)
+	suite.dir = dir
+}
+
+func (suite *ProcfsTestSuite) TearDownTest() {
+	_ = os.RemoveAll(suite.dir)
+}
+
+func (suite *ProcfsTestSuite) file(name string, content string) string {
+	file := filepath.Join(suite.dir, name)
+	suite.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+	suite.NoError(ioutil.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func (suite *ProcfsTestSuite) TestReadCpuTimes() {
+	times, err := readCpuTimesFile(suite.file("stat",
+		"cpu  100 20 300 4000 50 6 7 8 9 10\n"+
+			"cpu0 50 10 150 2000 25 3 3 4 4 5\n"+
+			"intr 12345\n"))
+	suite.NoError(err)
+	suite.Equal(cpu.TimesStat{CPU: "cpu-total", User: 1, Nice: 0.2, System: 3, Idle: 40, Iowait: 0.5,
+		Irq: 0.06, Softirq: 0.07, Steal: 0.08, Guest: 0.09, GuestNice: 0.1}, times)
+
+	// Old kernel without steal and guest times
+	times, err = readCpuTimesFile(suite.file("stat", "cpu 100 0 100 800 0 0 0\n"))
+	suite.NoError(err)
+	suite.Equal(cpu.TimesStat{CPU: "cpu-total", User: 1, System: 1, Idle: 8}, times)
+
+	for _, invalid := range []string{"", "cpu0 1 2 3 4\n", "cpu 1 2 3\n", "cpu 1 2 x 4\n"} {
+		_, err := readCpuTimesFile(suite.file("stat", invalid))
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ProcfsTestSuite) TestReadMemory() {
+	memory, err := readMemoryFile(suite.file("meminfo",
+		"MemTotal:        1000 kB\n"+
+			"MemFree:          200 kB\n"+
+			"MemAvailable:     500 kB\n"+
+			"Buffers:           50 kB\n"+
+			"Cached:           100 kB\n"+
+			"SwapCached:         0 kB\n"+
+			"SReclaimable:      50 kB\n"+
+			"HugePages_Total:    0\n"))
+	suite.NoError(err)
+	suite.Equal(mem.VirtualMemoryStat{Total: 1024000, Free: 204800, Available: 512000, Buffers: 51200, Cached: 153600,
+		Used: 614400, UsedPercent: 60}, memory)
+
+	// Kernels before 3.14 do not report MemAvailable
+	memory, err = readMemoryFile(suite.file("meminfo", "MemTotal: 1000 kB\nMemFree: 200 kB\nBuffers: 50 kB\nCached: 150 kB\n"))
+	suite.NoError(err)
+	suite.Equal(uint64(409600), memory.Available)
+	suite.Equal(uint64(614400), memory.Used)
+
+	for _, invalid := range []string{"", "MemFree: 200 kB\n", "MemTotal: x kB\n", "MemTotal:\n"} {
+		_, err := readMemoryFile(suite.file("meminfo", invalid))
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ProcfsTestSuite) TestReadLoad() {
+	avg, err := readLoadFile(suite.file("loadavg", "0.50 1.25 2.00 3/456 7890\n"))
+	suite.NoError(err)
+	suite.Equal(load.AvgStat{Load1: 0.5, Load5: 1.25, Load15: 2}, avg)
+
+	for _, invalid := range []string{"", "0.50 1.25\n", "0.50 x 2.00 3/456 7890\n"} {
+		_, err := readLoadFile(suite.file("loadavg", invalid))
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ProcfsTestSuite) TestReadDiskIO() {
+	disks, err := readDiskIOFile(suite.file("diskstats",
+		"   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n"+
+			"   8       0 sda 100 5 2000 30 200 10 4000 60 0 80 90 0 0 0 0 0 0\n"+
+			"   8       1 sda1 50 2 1000 15\n"))
+	suite.NoError(err)
+	suite.Equal(map[string]disk.IOCountersStat{
+		"sda": {Name: "sda", ReadCount: 100, ReadBytes: 1024000, ReadTime: 30, WriteCount: 200, WriteBytes: 2048000,
+			WriteTime: 60, IoTime: 80},
+	}, disks)
+
+	_, err = readDiskIOFile(suite.file("diskstats", "   8       0 sda 100 5 x 30 200 10 4000 60 0 80 90\n"))
+	suite.Error(err)
+}
+
+func (suite *ProcfsTestSuite) TestReadPids() {
+	suite.file("1/stat", "")
+	suite.file("42/stat", "")
+	suite.file("self/stat", "")
+	suite.file("meminfo", "")
+	pids, err := readPidsDir(suite.dir)
+	suite.NoError(err)
+	suite.ElementsMatch([]int32{1, 42}, pids)
+
+	_, err = readPidsDir(filepath.Join(suite.dir, "missing"))
+	suite.Error(err)
+}
+
+func (suite *ProcfsTestSuite) TestReadProcFiles() {
+	cmdline, err := readProcCmdlineFile(suite.file("cmdline", "nginx: worker\x00-c\x00/etc/nginx.conf\x00"))
+	suite.NoError(err)
+	suite.Equal("nginx: worker -c /etc/nginx.conf", cmdline)
+
+	stat, err := readProcStatFile(suite.file("stat",
+		"1234 (my (weird) cmd) S 1 1234 1234 0 -1 4194560 500 0 0 0 250 75 0 0 20 0 4 0 100 12345678 300\n"))
+	suite.NoError(err)
+	suite.Equal(&procStat{times: cpu.TimesStat{CPU: "cpu", User: 2.5, System: 0.75}}, stat)
+	stat, err = readProcStatFile(suite.file("stat",
+		"1234 (cmd) S 1 1234 1234 0 -1 4194560 500 0 0 0 250 75 0 0 20 0 4 0 100 12345678 300 "+
+			"18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 150 0 0\n"))
+	suite.NoError(err)
+	suite.Equal(&procStat{times: cpu.TimesStat{CPU: "cpu", User: 2.5, System: 0.75}, blkioDelay: 1.5}, stat)
+	for _, invalid := range []string{"1234 cmd S 1", "1234 (cmd) S 1 1234", "1234 (cmd) S 1 1234 1234 0 -1 4194560 500 0 0 0 x 75"} {
+		_, err := readProcStatFile(suite.file("stat", invalid))
+		suite.Error(err, invalid)
+	}
+
+	io, err := readProcIOFile(suite.file("io",
+		"rchar: 1000\nwchar: 2000\nsyscr: 10\nsyscw: 20\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n"))
+	suite.NoError(err)
+	suite.Equal(&process.IOCountersStat{ReadCount: 10, WriteCount: 20, ReadBytes: 4096, WriteBytes: 8192}, io)
+	_, err = readProcIOFile(suite.file("io", "syscr: x\n"))
+	suite.Error(err)
+
+	memory, err := readProcMemoryFile(suite.file("statm", "1000 200 50 10 0 300 0\n"))
+	suite.NoError(err)
+	suite.Equal(&process.MemoryInfoStat{VMS: 1000 * pageSize, RSS: 200 * pageSize}, memory)
+	for _, invalid := range []string{"", "1000\n", "1000 x\n"} {
+		_, err := readProcMemoryFile(suite.file("statm", invalid))
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ProcfsTestSuite) TestReadProcSmaps() {
+	detail, err := readProcSmapsFile(suite.file("smaps_rollup",
+		"55d0c0a00000-7ffc1a5f6000 ---p 00000000 00:00 0                          [rollup]\n"+
+			"Rss:                3000 kB\nPss:                1500 kB\nPss_Anon:            800 kB\n"+
+			"Shared_Clean:       1200 kB\nShared_Dirty:        400 kB\nPrivate_Clean:       300 kB\nPrivate_Dirty:      1100 kB\n"+
+			"Referenced:         2900 kB\nAnonymous:          1300 kB\nSwap:                 64 kB\nSwapPss:              32 kB\n"))
+	suite.NoError(err)
+	suite.Equal(&procMemoryDetail{pss: 1500 * 1024, uss: 1400 * 1024, swap: 64 * 1024}, detail)
+
+	// The smaps file of older kernels contains the same fields for every mapping
+	detail, err = readProcSmapsFile(suite.file("smaps",
+		"55d0c0a00000-55d0c0a21000 r-xp 00000000 fd:01 1234                       /usr/bin/worker\n"+
+			"Size:                132 kB\nPss:                  50 kB\nPrivate_Clean:         20 kB\nPrivate_Dirty:          0 kB\nSwap:                  0 kB\n"+
+			"VmFlags: rd ex mr mw me dw\n"+
+			"7f3a10000000-7f3a10021000 rw-p 00000000 00:00 0 \n"+
+			"Size:                132 kB\nPss:                 100 kB\nPrivate_Clean:          0 kB\nPrivate_Dirty:        100 kB\nSwap:                  8 kB\n"+
+			"VmFlags: rd wr mr mw me ac\n"))
+	suite.NoError(err)
+	suite.Equal(&procMemoryDetail{pss: 150 * 1024, uss: 120 * 1024, swap: 8 * 1024}, detail)
+
+	for _, invalid := range []string{"Pss:\n", "Pss: x kB\n"} {
+		_, err := readProcSmapsFile(suite.file("smaps_rollup", invalid))
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ProcfsTestSuite) TestParseProcCgroup() {
+	v1 := "12:cpu,cpuacct:/docker/abc\n5:blkio:/docker/abc\n0::/system.slice/docker.service\n"
+	cgroup, err := parseProcCgroup(suite.dir, v1, "blkio")
+	suite.NoError(err)
+	suite.Equal(cgroupDir{dir: filepath.Join(suite.dir, "blkio/docker/abc")}, cgroup)
+	cgroup, err = parseProcCgroup(suite.dir, v1, "cpu")
+	suite.NoError(err)
+	suite.Equal(cgroupDir{dir: filepath.Join(suite.dir, "cpu/docker/abc")}, cgroup)
+
+	// Hybrid layout, the unified hierarchy is mounted below unified/
+	cgroup, err = parseProcCgroup(suite.dir, "1:name=systemd:/user.slice\n0::/user.slice/session-1.scope\n", "blkio")
+	suite.NoError(err)
+	suite.Equal(cgroupDir{dir: filepath.Join(suite.dir, "unified/user.slice/session-1.scope"), v2: true}, cgroup)
+
+	suite.file("cgroup.controllers", "cpu io memory pids\n")
+	cgroup, err = parseProcCgroup(suite.dir, "0::/kubepods.slice/pod1\n", "cpu")
+	suite.NoError(err)
+	suite.Equal(cgroupDir{dir: filepath.Join(suite.dir, "kubepods.slice/pod1"), v2: true}, cgroup)
+
+	_, err = parseProcCgroup(suite.dir, "3:memory:/docker/abc\n", "blkio")
+	suite.Error(err)
+}
+
+func (suite *ProcfsTestSuite) TestParseContainerId() {
+	id := "3f4e1c2b5a6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345678abcdef"
+	suite.Equal("3f4e1c2b5a6d", parseContainerId("12:cpu,cpuacct:/docker/"+id+"\n0::/system.slice/docker.service\n"))
+	suite.Equal("3f4e1c2b5a6d", parseContainerId("0::/kubepods.slice/kubepods-pod1.slice/cri-containerd-"+id+".scope\n"))
+	suite.Equal("", parseContainerId("0::/user.slice/user-1000.slice/session-1.scope\n"))
+}
+
+func (suite *ProcfsTestSuite) TestReadCgroupBlkio() {
+	suite.file("v1/blkio.throttle.io_service_bytes",
+		"8:0 Read 4096\n8:0 Write 8192\n8:0 Sync 12288\n8:0 Async 0\n8:0 Discard 0\n8:0 Total 12288\n"+
+			"8:16 Read 1024\n8:16 Write 0\n8:16 Total 1024\nTotal 13312\n")
+	suite.file("v1/blkio.throttle.io_serviced", "8:0 Read 3\n8:0 Write 5\n8:0 Total 8\nTotal 8\n")
+	stat, err := readCgroupV1Blkio(filepath.Join(suite.dir, "v1"))
+	suite.NoError(err)
+	suite.Equal(cgroupBlkioStat{reads: 3, writes: 5, readBytes: 5120, writeBytes: 8192}, stat)
+	suite.file("v1/blkio.throttle.io_serviced", "8:0 Read x\n")
+	_, err = readCgroupV1Blkio(filepath.Join(suite.dir, "v1"))
+	suite.Error(err)
+
+	suite.file("v2/io.stat",
+		"8:0 rbytes=4096 wbytes=8192 rios=3 wios=5 dbytes=0 dios=0\n"+
+			"8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n")
+	stat, err = readCgroupV2Blkio(filepath.Join(suite.dir, "v2"))
+	suite.NoError(err)
+	suite.Equal(cgroupBlkioStat{reads: 4, writes: 5, readBytes: 5120, writeBytes: 8192}, stat)
+
+	suite.file("v2/io.pressure",
+		"some avg10=1.50 avg60=0.80 avg300=0.20 total=250000\n"+
+			"full avg10=0.50 avg60=0.30 avg300=0.10 total=100000\n")
+	stat, err = readCgroupV2Blkio(filepath.Join(suite.dir, "v2"))
+	suite.NoError(err)
+	suite.Equal(cgroupBlkioStat{reads: 4, writes: 5, readBytes: 5120, writeBytes: 8192, stallTime: 250000}, stat)
+	suite.file("v2/io.pressure", "full avg10=0.50 avg60=0.30 avg300=0.10 total=100000\n")
+	_, err = readCgroupV2Blkio(filepath.Join(suite.dir, "v2"))
+	suite.Error(err)
+
+	_, err = readCgroupV2Blkio(filepath.Join(suite.dir, "missing"))
+	suite.Error(err)
+}
+
+func (suite *ProcfsTestSuite) TestReadCgroupCpu() {
+	suite.file("v1/cpu.stat", "nr_periods 500\nnr_throttled 20\nthrottled_time 3000000000\n")
+	suite.file("v1/cpu.cfs_quota_us", "150000\n")
+	suite.file("v1/cpu.cfs_period_us", "100000\n")
+	stat, err := readCgroupV1Cpu(filepath.Join(suite.dir, "v1"))
+	suite.NoError(err)
+	suite.Equal(cgroupCpuStat{throttled: 20, throttledTime: 3000000000, quota: 1.5}, stat)
+	suite.file("v1/cpu.cfs_quota_us", "-1\n")
+	stat, err = readCgroupV1Cpu(filepath.Join(suite.dir, "v1"))
+	suite.NoError(err)
+	suite.Equal(cgroupCpuStat{throttled: 20, throttledTime: 3000000000}, stat)
+	suite.file("v1/cpu.stat", "nr_throttled x\n")
+	_, err = readCgroupV1Cpu(filepath.Join(suite.dir, "v1"))
+	suite.Error(err)
+
+	suite.file("v2/cpu.stat", "usage_usec 100000\nuser_usec 60000\nsystem_usec 40000\nnr_periods 500\nnr_throttled 20\nthrottled_usec 3000000\n")
+	stat, err = readCgroupV2Cpu(filepath.Join(suite.dir, "v2"))
+	suite.NoError(err)
+	suite.Equal(cgroupCpuStat{throttled: 20, throttledTime: 3000000000}, stat)
+	suite.file("v2/cpu.max", "50000 100000\n")
+	stat, err = readCgroupV2Cpu(filepath.Join(suite.dir, "v2"))
+	suite.NoError(err)
+	suite.Equal(cgroupCpuStat{throttled: 20, throttledTime: 3000000000, quota: 0.5}, stat)
+	suite.file("v2/cpu.max", "max 100000\n")
+	stat, err = readCgroupV2Cpu(filepath.Join(suite.dir, "v2"))
+	suite.NoError(err)
+	suite.Equal(cgroupCpuStat{throttled: 20, throttledTime: 3000000000}, stat)
+	suite.file("v2/cpu.max", "100000\n")
+	_, err = readCgroupV2Cpu(filepath.Join(suite.dir, "v2"))
+	suite.Error(err)
+}
+
+func (suite *ProcfsTestSuite) TestParseCpuList() {
+	cpus, err := parseCpuList("0-3,8,10-11\n")
+	suite.NoError(err)
+	suite.Equal([]int{0, 1, 2, 3, 8, 10, 11}, cpus)
+	cpus, err = parseCpuList("5")
+	suite.NoError(err)
+	suite.Equal([]int{5}, cpus)
+	for _, invalid := range []string{"x", "0-x", "1,a-3"} {
+		_, err := parseCpuList(invalid)
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *ProcfsTestSuite) TestReadConntrack() {
+	header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	sockets := make(map[uint64]connTuple)
+	suite.NoError(readProcNetSocketsFile(suite.file("tcp", header+
+		"   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 1 0000000000000000 100 0 0 10 0\n"+
+		"   1: 0200000A:D431 0100000A:0016 01 00000000:00000000 02:000A7B3D 00000000  1000        0 23456 4 0000000000000000 20 4 30 10 -1\n"+
+		"   2: 0200000A:D432 0100000A:0016 06 00000000:00000000 03:00000DE3 00000000     0        0 0 3 0000000000000000\n"), "tcp", sockets))
+	suite.NoError(readProcNetSocketsFile(suite.file("tcp6", header+
+		"   0: 0000000000000000FFFF00000200000A:0050 0000000000000000FFFF00000300000A:C350 01 00000000:00000000 00:00000000 00000000     0        0 34567 1 0000000000000000 20 4 0 10 -1\n"), "tcp", sockets))
+	suite.NoError(readProcNetSocketsFile(suite.file("udp6", header+
+		"  10: 00000000000000000000000001000000:A000 00000000000000000000000001000000:0035 01 00000000:00000000 00:00000000 00000000  1000        0 45678 2 0000000000000000 0\n"), "udp", sockets))
+	suite.Equal(map[uint64]connTuple{
+		23456: {proto: "tcp", local: "10.0.0.2", lport: 54321, remote: "10.0.0.1", rport: 22},
+		34567: {proto: "tcp", local: "10.0.0.2", lport: 80, remote: "10.0.0.3", rport: 50000},
+		45678: {proto: "udp", local: "::1", lport: 40960, remote: "::1", rport: 53},
+	}, sockets)
+	for _, invalid := range []string{"0: 0100007F:0CEA 00000000:0000 0A\n", "0: 0100007X:0CEA 00000000:0000 0A 0 0 0 0 0 1\n", "0: 0100007F 00000000:0000 0A 0 0 0 0 0 1\n"} {
+		suite.Error(readProcNetSocketsFile(suite.file("tcp", header+invalid), "tcp", sockets), invalid)
+	}
+
+	conntrack, err := readConntrackFile(suite.file("nf_conntrack",
+		"ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=54321 dport=22 packets=10 bytes=1000 src=10.0.0.1 dst=10.0.0.2 sport=22 dport=54321 packets=8 bytes=900 [ASSURED] mark=0 zone=0 use=2\n"+
+			"ipv4     2 icmp     1 29 src=10.0.0.2 dst=10.0.0.1 type=8 code=0 id=1 packets=1 bytes=84 src=10.0.0.1 dst=10.0.0.2 type=0 code=0 id=1 packets=1 bytes=84 mark=0 use=1\n"+
+			"ipv6     10 udp      17 29 src=0:0::1 dst=::1 sport=40960 dport=53 packets=1 bytes=60 src=::1 dst=::1 sport=53 dport=40960 packets=1 bytes=120 mark=0 use=2\n"))
+	suite.NoError(err)
+	outgoing := connTuple{proto: "tcp", local: "10.0.0.2", lport: 54321, remote: "10.0.0.1", rport: 22}
+	incoming := connTuple{proto: "tcp", local: "10.0.0.1", lport: 22, remote: "10.0.0.2", rport: 54321}
+	dns := connTuple{proto: "udp", local: "::1", lport: 40960, remote: "::1", rport: 53}
+	dnsServer := connTuple{proto: "udp", local: "::1", lport: 53, remote: "::1", rport: 40960}
+	suite.Equal(map[connTuple]conntrackCounters{
+		outgoing:  {txBytes: 1000, txPackets: 10, rxBytes: 900, rxPackets: 8},
+		incoming:  {txBytes: 900, txPackets: 8, rxBytes: 1000, rxPackets: 10},
+		dns:       {txBytes: 60, txPackets: 1, rxBytes: 120, rxPackets: 1},
+		dnsServer: {txBytes: 120, txPackets: 1, rxBytes: 60, rxPackets: 1},
+	}, conntrack)
+	for _, invalid := range []string{"ipv4 2 tcp 6 1 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=1 dport=2\n", "ipv4 2 tcp 6 1 src=a dst=b sport=x dport=2 src=b dst=a sport=2 dport=1\n"} {
+		_, err := readConntrackFile(suite.file("nf_conntrack", invalid))
+		suite.Error(err, invalid)
+	}
+
+	// The tuple of a closed connection is reused
+	suite.Equal(conntrackCounters{rxBytes: 100, rxPackets: 1, txBytes: 50, txPackets: 2},
+		conntrackCounters{rxBytes: 200, rxPackets: 3, txBytes: 100, txPackets: 4}.since(conntrackCounters{rxBytes: 100, rxPackets: 2, txBytes: 50, txPackets: 2}))
+	suite.Equal(conntrackCounters{rxBytes: 10, rxPackets: 1},
+		conntrackCounters{rxBytes: 10, rxPackets: 1}.since(conntrackCounters{rxBytes: 100, rxPackets: 2}))
+}
@@ -1,48 +1,151 @@
 package psutil
 
-import "github.com/bitflow-stream/go-bitflow-collector"
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Default values for the respective fields of newly created RootCollector instances
+	PidUpdateInterval = 60 * time.Second
+	PcapNics          []string
+	TaskstatsBackend  = false
+	MemoryDetail      = false
+	ConntrackTraffic  = false
+	ProcessMetricName = DefaultProcessMetricName
+
+	WritableLayerUpdateInterval = 60 * time.Second
+)
 
 type RootCollector struct {
 	collector.AbstractCollector
 
 	Factory *collector.ValueRingFactory
 
+	// Interval for refreshing the list of processes matched by process collectors
+	PidUpdateInterval time.Duration
+
+	// NICs to capture packets from for PCAP-based monitoring of process network IO
+	PcapNics []string
+
+	// Use the netlink taskstats interface of the kernel to obtain CPU times and delay accounting
+	// information of monitored processes, instead of parsing /proc/<pid>/stat. Requires the CAP_NET_ADMIN capability.
+	// The taskstats interface is queried with one netlink request per process, which avoids opening and parsing
+	// the /proc files, but is not a bulk query. Disk IO is still read from /proc/<pid>/io, because the per-process
+	// taskstats only contain the IO accounting of already terminated threads.
+	// If the taskstats interface cannot be opened, the /proc filesystem is used as fallback.
+	TaskstatsBackend bool
+
+	// Read the proportional (PSS) and unique (USS) memory and the swap usage of monitored processes from
+	// /proc/<pid>/smaps_rollup. Reading this file is considerably more expensive than the RSS in /proc/<pid>/statm,
+	// because the kernel walks all memory mappings of the process. Only available on Linux.
+	MemoryDetail bool
+
+	// Report the network traffic of the sockets of monitored processes, obtained from the connection tracking table of
+	// netfilter. Requires the nf_conntrack kernel module and enabled accounting (sysctl net.netfilter.nf_conntrack_acct=1).
+	ConntrackTraffic bool
+
+	// Template for the metric names of the process collectors created by NewProcessCollector() and
+	// NewMultiProcessCollector(), see ValidateProcessMetricName(). The placeholders {group} and {metric} are replaced
+	// by the name of the process group and the metric (e.g. cpu or mem/rss), {pid} by the lowest PID in the group and
+	// {container} by the short ID of the container of that process ("host" outside of containers, "none" if the group has
+	// no processes). The metric collection is restarted when the values of {pid} or {container} change.
+	ProcessMetricName string
+
+	// Containers to evaluate the disk usage for, inside their mount namespaces. Every container is identified
+	// by the first process with a command line matching the regex.
+	ContainerDiskUsage map[string]*regexp.Regexp
+
+	// Interval for recomputing the size of the writable overlay layer of the ContainerDiskUsage containers,
+	// which requires walking the entire directory tree of the layer.
+	WritableLayerUpdateInterval time.Duration
+
 	pids      *PidCollector
 	cpu       *CpuCollector
+	cpuStates *CpuStatesCollector
 	mem       *MemCollector
 	load      *LoadCollector
+	sessions  *SessionsCollector
 	net       *NetCollector
 	netProto  *NetProtoCollector
+	softnet   *SoftnetCollector
+	neighbors *NeighborCollector
+	sockstat  *SockstatCollector
 	diskIo    *DiskIOCollector
 	diskUsage *DiskUsageCollector
+	pcap      *pcapCollector
+	snapshot  *procSnapshot
 }
 
 func NewPsutilRootCollector(factory *collector.ValueRingFactory) *RootCollector {
 	col := &RootCollector{
 		AbstractCollector: collector.RootCollector("psutil"),
 		Factory:           factory,
+		PidUpdateInterval: PidUpdateInterval,
+		PcapNics:          PcapNics,
+		TaskstatsBackend:  TaskstatsBackend,
+		MemoryDetail:      MemoryDetail,
+		ConntrackTraffic:  ConntrackTraffic,
+		ProcessMetricName: ProcessMetricName,
+
+		WritableLayerUpdateInterval: WritableLayerUpdateInterval,
 	}
 
 	col.pids = newPidCollector(col)
 	col.cpu = newCpuCollector(col)
+	col.cpuStates = newCpuStatesCollector(col)
 	col.mem = newMemCollector(col)
 	col.load = newLoadCollector(col)
+	col.sessions = newSessionsCollector(col)
 	col.net = newNetCollector(col)
 	col.netProto = newNetProtoCollector(col)
+	col.softnet = newSoftnetCollector(col)
+	col.neighbors = newNeighborCollector(col)
+	col.sockstat = newSockstatCollector(col)
 	col.diskIo = newDiskIoCollector(col)
 	col.diskUsage = newDiskUsageCollector(col)
+	col.pcap = newPcapCollector(col)
+	col.snapshot = newProcSnapshot()
 	return col
 }
 
-func (col *RootCollector) Init() ([]collector.Collector, error) {
+func (col *RootCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if col.TaskstatsBackend && col.snapshot.taskstats == nil {
+		if client, err := newTaskstatsClient(); err != nil {
+			log.Warnln("Failed to open taskstats interface, reading process CPU times from /proc:", err)
+		} else {
+			col.snapshot.taskstats = client
+		}
+	}
 	return []collector.Collector{
 		col.pids,
 		col.cpu,
+		col.cpuStates,
 		col.mem,
 		col.load,
+		col.sessions,
 		col.net,
 		col.netProto,
+		col.softnet,
+		col.neighbors,
+		col.sockstat,
 		col.diskIo,
 		col.diskUsage,
 	}, nil
 }
+
+func (col *RootCollector) Update(ctx context.Context) error {
+	col.snapshot.reset()
+	return nil
+}
+
+func (col *RootCollector) processMetricName() string {
+	if col.ProcessMetricName == "" {
+		return DefaultProcessMetricName
+	}
+	return col.ProcessMetricName
+}
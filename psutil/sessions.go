@@ -0,0 +1,65 @@
+package psutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/shirou/gopsutil/host"
+)
+
+// SessionsCollector reports the logged-in users and their terminal sessions, as recorded in the utmp file.
+// This includes local console logins as well as remote sessions with a pseudo terminal, e.g. through SSH.
+type SessionsCollector struct {
+	collector.AbstractCollector
+	lock     sync.Mutex
+	users    int
+	sessions int
+}
+
+func newSessionsCollector(root *RootCollector) *SessionsCollector {
+	return &SessionsCollector{
+		AbstractCollector: root.Child("sessions"),
+	}
+}
+
+func (col *SessionsCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		"sessions/users": col.readUsers,
+		"sessions/tty":   col.readSessions,
+	}
+}
+
+func (col *SessionsCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"sessions/users": collector.GaugeMetric(collector.UnitCount, "Number of distinct logged-in users"),
+		"sessions/tty":   collector.GaugeMetric(collector.UnitCount, "Number of login sessions on terminals and pseudo terminals"),
+	}
+}
+
+func (col *SessionsCollector) Update(ctx context.Context) error {
+	stats, err := host.Users()
+	users := make(map[string]bool, len(stats))
+	for _, stat := range stats {
+		users[stat.User] = true
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	col.users = len(users)
+	col.sessions = len(stats)
+	return err
+}
+
+func (col *SessionsCollector) readUsers() bitflow.Value {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return bitflow.Value(col.users)
+}
+
+func (col *SessionsCollector) readSessions() bitflow.Value {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return bitflow.Value(col.sessions)
+}
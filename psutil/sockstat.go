@@ -0,0 +1,193 @@
+package psutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Kernel limits of the socket memory and counts, read from /proc/sys/net/ipv4. The memory limits contain
+// three values in pages: the minimum, the pressure threshold and the maximum.
+var sockstatLimits = map[string][]string{
+	"tcp_mem":            {"tcp/mem_min", "tcp/mem_pressure", "tcp/mem_max"},
+	"udp_mem":            {"udp/mem_min", "udp/mem_pressure", "udp/mem_max"},
+	"tcp_max_orphans":    {"tcp/orphan_max"},
+	"tcp_max_tw_buckets": {"tcp/tw_max"},
+}
+
+var sockstatDescriptions = map[string]string{
+	"sockets/used":     "Allocated sockets",
+	"tcp/inuse":        "IPv4 TCP sockets in use",
+	"tcp/orphan":       "TCP sockets that are not attached to a file descriptor",
+	"tcp/tw":           "TCP sockets in TIME_WAIT",
+	"tcp/alloc":        "Allocated TCP sockets",
+	"tcp/mem":          "Memory of all TCP socket buffers",
+	"udp/inuse":        "IPv4 UDP sockets in use",
+	"udp/mem":          "Memory of all UDP socket buffers",
+	"tcp/mem_pressure": "Memory of the TCP socket buffers above which the kernel moderates the buffers (tcp_mem)",
+	"tcp/mem_max":      "Maximum memory of the TCP socket buffers (tcp_mem)",
+	"udp/mem_pressure": "Memory of the UDP socket buffers above which the kernel moderates the buffers (udp_mem)",
+	"udp/mem_max":      "Maximum memory of the UDP socket buffers (udp_mem)",
+	"tcp/orphan_max":   "Maximum number of orphaned TCP sockets (tcp_max_orphans)",
+	"tcp/tw_max":       "Maximum number of TCP sockets in TIME_WAIT (tcp_max_tw_buckets)",
+}
+
+// SockstatCollector reports the socket counts and socket buffer memory of /proc/net/sockstat and /proc/net/sockstat6,
+// e.g. net-sockstat/tcp/inuse, net-sockstat/tcp/orphan, net-sockstat/tcp/tw (TIME_WAIT) and net-sockstat/tcp/mem.
+// The memory values are converted from pages to bytes. When the TCP memory exceeds net-sockstat/tcp/mem_pressure,
+// the kernel starts to shrink the socket buffers, and new allocations fail above net-sockstat/tcp/mem_max.
+type SockstatCollector struct {
+	collector.AbstractCollector
+
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func newSockstatCollector(root *RootCollector) *SockstatCollector {
+	return &SockstatCollector{
+		AbstractCollector: root.Child("net-sockstat"),
+	}
+}
+
+func (col *SockstatCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	values, err := readSockstat()
+	if err != nil {
+		return nil, err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	col.values = values
+	return nil, nil
+}
+
+func (col *SockstatCollector) Update(ctx context.Context) error {
+	values, err := readSockstat()
+	if err != nil {
+		return err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := len(values) != len(col.values)
+	for name := range values {
+		if _, ok := col.values[name]; !ok {
+			changed = true
+		}
+	}
+	if changed {
+		return collector.MetricsChanged
+	}
+	col.values = values
+	return nil
+}
+
+func (col *SockstatCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *SockstatCollector) Metrics() collector.MetricReaderMap {
+	res := make(collector.MetricReaderMap, len(col.values))
+	for name := range col.values {
+		name := name
+		res["net-sockstat/"+name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.values[name])
+		}
+	}
+	return res
+}
+
+func (col *SockstatCollector) MetricsMetadata() collector.MetricMetadataMap {
+	res := make(collector.MetricMetadataMap, len(col.values))
+	for name := range col.values {
+		unit := collector.UnitCount
+		if isSockstatMemory(name) {
+			unit = collector.UnitBytes
+		}
+		description, ok := sockstatDescriptions[name]
+		if !ok {
+			description = "Socket statistic " + name
+		}
+		res["net-sockstat/"+name] = collector.GaugeMetric(unit, description)
+	}
+	return res
+}
+
+// isSockstatMemory returns whether the given sockstat value is a memory size, which is given in pages by the kernel,
+// except for the memory of the IP fragment reassembly queues, which is given in bytes
+func isSockstatMemory(name string) bool {
+	return strings.HasSuffix(name, "/mem") || strings.Contains(name, "/mem_") || strings.HasSuffix(name, "/memory")
+}
+
+func readSockstat() (map[string]float64, error) {
+	values, err := parseSockstat(hostfs.Current().NamespaceProcPath("net", "sockstat"))
+	if err != nil {
+		return nil, err
+	}
+	values6, err := parseSockstat(hostfs.Current().NamespaceProcPath("net", "sockstat6"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for name, value := range values6 {
+		values[name] = value
+	}
+	for file, names := range sockstatLimits {
+		data, err := ioutil.ReadFile(hostfs.Current().ProcPath("sys", "net", "ipv4", file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) != len(names) {
+			return nil, fmt.Errorf("Unexpected content of %v: %v", file, strings.TrimSpace(string(data)))
+		}
+		for i, field := range fields {
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Unexpected content of %v: %v", file, err)
+			}
+			values[names[i]] = value
+		}
+	}
+	pageSize := float64(os.Getpagesize())
+	for name, value := range values {
+		if isSockstatMemory(name) && !strings.HasSuffix(name, "/memory") {
+			values[name] = value * pageSize
+		}
+	}
+	return values, nil
+}
+
+// parseSockstat parses a sockstat file with lines like "TCP: inuse 4 orphan 0 tw 4 alloc 4 mem 0".
+// The returned values are named <protocol>/<key>, e.g. tcp/inuse, or tcp6/inuse for the sockstat6 file.
+func parseSockstat(filename string) (map[string]float64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64)
+	for i, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || len(fields)%2 != 1 || !strings.HasSuffix(fields[0], ":") {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		protocol := strings.ToLower(strings.TrimSuffix(fields[0], ":"))
+		for j := 1; j < len(fields); j += 2 {
+			value, err := strconv.ParseFloat(fields[j+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+			}
+			res[protocol+"/"+fields[j]] = value
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type SockstatTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSockstat(t *testing.T) {
+	suite.Run(t, new(SockstatTestSuite))
+}
+
+func (suite *SockstatTestSuite) TestParseSockstat() {
+	dir, err := ioutil.TempDir("", "sockstat")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "sockstat")
+	suite.NoError(ioutil.WriteFile(file, []byte(
+		"sockets: used 16\n"+
+			"TCP: inuse 4 orphan 1 tw 12 alloc 5 mem 3\n"+
+			"FRAG: inuse 0 memory 0\n"), 0644))
+	values, err := parseSockstat(file)
+	suite.NoError(err)
+	suite.Equal(map[string]float64{
+		"sockets/used": 16,
+		"tcp/inuse":    4,
+		"tcp/orphan":   1,
+		"tcp/tw":       12,
+		"tcp/alloc":    5,
+		"tcp/mem":      3,
+		"frag/inuse":   0,
+		"frag/memory":  0,
+	}, values)
+
+	suite.NoError(ioutil.WriteFile(file, []byte("TCP6: inuse 2\n"), 0644))
+	values, err = parseSockstat(file)
+	suite.NoError(err)
+	suite.Equal(map[string]float64{"tcp6/inuse": 2}, values)
+
+	for _, invalid := range []string{
+		"TCP: inuse\n",
+		"TCP inuse 4\n",
+		"TCP: inuse 4 orphan\n",
+		"TCP: inuse x\n",
+	} {
+		suite.NoError(ioutil.WriteFile(file, []byte(invalid), 0644))
+		_, err := parseSockstat(file)
+		suite.Error(err, invalid)
+	}
+
+	suite.True(isSockstatMemory("tcp/mem"))
+	suite.True(isSockstatMemory("tcp/mem_max"))
+	suite.True(isSockstatMemory("frag/memory"))
+	suite.False(isSockstatMemory("tcp/tw"))
+	suite.False(isSockstatMemory("tcp/orphan_max"))
+}
@@ -0,0 +1,178 @@
+package psutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+)
+
+// Columns of /proc/net/softnet_stat, see softnet_seq_show() in net/core/net-procfs.c
+const (
+	softnetProcessed   = 0
+	softnetDropped     = 1
+	softnetTimeSqueeze = 2
+	softnetCpuIndex    = 12 // Only available since Linux 5.10
+)
+
+// softnetCounters are the counters of one line in /proc/net/softnet_stat
+type softnetCounters struct {
+	cpu         int
+	processed   uint64
+	dropped     uint64
+	timeSqueeze uint64
+}
+
+// SoftnetCollector collects the packet processing statistics of the kernel network stack per CPU. In contrast to the
+// interface counters collected by NetCollector, the dropped counter includes packets that were dropped because the
+// backlog queue of a CPU was full, and time_squeeze counts the times the packet processing had to be interrupted
+// before the queue was empty, because the budget or time limit of the softirq was exhausted.
+type SoftnetCollector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	total softnetRings
+	cpus  map[int]softnetRings
+}
+
+type softnetRings struct {
+	processed   *collector.ValueRing
+	dropped     *collector.ValueRing
+	timeSqueeze *collector.ValueRing
+}
+
+func newSoftnetCollector(root *RootCollector) *SoftnetCollector {
+	return &SoftnetCollector{
+		AbstractCollector: root.Child("net-softnet"),
+		factory:           root.Factory,
+	}
+}
+
+func (col *SoftnetCollector) newRings() softnetRings {
+	return softnetRings{
+		processed:   col.factory.NewValueRing(),
+		dropped:     col.factory.NewValueRing(),
+		timeSqueeze: col.factory.NewValueRing(),
+	}
+}
+
+func (col *SoftnetCollector) Init(ctx context.Context) ([]collector.Collector, error) {
+	counters, err := readSoftnetCounters(hostfs.Current().ProcPath("net", "softnet_stat"))
+	if err != nil {
+		return nil, err
+	}
+	col.total = col.newRings()
+	col.cpus = make(map[int]softnetRings, len(counters))
+	for _, cpu := range counters {
+		col.cpus[cpu.cpu] = col.newRings()
+	}
+	return nil, nil
+}
+
+func (col *SoftnetCollector) Metrics() collector.MetricReaderMap {
+	res := col.total.metrics("net-softnet")
+	for cpu, rings := range col.cpus {
+		for name, reader := range rings.metrics(fmt.Sprintf("net-softnet/cpu/%v", cpu)) {
+			res[name] = reader
+		}
+	}
+	return res
+}
+
+func (col *SoftnetCollector) MetricsMetadata() collector.MetricMetadataMap {
+	res := softnetMetadata("net-softnet", "all CPUs")
+	for cpu := range col.cpus {
+		for name, metadata := range softnetMetadata(fmt.Sprintf("net-softnet/cpu/%v", cpu), fmt.Sprintf("CPU %v", cpu)) {
+			res[name] = metadata
+		}
+	}
+	return res
+}
+
+func (col *SoftnetCollector) Update(ctx context.Context) error {
+	counters, err := readSoftnetCounters(hostfs.Current().ProcPath("net", "softnet_stat"))
+	if err != nil {
+		return err
+	}
+	if len(counters) != len(col.cpus) {
+		return collector.MetricsChanged
+	}
+	var total softnetCounters
+	for _, cpu := range counters {
+		rings, ok := col.cpus[cpu.cpu]
+		if !ok {
+			return collector.MetricsChanged
+		}
+		rings.add(cpu)
+		total.processed += cpu.processed
+		total.dropped += cpu.dropped
+		total.timeSqueeze += cpu.timeSqueeze
+	}
+	col.total.add(total)
+	return nil
+}
+
+func (col *SoftnetCollector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (rings softnetRings) add(counters softnetCounters) {
+	rings.processed.Add(collector.StoredValue(counters.processed))
+	rings.dropped.Add(collector.StoredValue(counters.dropped))
+	rings.timeSqueeze.Add(collector.StoredValue(counters.timeSqueeze))
+}
+
+func (rings softnetRings) metrics(prefix string) collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		prefix + "/processed":    rings.processed.GetDiff,
+		prefix + "/dropped":      rings.dropped.GetDiff,
+		prefix + "/time_squeeze": rings.timeSqueeze.GetDiff,
+	}
+}
+
+func softnetMetadata(prefix string, cpus string) collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		prefix + "/processed":    collector.DerivedMetric(collector.UnitPerSecond, "Packets processed by the network stack on "+cpus),
+		prefix + "/dropped":      collector.DerivedMetric(collector.UnitPerSecond, "Packets dropped due to a full backlog queue on "+cpus),
+		prefix + "/time_squeeze": collector.DerivedMetric(collector.UnitPerSecond, "Interruptions of the packet processing due to an exhausted budget on "+cpus),
+	}
+}
+
+// readSoftnetCounters parses the given softnet_stat file. Every line contains the hexadecimal counters of one online
+// CPU. Older kernels do not include the CPU index in the last column, in which case the line number is used.
+// Since offline CPUs are skipped, the line number can differ from the actual CPU index.
+func readSoftnetCounters(filename string) ([]softnetCounters, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	res := make([]softnetCounters, 0, len(lines))
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) <= softnetTimeSqueeze {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		values := make([]uint64, len(fields))
+		for j, field := range fields {
+			if values[j], err = strconv.ParseUint(field, 16, 64); err != nil {
+				return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+			}
+		}
+		counters := softnetCounters{
+			cpu:         i,
+			processed:   values[softnetProcessed],
+			dropped:     values[softnetDropped],
+			timeSqueeze: values[softnetTimeSqueeze],
+		}
+		if len(values) > softnetCpuIndex {
+			counters.cpu = int(values[softnetCpuIndex])
+		}
+		res = append(res, counters)
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type SoftnetTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSoftnet(t *testing.T) {
+	suite.Run(t, new(SoftnetTestSuite))
+}
+
+func (suite *SoftnetTestSuite) TestReadSoftnetCounters() {
+	dir, err := ioutil.TempDir("", "softnet")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "softnet_stat")
+
+	// Old format without CPU index
+	suite.NoError(ioutil.WriteFile(file, []byte(
+		"0000a1b2 00000003 0000001f 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000\n"+
+			"00000010 00000000 00000002 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000\n"), 0644))
+	counters, err := readSoftnetCounters(file)
+	suite.NoError(err)
+	suite.Equal([]softnetCounters{
+		{cpu: 0, processed: 0xa1b2, dropped: 3, timeSqueeze: 0x1f},
+		{cpu: 1, processed: 0x10, dropped: 0, timeSqueeze: 2},
+	}, counters)
+
+	// New format, CPU 1 is offline
+	suite.NoError(ioutil.WriteFile(file, []byte(
+		"00000001 00000002 00000003 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000\n"+
+			"00000004 00000005 00000006 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000002\n"), 0644))
+	counters, err = readSoftnetCounters(file)
+	suite.NoError(err)
+	suite.Equal([]softnetCounters{
+		{cpu: 0, processed: 1, dropped: 2, timeSqueeze: 3},
+		{cpu: 2, processed: 4, dropped: 5, timeSqueeze: 6},
+	}, counters)
+
+	for _, invalid := range []string{
+		"",
+		"00000001 00000002\n",
+		"00000001 00000002 0000000x\n",
+	} {
+		suite.NoError(ioutil.WriteFile(file, []byte(invalid), 0644))
+		_, err := readSoftnetCounters(file)
+		suite.Error(err, invalid)
+	}
+}
@@ -0,0 +1,14 @@
+package psutil
+
+// taskstats contains the relevant fields of the kernel struct taskstats.
+// Delays are in nanoseconds, CPU times in microseconds.
+type taskstats struct {
+	cpuDelayTotal        uint64
+	blkioDelayTotal      uint64
+	swapinDelayTotal     uint64
+	freepagesDelayTotal  uint64
+	utime                uint64
+	stime                uint64
+	voluntaryCtxSwitch   uint64
+	involuntaryCtxSwitch uint64
+}
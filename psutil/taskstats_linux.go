@@ -0,0 +1,206 @@
+package psutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Constants from linux/genetlink.h and linux/taskstats.h
+const (
+	genlIdCtrl            = 0x10
+	genlCtrlCmdGetFamily  = 3
+	genlCtrlAttrFamilyId  = 1
+	genlCtrlAttrFamilyNam = 2
+	genlHeaderLen         = 4
+	nlaHeaderLen          = 4
+
+	taskstatsFamilyName    = "TASKSTATS"
+	taskstatsGenlVersion   = 1
+	taskstatsCmdGet        = 1
+	taskstatsCmdAttrTgid   = 2
+	taskstatsTypeStats     = 3
+	taskstatsTypeAggrTgid  = 5
+	taskstatsMinStructSize = 328 // Size of struct taskstats up to freepages_delay_total (version 8)
+)
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// taskstatsClient queries the accounting information of the kernel through the generic netlink
+// taskstats interface. Opening the connection requires the CAP_NET_ADMIN capability.
+type taskstatsClient struct {
+	lock     sync.Mutex
+	fd       int
+	familyId uint16
+	seq      uint32
+	buf      []byte
+}
+
+func newTaskstatsClient() (*taskstatsClient, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open generic netlink socket: %v", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("Failed to bind generic netlink socket: %v", err)
+	}
+	client := &taskstatsClient{
+		fd:  fd,
+		buf: make([]byte, os.Getpagesize()),
+	}
+	if client.familyId, err = client.resolveFamily(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (c *taskstatsClient) Close() {
+	if err := syscall.Close(c.fd); err != nil {
+		log.Warnln("Failed to close taskstats netlink socket:", err)
+	}
+}
+
+func (c *taskstatsClient) resolveFamily() (uint16, error) {
+	name := append([]byte(taskstatsFamilyName), 0)
+	attrs, err := c.request(genlIdCtrl, genlCtrlCmdGetFamily, genlCtrlAttrFamilyNam, name)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to resolve the %v netlink family: %v", taskstatsFamilyName, err)
+	}
+	if id, ok := attrs[genlCtrlAttrFamilyId]; ok && len(id) >= 2 {
+		return nativeEndian.Uint16(id), nil
+	}
+	return 0, fmt.Errorf("Netlink family %v not found", taskstatsFamilyName)
+}
+
+// tgidStats returns the accumulated accounting information for all threads of the given process.
+// Every call is a separate netlink request. The kernel accumulates the CPU times, context switches and delays
+// of all threads, but the IO accounting fields only contain the values of terminated threads, so they are not parsed.
+func (c *taskstatsClient) tgidStats(tgid int32) (*taskstats, error) {
+	pidBytes := make([]byte, 4)
+	nativeEndian.PutUint32(pidBytes, uint32(tgid))
+	attrs, err := c.request(c.familyId, taskstatsCmdGet, taskstatsCmdAttrTgid, pidBytes)
+	if err != nil {
+		return nil, err
+	}
+	aggr, ok := attrs[taskstatsTypeAggrTgid]
+	if !ok {
+		return nil, fmt.Errorf("Taskstats response for %v does not contain aggregated stats", tgid)
+	}
+	stats, ok := parseNetlinkAttributes(aggr)[taskstatsTypeStats]
+	if !ok {
+		return nil, fmt.Errorf("Taskstats response for %v does not contain stats", tgid)
+	}
+	return parseTaskstats(stats)
+}
+
+// request sends a generic netlink message with a single attribute and returns the attributes of the response.
+func (c *taskstatsClient) request(family uint16, cmd uint8, attrType uint16, attrData []byte) (map[uint16][]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.seq++
+
+	attrLen := nlaHeaderLen + len(attrData)
+	msgLen := syscall.NLMSG_HDRLEN + genlHeaderLen + nlaAlign(attrLen)
+	msg := make([]byte, msgLen)
+	nativeEndian.PutUint32(msg[0:4], uint32(msgLen))
+	nativeEndian.PutUint16(msg[4:6], family)
+	nativeEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST)
+	nativeEndian.PutUint32(msg[8:12], c.seq)
+	nativeEndian.PutUint32(msg[12:16], 0)
+	msg[16] = cmd
+	msg[17] = taskstatsGenlVersion
+	attr := msg[syscall.NLMSG_HDRLEN+genlHeaderLen:]
+	nativeEndian.PutUint16(attr[0:2], uint16(attrLen))
+	nativeEndian.PutUint16(attr[2:4], attrType)
+	copy(attr[nlaHeaderLen:], attrData)
+
+	if err := syscall.Sendto(c.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+	for {
+		n, _, err := syscall.Recvfrom(c.fd, c.buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		messages, err := syscall.ParseNetlinkMessage(c.buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, response := range messages {
+			if response.Header.Seq != c.seq {
+				// Response to an earlier, interrupted request
+				continue
+			}
+			if response.Header.Type == syscall.NLMSG_ERROR {
+				if len(response.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(response.Data[0:4])); errno != 0 {
+						return nil, syscall.Errno(-errno)
+					}
+				}
+				return nil, fmt.Errorf("Empty netlink response")
+			}
+			if len(response.Data) < genlHeaderLen {
+				return nil, fmt.Errorf("Received truncated generic netlink message (%v bytes)", len(response.Data))
+			}
+			return parseNetlinkAttributes(response.Data[genlHeaderLen:]), nil
+		}
+	}
+}
+
+func nlaAlign(length int) int {
+	return (length + syscall.NLA_ALIGNTO - 1) & ^(syscall.NLA_ALIGNTO - 1)
+}
+
+func parseNetlinkAttributes(data []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+	for len(data) >= nlaHeaderLen {
+		length := int(nativeEndian.Uint16(data[0:2]))
+		attrType := nativeEndian.Uint16(data[2:4]) & ^uint16(syscall.NLA_F_NESTED|syscall.NLA_F_NET_BYTEORDER)
+		if length < nlaHeaderLen || length > len(data) {
+			break
+		}
+		attrs[attrType] = data[nlaHeaderLen:length]
+		if aligned := nlaAlign(length); aligned < len(data) {
+			data = data[aligned:]
+		} else {
+			break
+		}
+	}
+	return attrs
+}
+
+func parseTaskstats(data []byte) (*taskstats, error) {
+	if len(data) < taskstatsMinStructSize {
+		return nil, fmt.Errorf("Received truncated taskstats struct (%v bytes, expected at least %v)", len(data), taskstatsMinStructSize)
+	}
+	u64 := func(offset int) uint64 {
+		return nativeEndian.Uint64(data[offset : offset+8])
+	}
+	// Offsets of the fields in struct taskstats, see linux/taskstats.h
+	return &taskstats{
+		cpuDelayTotal:        u64(24),
+		blkioDelayTotal:      u64(40),
+		swapinDelayTotal:     u64(56),
+		utime:                u64(152),
+		stime:                u64(160),
+		voluntaryCtxSwitch:   u64(272),
+		involuntaryCtxSwitch: u64(280),
+		freepagesDelayTotal:  u64(320),
+	}, nil
+}
@@ -0,0 +1,19 @@
+// +build !linux
+
+package psutil
+
+import "errors"
+
+type taskstatsClient struct {
+}
+
+func newTaskstatsClient() (*taskstatsClient, error) {
+	return nil, errors.New("The taskstats interface is only available on Linux")
+}
+
+func (c *taskstatsClient) Close() {
+}
+
+func (c *taskstatsClient) tgidStats(tgid int32) (*taskstats, error) {
+	return nil, errors.New("The taskstats interface is only available on Linux")
+}
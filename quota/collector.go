@@ -0,0 +1,207 @@
+package quota
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	DefaultRepquota = "repquota"
+
+	// repquota reports the block usage and limits in units of 1 KiB
+	blockSize = 1024
+)
+
+var (
+	QuotaTypes        = []string{"user", "group", "project"}
+	DefaultQuotaTypes = []string{"user", "group"}
+
+	repquotaFlags = map[string]string{
+		"user":    "-u",
+		"group":   "-g",
+		"project": "-P",
+	}
+)
+
+// Filesystem is a mount point for which the usage and limits of the given quota types are reported
+type Filesystem struct {
+	Mountpoint string
+	Types      []string
+}
+
+// ParseFilesystem parses a filesystem in the format <mountpoint>[:<type>,<type>...], e.g. /home:user,project.
+// The quota types default to DefaultQuotaTypes.
+func ParseFilesystem(str string) (Filesystem, error) {
+	fs := Filesystem{Mountpoint: str, Types: DefaultQuotaTypes}
+	if index := strings.LastIndex(str, ":"); index >= 0 {
+		fs.Mountpoint = str[:index]
+		fs.Types = strings.Split(str[index+1:], ",")
+		for _, quotaType := range fs.Types {
+			if _, ok := repquotaFlags[quotaType]; !ok {
+				return fs, fmt.Errorf("Unknown quota type '%v' in '%v', available: %v", quotaType, str, strings.Join(QuotaTypes, ","))
+			}
+		}
+	}
+	if fs.Mountpoint == "" {
+		return fs, fmt.Errorf("Missing mount point in '%v'", str)
+	}
+	return fs, nil
+}
+
+// Collector reports the space and file usage and limits of all users, groups or projects with quotas on the configured
+// filesystems. The quotas are read through 'repquota', which requires root privileges. Metrics are named
+// quota/<filesystem>/<type>/<name>/..., e.g. quota/home/user/alice/space. Limits of 0 mean that no limit is set.
+type Collector struct {
+	collector.AbstractCollector
+	Filesystems []Filesystem
+	Repquota    string
+
+	lock     sync.Mutex
+	values   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewQuotaCollector(filesystems []Filesystem) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("quota"),
+		Filesystems:       filesystems,
+		Repquota:          DefaultRepquota,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.values = nil
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.values))
+	for name := range col.values {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.values[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	values := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	for _, fs := range col.Filesystems {
+		for _, quotaType := range fs.Types {
+			if err := col.readQuotas(ctx, fs.Mountpoint, quotaType, values, metadata); err != nil {
+				return err
+			}
+		}
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.values == nil || len(values) != len(col.values)
+	for name := range values {
+		if _, ok := col.values[name]; !ok {
+			changed = true
+		}
+	}
+	col.values = values
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+// readQuotas parses the output of 'repquota -O csv', which starts with a header line like:
+// User,BlockStatus,FileStatus,BlockUsed,BlockSoftLimit,BlockHardLimit,BlockGrace,FileUsed,FileSoftLimit,FileHardLimit,FileGrace
+func (col *Collector) readQuotas(ctx context.Context, mountpoint string, quotaType string, values map[string]float64, metadata collector.MetricMetadataMap) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, col.Repquota, "-O", "csv", repquotaFlags[quotaType], mountpoint)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%v %v %v failed: %v (%v)", col.Repquota, repquotaFlags[quotaType], mountpoint, err, strings.TrimSpace(stderr.String()))
+	}
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("Failed to parse %v quotas of %v: %v", quotaType, mountpoint, err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	columns := make(map[string]int, len(records[0]))
+	for i, column := range records[0] {
+		columns[column] = i
+	}
+
+	prefix := "quota/" + filesystemName(mountpoint) + "/" + quotaType + "/"
+	for _, record := range records[1:] {
+		if len(record) != len(records[0]) {
+			continue
+		}
+		name := strings.TrimPrefix(record[0], "#")
+		for _, metric := range []struct {
+			name   string
+			column string
+			factor float64
+			unit   string
+			desc   string
+		}{
+			{"space", "BlockUsed", blockSize, collector.UnitBytes, "Used space"},
+			{"space/soft", "BlockSoftLimit", blockSize, collector.UnitBytes, "Soft limit of the used space"},
+			{"space/hard", "BlockHardLimit", blockSize, collector.UnitBytes, "Hard limit of the used space"},
+			{"files", "FileUsed", 1, collector.UnitCount, "Used inodes"},
+			{"files/soft", "FileSoftLimit", 1, collector.UnitCount, "Soft limit of the used inodes"},
+			{"files/hard", "FileHardLimit", 1, collector.UnitCount, "Hard limit of the used inodes"},
+		} {
+			index, ok := columns[metric.column]
+			if !ok {
+				return fmt.Errorf("Missing column %v in the output of %v", metric.column, col.Repquota)
+			}
+			value, err := strconv.ParseFloat(record[index], 64)
+			if err != nil {
+				return fmt.Errorf("Failed to parse %v of %v %v on %v: %v", metric.column, quotaType, name, mountpoint, err)
+			}
+			values[prefix+name+"/"+metric.name] = value * metric.factor
+			metadata[prefix+name+"/"+metric.name] = collector.GaugeMetric(metric.unit, metric.desc)
+		}
+	}
+	return nil
+}
+
+func filesystemName(mountpoint string) string {
+	name := strings.Trim(mountpoint, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
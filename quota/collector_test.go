@@ -0,0 +1,185 @@
+package quota
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuotaTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestQuota(t *testing.T) {
+	suite.Run(t, new(QuotaTestSuite))
+}
+
+const repquotaHeader = "User,BlockStatus,FileStatus,BlockUsed,BlockSoftLimit,BlockHardLimit,BlockGrace,FileUsed,FileSoftLimit,FileHardLimit,FileGrace\n"
+
+// newCollector returns a Collector that executes a script instead of repquota. The script prints the file quota<flag>
+// in the given directory, e.g. quota-u for user quotas, and fails if the file does not exist.
+func (suite *QuotaTestSuite) newCollector(dir string, outputs map[string]string, filesystems ...Filesystem) *Collector {
+	for flag, output := range outputs {
+		suite.NoError(ioutil.WriteFile(filepath.Join(dir, "quota"+flag), []byte(output), 0644))
+	}
+	script := filepath.Join(dir, "repquota")
+	suite.NoError(ioutil.WriteFile(script, []byte("#!/bin/sh\ncat \"$(dirname \"$0\")/quota$3\"\n"), 0755))
+	col := NewQuotaCollector(filesystems)
+	col.Repquota = script
+	return col
+}
+
+func (suite *QuotaTestSuite) TestParseFilesystem() {
+	for _, test := range []struct {
+		input    string
+		expected Filesystem
+		err      bool
+	}{
+		{input: "/home", expected: Filesystem{Mountpoint: "/home", Types: DefaultQuotaTypes}},
+		{input: "/home:user", expected: Filesystem{Mountpoint: "/home", Types: []string{"user"}}},
+		{input: "/srv/data:user,project", expected: Filesystem{Mountpoint: "/srv/data", Types: []string{"user", "project"}}},
+		{input: "/home:inode", err: true},
+		{input: "/home:", err: true},
+		{input: ":user", err: true},
+		{input: "", err: true},
+	} {
+		fs, err := ParseFilesystem(test.input)
+		if test.err {
+			suite.Error(err, test.input)
+		} else {
+			suite.NoError(err, test.input)
+			suite.Equal(test.expected, fs, test.input)
+		}
+	}
+}
+
+func (suite *QuotaTestSuite) TestFilesystemName() {
+	for _, test := range []struct {
+		mountpoint string
+		expected   string
+	}{
+		{"/", "root"},
+		{"/home", "home"},
+		{"/srv/data/", "srv_data"},
+		{"/mnt/my disk", "mnt_my_disk"},
+	} {
+		suite.Equal(test.expected, filesystemName(test.mountpoint), test.mountpoint)
+	}
+}
+
+func (suite *QuotaTestSuite) TestCollect() {
+	for _, test := range []struct {
+		name        string
+		outputs     map[string]string
+		filesystems []Filesystem
+		expected    map[string]float64
+		err         bool
+	}{
+		{
+			name: "users and groups",
+			outputs: map[string]string{
+				"-u": repquotaHeader +
+					"root,ok,ok,20,0,0,,5,0,0,\n" +
+					"alice,soft,ok,1500,1000,2000,6days,10,100,200,\n",
+				"-g": repquotaHeader + "#1001,ok,ok,0,0,0,,0,0,0,\n",
+			},
+			filesystems: []Filesystem{{Mountpoint: "/home", Types: DefaultQuotaTypes}},
+			expected: map[string]float64{
+				"quota/home/user/root/space":       20 * blockSize,
+				"quota/home/user/root/space/soft":  0,
+				"quota/home/user/root/space/hard":  0,
+				"quota/home/user/root/files":       5,
+				"quota/home/user/root/files/soft":  0,
+				"quota/home/user/root/files/hard":  0,
+				"quota/home/user/alice/space":      1500 * blockSize,
+				"quota/home/user/alice/space/soft": 1000 * blockSize,
+				"quota/home/user/alice/space/hard": 2000 * blockSize,
+				"quota/home/user/alice/files":      10,
+				"quota/home/user/alice/files/soft": 100,
+				"quota/home/user/alice/files/hard": 200,
+				"quota/home/group/1001/space":      0,
+				"quota/home/group/1001/space/soft": 0,
+				"quota/home/group/1001/space/hard": 0,
+				"quota/home/group/1001/files":      0,
+				"quota/home/group/1001/files/soft": 0,
+				"quota/home/group/1001/files/hard": 0,
+			},
+		},
+		{
+			name:        "no quotas",
+			outputs:     map[string]string{"-P": repquotaHeader, "-u": ""},
+			filesystems: []Filesystem{{Mountpoint: "/", Types: []string{"project"}}, {Mountpoint: "/srv", Types: []string{"user"}}},
+			expected:    map[string]float64{},
+		},
+		{
+			name:        "incomplete line",
+			outputs:     map[string]string{"-u": repquotaHeader + "alice,ok,ok,10\n"},
+			filesystems: []Filesystem{{Mountpoint: "/", Types: []string{"user"}}},
+			expected:    map[string]float64{},
+		},
+		{
+			name:        "repquota failure",
+			filesystems: []Filesystem{{Mountpoint: "/", Types: []string{"user"}}},
+			err:         true,
+		},
+		{
+			name:        "missing column",
+			outputs:     map[string]string{"-u": "User,BlockUsed\nalice,10\n"},
+			filesystems: []Filesystem{{Mountpoint: "/", Types: []string{"user"}}},
+			err:         true,
+		},
+		{
+			name:        "invalid value",
+			outputs:     map[string]string{"-u": repquotaHeader + "alice,ok,ok,x,0,0,,0,0,0,\n"},
+			filesystems: []Filesystem{{Mountpoint: "/", Types: []string{"user"}}},
+			err:         true,
+		},
+	} {
+		func() {
+			dir, err := ioutil.TempDir("", "quota")
+			suite.NoError(err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			col := suite.newCollector(dir, test.outputs, test.filesystems...)
+			_, err = col.Init(context.Background())
+			if test.err {
+				suite.Error(err, test.name)
+				return
+			}
+			suite.NoError(err, test.name)
+
+			values := make(map[string]float64)
+			for name, reader := range col.Metrics() {
+				values[name] = float64(reader())
+			}
+			suite.Equal(test.expected, values, test.name)
+			suite.Len(col.MetricsMetadata(), len(test.expected), test.name)
+			suite.NoError(col.Update(context.Background()), test.name)
+		}()
+	}
+}
+
+func (suite *QuotaTestSuite) TestQuotasChanged() {
+	dir, err := ioutil.TempDir("", "quota")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	fs := Filesystem{Mountpoint: "/home", Types: []string{"user"}}
+	col := suite.newCollector(dir, map[string]string{"-u": repquotaHeader + "alice,ok,ok,10,0,0,,1,0,0,\n"}, fs)
+	_, err = col.Init(context.Background())
+	suite.NoError(err)
+	suite.NoError(col.MetricsChanged(context.Background()))
+
+	suite.newCollector(dir, map[string]string{"-u": repquotaHeader + "bob,ok,ok,10,0,0,,1,0,0,\n"}, fs)
+	suite.Equal(collector.MetricsChanged, col.Update(context.Background()))
+	suite.NoError(col.Update(context.Background()))
+	suite.Contains(col.Metrics(), "quota/home/user/bob/space")
+}
@@ -0,0 +1,67 @@
+package rapl
+
+import (
+	"context"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+)
+
+// Collector reports the power consumption of the RAPL (Running Average Power Limit) domains of Intel and AMD CPUs,
+// computed from the energy counters in /sys/class/powercap/intel-rapl:*. Metrics are named rapl/<package> for the
+// package domains, e.g. rapl/package-0, and rapl/<package>/<domain> for their subdomains, e.g. rapl/package-0/dram,
+// rapl/package-0/core and rapl/package-0/uncore. On some systems, the platform domain rapl/psys covers the entire
+// SoC including the packages. Reading the energy counters requires root privileges on most kernels.
+type Collector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	zones []*zone
+}
+
+func NewRaplCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("rapl"),
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	zones, err := readZones(hostfs.Current().SysPath("class", "powercap"))
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range zones {
+		zone.ring = col.factory.NewValueRing()
+		if err := zone.update(); err != nil {
+			return nil, err
+		}
+	}
+	col.zones = zones
+	return nil, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	for _, zone := range col.zones {
+		if err := zone.update(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	res := make(collector.MetricReaderMap, len(col.zones))
+	for _, zone := range col.zones {
+		res["rapl/"+zone.name] = zone.ring.GetDiff
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	res := make(collector.MetricMetadataMap, len(col.zones))
+	for _, zone := range col.zones {
+		res["rapl/"+zone.name] = collector.DerivedMetric("watts", "Power consumption of the RAPL domain "+zone.name)
+	}
+	return res
+}
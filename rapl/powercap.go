@@ -0,0 +1,104 @@
+package rapl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
+
+const zonePrefix = "intel-rapl:"
+
+// zone is a RAPL domain in the powercap class, e.g. /sys/class/powercap/intel-rapl:0 for the first package
+// or /sys/class/powercap/intel-rapl:0:1 for its second subdomain
+type zone struct {
+	name       string
+	energyFile string
+	maxEnergy  uint64 // Value in microjoules at which the energy counter wraps around
+
+	hasLast bool
+	last    uint64
+	total   float64 // Joules since the first update, accounting for wraparounds of the counter
+	ring    *collector.ValueRing
+}
+
+// readZones returns the RAPL zones in the given directory, usually /sys/class/powercap. The MMIO interface
+// (intel-rapl-mmio:*) is not included, since it duplicates the package domains of the MSR interface.
+func readZones(dir string) ([]*zone, error) {
+	dirs, err := filepath.Glob(filepath.Join(dir, zonePrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(dirs)
+	names := make(map[string]string, len(dirs)) // Zone ID -> name
+	for _, zoneDir := range dirs {
+		name, err := ioutil.ReadFile(filepath.Join(zoneDir, "name"))
+		if err != nil {
+			return nil, err
+		}
+		names[strings.TrimPrefix(filepath.Base(zoneDir), zonePrefix)] = strings.TrimSpace(string(name))
+	}
+
+	res := make([]*zone, 0, len(dirs))
+	for _, zoneDir := range dirs {
+		id := strings.TrimPrefix(filepath.Base(zoneDir), zonePrefix)
+		name := names[id]
+		if index := strings.LastIndex(id, ":"); index >= 0 {
+			parent, ok := names[id[:index]]
+			if !ok {
+				return nil, fmt.Errorf("Missing parent zone of RAPL zone %v", filepath.Base(zoneDir))
+			}
+			name = parent + "/" + name
+		}
+		maxEnergy, err := readUint(filepath.Join(zoneDir, "max_energy_range_uj"))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &zone{
+			name:       name,
+			energyFile: filepath.Join(zoneDir, "energy_uj"),
+			maxEnergy:  maxEnergy,
+		})
+	}
+	return res, nil
+}
+
+func (z *zone) update() error {
+	energy, err := readUint(z.energyFile)
+	if err != nil {
+		return err
+	}
+	z.add(energy)
+	z.ring.Add(collector.StoredValue(z.total))
+	return nil
+}
+
+// add accounts the given value of the energy counter in microjoules to the total energy of the zone
+func (z *zone) add(energy uint64) {
+	if z.hasLast {
+		delta := energy - z.last
+		if energy < z.last {
+			// The counter wrapped around
+			delta = z.maxEnergy - z.last + energy
+		}
+		z.total += float64(delta) / 1e6
+	}
+	z.last = energy
+	z.hasLast = true
+}
+
+func readUint(filename string) (uint64, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse %v: %v", filename, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,65 @@
+package rapl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type RaplTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestRapl(t *testing.T) {
+	suite.Run(t, new(RaplTestSuite))
+}
+
+func (suite *RaplTestSuite) TestReadZones() {
+	dir, err := ioutil.TempDir("", "powercap")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	write := func(content string, path ...string) {
+		file := filepath.Join(append([]string{dir}, path...)...)
+		suite.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+		suite.NoError(ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	write("package-0\n", "intel-rapl:0", "name")
+	write("262143328850\n", "intel-rapl:0", "max_energy_range_uj")
+	write("dram\n", "intel-rapl:0:0", "name")
+	write("65712999613\n", "intel-rapl:0:0", "max_energy_range_uj")
+	write("package-0\n", "intel-rapl-mmio:0", "name")
+	write("262143328850\n", "intel-rapl-mmio:0", "max_energy_range_uj")
+
+	zones, err := readZones(dir)
+	suite.NoError(err)
+	suite.Len(zones, 2)
+	suite.Equal("package-0", zones[0].name)
+	suite.Equal(filepath.Join(dir, "intel-rapl:0", "energy_uj"), zones[0].energyFile)
+	suite.Equal(uint64(262143328850), zones[0].maxEnergy)
+	suite.Equal("package-0/dram", zones[1].name)
+	suite.Equal(uint64(65712999613), zones[1].maxEnergy)
+
+	write("invalid\n", "intel-rapl:0:0", "max_energy_range_uj")
+	_, err = readZones(dir)
+	suite.Error(err)
+	write("1000\n", "intel-rapl:0:0", "max_energy_range_uj")
+	write("core\n", "intel-rapl:1:0", "name")
+	_, err = readZones(dir)
+	suite.Error(err)
+}
+
+func (suite *RaplTestSuite) TestWraparound() {
+	z := &zone{maxEnergy: 10000000}
+	z.add(9000000)
+	suite.Equal(0.0, z.total)
+	z.add(9500000)
+	suite.Equal(0.5, z.total)
+	z.add(1500000)
+	suite.Equal(2.5, z.total)
+}
@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/antongulenko/golib"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadableConfig contains the fields of a SampleSource that can be changed while it is running, see Reconfigure().
+type ReloadableConfig struct {
+	ExcludeMetrics []*regexp.Regexp
+	IncludeMetrics []*regexp.Regexp
+	Tags           []*TagTemplate
+}
+
+// reloadState holds the configuration passed to Reconfigure(), until it is applied by the next (re)start
+// of the metric collection
+type reloadState struct {
+	lock    sync.Mutex
+	pending *ReloadableConfig
+	notify  chan struct{}
+}
+
+// Reconfigure replaces the metric filters and tags of the SampleSource. If the SampleSource is running, the metric
+// collection is restarted with the new configuration, like after a change of the FilterFile: the collectors are
+// initialized again and the sample stream continues with a new header. Can be called concurrently.
+func (source *SampleSource) Reconfigure(config ReloadableConfig) {
+	state := &source.reload
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.pending = &config
+	select {
+	case state.channel() <- struct{}{}:
+	default:
+	}
+}
+
+func (state *reloadState) channel() chan struct{} {
+	if state.notify == nil {
+		state.notify = make(chan struct{}, 1)
+	}
+	return state.notify
+}
+
+// apply sets the pending configuration, if any. Must be called before starting the metric collection.
+func (state *reloadState) apply(source *SampleSource) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if state.pending == nil {
+		return
+	}
+	source.ExcludeMetrics = state.pending.ExcludeMetrics
+	source.IncludeMetrics = state.pending.IncludeMetrics
+	source.Tags = state.pending.Tags
+	state.pending = nil
+	// The notification is obsolete, since the configuration is applied now
+	select {
+	case <-state.channel():
+	default:
+	}
+}
+
+func (source *SampleSource) watchReload(wg *sync.WaitGroup, stopper golib.StopChan) {
+	state := &source.reload
+	state.lock.Lock()
+	notify := state.channel()
+	state.lock.Unlock()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-notify:
+			log.Warnf("The configuration of %v has changed! Restarting metric collection.", source)
+			stopper.Stop()
+		case <-stopper.WaitChan():
+		}
+	}()
+}
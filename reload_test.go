@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReloadTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestReload(t *testing.T) {
+	suite.Run(t, new(ReloadTestSuite))
+}
+
+func (suite *ReloadTestSuite) TestReconfigure() {
+	tag, err := ParseTagTemplate("env=staging")
+	suite.NoError(err)
+	source := &SampleSource{
+		ExcludeMetrics: []*regexp.Regexp{regexp.MustCompile("^disk")},
+	}
+	config := ReloadableConfig{
+		IncludeMetrics: []*regexp.Regexp{regexp.MustCompile("^cpu")},
+		Tags:           []*TagTemplate{tag},
+	}
+
+	// A running collection is stopped by the change
+	var wg sync.WaitGroup
+	stopper := golib.NewStopChan()
+	source.watchReload(&wg, stopper)
+	source.Reconfigure(config)
+	suite.False(stopper.WaitTimeout(time.Second))
+	wg.Wait()
+
+	// The configuration is applied with the next start, which must not be stopped by the obsolete notification
+	source.reload.apply(source)
+	suite.Nil(source.ExcludeMetrics)
+	suite.Equal(config.IncludeMetrics, source.IncludeMetrics)
+	suite.Equal(config.Tags, source.Tags)
+	stopper = golib.NewStopChan()
+	source.watchReload(&wg, stopper)
+	suite.True(stopper.WaitTimeout(50 * time.Millisecond))
+	stopper.Stop()
+	wg.Wait()
+
+	// Without a pending change, the configuration is kept
+	source.reload.apply(source)
+	suite.Equal(config.IncludeMetrics, source.IncludeMetrics)
+}
@@ -0,0 +1,187 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// Collector replays the samples of a previously recorded bitflow file (CSV or binary format). The samples are
+// replayed with their original timing, scaled by Speed (e.g. 2 for twice as fast). The metrics keep their recorded
+// names, so that downstream pipelines and alert rules can be tested against the recording without changes.
+// The values are emitted with the collect interval of the SampleSource, which should match the interval of the
+// recording. If the file contains multiple headers, the metric collection is restarted at every header change.
+// After the last sample, the replay starts over if Loop is set, otherwise the values of the last sample are kept.
+type Collector struct {
+	collector.AbstractCollector
+	File  string
+	Speed float64
+	Loop  bool
+
+	lock      sync.Mutex
+	headers   [][]string
+	samples   []recordedSample
+	start     time.Time
+	position  int
+	published int
+	finished  bool
+}
+
+type recordedSample struct {
+	time   time.Time
+	header int
+	values []bitflow.Value
+}
+
+func NewReplayCollector(file string, speed float64, loop bool) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("replay"),
+		File:              file,
+		Speed:             speed,
+		Loop:              loop,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	// The replay position is kept when the metric collection is restarted
+	if col.samples == nil {
+		if col.Speed <= 0 {
+			return nil, fmt.Errorf("Replay speed must be positive, but is %v", col.Speed)
+		}
+		headers, samples, err := readRecording(col.File)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read recording %v: %v", col.File, err)
+		}
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("Recording %v contains no samples", col.File)
+		}
+		log.Printf("Replaying %v samples from %v (%v)", len(samples), col.File,
+			samples[len(samples)-1].time.Sub(samples[0].time))
+		col.headers, col.samples = headers, samples
+		col.start = time.Now()
+		col.position = 0
+		col.finished = false
+	}
+	col.published = col.samples[col.position].header
+	return nil, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	col.advance(time.Now())
+	if col.samples[col.position].header != col.published {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+// advance moves the replay position to the last sample that is due at the given time
+func (col *Collector) advance(now time.Time) {
+	first, last := col.samples[0].time, col.samples[len(col.samples)-1].time
+	elapsed := time.Duration(float64(now.Sub(col.start)) * col.Speed)
+	if col.Loop && elapsed > last.Sub(first) {
+		log.Printf("Replay of %v finished, starting over", col.File)
+		col.start = now
+		col.position = 0
+		return
+	}
+	target := first.Add(elapsed)
+	for col.position+1 < len(col.samples) && !col.samples[col.position+1].time.After(target) {
+		col.position++
+	}
+	if col.position == len(col.samples)-1 && !col.finished {
+		log.Printf("Replay of %v finished, keeping the values of the last sample", col.File)
+		col.finished = true
+	}
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	fields := col.headers[col.published]
+	res := make(collector.MetricReaderMap, len(fields))
+	for i, field := range fields {
+		i := i
+		res[field] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			sample := col.samples[col.position]
+			if sample.header != col.published {
+				// The header has changed, the metric collection will be restarted
+				return 0
+			}
+			return sample.values[i]
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	fields := col.headers[col.published]
+	res := make(collector.MetricMetadataMap, len(fields))
+	for _, field := range fields {
+		res[field] = collector.GaugeMetric(collector.UnitNone, "Metric replayed from "+col.File)
+	}
+	return res
+}
+
+// readRecording reads all headers and samples from a bitflow file in CSV or binary format
+func readRecording(path string) ([][]string, []recordedSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	start, err := reader.Peek(len(bitflow.DefaultCsvTimeColumn))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to detect the format: %v", err)
+	}
+	unmarshaller, err := bitflow.DetectFormatFrom(string(start))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var headers [][]string
+	var samples []recordedSample
+	var header *bitflow.UnmarshalledHeader
+	for {
+		newHeader, data, err := unmarshaller.Read(reader, header)
+		if newHeader != nil {
+			header = newHeader
+			headers = append(headers, header.Fields)
+		} else if data != nil {
+			sample, parseErr := unmarshaller.ParseSample(header, len(header.Fields), data)
+			if parseErr != nil {
+				return nil, nil, fmt.Errorf("Sample %v: %v", len(samples)+1, parseErr)
+			}
+			samples = append(samples, recordedSample{
+				time:   sample.Time,
+				header: len(headers) - 1,
+				values: sample.Values,
+			})
+		}
+		if err == io.EOF {
+			return headers, samples, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+}
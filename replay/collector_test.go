@@ -0,0 +1,91 @@
+package replay
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+const testRecording = `time,tags,cpu,mem
+2020-01-01 10:00:00,host=a,1,10
+2020-01-01 10:00:01,host=a,2,20
+2020-01-01 10:00:02,host=a,3,30
+time,tags,cpu
+2020-01-01 10:00:04,host=a,5
+`
+
+type ReplayTestSuite struct {
+	golib.AbstractTestSuite
+	file string
+}
+
+func TestReplay(t *testing.T) {
+	suite.Run(t, new(ReplayTestSuite))
+}
+
+func (suite *ReplayTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "replay")
+	suite.NoError(err)
+	suite.file = filepath.Join(dir, "recording.csv")
+	suite.NoError(ioutil.WriteFile(suite.file, []byte(testRecording), 0644))
+}
+
+func (suite *ReplayTestSuite) TearDownTest() {
+	_ = os.RemoveAll(filepath.Dir(suite.file))
+}
+
+func (suite *ReplayTestSuite) TestReadRecording() {
+	headers, samples, err := readRecording(suite.file)
+	suite.NoError(err)
+	suite.Equal([][]string{{"cpu", "mem"}, {"cpu"}}, headers)
+	suite.Len(samples, 4)
+	suite.Equal([]bitflow.Value{2, 20}, samples[1].values)
+	suite.Equal(0, samples[2].header)
+	suite.Equal(1, samples[3].header)
+	suite.Equal(4*time.Second, samples[3].time.Sub(samples[0].time))
+}
+
+func (suite *ReplayTestSuite) TestReplay() {
+	ctx := context.Background()
+	col := NewReplayCollector(suite.file, 2, false)
+	_, err := col.Init(ctx)
+	suite.NoError(err)
+	metrics := col.Metrics()
+	suite.Len(metrics, 2)
+	suite.Equal(bitflow.Value(10), metrics["mem"]())
+
+	// With speed 2, the third sample is due after one second
+	col.advance(col.start.Add(time.Second))
+	suite.Equal(bitflow.Value(30), metrics["mem"]())
+	suite.NoError(col.Update(ctx))
+
+	// The last sample has a different header
+	col.start = time.Now().Add(-2 * time.Second)
+	suite.Equal(collector.MetricsChanged, col.Update(ctx))
+	_, err = col.Init(ctx)
+	suite.NoError(err)
+	metrics = col.Metrics()
+	suite.Len(metrics, 1)
+	suite.Equal(bitflow.Value(5), metrics["cpu"]())
+	suite.True(col.finished)
+}
+
+func (suite *ReplayTestSuite) TestLoop() {
+	col := NewReplayCollector(suite.file, 1, true)
+	_, err := col.Init(context.Background())
+	suite.NoError(err)
+	col.advance(col.start.Add(2 * time.Second))
+	suite.Equal(2, col.position)
+	now := col.start.Add(5 * time.Second)
+	col.advance(now)
+	suite.Equal(0, col.position)
+	suite.Equal(now, col.start)
+}
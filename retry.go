@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// retrySchedule implements the exponential backoff of retries, see SampleSource.FailedCollectorMaxCheckInterval.
+type retrySchedule struct {
+	next     time.Time
+	interval time.Duration
+}
+
+// backoff schedules the next retry and returns the time until then.
+func (r *retrySchedule) backoff(now time.Time, interval time.Duration, maxInterval time.Duration) time.Duration {
+	if maxInterval <= interval {
+		// No backoff, retry in every check interval
+		r.interval = interval
+	} else if r.interval <= 0 {
+		r.interval = interval
+	} else if r.interval *= 2; r.interval > maxInterval {
+		r.interval = maxInterval
+	}
+	r.next = now.Add(r.interval)
+	return r.interval
+}
+
+// retryState stores the retry schedules of failed collectors by their name, so that the backoff is not reset
+// when the metric collection is restarted and the collector graph is rebuilt.
+type retryState struct {
+	lock       sync.Mutex
+	collectors map[string]*retrySchedule
+
+	// Used when all collectors have failed and no collector graph could be created
+	allFailed retrySchedule
+}
+
+// isDue returns true if the failed collector with the given name should be retried now.
+func (r *retryState) isDue(name string, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	schedule, ok := r.collectors[name]
+	return !ok || !now.Before(schedule.next)
+}
+
+func (r *retryState) backoff(name string, now time.Time, interval time.Duration, maxInterval time.Duration) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.collectors == nil {
+		r.collectors = make(map[string]*retrySchedule)
+	}
+	schedule, ok := r.collectors[name]
+	if !ok {
+		schedule = new(retrySchedule)
+		r.collectors[name] = schedule
+	}
+	return schedule.backoff(now, interval, maxInterval)
+}
+
+// reset forgets the schedules of all collectors that are not failed anymore in the given graph.
+func (r *retryState) reset(graph *collectorGraph) {
+	failed := make(map[string]bool, len(graph.failed))
+	for node := range graph.failed {
+		failed[node.String()] = true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for name := range r.collectors {
+		if !failed[name] {
+			delete(r.collectors, name)
+		}
+	}
+	r.allFailed = retrySchedule{}
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+type routeEvent int
+
+const (
+	routeAdded routeEvent = iota
+	routeChanged
+	routeDeleted
+)
+
+// Collector reports the rates of added, changed and deleted IPv4 and IPv6 routes, received as rtnetlink
+// notifications, and the number of routes in the routing tables. The routes of the local table (local and broadcast
+// addresses of the host) and cached routes are not counted. Metrics are named routes/{ipv4,ipv6}/{added,changed,deleted},
+// routes/overflows for overflows of the receive buffer (after which events were lost), and routes/{ipv4,ipv6} for the
+// number of routes. The routes are counted by the child collector routes/tables, which dumps all routing tables and
+// should therefore be updated less frequently on routers with large tables.
+type Collector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	socket    *routeSocket
+	ipv4      familyCounters
+	ipv6      familyCounters
+	overflows counter
+}
+
+type familyCounters struct {
+	added   counter
+	changed counter
+	deleted counter
+}
+
+type counter struct {
+	// Accessed atomically. Must be the first field to guarantee 64-bit alignment on 32-bit platforms.
+	value uint64
+	ring  *collector.ValueRing
+}
+
+func (c *counter) increment() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *counter) update() {
+	c.ring.Add(collector.StoredValue(atomic.LoadUint64(&c.value)))
+}
+
+func NewRoutesCollector(factory *collector.ValueRingFactory) *Collector {
+	newFamily := func() familyCounters {
+		return familyCounters{
+			added:   counter{ring: factory.NewValueRing()},
+			changed: counter{ring: factory.NewValueRing()},
+			deleted: counter{ring: factory.NewValueRing()},
+		}
+	}
+	return &Collector{
+		AbstractCollector: collector.RootCollector("routes"),
+		factory:           factory,
+		ipv4:              newFamily(),
+		ipv6:              newFamily(),
+		overflows:         counter{ring: factory.NewValueRing()},
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	if col.socket == nil {
+		// The socket stays open when the collectors are restarted, to avoid missing events
+		socket, err := openRouteSocket()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to subscribe to route notifications: %v", err)
+		}
+		col.socket = socket
+		go col.socket.receive(col.handleEvent, col.overflows.increment)
+	}
+	return []collector.Collector{
+		&tablesCollector{AbstractCollector: col.Child("tables")},
+	}, nil
+}
+
+func (col *Collector) handleEvent(ipv6 bool, event routeEvent) {
+	counters := &col.ipv4
+	if ipv6 {
+		counters = &col.ipv6
+	}
+	switch event {
+	case routeAdded:
+		counters.added.increment()
+	case routeChanged:
+		counters.changed.increment()
+	case routeDeleted:
+		counters.deleted.increment()
+	}
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	for _, counters := range []*familyCounters{&col.ipv4, &col.ipv6} {
+		counters.added.update()
+		counters.changed.update()
+		counters.deleted.update()
+	}
+	col.overflows.update()
+	return nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	res := collector.MetricReaderMap{
+		"routes/overflows": col.overflows.ring.GetDiff,
+	}
+	for family, counters := range map[string]*familyCounters{"ipv4": &col.ipv4, "ipv6": &col.ipv6} {
+		res["routes/"+family+"/added"] = counters.added.ring.GetDiff
+		res["routes/"+family+"/changed"] = counters.changed.ring.GetDiff
+		res["routes/"+family+"/deleted"] = counters.deleted.ring.GetDiff
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	res := collector.MetricMetadataMap{
+		"routes/overflows": collector.DerivedMetric(collector.UnitPerSecond, "Overflows of the receive buffer for route notifications, after which events were lost"),
+	}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		name := "IPv" + family[3:]
+		res["routes/"+family+"/added"] = collector.DerivedMetric(collector.UnitPerSecond, "Added "+name+" routes")
+		res["routes/"+family+"/changed"] = collector.DerivedMetric(collector.UnitPerSecond, "Replaced "+name+" routes")
+		res["routes/"+family+"/deleted"] = collector.DerivedMetric(collector.UnitPerSecond, "Deleted "+name+" routes")
+	}
+	return res
+}
+
+// tablesCollector counts the routes in all routing tables
+type tablesCollector struct {
+	collector.AbstractCollector
+
+	lock sync.Mutex
+	ipv4 uint64
+	ipv6 uint64
+}
+
+func (col *tablesCollector) Update(ctx context.Context) error {
+	ipv4, ipv6, err := countRoutes()
+	if err != nil {
+		return err
+	}
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	col.ipv4, col.ipv6 = ipv4, ipv6
+	return nil
+}
+
+func (col *tablesCollector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		"routes/ipv4": func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.ipv4)
+		},
+		"routes/ipv6": func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.ipv6)
+		},
+	}
+}
+
+func (col *tablesCollector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"routes/ipv4": collector.GaugeMetric(collector.UnitCount, "IPv4 routes in all routing tables"),
+		"routes/ipv6": collector.GaugeMetric(collector.UnitCount, "IPv6 routes in all routing tables"),
+	}
+}
@@ -0,0 +1,194 @@
+package routes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RTMGRP_IPV4_ROUTE and RTMGRP_IPV6_ROUTE from linux/rtnetlink.h
+	rtmGroupIpv4Route = 0x40
+	rtmGroupIpv6Route = 0x400
+
+	// Requested size of the receive buffer, limited by net.core.rmem_max
+	receiveBufferSize = 4 * 1024 * 1024
+)
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+type routeSocket struct {
+	fd  int
+	buf []byte
+}
+
+func openRouteSocket() (*routeSocket, error) {
+	fd, err := openNetlinkSocket(rtmGroupIpv4Route | rtmGroupIpv6Route)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, receiveBufferSize); err != nil {
+		log.Warnln("Failed to increase the receive buffer for route notifications:", err)
+	}
+	return &routeSocket{
+		fd:  fd,
+		buf: make([]byte, os.Getpagesize()*4),
+	}, nil
+}
+
+func openNetlinkSocket(groups uint32) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return 0, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		_ = syscall.Close(fd)
+		return 0, err
+	}
+	return fd, nil
+}
+
+// receive reads route notifications from the socket and passes them to the given handler, until the socket fails
+func (s *routeSocket) receive(handler func(ipv6 bool, event routeEvent), overflow func()) {
+	for {
+		n, _, err := syscall.Recvfrom(s.fd, s.buf, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			if err == syscall.ENOBUFS {
+				overflow()
+				continue
+			}
+			log.Errorln("Stopped receiving route notifications:", err)
+			return
+		}
+		messages, err := syscall.ParseNetlinkMessage(s.buf[:n])
+		if err != nil {
+			log.Warnln("Failed to parse route notification:", err)
+			continue
+		}
+		for _, msg := range messages {
+			if ipv6, event, ok := parseRouteEvent(msg); ok {
+				handler(ipv6, event)
+			}
+		}
+	}
+}
+
+// countRoutes dumps the routes of all routing tables and counts them without storing the entire dump,
+// which can be large on routers with full BGP tables
+func countRoutes() (ipv4 uint64, ipv6 uint64, err error) {
+	fd, err := openNetlinkSocket(0)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = syscall.Close(fd)
+	}()
+
+	// struct nlmsghdr followed by an empty struct rtmsg, which selects all address families
+	req := make([]byte, syscall.NLMSG_HDRLEN+syscall.SizeofRtMsg)
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], syscall.RTM_GETROUTE)
+	nativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	nativeEndian.PutUint32(req[8:12], 1)
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return 0, 0, err
+	}
+	buf := make([]byte, os.Getpagesize()*8)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return 0, 0, err
+		}
+		messages, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, msg := range messages {
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return ipv4, ipv6, nil
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(msg.Data[0:4])); errno != 0 {
+						return 0, 0, syscall.Errno(-errno)
+					}
+				}
+				return 0, 0, fmt.Errorf("Empty netlink response")
+			case syscall.RTM_NEWROUTE:
+				if family, ok := countedRoute(msg.Data); ok {
+					if family == syscall.AF_INET6 {
+						ipv6++
+					} else {
+						ipv4++
+					}
+				}
+			}
+		}
+	}
+}
+
+func parseRouteEvent(msg syscall.NetlinkMessage) (ipv6 bool, event routeEvent, ok bool) {
+	switch msg.Header.Type {
+	case syscall.RTM_NEWROUTE:
+		event = routeAdded
+		if msg.Header.Flags&syscall.NLM_F_REPLACE != 0 {
+			event = routeChanged
+		}
+	case syscall.RTM_DELROUTE:
+		event = routeDeleted
+	default:
+		return
+	}
+	family, ok := countedRoute(msg.Data)
+	return family == syscall.AF_INET6, event, ok
+}
+
+// countedRoute parses the struct rtmsg at the beginning of a route message and returns its address family.
+// Only the IPv4 and IPv6 routes outside of the local table that are not cached are counted.
+func countedRoute(data []byte) (family uint8, ok bool) {
+	if len(data) < syscall.SizeofRtMsg {
+		return 0, false
+	}
+	family, table := data[0], uint32(data[4])
+	flags := nativeEndian.Uint32(data[8:12])
+	if family != syscall.AF_INET && family != syscall.AF_INET6 || flags&syscall.RTM_F_CLONED != 0 {
+		return 0, false
+	}
+	// Tables with IDs above 255 are only contained in the RTA_TABLE attribute
+	attrs := data[syscall.SizeofRtMsg:]
+	for len(attrs) >= syscall.SizeofRtAttr {
+		length := int(nativeEndian.Uint16(attrs[0:2]))
+		if length < syscall.SizeofRtAttr || length > len(attrs) {
+			break
+		}
+		if nativeEndian.Uint16(attrs[2:4]) == syscall.RTA_TABLE && length >= syscall.SizeofRtAttr+4 {
+			table = nativeEndian.Uint32(attrs[syscall.SizeofRtAttr:])
+			break
+		}
+		aligned := (length + syscall.RTA_ALIGNTO - 1) & ^(syscall.RTA_ALIGNTO - 1)
+		if aligned >= len(attrs) {
+			break
+		}
+		attrs = attrs[aligned:]
+	}
+	return family, table != syscall.RT_TABLE_LOCAL
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type RoutesTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestRoutes(t *testing.T) {
+	suite.Run(t, new(RoutesTestSuite))
+}
+
+func rtmsg(family uint8, table uint8, flags uint32, tableAttr uint32) []byte {
+	data := make([]byte, syscall.SizeofRtMsg)
+	data[0] = family
+	data[4] = table
+	nativeEndian.PutUint32(data[8:12], flags)
+	// An RTA_DST attribute with an IPv4 address, optionally followed by RTA_TABLE
+	attr := make([]byte, syscall.SizeofRtAttr+4)
+	nativeEndian.PutUint16(attr[0:2], uint16(len(attr)))
+	nativeEndian.PutUint16(attr[2:4], syscall.RTA_DST)
+	data = append(data, attr...)
+	if tableAttr != 0 {
+		attr = make([]byte, syscall.SizeofRtAttr+4)
+		nativeEndian.PutUint16(attr[0:2], uint16(len(attr)))
+		nativeEndian.PutUint16(attr[2:4], syscall.RTA_TABLE)
+		nativeEndian.PutUint32(attr[syscall.SizeofRtAttr:], tableAttr)
+		data = append(data, attr...)
+	}
+	return data
+}
+
+func (suite *RoutesTestSuite) TestCountedRoute() {
+	family, ok := countedRoute(rtmsg(syscall.AF_INET, syscall.RT_TABLE_MAIN, 0, syscall.RT_TABLE_MAIN))
+	suite.True(ok)
+	suite.Equal(uint8(syscall.AF_INET), family)
+	family, ok = countedRoute(rtmsg(syscall.AF_INET6, syscall.RT_TABLE_COMPAT, 0, 1000))
+	suite.True(ok)
+	suite.Equal(uint8(syscall.AF_INET6), family)
+
+	_, ok = countedRoute(rtmsg(syscall.AF_INET, syscall.RT_TABLE_LOCAL, 0, syscall.RT_TABLE_LOCAL))
+	suite.False(ok)
+	_, ok = countedRoute(rtmsg(syscall.AF_INET, syscall.RT_TABLE_LOCAL, 0, 0))
+	suite.False(ok)
+	_, ok = countedRoute(rtmsg(syscall.AF_INET6, syscall.RT_TABLE_MAIN, syscall.RTM_F_CLONED, 0))
+	suite.False(ok)
+	_, ok = countedRoute(rtmsg(syscall.AF_BRIDGE, syscall.RT_TABLE_MAIN, 0, 0))
+	suite.False(ok)
+	_, ok = countedRoute([]byte{syscall.AF_INET})
+	suite.False(ok)
+}
+
+func (suite *RoutesTestSuite) TestParseRouteEvent() {
+	msg := func(msgType uint16, flags uint16, family uint8) syscall.NetlinkMessage {
+		return syscall.NetlinkMessage{
+			Header: syscall.NlMsghdr{Type: msgType, Flags: flags},
+			Data:   rtmsg(family, syscall.RT_TABLE_MAIN, 0, 0),
+		}
+	}
+	ipv6, event, ok := parseRouteEvent(msg(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE, syscall.AF_INET))
+	suite.True(ok)
+	suite.False(ipv6)
+	suite.Equal(routeAdded, event)
+	ipv6, event, ok = parseRouteEvent(msg(syscall.RTM_NEWROUTE, syscall.NLM_F_REPLACE, syscall.AF_INET6))
+	suite.True(ok)
+	suite.True(ipv6)
+	suite.Equal(routeChanged, event)
+	_, event, ok = parseRouteEvent(msg(syscall.RTM_DELROUTE, 0, syscall.AF_INET))
+	suite.True(ok)
+	suite.Equal(routeDeleted, event)
+	_, _, ok = parseRouteEvent(msg(syscall.RTM_NEWLINK, 0, syscall.AF_INET))
+	suite.False(ok)
+
+	col := NewRoutesCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.handleEvent(false, routeAdded)
+	col.handleEvent(false, routeAdded)
+	col.handleEvent(true, routeDeleted)
+	suite.Equal(uint64(2), col.ipv4.added.value)
+	suite.Equal(uint64(0), col.ipv4.deleted.value)
+	suite.Equal(uint64(1), col.ipv6.deleted.value)
+}
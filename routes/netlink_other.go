@@ -0,0 +1,19 @@
+// +build !linux
+
+package routes
+
+import "errors"
+
+type routeSocket struct {
+}
+
+func openRouteSocket() (*routeSocket, error) {
+	return nil, errors.New("Route notifications are only available on Linux")
+}
+
+func (s *routeSocket) receive(handler func(ipv6 bool, event routeEvent), overflow func()) {
+}
+
+func countRoutes() (uint64, uint64, error) {
+	return 0, 0, errors.New("Counting routes is only available on Linux")
+}
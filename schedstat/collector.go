@@ -0,0 +1,138 @@
+package schedstat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Collector reports the run queue statistics of the scheduler from /proc/schedstat: the time tasks spent running
+// on every CPU, the time runnable tasks spent waiting in the run queue of the CPU before they were running, and
+// the average wait time per timeslice, which is the scheduling latency. Metrics are named schedstat/{run,wait,
+// timeslices,latency} for the sum of all CPUs and schedstat/cpu/<N>/... for the individual CPUs. Requires a kernel
+// with CONFIG_SCHED_INFO, which is enabled together with CONFIG_SCHEDSTATS or task delay accounting.
+type Collector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+
+	total cpuRings
+	cpus  map[int]cpuRings
+}
+
+type cpuRings struct {
+	run        *collector.ValueRing
+	wait       *collector.ValueRing
+	timeslices *collector.ValueRing
+}
+
+func NewSchedstatCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("schedstat"),
+		factory:           factory,
+	}
+}
+
+func (col *Collector) newRings() cpuRings {
+	return cpuRings{
+		run:        col.factory.NewValueRing(),
+		wait:       col.factory.NewValueRing(),
+		timeslices: col.factory.NewValueRing(),
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	stats, err := readSchedstat(hostfs.Current().ProcPath("schedstat"))
+	if err != nil {
+		return nil, err
+	}
+	col.total = col.newRings()
+	col.cpus = make(map[int]cpuRings, len(stats))
+	for _, cpu := range stats {
+		col.cpus[cpu.cpu] = col.newRings()
+	}
+	return nil, nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	res := col.total.metrics("schedstat")
+	for cpu, rings := range col.cpus {
+		for name, reader := range rings.metrics(fmt.Sprintf("schedstat/cpu/%v", cpu)) {
+			res[name] = reader
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	res := schedstatMetadata("schedstat", "all CPUs")
+	for cpu := range col.cpus {
+		for name, metadata := range schedstatMetadata(fmt.Sprintf("schedstat/cpu/%v", cpu), fmt.Sprintf("CPU %v", cpu)) {
+			res[name] = metadata
+		}
+	}
+	return res
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	stats, err := readSchedstat(hostfs.Current().ProcPath("schedstat"))
+	if err != nil {
+		return err
+	}
+	if len(stats) != len(col.cpus) {
+		return collector.MetricsChanged
+	}
+	var total cpuStats
+	for _, cpu := range stats {
+		rings, ok := col.cpus[cpu.cpu]
+		if !ok {
+			return collector.MetricsChanged
+		}
+		rings.add(cpu)
+		total.runTime += cpu.runTime
+		total.waitTime += cpu.waitTime
+		total.timeslices += cpu.timeslices
+	}
+	col.total.add(total)
+	return nil
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (rings cpuRings) add(stats cpuStats) {
+	// The times are stored in milliseconds, so that their rates are in ms/sec
+	rings.run.Add(collector.StoredValue(float64(stats.runTime) / 1e6))
+	rings.wait.Add(collector.StoredValue(float64(stats.waitTime) / 1e6))
+	rings.timeslices.Add(collector.StoredValue(stats.timeslices))
+}
+
+func (rings cpuRings) metrics(prefix string) collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		prefix + "/run":        rings.run.GetDiff,
+		prefix + "/wait":       rings.wait.GetDiff,
+		prefix + "/timeslices": rings.timeslices.GetDiff,
+		prefix + "/latency":    rings.readLatency,
+	}
+}
+
+// readLatency returns the average wait time per timeslice in milliseconds within the time window of the value rings
+func (rings cpuRings) readLatency() bitflow.Value {
+	timeslices := rings.timeslices.GetDiff()
+	if timeslices <= 0 {
+		return 0
+	}
+	return rings.wait.GetDiff() / timeslices
+}
+
+func schedstatMetadata(prefix string, cpus string) collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		prefix + "/run":        collector.DerivedMetric(collector.UnitMillisPerSec, "Time spent running tasks on "+cpus),
+		prefix + "/wait":       collector.DerivedMetric(collector.UnitMillisPerSec, "Time runnable tasks spent waiting in the run queue of "+cpus),
+		prefix + "/timeslices": collector.DerivedMetric(collector.UnitPerSecond, "Timeslices run on "+cpus),
+		prefix + "/latency":    collector.DerivedMetric(collector.UnitMillis, "Average time runnable tasks waited in the run queue per timeslice on "+cpus),
+	}
+}
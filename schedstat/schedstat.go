@@ -0,0 +1,61 @@
+package schedstat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Columns of the cpu<N> lines in /proc/schedstat after the CPU name, see Documentation/scheduler/sched-stats.rst
+const (
+	schedstatRunTime    = 6
+	schedstatWaitTime   = 7
+	schedstatTimeslices = 8
+)
+
+// cpuStats are the run queue statistics of one CPU, with times in nanoseconds
+type cpuStats struct {
+	cpu        int
+	runTime    uint64
+	waitTime   uint64
+	timeslices uint64
+}
+
+// readSchedstat parses the cpu<N> lines of the given schedstat file. The version, timestamp and scheduling domain
+// lines are ignored, since the format of the domain lines changes between versions of the file.
+func readSchedstat(filename string) ([]cpuStats, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var res []cpuStats
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		cpu, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil || len(fields) <= schedstatTimeslices+1 {
+			return nil, fmt.Errorf("%v is not formatted correctly in line %v", filename, i+1)
+		}
+		stats := cpuStats{cpu: cpu}
+		for _, field := range []struct {
+			column int
+			value  *uint64
+		}{
+			{schedstatRunTime, &stats.runTime},
+			{schedstatWaitTime, &stats.waitTime},
+			{schedstatTimeslices, &stats.timeslices},
+		} {
+			if *field.value, err = strconv.ParseUint(fields[field.column+1], 10, 64); err != nil {
+				return nil, fmt.Errorf("%v is not formatted correctly in line %v: %v", filename, i+1, err)
+			}
+		}
+		res = append(res, stats)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%v does not contain any CPU statistics", filename)
+	}
+	return res, nil
+}
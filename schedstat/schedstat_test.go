@@ -0,0 +1,51 @@
+package schedstat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchedstatTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSchedstat(t *testing.T) {
+	suite.Run(t, new(SchedstatTestSuite))
+}
+
+func (suite *SchedstatTestSuite) TestReadSchedstat() {
+	dir, err := ioutil.TempDir("", "schedstat")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "schedstat")
+	suite.NoError(ioutil.WriteFile(file, []byte(
+		"version 15\n"+
+			"timestamp 4295123456\n"+
+			"cpu0 0 0 1500 600 700 300 2500000000 40000000 900\n"+
+			"domain0 00000003 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28 29 30 31 32 33 34 35 36\n"+
+			"cpu2 0 0 10 5 6 2 1000 2000 30\n"), 0644))
+	stats, err := readSchedstat(file)
+	suite.NoError(err)
+	suite.Equal([]cpuStats{
+		{cpu: 0, runTime: 2500000000, waitTime: 40000000, timeslices: 900},
+		{cpu: 2, runTime: 1000, waitTime: 2000, timeslices: 30},
+	}, stats)
+
+	for _, invalid := range []string{
+		"version 15\n",
+		"cpu0 0 0 1500 600 700 300 2500000000 40000000\n",
+		"cpu0 0 0 1500 600 700 300 2500000000 x 900\n",
+		"cpux 0 0 1500 600 700 300 2500000000 40000000 900\n",
+	} {
+		suite.NoError(ioutil.WriteFile(file, []byte(invalid), 0644))
+		_, err := readSchedstat(file)
+		suite.Error(err, invalid)
+	}
+}
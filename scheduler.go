@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/antongulenko/golib"
+	log "github.com/sirupsen/logrus"
+)
+
+// updateScheduler performs update rounds on all nodes of a collectorGraph. The dependencies declared through
+// Collector.Depends() are updated before the depending collectors, while independent branches of the graph are
+// updated in parallel. The number of parallel updates can be limited by a positive parallelism value.
+type updateScheduler struct {
+	graph *collectorGraph
+
+	// For every node, the nodes that depend on it and the number of its own dependencies
+	dependents   map[*collectorNode][]*collectorNode
+	dependencies map[*collectorNode]int
+
+	// Reused in every round to avoid allocations
+	pending map[*collectorNode]int
+	done    chan *collectorNode
+	limit   chan struct{}
+}
+
+func newUpdateScheduler(graph *collectorGraph, parallelism int) *updateScheduler {
+	s := &updateScheduler{
+		graph:        graph,
+		dependents:   make(map[*collectorNode][]*collectorNode),
+		dependencies: make(map[*collectorNode]int),
+		pending:      make(map[*collectorNode]int),
+	}
+	if parallelism > 0 {
+		s.limit = make(chan struct{}, parallelism)
+	}
+	for node := range graph.nodes {
+		s.dependencies[node] = 0
+	}
+	for node := range graph.nodes {
+		for _, dependsCol := range node.collector.Depends() {
+			depends := graph.resolve(dependsCol)
+			if _, ok := s.dependencies[depends]; !ok {
+				// Should not happen after pruneAndRepair()
+				continue
+			}
+			s.dependents[depends] = append(s.dependents[depends], node)
+			s.dependencies[node]++
+		}
+	}
+	s.done = make(chan *collectorNode, len(s.dependencies))
+	return s
+}
+
+// runRound updates every node of the graph once and returns after all updates are finished.
+// Nodes that are removed from the graph while the round is running are skipped.
+// Rounds must not be executed concurrently.
+func (s *updateScheduler) runRound(ctx context.Context, stopper golib.StopChan) {
+	pending, limit, done := s.pending, s.limit, s.done
+	for node, num := range s.dependencies {
+		pending[node] = num
+		node.setRoundPending(true)
+	}
+
+	running := 0
+	start := func(node *collectorNode) {
+		running++
+		go func() {
+			if limit != nil {
+				limit <- struct{}{}
+				defer func() {
+					<-limit
+				}()
+			}
+			if !stopper.Stopped() && s.graph.containsNode(node) {
+				node.scheduledUpdate(ctx, stopper)
+			}
+			node.setRoundPending(false)
+			done <- node
+		}()
+	}
+
+	for node, num := range pending {
+		if num == 0 {
+			start(node)
+		}
+	}
+	for running > 0 {
+		node := <-done
+		running--
+		for _, dependent := range s.dependents[node] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				start(dependent)
+			}
+		}
+	}
+	for node, num := range pending {
+		if num > 0 {
+			// Should not happen, since the graph is acyclic
+			log.Errorln("Collector", node, "was not updated, still waiting for", num, "dependencies")
+		}
+	}
+}
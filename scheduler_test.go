@@ -0,0 +1,244 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchedulerTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestScheduler(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
+
+// mockCollector records its updates in the shared updateLog. The children are returned from Init().
+type mockCollector struct {
+	AbstractCollector
+	log      *updateLog
+	children []Collector
+	depends  []Collector
+	onUpdate func()
+}
+
+func (col *mockCollector) Init(_ context.Context) ([]Collector, error) {
+	return col.children, nil
+}
+
+// Metrics returns one metric, so that pruneAndRepair() does not filter the collector
+func (col *mockCollector) Metrics() MetricReaderMap {
+	return MetricReaderMap{
+		col.String(): func() bitflow.Value {
+			return 0
+		},
+	}
+}
+
+func (col *mockCollector) Depends() []Collector {
+	return col.depends
+}
+
+func (col *mockCollector) Update(_ context.Context) error {
+	col.log.start(col.String())
+	if col.onUpdate != nil {
+		col.onUpdate()
+	}
+	col.log.finish(col.String())
+	return nil
+}
+
+type updateLog struct {
+	lock     sync.Mutex
+	started  map[string]time.Time
+	finished map[string]time.Time
+
+	running    int32
+	maxRunning int32
+}
+
+func newUpdateLog() *updateLog {
+	return &updateLog{
+		started:  make(map[string]time.Time),
+		finished: make(map[string]time.Time),
+	}
+}
+
+func (l *updateLog) start(name string) {
+	running := atomic.AddInt32(&l.running, 1)
+	for {
+		max := atomic.LoadInt32(&l.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(&l.maxRunning, max, running) {
+			break
+		}
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.started[name] = time.Now()
+}
+
+func (l *updateLog) finish(name string) {
+	atomic.AddInt32(&l.running, -1)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.finished[name] = time.Now()
+}
+
+func (suite *SchedulerTestSuite) newCollector(l *updateLog, name string, parent *mockCollector) *mockCollector {
+	col := &mockCollector{log: l}
+	if parent == nil {
+		col.AbstractCollector = RootCollector(name)
+	} else {
+		col.AbstractCollector = parent.Child(name)
+		col.depends = []Collector{parent}
+		parent.children = append(parent.children, col)
+	}
+	return col
+}
+
+func (suite *SchedulerTestSuite) runRound(roots []Collector, parallelism int) *collectorGraph {
+	graph, err := initCollectorGraph(context.Background(), roots, nil)
+	suite.NoError(err)
+	newUpdateScheduler(graph, parallelism).runRound(context.Background(), golib.NewStopChan())
+	return graph
+}
+
+func (suite *SchedulerTestSuite) TestDependenciesBeforeDependents() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	a := suite.newCollector(l, "a", root)
+	b := suite.newCollector(l, "b", root)
+	a1 := suite.newCollector(l, "a1", a)
+	a2 := suite.newCollector(l, "a2", a)
+	// Depends on two branches of the tree
+	joined := suite.newCollector(l, "joined", a1)
+	joined.depends = append(joined.depends, b)
+	for _, col := range []*mockCollector{root, a, b, a1, a2, joined} {
+		col.onUpdate = func() {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	suite.runRound([]Collector{root}, 0)
+
+	suite.Len(l.finished, 6)
+	for _, col := range []*mockCollector{a, b, a1, a2, joined} {
+		for _, dependency := range col.depends {
+			suite.False(l.started[col.String()].Before(l.finished[dependency.String()]),
+				"%v was started before its dependency %v finished", col, dependency)
+		}
+	}
+}
+
+func (suite *SchedulerTestSuite) TestParallelism() {
+	for _, test := range []struct {
+		parallelism int
+		expected    int32
+	}{
+		{0, 6},
+		{1, 1},
+		{2, 2},
+		{4, 4},
+	} {
+		l := newUpdateLog()
+		root := suite.newCollector(l, "root", nil)
+		for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+			suite.newCollector(l, name, root).onUpdate = func() {
+				time.Sleep(30 * time.Millisecond)
+			}
+		}
+		start := time.Now()
+		suite.runRound([]Collector{root}, test.parallelism)
+
+		suite.Len(l.finished, 7)
+		suite.Equal(test.expected, l.maxRunning, "Unexpected number of parallel updates with parallelism %v", test.parallelism)
+		if test.parallelism == 0 {
+			// All independent branches run at the same time, so the round takes about as long as one update
+			suite.True(time.Since(start) < 6*30*time.Millisecond, "Independent collectors were not updated in parallel")
+		}
+	}
+}
+
+func (suite *SchedulerTestSuite) TestNodesRemovedDuringRound() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	a := suite.newCollector(l, "a", root)
+	removed := suite.newCollector(l, "removed", a)
+	removedChild := suite.newCollector(l, "child", removed)
+	sibling := suite.newCollector(l, "sibling", a)
+
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+	a.onUpdate = func() {
+		// Simulates a collector that exceeded the tolerated number of update failures in a concurrent round
+		graph.collectorUpdateFailed(graph.resolve(removed))
+	}
+	newUpdateScheduler(graph, 0).runRound(context.Background(), golib.NewStopChan())
+
+	suite.Contains(l.finished, root.String())
+	suite.Contains(l.finished, a.String())
+	suite.Contains(l.finished, sibling.String())
+	suite.NotContains(l.started, removed.String())
+	suite.NotContains(l.started, removedChild.String())
+}
+
+func (suite *SchedulerTestSuite) TestStoppedRound() {
+	l := newUpdateLog()
+	root := suite.newCollector(l, "root", nil)
+	a := suite.newCollector(l, "a", root)
+	suite.newCollector(l, "b", a)
+
+	stopper := golib.NewStopChan()
+	root.onUpdate = stopper.Stop
+	graph, err := initCollectorGraph(context.Background(), []Collector{root}, nil)
+	suite.NoError(err)
+	newUpdateScheduler(graph, 0).runRound(context.Background(), stopper)
+
+	// The round returns without updating the remaining collectors
+	suite.Len(l.started, 1)
+}
+
+func (suite *SchedulerTestSuite) TestSampleValuesReadAfterUpdate() {
+	col := suite.newCollector(newUpdateLog(), "col", nil)
+	var value bitflow.Value
+	col.onUpdate = func() {
+		value++
+	}
+	graph, err := initCollectorGraph(context.Background(), []Collector{col}, nil)
+	suite.NoError(err)
+	metrics := graph.getMetrics()
+	suite.Len(metrics, 1)
+	metrics[0].reader = func() bitflow.Value {
+		return value
+	}
+	_, getValues := metrics.ConstructSample(&SampleSource{}, 0)
+	suite.Equal([]bitflow.Value{0}, getValues())
+
+	// The values are read concurrently to the updates, but only change after an update is finished
+	scheduler := newUpdateScheduler(graph, 0)
+	finished := make(chan bool)
+	go func() {
+		for i := 0; i < 50; i++ {
+			scheduler.runRound(context.Background(), golib.NewStopChan())
+		}
+		close(finished)
+	}()
+	var last bitflow.Value
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		default:
+		}
+		current := getValues()[0]
+		suite.True(current >= last)
+		last = current
+	}
+	suite.Equal([]bitflow.Value{50}, getValues())
+}
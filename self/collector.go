@@ -0,0 +1,96 @@
+package self
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// NewSelfCollector returns a collector that reports the resource usage of the collector process itself,
+// including the allocation and garbage collection statistics of the Go runtime. The memory usage
+// of the value rings created by the given factory is included as well.
+func NewSelfCollector(factory *collector.ValueRingFactory) collector.Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("self"),
+		factory:           factory,
+	}
+}
+
+type Collector struct {
+	collector.AbstractCollector
+	factory *collector.ValueRingFactory
+	stats   runtime.MemStats
+
+	goroutines int
+	allocBytes *collector.ValueRing
+	mallocs    *collector.ValueRing
+	frees      *collector.ValueRing
+	gcRuns     *collector.ValueRing
+	gcPause    *collector.ValueRing
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.allocBytes = col.factory.NewValueRing()
+	col.mallocs = col.factory.NewValueRing()
+	col.frees = col.factory.NewValueRing()
+	col.gcRuns = col.factory.NewValueRing()
+	col.gcPause = col.factory.NewValueRing()
+	return nil, nil
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	runtime.ReadMemStats(&col.stats)
+	col.goroutines = runtime.NumGoroutine()
+	col.allocBytes.Add(collector.StoredValue(col.stats.TotalAlloc))
+	col.mallocs.Add(collector.StoredValue(col.stats.Mallocs))
+	col.frees.Add(collector.StoredValue(col.stats.Frees))
+	col.gcRuns.Add(collector.StoredValue(col.stats.NumGC))
+	col.gcPause.Add(collector.StoredValue(float64(col.stats.PauseTotalNs) / 1e6))
+	return nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		"self/goroutines":     col.readGoroutines,
+		"self/mem/heap":       col.readHeap,
+		"self/mem/sys":        col.readSys,
+		"self/mem/value-ring": col.readValueRingMemory,
+		"self/alloc/bytes":    col.allocBytes.GetDiff,
+		"self/alloc/mallocs":  col.mallocs.GetDiff,
+		"self/alloc/frees":    col.frees.GetDiff,
+		"self/gc/runs":        col.gcRuns.GetDiff,
+		"self/gc/pause":       col.gcPause.GetDiff,
+	}
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"self/goroutines":     collector.GaugeMetric(collector.UnitCount, "Number of goroutines in the collector process"),
+		"self/mem/heap":       collector.GaugeMetric(collector.UnitBytes, "Allocated heap memory of the collector process"),
+		"self/mem/sys":        collector.GaugeMetric(collector.UnitBytes, "Memory obtained from the OS by the collector process"),
+		"self/mem/value-ring": collector.GaugeMetric(collector.UnitBytes, "Memory used by value rings for computing rates"),
+		"self/alloc/bytes":    collector.DerivedMetric(collector.UnitBytesPerSecond, "Allocated heap memory"),
+		"self/alloc/mallocs":  collector.DerivedMetric(collector.UnitPerSecond, "Allocated heap objects"),
+		"self/alloc/frees":    collector.DerivedMetric(collector.UnitPerSecond, "Freed heap objects"),
+		"self/gc/runs":        collector.DerivedMetric(collector.UnitPerSecond, "Completed garbage collection cycles"),
+		"self/gc/pause":       collector.DerivedMetric(collector.UnitMillisPerSec, "Time spent in stop-the-world garbage collection pauses"),
+	}
+}
+
+func (col *Collector) readGoroutines() bitflow.Value {
+	return bitflow.Value(col.goroutines)
+}
+
+func (col *Collector) readHeap() bitflow.Value {
+	return bitflow.Value(col.stats.HeapAlloc)
+}
+
+func (col *Collector) readSys() bitflow.Value {
+	return bitflow.Value(col.stats.Sys)
+}
+
+func (col *Collector) readValueRingMemory() bitflow.Value {
+	return bitflow.Value(col.factory.MemoryUsage())
+}
@@ -0,0 +1,92 @@
+package sensors
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow-collector/hostfs"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// Collector reports the temperatures, fan speeds, voltages, power and currents of all hardware monitoring chips,
+// read from /sys/class/hwmon like lm-sensors. Metrics are named sensors/<chip>/<type>/<sensor>, where the type is
+// temp, fan, voltage, power or current, and the sensor is the label of the sensor (e.g. Core_0 for coretemp) or its
+// index, if the driver provides no label. Chips with the same name get their hwmon index appended, e.g. coretemp-1.
+// Sensors that cannot be read (e.g. disconnected fans on some boards) are omitted.
+type Collector struct {
+	collector.AbstractCollector
+
+	lock     sync.Mutex
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewSensorsCollector() *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("sensors"),
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.gauges = nil
+	return nil, col.update(false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.gauges))
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) update(checkChange bool) error {
+	readings, err := readSensors(hostfs.Current().SysPath("class", "hwmon"))
+	if err != nil {
+		return err
+	}
+	gauges := make(map[string]float64, len(readings))
+	metadata := make(collector.MetricMetadataMap, len(readings))
+	for _, reading := range readings {
+		name := "sensors/" + reading.chip + "/" + reading.sensorType.name + "/" + reading.sensor
+		gauges[name] = reading.value
+		metadata[name] = collector.GaugeMetric(reading.sensorType.unit, reading.sensorType.description+" of sensor "+reading.label+" of "+reading.chip)
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.gauges == nil || len(gauges) != len(col.gauges)
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package sensors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type sensorType struct {
+	prefix      string // Prefix of the files in the hwmon directory, e.g. temp for temp1_input
+	name        string
+	unit        string
+	description string
+	scale       float64 // Divisor converting the raw values into the unit
+}
+
+// See Documentation/hwmon/sysfs-interface.rst in the kernel sources
+var sensorTypes = []sensorType{
+	{prefix: "temp", name: "temp", unit: "celsius", description: "Temperature", scale: 1000},
+	{prefix: "fan", name: "fan", unit: "rpm", description: "Fan speed", scale: 1},
+	{prefix: "in", name: "voltage", unit: "volts", description: "Voltage", scale: 1000},
+	{prefix: "power", name: "power", unit: "watts", description: "Power", scale: 1000000},
+	{prefix: "curr", name: "current", unit: "amperes", description: "Current", scale: 1000},
+}
+
+type sensorReading struct {
+	chip       string
+	sensorType *sensorType
+	sensor     string // Label usable in metric names, or the index of the sensor
+	label      string // Original label as provided by the driver
+	value      float64
+}
+
+// readSensors reads the <type><index>_input files of all hwmon<N> directories in the given directory, usually
+// /sys/class/hwmon. Older drivers place the files in the device subdirectory of the hwmon directory.
+func readSensors(dir string) ([]sensorReading, error) {
+	chipDirs, err := filepath.Glob(filepath.Join(dir, "hwmon[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(chipDirs)
+	chipNames := make([]string, len(chipDirs))
+	indices := make([]string, len(chipDirs))
+	nameCounts := make(map[string]int)
+	for i, chipDir := range chipDirs {
+		indices[i] = strings.TrimPrefix(filepath.Base(chipDir), "hwmon")
+		if _, err := os.Stat(filepath.Join(chipDir, "name")); os.IsNotExist(err) {
+			chipDirs[i] = filepath.Join(chipDir, "device")
+		}
+		name, err := ioutil.ReadFile(filepath.Join(chipDirs[i], "name"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		chipNames[i] = sanitizeName(string(name))
+		if chipNames[i] == "" {
+			chipNames[i] = "hwmon" + indices[i]
+		}
+		nameCounts[chipNames[i]]++
+	}
+
+	var res []sensorReading
+	for i, chipDir := range chipDirs {
+		chip := chipNames[i]
+		if nameCounts[chip] > 1 {
+			chip += "-" + indices[i]
+		}
+		readings, err := readChipSensors(chipDir, chip)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, readings...)
+	}
+	return res, nil
+}
+
+func readChipSensors(dir string, chip string) ([]sensorReading, error) {
+	var res []sensorReading
+	for i := range sensorTypes {
+		sensorType := &sensorTypes[i]
+		inputs, err := filepath.Glob(filepath.Join(dir, sensorType.prefix+"[0-9]*_input"))
+		if err != nil {
+			return nil, err
+		}
+		sensors := make(map[string]bool, len(inputs))
+		for _, input := range inputs {
+			index := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(input), sensorType.prefix), "_input")
+			if _, err := strconv.Atoi(index); err != nil {
+				continue
+			}
+			data, err := ioutil.ReadFile(input)
+			if err != nil {
+				// Faulty or disconnected sensors fail with errors like EIO or ENODATA
+				continue
+			}
+			raw, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse %v: %v", input, err)
+			}
+			reading := sensorReading{
+				chip:       chip,
+				sensorType: sensorType,
+				sensor:     index,
+				label:      sensorType.prefix + index,
+				value:      raw / sensorType.scale,
+			}
+			if label, err := ioutil.ReadFile(filepath.Join(dir, sensorType.prefix+index+"_label")); err == nil {
+				if name := sanitizeName(string(label)); name != "" && !sensors[name] {
+					reading.sensor = name
+					reading.label = strings.TrimSpace(string(label))
+				}
+			}
+			sensors[reading.sensor] = true
+			res = append(res, reading)
+		}
+	}
+	return res, nil
+}
+
+// sanitizeName makes a chip name or sensor label usable as part of a metric name, e.g. "Package id 0" becomes Package_id_0
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '/' || r == '\t' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+}
@@ -0,0 +1,71 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type SensorsTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSensors(t *testing.T) {
+	suite.Run(t, new(SensorsTestSuite))
+}
+
+func (suite *SensorsTestSuite) TestReadSensors() {
+	dir, err := ioutil.TempDir("", "hwmon")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	write := func(content string, path ...string) {
+		file := filepath.Join(append([]string{dir}, path...)...)
+		suite.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+		suite.NoError(ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	write("coretemp\n", "hwmon0", "name")
+	write("45000\n", "hwmon0", "temp1_input")
+	write("Package id 0\n", "hwmon0", "temp1_label")
+	write("100000\n", "hwmon0", "temp1_crit")
+	write("coretemp\n", "hwmon1", "name")
+	write("51500\n", "hwmon1", "temp2_input")
+	write("nct6775\n", "hwmon2", "device", "name")
+	write("1200\n", "hwmon2", "device", "fan1_input")
+	write("1020\n", "hwmon2", "device", "in0_input")
+	write("12500000\n", "hwmon2", "device", "power1_input")
+	write("1500\n", "hwmon2", "device", "curr1_input")
+	write("CPU\n", "hwmon2", "device", "curr1_label")
+	write("500\n", "hwmon2", "device", "curr2_input")
+	write("CPU\n", "hwmon2", "device", "curr2_label")
+
+	readings, err := readSensors(dir)
+	suite.NoError(err)
+	values := make(map[string]float64)
+	labels := make(map[string]string)
+	for _, reading := range readings {
+		name := reading.chip + "/" + reading.sensorType.name + "/" + reading.sensor
+		values[name] = reading.value
+		labels[name] = reading.label
+	}
+	suite.Equal(map[string]float64{
+		"coretemp-0/temp/Package_id_0": 45,
+		"coretemp-1/temp/2":            51.5,
+		"nct6775/fan/1":                1200,
+		"nct6775/voltage/0":            1.02,
+		"nct6775/power/1":              12.5,
+		"nct6775/current/CPU":          1.5,
+		"nct6775/current/2":            0.5,
+	}, values)
+	suite.Equal("Package id 0", labels["coretemp-0/temp/Package_id_0"])
+	suite.Equal("in0", labels["nct6775/voltage/0"])
+
+	write("invalid\n", "hwmon1", "temp2_input")
+	_, err = readSensors(dir)
+	suite.Error(err)
+}
@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"regexp"
 	"sort"
 	"strings"
@@ -18,6 +21,9 @@ import (
 // This stabilizes sleep times in high-CPU and low-priority situations.
 const timeoutLoopFactor = 0.1
 
+// WarmupTag is set to "true" in warm-up samples, if SampleSource.TagWarmupSamples is enabled.
+const WarmupTag = "warmup"
+
 type SampleSource struct {
 	bitflow.AbstractSampleSource
 
@@ -29,21 +35,184 @@ type SampleSource struct {
 	IncludeMetrics     []*regexp.Regexp
 	DisabledCollectors []string
 
+	// If SelectMetrics is set, only the metrics matching one of the regexes are collected, in addition to the
+	// ExcludeMetrics and IncludeMetrics. This allows splitting the metrics of one process into multiple SampleSources
+	// with different intervals, e.g. collecting CPU metrics every 100ms and virtual machine metrics every 5s.
+	SelectMetrics []*regexp.Regexp
+
+	// If FilterFile is set, it contains additional include and exclude regexes for the metrics (see parseMetricFilters).
+	// The file is checked for changes every FilterFileCheckInterval. When it changes, the metric collection is
+	// restarted with the new filters, which also leads to a new header. Invalid changes are ignored.
+	FilterFile              string
+	FilterFileCheckInterval time.Duration
+
+	// Maximum number of collectors that are updated in parallel. Independent branches of the collector graph
+	// are updated in parallel, while the dependencies of every collector are updated before the collector itself.
+	// Zero or negative values do not limit the parallelism.
+	UpdateParallelism int
+
+	// Metrics matching one of these regexes are complemented by an additional metric with the suffix
+	// StdDevMetricSuffix, which contains the standard deviation of the metric within the last StdDevWindow.
+	// The values for the standard deviation are sampled after every update of the respective collector.
+	StdDevMetrics []*regexp.Regexp
+	StdDevWindow  time.Duration
+
+	// The first WarmupSamples samples after every (re)start of the metric collection contain incomplete values,
+	// e.g. zero rates from ValueRings with only one value. By default, these samples are not emitted.
+	// If TagWarmupSamples is set, they are emitted with the tag WarmupTag set to "true" instead.
+	// The warm-up also starts over when a ValueRing is flushed because of an overflown counter, beginning with
+	// the sample that contains the repeated previous rate of the flushed ring. Only the rings created by RingFactory
+	// and CollectorErrorRings are taken into account.
+	WarmupSamples    int
+	TagWarmupSamples bool
+
+	// If metrics match any of the AnomalyMetrics regexes, an additional metric named AnomalyScoreMetric is added
+	// to every sample. It contains the root mean square of the z-scores of all matching metrics, based on an
+	// exponentially weighted mean and variance over the AnomalyWindow.
+	AnomalyMetrics []*regexp.Regexp
+	AnomalyWindow  time.Duration
+
+	// Every sample is checked against the AlertRules. When an alert is triggered or resolved, all AlertActions
+	// are executed. While alerts are active, the samples are tagged with AlertTag. Additional tags can be
+	// added to the samples through Annotate().
+	AlertRules   []AlertRule
+	AlertActions []AlertAction
+
+	// RingFactory creates the ValueRings of the collectors registered with this source, e.g. for computing the
+	// rates of counter metrics. Every source has its own factory, so that the rings are accounted per source.
+	RingFactory *ValueRingFactory
+
+	// If CollectorErrorRings is set, the metric CollectorErrorsPrefix+<collector> is added for every collector.
+	// It contains the rate of failed updates, computed through ValueRings created by the factory. Failed collectors
+	// keep reporting the failures of their retries, until the metric collection is restarted.
+	CollectorErrorRings *ValueRingFactory
+
+	// Samples are emitted every SinkInterval, independent of the collector updates. If PartialSamples is set,
+	// the metrics of collectors that have not finished the current update round are not read, since their values
+	// can be incomplete or blocked by the running update. Instead, the previous values are repeated and the
+	// sample is tagged with StaleTag. The late values are emitted with the next sample.
+	PartialSamples bool
+
+	// If HighFrequencyInterval is set, only the metrics matching HighFrequencyMetrics are collected, in addition to
+	// the regular metric filters. All collectors are updated sequentially in a tight loop, and a sample is emitted
+	// after every round, every HighFrequencyInterval. CollectInterval and SinkInterval are ignored, as well as all
+	// features processing the samples (e.g. UpdateFrequencies, UpdateTimeout, UnitNormalization, StdDevMetrics,
+	// AnomalyMetrics, AlertRules, Tags, warm-up samples, CPU budget and memory limit). Failures are not retried and
+	// only summarized in the log.
+	HighFrequencyInterval time.Duration
+	HighFrequencyMetrics  []*regexp.Regexp
+
+	// If StatsOutput is set, statistics about the collector updates and emitted samples are written to it
+	// every StatsInterval as one JSON object per line, see CollectionStats.
+	StatsOutput   io.Writer
+	StatsInterval time.Duration
+
+	// If Chaos is set, failures are injected into the collectors and metrics, see ChaosSpec. Only for testing.
+	Chaos *ChaosSpec
+
+	// If UnitNormalization is set, all metric values are converted into a consistent scheme of units,
+	// based on their metadata. Alert rules are evaluated on the converted values.
+	UnitNormalization *UnitNormalization
+
+	// Changes of the collected metrics (e.g. appearing or disappearing virtual machines, containers or network
+	// interfaces) restart the metric collection, which leads to a new header. If HeaderChangeInterval is set, these
+	// restarts are delayed so that the header changes at most once per interval, and all changes within the interval
+	// are batched into one restart. In the meantime, the previous metrics are emitted.
+	HeaderChangeInterval time.Duration
+
+	// If MissingMetricGracePeriod is set, metrics that disappear after a restart are kept in the header with NaN values
+	// for the grace period. If they reappear within the grace period, the header is not changed by them.
+	// After the grace period, the metric collection is restarted to remove the missing metrics from the header.
+	MissingMetricGracePeriod time.Duration
+
+	// Tags are added to every sample. Their values are evaluated for every sample, see TagTemplate.
+	// Annotations (see Annotate()) override tags with the same key.
+	Tags []*TagTemplate
+
 	FailedCollectorCheckInterval   time.Duration
 	FilteredCollectorCheckInterval time.Duration
 
-	loopTask       *golib.LoopTask
-	currentMetrics []string
+	// If UpdateTimeout is positive, the context of every collector update is canceled after the timeout. Updates that
+	// do not return in time (e.g. blocked in a libvirt or OVSDB call that ignores the context) are abandoned, so that
+	// they do not stall the update rounds of the other collectors. Until the abandoned update returns, the following
+	// updates of the collector fail immediately. While the updates of a collector fail, its metrics are reported as
+	// NaN instead of repeating the previous values. Like other failures, collectors exceeding ToleratedUpdateFailures
+	// are removed from the update rounds and retried with the backoff of FailedCollectorMaxCheckInterval.
+	UpdateTimeout time.Duration
+
+	// If positive, the retry interval of every failed collector is doubled after every unsuccessful retry,
+	// starting at FailedCollectorCheckInterval, until reaching FailedCollectorMaxCheckInterval.
+	// The retry intervals are kept across restarts of the metric collection. The same backoff applies
+	// when all collectors have failed.
+	FailedCollectorMaxCheckInterval time.Duration
+
+	// If CpuBudget is positive, the CPU usage of the collector process is checked every BudgetCheckInterval.
+	// The budget is a fraction of one CPU core, e.g. 0.01 for 1%. When exceeding the budget, the leaf collectors
+	// with the highest update duration are disabled one by one, except for collectors matching EssentialCollectors.
+	// Disabled collectors are removed from the update rounds immediately and their metrics are reported as NaN
+	// until the next restart of the metric collection. If no collectors can be disabled anymore, the collect interval
+	// is doubled until it reaches MaxCollectInterval. When the usage stays well below the budget (see
+	// BudgetRecoveryThreshold), the collect interval is decreased again, and then the disabled collectors are
+	// re-enabled one by one. The current state can be queried through BudgetStatus().
+	CpuBudget           float64
+	BudgetCheckInterval time.Duration
+	MaxCollectInterval  time.Duration
+	EssentialCollectors []*regexp.Regexp
+
+	// If MemoryLimit is positive, the memory usage of the collector process is checked every BudgetCheckInterval.
+	// When exceeding MemoryLimitThreshold of the limit (in bytes), the leaf collectors with the most metrics are
+	// disabled one by one, except for collectors matching EssentialCollectors. Every disabled collector restarts the
+	// metric collection (see HeaderChangeInterval) to release its memory. Like with the CpuBudget, the collectors are
+	// re-enabled when the usage stays well below the limit. The disabled collectors are logged and listed in the
+	// BudgetStatus().
+	MemoryLimit uint64
+
+	budget          budgetState
+	alerts          alertState
+	annotations     annotationState
+	header          headerState
+	facts           factsState
+	metricStatus    metricStatusState
+	prefixes        map[Collector]string // Metric prefixes of root collectors, see RegisterPrefixedCollector()
+	retries         retryState
+	filterFile      metricFilterFile
+	reload          reloadState
+	sampleCounter   sampleCounter
+	loopTask        *golib.LoopTask
+	currentMetrics  []string
+	currentMetadata MetricMetadataMap
 }
 
 func (source *SampleSource) String() string {
 	return fmt.Sprintf("CollectorSource (%v root-collectors)", len(source.RootCollectors))
 }
 
+// RegisterCollector adds a root collector to this SampleSource. The names of all root collectors must be unique.
+// Must be called before starting the SampleSource.
+func (source *SampleSource) RegisterCollector(col Collector) error {
+	return source.RegisterPrefixedCollector("", col)
+}
+
+// RegisterCollectors calls RegisterCollector() for all given collectors and stops at the first error.
+func (source *SampleSource) RegisterCollectors(cols ...Collector) error {
+	for _, col := range cols {
+		if err := source.RegisterCollector(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (source *SampleSource) CurrentMetrics() []string {
 	return source.currentMetrics
 }
 
+// CurrentMetadata returns the metadata of all metrics that are currently collected.
+// Metrics of collectors that do not provide metadata are mapped to an empty MetricMetadata value.
+func (source *SampleSource) CurrentMetadata() MetricMetadataMap {
+	return source.currentMetadata
+}
+
 func (source *SampleSource) Start(wg *sync.WaitGroup) golib.StopChan {
 	for name, val := range map[string]time.Duration{
 		"CollectInterval":                source.CollectInterval,
@@ -55,6 +224,25 @@ func (source *SampleSource) Start(wg *sync.WaitGroup) golib.StopChan {
 			return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.%v must be set to a positive value (have %v)", name, val))
 		}
 	}
+	if source.FilterFile != "" && source.FilterFileCheckInterval <= 0 {
+		return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.FilterFileCheckInterval must be set to a positive value (have %v)", source.FilterFileCheckInterval))
+	}
+	if source.HighFrequencyInterval > 0 && (source.HighFrequencyInterval < MinHighFrequencyInterval || len(source.HighFrequencyMetrics) == 0) {
+		return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.HighFrequencyInterval must be at least %v (have %v) and requires HighFrequencyMetrics",
+			MinHighFrequencyInterval, source.HighFrequencyInterval))
+	}
+	if source.StatsOutput != nil && source.StatsInterval <= 0 {
+		return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.StatsInterval must be set to a positive value (have %v)", source.StatsInterval))
+	}
+	if (source.CpuBudget > 0 || source.MemoryLimit > 0) && source.BudgetCheckInterval <= 0 {
+		return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.BudgetCheckInterval must be set to a positive value (have %v)", source.BudgetCheckInterval))
+	}
+	if len(source.AnomalyMetrics) > 0 && source.AnomalyWindow <= 0 {
+		return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.AnomalyWindow must be set to a positive value (have %v)", source.AnomalyWindow))
+	}
+	if len(source.StdDevMetrics) > 0 && source.StdDevWindow <= 0 {
+		return golib.NewStoppedChan(fmt.Errorf("The field CollectorSource.StdDevWindow must be set to a positive value (have %v)", source.StdDevWindow))
+	}
 
 	source.loopTask = &golib.LoopTask{
 		Description: source.String(),
@@ -63,8 +251,17 @@ func (source *SampleSource) Start(wg *sync.WaitGroup) golib.StopChan {
 		},
 		Loop: func(loopStop golib.StopChan) error {
 			var collectWg sync.WaitGroup
-			collectionStop, err := source.collect(&collectWg)
-			if err != nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			collectionStop, err := source.collect(ctx, &collectWg)
+			if _, ok := err.(allCollectorsFailedError); ok {
+				// Keep running and retry, since the unavailable data sources might become available later
+				retryTime := time.Now()
+				retry := source.retries.allFailed.backoff(retryTime, source.FailedCollectorCheckInterval, source.FailedCollectorMaxCheckInterval)
+				log.Warnf("%v, retrying in %v", err, retry)
+				loopStop.WaitTimeoutPrecise(retry, timeoutLoopFactor, &retryTime)
+				return nil
+			} else if err != nil {
 				return err
 			}
 			select {
@@ -72,6 +269,8 @@ func (source *SampleSource) Start(wg *sync.WaitGroup) golib.StopChan {
 			case <-loopStop.WaitChan():
 			}
 			collectionStop.Stop()
+			// Interrupt collector updates that are still running
+			cancel()
 			collectWg.Wait()
 			return nil
 		},
@@ -83,34 +282,97 @@ func (source *SampleSource) Close() {
 	source.loopTask.Stop()
 }
 
-func (source *SampleSource) collect(wg *sync.WaitGroup) (golib.StopChan, error) {
-	graph, err := source.createFilteredGraph()
+func (source *SampleSource) collect(ctx context.Context, wg *sync.WaitGroup) (golib.StopChan, error) {
+	source.reload.apply(source)
+	graph, err := source.createFilteredGraph(ctx)
 	if err != nil {
 		return golib.StopChan{}, err
 	}
-
-	metrics := graph.getMetrics()
-	fields, getValues := metrics.ConstructSample(source)
+	source.retries.reset(graph)
+	source.facts.set(graph.staticMetrics())
+
+	if source.HighFrequencyInterval > 0 {
+		metrics := graph.getMetrics()
+		source.currentMetadata = metrics.Metadata()
+		stopper := golib.NewStopChan()
+		source.startHighFrequencyLoop(ctx, wg, stopper, graph, metrics)
+		source.watchFilterFile(wg, stopper)
+		source.watchReload(wg, stopper)
+		source.watchStats(wg, stopper, graph, len(metrics))
+		return stopper, nil
+	}
+	graph.applyUnitNormalization(source.UnitNormalization, source.SinkInterval)
+	graph.applyChaos(source.Chaos)
+	graph.applyUpdateTimeout(source.UpdateTimeout)
+	graph.applyStdDevMetrics(source.StdDevMetrics, source.stdDevRingFactory())
+	graph.applyErrorMetrics(source.CollectorErrorRings)
+	if source.HeaderChangeInterval > 0 {
+		graph.restartAfter = time.Now().Add(source.HeaderChangeInterval)
+	}
+	metrics, paddingExpiry := source.padMissingMetrics(graph.getMetrics())
+	fields, getValues := metrics.ConstructSample(source, source.numExtraValues())
+	source.currentMetadata = metrics.Metadata()
+	fields, getValues = source.addAnomalyScore(fields, getValues)
 	log.Println("Collecting", len(metrics), "metrics through", len(graph.collectors), "collectors")
 	graph.applyUpdateFrequencies(source.UpdateFrequencies)
 
 	stopper := golib.NewStopChan()
-	source.startUpdates(wg, stopper, graph)
-	source.watchFilteredCollectors(wg, stopper, graph)
-	source.watchFailedCollectors(wg, stopper, graph)
+	source.startUpdates(ctx, wg, stopper, graph)
+	source.watchFilteredCollectors(ctx, wg, stopper, graph)
+	source.watchFailedCollectors(ctx, wg, stopper, graph)
+	source.watchCpuBudget(wg, stopper, graph)
+	source.watchMemoryLimit(wg, stopper, graph)
+	source.watchFilterFile(wg, stopper)
+	source.watchReload(wg, stopper)
+	source.watchStats(wg, stopper, graph, len(fields))
+	source.watchMissingMetrics(wg, stopper, graph, paddingExpiry)
 	wg.Add(1)
-	go source.sinkMetrics(wg, metrics, fields, getValues, stopper)
+	var sampleNodes []*collectorNode
+	if source.PartialSamples {
+		sampleNodes = metrics.sampleNodes()
+	}
+	go source.sinkMetrics(wg, fields, getValues, sampleNodes, stopper)
 	return stopper, nil
 }
 
-func (source *SampleSource) createGraph() (*collectorGraph, error) {
+// overflowFlushes returns the number of overflow flushes of the ValueRings created by the factories of this source
+func (source *SampleSource) overflowFlushes() int64 {
+	var flushes int64
+	if source.RingFactory != nil {
+		flushes += source.RingFactory.OverflowFlushes()
+	}
+	if source.CollectorErrorRings != nil && source.CollectorErrorRings != source.RingFactory {
+		flushes += source.CollectorErrorRings.OverflowFlushes()
+	}
+	return flushes
+}
+
+// numExtraValues returns the number of values that are appended to the collected metrics in every sample.
+func (source *SampleSource) numExtraValues() int {
+	if len(source.AnomalyMetrics) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (source *SampleSource) stdDevRingFactory() *ValueRingFactory {
+	// Make sure all values within the window fit into the ring
+	length := int(float64(source.StdDevWindow)/float64(source.CollectInterval)) + 2
+	return &ValueRingFactory{
+		Length:   length,
+		Interval: source.StdDevWindow,
+	}
+}
+
+func (source *SampleSource) createGraph(ctx context.Context) (*collectorGraph, error) {
+	disabled := source.disabledCollectors()
 	roots := make([]Collector, 0, len(source.RootCollectors))
 	for _, root := range source.RootCollectors {
-		name := root.String()
+		name := source.rootName(root)
 		isEnabled := true
-		for _, disabled := range source.DisabledCollectors {
+		for _, disabledName := range disabled {
 			// Disabled root collectors are ignored immediately
-			if name == disabled {
+			if name == disabledName || root.String() == disabledName {
 				isEnabled = false
 				break
 			}
@@ -121,37 +383,72 @@ func (source *SampleSource) createGraph() (*collectorGraph, error) {
 			log.Debugln("Disabling root collector", name)
 		}
 	}
-	return initCollectorGraph(roots)
+	return initPrefixedCollectorGraph(ctx, roots, disabled, source.prefixes)
 }
 
-func (source *SampleSource) createFilteredGraph() (*collectorGraph, error) {
-	graph, err := source.createGraph()
+func (source *SampleSource) createFilteredGraph(ctx context.Context) (*collectorGraph, error) {
+	exclude, include, err := source.metricFilters()
+	if err != nil {
+		return nil, err
+	}
+	graph, err := source.createGraph(ctx)
 	if err != nil {
 		return nil, err
 	}
-	graph.applyMetricFilters(source.ExcludeMetrics, source.IncludeMetrics)
-	graph.applyCollectorFilters(source.DisabledCollectors)
+	source.metricStatus.setAvailable(graph.listMetricMetadata())
+	graph.applyMetricFilters(exclude, include)
+	if len(source.SelectMetrics) > 0 {
+		graph.applyMetricFilters(nil, source.SelectMetrics)
+	}
+	if source.HighFrequencyInterval > 0 {
+		graph.applyMetricFilters(nil, source.HighFrequencyMetrics)
+	}
 	graph.pruneAndRepair()
 	return graph, nil
 }
 
-func (source *SampleSource) sinkMetrics(wg *sync.WaitGroup, metrics MetricSlice, fields []string, getValues func() []bitflow.Value, stopper golib.StopChan) {
+func (source *SampleSource) sinkMetrics(wg *sync.WaitGroup, fields []string, getValues func() []bitflow.Value, sampleNodes []*collectorNode, stopper golib.StopChan) {
 	defer wg.Done()
 
 	source.currentMetrics = fields
+	source.metricStatus.setSample(fields, nil)
 	header := &bitflow.Header{Fields: fields}
 	sink := source.GetSink()
+	alerts := source.newAlertEvaluator(fields)
+	tags := source.newTagEvaluator(fields)
 
 	sinkTime := time.Now()
-	for {
-		metrics.UpdateAll()
+	flushes := source.overflowFlushes()
+	for numSamples := 0; ; numSamples++ {
+		late := markLateNodes(sampleNodes)
 		values := getValues()
+		if currentFlushes := source.overflowFlushes(); currentFlushes != flushes {
+			flushes = currentFlushes
+			if source.WarmupSamples > 0 {
+				log.Debugln("Values have been flushed after a counter overflow, restarting the warm-up")
+				numSamples = 0
+			}
+		}
 		sample := &bitflow.Sample{
 			Time:   time.Now(),
 			Values: values,
 		}
-		if err := sink.Sample(sample, header); err != nil {
+		source.metricStatus.setSample(fields, values)
+		setStaleTag(sample, late)
+		tags.apply(sample)
+		source.annotations.apply(sample)
+		isWarmup := numSamples < source.WarmupSamples
+		if isWarmup && source.TagWarmupSamples {
+			sample.SetTag(WarmupTag, "true")
+		} else if !isWarmup {
+			alerts.evaluate(sample)
+		}
+		if isWarmup && !source.TagWarmupSamples {
+			log.Debugln("Suppressing warm-up sample", numSamples+1, "of", source.WarmupSamples)
+		} else if err := sink.Sample(sample, header); err != nil {
 			log.Warnln("Failed to sink", len(values), "metrics:", err)
+		} else {
+			source.sampleCounter.increment()
 		}
 		if !stopper.WaitTimeoutPrecise(source.SinkInterval, timeoutLoopFactor, &sinkTime) {
 			return
@@ -159,65 +456,26 @@ func (source *SampleSource) sinkMetrics(wg *sync.WaitGroup, metrics MetricSlice,
 	}
 }
 
-func (source *SampleSource) startUpdates(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
-	roots, leafs := graph.getRootsAndLeafs()
-	log.Debugln("Root collectors:", len(roots), roots)
-	log.Debugln("Leaf collectors:", len(leafs), leafs)
-
-	rootConditions := make([]*golib.BoolCondition, len(roots))
-	leafConditions := make([]*golib.BoolCondition, len(leafs))
-	for i, root := range roots {
-		cond := golib.NewBoolCondition()
-		rootConditions[i] = cond
-		root.preconditions = append(root.preconditions, cond)
-	}
-	for i, leaf := range leafs {
-		cond := golib.NewBoolCondition()
-		leafConditions[i] = cond
-		leaf.postconditions = append(leaf.postconditions, cond)
-	}
-
-	// Prepare all nodes for updates
-	for node := range graph.nodes {
-		node.loopUpdate(wg, stopper)
-	}
+func (source *SampleSource) startUpdates(ctx context.Context, wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
+	scheduler := newUpdateScheduler(graph, source.UpdateParallelism)
 
 	// Wait for first update of all collectors
 	log.Debugln("Performing initial collector updates...")
-	source.setAll(rootConditions)
-	for _, cond := range leafConditions {
-		cond.Wait()
-	}
+	scheduler.runRound(ctx, stopper)
 	log.Debugln("Initial updates complete, now starting background updates")
 
 	// Now do regular updates in the background
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer func() {
-			// Make sure to wake up and stop all update routines
-			stopper.Stop()
-			for node := range graph.nodes {
-				source.setAll(node.preconditions)
-			}
-		}()
 		triggerTime := time.Now()
-		for {
-			source.setAll(rootConditions)
-			if !stopper.WaitTimeoutPrecise(source.CollectInterval, timeoutLoopFactor, &triggerTime) {
-				break
-			}
+		for stopper.WaitTimeoutPrecise(source.currentCollectInterval(), timeoutLoopFactor, &triggerTime) {
+			scheduler.runRound(ctx, stopper)
 		}
 	}()
 }
 
-func (source *SampleSource) setAll(conditions []*golib.BoolCondition) {
-	for _, cond := range conditions {
-		cond.Broadcast()
-	}
-}
-
-func (source *SampleSource) watchFilteredCollectors(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
+func (source *SampleSource) watchFilteredCollectors(ctx context.Context, wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
 	filtered := graph.sortedFilteredNodes()
 	if len(filtered) == 0 {
 		return
@@ -225,15 +483,15 @@ func (source *SampleSource) watchFilteredCollectors(wg *sync.WaitGroup, stopper
 	log.Debugln("Watching filtered collectors:", filtered)
 
 	source.loopCheck(wg, stopper, &filtered, source.FilteredCollectorCheckInterval, func(node *collectorNode) {
-		err := node.collector.MetricsChanged()
+		err := node.runUpdate(ctx, node.collector.MetricsChanged)
 		if err == MetricsChanged {
 			log.Warnln("Metrics of", node, "(filtered) have changed! Restarting metric collection.")
-			stopper.Stop()
+			graph.metricsChanged(stopper)
 		} else if err == nil {
 			// Reset the update failure counter since there was no error
 			node.failedUpdates = 0
 		} else {
-			log.Warnln("Update of", node, "(filtered) failed:", err)
+			ReportError(node.String(), fmt.Errorf("Update (filtered) failed: %v", err))
 			if node.updateFailed() {
 				graph.modificationLock.Lock()
 				filtered = graph.sortedFilteredNodes()
@@ -244,7 +502,7 @@ func (source *SampleSource) watchFilteredCollectors(wg *sync.WaitGroup, stopper
 	})
 }
 
-func (source *SampleSource) watchFailedCollectors(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
+func (source *SampleSource) watchFailedCollectors(ctx context.Context, wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph) {
 	var previousList []*collectorNode
 	source.loopCheck(wg, stopper, &graph.failedList, source.FailedCollectorCheckInterval, func(node *collectorNode) {
 		// Check if graph.failedList changed in any way
@@ -253,15 +511,23 @@ func (source *SampleSource) watchFailedCollectors(wg *sync.WaitGroup, stopper go
 			previousList = graph.failedList
 		}
 
+		now := time.Now()
+		if !source.retries.isDue(node.String(), now) {
+			return
+		}
 		var err error
 		if node.isInitialized() {
-			err = node.collector.Update()
+			err = node.runUpdate(ctx, node.collector.Update)
+			node.recordErrors(err != nil)
 		} else {
-			_, err = node.init()
+			_, err = node.init(ctx)
 		}
 		if err == nil {
 			log.Warnln("Collector", node, "is not failing anymore. Restarting metric collection.")
-			stopper.Stop()
+			graph.metricsChanged(stopper)
+		} else {
+			retry := source.retries.backoff(node.String(), now, source.FailedCollectorCheckInterval, source.FailedCollectorMaxCheckInterval)
+			log.Debugf("Collector %v is still failing, retrying in %v: %v", node, retry, err)
 		}
 	})
 }
@@ -286,12 +552,16 @@ func (source *SampleSource) loopCheck(wg *sync.WaitGroup, stopper golib.StopChan
 }
 
 func (source *SampleSource) PrintMetrics() error {
-	graph, err := initCollectorGraph(source.RootCollectors)
+	exclude, include, err := source.metricFilters()
+	if err != nil {
+		return err
+	}
+	graph, err := initPrefixedCollectorGraph(context.Background(), source.RootCollectors, nil, source.prefixes)
 	if err != nil {
 		return err
 	}
 	all := graph.listMetricNames()
-	graph.applyMetricFilters(source.ExcludeMetrics, source.IncludeMetrics)
+	graph.applyMetricFilters(exclude, include)
 	filtered := graph.listMetricNames()
 	sort.Strings(all)
 	sort.Strings(filtered)
@@ -310,11 +580,48 @@ func (source *SampleSource) PrintMetrics() error {
 	return nil
 }
 
+// PrintMetricsJson prints all available metrics as JSON, including their metadata and
+// whether they are excluded by the configured metric filters.
+func (source *SampleSource) PrintMetricsJson() error {
+	exclude, include, err := source.metricFilters()
+	if err != nil {
+		return err
+	}
+	graph, err := initPrefixedCollectorGraph(context.Background(), source.RootCollectors, nil, source.prefixes)
+	if err != nil {
+		return err
+	}
+	all := graph.listMetricMetadata()
+	graph.applyMetricFilters(exclude, include)
+	filtered := graph.listMetricMetadata()
+
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]MetricStatus, len(names))
+	for i, name := range names {
+		_, included := filtered[name]
+		result[i] = MetricStatus{
+			Name:           name,
+			MetricMetadata: all[name],
+			Excluded:       !included,
+		}
+	}
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
 func (source *SampleSource) getGraphForPrinting(fullGraph bool) (*collectorGraph, error) {
 	if fullGraph {
-		return source.createGraph()
+		return source.createGraph(context.Background())
 	} else {
-		return source.createFilteredGraph()
+		return source.createFilteredGraph(context.Background())
 	}
 }
 
@@ -0,0 +1,184 @@
+package sshauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// JournalSource can be used as log source to read the sshd messages from the systemd journal through journalctl
+	JournalSource = "journal"
+
+	DefaultJournalctl = "journalctl"
+)
+
+// The process title of sshd processes that handle an authenticated user session.
+// Newer OpenSSH versions use a separate sshd-session binary.
+var sessionProcessRegex = regexp.MustCompile(`^sshd(-session)?: [^ ]+@`)
+
+// Collector reports the rates of failed and accepted SSH logins, as logged by sshd, and the number of active
+// SSH sessions. The log messages are read from an auth log file (e.g. /var/log/auth.log or /var/log/secure),
+// or from the systemd journal (LogSource set to JournalSource). Only messages logged after the start of the
+// collector are considered. The active sessions are counted through the titles of the sshd session processes.
+type Collector struct {
+	// Accessed atomically. Must be the first fields to guarantee 64-bit alignment on 32-bit platforms.
+	numFailed      uint64
+	numAccepted    uint64
+	numInvalidUser uint64
+
+	collector.AbstractCollector
+	LogSource  string
+	Journalctl string
+	factory    *collector.ValueRingFactory
+
+	failed      *collector.ValueRing
+	accepted    *collector.ValueRing
+	invalidUser *collector.ValueRing
+	sessions    int
+
+	tail    *logTail
+	journal *exec.Cmd
+}
+
+func NewSshAuthCollector(logSource string, factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("ssh"),
+		LogSource:         logSource,
+		Journalctl:        DefaultJournalctl,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.failed = col.factory.NewValueRing()
+	col.accepted = col.factory.NewValueRing()
+	col.invalidUser = col.factory.NewValueRing()
+	if col.LogSource == JournalSource {
+		// The journalctl process is stopped through the context when the collection is restarted
+		return nil, col.followJournal(ctx)
+	}
+	if col.tail == nil {
+		// The log file stays open when the collectors are restarted, to avoid missing messages
+		tail, err := openLogTail(col.LogSource)
+		if err != nil {
+			return nil, err
+		}
+		col.tail = tail
+	}
+	return nil, nil
+}
+
+func (col *Collector) followJournal(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, col.Journalctl, "--follow", "--lines=0", "--output=cat", "--identifier=sshd", "--identifier=sshd-session")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Failed to start %v: %v", col.Journalctl, err)
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			col.handleMessage(scanner.Text())
+		}
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			log.Errorf("%v stopped unexpectedly: %v (%v)", col.Journalctl, err, strings.TrimSpace(stderr.String()))
+		}
+	}()
+	return nil
+}
+
+// handleLogLine extracts the message from a syslog line written by sshd
+func (col *Collector) handleLogLine(line string) {
+	index := strings.Index(line, " sshd")
+	if index < 0 {
+		return
+	}
+	line = line[index:]
+	if index = strings.Index(line, ": "); index >= 0 {
+		col.handleMessage(line[index+2:])
+	}
+}
+
+func (col *Collector) handleMessage(msg string) {
+	switch {
+	case strings.HasPrefix(msg, "Failed "):
+		// Failed password/publickey/keyboard-interactive for ...
+		atomic.AddUint64(&col.numFailed, 1)
+	case strings.HasPrefix(msg, "Accepted "):
+		atomic.AddUint64(&col.numAccepted, 1)
+	case strings.HasPrefix(msg, "Invalid user "):
+		atomic.AddUint64(&col.numInvalidUser, 1)
+	}
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	if col.tail != nil {
+		if err := col.tail.readLines(col.handleLogLine); err != nil {
+			return err
+		}
+	}
+	sessions, err := countSessions()
+	if err != nil {
+		return err
+	}
+	col.sessions = sessions
+	col.failed.Add(collector.StoredValue(atomic.LoadUint64(&col.numFailed)))
+	col.accepted.Add(collector.StoredValue(atomic.LoadUint64(&col.numAccepted)))
+	col.invalidUser.Add(collector.StoredValue(atomic.LoadUint64(&col.numInvalidUser)))
+	return nil
+}
+
+func countSessions() (int, error) {
+	files, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return 0, err
+	}
+	sessions := 0
+	for _, file := range files {
+		cmdline, err := ioutil.ReadFile(file)
+		if err != nil {
+			// The process has probably exited in the meantime
+			continue
+		}
+		if sessionProcessRegex.Match(cmdline) {
+			sessions++
+		}
+	}
+	return sessions, nil
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	return collector.MetricReaderMap{
+		"ssh/failed":       col.failed.GetDiff,
+		"ssh/accepted":     col.accepted.GetDiff,
+		"ssh/invalid-user": col.invalidUser.GetDiff,
+		"ssh/sessions": func() bitflow.Value {
+			return bitflow.Value(col.sessions)
+		},
+	}
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	return collector.MetricMetadataMap{
+		"ssh/failed":       collector.DerivedMetric(collector.UnitPerSecond, "Failed SSH authentication attempts"),
+		"ssh/accepted":     collector.DerivedMetric(collector.UnitPerSecond, "Successful SSH logins"),
+		"ssh/invalid-user": collector.DerivedMetric(collector.UnitPerSecond, "SSH login attempts for non-existing users"),
+		"ssh/sessions":     collector.GaugeMetric(collector.UnitCount, "Active SSH sessions"),
+	}
+}
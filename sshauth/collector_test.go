@@ -0,0 +1,101 @@
+package sshauth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antongulenko/golib"
+	"github.com/stretchr/testify/suite"
+)
+
+type SshAuthTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestSshAuth(t *testing.T) {
+	suite.Run(t, new(SshAuthTestSuite))
+}
+
+func (suite *SshAuthTestSuite) TestHandleLogLine() {
+	for _, test := range []struct {
+		line                          string
+		failed, accepted, invalidUser uint64
+	}{
+		{"Oct 15 10:00:00 host sshd[1234]: Failed password for root from 10.0.0.1 port 22 ssh2", 1, 0, 0},
+		{"Oct 15 10:00:00 host sshd[1234]: Failed publickey for invalid user admin from 10.0.0.1 port 22 ssh2", 1, 0, 0},
+		{"Oct 15 10:00:00 host sshd[1234]: Accepted publickey for alice from 10.0.0.2 port 22 ssh2: ED25519 SHA256:abc", 0, 1, 0},
+		{"2026-10-15T10:00:00.000000+00:00 host sshd-session[99]: Accepted password for bob from ::1 port 22 ssh2", 0, 1, 0},
+		{"Oct 15 10:00:00 host sshd[1234]: Invalid user admin from 10.0.0.1 port 22", 0, 0, 1},
+		{"Oct 15 10:00:00 host sshd[1234]: Connection closed by 10.0.0.1 port 22 [preauth]", 0, 0, 0},
+		{"Oct 15 10:00:00 host sudo: alice : Failed password ; TTY=pts/0", 0, 0, 0},
+		{"", 0, 0, 0},
+	} {
+		col := NewSshAuthCollector("", nil)
+		col.handleLogLine(test.line)
+		suite.Equal(test.failed, col.numFailed, test.line)
+		suite.Equal(test.accepted, col.numAccepted, test.line)
+		suite.Equal(test.invalidUser, col.numInvalidUser, test.line)
+	}
+}
+
+func (suite *SshAuthTestSuite) TestSessionProcess() {
+	for _, test := range []struct {
+		cmdline string
+		session bool
+	}{
+		{"sshd: alice@pts/0\x00", true},
+		{"sshd: bob@notty\x00", true},
+		{"sshd-session: alice@pts/1\x00", true},
+		{"sshd: alice [priv]\x00", false},
+		{"sshd: /usr/sbin/sshd -D [listener] 0 of 10-100 startups\x00", false},
+		{"/usr/sbin/sshd\x00-D\x00", false},
+	} {
+		suite.Equal(test.session, sessionProcessRegex.MatchString(test.cmdline), test.cmdline)
+	}
+}
+
+func (suite *SshAuthTestSuite) TestLogTail() {
+	dir, err := ioutil.TempDir("", "sshauth")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "auth.log")
+	suite.NoError(ioutil.WriteFile(path, []byte("old line\n"), 0644))
+	appendLog := func(content string) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		suite.NoError(err)
+		_, err = file.WriteString(content)
+		suite.NoError(err)
+		suite.NoError(file.Close())
+	}
+
+	tail, err := openLogTail(path)
+	suite.NoError(err)
+	defer tail.close()
+	for _, test := range []struct {
+		name   string
+		modify func()
+		lines  []string
+	}{
+		{"existing content is skipped", func() {}, nil},
+		{"appended lines", func() { appendLog("line 1\nline 2\n") }, []string{"line 1", "line 2"}},
+		{"incomplete line", func() { appendLog("line 3\nline") }, []string{"line 3"}},
+		{"completed line", func() { appendLog(" 4\n") }, []string{"line 4"}},
+		{"truncated file", func() { suite.NoError(ioutil.WriteFile(path, []byte("a\n"), 0644)) }, []string{"a"}},
+		{"rotated file", func() {
+			suite.NoError(os.Rename(path, path+".1"))
+			suite.NoError(ioutil.WriteFile(path, []byte("new 1\nnew 2\n"), 0644))
+		}, []string{"new 1", "new 2"}},
+		{"removed file", func() { suite.NoError(os.Remove(path)) }, nil},
+	} {
+		test.modify()
+		var lines []string
+		suite.NoError(tail.readLines(func(line string) {
+			lines = append(lines, line)
+		}), test.name)
+		suite.Equal(test.lines, lines, test.name)
+	}
+}
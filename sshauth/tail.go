@@ -0,0 +1,89 @@
+package sshauth
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// logTail reads lines that are appended to a log file. When the file is rotated (replaced or truncated),
+// the new file is read from the beginning.
+type logTail struct {
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	offset  int64
+	partial string
+}
+
+// openLogTail opens the given file and skips its current content
+func openLogTail(path string) (*logTail, error) {
+	tail := &logTail{path: path}
+	if err := tail.open(io.SeekEnd); err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
+
+func (t *logTail) open(whence int) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	offset, err := file.Seek(0, whence)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	t.close()
+	t.file = file
+	t.reader = bufio.NewReader(file)
+	t.offset = offset
+	t.partial = ""
+	return nil
+}
+
+func (t *logTail) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+// readLines passes all complete lines appended since the last call to the given handler
+func (t *logTail) readLines(handler func(line string)) error {
+	if err := t.checkRotated(); err != nil {
+		return err
+	}
+	for {
+		line, err := t.reader.ReadString('\n')
+		t.offset += int64(len(line))
+		if err == io.EOF {
+			// Keep incomplete lines until the rest is written
+			t.partial += line
+			return nil
+		} else if err != nil {
+			return err
+		}
+		handler(strings.TrimSuffix(t.partial+line, "\n"))
+		t.partial = ""
+	}
+}
+
+func (t *logTail) checkRotated() error {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// The file might be in the process of being rotated, continue reading the old file
+		return nil
+	}
+	current, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, current) || info.Size() < t.offset {
+		// Lines that were appended to the old file since the last call are lost
+		return t.open(io.SeekStart)
+	}
+	return nil
+}
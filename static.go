@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// StaticMetricsCollector can optionally be implemented by a Collector to mark some of its metrics as static, i.e. their
+// values do not change while the collector is running, like the total RAM or the number of CPUs. The returned names
+// must be keys of the map returned by Collector.Metrics(). The readers of static metrics are invoked once after Init()
+// returned successfully and again only when SampleSource.RefreshStaticMetrics() is called. Every sample contains the
+// cached value. Since the first read happens before the first Update(), the readers of static metrics should obtain
+// their values independently of Update(). Static metrics are also available as host facts through SampleSource.Facts().
+type StaticMetricsCollector interface {
+	StaticMetrics() []string
+}
+
+// staticMetric caches the value of the reader of a static metric
+type staticMetric struct {
+	name   string
+	reader MetricReader
+	lock   sync.Mutex
+	value  bitflow.Value
+}
+
+func (m *staticMetric) refresh() {
+	value := m.reader()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.value = value
+}
+
+func (m *staticMetric) read() bitflow.Value {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.value
+}
+
+// applyStaticMetrics replaces the readers of static metrics with readers of the cached values. Must be called
+// after applyPrefix(), so that the static metrics are listed with their final names.
+func (node *collectorNode) applyStaticMetrics() {
+	staticCol, ok := node.collector.(StaticMetricsCollector)
+	if !ok {
+		node.staticMetrics = nil
+		return
+	}
+	names := staticCol.StaticMetrics()
+	node.staticMetrics = make([]*staticMetric, 0, len(names))
+	for _, name := range names {
+		name = prefixedName(node.prefix, name)
+		reader, ok := node.metrics[name]
+		if !ok {
+			continue
+		}
+		metric := &staticMetric{name: name, reader: reader}
+		metric.refresh()
+		node.metrics[name] = metric.read
+		node.staticMetrics = append(node.staticMetrics, metric)
+	}
+}
+
+// isStaticMetric returns whether the given metric of the node is static. The readers of static metrics only access
+// the cached values and can be invoked at any time.
+func (node *collectorNode) isStaticMetric(name string) bool {
+	for _, metric := range node.staticMetrics {
+		if metric.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// staticMetrics returns the static metrics of all nodes that have not been removed by the metric filters
+func (g *collectorGraph) staticMetrics() []*staticMetric {
+	var res []*staticMetric
+	for node := range g.nodes {
+		for _, metric := range node.staticMetrics {
+			if _, ok := node.metrics[metric.name]; ok {
+				res = append(res, metric)
+			}
+		}
+	}
+	return res
+}
+
+// factsState stores the static metrics of the current collector graph, which are accessed by the REST API
+type factsState struct {
+	lock    sync.Mutex
+	metrics []*staticMetric
+}
+
+func (f *factsState) set(metrics []*staticMetric) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.metrics = metrics
+}
+
+func (f *factsState) get() []*staticMetric {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.metrics
+}
+
+// Facts returns the cached values of all static metrics that are currently collected, see StaticMetricsCollector.
+func (source *SampleSource) Facts() map[string]bitflow.Value {
+	metrics := source.facts.get()
+	res := make(map[string]bitflow.Value, len(metrics))
+	for _, metric := range metrics {
+		res[metric.name] = metric.read()
+	}
+	return res
+}
+
+// RefreshStaticMetrics invokes the readers of all static metrics that are currently collected and caches the new values.
+func (source *SampleSource) RefreshStaticMetrics() {
+	for _, metric := range source.facts.get() {
+		metric.refresh()
+	}
+}
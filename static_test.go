@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+type staticMockCollector struct {
+	mockCollector
+	reads int
+}
+
+func (col *staticMockCollector) Metrics() MetricReaderMap {
+	return MetricReaderMap{
+		"total": func() bitflow.Value {
+			col.reads++
+			return bitflow.Value(col.reads)
+		},
+		"excluded": func() bitflow.Value {
+			return 0
+		},
+	}
+}
+
+func (col *staticMockCollector) StaticMetrics() []string {
+	return []string{"total", "excluded", "missing"}
+}
+
+func (suite *SchedulerTestSuite) TestStaticMetrics() {
+	col := &staticMockCollector{mockCollector: mockCollector{log: newUpdateLog(), AbstractCollector: RootCollector("static")}}
+	source := &SampleSource{ExcludeMetrics: []*regexp.Regexp{regexp.MustCompile("^hv1/excluded$")}}
+	suite.NoError(source.RegisterPrefixedCollector("hv1", col))
+
+	graph, err := source.createFilteredGraph(context.Background())
+	suite.NoError(err)
+	source.facts.set(graph.staticMetrics())
+	suite.Equal(1, col.reads)
+	fields, getValues := graph.getMetrics().ConstructSample(source, 0)
+	suite.Equal([]string{"hv1/total"}, fields)
+	suite.Equal([]bitflow.Value{1}, getValues())
+	suite.Equal([]bitflow.Value{1}, getValues())
+	suite.Equal(map[string]bitflow.Value{"hv1/total": 1}, source.Facts())
+
+	source.RefreshStaticMetrics()
+	suite.Equal(2, col.reads)
+	suite.Equal([]bitflow.Value{2}, getValues())
+	suite.Equal(map[string]bitflow.Value{"hv1/total": 2}, source.Facts())
+}
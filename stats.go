@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antongulenko/golib"
+	log "github.com/sirupsen/logrus"
+)
+
+// CollectionStats is written to SampleSource.StatsOutput every StatsInterval. All counters refer to the time since
+// the previous CollectionStats, or since the (re)start of the metric collection.
+type CollectionStats struct {
+	Time       time.Time        `json:"time"`
+	Interval   string           `json:"interval"`
+	Samples    int              `json:"samples"`
+	Metrics    int              `json:"metrics"`
+	Collectors []CollectorStats `json:"collectors"`
+	Failed     []string         `json:"failed-collectors,omitempty"`
+}
+
+// CollectorStats describes the updates of one active collector, see CollectionStats.
+type CollectorStats struct {
+	Name         string  `json:"name"`
+	Metrics      int     `json:"metrics"`
+	Updates      int     `json:"updates"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg-latency-ms"`
+	MaxLatencyMs float64 `json:"max-latency-ms"`
+}
+
+// nodeStats accumulates the updates of a collectorNode between two CollectionStats
+type nodeStats struct {
+	lock         sync.Mutex
+	updates      int
+	errors       int
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+func (s *nodeStats) record(latency time.Duration, failed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.updates++
+	if failed {
+		s.errors++
+	}
+	s.totalLatency += latency
+	if latency > s.maxLatency {
+		s.maxLatency = latency
+	}
+}
+
+func (s *nodeStats) reset(res *CollectorStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res.Updates, res.Errors = s.updates, s.errors
+	if s.updates > 0 {
+		res.AvgLatencyMs = float64(s.totalLatency) / float64(s.updates) / float64(time.Millisecond)
+	}
+	res.MaxLatencyMs = float64(s.maxLatency) / float64(time.Millisecond)
+	s.updates, s.errors, s.totalLatency, s.maxLatency = 0, 0, 0, 0
+}
+
+// sampleCounter counts the samples emitted by sinkMetrics() between two CollectionStats
+type sampleCounter struct {
+	lock    sync.Mutex
+	samples int
+}
+
+func (c *sampleCounter) increment() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.samples++
+}
+
+func (c *sampleCounter) reset() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	res := c.samples
+	c.samples = 0
+	return res
+}
+
+func (source *SampleSource) watchStats(wg *sync.WaitGroup, stopper golib.StopChan, graph *collectorGraph, numMetrics int) {
+	if source.StatsOutput == nil {
+		return
+	}
+	source.sampleCounter.reset()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		encoder := json.NewEncoder(source.StatsOutput)
+		checkTime := time.Now()
+		for stopper.WaitTimeoutPrecise(source.StatsInterval, timeoutLoopFactor, &checkTime) {
+			if err := encoder.Encode(source.collectionStats(graph, numMetrics)); err != nil {
+				log.Warnln("Failed to write collection statistics:", err)
+			}
+		}
+	}()
+}
+
+func (source *SampleSource) collectionStats(graph *collectorGraph, numMetrics int) *CollectionStats {
+	res := &CollectionStats{
+		Time:       time.Now(),
+		Interval:   source.StatsInterval.String(),
+		Samples:    source.sampleCounter.reset(),
+		Metrics:    numMetrics,
+		Collectors: []CollectorStats{},
+	}
+	graph.modificationLock.Lock()
+	defer graph.modificationLock.Unlock()
+	for node := range graph.nodes {
+		stats := CollectorStats{Name: node.String(), Metrics: len(node.metrics)}
+		node.stats.reset(&stats)
+		res.Collectors = append(res.Collectors, stats)
+	}
+	for _, node := range graph.failedList {
+		res.Failed = append(res.Failed, node.String())
+	}
+	sort.Slice(res.Collectors, func(i, j int) bool {
+		return res.Collectors[i].Name < res.Collectors[j].Name
+	})
+	sort.Strings(res.Failed)
+	return res
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/antongulenko/golib"
+)
+
+type failingCollector struct {
+	mockCollector
+}
+
+func (col *failingCollector) Update(_ context.Context) error {
+	return errors.New("failed")
+}
+
+func (suite *SchedulerTestSuite) TestCollectionStats() {
+	l := newUpdateLog()
+	ok := &mockCollector{AbstractCollector: RootCollector("ok"), log: l}
+	failing := &failingCollector{mockCollector{AbstractCollector: RootCollector("failing"), log: l}}
+	graph, err := initCollectorGraph(context.Background(), []Collector{ok, failing}, nil)
+	suite.NoError(err)
+	for node := range graph.nodes {
+		node.update(context.Background(), golib.NewStopChan())
+		node.update(context.Background(), golib.NewStopChan())
+	}
+	source := &SampleSource{StatsInterval: time.Minute}
+	source.sampleCounter.increment()
+
+	stats := source.collectionStats(graph, 2)
+	suite.Equal(1, stats.Samples)
+	suite.Equal(2, stats.Metrics)
+	suite.Equal([]string{"failing"}, stats.Failed)
+	suite.Len(stats.Collectors, 1)
+	suite.Equal("ok", stats.Collectors[0].Name)
+	suite.Equal(1, stats.Collectors[0].Metrics)
+	suite.Equal(2, stats.Collectors[0].Updates)
+	suite.Equal(0, stats.Collectors[0].Errors)
+
+	// The counters are reset after every call
+	stats = source.collectionStats(graph, 2)
+	suite.Equal(0, stats.Samples)
+	suite.Equal(0, stats.Collectors[0].Updates)
+}
+
+func (suite *SchedulerTestSuite) TestErrorMetrics() {
+	l := newUpdateLog()
+	ok := &mockCollector{AbstractCollector: RootCollector("ok"), log: l}
+	failing := &failingCollector{mockCollector{AbstractCollector: RootCollector("failing"), log: l}}
+	graph, err := initCollectorGraph(context.Background(), []Collector{ok, failing}, nil)
+	suite.NoError(err)
+	graph.applyErrorMetrics(&ValueRingFactory{Length: 10, Interval: time.Minute})
+	metrics := graph.getMetrics().Metadata()
+	suite.Len(metrics, 4)
+	suite.Contains(metrics, CollectorErrorsPrefix+"ok")
+	suite.Contains(metrics, CollectorErrorsPrefix+"failing")
+
+	for node := range graph.nodes {
+		node.update(context.Background(), golib.NewStopChan())
+		if node.String() == "failing" {
+			suite.Equal(1, node.errorCount)
+			suite.True(node.metrics[CollectorErrorsPrefix+"failing"]() > 0)
+		} else {
+			suite.Equal(0, node.errorCount)
+			suite.Equal(0.0, float64(node.metrics[CollectorErrorsPrefix+"ok"]()))
+		}
+	}
+}
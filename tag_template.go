@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// TagTemplate adds a tag to all outgoing samples. The value can contain placeholders in the format {kind:argument}
+// or ${kind:argument}. Environment variables ({env:NAME}) are resolved once when parsing the template, while
+// metric values ({metric:name}) and the sample time formatted with a Go time layout ({time:2006-01-02}) are
+// evaluated for every sample.
+type TagTemplate struct {
+	Key      string
+	Template string
+	parts    []tagTemplatePart
+}
+
+type tagTemplatePart struct {
+	text       string
+	metric     string
+	timeLayout string
+}
+
+var tagTemplatePlaceholder = regexp.MustCompile(`\$?\{([a-zA-Z]+):([^}]*)\}`)
+
+// ParseTagTemplate parses a tag template in the format key=template, e.g. rack=${ENV:RACK} or vms={metric:libvirt/num_domains}.
+func ParseTagTemplate(spec string) (*TagTemplate, error) {
+	index := strings.IndexRune(spec, '=')
+	if index <= 0 {
+		return nil, fmt.Errorf("Invalid tag '%v', expected format: key=value", spec)
+	}
+	res := &TagTemplate{Key: spec[:index], Template: spec[index+1:]}
+	text := ""
+	last := 0
+	for _, match := range tagTemplatePlaceholder.FindAllStringSubmatchIndex(res.Template, -1) {
+		text += res.Template[last:match[0]]
+		last = match[1]
+		kind, arg := res.Template[match[2]:match[3]], res.Template[match[4]:match[5]]
+		switch strings.ToLower(kind) {
+		case "env":
+			value, ok := os.LookupEnv(arg)
+			if !ok {
+				return nil, fmt.Errorf("Environment variable %v of tag '%v' is not set", arg, spec)
+			}
+			text += value
+		case "metric", "time":
+			if arg == "" {
+				return nil, fmt.Errorf("Missing argument of placeholder {%v:} in tag '%v'", kind, spec)
+			}
+			if text != "" {
+				res.parts = append(res.parts, tagTemplatePart{text: text})
+				text = ""
+			}
+			if strings.ToLower(kind) == "metric" {
+				res.parts = append(res.parts, tagTemplatePart{metric: arg})
+			} else {
+				res.parts = append(res.parts, tagTemplatePart{timeLayout: arg})
+			}
+		default:
+			return nil, fmt.Errorf("Unknown placeholder {%v:%v} in tag '%v', expected env, metric or time", kind, arg, spec)
+		}
+	}
+	text += res.Template[last:]
+	if text != "" {
+		res.parts = append(res.parts, tagTemplatePart{text: text})
+	}
+	return res, nil
+}
+
+func (tag *TagTemplate) String() string {
+	return tag.Key + "=" + tag.Template
+}
+
+type tagEvaluator struct {
+	tags    []*TagTemplate
+	indices map[string]int
+}
+
+func (source *SampleSource) newTagEvaluator(fields []string) *tagEvaluator {
+	if len(source.Tags) == 0 {
+		return nil
+	}
+	indices := make(map[string]int, len(fields))
+	for i, field := range fields {
+		indices[field] = i
+	}
+	evaluator := &tagEvaluator{indices: make(map[string]int)}
+	for _, tag := range source.Tags {
+		missing := false
+		for _, part := range tag.parts {
+			if part.metric == "" {
+				continue
+			}
+			index, ok := indices[part.metric]
+			if !ok {
+				log.Warnf("Metric %v of tag '%v' is not collected, ignoring the tag", part.metric, tag)
+				missing = true
+				break
+			}
+			evaluator.indices[part.metric] = index
+		}
+		if !missing {
+			evaluator.tags = append(evaluator.tags, tag)
+		}
+	}
+	return evaluator
+}
+
+// apply evaluates all tag templates for the given sample and sets the resulting tags.
+func (evaluator *tagEvaluator) apply(sample *bitflow.Sample) {
+	if evaluator == nil {
+		return
+	}
+	for _, tag := range evaluator.tags {
+		var value strings.Builder
+		for _, part := range tag.parts {
+			switch {
+			case part.metric != "":
+				value.WriteString(strconv.FormatFloat(float64(sample.Values[evaluator.indices[part.metric]]), 'g', -1, 64))
+			case part.timeLayout != "":
+				value.WriteString(sample.Time.Format(part.timeLayout))
+			default:
+				value.WriteString(part.text)
+			}
+		}
+		sample.SetTag(tag.Key, value.String())
+	}
+}
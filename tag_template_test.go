@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type TagTemplateTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestTagTemplate(t *testing.T) {
+	suite.Run(t, new(TagTemplateTestSuite))
+}
+
+func (suite *TagTemplateTestSuite) TestTemplates() {
+	suite.NoError(os.Setenv("BITFLOW_TEST_RACK", "r12"))
+	defer func() {
+		_ = os.Unsetenv("BITFLOW_TEST_RACK")
+	}()
+	source := &SampleSource{}
+	for _, spec := range []string{
+		"static=value",
+		"rack=${ENV:BITFLOW_TEST_RACK}",
+		"host=rack-{env:BITFLOW_TEST_RACK}-{metric:cpu}",
+		"day={time:2006-01-02}",
+		"vms={metric:libvirt/num_domains}",
+		"missing={metric:not-collected}",
+	} {
+		tag, err := ParseTagTemplate(spec)
+		suite.NoError(err, spec)
+		source.Tags = append(source.Tags, tag)
+	}
+	sample := &bitflow.Sample{
+		Time:   time.Date(2020, 3, 4, 10, 0, 0, 0, time.UTC),
+		Values: []bitflow.Value{0.5, 3},
+	}
+	source.newTagEvaluator([]string{"cpu", "libvirt/num_domains"}).apply(sample)
+	suite.Equal("day=2020-03-04 host=rack-r12-0.5 rack=r12 static=value vms=3", sample.TagString())
+}
+
+func (suite *TagTemplateTestSuite) TestInvalidTemplates() {
+	for _, spec := range []string{
+		"no-value",
+		"=value",
+		"x=${ENV:BITFLOW_TEST_NOT_SET}",
+		"x={metric:}",
+		"x={unknown:abc}",
+	} {
+		_, err := ParseTagTemplate(spec)
+		suite.Error(err, spec)
+	}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+// errUpdateHanging is returned instead of updating a collector, while a previous update that exceeded
+// SampleSource.UpdateTimeout has not returned yet.
+var errUpdateHanging = errors.New("The previous update timed out and has not returned yet")
+
+// applyUpdateTimeout limits the duration of the collector updates of all nodes, see SampleSource.UpdateTimeout
+func (g *collectorGraph) applyUpdateTimeout(timeout time.Duration) {
+	for node := range g.nodes {
+		node.updateTimeout = timeout
+	}
+	for node := range g.failed {
+		node.updateTimeout = timeout
+	}
+	for node := range g.filtered {
+		node.updateTimeout = timeout
+	}
+}
+
+// runUpdate executes the given update function of the collector. If updateTimeout is set, the function is executed
+// in a separate routine and its context is canceled after the timeout. Updates that do not return in time are
+// abandoned, and all further updates fail immediately until the abandoned update returns. If the given context
+// is canceled, its error is returned instead of the timeout error.
+func (node *collectorNode) runUpdate(ctx context.Context, update func(ctx context.Context) error) error {
+	if node.updateTimeout <= 0 {
+		return update(ctx)
+	}
+	if !atomic.CompareAndSwapInt32(&node.updateRunning, 0, 1) {
+		return errUpdateHanging
+	}
+	updateCtx, cancel := context.WithTimeout(ctx, node.updateTimeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		err := update(updateCtx)
+		atomic.StoreInt32(&node.updateRunning, 0)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-updateCtx.Done():
+		// Prefer the result of an update that finished at the same time
+		select {
+		case err := <-result:
+			return err
+		default:
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("Update did not finish within %v", node.updateTimeout)
+	}
+}
+
+// setFailing marks whether the last update of the node has failed, see failureReader()
+func (node *collectorNode) setFailing(failing bool) {
+	var val int32
+	if failing {
+		val = 1
+	}
+	atomic.StoreInt32(&node.failing, val)
+}
+
+// failureReader returns a reader that reports the metric as missing (NaN) while the updates of the node are failing,
+// instead of repeating the value of the last successful update.
+func (node *collectorNode) failureReader(reader MetricReader) MetricReader {
+	return func() bitflow.Value {
+		if atomic.LoadInt32(&node.failing) != 0 {
+			return bitflow.Value(math.NaN())
+		}
+		return reader()
+	}
+}
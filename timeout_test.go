@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+func (suite *SchedulerTestSuite) TestUpdateTimeout() {
+	l := newUpdateLog()
+	fast := &mockCollector{AbstractCollector: RootCollector("fast"), log: l}
+	hanging := &mockCollector{AbstractCollector: RootCollector("hanging"), log: l}
+	graph, err := initCollectorGraph(context.Background(), []Collector{fast, hanging}, nil)
+	suite.NoError(err)
+	graph.applyUpdateTimeout(20 * time.Millisecond)
+	fields, getValues := graph.getMetrics().ConstructSample(&SampleSource{UpdateTimeout: 20 * time.Millisecond}, 0)
+	suite.Equal([]string{"fast", "hanging"}, fields)
+	scheduler := newUpdateScheduler(graph, 0)
+	stopper := golib.NewStopChan()
+	node := graph.resolve(hanging)
+
+	release := make(chan bool)
+	hanging.onUpdate = func() {
+		<-release
+	}
+	start := time.Now()
+	scheduler.runRound(context.Background(), stopper)
+	suite.True(time.Since(start) < time.Second, "Update round was stalled by the hanging collector")
+	values := getValues()
+	suite.Equal(bitflow.Value(0), values[0])
+	suite.True(math.IsNaN(float64(values[1])))
+
+	// The hanging update is not repeated, and the collector is removed after the tolerated number of failures
+	suite.Equal(errUpdateHanging, node.runUpdate(context.Background(), hanging.Update))
+	scheduler.runRound(context.Background(), stopper)
+	suite.False(graph.containsNode(node))
+	suite.True(graph.failed[node])
+	suite.True(math.IsNaN(float64(getValues()[1])))
+
+	// The retry succeeds after the hanging update returned
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	suite.NoError(node.runUpdate(context.Background(), hanging.Update))
+}
+
+func (suite *SchedulerTestSuite) TestRunUpdate() {
+	updateErr := errors.New("update failed")
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	waitForTimeout := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return ctx.Err()
+	}
+	for _, test := range []struct {
+		name   string
+		ctx    context.Context
+		update func(ctx context.Context) error
+		err    string
+	}{
+		{"success", context.Background(), func(ctx context.Context) error { return nil }, ""},
+		{"error", context.Background(), func(ctx context.Context) error { return updateErr }, updateErr.Error()},
+		{"timeout", context.Background(), waitForTimeout, "Update did not finish within 20ms"},
+		{"canceled parent", canceled, waitForTimeout, context.Canceled.Error()},
+	} {
+		node := &collectorNode{updateTimeout: 20 * time.Millisecond}
+		contexts := make(chan context.Context, 1)
+		err := node.runUpdate(test.ctx, func(ctx context.Context) error {
+			contexts <- ctx
+			return test.update(ctx)
+		})
+		if test.err == "" {
+			suite.NoError(err, test.name)
+		} else {
+			suite.EqualError(err, test.err, test.name)
+		}
+		// The context of the update is canceled when runUpdate returns
+		suite.Error((<-contexts).Err(), test.name)
+	}
+}
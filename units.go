@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+)
+
+// Units of MetricMetadata.Unit produced by UnitNormalization, in addition to the common units
+const (
+	UnitKiB         = "KiB"
+	UnitMiB         = "MiB"
+	UnitGiB         = "GiB"
+	UnitRatio       = "ratio"
+	UnitPerInterval = "interval"
+
+	rateSuffix = "/" + UnitSeconds
+)
+
+// UnitNormalization converts the values of all metrics into a consistent scheme of units, based on their
+// MetricMetadata.Unit. Size is the unit of byte values (bytes, KiB, MiB or GiB), Percent is either percent or ratio
+// (values between 0 and 1), Rate is the denominator of rates (sec or interval, where interval means the SinkInterval),
+// and Time is the unit of durations (ms or sec). Units are converted in numerators and denominators separately,
+// e.g. bytes/sec can become MiB/interval. Empty fields leave the respective units unchanged.
+type UnitNormalization struct {
+	Size    string
+	Percent string
+	Rate    string
+	Time    string
+}
+
+var unitSizes = map[string]float64{
+	UnitBytes: 1,
+	UnitKiB:   1 << 10,
+	UnitMiB:   1 << 20,
+	UnitGiB:   1 << 30,
+}
+
+var unitTimes = map[string]float64{
+	UnitMillis:  0.001,
+	UnitSeconds: 1,
+}
+
+// ParseUnitNormalization parses a comma-separated list of key=value pairs, e.g. size=MiB,percent=ratio,rate=interval,time=sec.
+func ParseUnitNormalization(spec string) (*UnitNormalization, error) {
+	res := new(UnitNormalization)
+	for _, part := range strings.Split(spec, ",") {
+		index := strings.IndexRune(part, '=')
+		if index <= 0 {
+			return nil, fmt.Errorf("Invalid unit normalization '%v', expected format: key=value,...", spec)
+		}
+		key, value := part[:index], part[index+1:]
+		var valid bool
+		switch key {
+		case "size":
+			_, valid = unitSizes[value]
+			res.Size = value
+		case "percent":
+			valid = value == UnitPercent || value == UnitRatio
+			res.Percent = value
+		case "rate":
+			valid = value == UnitSeconds || value == UnitPerInterval
+			res.Rate = value
+		case "time":
+			_, valid = unitTimes[value]
+			res.Time = value
+		default:
+			return nil, fmt.Errorf("Unknown key '%v' in unit normalization, available: size, percent, rate, time", key)
+		}
+		if !valid {
+			return nil, fmt.Errorf("Invalid unit '%v' for %v in unit normalization", value, key)
+		}
+	}
+	return res, nil
+}
+
+func (n *UnitNormalization) String() string {
+	return fmt.Sprintf("size=%v,percent=%v,rate=%v,time=%v", n.Size, n.Percent, n.Rate, n.Time)
+}
+
+// convert returns the normalized unit and the factor for converting values into it
+func (n *UnitNormalization) convert(unit string, interval time.Duration) (string, float64) {
+	if strings.HasSuffix(unit, rateSuffix) {
+		unit = strings.TrimSuffix(unit, rateSuffix)
+		numerator, numeratorFactor := n.convertSimple(unit)
+		if n.Rate == UnitPerInterval {
+			return numerator + "/" + UnitPerInterval, numeratorFactor * interval.Seconds()
+		}
+		return numerator + rateSuffix, numeratorFactor
+	}
+	return n.convertSimple(unit)
+}
+
+func (n *UnitNormalization) convertSimple(unit string) (string, float64) {
+	if size, ok := unitSizes[unit]; ok && n.Size != "" {
+		return n.Size, size / unitSizes[n.Size]
+	}
+	if seconds, ok := unitTimes[unit]; ok && n.Time != "" {
+		return n.Time, seconds / unitTimes[n.Time]
+	}
+	if unit == UnitPercent && n.Percent == UnitRatio {
+		return UnitRatio, 0.01
+	}
+	return unit, 1
+}
+
+// applyUnitNormalization replaces the metric readers of all nodes with readers converting the values into the
+// normalized units, and updates the units in the metadata accordingly.
+func (g *collectorGraph) applyUnitNormalization(n *UnitNormalization, interval time.Duration) {
+	if n == nil {
+		return
+	}
+	converted := 0
+	for node := range g.nodes {
+		for name, reader := range node.metrics {
+			metadata := node.metadata[name]
+			unit, factor := n.convert(metadata.Unit, interval)
+			if unit == metadata.Unit && factor == 1 {
+				continue
+			}
+			metadata.Unit = unit
+			node.metadata[name] = metadata
+			node.metrics[name] = scaledReader(reader, factor)
+			converted++
+		}
+	}
+	log.Debugf("Unit normalization (%v) applied to %v metrics", n, converted)
+}
+
+func scaledReader(reader MetricReader, factor float64) MetricReader {
+	return func() bitflow.Value {
+		return reader() * bitflow.Value(factor)
+	}
+}
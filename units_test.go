@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitsTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestUnits(t *testing.T) {
+	suite.Run(t, new(UnitsTestSuite))
+}
+
+func (suite *UnitsTestSuite) TestParseUnitNormalization() {
+	n, err := ParseUnitNormalization("size=MiB,percent=ratio,rate=interval,time=sec")
+	suite.NoError(err)
+	suite.Equal(&UnitNormalization{Size: "MiB", Percent: "ratio", Rate: "interval", Time: "sec"}, n)
+
+	for _, invalid := range []string{"", "size", "size=MB", "percent=bytes", "rate=min", "time=us", "unknown=x"} {
+		_, err := ParseUnitNormalization(invalid)
+		suite.Error(err, invalid)
+	}
+}
+
+func (suite *UnitsTestSuite) TestConvert() {
+	n := &UnitNormalization{Size: UnitKiB, Percent: UnitRatio, Rate: UnitPerInterval, Time: UnitSeconds}
+	for _, test := range []struct {
+		unit     string
+		expected string
+		factor   float64
+	}{
+		{UnitBytes, UnitKiB, 1.0 / 1024},
+		{UnitBytesPerSecond, "KiB/interval", 2.0 / 1024},
+		{UnitPercent, UnitRatio, 0.01},
+		{UnitPerSecond, "1/interval", 2},
+		{UnitMillisPerSec, "sec/interval", 0.002},
+		{UnitMillis, UnitSeconds, 0.001},
+		{UnitCount, UnitCount, 1},
+		{UnitNone, UnitNone, 1},
+	} {
+		unit, factor := n.convert(test.unit, 2*time.Second)
+		suite.Equal(test.expected, unit, test.unit)
+		suite.InDelta(test.factor, factor, 1e-9, test.unit)
+	}
+
+	// Empty fields leave the units unchanged
+	unit, factor := new(UnitNormalization).convert(UnitBytesPerSecond, time.Second)
+	suite.Equal(UnitBytesPerSecond, unit)
+	suite.Equal(1.0, factor)
+}
+
+func (suite *UnitsTestSuite) TestApply() {
+	graph := newEmptyGraph()
+	node := graph.newCollectorNode(&mockCollector{AbstractCollector: RootCollector("test")})
+	node.metrics = MetricReaderMap{
+		"mem":   func() bitflow.Value { return 2048 },
+		"count": func() bitflow.Value { return 3 },
+	}
+	node.metadata = MetricMetadataMap{
+		"mem": GaugeMetric(UnitBytes, "Memory"),
+	}
+	graph.applyUnitNormalization(&UnitNormalization{Size: UnitKiB}, time.Second)
+	suite.Equal(bitflow.Value(2), node.metrics["mem"]())
+	suite.Equal(UnitKiB, node.metadata["mem"].Unit)
+	suite.Equal(bitflow.Value(3), node.metrics["count"]())
+	suite.NotContains(node.metadata, "count")
+}
@@ -2,32 +2,92 @@ package collector
 
 import (
 	"fmt"
+	"math"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"github.com/bitflow-stream/go-bitflow/bitflow"
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	ringSlotSize  = int64(unsafe.Sizeof(ringSlot{}))
+	ringValueSize = int64(unsafe.Sizeof(LogbackValue(nil)))
+)
+
 type ValueRingFactory struct {
+	// Accessed atomically. Must be the first fields to guarantee 64-bit alignment on 32-bit platforms.
+	memoryUsage     int64
+	overflowFlushes int64
+
 	Length   int
 	Interval time.Duration
+
+	// The storage of released and garbage collected rings is reused for new rings
+	pool sync.Pool
 }
 
 func (factory *ValueRingFactory) NewValueRing() *ValueRing {
-	return &ValueRing{
-		values:   make([]TimedValue, factory.Length),
+	ring := &ValueRing{
+		factory:  factory,
 		interval: factory.Interval,
 	}
+	// Make sure the storage is returned to the pool, even if Release() is not called explicitly
+	runtime.SetFinalizer(ring, (*ValueRing).Release)
+	return ring
+}
+
+// MemoryUsage returns the number of bytes currently allocated for storing the values of all rings created by this factory.
+func (factory *ValueRingFactory) MemoryUsage() int64 {
+	return atomic.LoadInt64(&factory.memoryUsage)
+}
+
+// OverflowFlushes returns the number of times a ring created by this factory has been flushed in GetDiff()
+// because of an overflown value. Allows SampleSource to treat the affected samples as warm-up samples.
+func (factory *ValueRingFactory) OverflowFlushes() int64 {
+	return atomic.LoadInt64(&factory.overflowFlushes)
+}
+
+func (factory *ValueRingFactory) allocate() *ringStorage {
+	storage, ok := factory.pool.Get().(*ringStorage)
+	if ok && len(storage.slots) == factory.Length {
+		for i := range storage.slots {
+			storage.slots[i] = ringSlot{}
+		}
+	} else {
+		storage = &ringStorage{
+			slots: make([]ringSlot, factory.Length),
+		}
+	}
+	atomic.AddInt64(&factory.memoryUsage, storage.size())
+	return storage
+}
+
+func (factory *ValueRingFactory) release(storage *ringStorage) {
+	atomic.AddInt64(&factory.memoryUsage, -storage.size())
+	storage.values = nil
+	factory.pool.Put(storage)
+}
+
+func (factory *ValueRingFactory) allocateValues(storage *ringStorage) {
+	storage.values = make([]LogbackValue, len(storage.slots))
+	atomic.AddInt64(&factory.memoryUsage, int64(len(storage.values))*ringValueSize)
 }
 
 type ValueRing struct {
+	factory  *ValueRingFactory
 	interval time.Duration
-	values   []TimedValue
-	head     int // actually head+1
+	storage  *ringStorage // Allocated on the first write access
+	head     int          // actually head+1
 
-	aggregator   LogbackValue
-	previousDiff bitflow.Value
+	// StoredValue instances are aggregated in aggregatorNum, to avoid storing them as interface values
+	aggregator    LogbackValue
+	aggregatorNum float64
+	hasNum        bool
+	previousDiff  bitflow.Value
 
 	// Serializes GetDiff()/GetHead() and FlushHead()
 	// Writing access must be serialized externally!
@@ -48,15 +108,58 @@ func (val TimedValue) String() string {
 	return fmt.Sprintf("%v", val.val)
 }
 
+// ringSlot is the compact representation of a TimedValue. Values of type StoredValue are stored
+// directly in the slot. Other LogbackValue implementations are stored in ringStorage.values.
+type ringSlot struct {
+	time  int64 // Unix timestamp in nanoseconds, zero for empty slots
+	value float64
+}
+
+type ringStorage struct {
+	slots []ringSlot
+
+	// Only allocated when a LogbackValue other than StoredValue is added to the ring
+	values []LogbackValue
+}
+
+func (storage *ringStorage) size() int64 {
+	return int64(len(storage.slots))*ringSlotSize + int64(len(storage.values))*ringValueSize
+}
+
+func (storage *ringStorage) isNumeric(i int) bool {
+	return storage.values == nil || storage.values[i] == nil
+}
+
+func (storage *ringStorage) get(i int) TimedValue {
+	slot := storage.slots[i]
+	if slot.time == 0 {
+		return TimedValue{}
+	}
+	var val LogbackValue
+	if storage.isNumeric(i) {
+		val = StoredValue(slot.value)
+	} else {
+		val = storage.values[i]
+	}
+	return TimedValue{time.Unix(0, slot.time), val}
+}
+
 func (ring *ValueRing) AddValueToHead(val bitflow.Value) {
 	ring.AddToHead(StoredValue(val))
 }
 
 func (ring *ValueRing) AddToHead(val LogbackValue) {
-	if ring.aggregator == nil {
-		ring.aggregator = val
-	} else {
+	if ring.aggregator != nil {
 		ring.aggregator = ring.aggregator.AddValue(val)
+	} else if num, ok := storedNumber(val); ok {
+		ring.aggregatorNum += num
+		ring.hasNum = true
+	} else if ring.hasNum {
+		ring.aggregator = StoredValue(ring.aggregatorNum).AddValue(val)
+		ring.aggregatorNum = 0
+		ring.hasNum = false
+	} else {
+		ring.aggregator = val
 	}
 }
 
@@ -64,13 +167,38 @@ func (ring *ValueRing) FlushHead() {
 	ring.lock.Lock()
 	defer ring.lock.Unlock()
 
-	ring.values[ring.head] = TimedValue{time.Now(), ring.aggregator}
-	if ring.head >= len(ring.values)-1 {
+	if ring.storage == nil {
+		ring.storage = ring.factory.allocate()
+	}
+	storage := ring.storage
+	slot := &storage.slots[ring.head]
+	if ring.aggregator != nil {
+		if storage.values == nil {
+			ring.factory.allocateValues(storage)
+		}
+		storage.values[ring.head] = ring.aggregator
+		slot.time = time.Now().UnixNano()
+	} else {
+		if storage.values != nil {
+			storage.values[ring.head] = nil
+		}
+		if ring.hasNum {
+			slot.value = ring.aggregatorNum
+			slot.time = time.Now().UnixNano()
+		} else {
+			// Nothing was added since the last flush
+			*slot = ringSlot{}
+		}
+	}
+
+	if ring.head >= len(storage.slots)-1 {
 		ring.head = 0
 	} else {
 		ring.head++
 	}
 	ring.aggregator = nil
+	ring.aggregatorNum = 0
+	ring.hasNum = false
 }
 
 func (ring *ValueRing) Add(val LogbackValue) {
@@ -83,7 +211,7 @@ func (ring *ValueRing) AddValue(val bitflow.Value) {
 }
 
 func (ring *ValueRing) Increment(val LogbackValue) {
-	cur := ring.getHead().val
+	cur := ring.GetHead()
 	if cur != nil {
 		val = cur.AddValue(val)
 	}
@@ -103,90 +231,181 @@ func (ring *ValueRing) GetDiff() bitflow.Value {
 		// Likely means a number has overflown. Temporarily stick to same value.
 		val = ring.previousDiff
 		ring.flush(ring.head - 2) // Only keep the latest sample
+		atomic.AddInt64(&ring.factory.overflowFlushes, 1)
 	} else {
 		ring.previousDiff = val
 	}
 	return val
 }
 
+// GetVariance returns the variance of all values that have been added to the ring
+// within the time window of the ring. Only StoredValue instances are taken into account.
+func (ring *ValueRing) GetVariance() bitflow.Value {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	return ring.getVarianceInterval(ring.interval)
+}
+
+// GetStdDev returns the standard deviation of the values in the time window of the ring, see GetVariance().
+func (ring *ValueRing) GetStdDev() bitflow.Value {
+	return bitflow.Value(math.Sqrt(float64(ring.GetVariance())))
+}
+
 // May return nil in case of an empty ring
 func (ring *ValueRing) GetHead() LogbackValue {
 	ring.lock.Lock()
 	defer ring.lock.Unlock()
-	return ring.getHead().val
+	if ring.storage == nil {
+		return nil
+	}
+	return ring.storage.get(ring.headIndex()).val
+}
+
+// Release returns the storage of the ring to the pool of the ValueRingFactory. All values stored in the ring are lost.
+// Rings that are not released explicitly, are released when being garbage collected.
+func (ring *ValueRing) Release() {
+	ring.lock.Lock()
+	storage := ring.storage
+	ring.storage = nil
+	ring.head = 0
+	ring.lock.Unlock()
+	if storage != nil {
+		ring.factory.release(storage)
+	}
 }
 
 // ============================ Internal functions ============================
 
 func (ring *ValueRing) getDiffInterval(before time.Duration) bitflow.Value {
-	head := ring.getHead()
-	if head.val == nil {
-		// Probably empty ring
+	storage := ring.storage
+	if storage == nil {
+		// Empty ring
 		return bitflow.Value(0)
 	}
-	beforeTime := head.Time.Add(-before)
-	previous := ring.get(beforeTime)
-	if previous.val == nil {
+	headIndex := ring.headIndex()
+	head := storage.slots[headIndex]
+	if head.time == 0 {
+		// Probably empty ring
 		return bitflow.Value(0)
 	}
-	interval := head.Time.Sub(previous.Time)
+	previousIndex := ring.get(head.time - int64(before))
+	previous := storage.slots[previousIndex]
+	interval := time.Duration(head.time - previous.time)
 	if interval == 0 {
 		return bitflow.Value(0)
 	}
-	return head.val.DiffValue(previous.val, interval)
+	if storage.isNumeric(headIndex) && storage.isNumeric(previousIndex) {
+		// Fast path, equivalent to StoredValue.DiffValue()
+		return bitflow.Value(head.value-previous.value) / bitflow.Value(interval.Seconds())
+	}
+	return storage.get(headIndex).val.DiffValue(storage.get(previousIndex).val, interval)
 }
 
-func (ring *ValueRing) getHead() TimedValue {
-	headIndex := ring.head
-	if headIndex <= 0 {
-		headIndex = len(ring.values) - 1
-	} else {
-		headIndex--
+func (ring *ValueRing) getVarianceInterval(before time.Duration) bitflow.Value {
+	storage := ring.storage
+	if storage == nil {
+		return bitflow.Value(0)
 	}
-	return ring.values[headIndex]
-}
+	head := storage.slots[ring.headIndex()]
+	if head.time == 0 {
+		// Probably empty ring
+		return bitflow.Value(0)
+	}
+	beforeTime := head.time - int64(before)
 
-// Does not check for empty ring
-func (ring *ValueRing) get(before time.Time) (result TimedValue) {
-	walkRing := func(i int) bool {
-		if ring.values[i].val == nil {
+	// Welford's algorithm, to avoid the cancellation of the naive sum of squares for large values with a small variance
+	var mean, squaredDiffs float64
+	count := 0
+	ring.walk(func(i int) bool {
+		slot := storage.slots[i]
+		if slot.time < beforeTime {
 			return false
 		}
-		result = ring.values[i]
-		if result.Time.Before(before) {
-			return false
+		if storage.isNumeric(i) {
+			count++
+			delta := slot.value - mean
+			mean += delta / float64(count)
+			squaredDiffs += delta * (slot.value - mean)
 		}
 		return true
+	})
+	if count < 2 {
+		return bitflow.Value(0)
+	}
+	return bitflow.Value(squaredDiffs / float64(count))
+}
+
+func (ring *ValueRing) headIndex() int {
+	headIndex := ring.head
+	if headIndex <= 0 {
+		headIndex = len(ring.storage.slots) - 1
+	} else {
+		headIndex--
 	}
+	return headIndex
+}
+
+// Returns the index of the newest value recorded before the given timestamp, or of the oldest value,
+// if there is no such value. Does not check for empty ring.
+func (ring *ValueRing) get(before int64) (result int) {
+	ring.walk(func(i int) bool {
+		result = i
+		return ring.storage.slots[i].time >= before
+	})
+	return
+}
+
+// Walk the indices of all non-empty slots, starting from the newest, until the callback returns false
+func (ring *ValueRing) walk(callback func(i int) bool) {
+	slots := ring.storage.slots
 	for i := ring.head - 1; i >= 0; i-- {
-		if !walkRing(i) {
+		if slots[i].time == 0 || !callback(i) {
 			return
 		}
 	}
-	for i := len(ring.values) - 1; i >= ring.head; i-- {
-		if !walkRing(i) {
+	for i := len(slots) - 1; i >= ring.head; i-- {
+		if slots[i].time == 0 || !callback(i) {
 			return
 		}
 	}
-	return
 }
 
 func (ring *ValueRing) flush(start int) {
 	// Flush all older values, starting (including) the start
+	storage := ring.storage
 	if start < 0 {
-		start += len(ring.values)
+		start += len(storage.slots)
+	}
+	clearSlot := func(i int) bool {
+		if storage.slots[i].time == 0 {
+			return false
+		}
+		storage.slots[i] = ringSlot{}
+		if storage.values != nil {
+			storage.values[i] = nil
+		}
+		return true
 	}
 	for i := start; i >= 0; i-- {
-		if ring.values[i].val == nil {
+		if !clearSlot(i) {
 			return
 		}
-		ring.values[i].val = nil
 	}
-	for i := len(ring.values) - 1; i >= ring.head; i-- {
-		if ring.values[i].val == nil {
+	for i := len(storage.slots) - 1; i >= ring.head; i-- {
+		if !clearSlot(i) {
 			return
 		}
-		ring.values[i].val = nil
+	}
+}
+
+func storedNumber(val LogbackValue) (float64, bool) {
+	switch stored := val.(type) {
+	case StoredValue:
+		return float64(stored), true
+	case *StoredValue:
+		return float64(*stored), true
+	default:
+		return 0, false
 	}
 }
 
@@ -1,9 +1,12 @@
 package collector
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -15,6 +18,143 @@ func TestValueRing(t *testing.T) {
 	suite.Run(t, new(ValueRingTestSuite))
 }
 
+// Overwrite the timestamps of the stored values, so that they are recorded with the given step.
+// The first value added to the ring is recorded at the given base timestamp.
+func (suite *ValueRingTestSuite) setTimestamps(ring *ValueRing, base time.Time, step time.Duration, numValues int) {
+	for i := 0; i < numValues; i++ {
+		index := i % len(ring.storage.slots)
+		ring.storage.slots[index].time = base.Add(time.Duration(i) * step).UnixNano()
+	}
+}
+
+func (suite *ValueRingTestSuite) TestEmptyRing() {
+	factory := &ValueRingFactory{Length: 5, Interval: time.Second}
+	ring := factory.NewValueRing()
+	suite.Equal(bitflow.Value(0), ring.GetDiff())
+	suite.Equal(bitflow.Value(0), ring.GetStdDev())
+	suite.Nil(ring.GetHead())
+	suite.Equal(int64(0), factory.MemoryUsage())
+}
+
 func (suite *ValueRingTestSuite) TestRing() {
-	// TODO write tests
+	factory := &ValueRingFactory{Length: 10, Interval: time.Second}
+	ring := factory.NewValueRing()
+	for i := 0; i < 5; i++ {
+		ring.AddValue(bitflow.Value(i * 10))
+	}
+	suite.setTimestamps(ring, time.Now(), 100*time.Millisecond, 5)
+
+	// All values are within the interval, so the oldest value is used for the diff: 40 / 0.4s
+	suite.InDelta(100, float64(ring.GetDiff()), 0.0001)
+	suite.InDelta(math.Sqrt(200), float64(ring.GetStdDev()), 0.0001)
+	suite.Equal(StoredValue(40), ring.GetHead())
+}
+
+func (suite *ValueRingTestSuite) TestRingWrapAround() {
+	factory := &ValueRingFactory{Length: 4, Interval: 150 * time.Millisecond}
+	ring := factory.NewValueRing()
+	for i := 0; i < 10; i++ {
+		ring.AddValue(bitflow.Value(i))
+	}
+	suite.setTimestamps(ring, time.Now(), 100*time.Millisecond, 10)
+
+	// The value 7 is the first value recorded before the interval: (9 - 7) / 0.2s
+	suite.InDelta(10, float64(ring.GetDiff()), 0.0001)
+	suite.Equal(StoredValue(9), ring.GetHead())
+}
+
+func (suite *ValueRingTestSuite) TestAggregateHead() {
+	factory := &ValueRingFactory{Length: 4, Interval: time.Second}
+	ring := factory.NewValueRing()
+	ring.AddValueToHead(3)
+	ring.AddValueToHead(4)
+	ring.FlushHead()
+	suite.Equal(StoredValue(7), ring.GetHead())
+	ring.IncrementValue(3)
+	suite.Equal(StoredValue(10), ring.GetHead())
+}
+
+func (suite *ValueRingTestSuite) TestMemoryUsage() {
+	factory := &ValueRingFactory{Length: 10, Interval: time.Second}
+	ring1 := factory.NewValueRing()
+	ring2 := factory.NewValueRing()
+	suite.Equal(int64(0), factory.MemoryUsage())
+
+	ring1.AddValue(1)
+	suite.Equal(10*ringSlotSize, factory.MemoryUsage())
+	ring2.AddValue(1)
+	suite.Equal(20*ringSlotSize, factory.MemoryUsage())
+
+	ring1.Release()
+	suite.Equal(10*ringSlotSize, factory.MemoryUsage())
+	suite.Nil(ring1.GetHead())
+	ring2.Release()
+	suite.Equal(int64(0), factory.MemoryUsage())
+}
+
+func (suite *ValueRingTestSuite) TestOverflowFlushes() {
+	factory := &ValueRingFactory{Length: 10, Interval: time.Second}
+	other := &ValueRingFactory{Length: 10, Interval: time.Second}
+	ring := factory.NewValueRing()
+	otherRing := other.NewValueRing()
+	for _, val := range []bitflow.Value{10, 20, 30} {
+		ring.AddValue(val)
+		otherRing.AddValue(val)
+	}
+	suite.setTimestamps(ring, time.Now(), 100*time.Millisecond, 3)
+	suite.setTimestamps(otherRing, time.Now(), 100*time.Millisecond, 3)
+	rate := ring.GetDiff()
+	suite.InDelta(100, float64(rate), 0.0001)
+	suite.Equal(int64(0), factory.OverflowFlushes())
+
+	// A decreasing counter repeats the previous rate and is only counted for the factory of the ring
+	ring.AddValue(5)
+	suite.Equal(rate, ring.GetDiff())
+	suite.Equal(int64(1), factory.OverflowFlushes())
+	suite.InDelta(100, float64(otherRing.GetDiff()), 0.0001)
+	suite.Equal(int64(0), other.OverflowFlushes())
+
+	source := &SampleSource{RingFactory: factory, CollectorErrorRings: other}
+	suite.Equal(int64(1), source.overflowFlushes())
+	source.CollectorErrorRings = factory
+	suite.Equal(int64(1), source.overflowFlushes())
+}
+
+func (suite *ValueRingTestSuite) TestVariance() {
+	for _, test := range []struct {
+		name     string
+		length   int
+		values   []bitflow.Value
+		variance float64
+	}{
+		{"empty", 5, nil, 0},
+		{"single value", 5, []bitflow.Value{42}, 0},
+		{"constant", 5, []bitflow.Value{3, 3, 3, 3}, 0},
+		{"two values", 5, []bitflow.Value{1, 3}, 1},
+		{"sequence", 10, []bitflow.Value{0, 10, 20, 30, 40}, 200},
+		{"negative", 10, []bitflow.Value{-2, 2, -2, 2}, 4},
+		{"wrap around", 3, []bitflow.Value{100, 100, 1, 2, 3}, 2.0 / 3},
+		{"large values", 10, []bitflow.Value{1e12 + 1, 1e12 + 2, 1e12 + 3, 1e12 + 4}, 1.25},
+		{"large constant", 10, []bitflow.Value{1e15, 1e15, 1e15}, 0},
+	} {
+		factory := &ValueRingFactory{Length: test.length, Interval: time.Minute}
+		ring := factory.NewValueRing()
+		for _, val := range test.values {
+			ring.AddValue(val)
+		}
+		suite.InDelta(test.variance, float64(ring.GetVariance()), 0.0001, test.name)
+		suite.InDelta(math.Sqrt(test.variance), float64(ring.GetStdDev()), 0.0001, test.name)
+	}
+}
+
+func (suite *ValueRingTestSuite) TestVarianceInterval() {
+	factory := &ValueRingFactory{Length: 10, Interval: 150 * time.Millisecond}
+	ring := factory.NewValueRing()
+	for _, val := range []bitflow.Value{1000, 1000, 1, 2, 3} {
+		ring.AddValue(val)
+	}
+	suite.setTimestamps(ring, time.Now(), 100*time.Millisecond, 5)
+	// Only the values recorded within 150ms before the head are used: 2 and 3
+	suite.InDelta(0.25, float64(ring.GetVariance()), 0.0001)
+	suite.InDelta(0.5, float64(ring.GetStdDev()), 0.0001)
 }
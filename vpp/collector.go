@@ -0,0 +1,235 @@
+package vpp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"git.fd.io/govpp.git/adapter"
+	"git.fd.io/govpp.git/adapter/statsclient"
+	"git.fd.io/govpp.git/api"
+	"git.fd.io/govpp.git/core"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const DefaultStatsSocket = adapter.DefaultStatsSocket
+
+// Collector reads the statistics segment of a local FD.io VPP instance. Interface counters are reported
+// as vpp/interface/<interface>/..., the vector rates of the main and worker threads as vpp/vector-rate and
+// vpp/worker/<index>/vector-rate, and the counters of all graph nodes that have been called at least once
+// as vpp/node/<node>/...
+type Collector struct {
+	collector.AbstractCollector
+	StatsSocket string
+	factory     *collector.ValueRingFactory
+
+	conn       *core.StatsConnection
+	interfaces api.InterfaceStats
+	system     api.SystemStats
+	nodes      api.NodeStats
+
+	lock      sync.Mutex
+	counters  map[string]*collector.ValueRing
+	gauges    map[string]float64
+	metadata  collector.MetricMetadataMap
+	nodeNames map[string]bool
+}
+
+func NewVppCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("vpp"),
+		StatsSocket:       DefaultStatsSocket,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.Close()
+	col.counters = nil
+	return nil, col.update(false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Close() {
+	if col.conn != nil {
+		col.conn.Disconnect()
+		col.conn = nil
+	}
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges)+len(col.nodeNames))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	for node := range col.nodeNames {
+		prefix := "vpp/node/" + node + "/"
+		clocks, vectors := col.counters[prefix+"clocks"], col.counters[prefix+"vectors"]
+		res[prefix+"clocks-per-vector"] = func() bitflow.Value {
+			if numVectors := vectors.GetDiff(); numVectors > 0 {
+				return clocks.GetDiff() / numVectors
+			}
+			return 0
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+type vppValues struct {
+	counters  map[string]float64
+	gauges    map[string]float64
+	metadata  collector.MetricMetadataMap
+	nodeNames map[string]bool
+}
+
+func (v *vppValues) counter(name string, value uint64, description string) {
+	v.counters[name] = float64(value)
+	v.metadata[name] = collector.DerivedMetric(collector.UnitPerSecond, description)
+}
+
+func (v *vppValues) gauge(name string, value float64, unit string, description string) {
+	v.gauges[name] = value
+	v.metadata[name] = collector.GaugeMetric(unit, description)
+}
+
+func (col *Collector) ensureConnection() error {
+	if col.conn == nil {
+		conn, err := core.ConnectStats(statsclient.NewStatsClient(col.StatsSocket))
+		if err != nil {
+			return fmt.Errorf("Failed to connect to VPP stats socket %v: %v", col.StatsSocket, err)
+		}
+		col.conn = conn
+	}
+	return nil
+}
+
+func (col *Collector) readStats() error {
+	if err := col.conn.GetInterfaceStats(&col.interfaces); err != nil {
+		return err
+	}
+	if err := col.conn.GetSystemStats(&col.system); err != nil {
+		return err
+	}
+	return col.conn.GetNodeStats(&col.nodes)
+}
+
+func (col *Collector) update(checkChange bool) error {
+	if err := col.ensureConnection(); err != nil {
+		return err
+	}
+	if err := col.readStats(); err != nil {
+		// Reconnect in the next update, VPP might have been restarted
+		col.Close()
+		return err
+	}
+	return col.storeValues(col.parseStats(), checkChange)
+}
+
+// parseStats converts the statistics read from the stats segment to metric values
+func (col *Collector) parseStats() *vppValues {
+	values := &vppValues{
+		counters:  make(map[string]float64),
+		gauges:    make(map[string]float64),
+		metadata:  make(collector.MetricMetadataMap),
+		nodeNames: make(map[string]bool),
+	}
+	for _, iface := range col.interfaces.Interfaces {
+		if iface.InterfaceName == "" {
+			continue
+		}
+		prefix := "vpp/interface/" + metricName(iface.InterfaceName) + "/"
+		values.counter(prefix+"rx/bytes", iface.Rx.Bytes, "Received bytes")
+		values.counter(prefix+"rx/packets", iface.Rx.Packets, "Received packets")
+		values.counter(prefix+"tx/bytes", iface.Tx.Bytes, "Sent bytes")
+		values.counter(prefix+"tx/packets", iface.Tx.Packets, "Sent packets")
+		values.counter(prefix+"rx/errors", iface.RxErrors, "Receive errors")
+		values.counter(prefix+"tx/errors", iface.TxErrors, "Send errors")
+		values.counter(prefix+"rx/no-buf", iface.RxNoBuf, "Packets dropped due to missing buffers")
+		values.counter(prefix+"rx/miss", iface.RxMiss, "Packets missed by the receive queues")
+		values.counter(prefix+"drops", iface.Drops, "Dropped packets")
+		values.counter(prefix+"punts", iface.Punts, "Packets punted to the control plane")
+	}
+	values.gauge("vpp/vector-rate", float64(col.system.VectorRate), collector.UnitCount, "Average number of packets processed per graph dispatch")
+	values.gauge("vpp/input-rate", float64(col.system.InputRate), collector.UnitPerSecond, "Packets received by all input nodes")
+	for i, rate := range col.system.VectorRatePerWorker {
+		values.gauge(fmt.Sprintf("vpp/worker/%v/vector-rate", i), float64(rate), collector.UnitCount, "Average number of packets processed per graph dispatch of the thread")
+	}
+	for _, node := range col.nodes.Nodes {
+		if node.Calls == 0 || node.NodeName == "" {
+			// Most graph nodes are never used
+			continue
+		}
+		name := metricName(node.NodeName)
+		values.nodeNames[name] = true
+		prefix := "vpp/node/" + name + "/"
+		values.counter(prefix+"clocks", node.Clocks, "CPU clock cycles spent in the graph node")
+		values.counter(prefix+"vectors", node.Vectors, "Packets processed by the graph node")
+		values.counter(prefix+"calls", node.Calls, "Calls of the graph node")
+		values.metadata[prefix+"clocks-per-vector"] = collector.GaugeMetric(collector.UnitNone, "CPU clock cycles spent per processed packet")
+	}
+	return values
+}
+
+func (col *Collector) storeValues(values *vppValues, checkChange bool) error {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(values.counters) != len(col.counters) || len(values.gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(values.counters))
+	}
+	for name, value := range values.counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := values.counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range values.gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = values.gauges
+	col.metadata = values.metadata
+	col.nodeNames = values.nodeNames
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func metricName(name string) string {
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
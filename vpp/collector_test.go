@@ -0,0 +1,82 @@
+package vpp
+
+import (
+	"testing"
+	"time"
+
+	"git.fd.io/govpp.git/api"
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type VppTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestVpp(t *testing.T) {
+	suite.Run(t, new(VppTestSuite))
+}
+
+func (suite *VppTestSuite) newCollector() *Collector {
+	col := NewVppCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Minute})
+	col.interfaces = api.InterfaceStats{Interfaces: []api.InterfaceCounters{
+		{InterfaceName: "GigabitEthernet0/8/0", Rx: api.InterfaceCounterCombined{Packets: 10, Bytes: 1000}, Drops: 2},
+		{InterfaceName: ""},
+	}}
+	col.system = api.SystemStats{VectorRate: 4, InputRate: 100, VectorRatePerWorker: []uint64{3, 5}}
+	col.nodes = api.NodeStats{Nodes: []api.NodeCounters{
+		{NodeName: "ip4-input", Clocks: 1000, Vectors: 10, Calls: 5},
+		{NodeName: "ip6-input"},
+	}}
+	return col
+}
+
+func (suite *VppTestSuite) TestParseStats() {
+	values := suite.newCollector().parseStats()
+	for _, test := range []struct {
+		name    string
+		value   float64
+		counter bool
+	}{
+		{"vpp/interface/GigabitEthernet0_8_0/rx/bytes", 1000, true},
+		{"vpp/interface/GigabitEthernet0_8_0/rx/packets", 10, true},
+		{"vpp/interface/GigabitEthernet0_8_0/tx/packets", 0, true},
+		{"vpp/interface/GigabitEthernet0_8_0/drops", 2, true},
+		{"vpp/vector-rate", 4, false},
+		{"vpp/input-rate", 100, false},
+		{"vpp/worker/0/vector-rate", 3, false},
+		{"vpp/worker/1/vector-rate", 5, false},
+		{"vpp/node/ip4-input/clocks", 1000, true},
+		{"vpp/node/ip4-input/vectors", 10, true},
+		{"vpp/node/ip4-input/calls", 5, true},
+	} {
+		if test.counter {
+			suite.Contains(values.counters, test.name)
+			suite.Equal(test.value, values.counters[test.name], test.name)
+		} else {
+			suite.Contains(values.gauges, test.name)
+			suite.Equal(test.value, values.gauges[test.name], test.name)
+		}
+		suite.Contains(values.metadata, test.name)
+	}
+	// 10 counters of the named interface, 3 counters of the called graph node
+	suite.Len(values.counters, 13)
+	suite.Len(values.gauges, 4)
+	suite.Equal(map[string]bool{"ip4-input": true}, values.nodeNames)
+}
+
+func (suite *VppTestSuite) TestStoreValues() {
+	col := suite.newCollector()
+	suite.NoError(col.storeValues(col.parseStats(), false))
+	suite.NoError(col.storeValues(col.parseStats(), true))
+	metrics := col.Metrics()
+	suite.Len(metrics, 13+4+1)
+	suite.Contains(metrics, "vpp/node/ip4-input/clocks-per-vector")
+	suite.Equal(4.0, float64(metrics["vpp/vector-rate"]()))
+
+	// A graph node that is called for the first time adds new metrics
+	col.nodes.Nodes[1].Calls = 1
+	suite.Equal(collector.MetricsChanged, col.storeValues(col.parseStats(), true))
+	suite.Len(col.Metrics(), 13+3+4+2)
+}
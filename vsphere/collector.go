@@ -0,0 +1,227 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Length of the real-time sampling interval of ESXi performance counters, in seconds.
+// Counters of the type "summation" (like cpu.ready) are accumulated over this interval.
+const realtimeInterval = 20
+
+// Collector queries the vSphere API of a vCenter server or a standalone ESXi host. For every VM, the metrics
+// are reported as vsphere/<vm>/..., similar to the libvirt collector. Host metrics are reported as vsphere-host/<host>/...
+// The reported values are the latest real-time samples of the ESXi performance counters, which are updated every 20 seconds.
+type Collector struct {
+	collector.AbstractCollector
+	Url      string
+	Insecure bool
+
+	client *govmomi.Client
+	perf   *performance.Manager
+
+	lock     sync.Mutex
+	values   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+// NewVsphereCollector creates a collector for the given vSphere SDK URL (e.g. https://vcenter.example.com/sdk).
+// If the URL does not contain credentials, they are read from the GOVC_USERNAME and GOVC_PASSWORD environment variables.
+func NewVsphereCollector(url string, insecure bool) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("vsphere"),
+		Url:               url,
+		Insecure:          insecure,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.Close()
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Close() {
+	if client := col.client; client != nil {
+		if err := client.Logout(context.Background()); err != nil {
+			log.Warnln("Error closing vSphere session:", err)
+		}
+		col.client = nil
+		col.perf = nil
+	}
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.values))
+	for name := range col.values {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.values[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+func (col *Collector) connect(ctx context.Context) error {
+	if col.client != nil {
+		return nil
+	}
+	u, err := soap.ParseURL(col.Url)
+	if err != nil {
+		return fmt.Errorf("Failed to parse vSphere URL: %v", err)
+	}
+	if u.User == nil {
+		u.User = url.UserPassword(os.Getenv("GOVC_USERNAME"), os.Getenv("GOVC_PASSWORD"))
+	}
+	client, err := govmomi.NewClient(ctx, u, col.Insecure)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to vSphere API at %v: %v", u.Host, err)
+	}
+	col.client = client
+	col.perf = performance.NewManager(client.Client)
+	return nil
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	if err := col.connect(ctx); err != nil {
+		return err
+	}
+	values := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	err := col.collectEntities(ctx, "VirtualMachine", "vsphere/", vmCounters, values, metadata)
+	if err == nil {
+		err = col.collectEntities(ctx, "HostSystem", "vsphere-host/", hostCounters, values, metadata)
+	}
+	if err != nil {
+		// The session might have expired, reconnect in the next update
+		col.Close()
+		return err
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := len(values) != len(col.values)
+	for name := range values {
+		if _, ok := col.values[name]; !ok {
+			changed = true
+		}
+	}
+	col.values = values
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
+
+func (col *Collector) collectEntities(ctx context.Context, kind string, prefix string, counters []perfCounter, values map[string]float64, metadata collector.MetricMetadataMap) error {
+	manager := view.NewManager(col.client.Client)
+	containerView, err := manager.CreateContainerView(ctx, col.client.ServiceContent.RootFolder, []string{kind}, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := containerView.Destroy(context.Background()); err != nil {
+			log.Warnln("Failed to destroy vSphere container view:", err)
+		}
+	}()
+	var entities []mo.ManagedEntity
+	switch kind {
+	case "VirtualMachine":
+		var vms []mo.VirtualMachine
+		err = containerView.Retrieve(ctx, []string{kind}, []string{"name"}, &vms)
+		for _, vm := range vms {
+			entities = append(entities, vm.ManagedEntity)
+		}
+	case "HostSystem":
+		var hosts []mo.HostSystem
+		err = containerView.Retrieve(ctx, []string{kind}, []string{"name"}, &hosts)
+		for _, host := range hosts {
+			entities = append(entities, host.ManagedEntity)
+		}
+	}
+	if err != nil || len(entities) == 0 {
+		return err
+	}
+	names := make(map[types.ManagedObjectReference]string, len(entities))
+	refs := make([]types.ManagedObjectReference, len(entities))
+	for i, entity := range entities {
+		refs[i] = entity.Self
+		names[refs[i]] = strings.Replace(entity.Name, "/", "_", -1)
+	}
+
+	counterNames := make([]string, len(counters))
+	countersByName := make(map[string]perfCounter, len(counters))
+	for i, counter := range counters {
+		counterNames[i] = counter.name
+		countersByName[counter.name] = counter
+	}
+	spec := types.PerfQuerySpec{
+		MaxSample:  1,
+		MetricId:   []types.PerfMetricId{{Instance: "*"}},
+		IntervalId: realtimeInterval,
+	}
+	samples, err := col.perf.SampleByName(ctx, spec, counterNames, refs)
+	if err != nil {
+		return err
+	}
+	series, err := col.perf.ToMetricSeries(ctx, samples)
+	if err != nil {
+		return err
+	}
+	for _, entitySeries := range series {
+		entityPrefix := prefix + names[entitySeries.Entity] + "/"
+		for _, value := range entitySeries.Value {
+			counter, ok := countersByName[value.Name]
+			if !ok || len(value.Value) == 0 {
+				continue
+			}
+			val := float64(value.Value[len(value.Value)-1]) * counter.scale
+			if value.Instance == "" {
+				values[entityPrefix+counter.metric] = val
+				metadata[entityPrefix+counter.metric] = collector.GaugeMetric(counter.unit, counter.description)
+			} else if counter.perInstance {
+				// Latencies of individual datastores are reported separately, the aggregated metric contains the maximum
+				name := entityPrefix + counter.instancePrefix + "/" + value.Instance + "/" + counter.metric[len(counter.instancePrefix)+1:]
+				values[name] = val
+				metadata[name] = collector.GaugeMetric(counter.unit, counter.description)
+				if val > values[entityPrefix+counter.metric] {
+					values[entityPrefix+counter.metric] = val
+				}
+				metadata[entityPrefix+counter.metric] = collector.GaugeMetric(counter.unit, counter.description+" (maximum of all instances)")
+			}
+		}
+	}
+	return nil
+}
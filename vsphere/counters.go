@@ -0,0 +1,55 @@
+package vsphere
+
+import (
+	"github.com/bitflow-stream/go-bitflow-collector"
+)
+
+type perfCounter struct {
+	name        string
+	metric      string
+	unit        string
+	scale       float64
+	description string
+
+	// If true, the values of all instances are reported as <instancePrefix>/<instance>/..., e.g. for every datastore
+	perInstance    bool
+	instancePrefix string
+}
+
+const (
+	kbToBytes = 1024
+
+	// cpu.usage is reported in hundredths of a percent
+	cpuUsageScale = 0.01
+
+	// cpu.ready is the summed up time (in ms) in the real-time interval, convert it to percent
+	cpuReadyScale = 100.0 / (realtimeInterval * 1000)
+)
+
+var vmCounters = []perfCounter{
+	{name: "cpu.usage.average", metric: "cpu", unit: collector.UnitPercent, scale: cpuUsageScale, description: "CPU usage of the VM"},
+	{name: "cpu.ready.summation", metric: "cpu/ready", unit: collector.UnitPercent, scale: cpuReadyScale, description: "Time the VM was ready to run, but could not be scheduled on a physical CPU"},
+	{name: "mem.active.average", metric: "mem/active", unit: collector.UnitBytes, scale: kbToBytes, description: "Actively used memory of the VM"},
+	{name: "mem.vmmemctl.average", metric: "mem/balloon", unit: collector.UnitBytes, scale: kbToBytes, description: "Memory reclaimed from the VM through ballooning"},
+	{name: "mem.swapped.average", metric: "mem/swapped", unit: collector.UnitBytes, scale: kbToBytes, description: "Memory of the VM swapped out by the host"},
+	{name: "mem.swapinRate.average", metric: "mem/swap-in", unit: collector.UnitBytesPerSecond, scale: kbToBytes, description: "Rate of memory swapped in from the host swap file"},
+	{name: "mem.swapoutRate.average", metric: "mem/swap-out", unit: collector.UnitBytesPerSecond, scale: kbToBytes, description: "Rate of memory swapped out to the host swap file"},
+	{name: "datastore.totalReadLatency.average", metric: "datastore/read-latency", unit: collector.UnitMillis, scale: 1, description: "Average latency of datastore read operations",
+		perInstance: true, instancePrefix: "datastore"},
+	{name: "datastore.totalWriteLatency.average", metric: "datastore/write-latency", unit: collector.UnitMillis, scale: 1, description: "Average latency of datastore write operations",
+		perInstance: true, instancePrefix: "datastore"},
+}
+
+var hostCounters = []perfCounter{
+	{name: "cpu.usage.average", metric: "cpu", unit: collector.UnitPercent, scale: cpuUsageScale, description: "CPU usage of the host"},
+	{name: "cpu.ready.summation", metric: "cpu/ready", unit: collector.UnitPercent, scale: cpuReadyScale, description: "Time VMs on the host were ready to run, but could not be scheduled"},
+	{name: "mem.usage.average", metric: "mem/percent", unit: collector.UnitPercent, scale: cpuUsageScale, description: "Memory usage of the host"},
+	{name: "mem.vmmemctl.average", metric: "mem/balloon", unit: collector.UnitBytes, scale: kbToBytes, description: "Memory reclaimed from all VMs on the host through ballooning"},
+	{name: "mem.swapused.average", metric: "mem/swapped", unit: collector.UnitBytes, scale: kbToBytes, description: "Memory of VMs swapped out by the host"},
+	{name: "mem.swapinRate.average", metric: "mem/swap-in", unit: collector.UnitBytesPerSecond, scale: kbToBytes, description: "Rate of memory swapped in from host swap files"},
+	{name: "mem.swapoutRate.average", metric: "mem/swap-out", unit: collector.UnitBytesPerSecond, scale: kbToBytes, description: "Rate of memory swapped out to host swap files"},
+	{name: "datastore.totalReadLatency.average", metric: "datastore/read-latency", unit: collector.UnitMillis, scale: 1, description: "Average latency of datastore read operations",
+		perInstance: true, instancePrefix: "datastore"},
+	{name: "datastore.totalWriteLatency.average", metric: "datastore/write-latency", unit: collector.UnitMillis, scale: 1, description: "Average latency of datastore write operations",
+		perInstance: true, instancePrefix: "datastore"},
+}
@@ -0,0 +1,204 @@
+package wireguard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/bitflow-stream/go-bitflow/bitflow"
+)
+
+const (
+	DefaultWgCommand = "wg"
+
+	// WireGuard stops using session keys after REJECT_AFTER_TIME (3 minutes). Handshakes are renewed every
+	// 2 minutes while traffic is sent, so peers without a more recent handshake are considered unreachable.
+	ReachableHandshakeAge = 3 * time.Minute
+)
+
+// Collector reports the statistics of all local WireGuard interfaces and their peers, as shown by 'wg show all dump'.
+// Peer metrics are named wireguard/<interface>/peer/<peer>/..., where <peer> is the public key of the peer
+// with '/' and '+' replaced. Reading the statistics requires the CAP_NET_ADMIN capability.
+type Collector struct {
+	collector.AbstractCollector
+	WgCommand string
+	factory   *collector.ValueRingFactory
+
+	lock     sync.Mutex
+	counters map[string]*collector.ValueRing
+	gauges   map[string]float64
+	metadata collector.MetricMetadataMap
+}
+
+func NewWireguardCollector(factory *collector.ValueRingFactory) *Collector {
+	return &Collector{
+		AbstractCollector: collector.RootCollector("wireguard"),
+		WgCommand:         DefaultWgCommand,
+		factory:           factory,
+	}
+}
+
+func (col *Collector) Init(ctx context.Context) ([]collector.Collector, error) {
+	col.counters = nil
+	return nil, col.update(ctx, false)
+}
+
+func (col *Collector) Update(ctx context.Context) error {
+	return col.update(ctx, true)
+}
+
+func (col *Collector) MetricsChanged(ctx context.Context) error {
+	return col.Update(ctx)
+}
+
+func (col *Collector) Metrics() collector.MetricReaderMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	res := make(collector.MetricReaderMap, len(col.counters)+len(col.gauges))
+	for name, ring := range col.counters {
+		res[name] = ring.GetDiff
+	}
+	for name := range col.gauges {
+		name := name
+		res[name] = func() bitflow.Value {
+			col.lock.Lock()
+			defer col.lock.Unlock()
+			return bitflow.Value(col.gauges[name])
+		}
+	}
+	return res
+}
+
+func (col *Collector) MetricsMetadata() collector.MetricMetadataMap {
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	return col.metadata
+}
+
+type wireguardPeer struct {
+	iface         string
+	publicKey     string
+	endpoint      string
+	lastHandshake time.Time
+	rxBytes       float64
+	txBytes       float64
+}
+
+// readPeers parses the output of 'wg show all dump'. The first line of every interface contains 5 fields
+// (interface, private key, public key, listen port, fwmark), every following peer line contains 9 fields
+// (interface, public key, preshared key, endpoint, allowed ips, latest handshake, rx bytes, tx bytes, keepalive).
+func (col *Collector) readPeers(ctx context.Context) (interfaces []string, peers []wireguardPeer, err error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, col.WgCommand, "show", "all", "dump")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v show all dump failed: %v (%v)", col.WgCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		switch len(fields) {
+		case 5:
+			interfaces = append(interfaces, fields[0])
+		case 9:
+			handshake, err1 := strconv.ParseInt(fields[5], 10, 64)
+			rx, err2 := strconv.ParseFloat(fields[6], 64)
+			tx, err3 := strconv.ParseFloat(fields[7], 64)
+			if err1 != nil || err2 != nil || err3 != nil {
+				return nil, nil, fmt.Errorf("Failed to parse WireGuard peer statistics: %v", scanner.Text())
+			}
+			peer := wireguardPeer{
+				iface:     fields[0],
+				publicKey: fields[1],
+				endpoint:  fields[3],
+				rxBytes:   rx,
+				txBytes:   tx,
+			}
+			if handshake > 0 {
+				peer.lastHandshake = time.Unix(handshake, 0)
+			}
+			peers = append(peers, peer)
+		}
+	}
+	return interfaces, peers, scanner.Err()
+}
+
+func (col *Collector) update(ctx context.Context, checkChange bool) error {
+	interfaces, peers, err := col.readPeers(ctx)
+	if err != nil {
+		return err
+	}
+	counters := make(map[string]float64)
+	gauges := make(map[string]float64)
+	metadata := make(collector.MetricMetadataMap)
+	for _, iface := range interfaces {
+		prefix := "wireguard/" + iface + "/"
+		gauges[prefix+"peers"] = 0
+		gauges[prefix+"peers/reachable"] = 0
+		metadata[prefix+"peers"] = collector.GaugeMetric(collector.UnitCount, "Configured peers")
+		metadata[prefix+"peers/reachable"] = collector.GaugeMetric(collector.UnitCount, "Peers with a recent handshake")
+	}
+	now := time.Now()
+	for _, peer := range peers {
+		prefix := "wireguard/" + peer.iface + "/peer/" + strings.NewReplacer("/", "_", "+", "-").Replace(peer.publicKey) + "/"
+		counters[prefix+"rx"] = peer.rxBytes
+		counters[prefix+"tx"] = peer.txBytes
+		metadata[prefix+"rx"] = collector.DerivedMetric(collector.UnitBytesPerSecond, "Bytes received from the peer")
+		metadata[prefix+"tx"] = collector.DerivedMetric(collector.UnitBytesPerSecond, "Bytes sent to the peer")
+
+		// Peers without any handshake report the age -1
+		age, reachable := -1.0, 0.0
+		if !peer.lastHandshake.IsZero() {
+			age = now.Sub(peer.lastHandshake).Seconds()
+			if now.Sub(peer.lastHandshake) < ReachableHandshakeAge && peer.endpoint != "(none)" {
+				reachable = 1
+			}
+		}
+		gauges[prefix+"handshake-age"] = age
+		gauges[prefix+"reachable"] = reachable
+		gauges["wireguard/"+peer.iface+"/peers"]++
+		gauges["wireguard/"+peer.iface+"/peers/reachable"] += reachable
+		metadata[prefix+"handshake-age"] = collector.GaugeMetric(collector.UnitSeconds, "Time since the latest handshake with the peer")
+		metadata[prefix+"reachable"] = collector.GaugeMetric(collector.UnitNone, "1 if a handshake with the peer was completed recently, 0 otherwise")
+	}
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+	changed := col.counters == nil || len(counters) != len(col.counters) || len(gauges) != len(col.gauges)
+	if col.counters == nil {
+		col.counters = make(map[string]*collector.ValueRing, len(counters))
+	}
+	for name, value := range counters {
+		ring, ok := col.counters[name]
+		if !ok {
+			changed = true
+			ring = col.factory.NewValueRing()
+			col.counters[name] = ring
+		}
+		ring.Add(collector.StoredValue(value))
+	}
+	for name := range col.counters {
+		if _, ok := counters[name]; !ok {
+			delete(col.counters, name)
+		}
+	}
+	for name := range gauges {
+		if _, ok := col.gauges[name]; !ok {
+			changed = true
+		}
+	}
+	col.gauges = gauges
+	col.metadata = metadata
+	if checkChange && changed {
+		return collector.MetricsChanged
+	}
+	return nil
+}
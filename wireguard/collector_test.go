@@ -0,0 +1,111 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antongulenko/golib"
+	"github.com/bitflow-stream/go-bitflow-collector"
+	"github.com/stretchr/testify/suite"
+)
+
+type WireguardTestSuite struct {
+	golib.AbstractTestSuite
+}
+
+func TestWireguard(t *testing.T) {
+	suite.Run(t, new(WireguardTestSuite))
+}
+
+// newCollector returns a Collector that executes a script printing the given output instead of the wg command
+func (suite *WireguardTestSuite) newCollector(dir string, output string, exitCode int) *Collector {
+	script := filepath.Join(dir, "wg")
+	suite.NoError(ioutil.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\nprintf '%v'\nexit %v\n", output, exitCode)), 0755))
+	col := NewWireguardCollector(&collector.ValueRingFactory{Length: 10, Interval: time.Second})
+	col.WgCommand = script
+	return col
+}
+
+func (suite *WireguardTestSuite) TestCollect() {
+	dir, err := ioutil.TempDir("", "wireguard")
+	suite.NoError(err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	now := time.Now().Unix()
+
+	for _, test := range []struct {
+		name     string
+		output   string
+		exitCode int
+		gauges   map[string]float64
+		counters []string
+		metrics  int
+		err      bool
+	}{
+		{
+			name: "peers",
+			output: "wg0\tprivkey\tpubkey\t51820\toff\\n" +
+				fmt.Sprintf("wg0\tpeer/A+\t(none)\t10.0.0.2:51820\t10.1.0.2/32\t%v\t100\t200\t25\\n", now-60) +
+				fmt.Sprintf("wg0\tpeerB\t(none)\t10.0.0.3:51820\t10.1.0.3/32\t%v\t0\t0\toff\\n", now-600) +
+				"wg0\tpeerC\t(none)\t(none)\t10.1.0.4/32\t0\t0\t0\toff\\n" +
+				"wg1\tprivkey\tpubkey\t51821\toff\\n",
+			gauges: map[string]float64{
+				"wireguard/wg0/peers":                    3,
+				"wireguard/wg0/peers/reachable":          1,
+				"wireguard/wg0/peer/peer_A-/reachable":   1,
+				"wireguard/wg0/peer/peerB/reachable":     0,
+				"wireguard/wg0/peer/peerC/reachable":     0,
+				"wireguard/wg0/peer/peerC/handshake-age": -1,
+				"wireguard/wg1/peers":                    0,
+				"wireguard/wg1/peers/reachable":          0,
+			},
+			counters: []string{
+				"wireguard/wg0/peer/peer_A-/rx", "wireguard/wg0/peer/peer_A-/tx",
+				"wireguard/wg0/peer/peerB/rx", "wireguard/wg0/peer/peerB/tx",
+				"wireguard/wg0/peer/peerC/rx", "wireguard/wg0/peer/peerC/tx",
+			},
+			// 2 metrics per interface, 4 metrics per peer
+			metrics: 16,
+		},
+		{
+			name:   "no interfaces",
+			output: "",
+			gauges: map[string]float64{},
+		},
+		{
+			name:   "invalid peer",
+			output: "wg0\tpeerA\t(none)\t10.0.0.2:51820\t10.1.0.2/32\tnever\t100\t200\t25\\n",
+			err:    true,
+		},
+		{
+			name:     "command failed",
+			output:   "Unable to access interface: Operation not permitted",
+			exitCode: 1,
+			err:      true,
+		},
+	} {
+		col := suite.newCollector(dir, test.output, test.exitCode)
+		_, err := col.Init(context.Background())
+		if test.err {
+			suite.Error(err, test.name)
+			continue
+		}
+		suite.NoError(err, test.name)
+		metrics := col.Metrics()
+		for name, value := range test.gauges {
+			suite.Contains(metrics, name, test.name)
+			suite.Equal(value, float64(metrics[name]()), "%v: %v", test.name, name)
+		}
+		for _, name := range test.counters {
+			suite.Contains(metrics, name, test.name)
+		}
+		suite.Len(metrics, test.metrics, test.name)
+		suite.NoError(col.Update(context.Background()), test.name)
+	}
+}